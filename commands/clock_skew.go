@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/git-lfs/git-lfs/lfshttp"
+)
+
+// maxClockSkew is the largest difference between the local clock and that of
+// the LFS server which is not reported as a problem. Servers which sign their
+// authentication tokens with an expiry time may reject them, or clients may
+// discard them as already expired, when the clocks differ by more.
+const maxClockSkew = time.Minute
+
+// clockSkew returns how far the local clock is ahead of the server's, which is
+// negative if it is behind, based on the Date header of the response "res". It
+// returns false if the response has no valid Date header.
+func clockSkew(res *http.Response) (time.Duration, bool) {
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(date), true
+}
+
+// probeClockSkew measures the skew of the local clock against that of the
+// server of the endpoint "e", with a HEAD request which is sent without
+// credentials, since the Date header of any response will do. It returns
+// false if the server couldn't be reached or sent no Date header.
+func probeClockSkew(e lfshttp.Endpoint) (time.Duration, bool) {
+	c := getAPIClient()
+	req, err := c.NewRequest("HEAD", e, "", nil)
+	if err != nil {
+		return 0, false
+	}
+
+	res, _ := c.Do(req)
+	if res == nil {
+		return 0, false
+	}
+	res.Body.Close()
+	return clockSkew(res)
+}
+
+// absDuration returns the absolute value of "d".
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -1,13 +1,21 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tasklog"
+	"github.com/git-lfs/git-lfs/tools"
 	"github.com/git-lfs/git-lfs/tq"
 	"github.com/spf13/cobra"
 )
@@ -17,16 +25,40 @@ var (
 	checkoutBase   bool
 	checkoutOurs   bool
 	checkoutTheirs bool
+
+	checkoutFailOnMissing bool
+	checkoutJSON          bool
+	checkoutQuiet         bool
+	checkoutAllPaths      bool
+
+	checkoutBytes     string
+	checkoutRangeOnly bool
+	checkoutWriteMeta bool
 )
 
 func checkoutCommand(cmd *cobra.Command, args []string) {
 	setupRepository()
+	setupJSONProgress()
 
 	stage, err := whichCheckout()
 	if err != nil {
 		Exit("Error parsing args: %v", err)
 	}
 
+	if checkoutBytes != "" {
+		if checkoutTo == "" || len(args) != 1 {
+			Exit("--bytes requires --to and exactly one path")
+		}
+		checkoutRange(rootedPaths(args)[0], stage)
+		return
+	} else if checkoutRangeOnly {
+		Exit("--range-only requires --bytes")
+	}
+
+	if checkoutWriteMeta && checkoutTo == "" {
+		Exit("--write-meta requires --to")
+	}
+
 	if checkoutTo != "" && stage != git.IndexStageDefault {
 		checkoutConflict(rootedPaths(args)[0], stage)
 		return
@@ -45,24 +77,28 @@ func checkoutCommand(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	var totalBytes int64
-	var pointers []*lfs.WrappedPointer
-	logger := tasklog.NewLogger(os.Stdout,
-		tasklog.ForceProgress(cfg.ForceProgress()),
-	)
-	meter := tq.NewMeter(cfg)
-	meter.Direction = tq.Checkout
-	meter.Logger = meter.LoggerFromEnv(cfg.Os)
-	logger.Enqueue(meter)
+	if !checkoutAllPaths {
+		fetchSparseCheckout = sparseCheckout()
+	}
+
+	// Files not matching --include and --exclude are left as they are,
+	// which for files which were checked out without the filter means as
+	// pointers.
+	includeArg, excludeArg := getIncludeExcludeArgs(cmd)
+	filtered := includeArg != nil || excludeArg != nil
+	filter := buildFilepathFilter(cfg, includeArg, excludeArg, false)
+
+	var pointers, missing []*lfs.WrappedPointer
 	chgitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
 		if err != nil {
 			LoggedError(err, "Scanner error: %s", err)
 			return
 		}
 
-		totalBytes += p.Size
-		meter.Add(p.Size)
-		meter.StartTransfer(p.Name)
+		if !inSparseCheckout(p) || !filter.Allows(p.Name) {
+			return
+		}
+
 		pointers = append(pointers, p)
 	})
 
@@ -73,9 +109,51 @@ func checkoutCommand(cmd *cobra.Command, args []string) {
 	}
 	chgitscanner.Close()
 
-	meter.Start()
+	var out io.Writer = os.Stdout
+	if checkoutJSON || checkoutQuiet {
+		out = ioutil.Discard
+	}
+
+	// When only some files are selected, fetch the objects of those which
+	// are missing, rather than leaving them as pointers.
+	if filtered {
+		downloadMissingObjects(singleCheckout.Manifest(), pointers, out)
+	}
+
+	var totalBytes int64
+	logger := tasklog.NewLogger(out,
+		tasklog.ForceProgress(cfg.ForceProgress()),
+	)
+	meter := tq.NewMeter(cfg)
+	meter.Direction = tq.Checkout
+	meter.Logger = meter.LoggerFromEnv(cfg.Os)
+	meter.Events = events.FromEnvironment(cfg.Os)
+	meter.Quiet = jsonProgress()
+	logger.Enqueue(meter)
 	for _, p := range pointers {
-		singleCheckout.Run(p)
+		totalBytes += p.Size
+		meter.Add(p.Size)
+		meter.StartTransfer(p.Name)
+	}
+
+	var doneBytes int64
+	meter.Start()
+	for i, p := range pointers {
+		// Leave the pointer in place for objects which are not present
+		// locally, rather than failing part of the way through.
+		lfs.LinkOrCopyFromReference(cfg, p.Oid, p.Size)
+		skipped := !cfg.LFSObjectExists(p.Oid, p.Size)
+		if skipped {
+			missing = append(missing, p)
+			restorePointer(p)
+		} else {
+			singleCheckout.Run(p)
+		}
+
+		doneBytes += p.Size
+		if checkoutJSON && !checkoutQuiet {
+			reportCheckoutProgress(p, skipped, i+1, len(pointers), doneBytes, totalBytes)
+		}
 
 		// not strictly correct (parallel) but we don't have a callback & it's just local
 		// plus only 1 slot in channel so it'll block & be close
@@ -85,6 +163,94 @@ func checkoutCommand(cmd *cobra.Command, args []string) {
 
 	meter.Finish()
 	singleCheckout.Close()
+
+	if filtered && !checkoutJSON && !checkoutQuiet {
+		Print("Checked out %d of %d file(s) matching --include and --exclude", len(pointers)-len(missing), len(pointers))
+	}
+
+	reportMissingCheckouts(missing)
+}
+
+// reportCheckoutProgress writes a line of JSON to standard output for --json
+// each time the file "p" has been checked out, or skipped if its object is
+// missing, with the number of files and bytes checked out so far.
+func reportCheckoutProgress(p *lfs.WrappedPointer, skipped bool, doneFiles, totalFiles int, doneBytes, totalBytes int64) {
+	encoded, err := json.Marshal(struct {
+		Name       string `json:"name"`
+		Skipped    bool   `json:"skipped"`
+		DoneFiles  int    `json:"files_done"`
+		TotalFiles int    `json:"files_total"`
+		DoneBytes  int64  `json:"bytes_done"`
+		TotalBytes int64  `json:"bytes_total"`
+	}{p.Name, skipped, doneFiles, totalFiles, doneBytes, totalBytes})
+	if err != nil {
+		ExitWithError(err)
+	}
+	Print(string(encoded))
+}
+
+// restorePointer writes the pointer "p" to its path in the working tree if
+// there is no file there, so that a file whose object is missing is left as a
+// pointer, as Git would check it out. Existing files are left alone.
+func restorePointer(p *lfs.WrappedPointer) {
+	path := filepath.Join(cfg.LocalWorkingDir(), p.Name)
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		return
+	}
+
+	if err := tools.MkdirAll(filepath.Dir(path), cfg); err != nil {
+		LoggedError(err, "Checkout error: %s", err)
+		return
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		LoggedError(err, "Checkout error: %s", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := p.Encode(f); err != nil {
+		LoggedError(err, "Checkout error: %s", err)
+	}
+}
+
+// reportMissingCheckouts reports the files which were not checked out because
+// their objects are not present locally, exiting with an error if
+// --fail-on-missing was given.
+func reportMissingCheckouts(missing []*lfs.WrappedPointer) {
+	if checkoutJSON {
+		type skippedFile struct {
+			Name string `json:"name"`
+			Oid  string `json:"oid"`
+			Size int64  `json:"size"`
+		}
+
+		skipped := make([]*skippedFile, 0, len(missing))
+		for _, p := range missing {
+			skipped = append(skipped, &skippedFile{p.Name, p.Oid, p.Size})
+		}
+
+		encoded, err := json.Marshal(struct {
+			Skipped []*skippedFile `json:"skipped"`
+		}{skipped})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+	} else if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		Error("Skipped checkout for %d file(s) whose Git LFS objects are not present locally:", len(missing))
+		for _, p := range missing {
+			Error("\t%s", p.Name)
+			names = append(names, p.Name)
+		}
+		Error("Run `git lfs pull --include=%q` to download them.", strings.Join(names, ","))
+	}
+
+	if checkoutFailOnMissing && len(missing) > 0 {
+		os.Exit(2)
+	}
 }
 
 func checkoutConflict(file string, stage git.IndexStage) {
@@ -94,8 +260,220 @@ func checkoutConflict(file string, stage git.IndexStage) {
 		return
 	}
 
+	p, sha := stagedPointer(file, stage)
+
+	var contentType string
+	if checkoutWriteMeta {
+		var err error
+		if contentType, err = downloadMissingObject(singleCheckout.Manifest(), p); err != nil {
+			Exit("Error checking out %v to %q: %v", sha, checkoutTo, err)
+		}
+	}
+
+	if err := singleCheckout.RunToPath(p, checkoutTo); err != nil {
+		Exit("Error checking out %v to %q: %v", sha, checkoutTo, err)
+	}
+	singleCheckout.Close()
+
+	if checkoutWriteMeta {
+		writeCheckoutMeta(p, contentType)
+	}
+}
+
+// downloadMissingObject downloads the object for "p" to the local store if it
+// isn't there already, and returns the Content-Type the server sent with it,
+// if any. Objects which are present locally have no Content-Type.
+func downloadMissingObject(m *tq.Manifest, p *lfs.WrappedPointer) (string, error) {
+	lfs.LinkOrCopyFromReference(cfg, p.Oid, p.Size)
+	if cfg.LFSObjectExists(p.Oid, p.Size) {
+		return "", nil
+	}
+
+	ref, _ := git.CurrentRef()
+	q := tq.NewTransferQueue(tq.Download, m, cfg.Remote(), tq.RemoteRef(ref))
+	watch := q.Watch()
+	q.Add(downloadTransfer(p))
+	q.Wait()
+
+	var contentType string
+	for t := range watch {
+		if t.Oid == p.Oid {
+			contentType = t.ContentType
+		}
+	}
+
+	if errs := q.Errors(); len(errs) > 0 {
+		return "", errs[0]
+	}
+	return contentType, nil
+}
+
+// downloadMissingObjects downloads the objects for "pointers" which are not in
+// the local store from the default remote, showing their progress on "out".
+// Files whose objects couldn't be downloaded are reported, and left to be
+// skipped by the checkout.
+func downloadMissingObjects(m *tq.Manifest, pointers []*lfs.WrappedPointer, out io.Writer) {
+	var missing []*lfs.WrappedPointer
+	seen := make(map[string]bool, len(pointers))
+	for _, p := range pointers {
+		if seen[p.Oid] {
+			continue
+		}
+		seen[p.Oid] = true
+
+		lfs.LinkOrCopyFromReference(cfg, p.Oid, p.Size)
+		if !cfg.LFSObjectExists(p.Oid, p.Size) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	logger := tasklog.NewLogger(out,
+		tasklog.ForceProgress(cfg.ForceProgress()),
+	)
+	meter := buildProgressMeter(false, tq.Download)
+	logger.Enqueue(meter)
+
+	ref, _ := git.CurrentRef()
+	q := tq.NewTransferQueue(tq.Download, m, cfg.Remote(),
+		tq.RemoteRef(ref), tq.WithProgress(meter))
+	for _, p := range missing {
+		meter.Add(p.Size)
+		q.Add(downloadTransfer(p))
+	}
+	q.Wait()
+
+	for _, err := range q.Errors() {
+		FullError(err)
+	}
+}
+
+// checkoutMeta is the metadata written by --write-meta to the path given by
+// --to with ".meta" appended.
+type checkoutMeta struct {
+	Name        string `json:"name"`
+	Oid         string `json:"oid"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// writeCheckoutMeta writes the metadata of the object for "p", which was
+// checked out to the path given by --to, next to it.
+func writeCheckoutMeta(p *lfs.WrappedPointer, contentType string) {
+	encoded, err := json.Marshal(&checkoutMeta{
+		Name:        p.Name,
+		Oid:         p.Oid,
+		Size:        p.Size,
+		ContentType: contentType,
+	})
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	metaPath := checkoutTo + ".meta"
+	if err := ioutil.WriteFile(metaPath, append(encoded, '\n'), 0644); err != nil {
+		Exit("Error writing metadata to %q: %v", metaPath, err)
+	}
+}
+
+// checkoutRange writes the bytes given by --bytes of the version of "file"
+// at "stage" of the index to the path given by --to, reading them from the
+// local object if it is present, and downloading only those bytes otherwise.
+func checkoutRange(file string, stage git.IndexStage) {
+	p, sha := stagedPointer(file, stage)
+
+	from, to, err := parseByteRange(checkoutBytes, p.Size)
+	if err != nil {
+		Exit("Invalid --bytes %q: %v", checkoutBytes, err)
+	}
+
+	if err := tools.MkdirAll(filepath.Dir(checkoutTo), cfg); err != nil {
+		Exit("Error checking out %v to %q: %v", sha, checkoutTo, err)
+	}
+	f, err := os.Create(checkoutTo)
+	if err != nil {
+		Exit("Error checking out %v to %q: %v", sha, checkoutTo, err)
+	}
+
+	var contentType string
+	lfs.LinkOrCopyFromReference(cfg, p.Oid, p.Size)
+	if cfg.LFSObjectExists(p.Oid, p.Size) {
+		err = copyLocalRange(f, p, from, to)
+	} else {
+		remote := cfg.Remote()
+		ref, _ := git.CurrentRef()
+		m := getTransferManifestOperationRemote("download", remote)
+		t := &tq.Transfer{Name: p.Name, Oid: p.Oid, Size: p.Size}
+		err = tq.DownloadRange(m, remote, ref, t, from, to, checkoutRangeOnly, f)
+		contentType = t.ContentType
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(checkoutTo)
+		Exit("Error checking out bytes %d-%d of %v to %q: %v", from, to, sha, checkoutTo, err)
+	}
+
+	if checkoutWriteMeta {
+		writeCheckoutMeta(p, contentType)
+	}
+}
+
+// copyLocalRange writes the bytes from "from" to "to", inclusive, of the local
+// object for "p" to "w".
+func copyLocalRange(w io.Writer, p *lfs.WrappedPointer, from, to int64) error {
+	f, err := os.Open(cfg.Filesystem().ObjectReadPathname(p.Oid, p.Size))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, io.NewSectionReader(f, from, to-from+1))
+	return err
+}
+
+// parseByteRange parses a range given to --bytes, of the form "FROM-TO" or
+// "FROM-", where both are byte offsets and TO is inclusive, for an object of
+// "size" bytes. TO is clamped to the end of the object.
+func parseByteRange(s string, size int64) (int64, int64, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected FROM-TO")
+	}
+
+	from, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || from < 0 {
+		return 0, 0, fmt.Errorf("invalid start %q", parts[0])
+	}
+
+	to := size - 1
+	if len(parts[1]) > 0 {
+		if to, err = strconv.ParseInt(parts[1], 10, 64); err != nil || to < from {
+			return 0, 0, fmt.Errorf("invalid end %q", parts[1])
+		}
+		if to >= size {
+			to = size - 1
+		}
+	}
+
+	if from >= size {
+		return 0, 0, fmt.Errorf("start is beyond the end of the %d byte object", size)
+	}
+	return from, to, nil
+}
+
+// stagedPointer returns the pointer of the version of "file" at "stage" of the
+// index, along with the SHA of its blob, exiting if there isn't one.
+func stagedPointer(file string, stage git.IndexStage) (*lfs.WrappedPointer, string) {
 	ref, err := git.ResolveRef(fmt.Sprintf(":%d:%s", stage, file))
 	if err != nil {
+		if stage == git.IndexStageDefault {
+			Exit("Could not checkout %q: %v", file, err)
+		}
 		Exit("Could not checkout (are you not in the middle of a merge?): %v", err)
 	}
 
@@ -113,12 +491,7 @@ func checkoutConflict(file string, stage git.IndexStage) {
 		Exit("Could not find decoder pointer for object %q: %v", ref.Sha, err)
 	}
 
-	p := &lfs.WrappedPointer{Name: file, Pointer: ptr}
-
-	if err := singleCheckout.RunToPath(p, checkoutTo); err != nil {
-		Exit("Error checking out %v to %q: %v", ref.Sha, checkoutTo, err)
-	}
-	singleCheckout.Close()
+	return &lfs.WrappedPointer{Name: file, Pointer: ptr}, ref.Sha
 }
 
 func whichCheckout() (stage git.IndexStage, err error) {
@@ -166,5 +539,15 @@ func init() {
 		cmd.Flags().BoolVar(&checkoutOurs, "ours", false, "Checkout our version of a conflicted file")
 		cmd.Flags().BoolVar(&checkoutTheirs, "theirs", false, "Checkout their version of a conflicted file")
 		cmd.Flags().BoolVar(&checkoutBase, "base", false, "Checkout the base version of a conflicted file")
+		cmd.Flags().BoolVar(&checkoutFailOnMissing, "fail-on-missing", false, "Fail if any objects are not present locally")
+		cmd.Flags().BoolVar(&checkoutJSON, "json", false, "Print progress and the files skipped for missing objects as JSON")
+		cmd.Flags().BoolVarP(&checkoutQuiet, "quiet", "q", false, "Don't show progress")
+		cmd.Flags().BoolVar(&jsonProgressArg, "json-progress", false, "Print progress as a stream of JSON events to standard error")
+		cmd.Flags().StringVarP(&includeArg, "include", "I", "", "Check out only files matching these paths, fetching their objects if missing")
+		cmd.Flags().StringVarP(&excludeArg, "exclude", "X", "", "Don't check out files matching these paths")
+		cmd.Flags().BoolVar(&checkoutAllPaths, "all-paths", false, "Checkout files outside the sparse checkout too")
+		cmd.Flags().StringVar(&checkoutBytes, "bytes", "", "Checkout only this range of bytes, FROM-TO, to the path given by --to")
+		cmd.Flags().BoolVar(&checkoutRangeOnly, "range-only", false, "With --bytes, fail if the server would send the whole object")
+		cmd.Flags().BoolVar(&checkoutWriteMeta, "write-meta", false, "With --to, write the object's metadata, including its Content-Type, to the path with .meta appended")
 	})
 }
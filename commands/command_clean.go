@@ -1,10 +1,17 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/spf13/cobra"
@@ -67,7 +74,9 @@ func clean(gf *lfs.GitFilter, to io.Writer, from io.Reader, fileName string, fil
 
 	tmpfile := cleaned.Filename
 	mediafile, err := gf.ObjectPath(cleaned.Oid)
-	if err != nil {
+	if fs.IsStorageError(err) {
+		ExitWithError(errors.Wrap(err, "Error cleaning LFS object"))
+	} else if err != nil {
 		Panic(err, "Unable to get local media path.")
 	}
 
@@ -78,7 +87,15 @@ func clean(gf *lfs.GitFilter, to io.Writer, from io.Reader, fileName string, fil
 		Debug("%s exists", mediafile)
 	} else {
 		if err := os.Rename(tmpfile, mediafile); err != nil {
-			Panic(err, "Unable to move %s to %s\n", tmpfile, mediafile)
+			// Another file with the same contents may have been
+			// cleaned at the same time, as by 'git lfs migrate
+			// import --workers'.
+			if stat, _ := os.Stat(mediafile); stat == nil {
+				if err = fs.NewStorageError(mediafile, err); fs.IsStorageError(err) {
+					ExitWithError(errors.Wrap(err, "Error cleaning LFS object"))
+				}
+				Panic(err, "Unable to move %s to %s\n", tmpfile, mediafile)
+			}
 		}
 
 		Debug("Writing %s", mediafile)
@@ -88,6 +105,57 @@ func clean(gf *lfs.GitFilter, to io.Writer, from io.Reader, fileName string, fil
 	return cleaned.Pointer, err
 }
 
+var (
+	magicOnce    sync.Once
+	magicMatcher *git.AttributeMatcher
+	magicTypes   *lfs.ContentTypes
+)
+
+// cleanByContents cleans the contents read from "from" as clean does, unless
+// the file "fileName" is matched only by the catch-all pattern written by `git
+// lfs track --magic`, and its contents are not of one of the types given by
+// lfs.magic.types, in which case they are written to "to" unchanged, and no
+// pointer is returned.
+func cleanByContents(gf *lfs.GitFilter, to io.Writer, from io.Reader, fileName string) (*lfs.Pointer, error) {
+	if len(fileName) > 0 && isTrackedByContents(fileName) {
+		br := bufio.NewReaderSize(from, lfs.MagicHeaderSize)
+		header, _ := br.Peek(lfs.MagicHeaderSize)
+		if !magicTypes.Match(header) {
+			Debug("Not cleaning %s, since its contents are not of a type in lfs.magic.types", fileName)
+			_, err := io.Copy(to, br)
+			return nil, err
+		}
+		from = br
+	}
+	return clean(gf, to, from, fileName, -1)
+}
+
+// isTrackedByContents returns whether the file at "name", relative to the root
+// of the working copy, is matched by a catch-all pattern with the lfs-magic
+// attribute before any other pattern setting the filter attribute.
+//
+// The attributes files are only read in full if the one at the root of the
+// working copy, where `git lfs track --magic` writes the catch-all pattern,
+// mentions the attribute at all, so that other repositories don't pay for
+// it.
+func isTrackedByContents(name string) bool {
+	magicOnce.Do(func() {
+		root, err := ioutil.ReadFile(filepath.Join(cfg.LocalWorkingDir(), ".gitattributes"))
+		if err != nil || !bytes.Contains(root, []byte(git.MagicAttrib)) {
+			return
+		}
+
+		magicMatcher = git.NewAttributeMatcher(getAllKnownPatterns())
+		magicTypes = lfs.NewContentTypes(cfg.Git.GetAll("lfs.magic.types"))
+	})
+
+	if magicMatcher == nil {
+		return false
+	}
+	p := magicMatcher.Match(name)
+	return p != nil && p.Tracked && p.Magic
+}
+
 func cleanCommand(cmd *cobra.Command, args []string) {
 	requireStdin("This command should be run by the Git 'clean' filter")
 	installHooks(false)
@@ -98,7 +166,7 @@ func cleanCommand(cmd *cobra.Command, args []string) {
 	}
 
 	gitfilter := lfs.NewGitFilter(cfg)
-	ptr, err := clean(gitfilter, os.Stdout, os.Stdin, fileName, -1)
+	ptr, err := cleanByContents(gitfilter, os.Stdout, os.Stdin, fileName)
 	if err != nil {
 		Error(err.Error())
 	}
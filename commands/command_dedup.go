@@ -111,7 +111,7 @@ func dedup(p *lfs.WrappedPointer) (success bool, err error) {
 	}
 
 	// Do clone
-	srcFile := cfg.Filesystem().ObjectPathname(p.Oid)
+	srcFile := cfg.Filesystem().ObjectReadPathname(p.Oid, p.Size)
 	dstFile := filepath.Join(cfg.LocalWorkingDir(), p.Name)
 
 	// Clone the file. This overwrites the destination if it exists.
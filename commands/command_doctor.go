@@ -0,0 +1,350 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+	"github.com/spf13/cobra"
+)
+
+const (
+	doctorPass = "PASS"
+	doctorWarn = "WARN"
+	doctorFail = "FAIL"
+)
+
+var (
+	doctorJSON = false
+
+	// doctorIntegerKeys are configuration keys whose values must be
+	// integers. Values which are not integers are silently ignored by the
+	// code which reads them.
+	doctorIntegerKeys = []string{
+		"lfs.activitytimeout",
+		"lfs.concurrenttransfers",
+		"lfs.credcachettl",
+		"lfs.dialtimeout",
+		"lfs.fetchrecentcommitsdays",
+		"lfs.fetchrecentrefsdays",
+		"lfs.keepalive",
+		"lfs.pruneincomingoffsetdays",
+		"lfs.pruneoffsetdays",
+		"lfs.pruneoutgoingoffsetdays",
+		"lfs.tlstimeout",
+		"lfs.transfer.batchsize",
+		"lfs.transfer.dnscachettl",
+		"lfs.transfer.maxidleconnsperhost",
+		"lfs.transfer.maxretries",
+		"lfs.transfer.maxretrydelay",
+		"lfs.transfer.maxverifies",
+	}
+)
+
+// doctorCheck is the result of a single diagnostic check.
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+
+	// SkewSeconds is set by the clock check to the number of seconds by
+	// which the local clock is ahead of the server's, if it was measured.
+	SkewSeconds *float64 `json:"skew_seconds,omitempty"`
+}
+
+func doctorCommand(cmd *cobra.Command, args []string) {
+	checks := []*doctorCheck{doctorCheckFilters()}
+
+	if cfg.InRepo() {
+		checks = append(checks, doctorCheckConfig()...)
+		checks = append(checks, doctorCheckEndpoint()...)
+		checks = append(checks,
+			doctorCheckStorage(),
+			doctorCheckDiskSpace(),
+		)
+	} else {
+		checks = append(checks, &doctorCheck{
+			Name:    "repository",
+			Status:  doctorWarn,
+			Message: "not in a Git repository; skipping repository checks",
+		})
+	}
+
+	status := doctorPass
+	for _, check := range checks {
+		if check.Status == doctorFail {
+			status = doctorFail
+		} else if check.Status == doctorWarn && status == doctorPass {
+			status = doctorWarn
+		}
+	}
+
+	if doctorJSON {
+		ret := struct {
+			Status string         `json:"status"`
+			Checks []*doctorCheck `json:"checks"`
+		}{status, checks}
+
+		encoded, err := json.Marshal(ret)
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+	} else {
+		var width int
+		for _, check := range checks {
+			width = tools.MaxInt(width, len(check.Name))
+		}
+		for _, check := range checks {
+			Print("%s  %-*s  %s", check.Status, width, check.Name, check.Message)
+		}
+	}
+
+	if status == doctorFail {
+		os.Exit(1)
+	}
+}
+
+// doctorCheckFilters checks that the Git LFS filters are installed, and point
+// at Git LFS.
+func doctorCheckFilters() *doctorCheck {
+	check := &doctorCheck{Name: "filters", Status: doctorPass}
+
+	var missing, foreign []string
+	for _, key := range []string{"filter.lfs.clean", "filter.lfs.smudge", "filter.lfs.process"} {
+		value, _ := cfg.Git.Get(key)
+		if len(value) == 0 {
+			missing = append(missing, key)
+		} else if !strings.Contains(value, "git-lfs") {
+			foreign = append(foreign, fmt.Sprintf("%s = %q", key, value))
+		}
+	}
+
+	if len(missing) > 0 {
+		check.Status = doctorFail
+		check.Message = fmt.Sprintf("%s not set; run `git lfs install`", strings.Join(missing, ", "))
+	} else if len(foreign) > 0 {
+		check.Status = doctorWarn
+		check.Message = fmt.Sprintf("%s does not run Git LFS", strings.Join(foreign, ", "))
+	} else if !cfg.Git.Bool("filter.lfs.required", false) {
+		check.Status = doctorWarn
+		check.Message = "filter.lfs.required is not true; filter failures will be ignored"
+	} else {
+		check.Message = "Git LFS filters are installed"
+	}
+	return check
+}
+
+// doctorCheckConfig checks the values of configuration keys which would
+// otherwise be ignored, or fall back to a default, without notice.
+func doctorCheckConfig() []*doctorCheck {
+	var checks []*doctorCheck
+	for _, key := range doctorIntegerKeys {
+		value, ok := cfg.Git.Get(key)
+		if !ok {
+			continue
+		}
+		if _, err := strconv.Atoi(value); err != nil {
+			checks = append(checks, &doctorCheck{
+				Name:    "config",
+				Status:  doctorWarn,
+				Message: fmt.Sprintf("%s = %q is not an integer and will be ignored", key, value),
+			})
+		}
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, &doctorCheck{
+			Name:    "config",
+			Status:  doctorPass,
+			Message: "configuration is valid",
+		})
+	}
+	return checks
+}
+
+// doctorCheckEndpoint checks that the LFS API of the default remote is
+// configured and responds to a batch request. When it does, the server's clock
+// is also compared with the local one, as a large difference between them can
+// cause authentication to fail.
+func doctorCheckEndpoint() []*doctorCheck {
+	check := &doctorCheck{Name: "endpoint"}
+
+	c := getAPIClient()
+	remote := cfg.Remote()
+	e := c.Endpoints.Endpoint("download", remote)
+	if len(e.Url) == 0 {
+		check.Status = doctorFail
+		check.Message = fmt.Sprintf("no Git LFS endpoint for remote %q", remote)
+		return []*doctorCheck{check}
+	}
+
+	u, err := url.Parse(e.Url)
+	if err != nil {
+		check.Status = doctorFail
+		check.Message = fmt.Sprintf("invalid endpoint %q: %s", e.Url, err)
+		return []*doctorCheck{check}
+	}
+
+	switch u.Scheme {
+	case "http", "https", "ssh":
+	case "file":
+		check.Status = doctorPass
+		check.Message = fmt.Sprintf("%s is served by the standalone file transfer agent", e.Url)
+		return []*doctorCheck{check}
+	case "sftp":
+		check.Status = doctorPass
+		check.Message = fmt.Sprintf("%s is served by the standalone SFTP transfer agent", e.Url)
+		return []*doctorCheck{check}
+	default:
+		check.Status = doctorWarn
+		check.Message = fmt.Sprintf("endpoint %q has unsupported scheme %q", e.Url, u.Scheme)
+		return []*doctorCheck{check}
+	}
+
+	// Probe with a download request for the empty object, which the
+	// server needn't have, and which transfers no data if it does.
+	req, err := c.NewRequest("POST", e, "objects/batch", map[string]interface{}{
+		"operation": "download",
+		"objects": []map[string]interface{}{
+			{"oid": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "size": 0},
+		},
+	})
+	if err != nil {
+		check.Status = doctorFail
+		check.Message = fmt.Sprintf("cannot create batch request for %s: %s", e.Url, err)
+		return []*doctorCheck{check}
+	}
+
+	res, err := c.DoAPIRequestWithAuth(remote, req)
+	if err == nil && res.StatusCode != http.StatusOK {
+		err = lfshttp.NewStatusCodeError(res)
+	}
+	if res != nil {
+		res.Body.Close()
+	}
+	if err != nil {
+		check.Status = doctorFail
+		check.Message = fmt.Sprintf("batch request to %s failed: %s", e.Url, err)
+		return []*doctorCheck{check}
+	}
+
+	check.Status = doctorPass
+	check.Message = fmt.Sprintf("%s is reachable", e.Url)
+	return []*doctorCheck{check, doctorCheckClock(res)}
+}
+
+// doctorCheckClock compares the local clock with the Date header of the given
+// server response.
+func doctorCheckClock(res *http.Response) *doctorCheck {
+	check := &doctorCheck{Name: "clock"}
+
+	skew, ok := clockSkew(res)
+	if !ok {
+		check.Status = doctorWarn
+		check.Message = "unable to determine the server's time"
+		return check
+	}
+
+	seconds := skew.Round(time.Second).Seconds()
+	check.SkewSeconds = &seconds
+	if absDuration(skew) > maxClockSkew {
+		check.Status = doctorWarn
+		check.Message = fmt.Sprintf("local clock differs from the server's by %s; authentication may fail", absDuration(skew).Round(time.Second))
+	} else {
+		check.Status = doctorPass
+		check.Message = "local clock agrees with the server's"
+	}
+	return check
+}
+
+// doctorCheckStorage checks that objects can be written to the local object
+// store, and to the temporary directory the clean filter writes them to first.
+func doctorCheckStorage() *doctorCheck {
+	check := &doctorCheck{Name: "storage"}
+
+	dir := cfg.LFSObjectDir()
+	for _, d := range []string{dir, cfg.TempDir()} {
+		if err := tools.MkdirAll(d, cfg); err != nil {
+			check.Status = doctorFail
+			check.Message = doctorStorageMessage(d, "cannot create %s: %s", err)
+			return check
+		}
+
+		f, err := ioutil.TempFile(d, "doctor")
+		if err != nil {
+			check.Status = doctorFail
+			check.Message = doctorStorageMessage(d, "%s is not writable: %s", err)
+			return check
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	check.Status = doctorPass
+	check.Message = fmt.Sprintf("object store %s is writable", dir)
+	return check
+}
+
+// doctorStorageMessage describes the failure "err" to write to "dir", saying
+// what to do about it if the filesystem is full or read-only, or its
+// permissions don't allow it, and otherwise as "format" does.
+func doctorStorageMessage(dir, format string, err error) string {
+	if err = fs.NewStorageError(dir, err); fs.IsStorageError(err) {
+		return err.Error()
+	}
+	return fmt.Sprintf(format, dir, err)
+}
+
+// doctorCheckDiskSpace checks that there is enough free space in the object
+// store to fetch the objects of the current checkout which are not yet
+// present locally.
+func doctorCheckDiskSpace() *doctorCheck {
+	check := &doctorCheck{Name: "disk"}
+
+	var pending uint64
+	var count int
+	pointers, err := pointersToFetchForRef("HEAD", buildFilepathFilter(cfg, nil, nil, true))
+	if err != nil {
+		// There is nothing to fetch before the first commit.
+		pointers = nil
+	}
+	for _, p := range pointers {
+		if !cfg.LFSObjectExists(p.Oid, p.Size) {
+			pending += uint64(p.Size)
+			count++
+		}
+	}
+
+	free, err := tools.DiskFree(cfg.LFSObjectDir())
+	if err != nil {
+		check.Status = doctorWarn
+		check.Message = fmt.Sprintf("unable to determine free disk space: %s", err)
+		return check
+	}
+
+	check.Message = fmt.Sprintf("%s free, %s needed to fetch %d object(s)",
+		humanize.FormatBytes(free), humanize.FormatBytes(pending), count)
+	if free < pending {
+		check.Status = doctorFail
+	} else {
+		check.Status = doctorPass
+	}
+	return check
+}
+
+func init() {
+	RegisterCommand("doctor", doctorCommand, func(cmd *cobra.Command) {
+		cmd.Flags().BoolVarP(&doctorJSON, "json", "", false, "print output in JSON")
+	})
+}
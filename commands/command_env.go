@@ -1,12 +1,56 @@
 package commands
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
+	"github.com/git-lfs/git-lfs/lfshttp"
 	"github.com/spf13/cobra"
 )
 
+var (
+	envJSON       bool
+	envCheckClock bool
+)
+
+// envEndpoint is an endpoint listed by "git lfs env --json".
+type envEndpoint struct {
+	Remote string `json:"remote"`
+	URL    string `json:"url"`
+	Auth   string `json:"auth"`
+	SSH    string `json:"ssh,omitempty"`
+}
+
+// envFilter describes how Git runs the LFS filter, as configured.
+type envFilter struct {
+	Process  string `json:"process"`
+	Smudge   string `json:"smudge"`
+	Clean    string `json:"clean"`
+	Required bool   `json:"required"`
+
+	// Mode is "process" if Git runs a single "git lfs filter-process"
+	// for all files, "clean-smudge" if it runs "git lfs clean" and "git
+	// lfs smudge" once per file, and "none" if no filter is configured.
+	Mode    string `json:"mode"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// envClock is the skew of the local clock against that of the LFS server of
+// the default remote, as measured by "git lfs env --check-clock".
+type envClock struct {
+	Remote string `json:"remote"`
+	URL    string `json:"url"`
+	// SkewSeconds is the number of seconds by which the local clock is
+	// ahead of the server's, or behind it if negative.
+	SkewSeconds float64 `json:"skew_seconds"`
+	Warning     string  `json:"warning,omitempty"`
+}
+
 func envCommand(cmd *cobra.Command, args []string) {
 	config.ShowConfigWarnings = true
 
@@ -15,20 +59,13 @@ func envCommand(cmd *cobra.Command, args []string) {
 		gitV = "Error getting git version: " + err.Error()
 	}
 
-	Print(config.VersionDesc)
-	Print(gitV)
-	Print("")
-
+	var endpoints []*envEndpoint
 	defaultRemote := ""
 	if cfg.IsDefaultRemote() {
 		defaultRemote = cfg.Remote()
 		endpoint := getAPIClient().Endpoints.Endpoint("download", defaultRemote)
 		if len(endpoint.Url) > 0 {
-			access := getAPIClient().Endpoints.AccessFor(endpoint.Url)
-			Print("Endpoint=%s (auth=%s)", endpoint.Url, access.Mode())
-			if len(endpoint.SshUserAndHost) > 0 {
-				Print("  SSH=%s:%s", endpoint.SshUserAndHost, endpoint.SshPath)
-			}
+			endpoints = append(endpoints, newEnvEndpoint(defaultRemote, endpoint))
 		}
 	}
 
@@ -36,24 +73,145 @@ func envCommand(cmd *cobra.Command, args []string) {
 		if remote == defaultRemote {
 			continue
 		}
-		remoteEndpoint := getAPIClient().Endpoints.Endpoint("download", remote)
-		remoteAccess := getAPIClient().Endpoints.AccessFor(remoteEndpoint.Url)
-		Print("Endpoint (%s)=%s (auth=%s)", remote, remoteEndpoint.Url, remoteAccess.Mode())
-		if len(remoteEndpoint.SshUserAndHost) > 0 {
-			Print("  SSH=%s:%s", remoteEndpoint.SshUserAndHost, remoteEndpoint.SshPath)
+		endpoints = append(endpoints, newEnvEndpoint(remote, getAPIClient().Endpoints.Endpoint("download", remote)))
+	}
+
+	environ := lfs.Environ(cfg, getTransferManifest(), oldEnv)
+	filter := findEnvFilter()
+
+	var clock *envClock
+	if envCheckClock {
+		clock = findEnvClock()
+	}
+
+	if envJSON {
+		environment := make(map[string]string, len(environ))
+		for _, env := range environ {
+			if parts := strings.SplitN(env, "=", 2); len(parts) == 2 {
+				environment[parts[0]] = parts[1]
+			}
 		}
+
+		encoded, err := json.MarshalIndent(struct {
+			Version     string            `json:"version"`
+			GitVersion  string            `json:"git_version"`
+			Endpoints   []*envEndpoint    `json:"endpoints"`
+			Environment map[string]string `json:"environment"`
+			Filter      *envFilter        `json:"filter"`
+			Clock       *envClock         `json:"clock,omitempty"`
+		}{config.VersionDesc, gitV, endpoints, environment, filter, clock}, "", "  ")
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+		return
 	}
 
-	for _, env := range lfs.Environ(cfg, getTransferManifest(), oldEnv) {
+	Print(config.VersionDesc)
+	Print(gitV)
+	Print("")
+
+	for _, e := range endpoints {
+		if e.Remote == defaultRemote {
+			Print("Endpoint=%s (auth=%s)", e.URL, e.Auth)
+		} else {
+			Print("Endpoint (%s)=%s (auth=%s)", e.Remote, e.URL, e.Auth)
+		}
+		if len(e.SSH) > 0 {
+			Print("  SSH=%s", e.SSH)
+		}
+	}
+
+	for _, env := range environ {
 		Print(env)
 	}
 
-	for _, key := range []string{"filter.lfs.process", "filter.lfs.smudge", "filter.lfs.clean"} {
+	for _, key := range []string{"filter.lfs.process", "filter.lfs.smudge", "filter.lfs.clean", "filter.lfs.required"} {
 		value, _ := cfg.Git.Get(key)
 		Print("git config %s = %q", key, value)
 	}
+	Print("FilterMode=%s", filter.Mode)
+	if len(filter.Warning) > 0 {
+		Error("warning: %s", filter.Warning)
+	}
+
+	if envCheckClock {
+		if clock == nil {
+			Error("warning: unable to determine the time of the Git LFS server")
+			return
+		}
+		Print("ClockSkew=%gs", clock.SkewSeconds)
+		if len(clock.Warning) > 0 {
+			Error("warning: %s", clock.Warning)
+		}
+	}
+}
+
+func newEnvEndpoint(remote string, e lfshttp.Endpoint) *envEndpoint {
+	access := getAPIClient().Endpoints.AccessFor(e.Url)
+	ee := &envEndpoint{
+		Remote: remote,
+		URL:    e.Url,
+		Auth:   string(access.Mode()),
+	}
+	if len(e.SshUserAndHost) > 0 {
+		ee.SSH = fmt.Sprintf("%s:%s", e.SshUserAndHost, e.SshPath)
+	}
+	return ee
+}
+
+// findEnvFilter returns the configuration of the LFS filter, and which of its
+// commands Git will run.
+func findEnvFilter() *envFilter {
+	f := &envFilter{Mode: "none"}
+	f.Process, _ = cfg.Git.Get("filter.lfs.process")
+	f.Smudge, _ = cfg.Git.Get("filter.lfs.smudge")
+	f.Clean, _ = cfg.Git.Get("filter.lfs.clean")
+	f.Required = cfg.Git.Bool("filter.lfs.required", false)
+
+	// Git has supported filter processes since 2.11.0, and prefers them
+	// to the clean and smudge commands when both are configured.
+	if len(f.Process) > 0 && git.IsGitVersionAtLeast("2.11.0") {
+		f.Mode = "process"
+	} else if len(f.Smudge) > 0 || len(f.Clean) > 0 {
+		f.Mode = "clean-smudge"
+		if len(f.Process) > 0 {
+			f.Warning = "this version of Git doesn't support filter.lfs.process, so it runs a Git LFS process for each file, which is slower. Upgrade Git to 2.11.0 or later."
+		} else {
+			f.Warning = "filter.lfs.process is not set, so Git runs a Git LFS process for each file, which is slower. Run `git lfs install` to set it."
+		}
+	}
+	return f
+}
+
+// findEnvClock measures the skew of the local clock against that of the LFS
+// server of the default remote, returning nil if it couldn't be measured.
+func findEnvClock() *envClock {
+	remote := cfg.Remote()
+	e := getAPIClient().Endpoints.Endpoint("download", remote)
+	if len(e.Url) == 0 {
+		return nil
+	}
+
+	skew, ok := probeClockSkew(e)
+	if !ok {
+		return nil
+	}
+
+	clock := &envClock{
+		Remote:      remote,
+		URL:         e.Url,
+		SkewSeconds: skew.Round(time.Second).Seconds(),
+	}
+	if absDuration(skew) > maxClockSkew {
+		clock.Warning = fmt.Sprintf("local clock differs from that of %s by %s; authentication tokens may appear to have expired", e.Url, absDuration(skew).Round(time.Second))
+	}
+	return clock
 }
 
 func init() {
-	RegisterCommand("env", envCommand, nil)
+	RegisterCommand("env", envCommand, func(cmd *cobra.Command) {
+		cmd.Flags().BoolVar(&envJSON, "json", false, "Print the environment as a JSON object")
+		cmd.Flags().BoolVar(&envCheckClock, "check-clock", false, "Compare the local clock with the LFS server's")
+	})
 }
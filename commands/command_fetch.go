@@ -1,25 +1,399 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tasklog"
+	"github.com/git-lfs/git-lfs/tools/humanize"
 	"github.com/git-lfs/git-lfs/tq"
 	"github.com/rubyist/tracerx"
 	"github.com/spf13/cobra"
 )
 
 var (
-	fetchRecentArg bool
-	fetchAllArg    bool
-	fetchPruneArg  bool
+	fetchRecentArg       bool
+	fetchRecentRemoteArg string
+	fetchAllArg          bool
+	fetchPruneArg        bool
+	fetchMaxSizeArg      string
+	fetchDryRunArg       bool
+	fetchJSONArg         bool
+	fetchSparseArg       bool
+	fetchVerboseArg      bool
+
+	// fetchSizeLimit skips objects larger than --max-size, if given.
+	fetchSizeLimit = &sizeLimit{}
+
+	// fetchSparseCheckout skips objects for paths outside the sparse
+	// checkout given by its patterns, if set.
+	fetchSparseCheckout *git.SparseCheckout
+
+	// fetchDryRunObjects collects the objects which would be fetched
+	// with --dry-run, instead of downloading them.
+	fetchDryRunObjects = newFetchDryRun()
+
+	// fetchObjects records the objects found across every ref fetched,
+	// so that each is requested at most once.
+	fetchObjects = newFetchObjectSet()
 )
 
+// fetchPrint prints a message about what is being fetched, to standard error
+// with --json, so that it doesn't mix with the JSON output.
+func fetchPrint(format string, args ...interface{}) {
+	if fetchJSONArg {
+		Error(format, args...)
+		return
+	}
+	Print(format, args...)
+}
+
+// fetchDryRun keeps the unique objects found by a dry run, separating those
+// already present locally from those which would be downloaded.
+type fetchDryRun struct {
+	seen    map[string]bool
+	cached  []*lfs.WrappedPointer
+	missing []*lfs.WrappedPointer
+}
+
+func newFetchDryRun() *fetchDryRun {
+	return &fetchDryRun{seen: make(map[string]bool)}
+}
+
+// Add records the objects of "pointers" not seen before. Objects over
+// --max-size, or outside the sparse checkout, are skipped, as they wouldn't be
+// fetched.
+func (d *fetchDryRun) Add(pointers []*lfs.WrappedPointer) {
+	for _, p := range pointers {
+		if !inSparseCheckout(p) {
+			continue
+		}
+		if d.seen[p.Oid] {
+			continue
+		}
+		d.seen[p.Oid] = true
+
+		if cfg.LFSObjectExists(p.Oid, p.Size) {
+			d.cached = append(d.cached, p)
+		} else if fetchSizeLimit.Allows(p) {
+			d.missing = append(d.missing, p)
+		}
+	}
+}
+
+// fetchObjectSet keeps the unique objects of the pointers found while
+// fetching, across all of the refs and commits traversed, which are each
+// fetched in turn.
+type fetchObjectSet struct {
+	// found is the set of objects of the pointers found, and requested
+	// those added to a transfer queue.
+	found     map[string]bool
+	requested map[string]bool
+	// pointers is the number of pointers found, including those to the
+	// same objects.
+	pointers int
+}
+
+func newFetchObjectSet() *fetchObjectSet {
+	return &fetchObjectSet{
+		found:     make(map[string]bool),
+		requested: make(map[string]bool),
+	}
+}
+
+// Found records that the pointer "p" was found.
+func (s *fetchObjectSet) Found(p *lfs.WrappedPointer) {
+	s.pointers++
+	s.found[p.Oid] = true
+}
+
+// Request returns whether the object "oid" should be added to a transfer
+// queue, which it should be unless it already has been while fetching another
+// ref, even if that transfer failed.
+func (s *fetchObjectSet) Request(oid string) bool {
+	if s.requested[oid] {
+		return false
+	}
+	s.requested[oid] = true
+	return true
+}
+
+// Report prints how many unique objects the pointers found referred to, with
+// --verbose.
+func (s *fetchObjectSet) Report() {
+	if !fetchVerboseArg || s.pointers == 0 {
+		return
+	}
+
+	fetchPrint("fetch: %d pointer(s) referred to %d unique object(s) (%.2f:1), %d of which were requested",
+		s.pointers, len(s.found), float64(s.pointers)/float64(len(s.found)), len(s.requested))
+}
+
+// fetchTimings collects the objects downloaded by a transfer queue, with the
+// time each took, to report with --verbose once the queue has finished.
+type fetchTimings struct {
+	transfers []*tq.Transfer
+	wg        sync.WaitGroup
+}
+
+// watchFetchTimings starts collecting the objects downloaded by "q", or
+// returns nil without --verbose.
+func watchFetchTimings(q *tq.TransferQueue) *fetchTimings {
+	if !fetchVerboseArg {
+		return nil
+	}
+
+	t := &fetchTimings{}
+	watch := q.Watch()
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		for transfer := range watch {
+			t.transfers = append(t.transfers, transfer)
+		}
+	}()
+	return t
+}
+
+// fetchTiming is a downloaded object, as printed by --verbose --json.
+type fetchTiming struct {
+	Name           string `json:"name"`
+	Oid            string `json:"oid"`
+	Size           int64  `json:"size"`
+	Elapsed        int64  `json:"elapsed_ms"`
+	BytesPerSecond int64  `json:"bytes_per_second"`
+}
+
+// Report prints a line for each object downloaded, in the order in which they
+// finished, with its size, how long it took and the rate at which it was
+// transferred, or a JSON object with --json. It must be called after the
+// queue has finished.
+func (t *fetchTimings) Report() {
+	if t == nil {
+		return
+	}
+
+	t.wg.Wait()
+	for _, transfer := range t.transfers {
+		if fetchJSONArg {
+			encoded, err := json.Marshal(&fetchTiming{
+				Name:           transfer.Name,
+				Oid:            transfer.Oid,
+				Size:           transfer.Size,
+				Elapsed:        transfer.Elapsed.Nanoseconds() / int64(time.Millisecond),
+				BytesPerSecond: transfer.BytesPerSecond(),
+			})
+			if err != nil {
+				ExitWithError(err)
+			}
+			Print(string(encoded))
+			continue
+		}
+
+		Print("fetch: downloaded %s %s (%s) in %s at %s", transfer.Oid, transfer.Name,
+			humanize.FormatBytes(uint64(transfer.Size)),
+			transfer.Elapsed.Round(time.Millisecond),
+			humanize.FormatByteRate(uint64(transfer.Size), transfer.Elapsed))
+	}
+}
+
+// fetchProgressWriter returns where the progress of downloads is written,
+// which is standard error with --json, so that it doesn't mix with the JSON
+// output.
+func fetchProgressWriter() io.Writer {
+	if fetchJSONArg {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// fetchDryRunObject is an object listed in the output of --dry-run --json.
+type fetchDryRunObject struct {
+	Name  string `json:"name"`
+	Oid   string `json:"oid"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report asks "remote" which of the objects to be downloaded it has, with a
+// "download" batch request, and prints those which would be downloaded, those
+// which are already present locally, and those the remote doesn't have.
+func (d *fetchDryRun) Report(remote string) {
+	unavailable := make(map[string]error)
+	manifest := getTransferManifestOperationRemote("download", remote)
+	if manifest.IsStandaloneTransfer() {
+		tracerx.Printf("fetch: not querying a standalone transfer agent for %d object(s)", len(d.missing))
+	} else if len(d.missing) > 0 {
+		transfers := make([]*tq.Transfer, 0, len(d.missing))
+		for _, p := range d.missing {
+			transfers = append(transfers, &tq.Transfer{Name: p.Name, Oid: p.Oid, Size: p.Size})
+		}
+
+		failed, err := tq.FindMissing(manifest, remote, nil, transfers)
+		if err != nil {
+			ExitWithError(errors.Wrap(err, "could not query the remote for the objects to fetch"))
+		}
+		for _, f := range failed {
+			unavailable[f.Oid] = f.Err
+		}
+	}
+
+	download := make([]*fetchDryRunObject, 0, len(d.missing))
+	cached := make([]*fetchDryRunObject, 0, len(d.cached))
+	missing := make([]*fetchDryRunObject, 0, len(unavailable))
+	var downloadSize, cachedSize int64
+	for _, p := range d.missing {
+		o := &fetchDryRunObject{Name: p.Name, Oid: p.Oid, Size: p.Size}
+		if err, ok := unavailable[p.Oid]; ok {
+			o.Error = err.Error()
+			missing = append(missing, o)
+			continue
+		}
+		download = append(download, o)
+		downloadSize += p.Size
+	}
+	for _, p := range d.cached {
+		cached = append(cached, &fetchDryRunObject{Name: p.Name, Oid: p.Oid, Size: p.Size})
+		cachedSize += p.Size
+	}
+
+	if fetchJSONArg {
+		encoded, err := json.Marshal(struct {
+			Download     []*fetchDryRunObject `json:"download"`
+			DownloadSize int64                `json:"download_size"`
+			Cached       []*fetchDryRunObject `json:"cached"`
+			CachedSize   int64                `json:"cached_size"`
+			Unavailable  []*fetchDryRunObject `json:"unavailable"`
+		}{download, downloadSize, cached, cachedSize, missing})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+		return
+	}
+
+	Print("Would download %d object(s) (%s in total)", len(download), humanize.FormatBytes(uint64(downloadSize)))
+	for _, o := range download {
+		Print("\t%s %s (%s)", o.Oid, o.Name, humanize.FormatBytes(uint64(o.Size)))
+	}
+	Print("Skipping %d object(s) already present locally (%s in total)", len(cached), humanize.FormatBytes(uint64(cachedSize)))
+	for _, o := range cached {
+		Print("\t%s %s (%s)", o.Oid, o.Name, humanize.FormatBytes(uint64(o.Size)))
+	}
+	if len(missing) > 0 {
+		Print("%d object(s) not available from the remote", len(missing))
+		for _, o := range missing {
+			Print("\t%s %s: %s", o.Oid, o.Name, o.Error)
+		}
+	}
+}
+
+// sizeLimit skips downloading objects larger than a maximum size, keeping
+// count of the unique objects skipped, and their total size.
+type sizeLimit struct {
+	// max is the largest size of object to download, or 0 for no limit.
+	max uint64
+
+	mu      sync.Mutex
+	skipped map[string]int64
+}
+
+// newSizeLimit returns a sizeLimit for the given value of --max-size, which is
+// either empty, for no limit, or a size such as "100MB".
+func newSizeLimit(arg string) *sizeLimit {
+	l := &sizeLimit{skipped: make(map[string]int64)}
+	if len(arg) == 0 {
+		return l
+	}
+
+	max, err := humanize.ParseBytes(arg)
+	if err != nil {
+		ExitWithError(errors.Wrap(err, "cannot parse --max-size=<size>"))
+	}
+	if max == 0 {
+		Exit("--max-size must be greater than zero")
+	}
+	l.max = max
+	return l
+}
+
+// Allows returns whether the object of "p" is small enough to download, and
+// records it as skipped otherwise.
+func (l *sizeLimit) Allows(p *lfs.WrappedPointer) bool {
+	if l.max == 0 || uint64(p.Size) <= l.max {
+		return true
+	}
+
+	tracerx.Printf("fetch: skipping %v [%v], %d bytes is over --max-size", p.Name, p.Oid, p.Size)
+
+	l.mu.Lock()
+	l.skipped[p.Oid] = p.Size
+	l.mu.Unlock()
+	return false
+}
+
+// sparseCheckout returns the sparse-checkout patterns of the repository, or
+// nil if it isn't a sparse checkout.
+func sparseCheckout() *git.SparseCheckout {
+	if !cfg.Git.Bool("core.sparsecheckout", false) {
+		return nil
+	}
+
+	s, err := git.ReadSparseCheckout(cfg.LocalGitDir())
+	if err != nil {
+		ExitWithError(errors.Wrap(err, "cannot read sparse-checkout patterns"))
+	}
+	return s
+}
+
+// smudgeSparseCheckout returns the sparse-checkout patterns outside of which
+// the smudge filter leaves pointers, or nil if lfs.fetchfromsparse isn't set.
+// While git-sparse-checkout(1) changes the patterns, it only checks out the
+// files newly included by those it hasn't written yet, so nil is returned and
+// their objects are downloaded.
+func smudgeSparseCheckout() *git.SparseCheckout {
+	if !cfg.FetchFromSparse() || git.SparseCheckoutChanging(cfg.LocalGitDir()) {
+		return nil
+	}
+	return sparseCheckout()
+}
+
+// inSparseCheckout returns whether the path of "p" is in the sparse checkout
+// given by fetchSparseCheckout, if any.
+func inSparseCheckout(p *lfs.WrappedPointer) bool {
+	if fetchSparseCheckout.Includes(p.Name) {
+		return true
+	}
+
+	tracerx.Printf("fetch: skipping %v [%v], outside of the sparse checkout", p.Name, p.Oid)
+	return false
+}
+
+// Report prints the number and total size of the objects skipped, if any.
+func (l *sizeLimit) Report() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.skipped) == 0 {
+		return
+	}
+
+	var total uint64
+	for _, size := range l.skipped {
+		total += uint64(size)
+	}
+	fetchPrint("Skipped %d object(s) larger than %s (%s in total)",
+		len(l.skipped), humanize.FormatBytes(l.max), humanize.FormatBytes(total))
+}
+
 func getIncludeExcludeArgs(cmd *cobra.Command) (include, exclude *string) {
 	includeFlag := cmd.Flag("include")
 	excludeFlag := cmd.Flag("exclude")
@@ -35,8 +409,10 @@ func getIncludeExcludeArgs(cmd *cobra.Command) (include, exclude *string) {
 
 func fetchCommand(cmd *cobra.Command, args []string) {
 	setupRepository()
+	setupJSONProgress()
 
 	var refs []*git.Ref
+	var ranges []string
 
 	if len(args) > 0 {
 		// Remote is first arg
@@ -46,9 +422,18 @@ func fetchCommand(cmd *cobra.Command, args []string) {
 	}
 
 	if len(args) > 1 {
-		resolvedrefs, err := git.ResolveRefs(args[1:])
+		var refnames []string
+		for _, arg := range args[1:] {
+			if git.IsRevisionRange(arg) {
+				ranges = append(ranges, arg)
+			} else {
+				refnames = append(refnames, arg)
+			}
+		}
+
+		resolvedrefs, err := git.ResolveRefs(refnames)
 		if err != nil {
-			Panic(err, "Invalid ref argument: %v", args[1:])
+			Panic(err, "Invalid ref argument: %v", refnames)
 		}
 		refs = resolvedrefs
 	} else if !fetchAllArg {
@@ -59,6 +444,27 @@ func fetchCommand(cmd *cobra.Command, args []string) {
 		refs = []*git.Ref{ref}
 	}
 
+	fetchSizeLimit = newSizeLimit(fetchMaxSizeArg)
+	if fetchSparseArg {
+		if fetchAllArg {
+			Exit("Cannot combine --all with --sparse")
+		}
+		fetchSparseCheckout = sparseCheckout()
+	} else if cfg.FetchFromSparse() {
+		if fetchAllArg {
+			fetchPrint("Ignoring lfs.fetchfromsparse to fulfil --all")
+		} else {
+			fetchSparseCheckout = sparseCheckout()
+		}
+	}
+
+	if fetchDryRunArg && fetchPruneArg {
+		Exit("Cannot combine --dry-run with --prune")
+	}
+	if fetchJSONArg && !fetchDryRunArg && !fetchVerboseArg {
+		Exit("--json requires --dry-run or --verbose")
+	}
+
 	success := true
 	gitscanner := lfs.NewGitScanner(cfg, nil)
 	defer gitscanner.Close()
@@ -66,6 +472,20 @@ func fetchCommand(cmd *cobra.Command, args []string) {
 	include, exclude := getIncludeExcludeArgs(cmd)
 	fetchPruneCfg := lfs.NewFetchPruneConfig(cfg.Git)
 
+	if len(fetchRecentRemoteArg) > 0 {
+		if !fetchRecentArg && !fetchPruneCfg.FetchRecentAlways {
+			Exit("Cannot use --recent-remote without --recent")
+		}
+		// A URL has no tracking refs, so only named remotes are valid.
+		var known bool
+		for _, remote := range cfg.Remotes() {
+			known = known || remote == fetchRecentRemoteArg
+		}
+		if !known {
+			Exit("Invalid remote name %q", fetchRecentRemoteArg)
+		}
+	}
+
 	if fetchAllArg {
 		if fetchRecentArg {
 			Exit("Cannot combine --all with --recent")
@@ -73,8 +493,11 @@ func fetchCommand(cmd *cobra.Command, args []string) {
 		if include != nil || exclude != nil {
 			Exit("Cannot combine --all with --include or --exclude")
 		}
+		if len(ranges) > 0 {
+			Exit("Cannot combine --all with a revision range")
+		}
 		if len(cfg.FetchIncludePaths()) > 0 || len(cfg.FetchExcludePaths()) > 0 {
-			Print("Ignoring global include / exclude paths to fulfil --all")
+			fetchPrint("Ignoring global include / exclude paths to fulfil --all")
 		}
 
 		if len(args) > 1 {
@@ -92,21 +515,35 @@ func fetchCommand(cmd *cobra.Command, args []string) {
 
 		// Fetch refs sequentially per arg order; duplicates in later refs will be ignored
 		for _, ref := range refs {
-			Print("fetch: Fetching reference %s", ref.Refspec())
+			fetchPrint("fetch: Fetching reference %s", ref.Refspec())
 			s := fetchRef(ref.Sha, filter)
 			success = success && s
 		}
 
+		for _, spec := range ranges {
+			fetchPrint("fetch: Fetching range %s", spec)
+			s := fetchRange(spec, filter)
+			success = success && s
+		}
+
 		if fetchRecentArg || fetchPruneCfg.FetchRecentAlways {
 			s := fetchRecent(fetchPruneCfg, refs, filter)
 			success = success && s
 		}
 	}
 
+	fetchSizeLimit.Report()
+	fetchObjects.Report()
+
+	if fetchDryRunArg {
+		fetchDryRunObjects.Report(cfg.Remote())
+	}
+
 	if fetchPruneArg {
 		verify := fetchPruneCfg.PruneVerifyRemoteAlways
-		// no dry-run or verbose options in fetch, assume false
-		prune(fetchPruneCfg, verify, false, false)
+		// --verbose only reports on the objects fetched, so prune
+		// quietly
+		prune(fetchPruneCfg, verify, false, false, pruneReportNone)
 	}
 
 	if !success {
@@ -151,6 +588,47 @@ func fetchRef(ref string, filter *filepathfilter.Filter) bool {
 	return fetchAndReportToChan(pointers, filter, nil)
 }
 
+func pointersToFetchForRange(include, exclude []string, filter *filepathfilter.Filter) ([]*lfs.WrappedPointer, error) {
+	var pointers []*lfs.WrappedPointer
+	var multiErr error
+	tempgitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
+		if err != nil {
+			if multiErr != nil {
+				multiErr = fmt.Errorf("%v\n%v", multiErr, err)
+			} else {
+				multiErr = err
+			}
+			return
+		}
+
+		pointers = append(pointers, p)
+	})
+
+	tempgitscanner.Filter = filter
+
+	if err := tempgitscanner.ScanRefs(include, exclude, nil); err != nil {
+		return nil, err
+	}
+
+	tempgitscanner.Close()
+	return pointers, multiErr
+}
+
+// Fetch all binaries introduced by the commits in a revision range, such as
+// "A..B" or "A...B" (that we don't have already)
+func fetchRange(spec string, filter *filepathfilter.Filter) bool {
+	include, exclude, err := git.ResolveRevisionRange(spec)
+	if err != nil {
+		Panic(err, "Invalid revision range: %s", spec)
+	}
+
+	pointers, err := pointersToFetchForRange(include, exclude, filter)
+	if err != nil {
+		Panic(err, "Could not scan for Git LFS files")
+	}
+	return fetchAndReportToChan(pointers, filter, nil)
+}
+
 func pointersToFetchForRefs(refs []string) ([]*lfs.WrappedPointer, error) {
 	// This could be a long process so use the chan version & report progress
 	task := tasklog.NewSimpleTask()
@@ -234,9 +712,16 @@ func fetchRecent(fetchconf lfs.FetchPruneConfig, alreadyFetchedRefs []*git.Ref,
 	}
 	// First find any other recent refs
 	if fetchconf.FetchRecentRefsDays > 0 {
-		Print("fetch: Fetching recent branches within %v days", fetchconf.FetchRecentRefsDays)
+		fetchPrint("fetch: Fetching recent branches within %v days", fetchconf.FetchRecentRefsDays)
 		refsSince := time.Now().AddDate(0, 0, -fetchconf.FetchRecentRefsDays)
-		refs, err := git.RecentBranches(refsSince, fetchconf.FetchRecentRefsIncludeRemotes, cfg.Remote())
+		// Recent remote branches are taken from the configured remote,
+		// unless another was given with --recent-remote, in which case
+		// they are included regardless of lfs.fetchrecentremoterefs.
+		includeRemotes, remote := fetchconf.FetchRecentRefsIncludeRemotes, cfg.Remote()
+		if len(fetchRecentRemoteArg) > 0 {
+			includeRemotes, remote = true, fetchRecentRemoteArg
+		}
+		refs, err := git.RecentBranches(refsSince, includeRemotes, remote)
 		if err != nil {
 			Panic(err, "Could not scan for recent refs")
 		}
@@ -248,7 +733,7 @@ func fetchRecent(fetchconf lfs.FetchPruneConfig, alreadyFetchedRefs []*git.Ref,
 				}
 			} else {
 				uniqueRefShas[ref.Sha] = ref.Name
-				Print("fetch: Fetching reference %s", ref.Name)
+				fetchPrint("fetch: Fetching reference %s", ref.Name)
 				k := fetchRef(ref.Sha, filter)
 				ok = ok && k
 			}
@@ -263,7 +748,7 @@ func fetchRecent(fetchconf lfs.FetchPruneConfig, alreadyFetchedRefs []*git.Ref,
 				Error("Couldn't scan commits at %v: %v", refName, err)
 				continue
 			}
-			Print("fetch: Fetching changes within %v days of %v", fetchconf.FetchRecentCommitsDays, refName)
+			fetchPrint("fetch: Fetching changes within %v days of %v", fetchconf.FetchRecentCommitsDays, refName)
 			commitsSince := summ.CommitDate.AddDate(0, 0, -fetchconf.FetchRecentCommitsDays)
 			k := fetchPreviousVersions(commit, commitsSince, filter)
 			ok = ok && k
@@ -275,7 +760,7 @@ func fetchRecent(fetchconf lfs.FetchPruneConfig, alreadyFetchedRefs []*git.Ref,
 
 func fetchAll() bool {
 	pointers := scanAll()
-	Print("fetch: Fetching all references...")
+	fetchPrint("fetch: Fetching all references...")
 	return fetchAndReportToChan(pointers, nil, nil)
 }
 
@@ -324,12 +809,22 @@ func scanAll() []*lfs.WrappedPointer {
 // Fetch and report completion of each OID to a channel (optional, pass nil to skip)
 // Returns true if all completed with no errors, false if errors were written to stderr/log
 func fetchAndReportToChan(allpointers []*lfs.WrappedPointer, filter *filepathfilter.Filter, out chan<- *lfs.WrappedPointer) bool {
+	if fetchDryRunArg {
+		fetchDryRunObjects.Add(allpointers)
+		if out != nil {
+			close(out)
+		}
+		return true
+	}
+
 	ready, pointers, meter := readyAndMissingPointers(allpointers, filter)
 	q := newDownloadQueue(
 		getTransferManifestOperationRemote("download", cfg.Remote()),
 		cfg.Remote(), tq.WithProgress(meter),
 	)
 
+	timings := watchFetchTimings(q)
+
 	if out != nil {
 		// If we already have it, or it won't be fetched
 		// report it to chan immediately to support pull/checkout
@@ -370,6 +865,7 @@ func fetchAndReportToChan(allpointers []*lfs.WrappedPointer, filter *filepathfil
 	processQueue := time.Now()
 	q.Wait()
 	tracerx.PerformanceSince("process queue", processQueue)
+	timings.Report()
 
 	ok := true
 	for _, err := range q.Errors() {
@@ -380,7 +876,7 @@ func fetchAndReportToChan(allpointers []*lfs.WrappedPointer, filter *filepathfil
 }
 
 func readyAndMissingPointers(allpointers []*lfs.WrappedPointer, filter *filepathfilter.Filter) ([]*lfs.WrappedPointer, []*lfs.WrappedPointer, *tq.Meter) {
-	logger := tasklog.NewLogger(os.Stdout,
+	logger := tasklog.NewLogger(fetchProgressWriter(),
 		tasklog.ForceProgress(cfg.ForceProgress()),
 	)
 	meter := buildProgressMeter(false, tq.Download)
@@ -391,6 +887,12 @@ func readyAndMissingPointers(allpointers []*lfs.WrappedPointer, filter *filepath
 	ready := make([]*lfs.WrappedPointer, 0, len(allpointers))
 
 	for _, p := range allpointers {
+		if !inSparseCheckout(p) {
+			continue
+		}
+
+		fetchObjects.Found(p)
+
 		// no need to download the same object multiple times
 		if seen[p.Oid] {
 			continue
@@ -405,6 +907,16 @@ func readyAndMissingPointers(allpointers []*lfs.WrappedPointer, filter *filepath
 			continue
 		}
 
+		if !fetchSizeLimit.Allows(p) {
+			continue
+		}
+
+		// nor to request an object again which was requested for
+		// another ref
+		if !fetchObjects.Request(p.Oid) {
+			continue
+		}
+
 		missing = append(missing, p)
 		meter.Add(p.Size)
 	}
@@ -417,7 +929,14 @@ func init() {
 		cmd.Flags().StringVarP(&includeArg, "include", "I", "", "Include a list of paths")
 		cmd.Flags().StringVarP(&excludeArg, "exclude", "X", "", "Exclude a list of paths")
 		cmd.Flags().BoolVarP(&fetchRecentArg, "recent", "r", false, "Fetch recent refs & commits")
+		cmd.Flags().StringVarP(&fetchRecentRemoteArg, "recent-remote", "", "", "Find recent remote refs on the given remote")
 		cmd.Flags().BoolVarP(&fetchAllArg, "all", "a", false, "Fetch all LFS files ever referenced")
 		cmd.Flags().BoolVarP(&fetchPruneArg, "prune", "p", false, "After fetching, prune old data")
+		cmd.Flags().StringVarP(&fetchMaxSizeArg, "max-size", "", "", "Skip objects larger than the given size")
+		cmd.Flags().BoolVarP(&fetchDryRunArg, "dry-run", "d", false, "List the objects which would be fetched without fetching them")
+		cmd.Flags().BoolVarP(&fetchJSONArg, "json", "", false, "Give the output of --dry-run or --verbose in JSON")
+		cmd.Flags().BoolVarP(&fetchVerboseArg, "verbose", "v", false, "Print the time taken to download each object")
+		cmd.Flags().BoolVarP(&fetchSparseArg, "sparse", "", false, "Only fetch objects for paths in the sparse checkout")
+		cmd.Flags().BoolVarP(&jsonProgressArg, "json-progress", "", false, "Print progress as a stream of JSON events to standard error")
 	})
 }
@@ -57,8 +57,9 @@ func filterCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	skip := filterSmudgeSkip || cfg.Os.Bool("GIT_LFS_SKIP_SMUDGE", false)
+	skip := filterSmudgeSkip || cfg.SkipSmudge()
 	filter := filepathfilter.New(cfg.FetchIncludePaths(), cfg.FetchExcludePaths())
+	fetchSparseCheckout = smudgeSparseCheckout()
 
 	ptrs := make(map[string]*lfs.Pointer)
 
@@ -82,7 +83,7 @@ func filterCommand(cmd *cobra.Command, args []string) {
 			w = git.NewPktlineWriter(os.Stdout, cleanFilterBufferCapacity)
 
 			var ptr *lfs.Pointer
-			ptr, err = clean(gitfilter, w, req.Payload, req.Header["pathname"], -1)
+			ptr, err = cleanByContents(gitfilter, w, req.Payload, req.Header["pathname"])
 
 			if ptr != nil {
 				n = ptr.Size
@@ -6,18 +6,42 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/git-lfs/git-lfs/filepathfilter"
+	"github.com/git-lfs/git-lfs/fs"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tools"
+	"github.com/rubyist/tracerx"
 	"github.com/spf13/cobra"
 )
 
 var (
-	fsckDryRun bool
+	fsckDryRun     bool
+	fsckPointers   bool
+	fsckRecent     bool
+	fsckIncludeArg string
+	fsckExcludeArg string
 )
 
+const (
+	// pointerNested describes a Git LFS object whose contents are
+	// themselves a pointer, as when a file is cleaned twice.
+	pointerNested = "object is itself a pointer"
+	// pointerUnsmudged describes a working tree file which is still
+	// pointer text, although its object is present locally.
+	pointerUnsmudged = "working tree file is a pointer"
+)
+
+// pointerProblem is a Git LFS file whose contents are pointer text where they
+// should not be.
+type pointerProblem struct {
+	Name   string
+	Oid    string
+	Nature string
+}
+
 // TODO(zeroshirts): 'git fsck' reports status (percentage, current#/total) as
 // it checks... we should do the same, as we are rehashing potentially gigs and
 // gigs of content.
@@ -33,13 +57,48 @@ func fsckCommand(cmd *cobra.Command, args []string) {
 		ExitWithError(err)
 	}
 
+	// If 'lfs.fetchexclude' is set and 'git lfs fsck' is run after the
+	// initial fetch (i.e., has elected to fetch a subset of Git LFS
+	// objects), the "missing" ones will fail the fsck.
+	//
+	// Attach a filepathfilter to avoid _only_ the excluded paths, unless
+	// other paths were given.
+	var include, exclude []string
+	if cmd.Flags().Changed("include") {
+		include = tools.CleanPaths(fsckIncludeArg, ",")
+	}
+	if cmd.Flags().Changed("exclude") {
+		exclude = tools.CleanPaths(fsckExcludeArg, ",")
+	} else {
+		exclude = cfg.FetchExcludePaths()
+	}
+	filter := filepathfilter.New(include, exclude)
+
 	var corruptOids []string
+	var problems []*pointerProblem
+	seen := make(map[string]struct{})
+	checked := make(map[string]bool)
 	gitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
-		if err == nil {
-			var pointerOk bool
-			pointerOk, err = fsckPointer(p.Name, p.Oid)
-			if !pointerOk {
-				corruptOids = append(corruptOids, p.Oid)
+		if err == nil && !filter.Allows(p.Name) {
+			return
+		}
+
+		if _, ok := checked[p.Oid]; err == nil && !ok {
+			checked[p.Oid] = true
+			if !fsckPointers {
+				var pointerOk bool
+				pointerOk, err = fsckPointer(p.Name, p.Oid)
+				if !pointerOk {
+					corruptOids = append(corruptOids, p.Oid)
+				}
+			}
+		}
+
+		if err == nil && fsckPointers {
+			key := p.Name + ":" + p.Oid
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				problems = append(problems, checkPointerContents(p)...)
 			}
 		}
 
@@ -47,33 +106,58 @@ func fsckCommand(cmd *cobra.Command, args []string) {
 			Panic(err, "Error checking Git LFS files")
 		}
 	})
+	gitscanner.Filter = filter
 
-	// If 'lfs.fetchexclude' is set and 'git lfs fsck' is run after the
-	// initial fetch (i.e., has elected to fetch a subset of Git LFS
-	// objects), the "missing" ones will fail the fsck.
-	//
-	// Attach a filepathfilter to avoid _only_ the excluded paths.
-	gitscanner.Filter = filepathfilter.New(nil, cfg.FetchExcludePaths())
+	if fsckRecent {
+		if err := fsckScanRecent(gitscanner, lfs.NewFetchPruneConfig(cfg.Git), ref); err != nil {
+			ExitWithError(err)
+		}
+	} else {
+		if err := gitscanner.ScanRef(ref.Sha, nil); err != nil {
+			ExitWithError(err)
+		}
 
-	if err := gitscanner.ScanRef(ref.Sha, nil); err != nil {
-		ExitWithError(err)
+		if err := gitscanner.ScanIndex("HEAD", nil); err != nil {
+			ExitWithError(err)
+		}
 	}
 
-	if err := gitscanner.ScanIndex("HEAD", nil); err != nil {
-		ExitWithError(err)
+	gitscanner.Close()
+
+	if fsckRecent || len(include) > 0 || cmd.Flags().Changed("exclude") {
+		var skipped int
+		cfg.EachLFSObject(func(obj fs.Object) error {
+			if !checked[obj.Oid] {
+				skipped++
+			}
+			return nil
+		})
+		Print("fsck: %d object(s) checked, %d object(s) in the local store skipped", len(checked), skipped)
 	}
 
-	gitscanner.Close()
+	for _, problem := range problems {
+		Print("Pointer %s (%s): %s", problem.Name, problem.Oid, problem.Nature)
+	}
 
-	if len(corruptOids) == 0 {
+	if len(corruptOids) == 0 && len(problems) == 0 {
 		Print("Git LFS fsck OK")
 		return
 	}
 
-	if fsckDryRun {
-		return
+	if len(corruptOids) > 0 && !fsckDryRun {
+		fsckMoveCorruptObjects(corruptOids)
+	}
+
+	// Pointer text in place of contents isn't fixed by moving anything
+	// aside, so it always fails the check.
+	if len(problems) > 0 {
+		os.Exit(1)
 	}
+}
 
+// fsckMoveCorruptObjects moves the corrupt objects "oids" out of the local
+// object store, into "bad" in the storage directory.
+func fsckMoveCorruptObjects(oids []string) {
 	badDir := filepath.Join(cfg.LFSStorageDir(), "bad")
 	Print("Moving corrupt objects to %s", badDir)
 
@@ -81,16 +165,72 @@ func fsckCommand(cmd *cobra.Command, args []string) {
 		ExitWithError(err)
 	}
 
-	for _, oid := range corruptOids {
+	for _, oid := range oids {
+		path := cfg.Filesystem().ObjectPathname(oid)
+		if !tools.FileExists(path) {
+			// Alternates are read-only, so are left as they are.
+			Print("Not moving %s, which is in an alternate object directory", oid)
+			continue
+		}
+
 		badFile := filepath.Join(badDir, oid)
-		if err := os.Rename(cfg.Filesystem().ObjectPathname(oid), badFile); err != nil {
+		if err := lfs.MoveFile(cfg, path, badFile); err != nil {
 			ExitWithError(err)
 		}
 	}
 }
 
+// fsckScanRecent scans only the trees of the recent refs and commits given by
+// the lfs.fetchrecent* settings in "fetchconf", in the same way as "git lfs
+// fetch --recent" and "git lfs prune" find them, with "gitscanner". The current
+// ref "ref" is scanned only if it is recent itself.
+func fsckScanRecent(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, ref *git.Ref) error {
+	commits := tools.NewStringSet()
+
+	if fetchconf.FetchRecentRefsDays > 0 {
+		refsSince := time.Now().AddDate(0, 0, -fetchconf.FetchRecentRefsDays)
+		refs, err := git.RecentBranches(refsSince, fetchconf.FetchRecentRefsIncludeRemotes, cfg.Remote())
+		if err != nil {
+			return err
+		}
+
+		// HEAD may be detached, or not at a branch tip.
+		summ, err := git.GetCommitSummary(ref.Sha)
+		if err != nil {
+			return err
+		}
+		if summ.CommitDate.After(refsSince) {
+			refs = append([]*git.Ref{ref}, refs...)
+		}
+
+		for _, r := range refs {
+			if commits.Add(r.Sha) {
+				tracerx.Printf("fsck: checking recent ref %s", r.Name)
+				if err := gitscanner.ScanTree(r.Sha); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if fetchconf.FetchRecentCommitsDays > 0 {
+		for commit := range commits.Iter() {
+			// We measure from the last commit at the ref
+			summ, err := git.GetCommitSummary(commit)
+			if err != nil {
+				return err
+			}
+			commitsSince := summ.CommitDate.AddDate(0, 0, -fetchconf.FetchRecentCommitsDays)
+			if err := gitscanner.ScanPreviousVersions(commit, commitsSince, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func fsckPointer(name, oid string) (bool, error) {
-	path := cfg.Filesystem().ObjectPathname(oid)
+	path := cfg.Filesystem().ObjectReadPathname(oid, -1)
 
 	Debug("Examining %v (%v)", name, path)
 
@@ -120,8 +260,38 @@ func fsckPointer(name, oid string) (bool, error) {
 	return false, nil
 }
 
+// checkPointerContents returns any problems with the contents of the Git LFS
+// object for the pointer "p", or of the working tree file at its path. Only
+// objects present locally are checked.
+func checkPointerContents(p *lfs.WrappedPointer) []*pointerProblem {
+	if !cfg.LFSObjectExists(p.Oid, p.Size) {
+		return nil
+	}
+
+	var problems []*pointerProblem
+	if _, err := lfs.DecodePointerFromFile(cfg.Filesystem().ObjectReadPathname(p.Oid, p.Size)); err == nil {
+		problems = append(problems, &pointerProblem{
+			Name: p.Name, Oid: p.Oid, Nature: pointerNested,
+		})
+	}
+
+	if wd := cfg.LocalWorkingDir(); len(wd) > 0 {
+		wp, err := lfs.DecodePointerFromFile(filepath.Join(wd, p.Name))
+		if err == nil && wp.Oid == p.Oid {
+			problems = append(problems, &pointerProblem{
+				Name: p.Name, Oid: p.Oid, Nature: pointerUnsmudged,
+			})
+		}
+	}
+	return problems
+}
+
 func init() {
 	RegisterCommand("fsck", fsckCommand, func(cmd *cobra.Command) {
 		cmd.Flags().BoolVarP(&fsckDryRun, "dry-run", "d", false, "List corrupt objects without deleting them.")
+		cmd.Flags().BoolVarP(&fsckPointers, "pointers", "", false, "Only check for pointer text in place of file contents.")
+		cmd.Flags().BoolVarP(&fsckRecent, "recent", "r", false, "Check only objects referenced by recent refs and commits.")
+		cmd.Flags().StringVarP(&fsckIncludeArg, "include", "I", "", "Only check objects at these paths.")
+		cmd.Flags().StringVarP(&fsckExcludeArg, "exclude", "X", "", "Do not check objects at these paths.")
 	})
 }
@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"encoding/json"
 	"os"
+	"strings"
 
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
+	"github.com/git-lfs/git-lfs/tools"
 	"github.com/spf13/cobra"
 )
 
@@ -16,9 +19,19 @@ var (
 	systemInstall     = false
 	skipSmudgeInstall = false
 	skipRepoInstall   = false
+	dryRunInstall     = false
+	jsonInstall       = false
 )
 
 func installCommand(cmd *cobra.Command, args []string) {
+	if jsonInstall && !dryRunInstall {
+		Exit("--json requires --dry-run")
+	}
+	if dryRunInstall {
+		installDryRun()
+		return
+	}
+
 	if err := cmdInstallOptions().Install(); err != nil {
 		Print("WARNING: %s", err.Error())
 		Print("Run `git lfs install --force` to reset git config.")
@@ -65,6 +78,91 @@ func cmdInstallOptions() *lfs.FilterOptions {
 	}
 }
 
+// installDryRun reports what "git lfs install" would do with the options given,
+// to the Git LFS configuration and to the hooks of the current repository,
+// without changing either.
+func installDryRun() {
+	if manualInstall {
+		Exit("--dry-run option can't be combined with --manual")
+	}
+
+	opt := cmdInstallOptions()
+	config := opt.DryRun()
+
+	var hookDir string
+	hooks := []*lfs.HookStatus{}
+	if !skipRepoInstall && (localInstall || worktreeInstall || cfg.InRepo()) {
+		var err error
+		hookDir, err = cfg.HookDir()
+		if err != nil {
+			ExitWithError(err)
+		}
+		for _, h := range lfs.LoadHooks(hookDir, cfg) {
+			status, err := h.DryRun(forceInstall)
+			if err != nil {
+				ExitWithError(err)
+			}
+			hooks = append(hooks, status)
+		}
+	}
+
+	if jsonInstall {
+		encoded, err := json.Marshal(struct {
+			Scope  string              `json:"scope"`
+			Config []*lfs.ConfigChange `json:"config"`
+			Hooks  []*lfs.HookStatus   `json:"hooks"`
+		}{opt.Scope(), config, hooks})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+		return
+	}
+
+	var conflicts int
+	Print("Git LFS config (%s):", opt.Scope())
+	for _, c := range config {
+		switch c.Action {
+		case "none":
+			Print("\t%s: already %q", c.Key, c.Value)
+		case "set":
+			Print("\t%s: would set to %q", c.Key, c.Value)
+		default:
+			Print("\t%s: is %q, which would not be changed to %q without --force", c.Key, c.Current, c.Value)
+			conflicts++
+		}
+	}
+
+	if len(hooks) > 0 {
+		Print("\nGit hooks (%s):", hookDir)
+	}
+	for _, h := range hooks {
+		switch h.Action {
+		case "create":
+			Print("\t%s: would create", h.Type)
+		case "none":
+			Print("\t%s: already installed", h.Type)
+		case "upgrade":
+			Print("\t%s: installed by Git LFS, would upgrade", h.Type)
+		case "overwrite":
+			Print("\t%s: customized, would overwrite", h.Type)
+		default:
+			Print("\t%s: customized, would not be overwritten without --force", h.Type)
+			conflicts++
+		}
+	}
+
+	for _, h := range hooks {
+		if len(h.Contents) > 0 {
+			Print("\nWould write %s:\n\n%s", h.Path, tools.Indent(strings.TrimSuffix(h.Contents, "\n")))
+		}
+	}
+
+	if conflicts > 0 {
+		Print("\n`git lfs install` would fail. Run `git lfs install --force` to overwrite, or `git lfs install --manual` for instructions on merging hooks.")
+	}
+}
+
 func installHooksCommand(cmd *cobra.Command, args []string) {
 	updateForce = forceInstall
 
@@ -91,6 +189,8 @@ func init() {
 		cmd.Flags().BoolVarP(&skipSmudgeInstall, "skip-smudge", "s", false, "Skip automatic downloading of objects on clone or pull.")
 		cmd.Flags().BoolVarP(&skipRepoInstall, "skip-repo", "", false, "Skip repo setup, just install global filters.")
 		cmd.Flags().BoolVarP(&manualInstall, "manual", "m", false, "Print instructions for manual install.")
+		cmd.Flags().BoolVarP(&dryRunInstall, "dry-run", "d", false, "Report what would be configured and which hooks would be written, without changing anything.")
+		cmd.Flags().BoolVarP(&jsonInstall, "json", "", false, "Give the output of --dry-run in JSON")
 		cmd.AddCommand(NewCommand("hooks", installHooksCommand))
 	})
 }
@@ -1,36 +1,101 @@
 package commands
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
+	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/locking"
+	"github.com/git-lfs/git-lfs/tasklog"
 	"github.com/git-lfs/git-lfs/tools"
+	isatty "github.com/mattn/go-isatty"
+	"github.com/rubyist/tracerx"
 	"github.com/spf13/cobra"
 )
 
 var (
 	lockRemote     string
 	lockRemoteHelp = "specify which remote to use when interacting with locks"
+	lockForce      bool
+	lockMessage    string
+
+	// lockRootRelative is whether the paths given to "git lfs lock" and
+	// "git lfs unlock" are relative to the root of the repository, rather
+	// than to the working directory.
+	lockRootRelative bool
+
+	// lockRecursive is whether directories given to "git lfs lock" and
+	// "git lfs unlock" stand for the files beneath them.
+	lockRecursive bool
+
+	// lockTransferTo is the user to whom "git lfs lock --transfer-to"
+	// hands locks over, and lockOwner the user whose locks are handed
+	// over, or the current user if it is empty.
+	lockTransferTo string
+	lockOwner      string
+
+	// lockYes is whether "git lfs lock --transfer-to" and "git lfs unlock
+	// --owner" go ahead without asking.
+	lockYes bool
 )
 
+// lockBatchSize is the number of paths locked or unlocked at a time by "git
+// lfs lock --recursive" and "git lfs unlock --recursive", between which the
+// progress meter is updated.
+const lockBatchSize = 100
+
+// lockDirectoryError is returned by lockPath and rootRelativeLockPath when the
+// path given is that of a directory, which can only be locked with
+// --recursive.
+type lockDirectoryError struct {
+	file string
+}
+
+func (e *lockDirectoryError) Error() string {
+	return fmt.Sprintf("lfs: cannot lock directory: %s", e.file)
+}
+
 func lockCommand(cmd *cobra.Command, args []string) {
+	if len(lockTransferTo) > 0 {
+		lockTransferCommand(args)
+		return
+	}
+	if len(lockOwner) > 0 {
+		Exit("lfs: --owner can only be given with --transfer-to")
+	}
+
 	if len(args) == 0 {
-		Print("Usage: git lfs lock <path>...")
+		Print("Usage: git lfs lock [--] <path>...")
 		return
 	}
 
-	paths := make([]string, len(args))
-	var err error
-	for i, path := range args {
-		paths[i], err = lockPath(path)
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		path, err := lockArgPath(arg)
+		if _, ok := err.(*lockDirectoryError); ok && lockRecursive {
+			files, err := lockDirectoryFiles(path)
+			if err != nil {
+				Exit(err.Error())
+			}
+			if len(files) == 0 {
+				Exit("lfs: no Git LFS files in directory: %s", arg)
+			}
+			paths = append(paths, files...)
+			continue
+		}
 		if err != nil {
 			Exit(err.Error())
 		}
+		paths = append(paths, path)
 	}
 
 	if len(lockRemote) > 0 {
@@ -42,10 +107,33 @@ func lockCommand(cmd *cobra.Command, args []string) {
 	lockClient.RemoteRef = refUpdate.Right()
 	defer lockClient.Close()
 
-	locks, err := lockClient.LockMultipleFiles(paths)
+	if !lockForce && cfg.Git.Bool("lfs.lock.warnnonlockable", false) {
+		for _, path := range paths {
+			if !lockClient.IsFileLockable(path) {
+				Error("Warning: %s is not marked as lockable, so it will not be made read-only while unlocked", path)
+				Error("Run `git lfs track --lockable %q` to make it lockable, or pass --force to silence this warning", path)
+			}
+		}
+	}
+
+	var locks []locking.Lock
+	var err error
+	if lockRecursive {
+		locks, err = lockInBatches("lock: Locking files", len(paths), func(i, j int) ([]locking.Lock, error) {
+			return lockClient.LockMultipleFiles(paths[i:j], lockMessage)
+		})
+	} else {
+		locks, err = lockClient.LockMultipleFiles(paths, lockMessage)
+	}
 	if err != nil {
 		Error("Lock failed: %v", errors.Cause(err))
 	}
+	for _, lock := range locks {
+		if len(lockMessage) > 0 && len(lock.Message) == 0 {
+			Error("Warning: the server did not keep the message of the lock on %s, as it may not support lock messages", lock.Path)
+		}
+		emitEvent(&events.Event{Type: events.LockAcquired, Path: lock.Path, LockID: lock.Id})
+	}
 	if locksCmdFlags.JSON {
 		encoder := json.NewEncoder(os.Stdout)
 		for _, lock := range locks {
@@ -57,6 +145,9 @@ func lockCommand(cmd *cobra.Command, args []string) {
 		for _, lock := range locks {
 			Print("Locked %s", lock.Path)
 		}
+		if lockRecursive {
+			Print("%d file(s) locked, %d failed", len(locks), len(paths)-len(locks))
+		}
 	}
 
 	if err != nil {
@@ -64,6 +155,290 @@ func lockCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// lockTransferCommand hands the locks at the paths matching "patterns", or at
+// any path if none are given, over to the user given by --transfer-to. Those
+// held by the user given by --owner are handed over, or if it isn't given,
+// those of the current user.
+func lockTransferCommand(patterns []string) {
+	if len(lockRemote) > 0 {
+		cfg.SetRemote(lockRemote)
+	}
+
+	refUpdate := git.NewRefUpdate(cfg.Git, cfg.PushRemote(), cfg.CurrentRef(), nil)
+	lockClient := newLockClient()
+	lockClient.RemoteRef = refUpdate.Right()
+	defer lockClient.Close()
+
+	locks, err := ownedLocks(lockClient, lockOwner, patterns)
+	if err != nil {
+		Exit("Unable to list locks: %v", errors.Cause(err))
+	}
+	if len(locks) == 0 {
+		Exit("lfs: no matching locks %s", lockHolder(lockOwner))
+	}
+
+	question := fmt.Sprintf("transfer these %d lock(s) to %s?", len(locks), lockTransferTo)
+	if !lockYes && isatty.IsTerminal(os.Stdin.Fd()) &&
+		!confirmLocks(os.Stdin, os.Stderr, "lock", lockHolder(lockOwner), question, locks) {
+		Exit("lock: no locks were transferred; pass --yes to skip this prompt")
+	}
+
+	var unsupported error
+	transferred, err := lockInBatches("lock: Transferring locks", len(locks), func(i, j int) ([]locking.Lock, error) {
+		if unsupported != nil {
+			return nil, nil
+		}
+		batch, err := lockClient.TransferMultipleLocks(locks[i:j], lockTransferTo)
+		if errors.IsNotImplementedError(err) {
+			unsupported = err
+			return nil, nil
+		}
+		return batch, err
+	})
+	if unsupported != nil {
+		tracerx.Printf("lock: transfer failed: %v", unsupported)
+		Exit("lfs: the server does not support transferring locks")
+	}
+	if err != nil {
+		Error("Transfer failed: %v", errors.Cause(err))
+	}
+	for _, lock := range transferred {
+		if len(lockOwner) == 0 {
+			emitEvent(&events.Event{Type: events.LockReleased, Path: lock.Path, LockID: lock.Id})
+		}
+	}
+
+	if locksCmdFlags.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, lock := range transferred {
+			if err := encoder.Encode(lock); err != nil {
+				Error(err.Error())
+			}
+		}
+	} else {
+		for _, lock := range transferred {
+			Print("Transferred %s to %s", lock.Path, lockTransferTo)
+		}
+		Print("%d lock(s) transferred to %s, %d failed", len(transferred), lockTransferTo, len(locks)-len(transferred))
+	}
+
+	if err != nil {
+		lockClient.Close()
+		os.Exit(2)
+	}
+}
+
+// ownedLocks returns the locks on the server held by the user "owner", or by
+// the current user if it is empty, at the paths matching "patterns", or at any
+// path if there are none, sorted by path. The patterns are relative to the
+// current directory, unless --root-relative was given.
+func ownedLocks(lockClient *locking.Client, owner string, patterns []string) ([]locking.Lock, error) {
+	var locks []locking.Lock
+	var err error
+	if len(owner) == 0 {
+		locks, _, err = lockClient.SearchLocksVerifiable(0, false)
+	} else {
+		locks, err = lockClient.SearchLocks(nil, 0, false, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var filter *filepathfilter.Filter
+	if len(patterns) > 0 {
+		if !lockRootRelative {
+			patterns = rootedPaths(patterns)
+		}
+		filter = filepathfilter.New(patterns, nil)
+	}
+
+	owned := locks[:0]
+	for _, lock := range locks {
+		if len(owner) > 0 && (lock.Owner == nil || lock.Owner.Name != owner) {
+			continue
+		}
+		if filter.Allows(lock.Path) {
+			owned = append(owned, lock)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return owned[i].Path < owned[j].Path })
+	return owned, nil
+}
+
+// lockHolder describes the user "owner" as the holder of locks, or the current
+// user if it is empty.
+func lockHolder(owner string) string {
+	if len(owner) == 0 {
+		return "held by you"
+	}
+	return "held by " + owner
+}
+
+// confirmLocks lists the locks "locks", which are "held", and then asks the
+// given yes or no question about them, on behalf of the command "cmd",
+// returning true only if the answer is yes.
+func confirmLocks(in io.Reader, out io.Writer, cmd, held, question string, locks []locking.Lock) bool {
+	fmt.Fprintf(out, "%s: %d lock(s) %s:\n", cmd, len(locks), held)
+	for _, lock := range locks {
+		fmt.Fprintf(out, "  %s\tID:%s\n", lock.Path, lock.Id)
+	}
+
+	answer := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "%s: %s [y/N] ", cmd, question)
+		s, err := answer.ReadString('\n')
+
+		switch strings.TrimSpace(s) {
+		case "y", "Y":
+			return true
+		case "n", "N", "":
+			return false
+		}
+
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// lockDirectoryFiles returns the files beneath the directory "dir", relative to
+// the root of the repository, which are in the index and stored with Git LFS,
+// as "git lfs lock --recursive" locks.
+func lockDirectoryFiles(dir string) ([]string, error) {
+	repo, err := git.RootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := git.IndexEntries(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if lockPathBeneath(entry.Path, dir) {
+			paths = append(paths, entry.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	filters, err := git.AttributeValues(repo, "filter", paths)
+	if err != nil {
+		return nil, err
+	}
+
+	files := paths[:0]
+	for _, path := range paths {
+		if filters[path] == "lfs" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// lockPathBeneath returns whether "path" is beneath the directory "dir", both
+// relative to the root of the repository, which is given as ".".
+func lockPathBeneath(path, dir string) bool {
+	return dir == "." || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// lockInBatches calls "fn" with the bounds of successive batches of "n" paths
+// or locks, counting them off a progress meter titled "msg" as it goes, and
+// returns the locks all of the calls returned, sorted by path, with their
+// errors combined.
+func lockInBatches(msg string, n int, fn func(i, j int) ([]locking.Lock, error)) ([]locking.Lock, error) {
+	logger := tasklog.NewLogger(os.Stderr,
+		tasklog.ForceProgress(cfg.ForceProgress()),
+	)
+	defer logger.Close()
+	task := logger.Percentage(msg, uint64(n))
+
+	var locks []locking.Lock
+	var errs []error
+	for i := 0; i < n; i += lockBatchSize {
+		j := i + lockBatchSize
+		if j > n {
+			j = n
+		}
+
+		batch, err := fn(i, j)
+		locks = append(locks, batch...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		task.Count(uint64(j - i))
+	}
+
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Path < locks[j].Path })
+	if len(errs) > 0 {
+		return locks, errors.Combine(errs)
+	}
+	return locks, nil
+}
+
+// lockArgPath returns the path of the file "file" given on the command line,
+// relative to the root of the repository, using rootRelativeLockPath if
+// --root-relative was given, and lockPath otherwise.
+func lockArgPath(file string) (string, error) {
+	if lockRootRelative {
+		return rootRelativeLockPath(file, false)
+	}
+	return lockPath(file)
+}
+
+// unlockArgPath is like lockArgPath, but also accepts the paths of files which
+// no longer exist, such as those deleted and committed since they were locked,
+// since they can only be unlocked if the server has a lock at that path.
+func unlockArgPath(file string) (string, error) {
+	if lockRootRelative {
+		return rootRelativeLockPath(file, true)
+	}
+	return lockPath(file)
+}
+
+// rootRelativeLockPath cleans the given filepath, which is relative to the root
+// of the repository rather than to the working directory, in the same way as
+// lockPath. Since the path doesn't pass through the working directory, an error
+// is returned unless it is a file which exists and is tracked by Git, to catch
+// paths which were not actually relative to the root, or, if "allowMissing" is
+// set, a file which does not exist at all.
+func rootRelativeLockPath(file string, allowMissing bool) (string, error) {
+	repo, err := git.RootDir()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.ToSlash(filepath.Clean(file))
+	if filepath.IsAbs(file) || path == ".." || strings.HasPrefix(path, "../") {
+		return "", fmt.Errorf("lfs: path %q is not relative to the root of the repository", file)
+	}
+	path = tools.CanonicalCase(repo, path, cfg.IgnoreCase())
+
+	stat, err := os.Stat(filepath.Join(repo, path))
+	if allowMissing && os.IsNotExist(err) {
+		return path, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("lfs: no such file in the repository: %s", path)
+	}
+	if stat.IsDir() {
+		return path, &lockDirectoryError{file}
+	}
+
+	tracked, err := git.IsFileTracked(path)
+	if err != nil {
+		return "", err
+	}
+	if !tracked {
+		return path, fmt.Errorf("lfs: file is not tracked by Git: %s", path)
+	}
+	return path, nil
+}
+
 // lockPaths relativizes the given filepath such that it is relative to the root
 // path of the repository it is contained within, taking into account the
 // working directory of the caller.
@@ -108,8 +483,12 @@ func lockPath(file string) (string, error) {
 		return "", fmt.Errorf("lfs: unable to canonicalize path %q", path)
 	}
 
+	// Where the filesystem ignores case, the file may have been given in
+	// any case, but must be locked with the name it has.
+	path = tools.CanonicalCase(repo, path, cfg.IgnoreCase())
+
 	if stat, err := os.Stat(abs); err == nil && stat.IsDir() {
-		return path, fmt.Errorf("lfs: cannot lock directory: %s", file)
+		return path, &lockDirectoryError{file}
 	}
 
 	return filepath.ToSlash(path), nil
@@ -119,5 +498,12 @@ func init() {
 	RegisterCommand("lock", lockCommand, func(cmd *cobra.Command) {
 		cmd.Flags().StringVarP(&lockRemote, "remote", "r", "", lockRemoteHelp)
 		cmd.Flags().BoolVarP(&locksCmdFlags.JSON, "json", "", false, "print output in json")
+		cmd.Flags().BoolVarP(&lockForce, "force", "f", false, "do not warn about paths which are not lockable")
+		cmd.Flags().StringVarP(&lockMessage, "message", "m", "", "the reason for the lock, kept with it by servers which support it")
+		cmd.Flags().BoolVarP(&lockRootRelative, "root-relative", "", false, "interpret paths relative to the root of the repository")
+		cmd.Flags().BoolVarP(&lockRecursive, "recursive", "", false, "lock the Git LFS files beneath the directories given")
+		cmd.Flags().StringVarP(&lockTransferTo, "transfer-to", "", "", "hand existing locks at the paths matching the patterns given over to this user")
+		cmd.Flags().StringVarP(&lockOwner, "owner", "", "", "with --transfer-to, hand over the locks held by this user, rather than your own")
+		cmd.Flags().BoolVarP(&lockYes, "yes", "y", false, "with --transfer-to, don't ask before handing locks over")
 	})
 }
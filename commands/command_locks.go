@@ -1,15 +1,22 @@
 package commands
 
 import (
+	"encoding/json"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/locking"
 	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/wildmatch"
 	"github.com/spf13/cobra"
 )
 
@@ -22,6 +29,33 @@ func locksCommand(cmd *cobra.Command, args []string) {
 	if err != nil {
 		Exit("Error building filters: %v", err)
 	}
+	match := locksCmdFlags.Matcher()
+
+	if locksCmdFlags.Mine || locksCmdFlags.Theirs {
+		if locksCmdFlags.Mine && locksCmdFlags.Theirs {
+			Exit("--mine option can't be combined with --theirs")
+		}
+		if locksCmdFlags.Verify {
+			Exit("--mine and --theirs options can't be combined with --verify")
+		}
+		if locksCmdFlags.Local {
+			Exit("--mine and --theirs options can't be combined with --local")
+		}
+		if locksCmdFlags.Watch {
+			Exit("--mine and --theirs options can't be combined with --watch")
+		}
+	}
+
+	var formatLock func(locking.Lock) string
+	if cmd.Flag("format").Changed {
+		if locksCmdFlags.JSON {
+			Exit("--format option can't be combined with --json")
+		}
+		formatLock, err = parseLockFormat(locksCmdFlags.Format)
+		if err != nil {
+			Exit("Invalid --format: %v", err)
+		}
+	}
 
 	if len(lockRemote) > 0 {
 		cfg.SetRemote(lockRemote)
@@ -32,6 +66,18 @@ func locksCommand(cmd *cobra.Command, args []string) {
 	lockClient.RemoteRef = refUpdate.Right()
 	defer lockClient.Close()
 
+	if locksCmdFlags.Refresh {
+		if len(filters) > 0 || match != nil || locksCmdFlags.Limit > 0 ||
+			locksCmdFlags.Local || locksCmdFlags.Cached || locksCmdFlags.Verify ||
+			locksCmdFlags.Mine || locksCmdFlags.Theirs || locksCmdFlags.Watch ||
+			locksCmdFlags.JSON || formatLock != nil {
+			Exit("--refresh option can't be combined with options other than --remote")
+		}
+
+		refreshLocks(lockClient)
+		return
+	}
+
 	if locksCmdFlags.Cached {
 		if locksCmdFlags.Limit > 0 {
 			Exit("--cached option can't be combined with --limit")
@@ -44,7 +90,26 @@ func locksCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	if locksCmdFlags.Verify {
+	if locksCmdFlags.Watch {
+		if locksCmdFlags.Local {
+			Exit("--watch option can't be combined with --local")
+		}
+		if locksCmdFlags.Cached {
+			Exit("--watch option can't be combined with --cached")
+		}
+		if locksCmdFlags.Verify {
+			Exit("--watch option can't be combined with --verify")
+		}
+		if formatLock != nil {
+			Exit("--watch option can't be combined with --format")
+		}
+
+		watchLocks(lockClient, filters, match)
+		return
+	}
+
+	verify := locksCmdFlags.Verify || locksCmdFlags.Mine || locksCmdFlags.Theirs
+	if verify {
 		if len(filters) > 0 {
 			Exit("--verify option can't be combined with filters")
 		}
@@ -53,30 +118,69 @@ func locksCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// The server can't apply the filters of "match", so is asked for all
+	// of the locks, which are limited once they are filtered.
+	limit := locksCmdFlags.Limit
+	if match != nil {
+		limit = 0
+	}
+
 	var locks []locking.Lock
 	var locksOwned map[locking.Lock]bool
 	var jsonWriteFunc func(io.Writer) error
-	if locksCmdFlags.Verify {
+	if verify {
 		var ourLocks, theirLocks []locking.Lock
-		ourLocks, theirLocks, err = lockClient.SearchLocksVerifiable(locksCmdFlags.Limit, locksCmdFlags.Cached)
-		jsonWriteFunc = func(writer io.Writer) error {
-			return lockClient.EncodeLocksVerifiable(ourLocks, theirLocks, writer)
+		ourLocks, theirLocks, err = lockClient.SearchLocksVerifiable(limit, locksCmdFlags.Cached)
+		if match != nil {
+			ourLocks = filterLocks(ourLocks, match, 0)
+			theirLocks = filterLocks(theirLocks, match, 0)
+		}
+
+		switch {
+		case locksCmdFlags.Mine:
+			locks = ourLocks
+		case locksCmdFlags.Theirs:
+			locks = theirLocks
+		default:
+			locks = append(ourLocks, theirLocks...)
+			locksOwned = make(map[locking.Lock]bool)
+			for _, lock := range ourLocks {
+				locksOwned[lock] = true
+			}
 		}
 
-		locks = append(ourLocks, theirLocks...)
-		locksOwned = make(map[locking.Lock]bool)
-		for _, lock := range ourLocks {
-			locksOwned[lock] = true
+		if locksCmdFlags.Verify {
+			jsonWriteFunc = func(writer io.Writer) error {
+				return lockClient.EncodeLocksVerifiable(ourLocks, theirLocks, writer)
+			}
+		} else {
+			jsonWriteFunc = func(writer io.Writer) error {
+				return lockClient.EncodeLocks(locks, writer)
+			}
 		}
 	} else {
-		locks, err = lockClient.SearchLocks(filters, locksCmdFlags.Limit, locksCmdFlags.Local, locksCmdFlags.Cached)
+		locks, err = lockClient.SearchLocks(filters, limit, locksCmdFlags.Local, locksCmdFlags.Cached)
 		jsonWriteFunc = func(writer io.Writer) error {
 			return lockClient.EncodeLocks(locks, writer)
 		}
 	}
 
+	if match != nil {
+		locks = filterLocks(locks, match, locksCmdFlags.Limit)
+	}
+
 	// Print any we got before exiting
 
+	if locksCmdFlags.Local && !locksCmdFlags.JSON && formatLock == nil {
+		Error("Listing locks recorded by this clone, which may be out of date.")
+	}
+	if locksCmdFlags.Cached && !locksCmdFlags.JSON && formatLock == nil {
+		if at, err := lockClient.LocksCachedAt(verify); err == nil {
+			Error("Listing locks cached at %s (%s ago), which may be out of date.",
+				at.Format(time.RFC3339), time.Since(at).Round(time.Second))
+		}
+	}
+
 	if locksCmdFlags.JSON {
 		if err := jsonWriteFunc(os.Stdout); err != nil {
 			Error(err.Error())
@@ -101,6 +205,10 @@ func locksCommand(cmd *cobra.Command, args []string) {
 	for _, lockPath := range lockPaths {
 		var ownerName string
 		lock := locksByPath[lockPath]
+		if formatLock != nil {
+			Print("%s", formatLock(lock))
+			continue
+		}
 		if lock.Owner != nil {
 			ownerName = lock.Owner.Name
 		}
@@ -116,9 +224,14 @@ func locksCommand(cmd *cobra.Command, args []string) {
 			}
 		}
 
-		Print("%s%s%s\t%s%s\tID:%s", kind, lock.Path, strings.Repeat(" ", pathPadding),
+		message := ""
+		if len(lock.Message) > 0 {
+			message = "\t" + lock.Message
+		}
+
+		Print("%s%s%s\t%s%s\tID:%s%s", kind, lock.Path, strings.Repeat(" ", pathPadding),
 			ownerName, strings.Repeat(" ", namePadding),
-			lock.Id,
+			lock.Id, message,
 		)
 	}
 
@@ -127,6 +240,227 @@ func locksCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// refreshLocks lists all of the locks from the server, both as they are listed
+// by default and as they are by --verify, so that they are cached, to be
+// listed with --cached, and to verify the locks of "git push" should the
+// server be unreachable then.
+func refreshLocks(lockClient *locking.Client) {
+	locks, err := lockClient.SearchLocks(nil, 0, false, false)
+	if err != nil {
+		Exit("Error while retrieving locks: %v", errors.Cause(err))
+	}
+
+	ours, _, err := lockClient.SearchLocksVerifiable(0, false)
+	if errors.IsNotImplementedError(err) {
+		Print("Cached %d lock(s)", len(locks))
+		Error("Remote %q does not support verifying locks, so only the locks listed without --verify were cached", cfg.PushRemote())
+		return
+	}
+	if err != nil {
+		Exit("Error while retrieving locks: %v", errors.Cause(err))
+	}
+
+	Print("Cached %d lock(s), %d of them our own", len(locks), len(ours))
+}
+
+const (
+	// defaultLocksWatchInterval is how often --watch asks for the locks
+	// unless lfs.locks.watchinterval says otherwise.
+	defaultLocksWatchInterval = 5 * time.Second
+	// maxLocksWatchBackoff is the longest --watch waits after the server
+	// fails, unless the interval is longer.
+	maxLocksWatchBackoff = 5 * time.Minute
+)
+
+// lockWatchEvent is a line of the output of --watch: a lock which was
+// "added" or "removed" since the locks were last asked for.
+type lockWatchEvent struct {
+	Event string `json:"event"`
+	locking.Lock
+}
+
+// watchLocks asks the server for the locks matching "filters", "match" (unless
+// it's nil), and --limit, every lfs.locks.watchinterval, and prints a lockWatchEvent as a line of JSON
+// for each lock added or removed since it last did, starting with those held
+// when it is first run. The wait is doubled each time the server fails, until
+// it succeeds again. It returns once interrupted.
+func watchLocks(lockClient *locking.Client, filters map[string]string, match func(locking.Lock) bool) {
+	interval := locksWatchInterval()
+
+	// Stop cleanly when interrupted, rather than exiting straight away
+	// as Git LFS otherwise does.
+	stop := make(chan os.Signal, 1)
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	encoder := json.NewEncoder(os.Stdout)
+	known := make(map[string]locking.Lock)
+	backoff := maxLocksWatchBackoff
+	if interval > backoff {
+		backoff = interval
+	}
+
+	wait := interval
+	for {
+		var locks []locking.Lock
+		var err error
+		if match != nil {
+			locks, err = lockClient.SearchLocks(filters, 0, false, false)
+			locks = filterLocks(locks, match, locksCmdFlags.Limit)
+		} else {
+			locks, err = lockClient.SearchLocks(filters, locksCmdFlags.Limit, false, false)
+		}
+		if err != nil {
+			if wait = wait * 2; wait > backoff {
+				wait = backoff
+			}
+			Error("Error while retrieving locks, retrying in %s: %v", wait, errors.Cause(err))
+		} else {
+			wait = interval
+			for _, ev := range lockChanges(known, locks) {
+				if err := encoder.Encode(ev); err != nil {
+					ExitWithError(err)
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// lockChanges returns the events for the locks "known" which aren't among
+// "locks", followed by those for the locks which are new, each in order of
+// their paths, and updates "known" to hold "locks", by ID.
+func lockChanges(known map[string]locking.Lock, locks []locking.Lock) []*lockWatchEvent {
+	current := make(map[string]locking.Lock, len(locks))
+	for _, lock := range locks {
+		current[lock.Id] = lock
+	}
+
+	var removed, added []*lockWatchEvent
+	for id, lock := range known {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, &lockWatchEvent{Event: "removed", Lock: lock})
+			delete(known, id)
+		}
+	}
+	for id, lock := range current {
+		if _, ok := known[id]; !ok {
+			added = append(added, &lockWatchEvent{Event: "added", Lock: lock})
+			known[id] = lock
+		}
+	}
+
+	for _, events := range [][]*lockWatchEvent{removed, added} {
+		sort.Slice(events, func(i, j int) bool {
+			if events[i].Path != events[j].Path {
+				return events[i].Path < events[j].Path
+			}
+			return events[i].Id < events[j].Id
+		})
+	}
+	return append(removed, added...)
+}
+
+// locksWatchInterval returns how long --watch waits between asking for the
+// locks, as given by lfs.locks.watchinterval.
+func locksWatchInterval() time.Duration {
+	v, ok := cfg.Git.Get("lfs.locks.watchinterval")
+	if !ok {
+		return defaultLocksWatchInterval
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval <= 0 {
+		Error("Ignoring invalid lfs.locks.watchinterval %q", v)
+		return defaultLocksWatchInterval
+	}
+	return interval
+}
+
+// lockFormatFields are the fields which may be given as "%(<name>)" in the
+// template of --format, with functions returning their values for a lock.
+var lockFormatFields = map[string]func(lock locking.Lock) string{
+	"id":   func(lock locking.Lock) string { return lock.Id },
+	"path": func(lock locking.Lock) string { return lock.Path },
+	"owner": func(lock locking.Lock) string {
+		if lock.Owner == nil {
+			return ""
+		}
+		return lock.Owner.Name
+	},
+	"locked_at": func(lock locking.Lock) string { return lock.LockedAt.Format(time.RFC3339) },
+	"message":   func(lock locking.Lock) string { return lock.Message },
+}
+
+// parseLockFormat parses "format", the template given with --format, and
+// returns a function which renders a lock with it. Like the templates of git
+// for-each-ref(1), "%%" stands for a "%", and "%xx", where xx are hexadecimal
+// digits, for the character with that code.
+func parseLockFormat(format string) (func(lock locking.Lock) string, error) {
+	var parts []func(lock locking.Lock) string
+	literal := func(s string) func(lock locking.Lock) string {
+		return func(lock locking.Lock) string { return s }
+	}
+
+	for len(format) > 0 {
+		i := strings.IndexByte(format, '%')
+		if i < 0 {
+			parts = append(parts, literal(format))
+			break
+		}
+		if i > 0 {
+			parts = append(parts, literal(format[:i]))
+		}
+		format = format[i:]
+
+		switch {
+		case strings.HasPrefix(format, "%%"):
+			parts = append(parts, literal("%"))
+			format = format[2:]
+		case strings.HasPrefix(format, "%("):
+			end := strings.IndexByte(format, ')')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated field %q", format)
+			}
+			name := format[2:end]
+			field, ok := lockFormatFields[name]
+			if !ok {
+				names := make([]string, 0, len(lockFormatFields))
+				for name := range lockFormatFields {
+					names = append(names, "%("+name+")")
+				}
+				sort.Strings(names)
+				return nil, errors.Errorf("unknown field %q, expected one of: %s", "%("+name+")", strings.Join(names, ", "))
+			}
+			parts = append(parts, field)
+			format = format[end+1:]
+		default:
+			if len(format) >= 3 {
+				if b, err := strconv.ParseUint(format[1:3], 16, 8); err == nil {
+					parts = append(parts, literal(string([]byte{byte(b)})))
+					format = format[3:]
+					continue
+				}
+			}
+			parts = append(parts, literal("%"))
+			format = format[1:]
+		}
+	}
+
+	return func(lock locking.Lock) string {
+		var b strings.Builder
+		for _, part := range parts {
+			b.WriteString(part(lock))
+		}
+		return b.String()
+	}, nil
+}
+
 // locksFlags wraps up and holds all of the flags that can be given to the
 // `git lfs locks` command.
 type locksFlags struct {
@@ -144,19 +478,40 @@ type locksFlags struct {
 	Local bool
 	// JSON is an optional parameter to output data in json format.
 	JSON bool
+	// Format is an optional template to output each lock with.
+	Format string
 	// for non-local queries, report cached query results from the last query
 	// instead of actually querying the server again
 	Cached bool
 	// for non-local queries, verify lock owner on server and
 	// denote our locks in output
 	Verify bool
+	// Watch keeps asking the server for the locks, and reports those
+	// added and removed as lines of JSON.
+	Watch bool
+	// Owner is an optional filter against the name of the lock's owner.
+	Owner string
+	// Mine and Theirs list only the locks held by the current user, or
+	// only those held by others, as found by --verify.
+	Mine   bool
+	Theirs bool
+	// Refresh lists all of the locks from the server, only to cache them
+	// for --cached and for the lock verification of "git push".
+	Refresh bool
+}
+
+// isLockPattern returns whether the --path given is a pattern, rather than the
+// path of a single file.
+func isLockPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
 }
 
-// Filters produces a filter based on locksFlags instance.
+// Filters produces a filter based on locksFlags instance, of those filters
+// which the server applies.
 func (l *locksFlags) Filters() (map[string]string, error) {
 	filters := make(map[string]string)
 
-	if l.Path != "" {
+	if l.Path != "" && !isLockPattern(l.Path) {
 		path, err := lockPath(l.Path)
 		if err != nil {
 			return nil, err
@@ -171,15 +526,65 @@ func (l *locksFlags) Filters() (map[string]string, error) {
 	return filters, nil
 }
 
+// Matcher returns a function which reports whether a lock matches the filters
+// which the server can't apply itself, and so are applied to the locks it
+// returns: a --path which is a pattern, and --owner. It returns nil if neither
+// is given.
+func (l *locksFlags) Matcher() func(locking.Lock) bool {
+	var pattern *wildmatch.Wildmatch
+	if isLockPattern(l.Path) {
+		// As in .gitattributes, a pattern without a slash matches
+		// files of that name in any directory.
+		pattern = wildmatch.NewWildmatch(strings.TrimPrefix(filepath.ToSlash(l.Path), "/"),
+			wildmatch.Basename, wildmatch.SystemCase)
+	}
+	if pattern == nil && l.Owner == "" {
+		return nil
+	}
+
+	return func(lock locking.Lock) bool {
+		if pattern != nil && !pattern.Match(lock.Path) {
+			return false
+		}
+		if l.Owner != "" && (lock.Owner == nil || lock.Owner.Name != l.Owner) {
+			return false
+		}
+		return true
+	}
+}
+
+// filterLocks returns the locks of "locks" which "match" allows, at most
+// "limit" of them, in order of their paths, unless "limit" is zero.
+func filterLocks(locks []locking.Lock, match func(locking.Lock) bool, limit int) []locking.Lock {
+	filtered := make([]locking.Lock, 0, len(locks))
+	for _, lock := range locks {
+		if match(lock) {
+			filtered = append(filtered, lock)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].Path < filtered[j].Path })
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+	return filtered
+}
+
 func init() {
 	RegisterCommand("locks", locksCommand, func(cmd *cobra.Command) {
 		cmd.Flags().StringVarP(&lockRemote, "remote", "r", "", lockRemoteHelp)
-		cmd.Flags().StringVarP(&locksCmdFlags.Path, "path", "p", "", "filter locks results matching a particular path")
+		cmd.Flags().StringVarP(&locksCmdFlags.Path, "path", "p", "", "filter locks results matching a particular path, or pattern")
 		cmd.Flags().StringVarP(&locksCmdFlags.Id, "id", "i", "", "filter locks results matching a particular ID")
 		cmd.Flags().IntVarP(&locksCmdFlags.Limit, "limit", "l", 0, "optional limit for number of results to return")
 		cmd.Flags().BoolVarP(&locksCmdFlags.Local, "local", "", false, "only list cached local record of own locks")
 		cmd.Flags().BoolVarP(&locksCmdFlags.Cached, "cached", "", false, "list cached lock information from the last remote query, instead of actually querying the server")
 		cmd.Flags().BoolVarP(&locksCmdFlags.Verify, "verify", "", false, "verify lock owner on server and mark own locks by 'O'")
 		cmd.Flags().BoolVarP(&locksCmdFlags.JSON, "json", "", false, "print output in json")
+		cmd.Flags().StringVarP(&locksCmdFlags.Format, "format", "", "", "print each lock with the given template, e.g. \"%(id) %(path)\"")
+		cmd.Flags().BoolVarP(&locksCmdFlags.Watch, "watch", "", false, "keep polling for locks, and print those added and removed as lines of JSON")
+		cmd.Flags().StringVarP(&locksCmdFlags.Owner, "owner", "", "", "filter locks results held by the owner with the given name")
+		cmd.Flags().BoolVarP(&locksCmdFlags.Mine, "mine", "", false, "list only our own locks, as found by --verify")
+		cmd.Flags().BoolVarP(&locksCmdFlags.Theirs, "theirs", "", false, "list only the locks held by others, as found by --verify")
+		cmd.Flags().BoolVarP(&locksCmdFlags.Refresh, "refresh", "", false, "cache all of the locks from the server, to be listed with --cached")
 	})
 }
@@ -1,7 +1,14 @@
 package commands
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/git-lfs/git-lfs/git"
@@ -11,21 +18,56 @@ import (
 )
 
 var (
-	longOIDs            = false
-	lsFilesScanAll      = false
-	lsFilesScanDeleted  = false
-	lsFilesShowSize     = false
-	lsFilesShowNameOnly = false
-	debug               = false
+	longOIDs             = false
+	lsFilesScanAll       = false
+	lsFilesScanDeleted   = false
+	lsFilesShowSize      = false
+	lsFilesShowNameOnly  = false
+	lsFilesShowOidOnly   = false
+	lsFilesNotDownloaded = false
+	lsFilesModified      = false
+	lsFilesJSON          = false
+	lsFilesRef           = ""
+	lsFilesSort          = ""
+	debug                = false
 )
 
+// lsFilesObject is a single Git LFS file, as listed by "git lfs ls-files
+// --json".
+type lsFilesObject struct {
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	Checkout   *bool  `json:"checkout,omitempty"`
+	Downloaded bool   `json:"downloaded"`
+	OidType    string `json:"oid_type"`
+	Oid        string `json:"oid"`
+	Version    string `json:"version"`
+}
+
 func lsFilesCommand(cmd *cobra.Command, args []string) {
 	setupRepository()
 
 	var ref string
 	var otherRef string
 	var scanRange = false
-	if len(args) > 0 {
+	if len(lsFilesRef) > 0 {
+		// With --ref, the tree of the reference is listed as it is,
+		// without looking at the index or the working tree, which
+		// needn't be checked out at all, or exist.
+		if len(args) > 0 {
+			Exit("fatal: cannot use --ref with explicit reference")
+		} else if lsFilesScanAll {
+			Exit("fatal: cannot use --ref with --all")
+		} else if lsFilesModified {
+			Exit("fatal: cannot use --ref with --modified")
+		}
+
+		resolved, err := git.ResolveRef(lsFilesRef)
+		if err != nil {
+			Exit("fatal: could not resolve %q: %s", lsFilesRef, err)
+		}
+		ref = resolved.Sha
+	} else if len(args) > 0 {
 		if lsFilesScanAll {
 			Exit("fatal: cannot use --all with explicit reference")
 		} else if args[0] == "--all" {
@@ -55,12 +97,80 @@ func lsFilesCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	switch lsFilesSort {
+	case "", "name", "size", "oid":
+	default:
+		Exit("fatal: invalid --sort %q; expected name, size or oid", lsFilesSort)
+	}
+
+	if lsFilesShowOidOnly && lsFilesShowNameOnly {
+		Exit("fatal: cannot use --oid-only with --name-only")
+	}
+
 	showOidLen := 10
 	if longOIDs {
 		showOidLen = 64
 	}
 
+	if lsFilesScanAll {
+		Error("Warning: scanning the history of every reference, which may be slow in large repositories")
+	}
+
 	seen := make(map[string]struct{})
+	seenOids := make(map[string]struct{})
+	listedOids := make(map[string]struct{})
+	files := []*lsFilesObject{}
+	var pointers []*lfs.WrappedPointer
+
+	// Files are listed with whether they are checked out, except with
+	// --ref, which lists a tree on its own.
+	inWorkingTree := len(lsFilesRef) == 0
+
+	list := func(p *lfs.WrappedPointer) {
+		if lsFilesJSON {
+			f := &lsFilesObject{
+				Name:       p.Name,
+				Size:       p.Size,
+				Downloaded: cfg.LFSObjectExists(p.Oid, p.Size),
+				OidType:    p.OidType,
+				Oid:        p.Oid,
+				Version:    p.Version,
+			}
+			if inWorkingTree {
+				checkout := fileExistsOfSize(p)
+				f.Checkout = &checkout
+			}
+			files = append(files, f)
+		} else if debug {
+			msg := fmt.Sprintf("filepath: %s\n    size: %d\n", p.Name, p.Size)
+			if inWorkingTree {
+				msg += fmt.Sprintf("checkout: %v\n", fileExistsOfSize(p))
+			}
+			msg += fmt.Sprintf("download: %v\n     oid: %s %s\n version: %s\n",
+				cfg.LFSObjectExists(p.Oid, p.Size), p.OidType, p.Oid, p.Version)
+			Print("%s", msg)
+		} else if lsFilesShowOidOnly {
+			// Each object is listed once, however many files refer
+			// to it, as the full OID alone.
+			if _, ok := listedOids[p.Oid]; !ok {
+				listedOids[p.Oid] = struct{}{}
+				Print(p.Oid)
+			}
+		} else if lsFilesShowNameOnly {
+			Print(p.Name)
+		} else {
+			msg := []string{p.Oid[:showOidLen], p.Name}
+			if inWorkingTree {
+				msg = []string{p.Oid[:showOidLen], lsFilesMarker(p), p.Name}
+			}
+			if lsFilesShowSize {
+				size := humanize.FormatBytes(uint64(p.Size))
+				msg = append(msg, "("+size+")")
+			}
+
+			Print(strings.Join(msg, " "))
+		}
+	}
 
 	gitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
 		if err != nil {
@@ -74,32 +184,26 @@ func lsFilesCommand(cmd *cobra.Command, args []string) {
 			}
 		}
 
-		if debug {
-			Print(
-				"filepath: %s\n"+
-					"    size: %d\n"+
-					"checkout: %v\n"+
-					"download: %v\n"+
-					"     oid: %s %s\n"+
-					" version: %s\n",
-				p.Name,
-				p.Size,
-				fileExistsOfSize(p),
-				cfg.LFSObjectExists(p.Oid, p.Size),
-				p.OidType,
-				p.Oid,
-				p.Version)
-		} else {
-			msg := []string{p.Oid[:showOidLen], lsFilesMarker(p), p.Name}
-			if lsFilesShowNameOnly {
-				msg = []string{p.Name}
-			}
-			if lsFilesShowSize {
-				size := humanize.FormatBytes(uint64(p.Size))
-				msg = append(msg, "("+size+")")
+		if !lsFilesSelected(p) {
+			seen[p.Name] = struct{}{}
+			return
+		}
+
+		// With --all, each object is listed once, by the first path
+		// it's found at, however many paths and commits refer to it.
+		if lsFilesScanAll {
+			if _, ok := seenOids[p.Oid]; ok {
+				return
 			}
+			seenOids[p.Oid] = struct{}{}
+		}
 
-			Print(strings.Join(msg, " "))
+		// Sorting needs every file, so they are listed once the scan
+		// is done, rather than as they are found.
+		if len(lsFilesSort) > 0 {
+			pointers = append(pointers, p)
+		} else {
+			list(p)
 		}
 
 		seen[p.Name] = struct{}{}
@@ -109,7 +213,7 @@ func lsFilesCommand(cmd *cobra.Command, args []string) {
 	includeArg, excludeArg := getIncludeExcludeArgs(cmd)
 	gitscanner.Filter = buildFilepathFilter(cfg, includeArg, excludeArg, false)
 
-	if len(args) == 0 {
+	if len(args) == 0 && inWorkingTree {
 		// Only scan the index when "git lfs ls-files" was invoked with
 		// no arguments.
 		//
@@ -137,6 +241,82 @@ func lsFilesCommand(cmd *cobra.Command, args []string) {
 			Exit("Could not scan for Git LFS tree: %s", err)
 		}
 	}
+
+	if len(lsFilesSort) > 0 {
+		sortLsFiles(pointers, lsFilesSort)
+		for _, p := range pointers {
+			list(p)
+		}
+	}
+
+	if lsFilesJSON {
+		encoded, err := json.Marshal(struct {
+			Ref   string           `json:"ref,omitempty"`
+			Files []*lsFilesObject `json:"files"`
+		}{lsFilesRef, files})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+	}
+}
+
+// sortLsFiles sorts "pointers" by "key", which is "name", "size", largest
+// first, or "oid". Files which compare equal are sorted by name.
+func sortLsFiles(pointers []*lfs.WrappedPointer, key string) {
+	sort.SliceStable(pointers, func(i, j int) bool {
+		a, b := pointers[i], pointers[j]
+		switch {
+		case key == "size" && a.Size != b.Size:
+			return a.Size > b.Size
+		case key == "oid" && a.Oid != b.Oid:
+			return a.Oid < b.Oid
+		}
+		return a.Name < b.Name
+	})
+}
+
+// lsFilesSelected returns whether "p" should be listed, given the
+// --not-downloaded and --modified filters. When both are given, files matching
+// either are listed.
+func lsFilesSelected(p *lfs.WrappedPointer) bool {
+	if !lsFilesNotDownloaded && !lsFilesModified {
+		return true
+	}
+	if lsFilesNotDownloaded && !cfg.LFSObjectExists(p.Oid, p.Size) {
+		return true
+	}
+	return lsFilesModified && fileModified(p)
+}
+
+// fileModified returns whether the working tree file of "p" exists and has
+// contents other than its Git LFS object. A file which still holds the pointer
+// itself is not considered modified.
+func fileModified(p *lfs.WrappedPointer) bool {
+	path := filepath.Join(cfg.LocalWorkingDir(), p.Name)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if wp, err := lfs.DecodePointerFromFile(path); err == nil {
+		return wp.Oid != p.Oid
+	}
+	if info.Size() != p.Size {
+		return true
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	oidHash := sha256.New()
+	if _, err := io.Copy(oidHash, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(oidHash.Sum(nil)) != p.Oid
 }
 
 // Returns true if a pointer appears to be properly smudge on checkout
@@ -158,9 +338,15 @@ func init() {
 		cmd.Flags().BoolVarP(&longOIDs, "long", "l", false, "")
 		cmd.Flags().BoolVarP(&lsFilesShowSize, "size", "s", false, "")
 		cmd.Flags().BoolVarP(&lsFilesShowNameOnly, "name-only", "n", false, "")
+		cmd.Flags().BoolVar(&lsFilesShowOidOnly, "oid-only", false, "")
 		cmd.Flags().BoolVarP(&debug, "debug", "d", false, "")
 		cmd.Flags().BoolVarP(&lsFilesScanAll, "all", "a", false, "")
 		cmd.Flags().BoolVar(&lsFilesScanDeleted, "deleted", false, "")
+		cmd.Flags().BoolVar(&lsFilesNotDownloaded, "not-downloaded", false, "")
+		cmd.Flags().BoolVar(&lsFilesModified, "modified", false, "")
+		cmd.Flags().BoolVar(&lsFilesJSON, "json", false, "")
+		cmd.Flags().StringVar(&lsFilesRef, "ref", "", "")
+		cmd.Flags().StringVar(&lsFilesSort, "sort", "", "")
 		cmd.Flags().StringVarP(&includeArg, "include", "I", "", "Include a list of paths")
 		cmd.Flags().StringVarP(&excludeArg, "exclude", "X", "", "Exclude a list of paths")
 	})
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"path"
 	"strings"
 
 	"github.com/git-lfs/git-lfs/errors"
@@ -61,6 +62,27 @@ var (
 	// migrateFixup is the flag indicating whether or not to infer the
 	// included and excluded filepath patterns.
 	migrateFixup bool
+
+	// migrateCompress is the flag indicating whether or not to expire the
+	// reflogs of rewritten refs and repack the repository after an import.
+	migrateCompress bool
+	// migrateForce indicates that the irreversible parts of --compress
+	// should be performed without asking for confirmation.
+	migrateForce bool
+	// migrateToRefPrefix is the prefix under which to write migrated refs,
+	// leaving the original refs untouched, if non-empty.
+	migrateToRefPrefix string
+	// migrateWorkers is the number of blobs 'git lfs migrate import'
+	// converts at once.
+	migrateWorkers int
+	// migrateChangesOnly indicates that 'git lfs migrate import' should
+	// reuse the pointers of blobs converted by earlier imports, rather
+	// than converting them again.
+	migrateChangesOnly bool
+	// importRemote is the remote from which 'git lfs migrate import'
+	// fetches the blobs to convert which are missing, as from a partial
+	// clone.
+	importRemote string
 )
 
 // migrate takes the given command and arguments, *gitobj.ObjectDatabase, as well
@@ -114,10 +136,16 @@ func rewriteOptions(args []string, opts *githistory.RewriteOptions, l *tasklog.L
 		Exclude: exclude,
 
 		UpdateRefs:        opts.UpdateRefs,
+		RefPrefix:         opts.RefPrefix,
 		Verbose:           opts.Verbose,
 		ObjectMapFilePath: opts.ObjectMapFilePath,
+		Workers:           opts.Workers,
 
 		BlobFn:            opts.BlobFn,
+		MissingBlobFn:     opts.MissingBlobFn,
+		MissingBlobsFn:    opts.MissingBlobsFn,
+		BlobCache:         opts.BlobCache,
+		ProgressFn:        opts.ProgressFn,
 		TreePreCallbackFn: opts.TreePreCallbackFn,
 		TreeCallbackFn:    opts.TreeCallbackFn,
 	}, nil
@@ -134,6 +162,9 @@ func rewriteOptions(args []string, opts *githistory.RewriteOptions, l *tasklog.L
 //     arguments and the --include-ref= or --exclude-ref= flag(s) aren't given.
 //   - Include all references given in --include-ref=<ref>.
 //   - Exclude all references given in --exclude-ref=<ref>.
+//
+// Values of --include-ref= and --exclude-ref= containing glob characters are
+// matched against all references (see expandRefPatterns).
 func includeExcludeRefs(l *tasklog.Logger, args []string) (include, exclude []string, err error) {
 	hardcore := len(migrateIncludeRefs) > 0 || len(migrateExcludeRefs) > 0
 
@@ -180,9 +211,22 @@ func includeExcludeRefs(l *tasklog.Logger, args []string) (include, exclude []st
 
 		// If either --include-ref=<ref> or --exclude-ref=<ref> were
 		// given, append those to the include and excluded reference
-		// set, respectively.
-		include = append(include, migrateIncludeRefs...)
-		exclude = append(exclude, migrateExcludeRefs...)
+		// set, respectively, expanding any glob patterns among them.
+		includeRefs, err := expandRefPatterns(migrateIncludeRefs, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		excludeRefs, err := expandRefPatterns(migrateExcludeRefs, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		include = append(include, includeRefs...)
+		exclude = append(exclude, excludeRefs...)
+
+		if err := warnSharedHistory(l, include, excludeRefs); err != nil {
+			return nil, nil, err
+		}
 	} else if migrateEverything {
 		refs, err := git.AllRefsIn("")
 		if err != nil {
@@ -238,6 +282,78 @@ func includeExcludeRefs(l *tasklog.Logger, args []string) (include, exclude []st
 	return include, exclude, nil
 }
 
+// expandRefPatterns returns the given references, where each one containing
+// glob characters is replaced by the fully-qualified names of all references
+// it matches. Patterns are matched with path.Match against both the full
+// name of a reference, i.e., "refs/heads/release/*", and its short name, i.e.,
+// "release/*".
+//
+// If "required" is true, a pattern which matches no references is an error.
+func expandRefPatterns(patterns []string, required bool) ([]string, error) {
+	var refs []*git.Ref
+
+	expanded := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?[") {
+			expanded = append(expanded, pattern)
+			continue
+		}
+
+		if refs == nil {
+			var err error
+			if refs, err = git.AllRefsIn(""); err != nil {
+				return nil, err
+			}
+		}
+
+		var matched bool
+		for _, ref := range refs {
+			if matchesRefPattern(pattern, ref) {
+				expanded = append(expanded, ref.Refspec())
+				matched = true
+			}
+		}
+
+		if !matched && required {
+			return nil, errors.Errorf("fatal: no references match %q", pattern)
+		}
+	}
+	return expanded, nil
+}
+
+// matchesRefPattern returns whether the glob "pattern" matches either the
+// fully-qualified or short name of "ref".
+func matchesRefPattern(pattern string, ref *git.Ref) bool {
+	for _, name := range []string{ref.Refspec(), ref.Name} {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// warnSharedHistory logs a warning for each excluded reference which shares
+// history with an included reference, since the commits reachable from both
+// will not be migrated.
+func warnSharedHistory(l *tasklog.Logger, include, exclude []string) error {
+	for _, ex := range exclude {
+		for _, in := range include {
+			base, err := git.MergeBase(in, ex)
+			if err != nil {
+				return err
+			}
+			if len(base) == 0 {
+				continue
+			}
+
+			task := l.Simple()
+			task.Logf("migrate: warning: excluded ref %s shares history with included ref %s; shared commits will not be migrated", ex, in)
+			task.Complete()
+		}
+	}
+	return nil
+}
+
 // getRemoteRefs returns a fully qualified set of references belonging to all
 // remotes known by the currently checked-out repository, or an error if those
 // references could not be determined.
@@ -313,6 +429,34 @@ func getHistoryRewriter(cmd *cobra.Command, db *gitobj.ObjectDatabase, l *tasklo
 		githistory.WithFilter(filter), githistory.WithLogger(l))
 }
 
+// migratePrompt asks the given yes or no question, returning true only if the
+// answer is yes.
+func migratePrompt(in io.Reader, out io.Writer, question string) bool {
+	answer := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "migrate: %s [y/N] ", question)
+		s, err := answer.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return false
+			}
+			ExitWithError(errors.Wrap(err,
+				"fatal: could not read answer"))
+		}
+
+		switch strings.TrimSpace(s) {
+		case "n", "N", "":
+			return false
+		case "y", "Y":
+			return true
+		}
+
+		if !strings.HasSuffix(s, "\n") {
+			fmt.Fprintf(out, "\n")
+		}
+	}
+}
+
 func ensureWorkingCopyClean(in io.Reader, out io.Writer) {
 	dirty, err := git.IsWorkingCopyDirty()
 	if err != nil {
@@ -324,37 +468,8 @@ func ensureWorkingCopyClean(in io.Reader, out io.Writer) {
 		return
 	}
 
-	var proceed bool
-	if migrateYes {
-		proceed = true
-	} else {
-		answer := bufio.NewReader(in)
-	L:
-		for {
-			fmt.Fprintf(out, "migrate: override changes in your working copy?  All uncommitted changes will be lost! [y/N] ")
-			s, err := answer.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					break L
-				}
-				ExitWithError(errors.Wrap(err,
-					"fatal: could not read answer"))
-			}
-
-			switch strings.TrimSpace(s) {
-			case "n", "N", "":
-				proceed = false
-				break L
-			case "y", "Y":
-				proceed = true
-				break L
-			}
-
-			if !strings.HasSuffix(s, "\n") {
-				fmt.Fprintf(out, "\n")
-			}
-		}
-	}
+	proceed := migrateYes || migratePrompt(in, out,
+		"override changes in your working copy?  All uncommitted changes will be lost!")
 
 	if proceed {
 		fmt.Fprintf(out, "migrate: changes in your working copy will be overridden ...\n")
@@ -370,6 +485,7 @@ func init() {
 	info.Flags().StringVar(&migrateInfoUnitFmt, "unit", "", "--unit=<unit>")
 	info.Flags().StringVar(&migrateInfoPointers, "pointers", "", "Ignore, dereference, or include LFS pointer files")
 	info.Flags().BoolVar(&migrateFixup, "fixup", false, "Infer filepaths based on .gitattributes")
+	info.Flags().BoolVar(&migrateInfoJSON, "json", false, "Give the output in a stable json format for scripts")
 
 	importCmd := NewCommand("import", migrateImportCommand)
 	importCmd.Flags().StringVar(&migrateImportAboveFmt, "above", "", "--above=<n>")
@@ -378,6 +494,12 @@ func init() {
 	importCmd.Flags().BoolVar(&migrateNoRewrite, "no-rewrite", false, "Add new history without rewriting previous")
 	importCmd.Flags().StringVarP(&migrateCommitMessage, "message", "m", "", "With --no-rewrite, an optional commit message")
 	importCmd.Flags().BoolVar(&migrateFixup, "fixup", false, "Infer filepaths based on .gitattributes")
+	importCmd.Flags().BoolVar(&migrateCompress, "compress", false, "Expire reflogs of rewritten refs and repack afterwards")
+	importCmd.Flags().BoolVar(&migrateForce, "force", false, "With --compress, expire reflogs without asking")
+	importCmd.Flags().StringVar(&migrateToRefPrefix, "to-ref-prefix", "", "Write migrated refs under this prefix, leaving the originals untouched")
+	importCmd.Flags().IntVar(&migrateWorkers, "workers", 1, "Convert this many files at once")
+	importCmd.Flags().StringVar(&importRemote, "remote", "", "Remote from which to fetch missing blobs")
+	importCmd.Flags().BoolVar(&migrateChangesOnly, "changes-only", false, "Don't convert files again which earlier imports converted")
 
 	exportCmd := NewCommand("export", migrateExportCommand)
 	exportCmd.Flags().BoolVar(&migrateVerbose, "verbose", false, "Verbose logging")
@@ -388,8 +510,8 @@ func init() {
 		cmd.PersistentFlags().StringVarP(&includeArg, "include", "I", "", "Include a list of paths")
 		cmd.PersistentFlags().StringVarP(&excludeArg, "exclude", "X", "", "Exclude a list of paths")
 
-		cmd.PersistentFlags().StringSliceVar(&migrateIncludeRefs, "include-ref", nil, "An explicit list of refs to include")
-		cmd.PersistentFlags().StringSliceVar(&migrateExcludeRefs, "exclude-ref", nil, "An explicit list of refs to exclude")
+		cmd.PersistentFlags().StringSliceVar(&migrateIncludeRefs, "include-ref", nil, "An explicit list of refs (or glob patterns) to include")
+		cmd.PersistentFlags().StringSliceVar(&migrateExcludeRefs, "exclude-ref", nil, "An explicit list of refs (or glob patterns) to exclude")
 		cmd.PersistentFlags().BoolVar(&migrateEverything, "everything", false, "Migrate all local references")
 		cmd.PersistentFlags().BoolVar(&migrateSkipFetch, "skip-fetch", false, "Assume up-to-date remote references.")
 
@@ -56,12 +56,7 @@ func migrateExportCommand(cmd *cobra.Command, args []string) {
 				return nil, err
 			}
 
-			downloadPath, err := gitfilter.ObjectPath(ptr.Oid)
-			if err != nil {
-				return nil, err
-			}
-
-			return gitobj.NewBlobFromFile(downloadPath)
+			return gitobj.NewBlobFromFile(cfg.Filesystem().ObjectReadPathname(ptr.Oid, ptr.Size))
 		},
 
 		TreeCallbackFn: func(path string, t *gitobj.Tree) (*gitobj.Tree, error) {
@@ -130,14 +125,15 @@ func migrateExportCommand(cmd *cobra.Command, args []string) {
 				return
 			}
 
-			downloadPath, err := gitfilter.ObjectPath(p.Oid)
-			if err != nil {
+			if cfg.Filesystem().ObjectExists(p.Oid, p.Size) {
 				return
 			}
 
-			if _, err := os.Stat(downloadPath); os.IsNotExist(err) {
-				q.Add(p.Name, downloadPath, p.Oid, p.Size, false, nil)
+			downloadPath, err := gitfilter.ObjectPath(p.Oid)
+			if err != nil {
+				return
 			}
+			q.Add(p.Name, downloadPath, p.Oid, p.Size, false, nil)
 		})
 		gs.ScanRefs(opts.Include, opts.Exclude, nil)
 
@@ -175,7 +171,7 @@ func migrateExportCommand(cmd *cobra.Command, args []string) {
 	fetchPruneCfg.FetchRecentRefsDays = 0
 
 	// Prune our cache
-	prune(fetchPruneCfg, false, false, true)
+	prune(fetchPruneCfg, false, false, true, pruneReportNone)
 }
 
 // trackedFromExportFilter returns an ordered set of strings where each entry
@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/filepathfilter"
@@ -22,6 +26,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// migrateFetchChunkSize is the greatest number of missing blobs fetched from
+// --remote with any one invocation of git-fetch(1).
+const migrateFetchChunkSize = 500
+
 func migrateImportCommand(cmd *cobra.Command, args []string) {
 	ensureWorkingCopyClean(os.Stdin, os.Stderr)
 
@@ -45,6 +53,12 @@ func migrateImportCommand(cmd *cobra.Command, args []string) {
 		if migrateFixup {
 			ExitWithError(errors.Errorf("fatal: --no-rewrite and --fixup cannot be combined"))
 		}
+		if migrateCompress {
+			ExitWithError(errors.Errorf("fatal: --no-rewrite and --compress cannot be combined"))
+		}
+		if len(migrateToRefPrefix) > 0 {
+			ExitWithError(errors.Errorf("fatal: --no-rewrite and --to-ref-prefix cannot be combined"))
+		}
 
 		if len(args) == 0 {
 			ExitWithError(errors.Errorf("fatal: expected one or more files with --no-rewrite"))
@@ -127,41 +141,184 @@ func migrateImportCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if !cmd.Flags().Changed("workers") {
+		migrateWorkers = cfg.Git.Int("lfs.migrate.workers", 1)
+		if migrateWorkers < 1 {
+			ExitWithError(errors.Errorf("fatal: lfs.migrate.workers must be at least 1, got %d", migrateWorkers))
+		}
+	}
+	if migrateWorkers < 1 {
+		ExitWithError(errors.Errorf("fatal: --workers must be at least 1, got %d", migrateWorkers))
+	}
+
+	refPrefix := strings.TrimSuffix(migrateToRefPrefix, "/")
+	if len(migrateToRefPrefix) > 0 {
+		if !strings.HasPrefix(refPrefix, "refs/") {
+			ExitWithError(errors.Errorf("fatal: --to-ref-prefix must start with \"refs/\", got %q", migrateToRefPrefix))
+		}
+		if migrateCompress {
+			ExitWithError(errors.Errorf("fatal: --to-ref-prefix and --compress cannot be combined"))
+		}
+	}
+
+	// Expiring reflogs cannot be undone, so ask before rewriting anything.
+	if migrateCompress && !migrateForce && !migrateYes {
+		if !migratePrompt(os.Stdin, os.Stderr, "--compress will expire the reflogs of rewritten refs and prune their old history.  This cannot be undone!  Continue?") {
+			Exit("migrate: pass --force to compress without confirmation")
+		}
+	}
+
+	var before []*git.Ref
+	if migrateCompress {
+		if before, err = git.AllRefsIn(""); err != nil {
+			ExitWithError(errors.Wrap(err, "fatal: could not list refs"))
+		}
+	}
+
+	if len(importRemote) > 0 {
+		if err := git.ValidateRemote(importRemote); err != nil {
+			ExitWithError(errors.Wrap(err, "fatal: invalid --remote"))
+		}
+	}
+
 	rewriter := getHistoryRewriter(cmd, db, l)
 
 	tracked := trackedFromFilter(rewriter.Filter())
 	exts := tools.NewOrderedSet()
 	gitfilter := lfs.NewGitFilter(cfg)
 
+	// The patterns of the files converted in each commit are only added to
+	// "exts" once all of them have been, in the order of their paths, which
+	// is the order they are visited in, so that it doesn't depend on which
+	// of the workers finishes first.
+	var extsMu sync.Mutex
+	newExts := make(map[string]string)
+
 	var fixups *gitattr.Tree
 	above, err := humanize.ParseBytes(migrateImportAboveFmt)
 	if err != nil {
 		ExitWithError(errors.Wrap(err, "fatal: cannot parse --above=<n>"))
 	}
 
-	migrate(args, rewriter, l, &githistory.RewriteOptions{
+	// fetched lists the commits and paths of the blobs fetched from
+	// --remote, to be reported once they have all been converted.
+	var fetched []string
+	var fetchedMu sync.Mutex
+
+	// fetchedDb is the object database opened after the missing blobs are
+	// fetched from --remote, since "db" does not notice the packs they are
+	// fetched into.
+	var fetchedDb *gitobj.ObjectDatabase
+	defer func() {
+		if fetchedDb != nil {
+			fetchedDb.Close()
+		}
+	}()
+
+	// converts returns whether the blob at "path" of size "size" is to be
+	// converted into a pointer.
+	converts := func(path string, size int64) bool {
+		if filepath.Base(path) == ".gitattributes" {
+			return false
+		}
+		if (above > 0) && (uint64(size) < above) {
+			return false
+		}
+
+		if migrateFixup {
+			var ok bool
+			attrs := fixups.Applied(path)
+			for _, attr := range attrs {
+				if attr.K == "filter" {
+					ok = attr.V == "lfs"
+				}
+			}
+			return ok
+		}
+		return true
+	}
+
+	// converted records the pattern to track for the file at "path",
+	// which has been converted.
+	converted := func(path string) {
+		var pattern string
+		if ext := filepath.Ext(path); len(ext) > 0 && above == 0 {
+			pattern = fmt.Sprintf("*%s filter=lfs diff=lfs merge=lfs -text", ext)
+		} else {
+			pattern = fmt.Sprintf("/%s filter=lfs diff=lfs merge=lfs -text", path)
+		}
+
+		extsMu.Lock()
+		newExts[path] = pattern
+		extsMu.Unlock()
+	}
+
+	var cache *importBlobCache
+	if migrateChangesOnly {
+		cache, err = newImportBlobCache(db, importBlobCachePath(), converts, converted)
+		if err != nil {
+			ExitWithError(errors.Wrap(err, "fatal: could not read the blobs converted by earlier imports"))
+		}
+	}
+
+	// hashed is the number of bytes of the blobs converted so far, which
+	// is given with the throughput of the conversion as commits are
+	// rewritten. It is managed with sync/atomic.
+	var hashed uint64
+	start := time.Now()
+
+	opts := &githistory.RewriteOptions{
 		Verbose:           migrateVerbose,
 		ObjectMapFilePath: objectMapFilePath,
-		BlobFn: func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
-			if filepath.Base(path) == ".gitattributes" {
-				return b, nil
+		MissingBlobsFn: func(oids [][]byte) error {
+			if len(importRemote) == 0 {
+				return nil
 			}
-			if (above > 0) && (uint64(b.Size) < above) {
-				return b, nil
+
+			hexOids := make([]string, 0, len(oids))
+			for _, oid := range oids {
+				hexOids = append(hexOids, hex.EncodeToString(oid))
 			}
 
-			if migrateFixup {
-				var ok bool
-				attrs := fixups.Applied(path)
-				for _, attr := range attrs {
-					if attr.K == "filter" {
-						ok = attr.V == "lfs"
-					}
+			// Fetch in chunks, so as to keep each command line
+			// within the limits of the platform.
+			for len(hexOids) > 0 {
+				n := migrateFetchChunkSize
+				if n > len(hexOids) {
+					n = len(hexOids)
 				}
-
-				if !ok {
-					return b, nil
+				if err := git.FetchObjects(importRemote, hexOids[:n]...); err != nil {
+					return errors.Wrapf(err, "could not fetch %d missing blob(s) from %s", len(oids), importRemote)
 				}
+				hexOids = hexOids[n:]
+			}
+
+			var err error
+			fetchedDb, err = getObjectDatabase()
+			return err
+		},
+		MissingBlobFn: func(commitOID []byte, path string, oid []byte) (*gitobj.Blob, error) {
+			if len(importRemote) == 0 {
+				return nil, errors.Errorf("blob %x of %s in commit %x is missing, such as from a partial clone; pass --remote to fetch it", oid, path, commitOID)
+			}
+
+			if fetchedDb == nil {
+				return nil, errors.Errorf("blob %x of %s in commit %x is missing, but was not fetched from %s", oid, path, commitOID, importRemote)
+			}
+
+			blob, err := fetchedDb.Blob(oid)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not fetch blob %x of %s in commit %x from %s", oid, path, commitOID, importRemote)
+			}
+
+			fetchedMu.Lock()
+			fetched = append(fetched, fmt.Sprintf("commit %x: %s", commitOID, path))
+			fetchedMu.Unlock()
+			return blob, nil
+		},
+		BlobFn: func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+			if !converts(path, b.Size) {
+				return b, nil
 			}
 
 			var buf bytes.Buffer
@@ -169,12 +326,8 @@ func migrateImportCommand(cmd *cobra.Command, args []string) {
 			if _, err := clean(gitfilter, &buf, b.Contents, path, b.Size); err != nil {
 				return nil, err
 			}
-
-			if ext := filepath.Ext(path); len(ext) > 0 && above == 0 {
-				exts.Add(fmt.Sprintf("*%s filter=lfs diff=lfs merge=lfs -text", ext))
-			} else {
-				exts.Add(fmt.Sprintf("/%s filter=lfs diff=lfs merge=lfs -text", path))
-			}
+			atomic.AddUint64(&hashed, uint64(b.Size))
+			converted(path)
 
 			return &gitobj.Blob{
 				Contents: &buf, Size: int64(buf.Len()),
@@ -195,6 +348,19 @@ func migrateImportCommand(cmd *cobra.Command, args []string) {
 		},
 
 		TreeCallbackFn: func(path string, t *gitobj.Tree) (*gitobj.Tree, error) {
+			if path == "/" {
+				paths := make([]string, 0, len(newExts))
+				for p := range newExts {
+					paths = append(paths, p)
+				}
+				sort.Strings(paths)
+
+				for _, p := range paths {
+					exts.Add(newExts[p])
+				}
+				newExts = make(map[string]string)
+			}
+
 			if path != "/" || migrateFixup {
 				// Avoid updating .gitattributes in non-root
 				// trees, or if --fixup is given.
@@ -242,22 +408,129 @@ func migrateImportCommand(cmd *cobra.Command, args []string) {
 			}
 
 			// Finally, return a copy of the tree "t" that has the
-			// new .gitattributes file included/replaced.
+			// new .gitattributes file included/replaced, keeping
+			// the mode of an existing executable one.
+			mode := int32(0100644)
+			if i := findEntry(t, ".gitattributes"); i >= 0 && t.Entries[i].Filemode == 0100755 {
+				mode = 0100755
+			}
 			return t.Merge(&gitobj.TreeEntry{
 				Name:     ".gitattributes",
-				Filemode: 0100644,
+				Filemode: mode,
 				Oid:      blob,
 			}), nil
 		},
 
+		ProgressFn: func() string {
+			var progress []string
+			if n := atomic.LoadUint64(&hashed); n > 0 {
+				progress = append(progress, fmt.Sprintf("%s converted, %s", humanize.FormatBytes(n), humanize.FormatByteRate(n, time.Since(start))))
+			}
+			if cache != nil && cache.Unchanged() > 0 {
+				progress = append(progress, fmt.Sprintf("%d unchanged", cache.Unchanged()))
+			}
+			return strings.Join(progress, ", ")
+		},
+
 		UpdateRefs: true,
-	})
+		RefPrefix:  refPrefix,
+		Workers:    migrateWorkers,
+	}
+	if cache != nil {
+		// A nil *importBlobCache isn't a nil githistory.BlobCache.
+		opts.BlobCache = cache
+	}
+	migrate(args, rewriter, l, opts)
+
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			ExitWithError(errors.Wrap(err, "fatal: could not record the blobs converted"))
+		}
+	}
 
-	if err := checkoutNonBare(l); err != nil {
-		ExitWithError(errors.Wrap(err, "fatal: could not checkout"))
+	if len(fetched) > 0 {
+		task := l.List(fmt.Sprintf("migrate: Fetched %d missing blob(s) from %s", len(fetched), importRemote))
+		for _, f := range fetched {
+			task.Entry(f)
+		}
+		task.Complete()
+	}
+
+	// The current branch, if any, hasn't moved if the migrated refs were
+	// written elsewhere, so neither should the working copy.
+	if len(refPrefix) == 0 {
+		if err := checkoutNonBare(l); err != nil {
+			ExitWithError(errors.Wrap(err, "fatal: could not checkout"))
+		}
+	}
+
+	if migrateCompress {
+		if err := compressRewrittenRefs(l, before); err != nil {
+			ExitWithError(errors.Wrap(err, "fatal: could not compress repository"))
+		}
 	}
 }
 
+// compressRewrittenRefs expires the reflogs of the refs which have changed
+// since "before" was listed, along with that of HEAD if it points to one, and
+// then repacks the repository so that the history which only they referred to
+// is removed. It logs the size of the object database before and after.
+func compressRewrittenRefs(l *tasklog.Logger, before []*git.Ref) error {
+	old := make(map[string]string, len(before))
+	for _, ref := range before {
+		old[ref.Refspec()] = ref.Sha
+	}
+
+	after, err := git.AllRefsIn("")
+	if err != nil {
+		return err
+	}
+
+	var rewritten []string
+	for _, ref := range after {
+		if sha, ok := old[ref.Refspec()]; ok && sha != ref.Sha {
+			rewritten = append(rewritten, ref.Refspec())
+		}
+	}
+	if len(rewritten) == 0 {
+		return nil
+	}
+
+	if head, err := git.CurrentRef(); err == nil {
+		for _, name := range rewritten {
+			if name == head.Refspec() {
+				rewritten = append(rewritten, "HEAD")
+				break
+			}
+		}
+	}
+
+	size, err := git.ObjectDatabaseSize()
+	if err != nil {
+		return err
+	}
+
+	t := l.Waiter("migrate: compressing repository")
+	if err = git.ExpireReflogs(rewritten...); err == nil {
+		err = git.GarbageCollect()
+	}
+	t.Complete()
+	if err != nil {
+		return err
+	}
+
+	compressed, err := git.ObjectDatabaseSize()
+	if err != nil {
+		return err
+	}
+
+	task := l.Simple()
+	task.Logf("migrate: Git objects took %s, and now take %s",
+		humanize.FormatBytes(uint64(size)), humanize.FormatBytes(uint64(compressed)))
+	task.Complete()
+	return nil
+}
+
 // generateMigrateCommitMessage generates a commit message used with
 // --no-rewrite, using --message (if given) or generating one if it isn't.
 func generateMigrateCommitMessage(cmd *cobra.Command, patterns string) string {
@@ -389,6 +662,15 @@ func rewriteTree(gf *lfs.GitFilter, db *gitobj.ObjectDatabase, root []byte, path
 		}
 
 		blobEntry := tree.Entries[index]
+		if blobEntry.Filemode == 0120000 {
+			// The contents of a symbolic link are its target,
+			// which would be meaningless in Git LFS.
+			return nil, errors.Errorf("migrate: %s is a symbolic link, and can't be stored in Git LFS", splits[0])
+		}
+		if blobEntry.Type() != gitobj.BlobObjectType {
+			return nil, errors.Errorf("migrate: expected %s to be a blob, got %s", splits[0], blobEntry.Type())
+		}
+
 		blob, err := db.Blob(blobEntry.Oid)
 		if err != nil {
 			return nil, err
@@ -460,3 +742,146 @@ func findEntry(t *gitobj.Tree, name string) int {
 
 	return -1
 }
+
+// importBlobCachePath returns the path of the file in which 'git lfs migrate
+// import --changes-only' records the blobs it converted.
+func importBlobCachePath() string {
+	return filepath.Join(cfg.LFSStorageDir(), "migrate", "import-blobs")
+}
+
+// importBlobCache is the githistory.BlobCache of 'git lfs migrate import
+// --changes-only'. It holds the pointer blob each blob was converted to by
+// earlier imports, so that those whose objects are still in the local object
+// store are used again, rather than being hashed and copied into it again.
+type importBlobCache struct {
+	db   *gitobj.ObjectDatabase
+	path string
+
+	// converts returns whether the blob at "path" of size "size" is to be
+	// converted, and converted records that it was.
+	converts  func(path string, size int64) bool
+	converted func(path string)
+
+	// blobs maps the hex SHA1 of each original blob to that of its
+	// pointer blob.
+	blobs map[string]string
+	mu    sync.Mutex
+	// unchanged is the number of blobs which weren't converted again. It
+	// is managed with sync/atomic.
+	unchanged uint64
+}
+
+// newImportBlobCache returns an *importBlobCache holding the blobs recorded in
+// the file at "path", if it exists.
+func newImportBlobCache(db *gitobj.ObjectDatabase, path string, converts func(string, int64) bool, converted func(string)) (*importBlobCache, error) {
+	c := &importBlobCache{
+		db:        db,
+		path:      path,
+		converts:  converts,
+		converted: converted,
+		blobs:     make(map[string]string),
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		c.blobs[fields[0]] = fields[1]
+	}
+	return c, scanner.Err()
+}
+
+// Rewritten implements githistory.BlobCache, giving the pointer blob which
+// "from" was converted to, so long as it is to be converted at "path", and
+// the object of the pointer is in the local object store.
+func (c *importBlobCache) Rewritten(path string, from []byte, size int64) ([]byte, bool) {
+	if !c.converts(path, size) {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	to, ok := c.blobs[hex.EncodeToString(from)]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	sha, err := hex.DecodeString(to)
+	if err != nil {
+		return nil, false
+	}
+	blob, err := c.db.Blob(sha)
+	if err != nil {
+		return nil, false
+	}
+	defer blob.Close()
+
+	ptr, err := lfs.DecodePointer(blob.Contents)
+	if err != nil || !cfg.LFSObjectExists(ptr.Oid, ptr.Size) {
+		return nil, false
+	}
+
+	atomic.AddUint64(&c.unchanged, 1)
+	c.converted(path)
+	return sha, true
+}
+
+// Add implements githistory.BlobCache.
+func (c *importBlobCache) Add(from, to []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blobs[hex.EncodeToString(from)] = hex.EncodeToString(to)
+}
+
+// Unchanged returns the number of blobs which weren't converted again.
+func (c *importBlobCache) Unchanged() uint64 {
+	return atomic.LoadUint64(&c.unchanged)
+}
+
+// Save writes the blobs held to the file the cache was read from, replacing
+// it.
+func (c *importBlobCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := tools.MkdirAll(filepath.Dir(c.path), cfg); err != nil {
+		return err
+	}
+
+	froms := make([]string, 0, len(c.blobs))
+	for from := range c.blobs {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	f, err := tools.TempFile(cfg.TempDir(), "migrate-import-blobs", cfg)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	for _, from := range froms {
+		fmt.Fprintf(w, "%s %s\n", from, c.blobs[from])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path)
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -22,9 +23,10 @@ import (
 type migrateInfoPointersType int
 
 const (
-	migrateInfoPointersFollow   = migrateInfoPointersType(iota)
-	migrateInfoPointersNoFollow = migrateInfoPointersType(iota)
-	migrateInfoPointersIgnore   = migrateInfoPointersType(iota)
+	migrateInfoPointersFollow      = migrateInfoPointersType(iota)
+	migrateInfoPointersNoFollow    = migrateInfoPointersType(iota)
+	migrateInfoPointersIgnore      = migrateInfoPointersType(iota)
+	migrateInfoPointersByExtension = migrateInfoPointersType(iota)
 )
 
 var (
@@ -54,6 +56,10 @@ var (
 	// migrateInfoPointersMode is the Git LFS pointer treatment mode
 	// parsed from migrateInfoPointers.
 	migrateInfoPointersMode migrateInfoPointersType
+
+	// migrateInfoJSON is a flag given to the git-lfs-migrate(1) subcommand
+	// 'info' specifying that the entries be printed as JSON.
+	migrateInfoJSON bool
 )
 
 func migrateInfoCommand(cmd *cobra.Command, args []string) {
@@ -94,6 +100,8 @@ func migrateInfoCommand(cmd *cobra.Command, args []string) {
 			migrateInfoPointersMode = migrateInfoPointersNoFollow
 		case "ignore":
 			migrateInfoPointersMode = migrateInfoPointersIgnore
+		case "by-extension":
+			migrateInfoPointersMode = migrateInfoPointersByExtension
 		default:
 			ExitWithError(errors.Errorf("fatal: unsupported --pointers option value"))
 		}
@@ -112,6 +120,7 @@ func migrateInfoCommand(cmd *cobra.Command, args []string) {
 
 	migrateInfoAbove = above
 	pointersInfoEntry := &MigrateInfoEntry{Qualifier: "LFS Objects", Separate: true}
+	pointerExts := make(map[string]*MigrateInfoEntry)
 	var fixups *gitattr.Tree
 
 	migrate(args, rewriter, l, &githistory.RewriteOptions{
@@ -145,7 +154,11 @@ func migrateInfoCommand(cmd *cobra.Command, args []string) {
 				if migrateInfoPointersMode == migrateInfoPointersIgnore {
 					return b, nil
 				}
-				entry = pointersInfoEntry
+				if migrateInfoPointersMode == migrateInfoPointersByExtension {
+					entry = findEntryByExtension(pointerExts, path)
+				} else {
+					entry = pointersInfoEntry
+				}
 				size = p.Size
 			} else {
 				entry = findEntryByExtension(exts, path)
@@ -177,18 +190,72 @@ func migrateInfoCommand(cmd *cobra.Command, args []string) {
 	})
 	l.Close()
 
+	entries := topEntries(exts)
+
+	var pointerEntries EntriesBySize
+	if migrateInfoPointersMode == migrateInfoPointersByExtension {
+		pointerEntries = topEntries(pointerExts)
+	} else if pointersInfoEntry.Total > 0 {
+		pointerEntries = EntriesBySize{pointersInfoEntry}
+	}
+
+	if migrateInfoJSON {
+		printMigrateInfoJSON(entries, pointerEntries)
+		return
+	}
+
+	if migrateInfoPointersMode == migrateInfoPointersByExtension {
+		for i, entry := range pointerEntries {
+			entry.Qualifier = fmt.Sprintf("%s (LFS Objects)", entry.Qualifier)
+			entry.Separate = i == 0
+		}
+	}
+
+	append(entries, pointerEntries...).Print(os.Stdout)
+}
+
+// topEntries returns the largest "--top" entries of "exts", largest first,
+// leaving out those with no files above the "--above" threshold.
+func topEntries(exts map[string]*MigrateInfoEntry) EntriesBySize {
 	entries := EntriesBySize(MapToEntries(exts))
 	entries = removeEmptyEntries(entries)
 	sort.Sort(sort.Reverse(entries))
 
-	migrateInfoTopN = tools.ClampInt(migrateInfoTopN, 0, len(entries))
+	return entries[:tools.ClampInt(migrateInfoTopN, 0, len(entries))]
+}
+
+// migrateInfoJSONEntry is an entry printed by printMigrateInfoJSON.
+type migrateInfoJSONEntry struct {
+	Qualifier  string `json:"qualifier"`
+	BytesAbove int64  `json:"bytes_above"`
+	TotalAbove int64  `json:"total_above"`
+	Total      int64  `json:"total"`
+}
 
-	entries = entries[:migrateInfoTopN]
-	if pointersInfoEntry.Total > 0 {
-		entries = append(entries, pointersInfoEntry)
+// printMigrateInfoJSON prints "entries", of the files stored in Git, and
+// "pointerEntries", of the Git LFS objects their pointers refer to, as JSON.
+func printMigrateInfoJSON(entries, pointerEntries EntriesBySize) {
+	toJSON := func(entries EntriesBySize) []*migrateInfoJSONEntry {
+		out := make([]*migrateInfoJSONEntry, 0, len(entries))
+		for _, e := range entries {
+			out = append(out, &migrateInfoJSONEntry{
+				Qualifier:  e.Qualifier,
+				BytesAbove: e.BytesAbove,
+				TotalAbove: e.TotalAbove,
+				Total:      e.Total,
+			})
+		}
+		return out
 	}
 
-	entries.Print(os.Stdout)
+	encoded, err := json.Marshal(struct {
+		Files      []*migrateInfoJSONEntry `json:"files"`
+		LFSObjects []*migrateInfoJSONEntry `json:"lfs_objects"`
+	}{toJSON(entries), toJSON(pointerEntries)})
+	if err != nil {
+		ExitWithError(err)
+	}
+	Print(string(encoded))
 }
 
 // MigrateInfoEntry represents a tuple of filetype to bytes and entry count
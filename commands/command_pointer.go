@@ -80,6 +80,14 @@ func pointerCommand(cmd *cobra.Command, args []string) {
 		}
 
 		ptr := lfs.NewPointer(hex.EncodeToString(oidHash.Sum(nil)), size, nil)
+		// Outside a repository, there is no configuration to read, and
+		// the default version is used.
+		if cfg.InRepo() {
+			if ptr.Version, err = lfs.PointerVersion(cfg.Git); err != nil {
+				Error(err.Error())
+				os.Exit(1)
+			}
+		}
 		fmt.Fprintf(os.Stderr, "Git LFS pointer for %s\n\n", pointerFile)
 		buf := &bytes.Buffer{}
 		lfs.EncodePointer(io.MultiWriter(os.Stdout, buf), ptr)
@@ -1,14 +1,22 @@
 package commands
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"regexp"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/fs"
 	"github.com/git-lfs/git-lfs/git"
@@ -17,6 +25,7 @@ import (
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/git-lfs/git-lfs/tools/humanize"
 	"github.com/git-lfs/git-lfs/tq"
+	isatty "github.com/mattn/go-isatty"
 	"github.com/rubyist/tracerx"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/semaphore"
@@ -29,6 +38,17 @@ var (
 	pruneRecentArg      bool
 	pruneForceArg       bool
 	pruneDoNotVerifyArg bool
+
+	pruneKeepUnpushedArg   bool
+	pruneVerifyUnpushedArg bool
+	pruneCacheSizeLimitArg string
+	pruneTmpArg            bool
+	pruneObjectsFromArg    string
+	pruneKeepSinceArg      string
+	pruneReportArg         bool
+	pruneJSONArg           bool
+
+	pruneOidRE = regexp.MustCompile(`\A[0-9a-f]{64}\z`)
 )
 
 func pruneCommand(cmd *cobra.Command, args []string) {
@@ -37,12 +57,61 @@ func pruneCommand(cmd *cobra.Command, args []string) {
 		Exit("Cannot specify both --verify-remote and --no-verify-remote")
 	}
 
+	if pruneJSONArg && !pruneReportArg {
+		Exit("Cannot use --json without --report")
+	}
+	if pruneReportArg && (pruneTmpArg || len(pruneObjectsFromArg) > 0) {
+		Exit("Cannot combine --report with --tmp or --objects-from")
+	}
+
+	if pruneTmpArg {
+		pruneTmp(pruneDryRunArg, pruneVerboseArg)
+		return
+	}
+
+	if len(pruneObjectsFromArg) > 0 {
+		pruneObjectsFrom(pruneObjectsFromArg, pruneDryRunArg, pruneVerboseArg, pruneForceArg)
+		return
+	}
+
 	fetchPruneConfig := lfs.NewFetchPruneConfig(cfg.Git)
 	verify := !pruneDoNotVerifyArg &&
 		(fetchPruneConfig.PruneVerifyRemoteAlways || pruneVerifyArg)
 	fetchPruneConfig.PruneRecent = pruneRecentArg || pruneForceArg
 	fetchPruneConfig.PruneForce = pruneForceArg
-	prune(fetchPruneConfig, verify, pruneDryRunArg, pruneVerboseArg)
+	fetchPruneConfig.PruneKeepUnpushed = pruneKeepUnpushedArg
+	fetchPruneConfig.PruneVerifyUnpushed = pruneVerifyUnpushedArg
+	if len(pruneCacheSizeLimitArg) > 0 {
+		limit, err := humanize.ParseBytes(pruneCacheSizeLimitArg)
+		if err != nil {
+			ExitWithError(errors.Wrap(err, "cannot parse --cache-size-limit=<size>"))
+		}
+		fetchPruneConfig.PruneCacheSizeLimit = limit
+	} else if v, ok := cfg.Git.Get("lfs.prune.cachesizelimit"); ok {
+		if _, err := humanize.ParseBytes(v); err != nil {
+			ExitWithError(errors.Wrapf(err, "cannot parse lfs.prune.cachesizelimit=%q", v))
+		}
+	}
+	if cmd.Flag("since").Changed && cmd.Flag("keep-since").Changed {
+		Exit("Cannot specify both --since and --keep-since")
+	}
+	if len(pruneKeepSinceArg) > 0 {
+		if fetchPruneConfig.PruneRecent {
+			Exit("Cannot combine --keep-since with --recent or --force")
+		}
+		since, err := parsePruneDate(pruneKeepSinceArg)
+		if err != nil {
+			ExitWithError(err)
+		}
+		fetchPruneConfig.PruneKeepSince = since
+	}
+	report := pruneReportNone
+	if pruneJSONArg {
+		report = pruneReportJSON
+	} else if pruneReportArg {
+		report = pruneReportTable
+	}
+	prune(fetchPruneConfig, verify, pruneDryRunArg || report != pruneReportNone, pruneVerboseArg, report)
 }
 
 type PruneProgressType int
@@ -60,11 +129,90 @@ type PruneProgress struct {
 }
 type PruneProgressChan chan PruneProgress
 
-func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose bool) {
+// pruneWindow names the recent window, as described in "git help prune", by
+// which an object was retained.
+type pruneWindow string
+
+const (
+	// pruneWindowNone is for objects retained for other reasons than
+	// being recent, such as being in the current checkout.
+	pruneWindowNone = pruneWindow("")
+	// pruneWindowIncoming is for objects retained by recent refs and
+	// commits found only through remote tracking refs.
+	pruneWindowIncoming = pruneWindow("incoming")
+	// pruneWindowOutgoing is for objects retained by recent refs and
+	// commits found through local refs.
+	pruneWindowOutgoing = pruneWindow("outgoing")
+)
+
+// pruneDateLayouts are the layouts of the dates accepted by --keep-since, in
+// local time unless they give a time zone.
+var pruneDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	time.RFC3339,
+}
+
+// parsePruneDate parses "s", as given to --keep-since, as a calendar date,
+// which means its start, or a date and time. Unlike Git, which makes what it
+// can of any date, it rejects anything else, rather than risk deleting objects
+// which were meant to be kept.
+func parsePruneDate(s string) (time.Time, error) {
+	for _, layout := range pruneDateLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("cannot parse --keep-since=%s; expected a date such as 2024-01-01, or a date and time such as 2024-01-01T12:00:00Z", s)
+}
+
+// pruneOffsetDays returns the number of days added to the fetch recent
+// settings for refs and commits in the window "w".
+func pruneOffsetDays(fetchconf lfs.FetchPruneConfig, w pruneWindow) int {
+	if w == pruneWindowIncoming {
+		return fetchconf.PruneIncomingOffsetDays
+	}
+	return fetchconf.PruneOutgoingOffsetDays
+}
+
+// pruneRetained is an object to be retained, and the window it was retained by.
+type pruneRetained struct {
+	Oid    string
+	Window pruneWindow
+}
+
+// pruneReportMode is how the objects which would be pruned are reported, as
+// --report asks.
+type pruneReportMode int
+
+const (
+	// pruneReportNone reports only their number and total size.
+	pruneReportNone = pruneReportMode(iota)
+	// pruneReportTable also reports them grouped by directory and
+	// extension.
+	pruneReportTable
+	// pruneReportJSON reports them and their groups as JSON, instead of
+	// any other output.
+	pruneReportJSON
+)
+
+func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose bool, report pruneReportMode) {
+	if cfg.Filesystem().Shared {
+		Exit("Cannot prune a shared object store (lfs.storage.shared), whose objects other repositories may need")
+	}
+
 	localObjects := make([]fs.Object, 0, 100)
 	retainedObjects := tools.NewStringSetWithCapacity(100)
+	retainedWindows := make(map[string]map[pruneWindow]bool)
 
-	logger := tasklog.NewLogger(OutputWriter,
+	var out io.Writer = OutputWriter
+	if report == pruneReportJSON {
+		out = ioutil.Discard
+	}
+	logger := tasklog.NewLogger(out,
 		tasklog.ForceProgress(cfg.ForceProgress()),
 	)
 	defer logger.Close()
@@ -75,10 +223,17 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 	// Add all the base funcs to the waitgroup before starting them, in case
 	// one completes really fast & hits 0 unexpectedly
 	// each main process can Add() to the wg itself if it subdivides the task
-	taskwait.Add(5) // 1..5: localObjects, current & recent refs, unpushed, worktree, stashes
+	taskwait.Add(4) // 1..4: localObjects, current & recent refs, worktree, stashes
+	if fetchPruneConfig.PruneKeepUnpushed {
+		taskwait.Add(1) // 5
+	}
 	if verifyRemote {
 		taskwait.Add(1) // 6
 	}
+	retainByRules := len(fetchPruneConfig.PruneRetentionRules) > 0 && !fetchPruneConfig.PruneForce
+	if retainByRules {
+		taskwait.Add(1) // 7
+	}
 
 	progressChan := make(PruneProgressChan, 100)
 
@@ -93,7 +248,7 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 	go pruneTaskGetLocalObjects(&localObjects, progressChan, &taskwait)
 
 	// Now find files to be retained from many sources
-	retainChan := make(chan string, 100)
+	retainChan := make(chan pruneRetained, 100)
 
 	gitscanner := lfs.NewGitScanner(cfg, nil)
 	gitscanner.Filter = filepathfilter.New(nil, cfg.FetchExcludePaths())
@@ -101,9 +256,14 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 	sem := semaphore.NewWeighted(int64(runtime.NumCPU() * 2))
 
 	go pruneTaskGetRetainedCurrentAndRecentRefs(gitscanner, fetchPruneConfig, retainChan, errorChan, &taskwait, sem)
-	go pruneTaskGetRetainedUnpushed(gitscanner, fetchPruneConfig, retainChan, errorChan, &taskwait, sem)
+	if fetchPruneConfig.PruneKeepUnpushed {
+		go pruneTaskGetRetainedUnpushed(gitscanner, fetchPruneConfig, retainChan, errorChan, &taskwait, sem)
+	}
 	go pruneTaskGetRetainedWorktree(gitscanner, fetchPruneConfig, retainChan, errorChan, &taskwait, sem)
 	go pruneTaskGetRetainedStashed(gitscanner, retainChan, errorChan, &taskwait, sem)
+	if retainByRules {
+		go pruneTaskGetRetainedByRules(gitscanner, fetchPruneConfig, retainChan, errorChan, &taskwait, sem)
+	}
 	if verifyRemote {
 		reachableObjects = tools.NewStringSetWithCapacity(100)
 		go pruneTaskGetReachableObjects(gitscanner, &reachableObjects, errorChan, &taskwait, sem)
@@ -112,7 +272,7 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 	// Now collect all the retained objects, on separate wait
 	var retainwait sync.WaitGroup
 	retainwait.Add(1)
-	go pruneTaskCollectRetained(&retainedObjects, retainChan, progressChan, &retainwait)
+	go pruneTaskCollectRetained(&retainedObjects, retainedWindows, retainChan, progressChan, &retainwait)
 
 	// Report progress
 	var progresswait sync.WaitGroup
@@ -128,7 +288,13 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 	errorwait.Wait() // make sure all errors have been processed
 	pruneCheckErrors(taskErrors)
 
-	prunableObjects := make([]string, 0, len(localObjects)/2)
+	var localOnly []fs.Object
+	if fetchPruneConfig.PruneVerifyUnpushed {
+		localOnly = pruneRetainLocalOnly(localObjects, retainedObjects, fetchPruneConfig.PruneRemoteName, progressChan)
+	}
+
+	candidates, limitReport := pruneCandidates(localObjects, retainedObjects, fetchPruneConfig.PruneCacheSizeLimit)
+	prunableObjects := make([]string, 0, len(candidates))
 
 	// Build list of prunables (also queue for verify at same time if applicable)
 	var verifyQueue *tq.TransferQueue
@@ -158,25 +324,23 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 		}()
 	}
 
-	for _, file := range localObjects {
-		if !retainedObjects.Contains(file.Oid) {
-			prunableObjects = append(prunableObjects, file.Oid)
-			totalSize += file.Size
-			if verbose {
-				// Save up verbose output for the end.
-				verboseOutput = append(verboseOutput,
-					fmt.Sprintf("%s (%s)",
-						file.Oid,
-						humanize.FormatBytes(uint64(file.Size))))
-			}
+	for _, file := range candidates {
+		prunableObjects = append(prunableObjects, file.Oid)
+		totalSize += file.Size
+		if verbose {
+			// Save up verbose output for the end.
+			verboseOutput = append(verboseOutput,
+				fmt.Sprintf("%s (%s)",
+					file.Oid,
+					humanize.FormatBytes(uint64(file.Size))))
+		}
 
-			if verifyRemote {
-				tracerx.Printf("VERIFYING: %v", file.Oid)
+		if verifyRemote {
+			tracerx.Printf("VERIFYING: %v", file.Oid)
 
-				verifyQueue.Add(downloadTransfer(&lfs.WrappedPointer{
-					Pointer: lfs.NewPointer(file.Oid, file.Size, nil),
-				}))
-			}
+			verifyQueue.Add(downloadTransfer(&lfs.WrappedPointer{
+				Pointer: lfs.NewPointer(file.Oid, file.Size, nil),
+			}))
 		}
 	}
 
@@ -191,6 +355,30 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 		progresswait.Wait()
 	}
 
+	if len(limitReport) > 0 {
+		info := tasklog.NewSimpleTask()
+		logger.Enqueue(info)
+		info.Logf("%s", limitReport)
+		info.Complete()
+	}
+
+	if len(localOnly) > 0 {
+		info := tasklog.NewSimpleTask()
+		logger.Enqueue(info)
+		info.Logf("prune: %d object(s) missing on remote retained, which unpushed commits alone would not have kept", len(localOnly))
+		for _, file := range localOnly {
+			info.Logf("\n * %s (%s)", file.Oid, humanize.FormatBytes(uint64(file.Size)))
+		}
+		info.Complete()
+	}
+
+	if dryRun {
+		pruneReportWindows(localObjects, retainedWindows, fetchPruneConfig, logger)
+	}
+	if report != pruneReportNone {
+		pruneReport(candidates, report, logger)
+	}
+
 	if len(prunableObjects) == 0 {
 		return
 	}
@@ -213,6 +401,250 @@ func prune(fetchPruneConfig lfs.FetchPruneConfig, verifyRemote, dryRun, verbose
 	}
 }
 
+// pruneCandidates returns the local objects which aren't retained, to be
+// deleted. If "limit" is non-zero, only as many of them are returned, least
+// recently accessed first, as must be deleted for the local objects to take up
+// no more than "limit" bytes, along with a report of how that went.
+func pruneCandidates(localObjects []fs.Object, retainedObjects tools.StringSet, limit uint64) ([]fs.Object, string) {
+	var candidates []fs.Object
+	var total uint64
+	for _, file := range localObjects {
+		total += uint64(file.Size)
+		if !retainedObjects.Contains(file.Oid) {
+			candidates = append(candidates, file)
+		}
+	}
+	if limit == 0 {
+		return candidates, ""
+	}
+
+	if total <= limit {
+		return nil, fmt.Sprintf("prune: local objects take %s, within the cache size limit of %s",
+			humanize.FormatBytes(total), humanize.FormatBytes(limit))
+	}
+
+	accessed := make(map[string]time.Time, len(candidates))
+	for _, file := range candidates {
+		if fi, err := os.Stat(cfg.Filesystem().ObjectPathname(file.Oid)); err == nil {
+			accessed[file.Oid] = tools.AccessTime(fi)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return accessed[candidates[i].Oid].Before(accessed[candidates[j].Oid])
+	})
+
+	for i, file := range candidates {
+		if total <= limit {
+			candidates = candidates[:i]
+			break
+		}
+		tracerx.Printf("EVICT: %v, last accessed %v", file.Oid, accessed[file.Oid])
+		total -= uint64(file.Size)
+	}
+
+	if total > limit {
+		return candidates, fmt.Sprintf("prune: local objects still take %s after pruning, over the cache size limit of %s, as the rest are retained",
+			humanize.FormatBytes(total), humanize.FormatBytes(limit))
+	}
+	return candidates, fmt.Sprintf("prune: evicting %d least recently accessed object(s) to stay within the cache size limit of %s",
+		len(candidates), humanize.FormatBytes(limit))
+}
+
+// pruneRetainLocalOnly asks the remote which of the local objects that would
+// otherwise be pruned it has, adds those it doesn't to retainedObjects, and
+// returns them. This protects objects which exist only locally, even if the
+// remote tracking refs wrongly suggest that the commits which refer to them
+// have been pushed.
+func pruneRetainLocalOnly(localObjects []fs.Object, retainedObjects tools.StringSet, remote string, progressChan PruneProgressChan) []fs.Object {
+	var candidates []*tq.Transfer
+	for _, file := range localObjects {
+		if !retainedObjects.Contains(file.Oid) {
+			tracerx.Printf("VERIFYING: %v", file.Oid)
+			candidates = append(candidates, &tq.Transfer{Oid: file.Oid, Size: file.Size})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	manifest := getTransferManifestOperationRemote("download", remote)
+	missing, err := tq.FindMissing(manifest, remote, nil, candidates)
+	if err != nil {
+		Exit("Abort: unable to verify that the remote has the objects to be pruned: %v", err)
+	}
+
+	missingOids := tools.NewStringSetWithCapacity(len(missing))
+	for _, m := range missing {
+		missingOids.Add(m.Oid)
+	}
+
+	var localOnly []fs.Object
+	for _, file := range localObjects {
+		if retainedObjects.Contains(file.Oid) {
+			continue
+		}
+
+		if missingOids.Contains(file.Oid) {
+			tracerx.Printf("RETAIN: %v missing on remote", file.Oid)
+			retainedObjects.Add(file.Oid)
+			localOnly = append(localOnly, file)
+			progressChan <- PruneProgress{PruneProgressTypeRetain, 1}
+		} else {
+			tracerx.Printf("VERIFIED: %v", file.Oid)
+			progressChan <- PruneProgress{PruneProgressTypeVerify, 1}
+		}
+	}
+	return localOnly
+}
+
+// pruneReportWindows lists the local objects which only the recent windows
+// retained, along with the windows which retained each.
+func pruneReportWindows(localObjects []fs.Object, retainedWindows map[string]map[pruneWindow]bool, fetchconf lfs.FetchPruneConfig, logger *tasklog.Logger) {
+	counts := make(map[pruneWindow]int)
+	var lines []string
+	for _, file := range localObjects {
+		windows := retainedWindows[file.Oid]
+		if len(windows) == 0 || windows[pruneWindowNone] {
+			continue
+		}
+
+		var names []string
+		for _, w := range []pruneWindow{pruneWindowOutgoing, pruneWindowIncoming} {
+			if windows[w] {
+				counts[w]++
+				names = append(names, string(w))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("\n * %s (%s) retained by %s", file.Oid,
+			humanize.FormatBytes(uint64(file.Size)), strings.Join(names, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	info := tasklog.NewSimpleTask()
+	logger.Enqueue(info)
+	if since := fetchconf.PruneKeepSince; !since.IsZero() {
+		info.Logf("prune: %d object(s) retained only by refs and commits since %s: %d outgoing, %d incoming",
+			len(lines), since.Format(time.RFC3339), counts[pruneWindowOutgoing], counts[pruneWindowIncoming])
+	} else {
+		info.Logf("prune: %d object(s) retained only by the recent windows: %d outgoing (offset %d day(s)), %d incoming (offset %d day(s))",
+			len(lines), counts[pruneWindowOutgoing], fetchconf.PruneOutgoingOffsetDays,
+			counts[pruneWindowIncoming], fetchconf.PruneIncomingOffsetDays)
+	}
+	for _, line := range lines {
+		info.Log(line)
+	}
+	info.Complete()
+}
+
+// pruneReportObject is an object which would be pruned, and a file which
+// referred to it, if one did.
+type pruneReportObject struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+	Name string `json:"name"`
+}
+
+// pruneUnreferencedGroup groups the objects which no file in any commit
+// refers to.
+const pruneUnreferencedGroup = "(unreferenced)"
+
+// pruneReport reports the objects "candidates" which would be pruned, grouped
+// by the directories and extensions of the files which referred to them, with
+// the space pruning them would reclaim, as a table or as JSON.
+func pruneReport(candidates []fs.Object, mode pruneReportMode, logger *tasklog.Logger) {
+	names := pruneReportNames(candidates)
+
+	dirs := make(objectGroups)
+	exts := make(objectGroups)
+
+	objects := make([]*pruneReportObject, 0, len(candidates))
+	var total int64
+	for _, file := range candidates {
+		name := names[file.Oid]
+		objects = append(objects, &pruneReportObject{Oid: file.Oid, Size: file.Size, Name: name})
+		total += file.Size
+
+		if len(name) == 0 {
+			dirs.add(pruneUnreferencedGroup, file.Size)
+			exts.add(pruneUnreferencedGroup, file.Size)
+			continue
+		}
+		addFile(dirs, exts, name, file.Size)
+	}
+
+	if mode == pruneReportJSON {
+		encoded, err := json.Marshal(struct {
+			Count       int                  `json:"count"`
+			Size        int64                `json:"size"`
+			Directories []*objectGroup       `json:"directories"`
+			Extensions  []*objectGroup       `json:"extensions"`
+			Objects     []*pruneReportObject `json:"objects"`
+		}{len(candidates), total, dirs.sorted(), exts.sorted(), objects})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+		return
+	}
+
+	info := tasklog.NewSimpleTask()
+	logger.Enqueue(info)
+	info.Logf("prune: %s reclaimable from %d object(s)", humanize.FormatBytes(uint64(total)), len(candidates))
+	for _, table := range []struct {
+		heading string
+		groups  objectGroups
+	}{
+		{"directory", dirs},
+		{"extension", exts},
+	} {
+		if len(table.groups) == 0 {
+			continue
+		}
+
+		info.Logf("\nprune: by %s:", table.heading)
+		for _, line := range formatObjectGroups(table.groups.sorted()) {
+			info.Logf("\n  %s", line)
+		}
+	}
+	info.Complete()
+}
+
+// pruneReportNames returns the name of a file which referred to each of the
+// "objects" in any commit, the first by name if several did.
+func pruneReportNames(objects []fs.Object) map[string]string {
+	wanted := tools.NewStringSetWithCapacity(len(objects))
+	for _, file := range objects {
+		wanted.Add(file.Oid)
+	}
+
+	names := make(map[string]string)
+	if len(wanted) == 0 {
+		return names
+	}
+
+	gitscanner := lfs.NewGitScanner(cfg, nil)
+	defer gitscanner.Close()
+
+	err := gitscanner.ScanAll(func(p *lfs.WrappedPointer, err error) {
+		if err != nil {
+			LoggedError(err, "Could not scan for Git LFS files: %s", err)
+			return
+		}
+		if !wanted.Contains(p.Oid) {
+			return
+		}
+		if name, ok := names[p.Oid]; !ok || p.Name < name {
+			names[p.Oid] = p.Name
+		}
+	})
+	if err != nil {
+		ExitWithError(err)
+	}
+	return names
+}
+
 func pruneCheckVerified(prunableObjects []string, reachableObjects, verifiedObjects tools.StringSet) {
 	// There's no issue if an object is not reachable and missing, only if reachable & missing
 	var problems bytes.Buffer
@@ -274,17 +706,198 @@ func pruneTaskDisplayProgress(progressChan PruneProgressChan, waitg *sync.WaitGr
 	}
 }
 
-func pruneTaskCollectRetained(outRetainedObjects *tools.StringSet, retainChan chan string,
-	progressChan PruneProgressChan, retainwait *sync.WaitGroup) {
+func pruneTaskCollectRetained(outRetainedObjects *tools.StringSet, outRetainedWindows map[string]map[pruneWindow]bool,
+	retainChan chan pruneRetained, progressChan PruneProgressChan, retainwait *sync.WaitGroup) {
 
 	defer retainwait.Done()
 
-	for oid := range retainChan {
-		if outRetainedObjects.Add(oid) {
+	for r := range retainChan {
+		if outRetainedObjects.Add(r.Oid) {
 			progressChan <- PruneProgress{PruneProgressTypeRetain, 1}
 		}
+
+		windows, ok := outRetainedWindows[r.Oid]
+		if !ok {
+			windows = make(map[pruneWindow]bool)
+			outRetainedWindows[r.Oid] = windows
+		}
+		windows[r.Window] = true
+	}
+
+}
+
+// pruneTmp removes the stale files in the temporary directory, such as those
+// left behind by interrupted clean filters, instead of pruning any objects.
+func pruneTmp(dryRun, verbose bool) {
+	stale, err := cfg.Filesystem().StaleTmpFiles()
+	if err != nil {
+		ExitWithError(errors.Wrap(err, "could not list temporary files"))
+	}
+
+	if dryRun {
+		Print("prune: %d stale temporary file(s) would be removed", len(stale))
+		if verbose {
+			for _, path := range stale {
+				Print(" * %s", path)
+			}
+		}
+		return
+	}
+
+	var problems bytes.Buffer
+	var removed int
+	for _, path := range stale {
+		if err := os.RemoveAll(path); err != nil {
+			problems.WriteString(fmt.Sprintf("Failed to remove file %v: %v\n", path, err))
+			continue
+		}
+		if verbose {
+			Print(" * %s", path)
+		}
+		removed++
+	}
+	Print("prune: %d stale temporary file(s) removed", removed)
+
+	if problems.Len() > 0 {
+		LoggedError(fmt.Errorf("failed to delete some files"), problems.String())
+		Exit("Prune failed, see errors above")
+	}
+}
+
+// pruneObjectsFrom deletes the objects whose OIDs are listed in the file at
+// "path", or standard input if it is "-", from the local store, whether or not
+// they would otherwise be retained. Unless "force" is set, it asks first, and
+// it warns about those objects which are still reachable from a ref.
+func pruneObjectsFrom(path string, dryRun, verbose, force bool) {
+	oids, err := pruneReadObjectList(path)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	var present []string
+	var size int64
+	for _, oid := range oids {
+		mediaFile, err := cfg.Filesystem().ObjectPath(oid)
+		if err != nil {
+			ExitWithError(errors.Wrapf(err, "unable to find media path for %v", oid))
+		}
+		if fi, err := os.Stat(mediaFile); err == nil {
+			present = append(present, oid)
+			size += fi.Size()
+		} else if verbose {
+			Print(" * %s is not in the local store", oid)
+		}
+	}
+
+	if len(present) > 0 {
+		pruneWarnReachable(present)
+	}
+
+	if dryRun {
+		Print("prune: %d of %d listed object(s) would be deleted (%s)",
+			len(present), len(oids), humanize.FormatBytes(uint64(size)))
+		if verbose {
+			for _, oid := range present {
+				Print(" * %s", oid)
+			}
+		}
+		return
+	}
+	if len(present) == 0 {
+		Print("prune: none of the %d listed object(s) are in the local store", len(oids))
+		return
+	}
+
+	if !force {
+		if path == "-" || !isatty.IsTerminal(os.Stdin.Fd()) ||
+			!migratePrompt(os.Stdin, os.Stderr, fmt.Sprintf("delete %d object(s) (%s) from the local store?", len(present), humanize.FormatBytes(uint64(size)))) {
+			Exit("prune: nothing was deleted; pass --force to delete the listed objects without confirmation")
+		}
+	}
+
+	logger := tasklog.NewLogger(OutputWriter,
+		tasklog.ForceProgress(cfg.ForceProgress()),
+	)
+	pruneDeleteFiles(present, logger)
+	logger.Close()
+
+	if verbose {
+		for _, oid := range present {
+			Print(" * %s", oid)
+		}
+	}
+	Print("prune: %d of %d listed object(s) deleted (%s)",
+		len(present), len(oids), humanize.FormatBytes(uint64(size)))
+}
+
+// pruneReadObjectList reads the OIDs listed one per line in the file at
+// "path", or standard input if it is "-". Blank lines, and those starting with
+// "#", are skipped, and each OID is listed once.
+func pruneReadObjectList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not open list of objects")
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var oids []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		oid := strings.TrimSpace(scanner.Text())
+		if len(oid) == 0 || strings.HasPrefix(oid, "#") {
+			continue
+		}
+		if !pruneOidRE.MatchString(oid) {
+			return nil, errors.Errorf("invalid OID %q on line %d of %s", oid, n, path)
+		}
+		if !seen[oid] {
+			seen[oid] = true
+			oids = append(oids, oid)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read list of objects")
+	}
+	return oids, nil
+}
+
+// pruneWarnReachable warns about each of "oids" which is still referenced by a
+// commit reachable from a ref, since checking out that commit would fail once
+// the object is deleted, unless it can be downloaded again.
+func pruneWarnReachable(oids []string) {
+	wanted := tools.NewStringSet()
+	for _, oid := range oids {
+		wanted.Add(oid)
+	}
+
+	reachable := make(map[string]string)
+	var mu sync.Mutex
+	gitscanner := lfs.NewGitScanner(cfg, nil)
+	err := gitscanner.ScanAll(func(p *lfs.WrappedPointer, err error) {
+		if err != nil || !wanted.Contains(p.Oid) {
+			return
+		}
+		mu.Lock()
+		if _, ok := reachable[p.Oid]; !ok {
+			reachable[p.Oid] = p.Name
+		}
+		mu.Unlock()
+	})
+	gitscanner.Close()
+	if err != nil {
+		ExitWithError(errors.Wrap(err, "could not scan for reachable objects"))
 	}
 
+	for _, oid := range oids {
+		if name, ok := reachable[oid]; ok {
+			Error("prune: warning: %s (%s) is still reachable from a ref; checking it out will fail unless it can be downloaded", oid, name)
+		}
+	}
 }
 
 func pruneTaskCollectErrors(outtaskErrors *[]error, errorChan chan error, errorwait *sync.WaitGroup) {
@@ -333,7 +946,7 @@ func pruneTaskGetLocalObjects(outLocalObjects *[]fs.Object, progChan PruneProgre
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetRetainedAtRef(gitscanner *lfs.GitScanner, ref string, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+func pruneTaskGetRetainedAtRef(gitscanner *lfs.GitScanner, ref string, window pruneWindow, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
 	sem.Acquire(context.Background(), 1)
 	defer sem.Release(1)
 	defer waitg.Done()
@@ -345,7 +958,7 @@ func pruneTaskGetRetainedAtRef(gitscanner *lfs.GitScanner, ref string, retainCha
 			return
 		}
 
-		retainChan <- p.Oid
+		retainChan <- pruneRetained{p.Oid, window}
 		tracerx.Printf("RETAIN: %v via ref %v", p.Oid, ref)
 	})
 
@@ -355,7 +968,7 @@ func pruneTaskGetRetainedAtRef(gitscanner *lfs.GitScanner, ref string, retainCha
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetPreviousVersionsOfRef(gitscanner *lfs.GitScanner, ref string, since time.Time, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+func pruneTaskGetPreviousVersionsOfRef(gitscanner *lfs.GitScanner, ref string, since time.Time, window pruneWindow, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
 	sem.Acquire(context.Background(), 1)
 	defer sem.Release(1)
 	defer waitg.Done()
@@ -367,7 +980,7 @@ func pruneTaskGetPreviousVersionsOfRef(gitscanner *lfs.GitScanner, ref string, s
 			return
 		}
 
-		retainChan <- p.Oid
+		retainChan <- pruneRetained{p.Oid, window}
 		tracerx.Printf("RETAIN: %v via ref %v >= %v", p.Oid, ref, since)
 	})
 
@@ -378,70 +991,186 @@ func pruneTaskGetPreviousVersionsOfRef(gitscanner *lfs.GitScanner, ref string, s
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetRetainedCurrentAndRecentRefs(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+func pruneTaskGetRetainedCurrentAndRecentRefs(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
 	defer waitg.Done()
 
 	// We actually increment the waitg in this func since we kick off sub-goroutines
-	// Make a list of what unique commits to keep, & search backward from
-	commits := tools.NewStringSet()
+	// Make a list of what unique commits to keep, & search backward from,
+	// along with the window each was found through
+	commits := make(map[string]pruneWindow)
 	// Do current first
 	ref, err := git.CurrentRef()
 	if err != nil {
 		errorChan <- err
 		return
 	}
-	commits.Add(ref.Sha)
+	commits[ref.Sha] = pruneWindowOutgoing
 	if !fetchconf.PruneForce {
 		waitg.Add(1)
-		go pruneTaskGetRetainedAtRef(gitscanner, ref.Sha, retainChan, errorChan, waitg, sem)
+		go pruneTaskGetRetainedAtRef(gitscanner, ref.Sha, pruneWindowNone, retainChan, errorChan, waitg, sem)
 	}
 
-	// Now recent
-	if !fetchconf.PruneRecent && fetchconf.FetchRecentRefsDays > 0 {
-		pruneRefDays := fetchconf.FetchRecentRefsDays + fetchconf.PruneOffsetDays
-		tracerx.Printf("PRUNE: Retaining non-HEAD refs within %d (%d+%d) days", pruneRefDays, fetchconf.FetchRecentRefsDays, fetchconf.PruneOffsetDays)
-		refsSince := time.Now().AddDate(0, 0, -pruneRefDays)
-		// Keep all recent refs including any recent remote branches
-		refs, err := git.RecentBranches(refsSince, fetchconf.FetchRecentRefsIncludeRemotes, "")
+	// Now recent, local refs with the outgoing window, then remote refs
+	// with the incoming window, skipping those at the same commits as any
+	// local ref, however old
+	keepSince := fetchconf.PruneKeepSince
+	if !fetchconf.PruneRecent && (fetchconf.FetchRecentRefsDays > 0 || !keepSince.IsZero()) {
+		localRefs, err := git.RecentBranches(time.Time{}, false, "")
 		if err != nil {
 			Panic(err, "Could not scan for recent refs")
 		}
-		for _, ref := range refs {
-			if commits.Add(ref.Sha) {
-				// A new commit
-				waitg.Add(1)
-				go pruneTaskGetRetainedAtRef(gitscanner, ref.Sha, retainChan, errorChan, waitg, sem)
+		localCommits := tools.NewStringSet()
+		for _, ref := range localRefs {
+			localCommits.Add(ref.Sha)
+		}
+
+		for _, window := range []pruneWindow{pruneWindowOutgoing, pruneWindowIncoming} {
+			remote := window == pruneWindowIncoming
+			if remote && !fetchconf.FetchRecentRefsIncludeRemotes {
+				continue
+			}
+
+			refsSince := keepSince
+			if refsSince.IsZero() {
+				offsetDays := pruneOffsetDays(fetchconf, window)
+				pruneRefDays := fetchconf.FetchRecentRefsDays + offsetDays
+				tracerx.Printf("PRUNE: Retaining non-HEAD %s refs within %d (%d+%d) days", window, pruneRefDays, fetchconf.FetchRecentRefsDays, offsetDays)
+				refsSince = time.Now().AddDate(0, 0, -pruneRefDays)
+			} else {
+				tracerx.Printf("PRUNE: Retaining non-HEAD %s refs with commits since %v", window, refsSince)
+			}
+			refs, err := git.RecentBranches(refsSince, remote, "")
+			if err != nil {
+				Panic(err, "Could not scan for recent refs")
+			}
+			for _, ref := range refs {
+				if remote && (ref.Type != git.RefTypeRemoteBranch || localCommits.Contains(ref.Sha)) {
+					continue
+				}
+				if _, ok := commits[ref.Sha]; !ok {
+					// A new commit
+					commits[ref.Sha] = window
+					waitg.Add(1)
+					go pruneTaskGetRetainedAtRef(gitscanner, ref.Sha, window, retainChan, errorChan, waitg, sem)
+				}
 			}
 		}
 	}
 
 	// For every unique commit we've fetched, check recent commits too
 	// Only if we're fetching recent commits, otherwise only keep at refs
-	if !fetchconf.PruneRecent && fetchconf.FetchRecentCommitsDays > 0 {
-		pruneCommitDays := fetchconf.FetchRecentCommitsDays + fetchconf.PruneOffsetDays
-		for commit := range commits.Iter() {
-			// We measure from the last commit at the ref
-			summ, err := git.GetCommitSummary(commit)
-			if err != nil {
-				errorChan <- fmt.Errorf("couldn't scan commits at %v: %v", commit, err)
-				continue
+	if !fetchconf.PruneRecent && (fetchconf.FetchRecentCommitsDays > 0 || !keepSince.IsZero()) {
+		for commit, window := range commits {
+			commitsSince := keepSince
+			if commitsSince.IsZero() {
+				pruneCommitDays := fetchconf.FetchRecentCommitsDays + pruneOffsetDays(fetchconf, window)
+				// We measure from the last commit at the ref
+				summ, err := git.GetCommitSummary(commit)
+				if err != nil {
+					errorChan <- fmt.Errorf("couldn't scan commits at %v: %v", commit, err)
+					continue
+				}
+				commitsSince = summ.CommitDate.AddDate(0, 0, -pruneCommitDays)
 			}
-			commitsSince := summ.CommitDate.AddDate(0, 0, -pruneCommitDays)
 			waitg.Add(1)
-			go pruneTaskGetPreviousVersionsOfRef(gitscanner, commit, commitsSince, retainChan, errorChan, waitg, sem)
+			go pruneTaskGetPreviousVersionsOfRef(gitscanner, commit, commitsSince, window, retainChan, errorChan, waitg, sem)
 		}
 	}
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetRetainedUnpushed(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+func pruneTaskGetRetainedByRules(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+	defer waitg.Done()
+
+	ref, err := git.CurrentRef()
+	if err != nil {
+		errorChan <- err
+		return
+	}
+
+	var versionRules []*lfs.PruneRetentionRule
+	for _, rule := range fetchconf.PruneRetentionRules {
+		if rule.Versions > 0 {
+			versionRules = append(versionRules, rule)
+		}
+		if rule.Days > 0 {
+			waitg.Add(1)
+			go pruneTaskGetRetainedByDaysRule(gitscanner, ref.Sha, rule, retainChan, errorChan, waitg, sem)
+		}
+	}
+	if len(versionRules) == 0 {
+		return
+	}
+
+	sem.Acquire(context.Background(), 1)
+	defer sem.Release(1)
+
+	// The versions of each file are found newest first, so the first ones
+	// of each are kept, up to the number the rules matching it allow.
+	versions := make(map[string]tools.StringSet)
+	err = gitscanner.ScanRefVersions(ref.Sha, func(p *lfs.WrappedPointer, err error) {
+		if err != nil {
+			errorChan <- err
+			return
+		}
+
+		seen, ok := versions[p.Name]
+		if !ok {
+			seen = tools.NewStringSet()
+			versions[p.Name] = seen
+		}
+		if seen.Contains(p.Oid) {
+			return
+		}
+		for _, rule := range versionRules {
+			if len(seen) < rule.Versions && rule.Matches(p.Name) {
+				seen.Add(p.Oid)
+				retainChan <- pruneRetained{p.Oid, pruneWindowNone}
+				tracerx.Printf("RETAIN: %v of %v via rule %q keeping %d version(s)", p.Oid, p.Name, rule.Pattern, rule.Versions)
+				return
+			}
+		}
+	})
+	if err != nil {
+		errorChan <- err
+	}
+}
+
+// Background task, must call waitg.Done() once at end
+func pruneTaskGetRetainedByDaysRule(gitscanner *lfs.GitScanner, ref string, rule *lfs.PruneRetentionRule, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+	sem.Acquire(context.Background(), 1)
+	defer sem.Release(1)
+	defer waitg.Done()
+
+	// The versions in use at ref are retained already, so only the
+	// previous versions in use since then are needed.
+	since := time.Now().AddDate(0, 0, -rule.Days)
+	err := gitscanner.ScanPreviousVersions(ref, since, func(p *lfs.WrappedPointer, err error) {
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		if !rule.Matches(p.Name) {
+			return
+		}
+
+		retainChan <- pruneRetained{p.Oid, pruneWindowNone}
+		tracerx.Printf("RETAIN: %v of %v via rule %q keeping %d day(s)", p.Oid, p.Name, rule.Pattern, rule.Days)
+	})
+	if err != nil {
+		errorChan <- err
+	}
+}
+
+// Background task, must call waitg.Done() once at end
+func pruneTaskGetRetainedUnpushed(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
 	defer waitg.Done()
 
 	err := gitscanner.ScanUnpushed(fetchconf.PruneRemoteName, func(p *lfs.WrappedPointer, err error) {
 		if err != nil {
 			errorChan <- err
 		} else {
-			retainChan <- p.Pointer.Oid
+			retainChan <- pruneRetained{p.Pointer.Oid, pruneWindowNone}
 			tracerx.Printf("RETAIN: %v unpushed", p.Pointer.Oid)
 		}
 	})
@@ -453,7 +1182,7 @@ func pruneTaskGetRetainedUnpushed(gitscanner *lfs.GitScanner, fetchconf lfs.Fetc
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetRetainedWorktree(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+func pruneTaskGetRetainedWorktree(gitscanner *lfs.GitScanner, fetchconf lfs.FetchPruneConfig, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
 	defer waitg.Done()
 
 	if fetchconf.PruneForce {
@@ -482,20 +1211,20 @@ func pruneTaskGetRetainedWorktree(gitscanner *lfs.GitScanner, fetchconf lfs.Fetc
 			// Worktree is on a different commit
 			waitg.Add(1)
 			// Don't need to 'cd' to worktree since we share same repo
-			go pruneTaskGetRetainedAtRef(gitscanner, ref.Sha, retainChan, errorChan, waitg, sem)
+			go pruneTaskGetRetainedAtRef(gitscanner, ref.Sha, pruneWindowNone, retainChan, errorChan, waitg, sem)
 		}
 	}
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetRetainedStashed(gitscanner *lfs.GitScanner, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
+func pruneTaskGetRetainedStashed(gitscanner *lfs.GitScanner, retainChan chan pruneRetained, errorChan chan error, waitg *sync.WaitGroup, sem *semaphore.Weighted) {
 	defer waitg.Done()
 
 	err := gitscanner.ScanStashed(func(p *lfs.WrappedPointer, err error) {
 		if err != nil {
 			errorChan <- err
 		} else {
-			retainChan <- p.Pointer.Oid
+			retainChan <- pruneRetained{p.Pointer.Oid, pruneWindowNone}
 			tracerx.Printf("RETAIN: %v stashed", p.Pointer.Oid)
 		}
 	})
@@ -534,5 +1263,14 @@ func init() {
 		cmd.Flags().BoolVarP(&pruneForceArg, "force", "f", false, "Prune everything that has been pushed")
 		cmd.Flags().BoolVarP(&pruneVerifyArg, "verify-remote", "c", false, "Verify that remote has LFS files before deleting")
 		cmd.Flags().BoolVar(&pruneDoNotVerifyArg, "no-verify-remote", false, "Override lfs.pruneverifyremotealways and don't verify")
+		cmd.Flags().BoolVar(&pruneKeepUnpushedArg, "keep-unpushed", true, "Keep objects referenced by commits not pushed to the remote")
+		cmd.Flags().BoolVar(&pruneVerifyUnpushedArg, "verify-unpushed", false, "Keep objects the remote doesn't have, whether or not they look pushed")
+		cmd.Flags().BoolVar(&pruneTmpArg, "tmp", false, "Only remove stale temporary files")
+		cmd.Flags().StringVar(&pruneObjectsFromArg, "objects-from", "", "Only delete the objects listed in the given file, or - for standard input")
+		cmd.Flags().StringVar(&pruneKeepSinceArg, "keep-since", "", "Keep objects referenced by refs and commits since the given date, instead of the recent ones")
+		cmd.Flags().StringVar(&pruneKeepSinceArg, "since", "", "Same as --keep-since")
+		cmd.Flags().BoolVar(&pruneReportArg, "report", false, "Don't delete anything, but report what would be deleted by directory and extension")
+		cmd.Flags().BoolVar(&pruneJSONArg, "json", false, "Give the report of --report as JSON")
+		cmd.Flags().StringVar(&pruneCacheSizeLimitArg, "cache-size-limit", "", "Prune least recently accessed objects only until the local objects fit in the given size")
 	})
 }
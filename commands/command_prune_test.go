@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePruneDate(t *testing.T) {
+	for s, expected := range map[string]time.Time{
+		"2024-01-01":                time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+		" 2024-01-01 ":              time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+		"2024-01-01 12:30":          time.Date(2024, 1, 1, 12, 30, 0, 0, time.Local),
+		"2024-01-01T12:30:15":       time.Date(2024, 1, 1, 12, 30, 15, 0, time.Local),
+		"2024-01-01 12:30:15 +0100": time.Date(2024, 1, 1, 11, 30, 15, 0, time.UTC),
+		"2024-01-01T12:30:15Z":      time.Date(2024, 1, 1, 12, 30, 15, 0, time.UTC),
+	} {
+		actual, err := parsePruneDate(s)
+		if assert.Nil(t, err, s) {
+			assert.True(t, expected.Equal(actual), "%s: expected %s, got %s", s, expected, actual)
+		}
+	}
+
+	for _, s := range []string{"", "yesterday", "2 weeks ago", "2024-13-01", "01/02/2024"} {
+		_, err := parsePruneDate(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"fmt"
-	"os"
 	"sync"
 	"time"
 
@@ -15,9 +14,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	pullAllPathsArg bool
+)
+
 func pullCommand(cmd *cobra.Command, args []string) {
 	requireGitVersion()
 	setupRepository()
+	setupJSONProgress()
 
 	if len(args) > 0 {
 		// Remote is first arg
@@ -26,11 +30,41 @@ func pullCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	fetchSizeLimit = newSizeLimit(fetchMaxSizeArg)
+	if !pullAllPathsArg {
+		fetchSparseCheckout = sparseCheckout()
+	}
+	if fetchJSONArg && !fetchDryRunArg && !fetchVerboseArg {
+		Exit("--json requires --dry-run or --verbose")
+	}
+
 	includeArg, excludeArg := getIncludeExcludeArgs(cmd)
 	filter := buildFilepathFilter(cfg, includeArg, excludeArg, true)
+	if fetchDryRunArg {
+		pullDryRun(filter)
+		return
+	}
 	pull(filter)
 }
 
+// pullDryRun lists the objects which pull would download for the current ref,
+// without downloading them or checking out any files.
+func pullDryRun(filter *filepathfilter.Filter) {
+	ref, err := git.CurrentRef()
+	if err != nil {
+		Panic(err, "Could not pull")
+	}
+
+	pointers, err := pointersToFetchForRef(ref.Sha, filter)
+	if err != nil {
+		Panic(err, "Could not scan for Git LFS files")
+	}
+	fetchDryRunObjects.Add(pointers)
+
+	fetchSizeLimit.Report()
+	fetchDryRunObjects.Report(cfg.Remote())
+}
+
 func pull(filter *filepathfilter.Filter) {
 	ref, err := git.CurrentRef()
 	if err != nil {
@@ -38,7 +72,7 @@ func pull(filter *filepathfilter.Filter) {
 	}
 
 	pointers := newPointerMap()
-	logger := tasklog.NewLogger(os.Stdout,
+	logger := tasklog.NewLogger(fetchProgressWriter(),
 		tasklog.ForceProgress(cfg.ForceProgress()),
 	)
 	meter := tq.NewMeter(cfg)
@@ -46,6 +80,7 @@ func pull(filter *filepathfilter.Filter) {
 	logger.Enqueue(meter)
 	remote := cfg.Remote()
 	singleCheckout := newSingleCheckout(cfg.Git, remote)
+	stream := cfg.SmudgeStream()
 	q := newDownloadQueue(singleCheckout.Manifest(), remote, tq.WithProgress(meter))
 	gitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
 		if err != nil {
@@ -53,6 +88,12 @@ func pull(filter *filepathfilter.Filter) {
 			return
 		}
 
+		// Files outside the sparse checkout aren't in the working
+		// tree, so neither fetch nor check them out.
+		if !inSparseCheckout(p) {
+			return
+		}
+
 		if pointers.Seen(p) {
 			return
 		}
@@ -64,6 +105,19 @@ func pull(filter *filepathfilter.Filter) {
 			return
 		}
 
+		// Leave the pointers of objects over --max-size in place.
+		if !fetchSizeLimit.Allows(p) {
+			return
+		}
+
+		if path, ok := singleCheckout.StreamPath(p); ok {
+			meter.Add(p.Size)
+			tracerx.Printf("fetch %v [%v] to the working tree", p.Name, p.Oid)
+			pointers.Add(p)
+			q.Add(p.Name, path, p.Oid, p.Size, false, nil)
+			return
+		}
+
 		meter.Add(p.Size)
 		tracerx.Printf("fetch %v [%v]", p.Name, p.Oid)
 		pointers.Add(p)
@@ -72,13 +126,20 @@ func pull(filter *filepathfilter.Filter) {
 
 	gitscanner.Filter = filter
 
+	timings := watchFetchTimings(q)
 	dlwatch := q.Watch()
 	var wg sync.WaitGroup
 	wg.Add(1)
 
 	go func() {
 		for t := range dlwatch {
-			for _, p := range pointers.All(t.Oid) {
+			for i, p := range pointers.All(t.Oid) {
+				// Only the first file of an object is
+				// downloaded, and so streamed.
+				if i == 0 && stream && t.Path != cfg.Filesystem().ObjectPathname(t.Oid) {
+					singleCheckout.RunStreamed(p, t.Path)
+					continue
+				}
 				singleCheckout.Run(p)
 			}
 		}
@@ -96,6 +157,7 @@ func pull(filter *filepathfilter.Filter) {
 	q.Wait()
 	wg.Wait()
 	tracerx.PerformanceSince("process queue", processQueue)
+	timings.Report()
 
 	singleCheckout.Close()
 
@@ -111,6 +173,8 @@ func pull(filter *filepathfilter.Filter) {
 		Exit("error: failed to fetch some objects from '%s'", e.Url)
 	}
 
+	fetchSizeLimit.Report()
+
 	if singleCheckout.Skip() {
 		fmt.Println("Skipping object checkout, Git LFS is not installed.")
 	}
@@ -154,5 +218,11 @@ func init() {
 	RegisterCommand("pull", pullCommand, func(cmd *cobra.Command) {
 		cmd.Flags().StringVarP(&includeArg, "include", "I", "", "Include a list of paths")
 		cmd.Flags().StringVarP(&excludeArg, "exclude", "X", "", "Exclude a list of paths")
+		cmd.Flags().StringVarP(&fetchMaxSizeArg, "max-size", "", "", "Skip objects larger than the given size")
+		cmd.Flags().BoolVarP(&fetchDryRunArg, "dry-run", "d", false, "List the objects which would be fetched without fetching them")
+		cmd.Flags().BoolVarP(&fetchJSONArg, "json", "", false, "Give the output of --dry-run or --verbose in JSON")
+		cmd.Flags().BoolVarP(&fetchVerboseArg, "verbose", "v", false, "Print the time taken to download each object")
+		cmd.Flags().BoolVarP(&pullAllPathsArg, "all-paths", "", false, "Pull objects for paths outside the sparse checkout too")
+		cmd.Flags().BoolVarP(&jsonProgressArg, "json-progress", "", false, "Print progress as a stream of JSON events to standard error")
 	})
 }
@@ -15,6 +15,8 @@ var (
 	pushDryRun    = false
 	pushObjectIDs = false
 	pushAll       = false
+	pushContinue  = false
+	pushVerify    = false
 	useStdin      = false
 
 	// shares some global vars and functions with command_pre_push.go
@@ -36,6 +38,7 @@ func pushCommand(cmd *cobra.Command, args []string) {
 	}
 
 	requireGitVersion()
+	setupJSONProgress()
 
 	// Remote is first arg
 	if err := cfg.SetValidPushRemote(args[0]); err != nil {
@@ -43,6 +46,8 @@ func pushCommand(cmd *cobra.Command, args []string) {
 	}
 
 	ctx := newUploadContext(pushDryRun)
+	ctx.continueOnError = pushContinue
+	ctx.verifyObjects = pushVerify
 	if pushObjectIDs {
 		if len(args) < 2 {
 			Print("Usage: git lfs push --object-id <remote> <lfs-object-id> [lfs-object-id] ...")
@@ -77,10 +82,10 @@ func uploadsBetweenRefAndRemote(ctx *uploadContext, refnames []string) {
 func uploadsWithObjectIDs(ctx *uploadContext, oids []string) {
 	pointers := make([]*lfs.WrappedPointer, len(oids))
 	for i, oid := range oids {
-		mp, err := ctx.gitfilter.ObjectPath(oid)
-		if err != nil {
+		if _, err := ctx.gitfilter.ObjectPath(oid); err != nil {
 			ExitWithError(errors.Wrap(err, "Unable to find local media path:"))
 		}
+		mp := cfg.Filesystem().ObjectReadPathname(oid, -1)
 
 		stat, err := os.Stat(mp)
 		if err != nil {
@@ -99,6 +104,7 @@ func uploadsWithObjectIDs(ctx *uploadContext, oids []string) {
 	q := ctx.NewQueue(tq.RemoteRef(currentRemoteRef()))
 	ctx.UploadPointers(q, pointers...)
 	ctx.CollectErrors(q)
+	ctx.VerifyUploads()
 	ctx.ReportErrors()
 }
 
@@ -142,5 +148,8 @@ func init() {
 		cmd.Flags().BoolVarP(&pushDryRun, "dry-run", "d", false, "Do everything except actually send the updates")
 		cmd.Flags().BoolVarP(&pushObjectIDs, "object-id", "o", false, "Push LFS object ID(s)")
 		cmd.Flags().BoolVarP(&pushAll, "all", "a", false, "Push all objects for the current ref to the remote.")
+		cmd.Flags().BoolVarP(&pushContinue, "continue", "", false, "Continue past objects which fail to upload, and report them at the end.")
+		cmd.Flags().BoolVarP(&pushVerify, "verify-objects", "", false, "Ask the server whether it has each uploaded object after the push.")
+		cmd.Flags().BoolVarP(&jsonProgressArg, "json-progress", "", false, "Print progress as a stream of JSON events to standard error.")
 	})
 }
@@ -1,17 +1,21 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/git-lfs/git-lfs/tools/humanize"
 	"github.com/git-lfs/git-lfs/tq"
+	"github.com/rubyist/tracerx"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +23,10 @@ var (
 	// smudgeSkip is a command-line flag belonging to the "git-lfs smudge"
 	// command specifying whether to skip the smudge process.
 	smudgeSkip = false
+	// smudgeInfo is a command-line flag belonging to the "git-lfs smudge"
+	// command specifying whether to print the pointer's OID and size
+	// instead of its contents.
+	smudgeInfo = false
 )
 
 // delayedSmudge performs a 'delayed' smudge, adding the LFS pointer to the
@@ -56,8 +64,11 @@ func delayedSmudge(gf *lfs.GitFilter, s *git.FilterProcessScanner, to io.Writer,
 	if err != nil {
 		return 0, false, nil, err
 	}
+	if alternate, ok := cfg.Filesystem().ObjectAlternatePathname(ptr.Oid, ptr.Size); ok && !tools.FileExists(path) {
+		path = alternate
+	}
 
-	if !skip && filter.Allows(filename) {
+	if !skip && smudgeAllows(filter, filename) {
 		if _, statErr := os.Stat(path); statErr != nil {
 			q.Add(filename, path, ptr.Oid, ptr.Size, false, err)
 			return 0, true, ptr, nil
@@ -70,7 +81,13 @@ func delayedSmudge(gf *lfs.GitFilter, s *git.FilterProcessScanner, to io.Writer,
 			return 0, false, nil, err
 		}
 
+		emitSmudgeEvent(events.SmudgeStarted, filename, ptr, nil)
 		n, err := gf.Smudge(to, ptr, filename, false, nil, nil)
+		if err != nil {
+			emitSmudgeEvent(events.SmudgeFailed, filename, ptr, err)
+		} else {
+			emitSmudgeEvent(events.SmudgeFinished, filename, ptr, nil)
+		}
 		return n, false, ptr, err
 	}
 
@@ -121,41 +138,112 @@ func smudge(gf *lfs.GitFilter, to io.Writer, from io.Reader, filename string, sk
 
 	download := !skip
 	if download {
-		download = filter.Allows(filename)
+		download = smudgeAllows(filter, filename)
 	}
 
+	emitSmudgeEvent(events.SmudgeStarted, filename, ptr, nil)
 	n, err := gf.Smudge(to, ptr, filename, download, getTransferManifestOperationRemote("download", cfg.Remote()), cb)
 	if file != nil {
 		file.Close()
 	}
 
 	if err != nil {
-		ptr.Encode(to)
 		// Download declined error is ok to skip if we weren't requesting download
-		if !(errors.IsDownloadDeclinedError(err) && !download) {
+		if errors.IsDownloadDeclinedError(err) && !download {
+			ptr.Encode(to)
+		} else {
+			emitSmudgeEvent(events.SmudgeFailed, filename, ptr, err)
+
 			var oid string = ptr.Oid
 			if len(oid) >= 7 {
 				oid = oid[:7]
 			}
 
 			LoggedError(err, "Error downloading object: %s (%s): %s", filename, oid, err)
-			if !cfg.SkipDownloadErrors() {
+			switch smudgeOnMissing() {
+			case smudgeOnMissingEmpty:
+			case smudgeOnMissingPointer:
+				ptr.Encode(to)
+			default:
+				ptr.Encode(to)
 				os.Exit(2)
 			}
 		}
+	} else {
+		emitSmudgeEvent(events.SmudgeFinished, filename, ptr, nil)
 	}
 
 	return n, nil
 }
 
+const (
+	// smudgeOnMissingError fails the smudge filter when an object can't
+	// be downloaded.
+	smudgeOnMissingError = "error"
+	// smudgeOnMissingPointer writes the pointer of an object which can't
+	// be downloaded.
+	smudgeOnMissingPointer = "pointer"
+	// smudgeOnMissingEmpty writes nothing for an object which can't be
+	// downloaded.
+	smudgeOnMissingEmpty = "empty"
+)
+
+// smudgeAllows returns whether the smudge filter downloads the object of the
+// file "filename", which it does unless "filter" or, with lfs.fetchfromsparse,
+// the sparse checkout leave it out.
+func smudgeAllows(filter *filepathfilter.Filter, filename string) bool {
+	if !fetchSparseCheckout.Includes(filename) {
+		tracerx.Printf("smudge: skipping %v, outside of the sparse checkout", filename)
+		return false
+	}
+	return filter.Allows(filename)
+}
+
+// smudgeOnMissing returns what the smudge filter does when an object can't be
+// downloaded, as set by lfs.smudge.onmissing. Unless it is set, the pointer is
+// written if download errors are skipped, and the filter fails otherwise.
+func smudgeOnMissing() string {
+	value, ok := cfg.Git.Get("lfs.smudge.onmissing")
+	if !ok {
+		if cfg.SkipDownloadErrors() {
+			return smudgeOnMissingPointer
+		}
+		return smudgeOnMissingError
+	}
+
+	switch v := strings.ToLower(value); v {
+	case smudgeOnMissingError, smudgeOnMissingPointer, smudgeOnMissingEmpty:
+		return v
+	}
+	Error("Invalid lfs.smudge.onmissing value %q, expected %q, %q or %q", value,
+		smudgeOnMissingError, smudgeOnMissingPointer, smudgeOnMissingEmpty)
+	return smudgeOnMissingError
+}
+
+// emitSmudgeEvent writes an event of the given type describing the smudging of
+// "ptr" into "filename" to the event stream, if one is configured.
+func emitSmudgeEvent(typ events.Type, filename string, ptr *lfs.Pointer, err error) {
+	ev := &events.Event{Type: typ, Path: filename, Oid: ptr.Oid, Size: ptr.Size}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	emitEvent(ev)
+}
+
 func smudgeCommand(cmd *cobra.Command, args []string) {
+	if smudgeInfo {
+		smudgeInfoCommand(args)
+		return
+	}
+
 	requireStdin("This command should be run by the Git 'smudge' filter")
 	installHooks(false)
 
-	if !smudgeSkip && cfg.Os.Bool("GIT_LFS_SKIP_SMUDGE", false) {
+	if !smudgeSkip && cfg.SkipSmudge() {
 		smudgeSkip = true
 	}
 	filter := filepathfilter.New(cfg.FetchIncludePaths(), cfg.FetchExcludePaths())
+	fetchSparseCheckout = smudgeSparseCheckout()
 	gitfilter := lfs.NewGitFilter(cfg)
 
 	if n, err := smudge(gitfilter, os.Stdout, os.Stdin, smudgeFilename(args), smudgeSkip, filter); err != nil {
@@ -169,6 +257,37 @@ func smudgeCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// smudgeInfoCommand decodes the pointer in the file named by the first argument,
+// or on stdin if there is none, and prints its OID and size as JSON without
+// fetching its contents.
+func smudgeInfoCommand(args []string) {
+	from := io.Reader(os.Stdin)
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			ExitWithError(err)
+		}
+		defer f.Close()
+		from = f
+	} else {
+		requireStdin("Provide a pointer on stdin, or the path to one")
+	}
+
+	ptr, err := lfs.DecodePointer(from)
+	if err != nil {
+		Exit("%s is not a Git LFS pointer: %s", smudgeFilename(args), errors.Cause(err))
+	}
+
+	encoded, err := json.Marshal(struct {
+		Oid  string `json:"oid"`
+		Size int64  `json:"size"`
+	}{ptr.Oid, ptr.Size})
+	if err != nil {
+		ExitWithError(err)
+	}
+	Print(string(encoded))
+}
+
 func smudgeFilename(args []string) string {
 	if len(args) > 0 {
 		return args[0]
@@ -183,5 +302,6 @@ func possiblyMalformedObjectSize(n int64) bool {
 func init() {
 	RegisterCommand("smudge", smudgeCommand, func(cmd *cobra.Command) {
 		cmd.Flags().BoolVarP(&smudgeSkip, "skip", "s", false, "")
+		cmd.Flags().BoolVarP(&smudgeInfo, "info", "", false, "print the pointer's OID and size as JSON")
 	})
 }
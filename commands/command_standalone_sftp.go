@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/git-lfs/git-lfs/lfshttp/standalone"
+	"github.com/spf13/cobra"
+)
+
+func standaloneSFTPCommand(cmd *cobra.Command, args []string) {
+	err := standalone.ProcessStandaloneSFTPData(cfg, os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+}
+
+func init() {
+	RegisterCommand("standalone-sftp", standaloneSFTPCommand, nil)
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/lfs"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// statsAll indicates that the history of all local branches and tags
+	// should be scanned, instead of the given refs.
+	statsAll bool
+	// statsTopN is the number of largest objects listed.
+	statsTopN int
+	// statsJSON indicates that the statistics should be printed as JSON.
+	statsJSON bool
+)
+
+// statsObject is an object referred to by a Git LFS file in the history of
+// the scanned refs, with the name of a file which referred to it.
+type statsObject struct {
+	Oid   string `json:"oid"`
+	Size  int64  `json:"size"`
+	Name  string `json:"name"`
+	Local bool   `json:"local"`
+}
+
+func statsCommand(cmd *cobra.Command, args []string) {
+	setupRepository()
+
+	if statsTopN < 0 {
+		Exit("fatal: --top must not be negative")
+	}
+
+	refs := statsRefs(args)
+
+	include, exclude := getIncludeExcludeArgs(cmd)
+	filter := buildFilepathFilter(cfg, include, exclude, false)
+
+	// Each object is counted once, however many of the refs, files and
+	// commits refer to it.
+	objects := make(map[string]*statsObject)
+	refGroups := make(objectGroups)
+	for _, ref := range refs {
+		seen := make(map[string]bool)
+		refGroups[ref.Refspec()] = &objectGroup{Name: ref.Refspec()}
+
+		gitscanner := lfs.NewGitScanner(cfg, nil)
+		gitscanner.Filter = filter
+		err := gitscanner.ScanRefs([]string{ref.Sha}, nil, func(p *lfs.WrappedPointer, err error) {
+			if err != nil {
+				LoggedError(err, "Could not scan for Git LFS files: %s", err)
+				return
+			}
+			if seen[p.Oid] {
+				return
+			}
+			seen[p.Oid] = true
+			refGroups.add(ref.Refspec(), p.Size)
+
+			if o, ok := objects[p.Oid]; !ok || p.Name < o.Name {
+				objects[p.Oid] = &statsObject{Oid: p.Oid, Size: p.Size, Name: p.Name}
+			}
+		})
+		gitscanner.Close()
+		if err != nil {
+			ExitWithError(err)
+		}
+	}
+
+	dirs := make(objectGroups)
+	exts := make(objectGroups)
+	largest := make([]*statsObject, 0, len(objects))
+	var size, localSize int64
+	var local int
+	for _, o := range objects {
+		o.Local = cfg.LFSObjectExists(o.Oid, o.Size)
+		if o.Local {
+			local++
+			localSize += o.Size
+		}
+		size += o.Size
+		addFile(dirs, exts, o.Name, o.Size)
+		largest = append(largest, o)
+	}
+
+	sort.Slice(largest, func(i, j int) bool {
+		if largest[i].Size != largest[j].Size {
+			return largest[i].Size > largest[j].Size
+		}
+		return largest[i].Oid < largest[j].Oid
+	})
+	if len(largest) > statsTopN {
+		largest = largest[:statsTopN]
+	}
+
+	// Refs are listed in the order they were given.
+	byRef := make([]*objectGroup, 0, len(refs))
+	for _, ref := range refs {
+		byRef = append(byRef, refGroups[ref.Refspec()])
+	}
+
+	if statsJSON {
+		encoded, err := json.Marshal(struct {
+			Count       int            `json:"count"`
+			Size        int64          `json:"size"`
+			LocalCount  int            `json:"local_count"`
+			LocalSize   int64          `json:"local_size"`
+			Refs        []*objectGroup `json:"refs"`
+			Directories []*objectGroup `json:"directories"`
+			Extensions  []*objectGroup `json:"extensions"`
+			Largest     []*statsObject `json:"largest"`
+		}{len(objects), size, local, localSize, byRef, dirs.sorted(), exts.sorted(), largest})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+		return
+	}
+
+	Print("%d LFS object(s), %s, in the history of %d ref(s); %d object(s), %s, present locally",
+		len(objects), humanize.FormatBytes(uint64(size)), len(refs),
+		local, humanize.FormatBytes(uint64(localSize)))
+	if len(objects) == 0 {
+		return
+	}
+
+	for _, table := range []struct {
+		heading string
+		groups  []*objectGroup
+	}{
+		{"ref", byRef},
+		{"directory", dirs.sorted()},
+		{"extension", exts.sorted()},
+	} {
+		Print("\nBy %s:", table.heading)
+		for _, line := range formatObjectGroups(table.groups) {
+			Print("  %s", line)
+		}
+	}
+
+	if len(largest) > 0 {
+		Print("\nLargest objects:")
+		for _, o := range largest {
+			oid := o.Oid
+			if !longOIDs {
+				oid = oid[:10]
+			}
+			Print("  %s\t%s\t%s", oid, humanize.FormatBytes(uint64(o.Size)), o.Name)
+		}
+	}
+}
+
+// statsRefs returns the refs whose history is scanned: those given, all local
+// branches and tags with --all, or else the current ref.
+func statsRefs(args []string) []*git.Ref {
+	if statsAll {
+		if len(args) > 0 {
+			Exit("fatal: cannot use --all with explicit reference")
+		}
+
+		refs, err := git.LocalRefs()
+		if err != nil {
+			Exit("fatal: could not list references: %s", err)
+		}
+		return refs
+	}
+
+	if len(args) == 0 {
+		ref, err := git.CurrentRef()
+		if err != nil {
+			Exit("fatal: could not resolve HEAD: %s", err)
+		}
+		return []*git.Ref{ref}
+	}
+
+	refs := make([]*git.Ref, 0, len(args))
+	seen := make(map[string]bool)
+	for _, name := range args {
+		ref, err := git.ResolveRef(name)
+		if err != nil {
+			Exit("fatal: could not resolve %q: %s", name, err)
+		}
+		if !seen[ref.Refspec()] {
+			seen[ref.Refspec()] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+func init() {
+	RegisterCommand("stats", statsCommand, func(cmd *cobra.Command) {
+		cmd.Flags().BoolVarP(&statsAll, "all", "a", false, "Scan the history of all local branches and tags")
+		cmd.Flags().IntVar(&statsTopN, "top", 10, "List this many of the largest objects")
+		cmd.Flags().BoolVar(&statsJSON, "json", false, "Print the statistics as JSON")
+		cmd.Flags().BoolVarP(&longOIDs, "long", "l", false, "Show the entire OID of each of the largest objects")
+		cmd.Flags().StringVarP(&includeArg, "include", "I", "", "Include a list of paths")
+		cmd.Flags().StringVarP(&excludeArg, "exclude", "X", "", "Exclude a list of paths")
+	})
+}
@@ -7,22 +7,32 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tools/humanize"
 	"github.com/spf13/cobra"
 )
 
 var (
-	porcelain  = false
-	statusJson = false
+	porcelain             = false
+	statusJson            = false
+	statusCheckAttributes = false
+	statusExitCode        = false
+	statusByPattern       = false
+	statusPointers        = false
 )
 
 func statusCommand(cmd *cobra.Command, args []string) {
 	setupWorkingCopy()
 
+	if statusByPattern && porcelain {
+		Exit("--by-pattern option can't be combined with --porcelain")
+	}
+
 	// tolerate errors getting ref so this works before first commit
 	ref, _ := git.CurrentRef()
 
@@ -36,21 +46,41 @@ func statusCommand(cmd *cobra.Command, args []string) {
 		ExitWithError(err)
 	}
 
-	if porcelain {
-		porcelainStagedPointers(scanIndexAt)
-		return
-	} else if statusJson {
-		jsonStagedPointers(scanner, scanIndexAt)
+	if statusCheckAttributes {
+		checkAttributesStatus()
 		return
 	}
 
-	statusScanRefRange(ref)
-
 	staged, unstaged, err := scanIndex(scanIndexAt)
 	if err != nil {
 		ExitWithError(err)
 	}
 
+	if statusByPattern {
+		printStatusByPattern(scanner, staged, unstaged)
+	} else if porcelain {
+		porcelainStagedPointers(staged, unstaged)
+	} else if statusJson {
+		jsonStagedPointers(scanner, staged, unstaged)
+	} else {
+		printStatus(scanner, ref, scanIndexAt, staged, unstaged)
+	}
+
+	dirty := statusExitCode && hasLFSChanges(scanner, append(staged, unstaged...))
+
+	if err = scanner.Close(); err != nil {
+		ExitWithError(err)
+	}
+	if dirty {
+		os.Exit(1)
+	}
+}
+
+// printStatus prints the human readable status: the objects to be pushed, and
+// the "staged" and "unstaged" changes to the index at "scanIndexAt".
+func printStatus(scanner *lfs.PointerScanner, ref *git.Ref, scanIndexAt string, staged, unstaged []*lfs.DiffIndexEntry) {
+	statusScanRefRange(ref)
+
 	wd, _ := os.Getwd()
 	repo := cfg.LocalWorkingDir()
 
@@ -78,11 +108,68 @@ func statusCommand(cmd *cobra.Command, args []string) {
 		Print("\t%s (%s)", src, formatBlobInfo(scanner, entry))
 	}
 
+	if !statusPointers {
+		Print("")
+		return
+	}
+
+	if problems := statusPointerProblems(ref, scanIndexAt); len(problems) > 0 {
+		Print("\nObjects with pointer text in place of contents:\n")
+		for _, problem := range problems {
+			src := relativize(wd, filepath.Join(repo, problem.Name))
+
+			Print("\t%s (%s)", src, problem.Nature)
+		}
+	}
+
 	Print("")
+}
 
-	if err = scanner.Close(); err != nil {
+// hasLFSChanges returns whether any of the changes "entries" involves Git LFS,
+// as "git lfs status --exit-code" reports: that is, if the blob stored in Git
+// on either side of it is a Git LFS pointer, or the path on either side of it
+// is tracked by Git LFS, such as a file in the working tree which would be
+// stored as a pointer were it added.
+func hasLFSChanges(s *lfs.PointerScanner, entries []*lfs.DiffIndexEntry) bool {
+	var paths []string
+	for _, entry := range entries {
+		for _, sha := range []string{entry.SrcSha, entry.DstSha} {
+			if git.IsZeroObjectID(sha) {
+				continue
+			}
+
+			s.Scan(sha)
+			if err := s.Err(); err != nil {
+				if git.IsMissingObject(err) {
+					continue
+				}
+				ExitWithError(err)
+			}
+			if s.Pointer() != nil {
+				return true
+			}
+		}
+
+		paths = append(paths, entry.SrcName)
+		if len(entry.DstName) > 0 && entry.DstName != entry.SrcName {
+			paths = append(paths, entry.DstName)
+		}
+	}
+
+	if len(paths) == 0 {
+		return false
+	}
+
+	filters, err := git.AttributeValues(cfg.LocalWorkingDir(), "filter", paths)
+	if err != nil {
 		ExitWithError(err)
 	}
+	for _, path := range paths {
+		if filters[path] == "lfs" {
+			return true
+		}
+	}
+	return false
 }
 
 func formatBlobInfo(s *lfs.PointerScanner, entry *lfs.DiffIndexEntry) string {
@@ -217,6 +304,10 @@ func keyFromEntry(e *lfs.DiffIndexEntry) string {
 		name = e.SrcName
 	}
 
+	// Names differing only in case are the same file where the filesystem
+	// ignores case.
+	name = tools.FoldPath(name, cfg.IgnoreCase())
+
 	return strings.Join([]string{e.SrcSha, e.DstSha, name}, ":")
 }
 
@@ -249,6 +340,263 @@ func statusScanRefRange(ref *git.Ref) {
 
 }
 
+// statusPointerProblems returns the problems found by checkPointerContents for
+// the Git LFS files in the tree of the given ref and in the index.
+func statusPointerProblems(ref *git.Ref, scanIndexAt string) []*pointerProblem {
+	var problems []*pointerProblem
+	seen := make(map[string]struct{})
+	cb := func(p *lfs.WrappedPointer, err error) {
+		if err != nil {
+			Panic(err, "Could not scan for Git LFS objects")
+			return
+		}
+
+		key := p.Name + ":" + p.Oid
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		problems = append(problems, checkPointerContents(p)...)
+	}
+
+	gitscanner := lfs.NewGitScanner(cfg, cb)
+	defer gitscanner.Close()
+
+	if ref != nil {
+		if err := gitscanner.ScanTree(ref.Sha); err != nil {
+			Panic(err, "Could not scan for Git LFS objects")
+		}
+	}
+	if err := gitscanner.ScanIndex(scanIndexAt, cb); err != nil {
+		Panic(err, "Could not scan for Git LFS objects")
+	}
+	return problems
+}
+
+// checkAttributesStatus lists the files in the index which match the patterns
+// tracked by Git LFS, but which are stored as Git objects rather than as
+// pointers, as happens when they are added while the filter isn't configured,
+// and exits with an error if there are any.
+func checkAttributesStatus() {
+	repo := cfg.LocalWorkingDir()
+	entries, err := git.IndexEntries(repo)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	filters, err := git.AttributeValues(repo, "filter", paths)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	scanner, err := git.NewObjectScanner(cfg.GitEnv(), cfg.OSEnv())
+	if err != nil {
+		ExitWithError(err)
+	}
+	defer scanner.Close()
+
+	wd, _ := os.Getwd()
+	wd = tools.ResolveSymlinks(wd)
+
+	var found int
+	for _, entry := range entries {
+		if filters[entry.Path] != "lfs" {
+			continue
+		}
+
+		if !scanner.Scan(entry.Sha) {
+			if err := scanner.Err(); err != nil && !git.IsMissingObject(err) {
+				ExitWithError(err)
+			}
+			continue
+		}
+		// Empty files are never converted to pointers.
+		if scanner.Size() == 0 {
+			continue
+		}
+		if _, err := lfs.DecodePointer(scanner.Contents()); err == nil {
+			continue
+		}
+
+		if found == 0 {
+			Print("Files matching Git LFS patterns stored as Git objects:\n")
+		}
+		found++
+		Print("\t%s (%s)", relativize(wd, filepath.Join(repo, entry.Path)),
+			humanize.FormatBytes(uint64(scanner.Size())))
+	}
+
+	if found > 0 {
+		Print("\nRun `git lfs migrate import --fixup` to convert them to Git LFS objects.")
+		scanner.Close()
+		os.Exit(1)
+	}
+}
+
+// statusPattern summarizes the changes to the Git LFS files tracked by one
+// pattern, for "git lfs status --by-pattern".
+type statusPattern struct {
+	// Pattern is the pattern, relative to the root of the repository, or
+	// empty for files tracked by no pattern, such as pointers committed
+	// while their pattern was tracked.
+	Pattern string   `json:"pattern"`
+	Source  string   `json:"source,omitempty"`
+	Count   int      `json:"count"`
+	Size    int64    `json:"size"`
+	Files   []string `json:"files"`
+}
+
+// statusPatterns groups the changed files among "staged" and "unstaged" which
+// involve Git LFS, as for --exit-code, by the pattern tracking each, with the
+// number of files and their total size. These are sorted by number of files,
+// most first, and then by pattern. Files changed in both the index and the
+// working tree are counted once, with their size in the working tree.
+func statusPatterns(scanner *lfs.PointerScanner, staged, unstaged []*lfs.DiffIndexEntry) []*statusPattern {
+	matcher := git.NewAttributeMatcher(getAllKnownPatterns())
+	ignoreCase := cfg.IgnoreCase()
+
+	type change struct {
+		name    string
+		size    int64
+		pattern *git.AttributePath
+	}
+	changes := make(map[string]*change)
+	var names []string
+
+	for _, entry := range append(staged, unstaged...) {
+		name := entry.DstName
+		if len(name) == 0 {
+			name = entry.SrcName
+		}
+
+		size, pointer, err := statusEntrySize(scanner, entry, name)
+		if err != nil {
+			ExitWithError(err)
+		}
+
+		pattern := matcher.Match(name)
+		if pattern != nil && !pattern.Tracked {
+			pattern = nil
+		}
+		if pattern == nil && !pointer {
+			continue
+		}
+
+		key := tools.FoldPath(name, ignoreCase)
+		if _, ok := changes[key]; !ok {
+			names = append(names, key)
+		}
+		changes[key] = &change{name: name, size: size, pattern: pattern}
+	}
+
+	groups := make(map[string]*statusPattern)
+	var patterns []*statusPattern
+	for _, key := range names {
+		c := changes[key]
+
+		var pattern, source string
+		if c.pattern != nil {
+			pattern = rootRelativePattern(*c.pattern)
+			source = c.pattern.Source.String()
+		}
+
+		g, ok := groups[pattern+"\x00"+source]
+		if !ok {
+			g = &statusPattern{Pattern: pattern, Source: source, Files: []string{}}
+			groups[pattern+"\x00"+source] = g
+			patterns = append(patterns, g)
+		}
+		g.Count++
+		g.Size += c.size
+		g.Files = append(g.Files, c.name)
+	}
+
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].Pattern < patterns[j].Pattern
+	})
+	for _, g := range patterns {
+		sort.Strings(g.Files)
+	}
+	return patterns
+}
+
+// statusEntrySize returns the size of the file "name" after the change
+// "entry": that of its Git LFS object if it is stored as a pointer, or else
+// that of the file in the working tree, which is zero if it has been deleted.
+// It also returns whether the file is stored as a pointer either before or
+// after the change.
+func statusEntrySize(s *lfs.PointerScanner, entry *lfs.DiffIndexEntry, name string) (int64, bool, error) {
+	var pointer bool
+	for _, sha := range []string{entry.DstSha, entry.SrcSha} {
+		if git.IsZeroObjectID(sha) {
+			continue
+		}
+
+		s.Scan(sha)
+		if err := s.Err(); err != nil {
+			if git.IsMissingObject(err) {
+				continue
+			}
+			return 0, false, err
+		}
+		if p := s.Pointer(); p != nil {
+			if sha == entry.DstSha {
+				return p.Size, true, nil
+			}
+			pointer = true
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(cfg.LocalWorkingDir(), name))
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0, pointer, nil
+	}
+	return fi.Size(), pointer, nil
+}
+
+// printStatusByPattern prints the summary of statusPatterns, as JSON if --json
+// was given.
+func printStatusByPattern(scanner *lfs.PointerScanner, staged, unstaged []*lfs.DiffIndexEntry) {
+	patterns := statusPatterns(scanner, staged, unstaged)
+
+	if statusJson {
+		ret, err := json.Marshal(struct {
+			Patterns []*statusPattern `json:"patterns"`
+		}{patterns})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(ret))
+		return
+	}
+
+	Print("Git LFS changes by pattern:\n")
+
+	var count int
+	var size int64
+	for _, g := range patterns {
+		name := g.Pattern
+		if len(name) == 0 {
+			name = "(no pattern)"
+		} else if len(g.Source) > 0 {
+			name = fmt.Sprintf("%s (%s)", g.Pattern, g.Source)
+		}
+		Print("\t%s: %d file(s), %s", name, g.Count, humanize.FormatBytes(uint64(g.Size)))
+
+		count += g.Count
+		size += g.Size
+	}
+
+	Print("\n%d file(s), %s in total", count, humanize.FormatBytes(uint64(size)))
+}
+
 type JSONStatusEntry struct {
 	Status string `json:"status"`
 	From   string `json:"from,omitempty"`
@@ -258,12 +606,7 @@ type JSONStatus struct {
 	Files map[string]JSONStatusEntry `json:"files"`
 }
 
-func jsonStagedPointers(scanner *lfs.PointerScanner, ref string) {
-	staged, unstaged, err := scanIndex(ref)
-	if err != nil {
-		ExitWithError(err)
-	}
-
+func jsonStagedPointers(scanner *lfs.PointerScanner, staged, unstaged []*lfs.DiffIndexEntry) {
 	status := JSONStatus{Files: make(map[string]JSONStatusEntry)}
 
 	for _, entry := range append(unstaged, staged...) {
@@ -295,19 +638,16 @@ func jsonStagedPointers(scanner *lfs.PointerScanner, ref string) {
 	Print(string(ret))
 }
 
-func porcelainStagedPointers(ref string) {
-	staged, unstaged, err := scanIndex(ref)
-	if err != nil {
-		ExitWithError(err)
-	}
-
+func porcelainStagedPointers(staged, unstaged []*lfs.DiffIndexEntry) {
 	seenNames := make(map[string]struct{})
+	ignoreCase := cfg.IgnoreCase()
 
 	for _, entry := range append(unstaged, staged...) {
 		name := entry.DstName
 		if len(name) == 0 {
 			name = entry.SrcName
 		}
+		name = tools.FoldPath(name, ignoreCase)
 
 		if _, seen := seenNames[name]; !seen {
 			Print(porcelainStatusLine(entry))
@@ -365,5 +705,9 @@ func init() {
 	RegisterCommand("status", statusCommand, func(cmd *cobra.Command) {
 		cmd.Flags().BoolVarP(&porcelain, "porcelain", "p", false, "Give the output in an easy-to-parse format for scripts.")
 		cmd.Flags().BoolVarP(&statusJson, "json", "j", false, "Give the output in a stable json format for scripts.")
+		cmd.Flags().BoolVarP(&statusCheckAttributes, "check-attributes", "", false, "List files matching Git LFS patterns stored as Git objects.")
+		cmd.Flags().BoolVarP(&statusExitCode, "exit-code", "", false, "Exit with 1 if there are changes involving Git LFS files, and 0 otherwise.")
+		cmd.Flags().BoolVarP(&statusByPattern, "by-pattern", "", false, "Summarize the changes to Git LFS files by the pattern tracking them.")
+		cmd.Flags().BoolVarP(&statusPointers, "pointers", "", false, "Also list files with pointer text in place of their contents.")
 	})
 }
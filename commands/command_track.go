@@ -4,14 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/git/gitattr"
+	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/spf13/cobra"
 )
@@ -28,6 +32,30 @@ var (
 	trackNoModifyAttrsFlag  bool
 	trackNoExcludedFlag     bool
 	trackFilenameFlag       bool
+	trackForceFlag          bool
+	trackExportFlag         bool
+	trackImportFlag         string
+	trackAttributesFileFlag string
+	trackMagicFlag          bool
+
+	// trackSourceExtensions are the extensions of files which are source
+	// code, and so should almost never be tracked by Git LFS.
+	trackSourceExtensions = []string{
+		".c", ".cc", ".cpp", ".cs", ".cxx", ".go", ".h", ".hpp", ".java",
+		".js", ".jsx", ".kt", ".m", ".mm", ".php", ".pl", ".py", ".rb",
+		".rs", ".scala", ".swift", ".ts", ".tsx",
+	}
+)
+
+const (
+	// defaultTrackWarnThreshold is the default percentage of the files
+	// tracked by Git which a new pattern may match before `git lfs track`
+	// requires --force.
+	defaultTrackWarnThreshold = 50
+	// trackWarnMinFiles is the number of files a new pattern must match
+	// before the threshold applies, so that it doesn't in small
+	// repositories.
+	trackWarnMinFiles = 10
 )
 
 func trackCommand(cmd *cobra.Command, args []string) {
@@ -38,6 +66,26 @@ func trackCommand(cmd *cobra.Command, args []string) {
 		installHooks(false)
 	}
 
+	if trackMagicFlag {
+		if len(args) > 0 || trackExportFlag || len(trackImportFlag) > 0 {
+			Exit("Cannot use --magic with --export, --import or patterns")
+		}
+		trackByContents()
+		return
+	}
+
+	if trackExportFlag || len(trackImportFlag) > 0 {
+		if len(args) > 0 || (trackExportFlag && len(trackImportFlag) > 0) {
+			Exit("Cannot use --export or --import with each other or with patterns")
+		}
+		if trackExportFlag {
+			exportPatterns()
+		} else {
+			importPatterns(trackImportFlag)
+		}
+		return
+	}
+
 	if len(args) == 0 {
 		listPatterns()
 		return
@@ -55,6 +103,10 @@ func trackCommand(cmd *cobra.Command, args []string) {
 		lineEnd = gitLineEnding(cfg.Git)
 	}
 
+	// Patterns which differ only in case match the same files where the
+	// filesystem ignores case.
+	ignoreCase := cfg.IgnoreCase()
+
 	wd, _ := tools.Getwd()
 	wd = tools.ResolveSymlinks(wd)
 	relpath, err := filepath.Rel(cfg.LocalWorkingDir(), wd)
@@ -62,9 +114,20 @@ func trackCommand(cmd *cobra.Command, args []string) {
 		Exit("Current directory %q outside of git working directory %q.", wd, cfg.LocalWorkingDir())
 	}
 
+	attribPath, attribDir := trackAttributesFile(relpath)
+	attribPrefix := ""
+	if attribDir != relpath {
+		sub, _ := filepath.Rel(attribDir, relpath)
+		attribPrefix = escapeGlobCharacters(sub)
+	}
+
 	changedAttribLines := make(map[string]string)
+	newPatterns := make(map[string]string)
 	var readOnlyPatterns []string
 	var writeablePatterns []string
+	var trackingPatterns []string
+	var overBroad bool
+	trackedCount := -1
 ArgsLoop:
 	for _, unsanitizedPattern := range args {
 		pattern := trimCurrentPrefix(cleanRootPath(unsanitizedPattern))
@@ -78,9 +141,20 @@ ArgsLoop:
 			encodedArg = escapeAttrPattern(pattern)
 		}
 
+		// Patterns are given relative to the current directory, and
+		// written relative to the attributes file.
+		filePattern := pattern
+		if len(attribPrefix) > 0 {
+			encodedArg = attributesFilePattern(attribPrefix, encodedArg)
+			filePattern = unescapeAttrPattern(encodedArg)
+			if trackFilenameFlag {
+				filePattern = encodedArg
+			}
+		}
+
 		if !trackNoModifyAttrsFlag {
 			for _, known := range knownPatterns {
-				if unescapeAttrPattern(known.Path) == filepath.Join(relpath, pattern) &&
+				if tools.PathsEqual(unescapeAttrPattern(known.Path), filepath.Join(attribDir, filePattern), ignoreCase) &&
 					((trackLockableFlag && known.Lockable) || // enabling lockable & already lockable (no change)
 						(trackNotLockableFlag && !known.Lockable) || // disabling lockable & not lockable (no change)
 						(!trackLockableFlag && !trackNotLockableFlag)) { // leave lockable as-is in all cases
@@ -90,12 +164,24 @@ ArgsLoop:
 			}
 		}
 
+		if !trackForceFlag && !isKnownPattern(knownPatterns, filepath.Join(attribDir, filePattern), ignoreCase) {
+			if trackedCount < 0 {
+				trackedCount = countTrackedFiles()
+			}
+			if warnOverBroadPattern(unescapeAttrPattern(encodedArg), pattern, trackedCount) {
+				overBroad = true
+				continue
+			}
+		}
+
 		lockableArg := ""
 		if trackLockableFlag { // no need to test trackNotLockableFlag, if we got here we're disabling
 			lockableArg = " " + git.LockableAttrib
 		}
 
-		changedAttribLines[pattern] = fmt.Sprintf("%s filter=lfs diff=lfs merge=lfs -text%v%s", encodedArg, lockableArg, lineEnd)
+		key := tools.FoldPath(filePattern, ignoreCase)
+		changedAttribLines[key] = fmt.Sprintf("%s filter=lfs diff=lfs merge=lfs -text%v%s", encodedArg, lockableArg, lineEnd)
+		newPatterns[key] = pattern
 
 		if trackLockableFlag {
 			readOnlyPatterns = append(readOnlyPatterns, pattern)
@@ -103,7 +189,14 @@ ArgsLoop:
 			writeablePatterns = append(writeablePatterns, pattern)
 		}
 
-		Print("Tracking %q", unescapeAttrPattern(encodedArg))
+		trackingPatterns = append(trackingPatterns, unescapeAttrPattern(encodedArg))
+	}
+
+	if overBroad && !trackDryRunFlag {
+		Exit("Not tracking any patterns. Use --force to track them anyway.")
+	}
+	for _, pattern := range trackingPatterns {
+		Print("Tracking %q", pattern)
 	}
 
 	// Now read the whole local attributes file and iterate over the contents,
@@ -115,14 +208,14 @@ ArgsLoop:
 		attributesFile *os.File
 	)
 	if !trackNoModifyAttrsFlag {
-		attribContents, err = ioutil.ReadFile(".gitattributes")
+		attribContents, err = ioutil.ReadFile(attribPath)
 		// it's fine for file to not exist
 		if err != nil && !os.IsNotExist(err) {
 			Print("Error reading .gitattributes file")
 			return
 		}
 		// Re-generate the file with merge of old contents and new (to deal with changes)
-		attributesFile, err = os.OpenFile(".gitattributes", os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0660)
+		attributesFile, err = os.OpenFile(attribPath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0660)
 		if err != nil {
 			Print("Error opening .gitattributes file")
 			return
@@ -138,7 +231,7 @@ ArgsLoop:
 					continue
 				}
 
-				pattern := unescapeAttrPattern(fields[0])
+				pattern := tools.FoldPath(unescapeAttrPattern(fields[0]), ignoreCase)
 				if newline, ok := changedAttribLines[pattern]; ok {
 					// Replace this line (newline already embedded)
 					attributesFile.WriteString(newline)
@@ -156,7 +249,7 @@ ArgsLoop:
 
 	// Any items left in the map, write new lines at the end of the file
 	// Note this is only new patterns, not ones which changed locking flags
-	for pattern, newline := range changedAttribLines {
+	for key, newline := range changedAttribLines {
 		if !trackNoModifyAttrsFlag {
 			// Newline already embedded
 			attributesFile.WriteString(newline)
@@ -172,54 +265,421 @@ ArgsLoop:
 		// Since all `git-lfs track` calls are relative to the root of
 		// the repository, the leading slash is simply removed for its
 		// implicit counterpart.
-		if trackVerboseLoggingFlag {
-			Print("Searching for files matching pattern: %s", pattern)
+		touchTrackedFiles(newPatterns[key])
+	}
+
+	// now flip read-only mode based on lockable / not lockable changes
+	lockClient := newLockClient()
+	err = lockClient.FixFileWriteFlagsInDir(relpath, readOnlyPatterns, writeablePatterns)
+	if err != nil {
+		LoggedError(err, "Error changing lockable file permissions: %s", err)
+	}
+}
+
+// trackAttributesFile returns the path of the attributes file patterns are
+// written to, given by --attributes-file relative to the current directory, or
+// lfs.track.attributesfile relative to the root of the repository, or else the
+// .gitattributes file in the current directory, and its directory relative to
+// the root. "relpath" is the current directory relative to the root, which
+// must be within that directory.
+func trackAttributesFile(relpath string) (string, string) {
+	name, base := trackAttributesFileFlag, "."
+	if len(name) == 0 {
+		name, _ = cfg.Git.Get("lfs.track.attributesfile")
+		base = cfg.LocalWorkingDir()
+	}
+	if len(name) == 0 {
+		return ".gitattributes", relpath
+	}
+
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(base, name)
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		ExitWithError(errors.Wrapf(err, "invalid attributes file %q", name))
+	}
+	abs = filepath.Join(tools.ResolveSymlinks(filepath.Dir(abs)), filepath.Base(abs))
+
+	rel, err := filepath.Rel(cfg.LocalWorkingDir(), abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		Exit("Attributes file %q is outside of the working directory %q.", name, cfg.LocalWorkingDir())
+	}
+	if filepath.Base(rel) != ".gitattributes" {
+		Exit("Attributes file %q is not named .gitattributes.", name)
+	}
+
+	dir := filepath.Dir(rel)
+	if dir != "." && relpath != dir && !strings.HasPrefix(relpath, dir+string(filepath.Separator)) {
+		Exit("Cannot track patterns outside of %q in %q.", dir, name)
+	}
+	return abs, dir
+}
+
+// attributesFilePattern returns the escaped "pattern", given relative to the
+// current directory, relative to an attributes file in the directory which the
+// current directory is at "prefix" below, also escaped.
+func attributesFilePattern(prefix, pattern string) string {
+	switch {
+	case strings.HasPrefix(pattern, "/"):
+		return prefix + pattern
+	case strings.Contains(pattern, "/"):
+		return prefix + "/" + pattern
+	default:
+		// Patterns without a slash match files at any depth below
+		// the directory they're given in.
+		return prefix + "/**/" + pattern
+	}
+}
+
+// touchTrackedFiles updates the modification times of the files tracked by Git
+// which match the new "pattern", so that they're shown as modified.
+func touchTrackedFiles(pattern string) {
+	if trackVerboseLoggingFlag {
+		Print("Searching for files matching pattern: %s", pattern)
+	}
+
+	gittracked, err := git.GetTrackedFiles(pattern)
+	if err != nil {
+		Exit("Error getting tracked files for %q: %s", pattern, err)
+	}
+
+	if trackVerboseLoggingFlag {
+		Print("Found %d files previously added to Git matching pattern: %s", len(gittracked), pattern)
+	}
+
+	var matchedBlocklist bool
+	for _, f := range gittracked {
+		if forbidden := blocklistItem(f); forbidden != "" {
+			Print("Pattern %s matches forbidden file %s. If you would like to track %s, modify .gitattributes manually.", pattern, f, f)
+			matchedBlocklist = true
 		}
+	}
+	if matchedBlocklist {
+		return
+	}
 
-		gittracked, err := git.GetTrackedFiles(pattern)
+	for _, f := range gittracked {
+		if trackVerboseLoggingFlag || trackDryRunFlag {
+			Print("Git LFS: touching %q", f)
+		}
+
+		if !trackDryRunFlag {
+			now := time.Now()
+			err := os.Chtimes(f, now, now)
+			if err != nil {
+				LoggedError(err, "Error marking %q modified: %s", f, err)
+				continue
+			}
+		}
+	}
+}
+
+// exportPatterns prints the patterns tracked by Git LFS in the attributes files
+// of the repository, including nested ones, as lines of a .gitattributes file
+// at the root of the repository, which importPatterns can read.
+func exportPatterns() {
+	mp := gitattr.NewMacroProcessor()
+	git.GetSystemAttributePaths(mp, cfg.Os)
+	git.GetRootAttributePaths(mp, cfg.Git)
+	knownPatterns := git.GetAttributePaths(mp, cfg.LocalWorkingDir(), cfg.LocalGitDir())
+
+	// Later lines take precedence over earlier ones for the same pattern,
+	// but keep the position of the first.
+	var order []string
+	effective := make(map[string]git.AttributePath)
+	for _, known := range knownPatterns {
+		pattern := rootRelativePattern(known)
+		if _, ok := effective[pattern]; !ok {
+			order = append(order, pattern)
+		}
+		effective[pattern] = known
+	}
+
+	for _, pattern := range order {
+		known := effective[pattern]
+		if known.Tracked && !known.Magic {
+			Print("%s", trackAttribLine(pattern, known.Lockable, ""))
+		}
+	}
+}
+
+// rootRelativePattern returns the pattern of "known" as it would be written
+// in the .gitattributes file at the root of the repository.
+func rootRelativePattern(known git.AttributePath) string {
+	dir := path.Dir(filepath.ToSlash(known.Source.Path))
+	if dir == "." || strings.HasPrefix(dir, ".git/") || strings.HasPrefix(dir, "../") {
+		return filepath.ToSlash(known.Path)
+	}
+
+	// Patterns without a slash match files at any depth below the
+	// attributes file they're given in, and others are relative to it.
+	pattern := filepath.ToSlash(known.Pattern)
+	if strings.Contains(pattern, "/") {
+		return dir + "/" + strings.TrimPrefix(pattern, "/")
+	}
+	return dir + "/**/" + pattern
+}
+
+// trackAttribLine returns the attributes line which tracks "pattern" with Git
+// LFS, ending with "lineEnd".
+func trackAttribLine(pattern string, lockable bool, lineEnd string) string {
+	lockableArg := ""
+	if lockable {
+		lockableArg = " " + git.LockableAttrib
+	}
+	return fmt.Sprintf("%s filter=lfs diff=lfs merge=lfs -text%v%s", pattern, lockableArg, lineEnd)
+}
+
+// trackAttribs are the attributes written by trackAttribLine, which are
+// replaced when a line is changed to track its pattern with Git LFS.
+var trackAttribs = map[string]bool{
+	"filter": true, "diff": true, "merge": true, "text": true,
+	git.LockableAttrib: true,
+}
+
+// trackByContents adds the catch-all pattern with the lfs-magic attribute to
+// the top of the .gitattributes file at the root of the repository, where any
+// other pattern overrides it. The clean filter stores the files it alone
+// matches in Git LFS only if their contents are of one of the types given by
+// lfs.magic.types, and otherwise passes them through unchanged.
+func trackByContents() {
+	if types := lfs.NewContentTypes(cfg.Git.GetAll("lfs.magic.types")); types.Empty() {
+		Exit("Set lfs.magic.types to the MIME types of the files to track by their contents first, such as with:\n\n  git config -f .lfsconfig lfs.magic.types application/octet-stream")
+	}
+
+	// The pattern is relative to the root of the repository.
+	if err := os.Chdir(cfg.LocalWorkingDir()); err != nil {
+		ExitWithError(errors.Wrap(err, "could not change to the root of the repository"))
+	}
+
+	knownPatterns := getAllKnownPatterns()
+	for _, known := range knownPatterns {
+		if known.Magic && known.Tracked {
+			Print("Already tracking files by their contents")
+			return
+		}
+	}
+
+	Print("Tracking files matching no other pattern by their contents")
+	if trackNoModifyAttrsFlag || trackDryRunFlag {
+		return
+	}
+
+	lineEnd := getAttributeLineEnding(knownPatterns)
+	if len(lineEnd) == 0 {
+		lineEnd = gitLineEnding(cfg.Git)
+	}
+
+	attribContents, err := ioutil.ReadFile(".gitattributes")
+	if err != nil && !os.IsNotExist(err) {
+		ExitWithError(errors.Wrap(err, "could not read .gitattributes"))
+	}
+
+	line := fmt.Sprintf("* filter=lfs %s%s", git.MagicAttrib, lineEnd)
+	if err := ioutil.WriteFile(".gitattributes", append([]byte(line), attribContents...), 0660); err != nil {
+		ExitWithError(errors.Wrap(err, "could not write .gitattributes"))
+	}
+
+	touchTrackedFiles("*")
+}
+
+// importPatterns tracks the patterns, and keeps the lockable attribute of
+// each, tracked by Git LFS in the attributes file at "filename", as written by
+// exportPatterns, or in standard input if "filename" is "-". Patterns are
+// added to the .gitattributes file at the root of the repository, and those
+// already tracked are skipped. Lines for the same patterns which don't track
+// them are changed to, keeping any other attributes they set.
+func importPatterns(filename string) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
 		if err != nil {
-			Exit("Error getting tracked files for %q: %s", pattern, err)
+			ExitWithError(errors.Wrap(err, "could not open patterns to import"))
 		}
+		defer f.Close()
+		r = f
+	}
+
+	lines, _, err := gitattr.ParseLines(r)
+	if err != nil {
+		ExitWithError(errors.Wrap(err, "could not read patterns to import"))
+	}
+
+	// Patterns are relative to the root of the repository.
+	if err := os.Chdir(cfg.LocalWorkingDir()); err != nil {
+		ExitWithError(errors.Wrap(err, "could not change to the root of the repository"))
+	}
+
+	mp := gitattr.NewMacroProcessor()
+	git.GetSystemAttributePaths(mp, cfg.Os)
+	git.GetRootAttributePaths(mp, cfg.Git)
+	knownPatterns := git.GetAttributePaths(mp, cfg.LocalWorkingDir(), cfg.LocalGitDir())
+	lineEnd := getAttributeLineEnding(knownPatterns)
+	if len(lineEnd) == 0 {
+		lineEnd = gitLineEnding(cfg.Git)
+	}
 
-		if trackVerboseLoggingFlag {
-			Print("Found %d files previously added to Git matching pattern: %s", len(gittracked), pattern)
+	var order []string
+	imported := make(map[string]bool)
+	for _, line := range mp.ProcessLines(lines, false) {
+		if line.Pattern == nil {
+			continue
 		}
 
-		var matchedBlocklist bool
-		for _, f := range gittracked {
-			if forbidden := blocklistItem(f); forbidden != "" {
-				Print("Pattern %s matches forbidden file %s. If you would like to track %s, modify .gitattributes manually.", pattern, f, f)
-				matchedBlocklist = true
+		var tracked, lockable bool
+		for _, attr := range line.Attrs {
+			if attr.K == "filter" {
+				tracked = attr.V == "lfs"
+			} else if attr.K == git.LockableAttrib {
+				lockable = attr.V == "true"
 			}
 		}
-		if matchedBlocklist {
+		if !tracked {
 			continue
 		}
 
-		for _, f := range gittracked {
-			if trackVerboseLoggingFlag || trackDryRunFlag {
-				Print("Git LFS: touching %q", f)
+		pattern := line.Pattern.String()
+		if _, ok := imported[pattern]; !ok {
+			order = append(order, pattern)
+		}
+		imported[pattern] = lockable
+	}
+
+	var readOnlyPatterns []string
+	var writeablePatterns []string
+	for _, pattern := range order {
+		for _, known := range knownPatterns {
+			if known.Path == pattern && known.Tracked && known.Lockable == imported[pattern] {
+				Print("%q already supported", pattern)
+				delete(imported, pattern)
+				break
 			}
+		}
+		if lockable, ok := imported[pattern]; ok {
+			Print("Tracking %q", unescapeAttrPattern(pattern))
+			if lockable {
+				readOnlyPatterns = append(readOnlyPatterns, pattern)
+			} else {
+				writeablePatterns = append(writeablePatterns, pattern)
+			}
+		}
+	}
+	if len(imported) == 0 || trackDryRunFlag {
+		return
+	}
 
-			if !trackDryRunFlag {
-				now := time.Now()
-				err := os.Chtimes(f, now, now)
-				if err != nil {
-					LoggedError(err, "Error marking %q modified: %s", f, err)
-					continue
-				}
+	attribContents, err := ioutil.ReadFile(".gitattributes")
+	if err != nil && !os.IsNotExist(err) {
+		ExitWithError(errors.Wrap(err, "could not read .gitattributes"))
+	}
+
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(attribContents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 1 {
+			buf.WriteString(line + lineEnd)
+			continue
+		}
+
+		lockable, ok := imported[fields[0]]
+		if !ok {
+			buf.WriteString(line + lineEnd)
+			continue
+		}
+
+		// Keep the attributes this line sets other than those tracking
+		// the pattern.
+		kept := []string{fields[0]}
+		for _, field := range fields[1:] {
+			name := strings.TrimLeft(field, "-!")
+			if i := strings.Index(name, "="); i >= 0 {
+				name = name[:i]
+			}
+			if !trackAttribs[name] {
+				kept = append(kept, field)
 			}
 		}
+		buf.WriteString(trackAttribLine(strings.Join(kept, " "), lockable, lineEnd))
+		delete(imported, fields[0])
+	}
+
+	for _, pattern := range order {
+		if lockable, ok := imported[pattern]; ok {
+			buf.WriteString(trackAttribLine(pattern, lockable, lineEnd))
+		}
+	}
+
+	if err := ioutil.WriteFile(".gitattributes", buf.Bytes(), 0660); err != nil {
+		ExitWithError(errors.Wrap(err, "could not write .gitattributes"))
+	}
+
+	for _, pattern := range append(readOnlyPatterns, writeablePatterns...) {
+		touchTrackedFiles(pattern)
 	}
 
-	// now flip read-only mode based on lockable / not lockable changes
 	lockClient := newLockClient()
-	err = lockClient.FixFileWriteFlagsInDir(relpath, readOnlyPatterns, writeablePatterns)
+	err = lockClient.FixFileWriteFlagsInDir("", readOnlyPatterns, writeablePatterns)
 	if err != nil {
 		LoggedError(err, "Error changing lockable file permissions: %s", err)
 	}
 }
 
+// isKnownPattern returns whether "pattern" is already given in an attributes
+// file, whether or not it is tracked by Git LFS, ignoring case if "ignoreCase"
+// is set.
+func isKnownPattern(knownPatterns []git.AttributePath, pattern string, ignoreCase bool) bool {
+	for _, known := range knownPatterns {
+		if tools.PathsEqual(unescapeAttrPattern(known.Path), pattern, ignoreCase) {
+			return true
+		}
+	}
+	return false
+}
+
+// countTrackedFiles returns the number of files in the current directory
+// which are tracked by Git.
+func countTrackedFiles() int {
+	files, err := git.GetTrackedFiles("*")
+	if err != nil {
+		Exit("Error getting tracked files: %s", err)
+	}
+	return len(files)
+}
+
+// warnOverBroadPattern warns about, and returns true for, a new pattern which
+// matches more than lfs.track.warnthreshold percent of the "trackedCount"
+// files tracked by Git, or which matches source code, as tracking it is most
+// likely a mistake.
+func warnOverBroadPattern(name, pattern string, trackedCount int) bool {
+	matched, err := git.GetTrackedFiles(pattern)
+	if err != nil {
+		Exit("Error getting tracked files for %q: %s", pattern, err)
+	}
+
+	var warned bool
+	threshold := cfg.Git.Int("lfs.track.warnthreshold", defaultTrackWarnThreshold)
+	if threshold > 0 && trackedCount > 0 && len(matched) >= trackWarnMinFiles &&
+		len(matched)*100 > threshold*trackedCount {
+		Error("Pattern %q matches %d of the %d files tracked by Git (%d%%), more than lfs.track.warnthreshold (%d%%).",
+			name, len(matched), trackedCount, len(matched)*100/trackedCount, threshold)
+		warned = true
+	}
+
+	for _, f := range matched {
+		ext := strings.ToLower(filepath.Ext(f))
+		for _, source := range trackSourceExtensions {
+			if ext == source {
+				Error("Pattern %q matches source code, such as %q.", name, f)
+				return true
+			}
+		}
+	}
+	return warned
+}
+
 func listPatterns() {
 	knownPatterns := getAllKnownPatterns()
 	if len(knownPatterns) < 1 {
@@ -230,6 +690,8 @@ func listPatterns() {
 	for _, t := range knownPatterns {
 		if t.Lockable {
 			Print("    %s [lockable] (%s)", t.Path, t.Source)
+		} else if t.Tracked && t.Magic {
+			Print("    %s [by content] (%s)", t.Path, t.Source)
 		} else if t.Tracked {
 			Print("    %s (%s)", t.Path, t.Source)
 		}
@@ -335,5 +797,10 @@ func init() {
 		cmd.Flags().BoolVarP(&trackNoModifyAttrsFlag, "no-modify-attrs", "", false, "skip modifying .gitattributes file")
 		cmd.Flags().BoolVarP(&trackNoExcludedFlag, "no-excluded", "", false, "skip listing excluded paths")
 		cmd.Flags().BoolVarP(&trackFilenameFlag, "filename", "", false, "treat this pattern as a literal filename")
+		cmd.Flags().BoolVarP(&trackForceFlag, "force", "f", false, "track patterns even if they match source code or many files")
+		cmd.Flags().BoolVarP(&trackExportFlag, "export", "", false, "print the patterns tracked by Git LFS in importable form")
+		cmd.Flags().StringVarP(&trackImportFlag, "import", "", "", "track the patterns exported to the given file")
+		cmd.Flags().StringVarP(&trackAttributesFileFlag, "attributes-file", "", "", "write patterns to the given .gitattributes file")
+		cmd.Flags().BoolVarP(&trackMagicFlag, "magic", "", false, "track files matching no other pattern by their contents")
 	})
 }
@@ -1,16 +1,52 @@
 package commands
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
 	"github.com/git-lfs/git-lfs/git"
+	isatty "github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
 // uninstallCmd removes any configuration and hooks set by Git LFS.
 func uninstallCommand(cmd *cobra.Command, args []string) {
-	if err := cmdInstallOptions().Uninstall(); err != nil {
+	opt := cmdInstallOptions()
+	scope := opt.Scope()
+	before := opt.GitConfig.FindSection(scope, "filter.lfs")
+
+	// The global and system configuration are shared with every other
+	// repository, so check before changing them when there is someone to
+	// ask.
+	if !forceInstall && len(before) > 0 && (scope == "global" || scope == "system") &&
+		isatty.IsTerminal(os.Stdin.Fd()) {
+		if !confirmUninstall(os.Stdin, os.Stderr, scope, before) {
+			Exit("uninstall: nothing was changed; pass --force to skip this prompt")
+		}
+	}
+
+	var err error
+	if skipSmudgeInstall {
+		err = opt.UninstallSkipSmudge()
+	} else {
+		err = opt.Uninstall()
+	}
+	if err != nil {
 		Print("WARNING: %s", err.Error())
 	}
 
+	reportConfigChanges(before, opt.GitConfig.FindSection(scope, "filter.lfs"))
+
+	if skipSmudgeInstall {
+		if err == nil {
+			Print("Automatic downloading of Git LFS objects has been restored.")
+		}
+		return
+	}
+
 	if !skipRepoInstall && (localInstall || worktreeInstall || cfg.InRepo()) {
 		uninstallHooksCommand(cmd, args)
 	}
@@ -22,6 +58,56 @@ func uninstallCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// confirmUninstall asks whether to change the Git LFS configuration in the
+// given files, returning true only if the answer is yes.
+func confirmUninstall(in io.Reader, out io.Writer, scope string, entries []*git.ConfigEntry) bool {
+	var files []string
+	seen := make(map[string]struct{})
+	for _, entry := range entries {
+		if _, ok := seen[entry.Origin]; !ok {
+			seen[entry.Origin] = struct{}{}
+			files = append(files, entry.Origin)
+		}
+	}
+
+	answer := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "uninstall: change the %s Git LFS configuration in %s?  This affects every repository using it. [y/N] ",
+			scope, strings.Join(files, ", "))
+		s, err := answer.ReadString('\n')
+
+		switch strings.TrimSpace(s) {
+		case "y", "Y":
+			return true
+		case "n", "N", "":
+			return false
+		}
+
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// reportConfigChanges prints each configuration key which was removed or
+// changed between the entries "before" and "after", along with the file
+// containing it.
+func reportConfigChanges(before, after []*git.ConfigEntry) {
+	remaining := make(map[string]string, len(after))
+	for _, entry := range after {
+		remaining[entry.Origin+"\x00"+entry.Key] = entry.Value
+	}
+
+	for _, entry := range before {
+		value, ok := remaining[entry.Origin+"\x00"+entry.Key]
+		if !ok {
+			Print("Removed %s from %s", entry.Key, entry.Origin)
+		} else if value != entry.Value {
+			Print("Updated %s in %s", entry.Key, entry.Origin)
+		}
+	}
+}
+
 // uninstallHooksCmd removes any hooks created by Git LFS.
 func uninstallHooksCommand(cmd *cobra.Command, args []string) {
 	if err := uninstallHooks(); err != nil {
@@ -39,6 +125,8 @@ func init() {
 		}
 		cmd.Flags().BoolVarP(&systemInstall, "system", "", false, "Remove the Git LFS config in system-wide scope.")
 		cmd.Flags().BoolVarP(&skipRepoInstall, "skip-repo", "", false, "Skip repo setup, just uninstall global filters.")
+		cmd.Flags().BoolVarP(&skipSmudgeInstall, "skip-smudge", "s", false, "Only undo `git lfs install --skip-smudge`, restoring automatic downloading of objects.")
+		cmd.Flags().BoolVarP(&forceInstall, "force", "f", false, "Change the global or system config without asking first.")
 		cmd.AddCommand(NewCommand("hooks", uninstallHooksCommand))
 	})
 }
@@ -2,11 +2,17 @@ package commands
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/locking"
+	"github.com/git-lfs/git-lfs/tools"
+	isatty "github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
@@ -22,14 +28,21 @@ type unlockFlags struct {
 	// with "--force", signifying the user's intent to break another
 	// individual's lock(s).
 	Force bool
+	// Owner is the user whose locks are being unlocked, at the paths
+	// matching the patterns given, rather than at the paths given.
+	Owner string
 }
 
-var unlockUsage = "Usage: git lfs unlock (--id my-lock-id | <path>...)"
+var unlockUsage = "Usage: git lfs unlock (--id my-lock-id | [--] <path>... | --owner <user> [--] [<pattern>...])"
 
 func unlockCommand(cmd *cobra.Command, args []string) {
 	hasPath := len(args) > 0
 	hasId := len(unlockCmdFlags.Id) > 0
-	if hasPath == hasId {
+	hasOwner := len(unlockCmdFlags.Owner) > 0
+	if hasOwner && (hasId || lockRecursive) {
+		Exit(unlockUsage)
+	}
+	if hasPath == hasId && !hasOwner {
 		// If there is both an `--id` AND a `<path>`, or there is
 		// neither, print the usage and quit.
 		Exit(unlockUsage)
@@ -44,46 +57,60 @@ func unlockCommand(cmd *cobra.Command, args []string) {
 	lockClient.RemoteRef = refUpdate.Right()
 	defer lockClient.Close()
 
-	if hasPath {
-		paths := make([]string, len(args))
-		var err error
-		for i, path := range args {
-			paths[i], err = lockPath(path)
+	if hasOwner {
+		unlockOwnerLocks(lockClient, args)
+		return
+	} else if hasPath {
+		paths := make([]string, 0, len(args))
+		for _, arg := range args {
+			path, err := unlockArgPath(arg)
+			if _, ok := err.(*lockDirectoryError); ok && lockRecursive {
+				files, err := unlockDirectoryLocks(lockClient, path)
+				if err != nil {
+					Exit("Unable to list locks: %v", errors.Cause(err))
+				}
+				if len(files) == 0 {
+					Exit("lfs: no locks in directory: %s", arg)
+				}
+				paths = append(paths, files...)
+				continue
+			}
 			if err != nil {
 				if !unlockCmdFlags.Force {
 					Exit("Unable to determine path: %v", err.Error())
 				}
-				paths[i] = path
+				path = arg
 			}
 
 			// This call can early-out
-			unlockAbortIfFileModified(paths[i])
+			unlockAbortIfFileModified(path)
+			paths = append(paths, path)
 		}
 
-		locks, err := lockClient.UnlockMultipleFiles(paths, unlockCmdFlags.Force)
-		if err != nil {
-			Error("%s", errors.Cause(err))
+		if root, err := git.RootDir(); err == nil && cfg.IgnoreCase() {
+			paths = resolveDeletedLockPaths(lockClient, root, paths)
 		}
 
-		if !locksCmdFlags.JSON {
-			for _, lock := range locks {
-				Print("Unlocked %s", lock.Path)
-			}
+		var locks []locking.Lock
+		var err error
+		if lockRecursive {
+			locks, err = lockInBatches("unlock: Unlocking files", len(paths), func(i, j int) ([]locking.Lock, error) {
+				return lockClient.UnlockMultipleFiles(paths[i:j], unlockCmdFlags.Force)
+			})
 		} else {
-			encoder := json.NewEncoder(os.Stdout)
-
-			type lockData struct {
-				locking.Lock
-				Unlocked bool `json:"unlocked"`
-			}
-			for _, lock := range locks {
-				if err := encoder.Encode(lockData{lock, true}); err != nil {
-					Error(err.Error())
-				}
-			}
+			locks, err = lockClient.UnlockMultipleFiles(paths, unlockCmdFlags.Force)
+		}
+		if err != nil {
+			Error("%s", errors.Cause(err))
+		}
+		reportUnlocked(locks)
+		if !locksCmdFlags.JSON && lockRecursive {
+			Print("%d file(s) unlocked, %d failed", len(locks), len(paths)-len(locks))
 		}
 
 		if err != nil {
+			// Save any cached locks found to be stale before exiting.
+			lockClient.Close()
 			os.Exit(2)
 		}
 		return
@@ -93,8 +120,10 @@ func unlockCommand(cmd *cobra.Command, args []string) {
 
 		err := lockClient.UnlockFileById(unlockCmdFlags.Id, unlockCmdFlags.Force)
 		if err != nil {
+			lockClient.Close()
 			Exit("Unable to unlock %v: %v", unlockCmdFlags.Id, errors.Cause(err))
 		}
+		emitEvent(&events.Event{Type: events.LockReleased, LockID: unlockCmdFlags.Id})
 
 		if !locksCmdFlags.JSON {
 			Print("Unlocked Lock %s", unlockCmdFlags.Id)
@@ -112,6 +141,150 @@ func unlockCommand(cmd *cobra.Command, args []string) {
 	return
 }
 
+// unlockOwnerLocks unlocks the locks held by the user given by --owner at the
+// paths matching "patterns", or at any path if none are given, in batches.
+// Locks on files which still exist are checked for uncommitted changes, as for
+// the paths given on the command line.
+func unlockOwnerLocks(lockClient *locking.Client, patterns []string) {
+	owner := unlockCmdFlags.Owner
+	locks, err := ownedLocks(lockClient, owner, patterns)
+	if err != nil {
+		Exit("Unable to list locks: %v", errors.Cause(err))
+	}
+	if len(locks) == 0 {
+		Exit("lfs: no matching locks %s", lockHolder(owner))
+	}
+
+	root, err := git.RootDir()
+	if err != nil {
+		Exit(err.Error())
+	}
+	for _, lock := range locks {
+		if _, err := os.Stat(filepath.Join(root, lock.Path)); err == nil {
+			// This call can early-out
+			unlockAbortIfFileModified(lock.Path)
+		}
+	}
+
+	question := fmt.Sprintf("unlock these %d lock(s)?", len(locks))
+	if !lockYes && isatty.IsTerminal(os.Stdin.Fd()) &&
+		!confirmLocks(os.Stdin, os.Stderr, "unlock", lockHolder(owner), question, locks) {
+		Exit("unlock: no locks were unlocked; pass --yes to skip this prompt")
+	}
+
+	unlocked, err := lockInBatches("unlock: Unlocking files", len(locks), func(i, j int) ([]locking.Lock, error) {
+		return lockClient.UnlockMultipleLocks(locks[i:j], unlockCmdFlags.Force)
+	})
+	if err != nil {
+		Error("%s", errors.Cause(err))
+	}
+	reportUnlocked(unlocked)
+	if !locksCmdFlags.JSON {
+		Print("%d lock(s) unlocked, %d failed", len(unlocked), len(locks)-len(unlocked))
+	}
+
+	if err != nil {
+		// Save any cached locks found to be stale before exiting.
+		lockClient.Close()
+		os.Exit(2)
+	}
+}
+
+// reportUnlocked emits an event for each of the locks "locks", which have been
+// unlocked, and prints them, as JSON if --json was given.
+func reportUnlocked(locks []locking.Lock) {
+	for _, lock := range locks {
+		emitEvent(&events.Event{Type: events.LockReleased, Path: lock.Path, LockID: lock.Id})
+	}
+
+	if !locksCmdFlags.JSON {
+		for _, lock := range locks {
+			Print("Unlocked %s", lock.Path)
+		}
+		return
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	type lockData struct {
+		locking.Lock
+		Unlocked bool `json:"unlocked"`
+	}
+	for _, lock := range locks {
+		if err := encoder.Encode(lockData{lock, true}); err != nil {
+			Error(err.Error())
+		}
+	}
+}
+
+// unlockDirectoryLocks returns the paths of the locks beneath the directory
+// "dir", as "git lfs unlock --recursive" unlocks: those of the current user,
+// or with --force, those of anyone. Locks on files which have since been
+// deleted are included, and those on files which still exist are checked for
+// uncommitted changes, as for the paths given on the command line.
+func unlockDirectoryLocks(lockClient *locking.Client, dir string) ([]string, error) {
+	root, err := git.RootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []locking.Lock
+	if unlockCmdFlags.Force {
+		locks, err = lockClient.SearchLocks(nil, 0, false, false)
+	} else {
+		locks, _, err = lockClient.SearchLocksVerifiable(0, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, lock := range locks {
+		if !lockPathBeneath(lock.Path, dir) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, lock.Path)); err == nil {
+			// This call can early-out
+			unlockAbortIfFileModified(lock.Path)
+		}
+		paths = append(paths, lock.Path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// resolveDeletedLockPaths returns "paths" with those of files which no longer
+// exist replaced by the path of the lock on the server which matches them
+// regardless of case, since the filesystem under "root" ignores case, and
+// without the file, the case it was locked with can't be found. Paths which
+// match no such lock, or more than one, are left as they are.
+func resolveDeletedLockPaths(lockClient *locking.Client, root string, paths []string) []string {
+	var locks []locking.Lock
+	for i, path := range paths {
+		if _, err := os.Stat(filepath.Join(root, path)); !os.IsNotExist(err) {
+			continue
+		}
+
+		if locks == nil {
+			var err error
+			if locks, err = lockClient.SearchLocks(nil, 0, false, false); err != nil {
+				return paths
+			}
+		}
+
+		var matches []string
+		for _, lock := range locks {
+			if tools.PathsEqual(lock.Path, path, true) {
+				matches = append(matches, lock.Path)
+			}
+		}
+		if len(matches) == 1 {
+			paths[i] = matches[0]
+		}
+	}
+	return paths
+}
+
 func unlockAbortIfFileModified(path string) {
 	modified, err := git.IsFileModified(path)
 
@@ -163,5 +336,9 @@ func init() {
 		cmd.Flags().StringVarP(&unlockCmdFlags.Id, "id", "i", "", "unlock a lock by its ID")
 		cmd.Flags().BoolVarP(&unlockCmdFlags.Force, "force", "f", false, "forcibly break another user's lock(s)")
 		cmd.Flags().BoolVarP(&locksCmdFlags.JSON, "json", "", false, "print output in json")
+		cmd.Flags().BoolVarP(&lockRootRelative, "root-relative", "", false, "interpret paths relative to the root of the repository")
+		cmd.Flags().BoolVarP(&lockRecursive, "recursive", "", false, "unlock the locks beneath the directories given")
+		cmd.Flags().StringVarP(&unlockCmdFlags.Owner, "owner", "", "", "unlock the locks held by this user at the paths matching the patterns given")
+		cmd.Flags().BoolVarP(&lockYes, "yes", "y", false, "with --owner, don't ask before unlocking")
 	})
 }
@@ -0,0 +1,425 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/lfs"
+	"github.com/git-lfs/git-lfs/tasklog"
+	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tq"
+	"github.com/rubyist/tracerx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyRef     = "HEAD"
+	verifyContent = false
+	verifyJSON    = false
+	verifyLocal   = false
+	verifyAll     = false
+	verifyFix     = false
+)
+
+// verifyProblem is an object referenced by the tree being verified which the
+// remote doesn't hold as its pointer describes, as listed by "git lfs verify
+// --json".
+type verifyProblem struct {
+	Name  string `json:"name"`
+	Oid   string `json:"oid"`
+	Size  int64  `json:"size"`
+	Error string `json:"error"`
+
+	// Fixed is whether a corrupt local object was replaced by a good copy
+	// from the remote, as with "git lfs verify --fix".
+	Fixed bool `json:"fixed,omitempty"`
+}
+
+func verifyCommand(cmd *cobra.Command, args []string) {
+	setupRepository()
+
+	local := verifyLocal || verifyAll || verifyFix
+	if verifyAll && cmd.Flags().Changed("ref") {
+		Exit("fatal: --all and --ref cannot be combined")
+	}
+
+	if len(args) > 0 {
+		if local {
+			// The local objects are those of a ref, which is the
+			// first arg, as with --ref.
+			if verifyAll {
+				Exit("fatal: --all and a ref cannot be combined")
+			}
+			if cmd.Flags().Changed("ref") {
+				Exit("fatal: --ref and a ref argument cannot be combined")
+			}
+			verifyRef = args[0]
+		} else if err := cfg.SetValidRemote(args[0]); err != nil {
+			// Remote is first arg
+			if _, rerr := git.ResolveRef(args[0]); rerr == nil {
+				Exit("Invalid remote name %q: %s\nTo check the local objects of the ref %q, run 'git lfs verify --local %s'.", args[0], err, args[0], args[0])
+			}
+			Exit("Invalid remote name %q: %s", args[0], err)
+		}
+	}
+	remote := cfg.Remote()
+
+	if local {
+		verifyLocalObjects(remote)
+		return
+	}
+
+	ref, err := git.ResolveRef(verifyRef)
+	if err != nil {
+		Exit("fatal: could not resolve %q: %s", verifyRef, err)
+	}
+
+	// Each object is verified once, by the first path it's found at,
+	// however many files refer to it.
+	var pointers []*lfs.WrappedPointer
+	seen := make(map[string]struct{})
+	gitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
+		if err != nil {
+			Exit("Could not scan for Git LFS tree: %s", err)
+		}
+		if _, ok := seen[p.Oid]; ok {
+			return
+		}
+		seen[p.Oid] = struct{}{}
+		pointers = append(pointers, p)
+	})
+	if err := gitscanner.ScanTree(ref.Sha); err != nil {
+		Exit("Could not scan for Git LFS tree: %s", err)
+	}
+	gitscanner.Close()
+
+	failed := make(map[string]error)
+	content := verifyContent
+	manifest := getTransferManifestOperationRemote("download", remote)
+	if manifest.IsStandaloneTransfer() {
+		// A standalone transfer agent can't be asked which objects
+		// it has, so each is downloaded instead.
+		tracerx.Printf("verify: downloading %d object(s) from a standalone transfer agent", len(pointers))
+		content = true
+	} else if len(pointers) > 0 {
+		transfers := make([]*tq.Transfer, 0, len(pointers))
+		for _, p := range pointers {
+			transfers = append(transfers, &tq.Transfer{Name: p.Name, Oid: p.Oid, Size: p.Size})
+		}
+
+		missing, err := tq.FindMissing(manifest, remote, ref, transfers)
+		if err != nil {
+			ExitWithError(errors.Wrap(err, "could not query the remote for the objects to verify"))
+		}
+		for _, m := range missing {
+			failed[m.Oid] = m.Err
+		}
+	}
+
+	if content {
+		verifyDownloads(manifest, remote, ref, pointers, failed)
+	}
+
+	problems := make([]*verifyProblem, 0, len(failed))
+	for _, p := range pointers {
+		if err, ok := failed[p.Oid]; ok {
+			problems = append(problems, &verifyProblem{Name: p.Name, Oid: p.Oid, Size: p.Size, Error: err.Error()})
+		}
+	}
+
+	if verifyJSON {
+		encoded, err := json.Marshal(struct {
+			Ref      string           `json:"ref"`
+			Commit   string           `json:"commit"`
+			Remote   string           `json:"remote"`
+			Content  bool             `json:"content"`
+			Checked  int              `json:"checked"`
+			Problems []*verifyProblem `json:"problems"`
+		}{verifyRef, ref.Sha, remote, content, len(pointers), problems})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+	} else {
+		for _, p := range problems {
+			Print("verify: %s %s: %s", p.Oid, p.Name, p.Error)
+		}
+		Print("verify: %d object(s) referenced by %s checked against %q", len(pointers), verifyRef, remote)
+	}
+
+	if len(problems) > 0 {
+		Exit("verify: %d object(s) do not match their pointers", len(problems))
+	}
+	if !verifyJSON {
+		Print("Git LFS verify OK")
+	}
+}
+
+// verifyDownloads downloads each of the objects of "pointers" from "remote",
+// except those already in "failed", which is given the error for each which
+// can't be downloaded, or whose contents don't hash to its OID. The objects
+// are downloaded to a temporary directory, rather than the local object store,
+// so that the objects held by the remote are checked even when they are
+// present locally.
+func verifyDownloads(manifest *tq.Manifest, remote string, ref *git.Ref, pointers []*lfs.WrappedPointer, failed map[string]error) {
+	dir, err := ioutil.TempDir(cfg.TempDir(), "verify")
+	if err != nil {
+		ExitWithError(errors.Wrap(err, "could not create a directory for the objects to verify"))
+	}
+	defer os.RemoveAll(dir)
+
+	logger := tasklog.NewLogger(os.Stderr,
+		tasklog.ForceProgress(cfg.ForceProgress()),
+	)
+	meter := buildProgressMeter(false, tq.Download)
+	logger.Enqueue(meter)
+
+	q := tq.NewTransferQueue(tq.Download, manifest, remote,
+		tq.RemoteRef(ref), tq.WithProgress(meter))
+	for _, p := range pointers {
+		if _, ok := failed[p.Oid]; ok {
+			continue
+		}
+		meter.Add(p.Size)
+		q.Add(p.Name, filepath.Join(dir, p.Oid), p.Oid, p.Size, false, nil)
+	}
+	q.Wait()
+
+	for _, f := range q.FailedTransfers() {
+		failed[f.Oid] = f.Err
+	}
+}
+
+// verifyLocalObjects re-hashes the objects in the local object store, either
+// those of the files in the tree of verifyRef, or with verifyAll, every object
+// the store holds, and reports each whose contents don't hash to its OID. With
+// verifyFix, each such object is deleted, and downloaded again from "remote".
+func verifyLocalObjects(remote string) {
+	var ref *git.Ref
+	var pointers []*lfs.WrappedPointer
+	var missing int
+	if verifyAll {
+		// The pointers found anywhere in the history give the names
+		// and sizes of the objects, which an object in the store with
+		// the wrong contents can't be relied on for.
+		found := make(map[string]*lfs.WrappedPointer)
+		gitscanner := lfs.NewGitScanner(cfg, nil)
+		err := gitscanner.ScanAll(func(p *lfs.WrappedPointer, err error) {
+			if err != nil {
+				Exit("Could not scan for Git LFS files: %s", err)
+			}
+			if _, ok := found[p.Oid]; !ok {
+				found[p.Oid] = p
+			}
+		})
+		if err != nil {
+			Exit("Could not scan for Git LFS files: %s", err)
+		}
+		gitscanner.Close()
+
+		cfg.EachLFSObject(func(obj fs.Object) error {
+			p, ok := found[obj.Oid]
+			if !ok {
+				p = &lfs.WrappedPointer{Pointer: &lfs.Pointer{Oid: obj.Oid, Size: -1}}
+			}
+			pointers = append(pointers, p)
+			return nil
+		})
+	} else {
+		var err error
+		ref, err = git.ResolveRef(verifyRef)
+		if err != nil {
+			Exit("fatal: could not resolve %q: %s", verifyRef, err)
+		}
+
+		seen := make(map[string]struct{})
+		gitscanner := lfs.NewGitScanner(cfg, func(p *lfs.WrappedPointer, err error) {
+			if err != nil {
+				Exit("Could not scan for Git LFS tree: %s", err)
+			}
+			if _, ok := seen[p.Oid]; ok {
+				return
+			}
+			seen[p.Oid] = struct{}{}
+
+			// Objects which haven't been fetched, as with
+			// lfs.fetchexclude, are counted, but aren't a problem.
+			if !tools.FileExists(cfg.Filesystem().ObjectReadPathname(p.Oid, -1)) {
+				missing++
+				return
+			}
+			pointers = append(pointers, p)
+		})
+		if err := gitscanner.ScanTree(ref.Sha); err != nil {
+			Exit("Could not scan for Git LFS tree: %s", err)
+		}
+		gitscanner.Close()
+	}
+
+	var problems []*verifyProblem
+	for _, p := range pointers {
+		if err := verifyLocalObject(p); err != nil {
+			problems = append(problems, &verifyProblem{Name: p.Name, Oid: p.Oid, Size: p.Size, Error: err.Error()})
+		}
+	}
+
+	if verifyFix && len(problems) > 0 {
+		verifyFixObjects(remote, ref, problems)
+	}
+
+	var unfixed int
+	for _, p := range problems {
+		if !p.Fixed {
+			unfixed++
+		}
+	}
+
+	if verifyJSON {
+		var name, commit string
+		if ref != nil {
+			name, commit = verifyRef, ref.Sha
+		}
+		if problems == nil {
+			problems = []*verifyProblem{}
+		}
+		encoded, err := json.Marshal(struct {
+			Ref      string           `json:"ref,omitempty"`
+			Commit   string           `json:"commit,omitempty"`
+			All      bool             `json:"all"`
+			Checked  int              `json:"checked"`
+			Missing  int              `json:"missing"`
+			Problems []*verifyProblem `json:"problems"`
+		}{name, commit, verifyAll, len(pointers), missing, problems})
+		if err != nil {
+			ExitWithError(err)
+		}
+		Print(string(encoded))
+	} else {
+		for _, p := range problems {
+			// An object no Git LFS file refers to has no name.
+			label := p.Oid
+			if len(p.Name) > 0 {
+				label += " " + p.Name
+			}
+			if p.Fixed {
+				Print("verify: %s: %s (fixed)", label, p.Error)
+			} else {
+				Print("verify: %s: %s", label, p.Error)
+			}
+		}
+		if verifyAll {
+			Print("verify: %d object(s) in the local store checked", len(pointers))
+		} else {
+			Print("verify: %d object(s) referenced by %s checked in the local store, %d not present", len(pointers), verifyRef, missing)
+		}
+	}
+
+	if unfixed > 0 {
+		Exit("verify: %d local object(s) are corrupt", unfixed)
+	}
+	if !verifyJSON {
+		Print("Git LFS verify OK")
+	}
+}
+
+// verifyLocalObject returns an error describing how the local object of "p"
+// doesn't match its OID, or its size, if known, or nil if it matches both.
+func verifyLocalObject(p *lfs.WrappedPointer) error {
+	path := cfg.Filesystem().ObjectReadPathname(p.Oid, -1)
+	tracerx.Printf("verify: re-hashing %s (%s)", p.Oid, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+
+	if oid := hex.EncodeToString(hash.Sum(nil)); oid != p.Oid {
+		if p.Size >= 0 && n != p.Size {
+			return fmt.Errorf("object is %d byte(s), expected %d", n, p.Size)
+		}
+		return fmt.Errorf("object's contents hash to %s", oid)
+	}
+	return nil
+}
+
+// verifyFixObjects deletes the corrupt local objects of "problems", and
+// downloads each again from "remote", marking those which are then fixed.
+// Objects in an alternate object directory, which is read-only, and objects
+// which no pointer gives the size of, are left as they are.
+func verifyFixObjects(remote string, ref *git.Ref, problems []*verifyProblem) {
+	logger := tasklog.NewLogger(os.Stderr,
+		tasklog.ForceProgress(cfg.ForceProgress()),
+	)
+	meter := buildProgressMeter(false, tq.Download)
+	logger.Enqueue(meter)
+
+	var opts []tq.Option
+	if ref != nil {
+		opts = append(opts, tq.RemoteRef(ref))
+	}
+	opts = append(opts, tq.WithProgress(meter))
+	q := tq.NewTransferQueue(tq.Download, getTransferManifestOperationRemote("download", remote), remote, opts...)
+
+	queued := make(map[string]*verifyProblem)
+	for _, p := range problems {
+		path := cfg.Filesystem().ObjectPathname(p.Oid)
+		if !tools.FileExists(path) {
+			p.Error += "; not fixed, since it is in an alternate object directory"
+			continue
+		}
+		if p.Size < 0 {
+			p.Error += "; not fixed, since no Git LFS file refers to it"
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			p.Error += fmt.Sprintf("; not fixed: %s", err)
+			continue
+		}
+		tracerx.Printf("verify: deleted %s, downloading it again from %q", p.Oid, remote)
+
+		queued[p.Oid] = p
+		meter.Add(p.Size)
+		q.Add(p.Name, path, p.Oid, p.Size, false, nil)
+	}
+	q.Wait()
+
+	failed := make(map[string]error)
+	for _, f := range q.FailedTransfers() {
+		failed[f.Oid] = f.Err
+	}
+	for oid, p := range queued {
+		if err, ok := failed[oid]; ok {
+			p.Error += fmt.Sprintf("; deleted, but could not be downloaded again: %s", err)
+			continue
+		}
+		p.Fixed = true
+	}
+}
+
+func init() {
+	RegisterCommand("verify", verifyCommand, func(cmd *cobra.Command) {
+		cmd.Flags().StringVarP(&verifyRef, "ref", "", "HEAD", "Verify the objects referenced by the tree of this ref.")
+		cmd.Flags().BoolVarP(&verifyContent, "content", "", false, "Download each object and check that its contents match its OID.")
+		cmd.Flags().BoolVarP(&verifyJSON, "json", "", false, "Write the result as JSON.")
+		cmd.Flags().BoolVarP(&verifyLocal, "local", "", false, "Re-hash the objects in the local object store, rather than checking the remote.")
+		cmd.Flags().BoolVarP(&verifyAll, "all", "", false, "Re-hash every object in the local object store.")
+		cmd.Flags().BoolVarP(&verifyFix, "fix", "", false, "Delete corrupt local objects and download them again from the remote.")
+	})
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
@@ -22,6 +23,7 @@ import (
 	"github.com/git-lfs/git-lfs/subprocess"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/git-lfs/git-lfs/tq"
+	"github.com/rubyist/tracerx"
 )
 
 // Populate man pages
@@ -39,6 +41,16 @@ var (
 	apiClient *lfsapi.Client
 	global    sync.Mutex
 
+	// metricsFile is the path given by --metrics-file, and
+	// transferMetrics the metrics written there, if any, which are shared
+	// by all transfer manifests.
+	metricsFile     string
+	transferMetrics *tq.Metrics
+
+	// deltaBases finds the bases offered by the delta transfer adapters of
+	// all transfer manifests.
+	deltaBases = &deltaBaseFinder{}
+
 	oldEnv = make(map[string]string)
 
 	includeArg string
@@ -63,11 +75,88 @@ func getTransferManifestOperationRemote(operation, remote string) *tq.Manifest {
 	k := fmt.Sprintf("%s.%s", operation, remote)
 	if tqManifest[k] == nil {
 		tqManifest[k] = tq.NewManifest(cfg.Filesystem(), c, operation, remote)
+		if m := getTransferMetrics(); m != nil {
+			tqManifest[k].SetMetrics(m)
+		}
+		tqManifest[k].SetDeltaBases(deltaBases.find)
 	}
 
 	return tqManifest[k]
 }
 
+// getTransferMetrics returns the metrics to record transfers in, if a file
+// was given to write them to with --metrics-file or GIT_LFS_METRICS_FILE, or
+// nil otherwise. The caller must hold the global lock.
+func getTransferMetrics() *tq.Metrics {
+	if transferMetrics == nil {
+		path := metricsFile
+		if len(path) == 0 {
+			path, _ = cfg.Os.Get("GIT_LFS_METRICS_FILE")
+		}
+		if len(path) > 0 {
+			transferMetrics = tq.NewMetrics(path)
+		}
+	}
+	return transferMetrics
+}
+
+// maxDeltaBases is the most objects offered as the bases of a delta transfer.
+const maxDeltaBases = 4
+
+// deltaBaseFinder finds the bases the delta transfer adapter offers, from the
+// versions of each file in the history of all refs, which it walks only once
+// for all of the objects transferred.
+type deltaBaseFinder struct {
+	once sync.Once
+
+	// versions holds the most recent versions of each file, newest first.
+	versions map[string][]*lfs.WrappedPointer
+}
+
+// find returns the OIDs of the objects of the most recent other versions of
+// the file at "name", which are present locally, as the bases for "oid".
+func (f *deltaBaseFinder) find(name, oid string) []string {
+	f.once.Do(f.scan)
+
+	var bases []string
+	for _, p := range f.versions[name] {
+		if len(bases) == maxDeltaBases {
+			break
+		}
+		if p.Oid != oid && cfg.LFSObjectExists(p.Oid, p.Size) {
+			bases = append(bases, p.Oid)
+		}
+	}
+	return bases
+}
+
+// scan walks the history of all refs, and keeps the most recent versions of
+// each file. The version being transferred is usually among them, so one more
+// is kept than the bases wanted.
+func (f *deltaBaseFinder) scan() {
+	f.versions = make(map[string][]*lfs.WrappedPointer)
+	seen := make(map[string]map[string]bool)
+
+	gitscanner := lfs.NewGitScanner(cfg, nil)
+	defer gitscanner.Close()
+
+	err := gitscanner.ScanAllVersions(func(p *lfs.WrappedPointer, err error) {
+		if err != nil || len(f.versions[p.Name]) > maxDeltaBases {
+			return
+		}
+		if seen[p.Name] == nil {
+			seen[p.Name] = make(map[string]bool)
+		}
+		if !seen[p.Name][p.Oid] {
+			seen[p.Name][p.Oid] = true
+			f.versions[p.Name] = append(f.versions[p.Name], p)
+		}
+	})
+	if err != nil {
+		tracerx.Printf("delta: could not scan for the versions of files: %s", err)
+	}
+}
+
 func getAPIClient() *lfsapi.Client {
 	global.Lock()
 	defer global.Unlock()
@@ -110,6 +199,12 @@ func newLockClient() *locking.Client {
 	return lockClient
 }
 
+// emitEvent writes the given event to the stream named by GIT_LFS_EVENTS_FD,
+// or to standard error for --json-progress, if one is configured.
+func emitEvent(ev *events.Event) {
+	events.FromEnvironment(cfg.Os).Emit(ev)
+}
+
 // newDownloadCheckQueue builds a checking queue, checks that objects are there but doesn't download
 func newDownloadCheckQueue(manifest *tq.Manifest, remote string, options ...tq.Option) *tq.TransferQueue {
 	return newDownloadQueue(manifest, remote, append(options,
@@ -528,11 +623,32 @@ func determineIncludeExcludePaths(config *config.Configuration, includeArg, excl
 func buildProgressMeter(dryRun bool, d tq.Direction) *tq.Meter {
 	m := tq.NewMeter(cfg)
 	m.Logger = m.LoggerFromEnv(cfg.Os)
+	m.Events = events.FromEnvironment(cfg.Os)
 	m.DryRun = dryRun
+	m.Quiet = jsonProgress()
 	m.Direction = d
 	return m
 }
 
+// jsonProgressArg is the --json-progress option of the commands which transfer
+// or check out objects.
+var jsonProgressArg bool
+
+// setupJSONProgress makes the event stream write to standard error if
+// --json-progress was given. It must be called before any transfers are begun.
+func setupJSONProgress() {
+	if jsonProgressArg {
+		events.SetDefault(events.NewEmitter(os.Stderr))
+	}
+}
+
+// jsonProgress returns whether progress is given as a stream of JSON events on
+// standard error in place of the progress meter, for --json-progress or
+// GIT_LFS_PROGRESS_FORMAT=json.
+func jsonProgress() bool {
+	return jsonProgressArg || events.JSONProgress(cfg.Os)
+}
+
 func requireGitVersion() {
 	minimumGit := "1.8.2"
 
@@ -2,13 +2,16 @@ package commands
 
 import (
 	"fmt"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/lfshttp"
 	"github.com/git-lfs/git-lfs/locking"
 	"github.com/git-lfs/git-lfs/tq"
@@ -22,27 +25,119 @@ const (
 	verifyStateDisabled
 )
 
+// lockServerUnreachable returns whether "err" is the failure to reach the
+// server at all, rather than an error it responded with.
+func lockServerUnreachable(err error) bool {
+	_, ok := errors.Cause(err).(*url.Error)
+	return ok
+}
+
 func verifyLocksForUpdates(lv *lockVerifier, updates []*git.RefUpdate) {
 	for _, update := range updates {
 		lv.Verify(update.Right())
 	}
 }
 
+const (
+	// lockVerifyBlockOff leaves locked files to be reported once the
+	// objects being pushed have been scanned.
+	lockVerifyBlockOff = "off"
+	// lockVerifyBlockWarn warns of every locked file changed by the
+	// commits being pushed, before anything is uploaded.
+	lockVerifyBlockWarn = "warn"
+	// lockVerifyBlockBlock stops the push, before anything is uploaded, if
+	// its commits change any file locked by someone else.
+	lockVerifyBlockBlock = "block"
+)
+
+// lockVerifyBlock returns what a push does about the files changed by its
+// commits which others have locked, as set by lfs.lockverify.block.
+func lockVerifyBlock() string {
+	value, ok := cfg.Git.Get("lfs.lockverify.block")
+	if !ok {
+		return lockVerifyBlockOff
+	}
+
+	switch v := strings.ToLower(value); v {
+	case lockVerifyBlockOff, lockVerifyBlockWarn, lockVerifyBlockBlock:
+		return v
+	}
+	Error("Invalid lfs.lockverify.block value %q, expected %q, %q or %q", value,
+		lockVerifyBlockWarn, lockVerifyBlockBlock, lockVerifyBlockOff)
+	return lockVerifyBlockOff
+}
+
+// verifyLockedPaths checks every path changed by the commits of "updates"
+// which the remote doesn't have, and which aren't reachable from "bases",
+// against the locks others hold, as lfs.lockverify.block asks. Unlike the
+// checks made as objects are uploaded, this includes files which aren't
+// stored with Git LFS, and files which were deleted or renamed.
+func verifyLockedPaths(lv *lockVerifier, g *lfs.GitScanner, updates []*git.RefUpdate, bases []string) error {
+	mode := lockVerifyBlock()
+	if mode == lockVerifyBlockOff || lv.verifyState == verifyStateDisabled || len(lv.theirLocks) == 0 {
+		return nil
+	}
+
+	var lines []string
+	seen := make(map[string]bool)
+	for _, update := range updates {
+		err := g.ScanChangedPathsToRemote(update.LeftCommitish(), bases, func(path, sha string) {
+			lock, ok := lv.theirLocks[path]
+			if !ok || seen[path] {
+				return
+			}
+			seen[path] = true
+
+			if len(sha) > 10 {
+				sha = sha[:10]
+			}
+			lines = append(lines, fmt.Sprintf("* %s - %s, changed in %s", path, lock.Owners(), sha))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	Print("Pushing changes to files locked by others:")
+	for _, line := range lines {
+		Print(line)
+	}
+
+	if mode == lockVerifyBlockBlock {
+		Exit("ERROR: Cannot push changes to locked files. Ask their owners to unlock them, or set lfs.lockverify.block to %q to push anyway.", lockVerifyBlockWarn)
+	}
+	Error("WARNING: The above files are locked by others, and the server may reject this push.")
+	return nil
+}
+
 // lockVerifier verifies locked files before updating one or more refs.
+//
+// A lockVerifier is scoped to a single push: the locks of each remote ref are
+// listed at most once, and are then used to answer every per-file check until
+// Close is called.
 type lockVerifier struct {
 	endpoint     lfshttp.Endpoint
 	verifyState  verifyState
 	verifiedRefs map[string]bool
 
+	// lockClient is shared by all verifications in this push, and is
+	// created when the first ref is verified.
+	lockClient *locking.Client
+
 	// all existing locks
 	ourLocks   map[string]*refLock
 	theirLocks map[string]*refLock
 
 	// locks from ourLocks that have been modified
 	ownedLocks []*refLock
+	ownedPaths map[string]bool
 
 	// locks from theirLocks that have been modified
 	unownedLocks []*refLock
+	unownedPaths map[string]bool
 }
 
 func (lv *lockVerifier) Verify(ref *git.Ref) {
@@ -54,9 +149,25 @@ func (lv *lockVerifier) Verify(ref *git.Ref) {
 		return
 	}
 
-	lockClient := newLockClient()
-	lockClient.RemoteRef = ref
-	ours, theirs, err := lockClient.SearchLocksVerifiable(0, false)
+	if lv.lockClient == nil {
+		lv.lockClient = newLockClient()
+	}
+	lv.lockClient.RemoteRef = ref
+	ours, theirs, err := lv.lockClient.SearchLocksVerifiable(0, false)
+	cached := false
+	if err != nil && lockServerUnreachable(err) {
+		// Verify against the locks last listed from the server, if
+		// any, rather than not at all.
+		if cachedOurs, cachedTheirs, cerr := lv.lockClient.SearchLocksVerifiable(0, true); cerr == nil {
+			Error("WARNING: Unable to reach remote %q to verify locks: %v", cfg.PushRemote(), errors.Cause(err))
+			if at, cerr := lv.lockClient.LocksCachedAt(true); cerr == nil {
+				Error("WARNING: Verifying against the locks cached at %s (%s ago), which may be out of date",
+					at.Format(time.RFC3339), time.Since(at).Round(time.Second))
+			}
+			ours, theirs, err = cachedOurs, cachedTheirs, nil
+			cached = true
+		}
+	}
 	if err != nil {
 		if errors.IsNotImplementedError(err) {
 			disableFor(lv.endpoint.Url)
@@ -75,7 +186,7 @@ func (lv *lockVerifier) Verify(ref *git.Ref) {
 				}
 			}
 		}
-	} else if lv.verifyState == verifyStateUnknown {
+	} else if lv.verifyState == verifyStateUnknown && !cached {
 		Print("Locking support detected on remote %q. Consider enabling it with:", cfg.PushRemote())
 		Print("  $ git config lfs.%s.locksverify true", lv.endpoint.Url)
 	}
@@ -85,6 +196,22 @@ func (lv *lockVerifier) Verify(ref *git.Ref) {
 	lv.verifiedRefs[ref.Refspec()] = true
 }
 
+// Close discards the locks listed during this push, so that a later
+// verification lists them again from the server. Locks which have already been
+// reported as owned or unowned are kept.
+func (lv *lockVerifier) Close() error {
+	var err error
+	if lv.lockClient != nil {
+		err = lv.lockClient.Close()
+		lv.lockClient = nil
+	}
+
+	lv.verifiedRefs = make(map[string]bool)
+	lv.ourLocks = make(map[string]*refLock)
+	lv.theirLocks = make(map[string]*refLock)
+	return err
+}
+
 func (lv *lockVerifier) addLocks(ref *git.Ref, locks []locking.Lock, set map[string]*refLock) {
 	for _, l := range locks {
 		if rl, ok := set[l.Path]; ok {
@@ -108,7 +235,10 @@ func (lv *lockVerifier) Contains(name string) bool {
 
 func (lv *lockVerifier) LockedByThem(name string) bool {
 	if lock, ok := lv.theirLocks[name]; ok {
-		lv.unownedLocks = append(lv.unownedLocks, lock)
+		if !lv.unownedPaths[name] {
+			lv.unownedPaths[name] = true
+			lv.unownedLocks = append(lv.unownedLocks, lock)
+		}
 		return true
 	}
 	return false
@@ -116,7 +246,10 @@ func (lv *lockVerifier) LockedByThem(name string) bool {
 
 func (lv *lockVerifier) LockedByUs(name string) bool {
 	if lock, ok := lv.ourLocks[name]; ok {
-		lv.ownedLocks = append(lv.ownedLocks, lock)
+		if !lv.ownedPaths[name] {
+			lv.ownedPaths[name] = true
+			lv.ownedLocks = append(lv.ownedLocks, lock)
+		}
 		return true
 	}
 	return false
@@ -156,11 +289,13 @@ func newLockVerifier(m *tq.Manifest) *lockVerifier {
 		verifiedRefs: make(map[string]bool),
 		ourLocks:     make(map[string]*refLock),
 		theirLocks:   make(map[string]*refLock),
+		ownedPaths:   make(map[string]bool),
+		unownedPaths: make(map[string]bool),
 	}
 
 	// Do not check locks for standalone transfer, because there is no LFS
-	// server to ask.
-	if m.IsStandaloneTransfer() {
+	// server to ask, unless the transfer agent handles locks itself.
+	if m.IsStandaloneTransfer() && !locking.HasLockingAgent(cfg.Git) {
 		lv.verifyState = verifyStateDisabled
 	} else {
 		lv.verifyState = getVerifyStateFor(lv.endpoint.Url)
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+)
+
+// objectGroup is the number and total size of a group of objects, such as
+// those of the files in one directory, or with one extension.
+type objectGroup struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Size  int64  `json:"size"`
+}
+
+// objectGroups holds groups of objects by their names.
+type objectGroups map[string]*objectGroup
+
+// add counts an object of the given size in the group "name".
+func (g objectGroups) add(name string, size int64) {
+	group, ok := g[name]
+	if !ok {
+		group = &objectGroup{Name: name}
+		g[name] = group
+	}
+	group.Count++
+	group.Size += size
+}
+
+// addFile counts an object of the given size, of the file "name", in the
+// group of its directory in "dirs", and of its extension in "exts".
+func addFile(dirs, exts objectGroups, name string, size int64) {
+	dirs.add(path.Dir(name), size)
+	if ext := path.Ext(name); len(ext) > 0 {
+		exts.add("*"+ext, size)
+	} else {
+		exts.add(path.Base(name), size)
+	}
+}
+
+// sorted returns the groups, largest first.
+func (g objectGroups) sorted() []*objectGroup {
+	sorted := make([]*objectGroup, 0, len(g))
+	for _, group := range g {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Size != sorted[j].Size {
+			return sorted[i].Size > sorted[j].Size
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// formatObjectGroups returns a line for each of the groups, with its name,
+// number of objects and size, in aligned columns separated by tabs.
+func formatObjectGroups(groups []*objectGroup) []string {
+	names := make([]string, 0, len(groups))
+	counts := make([]string, 0, len(groups))
+	sizes := make([]string, 0, len(groups))
+	for _, g := range groups {
+		names = append(names, g.Name)
+		counts = append(counts, fmt.Sprintf("%d object(s)", g.Count))
+		sizes = append(sizes, humanize.FormatBytes(uint64(g.Size)))
+	}
+	names = tools.Ljust(names)
+	counts = tools.Rjust(counts)
+	sizes = tools.Rjust(sizes)
+
+	lines := make([]string, 0, len(groups))
+	for i := range groups {
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s", names[i], counts[i], sizes[i]))
+	}
+	return lines
+}
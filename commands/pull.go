@@ -9,6 +9,7 @@ import (
 
 	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfs"
 	"github.com/git-lfs/git-lfs/subprocess"
@@ -36,6 +37,7 @@ func newSingleCheckout(gitEnv config.Environment, remote string) abstractCheckou
 		gitIndexer:    &gitIndexer{},
 		pathConverter: pathConverter,
 		manifest:      manifest,
+		streamed:      make(map[string]string),
 	}
 }
 
@@ -44,6 +46,8 @@ type abstractCheckout interface {
 	Skip() bool
 	Run(*lfs.WrappedPointer)
 	RunToPath(*lfs.WrappedPointer, string) error
+	StreamPath(*lfs.WrappedPointer) (string, bool)
+	RunStreamed(*lfs.WrappedPointer, string)
 	Close()
 }
 
@@ -51,6 +55,13 @@ type singleCheckout struct {
 	gitIndexer    *gitIndexer
 	pathConverter lfs.PathConverter
 	manifest      *tq.Manifest
+
+	// streamed holds the working tree file to which each object was
+	// streamed with lfs.smudge.stream, by OID, if it isn't in the local
+	// object store, so that other files with the same object are copied
+	// from it.
+	streamed   map[string]string
+	streamedMu sync.Mutex
 }
 
 func (c *singleCheckout) Manifest() *tq.Manifest {
@@ -82,7 +93,9 @@ func (c *singleCheckout) Run(p *lfs.WrappedPointer) {
 		return
 	}
 
+	emitSmudgeEvent(events.SmudgeStarted, p.Name, p.Pointer, nil)
 	if err := c.RunToPath(p, cwdfilepath); err != nil {
+		emitSmudgeEvent(events.SmudgeFailed, p.Name, p.Pointer, err)
 		if errors.IsDownloadDeclinedError(err) {
 			// acceptable error, data not local (fetch not run or include/exclude)
 			Error("Skipped checkout for %q, content not local. Use fetch to download.", p.Name)
@@ -91,6 +104,7 @@ func (c *singleCheckout) Run(p *lfs.WrappedPointer) {
 		}
 		return
 	}
+	emitSmudgeEvent(events.SmudgeFinished, p.Name, p.Pointer, nil)
 
 	// errors are only returned when the gitIndexer is starting a new cmd
 	if err := c.gitIndexer.Add(cwdfilepath); err != nil {
@@ -102,9 +116,43 @@ func (c *singleCheckout) Run(p *lfs.WrappedPointer) {
 // not perform any sort of sanity checking or add the path to the index.
 func (c *singleCheckout) RunToPath(p *lfs.WrappedPointer, path string) error {
 	gitfilter := lfs.NewGitFilter(cfg)
+
+	c.streamedMu.Lock()
+	src, ok := c.streamed[p.Oid]
+	c.streamedMu.Unlock()
+	if ok && !cfg.LFSObjectExists(p.Oid, p.Size) {
+		return gitfilter.SmudgeStreamedToFile(path, src, p.Pointer)
+	}
 	return gitfilter.SmudgeToFile(path, p.Pointer, false, c.manifest, nil)
 }
 
+// StreamPath returns the path to which the object of "p" is downloaded with
+// lfs.smudge.stream set, replacing its working tree file, or false if it must
+// be downloaded to the local object store and checked out with Run.
+func (c *singleCheckout) StreamPath(p *lfs.WrappedPointer) (string, bool) {
+	gitfilter := lfs.NewGitFilter(cfg)
+	return gitfilter.StreamPath(c.pathConverter.Convert(p.Name), p.Pointer)
+}
+
+// RunStreamed finishes checking out "p", whose object has been streamed to
+// its working tree file at "path", keeping the object in the local object
+// store if it can be without copying it, and updating the git index.
+func (c *singleCheckout) RunStreamed(p *lfs.WrappedPointer, path string) {
+	emitSmudgeEvent(events.SmudgeStarted, p.Name, p.Pointer, nil)
+
+	gitfilter := lfs.NewGitFilter(cfg)
+	if !gitfilter.KeepStreamedObject(path, p.Pointer) {
+		c.streamedMu.Lock()
+		c.streamed[p.Oid] = path
+		c.streamedMu.Unlock()
+	}
+	emitSmudgeEvent(events.SmudgeFinished, p.Name, p.Pointer, nil)
+
+	if err := c.gitIndexer.Add(c.pathConverter.Convert(p.Name)); err != nil {
+		Panic(err, "Could not update the index")
+	}
+}
+
 func (c *singleCheckout) Close() {
 	if err := c.gitIndexer.Close(); err != nil {
 		LoggedError(err, "Error updating the git index:\n%s", c.gitIndexer.Output())
@@ -127,8 +175,13 @@ func (c *noOpCheckout) RunToPath(p *lfs.WrappedPointer, path string) error {
 	return nil
 }
 
-func (c *noOpCheckout) Run(p *lfs.WrappedPointer) {}
-func (c *noOpCheckout) Close()                    {}
+func (c *noOpCheckout) StreamPath(p *lfs.WrappedPointer) (string, bool) {
+	return "", false
+}
+
+func (c *noOpCheckout) Run(p *lfs.WrappedPointer)                      {}
+func (c *noOpCheckout) RunStreamed(p *lfs.WrappedPointer, path string) {}
+func (c *noOpCheckout) Close()                                         {}
 
 // Don't fire up the update-index command until we have at least one file to
 // give it. Otherwise git interprets the lack of arguments to mean param-less update-index
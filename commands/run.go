@@ -97,6 +97,7 @@ Simply type ` + root.Name() + ` help [path to command] for full details.`,
 		os.Chdir(workingDirectory)
 	})
 	root.PersistentFlags().StringVarP(&workingDirectory, "", "C", "", "")
+	root.PersistentFlags().StringVar(&metricsFile, "metrics-file", "", "")
 
 	canonicalizeEnvironment()
 
@@ -27,6 +27,8 @@ func uploadForRefUpdates(ctx *uploadContext, updates []*git.RefUpdate, pushAll b
 
 	defer func() {
 		gitscanner.Close()
+		ctx.lockVerifier.Close()
+		ctx.VerifyUploads()
 		ctx.ReportErrors()
 	}()
 
@@ -38,6 +40,9 @@ func uploadForRefUpdates(ctx *uploadContext, updates []*git.RefUpdate, pushAll b
 			rightSides = append(rightSides, right)
 		}
 	}
+	if err := verifyLockedPaths(ctx.lockVerifier, gitscanner, updates, rightSides); err != nil {
+		return err
+	}
 	for _, update := range updates {
 		// initialized here to prevent looped defer
 		q := ctx.NewQueue(
@@ -92,6 +97,15 @@ type uploadContext struct {
 	// pointers should allow pushing Git blobs
 	allowMissing bool
 
+	// continueOnError specifies whether objects which fail to upload
+	// should be collected and reported at the end, rather than stopping
+	// the push
+	continueOnError bool
+
+	// verifyObjects specifies whether the server should be asked,
+	// after the push, whether it has each of the objects uploaded
+	verifyObjects bool
+
 	// tracks errors from gitscanner callbacks
 	scannerErr error
 	errMu      sync.Mutex
@@ -100,6 +114,16 @@ type uploadContext struct {
 	missing   map[string]string
 	corrupt   map[string]string
 	otherErrs []error
+
+	// objects which failed to upload, if continueOnError is set
+	failed []*tq.FailedTransfer
+
+	// objects uploaded by each queue, if verifyObjects is set
+	uploaded   []*tq.Transfer
+	uploadWait sync.WaitGroup
+
+	// uploaded objects which the server didn't report as present
+	unverified []*tq.FailedTransfer
 }
 
 func newUploadContext(dryRun bool) *uploadContext {
@@ -133,10 +157,26 @@ func newUploadContext(dryRun bool) *uploadContext {
 }
 
 func (c *uploadContext) NewQueue(options ...tq.Option) *tq.TransferQueue {
-	return tq.NewTransferQueue(tq.Upload, c.Manifest, c.Remote, append(options,
+	q := tq.NewTransferQueue(tq.Upload, c.Manifest, c.Remote, append(options,
 		tq.DryRun(c.DryRun),
 		tq.WithProgress(c.meter),
+		tq.ContinueOnError(c.continueOnError),
 	)...)
+
+	if c.verifyObjects && !c.DryRun {
+		watch := q.Watch()
+		c.uploadWait.Add(1)
+		go func() {
+			defer c.uploadWait.Done()
+
+			for t := range watch {
+				c.errMu.Lock()
+				c.uploaded = append(c.uploaded, t)
+				c.errMu.Unlock()
+			}
+		}()
+	}
+	return q
 }
 
 func (c *uploadContext) scannerError() error {
@@ -255,7 +295,19 @@ func (c *uploadContext) UploadPointers(q *tq.TransferQueue, unfiltered ...*lfs.W
 	for _, p := range pointers {
 		t, err := c.uploadTransfer(p)
 		if err != nil && !errors.IsCleanPointerError(err) {
-			ExitWithError(err)
+			if !c.continueOnError {
+				ExitWithError(err)
+			}
+
+			c.errMu.Lock()
+			c.failed = append(c.failed, &tq.FailedTransfer{
+				Name: p.Name,
+				Oid:  p.Oid,
+				Err:  err,
+			})
+			c.errMu.Unlock()
+			c.meter.Skip(p.Size)
+			continue
 		}
 
 		q.Add(t.Name, t.Path, t.Oid, t.Size, t.Missing, nil)
@@ -265,6 +317,7 @@ func (c *uploadContext) UploadPointers(q *tq.TransferQueue, unfiltered ...*lfs.W
 
 func (c *uploadContext) CollectErrors(tqueue *tq.TransferQueue) {
 	tqueue.Wait()
+	c.uploadWait.Wait()
 
 	for _, err := range tqueue.Errors() {
 		if malformed, ok := err.(*tq.MalformedObjectError); ok {
@@ -273,10 +326,38 @@ func (c *uploadContext) CollectErrors(tqueue *tq.TransferQueue) {
 			} else if malformed.Corrupt() {
 				c.corrupt[malformed.Name] = malformed.Oid
 			}
-		} else {
+		} else if !c.continueOnError {
 			c.otherErrs = append(c.otherErrs, err)
 		}
 	}
+
+	if c.continueOnError {
+		// Missing and corrupt objects are reported separately, so
+		// only collect the objects which failed for other reasons.
+		for _, f := range tqueue.FailedTransfers() {
+			if _, ok := f.Err.(*tq.MalformedObjectError); !ok {
+				c.failed = append(c.failed, f)
+			}
+		}
+	}
+}
+
+// VerifyUploads asks the server whether it has each of the objects uploaded
+// in the current process, if verifyObjects is set, and records those it
+// doesn't have, to be reported by ReportErrors.
+func (c *uploadContext) VerifyUploads() {
+	if !c.verifyObjects || len(c.uploaded) == 0 {
+		return
+	}
+
+	tracerx.Printf("verifying %d uploaded object(s)", len(c.uploaded))
+
+	missing, err := tq.FindMissing(c.Manifest, c.Remote, nil, c.uploaded)
+	if err != nil {
+		c.otherErrs = append(c.otherErrs, errors.Wrap(err, "Unable to verify uploaded objects"))
+		return
+	}
+	c.unverified = missing
 }
 
 func (c *uploadContext) ReportErrors() {
@@ -286,6 +367,20 @@ func (c *uploadContext) ReportErrors() {
 		FullError(err)
 	}
 
+	if len(c.failed) > 0 {
+		Print("LFS upload failed for %d object(s):", len(c.failed))
+		for _, f := range c.failed {
+			Print("  %s", f)
+		}
+	}
+
+	if len(c.unverified) > 0 {
+		Print("LFS upload verification failed for %d object(s):", len(c.unverified))
+		for _, f := range c.unverified {
+			Print("  %s", f)
+		}
+	}
+
 	if len(c.missing) > 0 || len(c.corrupt) > 0 {
 		var action string
 		if c.allowMissing {
@@ -312,7 +407,7 @@ func (c *uploadContext) ReportErrors() {
 		}
 	}
 
-	if len(c.otherErrs) > 0 {
+	if len(c.otherErrs) > 0 || len(c.failed) > 0 || len(c.unverified) > 0 {
 		os.Exit(2)
 	}
 
@@ -359,6 +454,11 @@ func (c *uploadContext) uploadTransfer(p *lfs.WrappedPointer) (*tq.Transfer, err
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error uploading file %s (%s)", filename, oid)
 	}
+	if !tools.FileExists(localMediaPath) {
+		if alternate, ok := cfg.Filesystem().ObjectAlternatePathname(oid, p.Size); ok {
+			localMediaPath = alternate
+		}
+	}
 
 	if len(filename) > 0 {
 		if missing, err = c.ensureFile(filename, localMediaPath, oid); err != nil && !errors.IsCleanPointerError(err) {
@@ -199,6 +199,20 @@ func (c *Configuration) FetchExcludePaths() []string {
 	return tools.CleanPaths(patterns, ",")
 }
 
+// FetchFromSparse returns whether only the objects of files in the sparse
+// checkout are downloaded by fetch and the smudge filter, as well as by pull
+// and checkout. Default is false.
+func (c *Configuration) FetchFromSparse() bool {
+	return c.Git.Bool("lfs.fetchfromsparse", false)
+}
+
+// SmudgeStream returns whether the objects of files checked out by pull are
+// downloaded straight to the working tree, rather than to the local object
+// store and then copied. Default is false.
+func (c *Configuration) SmudgeStream() bool {
+	return c.Git.Bool("lfs.smudge.stream", false)
+}
+
 func (c *Configuration) CurrentRef() *git.Ref {
 	c.loading.Lock()
 	defer c.loading.Unlock()
@@ -321,6 +335,38 @@ func (c *Configuration) SkipDownloadErrors() bool {
 	return c.Os.Bool("GIT_LFS_SKIP_DOWNLOAD_ERRORS", false) || c.Git.Bool("lfs.skipdownloaderrors", false)
 }
 
+// SmudgeOperation returns the name of the Git operation for which the smudge
+// filter is run: the first word of GIT_REFLOG_ACTION, which git-pull(1) and
+// git-merge(1) set, in lower case, or "checkout" for any other operation, since
+// Git tells its filters nothing more.
+func (c *Configuration) SmudgeOperation() string {
+	if action, ok := c.Os.Get("GIT_REFLOG_ACTION"); ok {
+		if fields := strings.Fields(action); len(fields) > 0 {
+			return strings.ToLower(fields[0])
+		}
+	}
+	return "checkout"
+}
+
+// SkipSmudge returns whether the smudge filter should leave pointers as they
+// are, rather than replacing them with their objects, as given by
+// "lfs.skipsmudge.<operation>" for the SmudgeOperation, or GIT_LFS_SKIP_SMUDGE
+// if that is unset.
+func (c *Configuration) SkipSmudge() bool {
+	key := fmt.Sprintf("lfs.skipsmudge.%s", c.SmudgeOperation())
+	if _, ok := c.Git.Get(key); ok {
+		return c.Git.Bool(key, false)
+	}
+	return c.Os.Bool("GIT_LFS_SKIP_SMUDGE", false)
+}
+
+// IgnoreCase returns whether paths which differ only in case name the same file
+// in the working tree, as given by "core.ignorecase", which Git sets when the
+// repository is created on a filesystem which ignores case.
+func (c *Configuration) IgnoreCase() bool {
+	return c.Git.Bool("core.ignorecase", false)
+}
+
 func (c *Configuration) SetLockableFilesReadOnly() bool {
 	return c.Os.Bool("GIT_LFS_SET_LOCKABLE_READONLY", true) && c.Git.Bool("lfs.setlockablereadonly", true)
 }
@@ -419,6 +465,13 @@ func (c *Configuration) TempDir() string {
 	return c.Filesystem().TempDir()
 }
 
+// ObjectPrefix returns the namespace of the objects of the repository, as
+// given by lfs.object.prefix, or an empty string if it has none.
+func (c *Configuration) ObjectPrefix() string {
+	prefix, _ := c.Git.Get("lfs.object.prefix")
+	return fs.CleanObjectPrefix(prefix)
+}
+
 func (c *Configuration) Filesystem() *fs.Filesystem {
 	c.loadGitDirs()
 	c.loading.Lock()
@@ -433,6 +486,15 @@ func (c *Configuration) Filesystem() *fs.Filesystem {
 			lfsdir,
 			c.RepositoryPermissions(false),
 		)
+		c.fs.Alternates = c.fs.ResolveAlternates(c.Git.GetAll("lfs.storage.alternates"))
+		c.fs.Shared = c.Git.Bool("lfs.storage.shared", false)
+		if v, ok := c.Git.Get("lfs.tmpmaxage"); ok {
+			if age, err := time.ParseDuration(v); err == nil && age > 0 {
+				c.fs.TmpMaxAge = age
+			} else {
+				tracerx.Printf("config: ignoring invalid lfs.tmpmaxage %q", v)
+			}
+		}
 	}
 
 	return c.fs
@@ -380,3 +380,57 @@ func TestRemoteNameWithDotDefault(t *testing.T) {
 
 	assert.Equal(t, "name.with.dot", cfg.Remote())
 }
+
+func TestSmudgeOperation(t *testing.T) {
+	for action, expected := range map[string]string{
+		"":                          "checkout",
+		"merge b":                   "merge",
+		"pull --rebase origin main": "pull",
+		"Rebase":                    "rebase",
+	} {
+		os := map[string][]string{}
+		if len(action) > 0 {
+			os["GIT_REFLOG_ACTION"] = []string{action}
+		}
+		cfg := NewFrom(Values{Os: os})
+		assert.Equal(t, expected, cfg.SmudgeOperation(), action)
+	}
+}
+
+func TestSkipSmudge(t *testing.T) {
+	cfg := NewFrom(Values{})
+	assert.False(t, cfg.SkipSmudge())
+
+	cfg = NewFrom(Values{
+		Os: map[string][]string{"GIT_LFS_SKIP_SMUDGE": []string{"1"}},
+	})
+	assert.True(t, cfg.SkipSmudge())
+
+	cfg = NewFrom(Values{
+		Git: map[string][]string{"lfs.skipsmudge.checkout": []string{"true"}},
+	})
+	assert.True(t, cfg.SkipSmudge())
+
+	cfg = NewFrom(Values{
+		Git: map[string][]string{"lfs.skipsmudge.checkout": []string{"true"}},
+		Os:  map[string][]string{"GIT_REFLOG_ACTION": []string{"pull origin main"}},
+	})
+	assert.False(t, cfg.SkipSmudge())
+
+	// The configuration for the operation takes precedence over the
+	// environment, which is used when it is unset.
+	cfg = NewFrom(Values{
+		Git: map[string][]string{"lfs.skipsmudge.pull": []string{"false"}},
+		Os: map[string][]string{
+			"GIT_LFS_SKIP_SMUDGE": []string{"1"},
+			"GIT_REFLOG_ACTION":   []string{"pull origin main"},
+		},
+	})
+	assert.False(t, cfg.SkipSmudge())
+
+	cfg = NewFrom(Values{
+		Git: map[string][]string{"lfs.skipsmudge.pull": []string{"false"}},
+		Os:  map[string][]string{"GIT_LFS_SKIP_SMUDGE": []string{"1"}},
+	})
+	assert.True(t, cfg.SkipSmudge())
+}
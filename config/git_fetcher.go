@@ -89,6 +89,12 @@ func readGitConfig(configs ...*git.ConfigurationSource) (gf *GitFetcher, extensi
 				uniqRemotes[remote] = remote == "origin"
 			} else if len(parts) > 2 && parts[len(parts)-1] == "access" {
 				allowed = true
+			} else if len(parts) > 3 && parts[0] == "lfs" && parts[1] == "route" && parts[len(parts)-1] == "url" {
+				// prop: lfs.route.<pattern>.url
+				allowed = true
+			} else if len(parts) > 3 && parts[0] == "lfs" && parts[1] == "pruneretain" && (parts[len(parts)-1] == "versions" || parts[len(parts)-1] == "days") {
+				// prop: lfs.pruneretain.<pattern>.{versions,days}
+				allowed = true
 			}
 
 			if !allowed && keyIsUnsafe(key) {
@@ -185,9 +191,13 @@ func keyIsUnsafe(key string) bool {
 var safeKeys = []string{
 	"lfs.allowincompletepush",
 	"lfs.fetchexclude",
+	"lfs.fetchfromsparse",
 	"lfs.fetchinclude",
 	"lfs.gitprotocol",
 	"lfs.locksverify",
+	"lfs.lockverify.block",
+	"lfs.magic.types",
+	"lfs.pointer.version",
 	"lfs.pushurl",
 	"lfs.skipdownloaderrors",
 	"lfs.url",
@@ -0,0 +1,114 @@
+package creds
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+// credentialCacheDaemon keeps approved credentials for a short time between
+// Git LFS commands, as configured by "lfs.credcachettl", so that an expensive
+// credential helper need not be asked for them again by each command. They are
+// stored by Git's credential-cache daemon, which holds them in memory and
+// never writes them to disk, and are forgotten when the TTL expires or they
+// are rejected.
+//
+// Failing to reach the daemon is not an error, since the credentials can
+// still be found by the other credential helpers.
+type credentialCacheDaemon struct {
+	ttl int
+
+	// filled is the set of cache keys whose credentials were filled from
+	// the daemon, which are not stored again when approved, so that
+	// using them does not extend their TTL.
+	filled map[string]bool
+	mu     sync.Mutex
+}
+
+func newCredentialCacheDaemon(ttl int) *credentialCacheDaemon {
+	return &credentialCacheDaemon{ttl: ttl, filled: make(map[string]bool)}
+}
+
+func (d *credentialCacheDaemon) Fill(what Creds) (Creds, error) {
+	creds, err := d.exec("get", what)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds["username"]) == 0 || len(creds["password"]) == 0 {
+		return nil, credHelperNoOp
+	}
+
+	tracerx.Printf("creds: git credential-cache (%q, %q, %q)",
+		what["protocol"], what["host"], what["path"])
+
+	d.mu.Lock()
+	d.filled[credCacheKey(what)] = true
+	d.mu.Unlock()
+
+	// Keep the rest of the query, such as the path, which the daemon may
+	// not echo back.
+	for k, v := range what {
+		if _, ok := creds[k]; !ok {
+			creds[k] = v
+		}
+	}
+	return creds, nil
+}
+
+func (d *credentialCacheDaemon) Approve(what Creds) error {
+	key := credCacheKey(what)
+
+	d.mu.Lock()
+	filled := d.filled[key]
+	d.mu.Unlock()
+
+	if filled {
+		return nil
+	}
+
+	if _, err := d.exec("store", what); err != nil {
+		tracerx.Printf("creds: could not store credentials in git credential-cache: %s", err)
+	}
+	return credHelperNoOp
+}
+
+func (d *credentialCacheDaemon) Reject(what Creds) error {
+	d.mu.Lock()
+	delete(d.filled, credCacheKey(what))
+	d.mu.Unlock()
+
+	if _, err := d.exec("erase", what); err != nil {
+		tracerx.Printf("creds: could not erase credentials from git credential-cache: %s", err)
+	}
+	return credHelperNoOp
+}
+
+func (d *credentialCacheDaemon) exec(subcommand string, input Creds) (Creds, error) {
+	output := new(bytes.Buffer)
+	cmd := subprocess.ExecCommand("git", "credential-cache",
+		fmt.Sprintf("--timeout=%d", d.ttl), subcommand)
+	cmd.Stdin = bufferCreds(input)
+	cmd.Stdout = output
+	// The daemon started by "git credential-cache" inherits its standard
+	// error and never closes it, so it is left unset, rather than passed
+	// through as for "git credential", since anyone reading our standard
+	// error would otherwise wait until the daemon exits.
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("'git credential-cache %s' error: %s", subcommand, err)
+	}
+
+	creds := make(Creds)
+	for _, line := range strings.Split(output.String(), "\n") {
+		pieces := strings.SplitN(line, "=", 2)
+		if len(pieces) < 2 || len(pieces[1]) < 1 {
+			continue
+		}
+		creds[pieces[0]] = pieces[1]
+	}
+	return creds, nil
+}
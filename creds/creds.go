@@ -67,9 +67,11 @@ func bufferCreds(c Creds) *bytes.Buffer {
 
 type CredentialHelperContext struct {
 	netrcCredHelper   *netrcCredentialHelper
+	envCredHelper     *envCredentialHelper
 	commandCredHelper *commandCredentialHelper
 	askpassCredHelper *AskPassCredentialHelper
 	cachingCredHelper *credentialCacher
+	cacheDaemon       *credentialCacheDaemon
 
 	urlConfig *config.URLConfig
 }
@@ -78,6 +80,7 @@ func NewCredentialHelperContext(gitEnv config.Environment, osEnv config.Environm
 	c := &CredentialHelperContext{urlConfig: config.NewURLConfig(gitEnv)}
 
 	c.netrcCredHelper = newNetrcCredentialHelper(osEnv)
+	c.envCredHelper = newEnvCredentialHelper(osEnv)
 
 	askpass, ok := osEnv.Get("GIT_ASKPASS")
 	if !ok {
@@ -100,6 +103,9 @@ func NewCredentialHelperContext(gitEnv config.Environment, osEnv config.Environm
 	if cacheCreds {
 		c.cachingCredHelper = NewCredentialCacher()
 	}
+	if ttl := gitEnv.Int("lfs.credcachettl", 0); ttl > 0 {
+		c.cacheDaemon = newCredentialCacheDaemon(ttl)
+	}
 
 	c.commandCredHelper = &commandCredentialHelper{
 		SkipPrompt: osEnv.Bool("GIT_TERMINAL_PROMPT", false),
@@ -108,6 +114,13 @@ func NewCredentialHelperContext(gitEnv config.Environment, osEnv config.Environm
 	return c
 }
 
+// Close forgets the credentials cached in memory, overwriting their passwords.
+func (ctxt *CredentialHelperContext) Close() {
+	if ctxt.cachingCredHelper != nil {
+		ctxt.cachingCredHelper.Purge()
+	}
+}
+
 // getCredentialHelper parses a 'credsConfig' from the git and OS environments,
 // returning the appropriate CredentialHelper to authenticate requests with.
 //
@@ -127,13 +140,19 @@ func (ctxt *CredentialHelperContext) GetCredentialHelper(helper CredentialHelper
 		return CredentialHelperWrapper{CredentialHelper: helper, Input: input, Url: u}
 	}
 
-	helpers := make([]CredentialHelper, 0, 4)
+	helpers := make([]CredentialHelper, 0, 5)
 	if ctxt.netrcCredHelper != nil {
 		helpers = append(helpers, ctxt.netrcCredHelper)
 	}
+	if ctxt.envCredHelper != nil {
+		helpers = append(helpers, ctxt.envCredHelper)
+	}
 	if ctxt.cachingCredHelper != nil {
 		helpers = append(helpers, ctxt.cachingCredHelper)
 	}
+	if ctxt.cacheDaemon != nil {
+		helpers = append(helpers, ctxt.cacheDaemon)
+	}
 	if ctxt.askpassCredHelper != nil {
 		helper, _ := ctxt.urlConfig.Get("credential", rawurl, "helper")
 		if len(helper) == 0 {
@@ -347,13 +366,56 @@ func (h *commandCredentialHelper) exec(subcommand string, input Creds) (Creds, e
 	return creds, nil
 }
 
+// credentialCacher is an in-memory cache of the credentials approved during
+// the lifetime of the current Git LFS command, so that credential helpers need
+// only be asked for them once.
 type credentialCacher struct {
-	creds map[string]Creds
+	creds map[string]*cachedCreds
 	mu    sync.Mutex
 }
 
+// cachedCreds holds a set of cached credentials. The password is kept apart
+// from the rest, as bytes, so that it can be overwritten once it is no longer
+// needed.
+type cachedCreds struct {
+	creds    Creds
+	password []byte
+}
+
+func newCachedCreds(c Creds) *cachedCreds {
+	cached := &cachedCreds{creds: make(Creds, len(c))}
+	for k, v := range c {
+		if k == "password" {
+			cached.password = []byte(v)
+		} else {
+			cached.creds[k] = v
+		}
+	}
+	return cached
+}
+
+// Creds returns a copy of the cached credentials, including the password.
+func (c *cachedCreds) Creds() Creds {
+	creds := make(Creds, len(c.creds)+1)
+	for k, v := range c.creds {
+		creds[k] = v
+	}
+	if c.password != nil {
+		creds["password"] = string(c.password)
+	}
+	return creds
+}
+
+// zero overwrites the cached password.
+func (c *cachedCreds) zero() {
+	for i := range c.password {
+		c.password[i] = 0
+	}
+	c.password = nil
+}
+
 func NewCredentialCacher() *credentialCacher {
-	return &credentialCacher{creds: make(map[string]Creds)}
+	return &credentialCacher{creds: make(map[string]*cachedCreds)}
 }
 
 func credCacheKey(creds Creds) string {
@@ -374,7 +436,7 @@ func (c *credentialCacher) Fill(what Creds) (Creds, error) {
 	if ok {
 		tracerx.Printf("creds: git credential cache (%q, %q, %q)",
 			what["protocol"], what["host"], what["path"])
-		return cached, nil
+		return cached.Creds(), nil
 	}
 
 	return nil, credHelperNoOp
@@ -390,18 +452,32 @@ func (c *credentialCacher) Approve(what Creds) error {
 		return nil
 	}
 
-	c.creds[key] = what
+	c.creds[key] = newCachedCreds(what)
 	return credHelperNoOp
 }
 
 func (c *credentialCacher) Reject(what Creds) error {
 	key := credCacheKey(what)
 	c.mu.Lock()
-	delete(c.creds, key)
+	if cached, ok := c.creds[key]; ok {
+		cached.zero()
+		delete(c.creds, key)
+	}
 	c.mu.Unlock()
 	return credHelperNoOp
 }
 
+// Purge removes all of the cached credentials, overwriting their passwords.
+func (c *credentialCacher) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, cached := range c.creds {
+		cached.zero()
+		delete(c.creds, key)
+	}
+}
+
 // CredentialHelpers iterates through a slice of CredentialHelper objects
 // CredentialHelpers is a []CredentialHelper that iterates through each
 // credential helper to fill, reject, or approve credentials. Typically, the
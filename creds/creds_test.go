@@ -264,3 +264,38 @@ func TestCredHelperSetAllFillErrors(t *testing.T) {
 	assert.Equal(t, 0, len(helper1.reject))
 	assert.Equal(t, 0, len(helper2.reject))
 }
+
+func TestCredentialCacherPurge(t *testing.T) {
+	cache := NewCredentialCacher()
+	creds := Creds{"protocol": "https", "host": "example.com", "username": "foo", "password": "bar"}
+	assert.Equal(t, credHelperNoOp, cache.Approve(creds))
+
+	cached := cache.creds[credCacheKey(creds)]
+	password := cached.password
+
+	// The caller's credentials are left alone.
+	out, err := cache.Fill(creds)
+	assert.Nil(t, err)
+	assert.Equal(t, creds, out)
+	out["password"] = "changed"
+	assert.Equal(t, "bar", creds["password"])
+
+	cache.Purge()
+	assert.Equal(t, []byte{0, 0, 0}, password)
+	assert.Nil(t, cached.password)
+
+	out, err = cache.Fill(creds)
+	assert.Equal(t, credHelperNoOp, err)
+	assert.Nil(t, out)
+}
+
+func TestCredentialCacherRejectZeroesPassword(t *testing.T) {
+	cache := NewCredentialCacher()
+	creds := Creds{"protocol": "https", "host": "example.com", "username": "foo", "password": "bar"}
+	cache.Approve(creds)
+	password := cache.creds[credCacheKey(creds)].password
+
+	assert.Equal(t, credHelperNoOp, cache.Reject(creds))
+	assert.Equal(t, []byte{0, 0, 0}, password)
+	assert.Empty(t, cache.creds)
+}
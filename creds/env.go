@@ -0,0 +1,150 @@
+package creds
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	// envDefaultUsername is the username sent along with a token given in
+	// the environment, if no username is given there too.
+	envDefaultUsername = "git-lfs"
+)
+
+// envCredentialHelper retrieves credentials from environment variables, so
+// that they can be given to Git LFS where configuring a credential helper is
+// impractical, such as in CI. Credentials are looked up for each host in the
+// following order, and the first complete set found is used:
+//
+//	GIT_LFS_<HOST>_TOKEN (with GIT_LFS_<HOST>_USERNAME or LFS_USERNAME)
+//	GIT_LFS_<HOST>_USERNAME and GIT_LFS_<HOST>_PASSWORD
+//	LFS_USERNAME and LFS_PASSWORD
+//
+// where <HOST> is the host name, with its port if it has one, in upper case
+// and with all other characters than letters and digits replaced by "_". If
+// nothing is found for a host with a port, the host without its port is tried
+// before the unscoped variables. Those are only used for the host named by
+// LFS_HOST, with or without its port, so that they're never sent to any other.
+//
+// The values of these variables are never logged.
+type envCredentialHelper struct {
+	osEnv config.Environment
+	mu    sync.Mutex
+	skip  map[string]bool
+}
+
+func newEnvCredentialHelper(osEnv config.Environment) *envCredentialHelper {
+	return &envCredentialHelper{osEnv: osEnv, skip: make(map[string]bool)}
+}
+
+func (c *envCredentialHelper) Fill(what Creds) (Creds, error) {
+	host := what["host"]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.skip[host] {
+		return nil, credHelperNoOp
+	}
+
+	username, password, source, ok := c.lookup(host, what["username"])
+	if !ok {
+		return nil, credHelperNoOp
+	}
+
+	tracerx.Printf("env: git credential fill (%q, %q, %q) from %s",
+		what["protocol"], host, what["path"], source)
+
+	creds := make(Creds)
+	creds["username"] = username
+	creds["password"] = password
+	creds["protocol"] = what["protocol"]
+	creds["host"] = host
+	creds["path"] = what["path"]
+	creds["source"] = "env"
+	return creds, nil
+}
+
+// lookup returns the credentials given in the environment for "host", along
+// with the name of the variable holding the password or token.
+func (c *envCredentialHelper) lookup(host, username string) (string, string, string, bool) {
+	hosts := []string{host}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hosts = append(hosts, h)
+	}
+
+	var unscopedUsername, unscopedPassword string
+	if lfsHost := c.get("LFS_HOST"); len(lfsHost) > 0 {
+		for _, h := range hosts {
+			if strings.EqualFold(lfsHost, h) {
+				unscopedUsername, unscopedPassword = c.get("LFS_USERNAME"), c.get("LFS_PASSWORD")
+			}
+		}
+	}
+
+	for _, h := range hosts {
+		prefix := "GIT_LFS_" + envHostKey(h) + "_"
+
+		if token := c.get(prefix + "TOKEN"); len(token) > 0 {
+			for _, u := range []string{c.get(prefix + "USERNAME"), username, unscopedUsername, envDefaultUsername} {
+				if len(u) > 0 {
+					return u, token, prefix + "TOKEN", true
+				}
+			}
+		}
+
+		u, p := c.get(prefix+"USERNAME"), c.get(prefix+"PASSWORD")
+		if len(u) > 0 && len(p) > 0 {
+			return u, p, prefix + "PASSWORD", true
+		}
+	}
+
+	if len(unscopedUsername) > 0 && len(unscopedPassword) > 0 {
+		return unscopedUsername, unscopedPassword, "LFS_PASSWORD", true
+	}
+	return "", "", "", false
+}
+
+func (c *envCredentialHelper) get(key string) string {
+	v, _ := c.osEnv.Get(key)
+	return v
+}
+
+func (c *envCredentialHelper) Approve(what Creds) error {
+	if what["source"] == "env" {
+		tracerx.Printf("env: git credential approve (%q, %q, %q)",
+			what["protocol"], what["host"], what["path"])
+		return nil
+	}
+	return credHelperNoOp
+}
+
+func (c *envCredentialHelper) Reject(what Creds) error {
+	if what["source"] == "env" {
+		tracerx.Printf("env: git credential reject (%q, %q, %q)",
+			what["protocol"], what["host"], what["path"])
+		c.mu.Lock()
+		c.skip[what["host"]] = true
+		c.mu.Unlock()
+		return nil
+	}
+	return credHelperNoOp
+}
+
+// envHostKey returns the form of "host" used in the names of environment
+// variables, e.g., "GIT_EXAMPLE_COM_8443" for "git.example.com:8443".
+func envHostKey(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, host)
+}
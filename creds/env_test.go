@@ -0,0 +1,156 @@
+package creds
+
+import (
+	"testing"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEnvCredentialHelper(env map[string]string) *envCredentialHelper {
+	return newEnvCredentialHelper(config.EnvironmentOf(config.UniqMapFetcher(env)))
+}
+
+func TestEnvCredsPrecedence(t *testing.T) {
+	for desc, c := range map[string]struct {
+		env      map[string]string
+		host     string
+		username string
+		password string
+	}{
+		"unscoped": {
+			env: map[string]string{
+				"LFS_HOST":     "git.example.com",
+				"LFS_USERNAME": "user",
+				"LFS_PASSWORD": "pass",
+			},
+			host: "git.example.com", username: "user", password: "pass",
+		},
+		"unscoped, host with port": {
+			env: map[string]string{
+				"LFS_HOST":     "git.example.com",
+				"LFS_USERNAME": "user",
+				"LFS_PASSWORD": "pass",
+			},
+			host: "git.example.com:8443", username: "user", password: "pass",
+		},
+		"token with unscoped username": {
+			env: map[string]string{
+				"LFS_HOST":                      "git.example.com",
+				"LFS_USERNAME":                  "user",
+				"GIT_LFS_GIT_EXAMPLE_COM_TOKEN": "token",
+			},
+			host: "git.example.com", username: "user", password: "token",
+		},
+		"host over unscoped": {
+			env: map[string]string{
+				"LFS_HOST":                         "git.example.com",
+				"LFS_USERNAME":                     "user",
+				"LFS_PASSWORD":                     "pass",
+				"GIT_LFS_GIT_EXAMPLE_COM_USERNAME": "host-user",
+				"GIT_LFS_GIT_EXAMPLE_COM_PASSWORD": "host-pass",
+			},
+			host: "git.example.com", username: "host-user", password: "host-pass",
+		},
+		"token over host password": {
+			env: map[string]string{
+				"GIT_LFS_GIT_EXAMPLE_COM_USERNAME": "host-user",
+				"GIT_LFS_GIT_EXAMPLE_COM_PASSWORD": "host-pass",
+				"GIT_LFS_GIT_EXAMPLE_COM_TOKEN":    "token",
+			},
+			host: "git.example.com", username: "host-user", password: "token",
+		},
+		"token with default username": {
+			env: map[string]string{
+				"GIT_LFS_GIT_EXAMPLE_COM_TOKEN": "token",
+			},
+			host: "git.example.com", username: "git-lfs", password: "token",
+		},
+		"host with port over host": {
+			env: map[string]string{
+				"GIT_LFS_GIT_EXAMPLE_COM_TOKEN":      "token",
+				"GIT_LFS_GIT_EXAMPLE_COM_8443_TOKEN": "port-token",
+			},
+			host: "git.example.com:8443", username: "git-lfs", password: "port-token",
+		},
+		"host without port": {
+			env: map[string]string{
+				"GIT_LFS_GIT_EXAMPLE_COM_TOKEN": "token",
+			},
+			host: "git.example.com:8443", username: "git-lfs", password: "token",
+		},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			helper := newTestEnvCredentialHelper(c.env)
+
+			creds, err := helper.Fill(Creds{"protocol": "https", "host": c.host})
+			require.Nil(t, err)
+			assert.Equal(t, c.username, creds["username"])
+			assert.Equal(t, c.password, creds["password"])
+			assert.Equal(t, c.host, creds["host"])
+		})
+	}
+}
+
+func TestEnvCredsIgnoresOtherHosts(t *testing.T) {
+	helper := newTestEnvCredentialHelper(map[string]string{
+		"GIT_LFS_GIT_EXAMPLE_COM_TOKEN": "token",
+		"LFS_USERNAME":                  "user",
+	})
+
+	creds, err := helper.Fill(Creds{"protocol": "https", "host": "other.example.com"})
+	assert.Equal(t, credHelperNoOp, err)
+	assert.Nil(t, creds)
+}
+
+func TestEnvCredsUnscopedOnlyForLFSHost(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"LFS_USERNAME": "user", "LFS_PASSWORD": "pass"},
+		{"LFS_HOST": "git.example.com", "LFS_USERNAME": "user", "LFS_PASSWORD": "pass"},
+		{"LFS_HOST": "other.example.com:8443", "LFS_USERNAME": "user", "LFS_PASSWORD": "pass"},
+	} {
+		helper := newTestEnvCredentialHelper(env)
+
+		creds, err := helper.Fill(Creds{"protocol": "https", "host": "other.example.com"})
+		assert.Equal(t, credHelperNoOp, err, env["LFS_HOST"])
+		assert.Nil(t, creds, env["LFS_HOST"])
+	}
+
+	helper := newTestEnvCredentialHelper(map[string]string{
+		"LFS_HOST":                      "other.example.com",
+		"LFS_USERNAME":                  "user",
+		"GIT_LFS_GIT_EXAMPLE_COM_TOKEN": "token",
+	})
+	creds, err := helper.Fill(Creds{"protocol": "https", "host": "git.example.com"})
+	require.Nil(t, err)
+	assert.Equal(t, "git-lfs", creds["username"])
+}
+
+func TestEnvCredsRejectSkipsHost(t *testing.T) {
+	helper := newTestEnvCredentialHelper(map[string]string{
+		"GIT_LFS_GIT_EXAMPLE_COM_TOKEN": "token",
+	})
+
+	creds, err := helper.Fill(Creds{"protocol": "https", "host": "git.example.com"})
+	require.Nil(t, err)
+	assert.Nil(t, helper.Reject(creds))
+
+	creds, err = helper.Fill(Creds{"protocol": "https", "host": "git.example.com"})
+	assert.Equal(t, credHelperNoOp, err)
+	assert.Nil(t, creds)
+}
+
+func TestEnvCredsOnlyApprovesOwnCreds(t *testing.T) {
+	helper := newTestEnvCredentialHelper(nil)
+
+	assert.Nil(t, helper.Approve(Creds{"source": "env"}))
+	assert.Equal(t, credHelperNoOp, helper.Approve(Creds{"username": "u", "password": "p"}))
+	assert.Equal(t, credHelperNoOp, helper.Reject(Creds{"username": "u", "password": "p"}))
+}
+
+func TestEnvHostKey(t *testing.T) {
+	assert.Equal(t, "GIT_EXAMPLE_COM_8443", envHostKey("git.example.com:8443"))
+	assert.Equal(t, "LOCALHOST", envHostKey("localhost"))
+	assert.Equal(t, "GIT_LFS_1", envHostKey("git-lfs-1"))
+}
@@ -0,0 +1,204 @@
+// Package events implements an opt-in stream of machine-readable events, for
+// use by editors and other tools which integrate with Git LFS and wish to react
+// to what it is doing without parsing its human-readable output.
+//
+// When the GIT_LFS_EVENTS_FD environment variable names an open file
+// descriptor, each event is written to that descriptor as a single line of
+// JSON.
+//
+// When GIT_LFS_PROGRESS_FORMAT is "json", or a command is given the
+// --json-progress option, the same events are written to standard error
+// instead, so that programs running Git LFS can show its progress themselves.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rubyist/tracerx"
+)
+
+// Type is the kind of an Event, and is given as the "event" field of each
+// encoded event.
+type Type string
+
+const (
+	// SmudgeStarted is emitted when the smudge filter begins to write the
+	// contents of an object into the working copy.
+	SmudgeStarted Type = "smudge-started"
+	// SmudgeFinished is emitted when the smudge filter has written the
+	// contents of an object into the working copy.
+	SmudgeFinished Type = "smudge-finished"
+	// SmudgeFailed is emitted when the smudge filter was unable to write
+	// the contents of an object, and left a pointer in its place.
+	SmudgeFailed Type = "smudge-failed"
+	// ObjectMissing is emitted when an object could not be found, either
+	// on the server (when downloading) or locally (when uploading).
+	ObjectMissing Type = "object-missing"
+	// TransferStarted is emitted when an object is handed to a transfer
+	// adapter.
+	TransferStarted Type = "transfer-started"
+	// TransferFinished is emitted when an object has been transferred
+	// successfully.
+	TransferFinished Type = "transfer-finished"
+	// TransferFailed is emitted when an object could not be transferred
+	// and will not be retried.
+	TransferFailed Type = "transfer-failed"
+	// TransferRetried is emitted when an object could not be transferred,
+	// and will be tried again.
+	TransferRetried Type = "transfer-retried"
+	// TransferProgress is emitted about once a second while objects are
+	// being transferred, and once they have been, with the totals so far.
+	TransferProgress Type = "transfer-progress"
+	// TransferBytes is emitted about once a second while an object is
+	// being transferred, and once all of it has been, with the number of
+	// bytes of it transferred so far.
+	TransferBytes Type = "transfer-bytes"
+	// LockAcquired is emitted when a file has been locked.
+	LockAcquired Type = "lock-acquired"
+	// LockReleased is emitted when a file has been unlocked.
+	LockReleased Type = "lock-released"
+)
+
+// Event is a single occurrence reported to the event stream. Fields which do
+// not apply to a given Type are left empty, and are omitted when encoded.
+type Event struct {
+	Type      Type      `json:"event"`
+	Time      time.Time `json:"time"`
+	Path      string    `json:"path,omitempty"`
+	Oid       string    `json:"oid,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	Direction string    `json:"direction,omitempty"`
+	LockID    string    `json:"lock_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+
+	// Retries is the number of times the object has been retried, and is
+	// given for TransferRetried, TransferFinished and TransferFailed
+	// events.
+	Retries int `json:"retries,omitempty"`
+
+	// The following are only given for TransferProgress events, except
+	// for Bytes, which is also given for TransferBytes events. ETA is the
+	// estimated number of seconds remaining, and is omitted if it is not
+	// known.
+	Files      int64 `json:"files,omitempty"`
+	TotalFiles int64 `json:"total_files,omitempty"`
+	Bytes      int64 `json:"bytes,omitempty"`
+	TotalBytes int64 `json:"total_bytes,omitempty"`
+	ETA        int64 `json:"eta_seconds,omitempty"`
+
+	// BytesPerSecond is given for TransferProgress and TransferBytes
+	// events, and for TransferFinished events along with Elapsed, the
+	// number of milliseconds the object took to transfer.
+	BytesPerSecond int64 `json:"bytes_per_second,omitempty"`
+	Elapsed        int64 `json:"elapsed_ms,omitempty"`
+}
+
+// Emitter writes events to an underlying io.Writer, one JSON object per line.
+// A nil *Emitter is valid, and discards all events.
+type Emitter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewEmitter returns a new *Emitter which writes events to "w".
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{w: w}
+}
+
+// env is the subset of config.Environment used to configure an *Emitter.
+type env interface {
+	Get(key string) (val string, ok bool)
+}
+
+var (
+	defaultEmitter     *Emitter
+	defaultEmitterOnce sync.Once
+)
+
+// FromEnvironment returns the process-wide *Emitter writing to standard error
+// if GIT_LFS_PROGRESS_FORMAT is "json" in the given environment, or else to
+// the file descriptor named by GIT_LFS_EVENTS_FD, or nil if that variable is
+// unset. The environment is only consulted upon the first call, unless
+// SetDefault has been called first.
+func FromEnvironment(e env) *Emitter {
+	defaultEmitterOnce.Do(func() {
+		em, err := newEmitterFromEnvironment(e)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating event stream: %s\n", err)
+			return
+		}
+		defaultEmitter = em
+	})
+	return defaultEmitter
+}
+
+// SetDefault makes "em" the process-wide *Emitter returned by
+// FromEnvironment, whatever the environment holds.
+func SetDefault(em *Emitter) {
+	defaultEmitterOnce.Do(func() {})
+	defaultEmitter = em
+}
+
+// JSONProgress returns whether GIT_LFS_PROGRESS_FORMAT asks for progress to be
+// given as a stream of events on standard error in the given environment.
+func JSONProgress(e env) bool {
+	if e == nil {
+		return false
+	}
+
+	val, _ := e.Get("GIT_LFS_PROGRESS_FORMAT")
+	return val == "json"
+}
+
+func newEmitterFromEnvironment(e env) (*Emitter, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	if JSONProgress(e) {
+		return NewEmitter(os.Stderr), nil
+	}
+
+	val, ok := e.Get("GIT_LFS_EVENTS_FD")
+	if !ok || len(val) == 0 {
+		return nil, nil
+	}
+
+	fd, err := strconv.Atoi(val)
+	if err != nil || fd <= 0 {
+		return nil, fmt.Errorf("GIT_LFS_EVENTS_FD must be a positive file descriptor, got: %q", val)
+	}
+
+	return NewEmitter(os.NewFile(uintptr(fd), "git-lfs-events")), nil
+}
+
+// Emit writes the given event to the stream, setting its time to the current
+// time if none was given.
+func (e *Emitter) Emit(ev *Event) {
+	if e == nil || ev == nil {
+		return
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		tracerx.Printf("events: unable to encode %q event: %s", ev.Type, err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := e.w.Write(append(data, '\n')); err != nil {
+		tracerx.Printf("events: unable to write %q event: %s", ev.Type, err)
+	}
+}
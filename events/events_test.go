@@ -0,0 +1,96 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv map[string]string
+
+func (e testEnv) Get(key string) (string, bool) {
+	val, ok := e[key]
+	return val, ok
+}
+
+func TestEmitterWritesOneEventPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmitter(&buf)
+
+	e.Emit(&Event{Type: LockAcquired, Path: "a.psd", LockID: "1"})
+	e.Emit(&Event{Type: TransferFinished, Oid: "abc", Size: 10, Direction: "download"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var ev map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &ev))
+	assert.Equal(t, "lock-acquired", ev["event"])
+	assert.Equal(t, "a.psd", ev["path"])
+	assert.Equal(t, "1", ev["lock_id"])
+	assert.NotContains(t, ev, "oid")
+	assert.Contains(t, ev, "time")
+
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &ev))
+	assert.Equal(t, "transfer-finished", ev["event"])
+	assert.Equal(t, float64(10), ev["size"])
+}
+
+func TestEmitterPreservesGivenTime(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	NewEmitter(&buf).Emit(&Event{Type: SmudgeStarted, Time: now})
+
+	assert.Equal(t,
+		`{"event":"smudge-started","time":"2021-01-02T03:04:05Z"}`+"\n",
+		buf.String())
+}
+
+func TestNilEmitterDiscardsEvents(t *testing.T) {
+	var e *Emitter
+	e.Emit(&Event{Type: SmudgeStarted})
+}
+
+func TestEmitterFromEnvironmentUnset(t *testing.T) {
+	e, err := newEmitterFromEnvironment(testEnv{})
+	assert.Nil(t, err)
+	assert.Nil(t, e)
+}
+
+func TestEmitterFromEnvironmentInvalid(t *testing.T) {
+	for _, val := range []string{"stderr", "-1", "0"} {
+		e, err := newEmitterFromEnvironment(testEnv{"GIT_LFS_EVENTS_FD": val})
+		assert.NotNil(t, err, val)
+		assert.Nil(t, e, val)
+	}
+}
+
+func TestEmitterFromEnvironment(t *testing.T) {
+	e, err := newEmitterFromEnvironment(testEnv{"GIT_LFS_EVENTS_FD": "2"})
+	assert.Nil(t, err)
+	assert.NotNil(t, e)
+}
+
+func TestEmitterFromEnvironmentJSONProgress(t *testing.T) {
+	e, err := newEmitterFromEnvironment(testEnv{
+		"GIT_LFS_PROGRESS_FORMAT": "json",
+		"GIT_LFS_EVENTS_FD":       "3",
+	})
+	assert.Nil(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, os.Stderr, e.w)
+}
+
+func TestJSONProgress(t *testing.T) {
+	assert.True(t, JSONProgress(testEnv{"GIT_LFS_PROGRESS_FORMAT": "json"}))
+	assert.False(t, JSONProgress(testEnv{"GIT_LFS_PROGRESS_FORMAT": "text"}))
+	assert.False(t, JSONProgress(testEnv{}))
+	assert.False(t, JSONProgress(nil))
+}
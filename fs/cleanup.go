@@ -11,15 +11,94 @@ import (
 	"github.com/rubyist/tracerx"
 )
 
+// defaultTmpMaxAge is how old temporary files must be before they are removed,
+// unless "lfs.tmpmaxage" is set.
+const defaultTmpMaxAge = time.Hour
+
+// RemoveOnCleanup arranges for the temporary file at "path", which is being
+// written by this process, to be removed by Cleanup if it is still there then,
+// as when the process is interrupted before it has renamed it into place.
+func (f *Filesystem) RemoveOnCleanup(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.tmpfiles == nil {
+		f.tmpfiles = make(map[string]struct{})
+	}
+	f.tmpfiles[path] = struct{}{}
+}
+
+// RemoveTempFile removes the temporary file at "path", and forgets it if it was
+// given to RemoveOnCleanup, so that those which are finished with don't pile
+// up in a long-running process, such as the filter process, which may write
+// many of them.
+func (f *Filesystem) RemoveTempFile(path string) error {
+	err := os.Remove(path)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.tmpfiles, path)
+	return err
+}
+
+// removeTmpFiles removes the files given to RemoveOnCleanup which still exist.
+func (f *Filesystem) removeTmpFiles() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for path := range f.tmpfiles {
+		if err := os.Remove(path); err == nil {
+			tracerx.Printf("Removing unfinished tmp file: %s", path)
+		}
+	}
+	f.tmpfiles = nil
+}
+
+// tmpMaxAge returns how old temporary files must be before they are removed.
+func (f *Filesystem) tmpMaxAge() time.Duration {
+	if f.TmpMaxAge > 0 {
+		return f.TmpMaxAge
+	}
+	return defaultTmpMaxAge
+}
+
 func (f *Filesystem) cleanupTmp() error {
+	f.removeTmpFiles()
+
+	stale, err := f.StaleTmpFiles()
+	for _, path := range stale {
+		os.RemoveAll(path)
+	}
+	return err
+}
+
+// StaleTmpFiles returns the paths of the files in the temporary directory which
+// are no longer needed: those for objects which are now present, and those
+// older than "lfs.tmpmaxage", which are left behind by processes that were
+// interrupted or failed.
+func (f *Filesystem) StaleTmpFiles() ([]string, error) {
 	tmpdir := f.TempDir()
 	if len(tmpdir) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// No temporary directory?  No problem.
 	if _, err := os.Stat(tmpdir); err != nil && os.IsNotExist(err) {
-		return nil
+		return nil, nil
+	}
+
+	maxAge := f.tmpMaxAge()
+
+	var (
+		stale   []string
+		staleMu sync.Mutex
+	)
+	addStale := func(path string) {
+		staleMu.Lock()
+		defer staleMu.Unlock()
+
+		stale = append(stale, path)
 	}
 
 	traversedDirectories := &sync.Map{}
@@ -43,12 +122,12 @@ func (f *Filesystem) cleanupTmp() error {
 			fi, err := os.Stat(f.ObjectPathname(oid))
 			if err == nil && !fi.IsDir() {
 				tracerx.Printf("Removing existing tmp object file: %s", path)
-				os.RemoveAll(path)
+				addStale(path)
 				return
 			}
 		}
 
-		// Don't prune items in a directory younger than an hour.  These
+		// Don't prune items in a directory younger than maxAge.  These
 		// items could be hard links to files from other repositories,
 		// which would have an older timestamp but which are still in
 		// use by some active process.  Exempt the main temporary from
@@ -67,17 +146,17 @@ func (f *Filesystem) cleanupTmp() error {
 				traversedDirectories.Store(path, dirInfo)
 			}
 
-			if time.Since(dirInfo.ModTime()) <= time.Hour {
+			if time.Since(dirInfo.ModTime()) <= maxAge {
 				return
 			}
 		}
 
-		if time.Since(info.ModTime()) > time.Hour {
+		if time.Since(info.ModTime()) > maxAge {
 			tracerx.Printf("Removing old tmp object file: %s", path)
-			os.RemoveAll(path)
+			addStale(path)
 			return
 		}
 	})
 
-	return walkErr
+	return stale, walkErr
 }
@@ -0,0 +1,13 @@
+// +build !windows
+
+package fs
+
+import "syscall"
+
+func isNoSpaceErrno(errno syscall.Errno) bool {
+	return errno == syscall.ENOSPC || errno == syscall.EDQUOT
+}
+
+func isReadOnlyErrno(errno syscall.Errno) bool {
+	return errno == syscall.EROFS
+}
@@ -0,0 +1,17 @@
+// +build windows
+
+package fs
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+func isNoSpaceErrno(errno syscall.Errno) bool {
+	return errno == windows.ERROR_DISK_FULL || errno == windows.ERROR_HANDLE_DISK_FULL
+}
+
+func isReadOnlyErrno(errno syscall.Errno) bool {
+	return errno == windows.ERROR_WRITE_PROTECT
+}
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/rubyist/tracerx"
@@ -33,12 +35,17 @@ type Object struct {
 }
 
 type Filesystem struct {
-	GitStorageDir string   // parent of objects/lfs (may be same as GitDir but may not)
-	LFSStorageDir string   // parent of lfs objects and tmp dirs. Default: ".git/lfs"
-	ReferenceDirs []string // alternative local media dirs (relative to clone reference repo)
+	GitStorageDir string        // parent of objects/lfs (may be same as GitDir but may not)
+	LFSStorageDir string        // parent of lfs objects and tmp dirs. Default: ".git/lfs"
+	ReferenceDirs []string      // alternative local media dirs (relative to clone reference repo)
+	Alternates    []string      // read-only local media dirs consulted after the primary one (lfs.storage.alternates)
+	TmpMaxAge     time.Duration // age of temporary files after which they are removed (lfs.tmpmaxage)
+	ObjectPrefix  string        // namespace of objects in a standalone remote's store, with forward slashes (lfs.object.prefix); set before use
+	Shared        bool          // whether LFSStorageDir is shared by many repositories (lfs.storage.shared)
 	lfsobjdir     string
 	tmpdir        string
 	logdir        string
+	tmpfiles      map[string]struct{}
 	repoPerms     os.FileMode
 	mu            sync.Mutex
 }
@@ -53,15 +60,24 @@ func (f *Filesystem) EachObject(fn func(Object) error) error {
 		if eachErr != nil || info.IsDir() {
 			return
 		}
-		if oidRE.MatchString(info.Name()) {
-			fn(Object{Oid: info.Name(), Size: info.Size()})
+		// Only files at "<oid[0:2]>/<oid[2:4]>/<oid>" are objects, so
+		// that those stored under an lfs.object.prefix directory
+		// aren't taken for objects of the store without one.
+		if oid := info.Name(); oidRE.MatchString(oid) && filepath.Clean(parentDir) == f.localObjectDir(oid) {
+			fn(Object{Oid: oid, Size: info.Size()})
 		}
 	})
 	return eachErr
 }
 
+// ObjectExists returns whether the object "oid" of "size" bytes can be read,
+// either from the primary object directory or from one of the alternates.
 func (f *Filesystem) ObjectExists(oid string, size int64) bool {
-	return tools.FileExistsOfSize(f.ObjectPathname(oid), size)
+	if tools.FileExistsOfSize(f.ObjectPathname(oid), size) {
+		return true
+	}
+	_, ok := f.ObjectAlternatePathname(oid, size)
+	return ok
 }
 
 func (f *Filesystem) ObjectPath(oid string) (string, error) {
@@ -70,6 +86,9 @@ func (f *Filesystem) ObjectPath(oid string) (string, error) {
 	}
 	dir := f.localObjectDir(oid)
 	if err := tools.MkdirAll(dir, f); err != nil {
+		if err = NewStorageError(dir, err); IsStorageError(err) {
+			return "", err
+		}
 		return "", fmt.Errorf("error trying to create local storage directory in %q: %s", dir, err)
 	}
 	return filepath.Join(dir, oid), nil
@@ -79,6 +98,70 @@ func (f *Filesystem) ObjectPathname(oid string) string {
 	return filepath.Join(f.localObjectDir(oid), oid)
 }
 
+// ObjectReadPathname returns the path of the file the object "oid" can be read
+// from: the one in the primary object directory if it is there, otherwise the
+// one in the first alternate which has it. The primary path is returned if no
+// alternate has it either. If "size" isn't negative, only files of that many
+// bytes are considered.
+func (f *Filesystem) ObjectReadPathname(oid string, size int64) string {
+	path := f.ObjectPathname(oid)
+	if !objectFileExists(path, size) {
+		if alternate, ok := f.ObjectAlternatePathname(oid, size); ok {
+			return alternate
+		}
+	}
+	return path
+}
+
+// ObjectAlternatePathname returns the path of the object "oid" in the first of
+// the alternate object directories which has it, in the order they are
+// configured, and whether any does. If "size" isn't negative, only files of
+// that many bytes are considered.
+func (f *Filesystem) ObjectAlternatePathname(oid string, size int64) (string, bool) {
+	if len(oid) < 4 {
+		return "", false
+	}
+
+	for _, dir := range f.Alternates {
+		path := filepath.Join(dir, oid[0:2], oid[2:4], oid)
+		if objectFileExists(path, size) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func objectFileExists(path string, size int64) bool {
+	if size < 0 {
+		return tools.FileExists(path)
+	}
+	return tools.FileExistsOfSize(path, size)
+}
+
+// CleanObjectPrefix returns "prefix", as given by lfs.object.prefix, with
+// forward slashes, and without any empty, "." or ".." elements, so that it can
+// never name a directory outside of the one it is joined to.
+func CleanObjectPrefix(prefix string) string {
+	var elems []string
+	for _, elem := range strings.FieldsFunc(prefix, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		if elem != "." && elem != ".." {
+			elems = append(elems, elem)
+		}
+	}
+	return strings.Join(elems, "/")
+}
+
+// ObjectKey returns the path of the object "oid" relative to the root of an
+// object store namespaced by "prefix", as stored by both the local object
+// store and the standalone transfer agents: "<prefix>/<oid[0:2]>/<oid[2:4]>/<oid>",
+// or without the leading "<prefix>/" if "prefix" is empty. The path has
+// forward slashes, and "prefix" must already be clean.
+func ObjectKey(prefix, oid string) string {
+	return path.Join(prefix, oid[0:2], oid[2:4], oid)
+}
+
 func (f *Filesystem) DecodePathname(path string) string {
 	return string(DecodePathBytes([]byte(path)))
 }
@@ -133,7 +216,7 @@ func (f *Filesystem) ObjectReferencePaths(oid string) []string {
 
 	var paths []string
 	for _, ref := range f.ReferenceDirs {
-		paths = append(paths, filepath.Join(ref, oid[0:2], oid[2:4], oid))
+		paths = append(paths, filepath.Join(ref, filepath.FromSlash(ObjectKey(f.ObjectPrefix, oid))))
 	}
 	return paths
 }
@@ -143,7 +226,7 @@ func (f *Filesystem) LFSObjectDir() string {
 	defer f.mu.Unlock()
 
 	if len(f.lfsobjdir) == 0 {
-		f.lfsobjdir = filepath.Join(f.LFSStorageDir, "objects")
+		f.lfsobjdir = filepath.Join(f.LFSStorageDir, "objects", filepath.FromSlash(f.ObjectPrefix))
 		tools.MkdirAll(f.lfsobjdir, f)
 	}
 
@@ -207,6 +290,30 @@ func New(env Environment, gitdir, workdir, lfsdir string, repoPerms os.FileMode)
 	return fs
 }
 
+// ResolveAlternates returns the alternate object directories "dirs", as given
+// by lfs.storage.alternates, with any leading "~" expanded, and relative paths
+// taken to be relative to the Git storage directory, like lfs.storage.
+func (f *Filesystem) ResolveAlternates(dirs []string) []string {
+	alternates := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if len(dir) == 0 {
+			continue
+		}
+
+		expanded, err := tools.ExpandPath(dir, false)
+		if err != nil {
+			tracerx.Printf("could not expand alternate %q: %s", dir, err)
+			continue
+		}
+
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(f.GitStorageDir, expanded)
+		}
+		alternates = append(alternates, expanded)
+	}
+	return alternates
+}
+
 func resolveReferenceDirs(env Environment, gitStorageDir string) []string {
 	var references []string
 
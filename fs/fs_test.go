@@ -1,8 +1,11 @@
 package fs
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -42,3 +45,217 @@ func TestRepositoryPermissions(t *testing.T) {
 		assert.Equal(t, v, fs.RepositoryPermissions(false))
 	}
 }
+
+func TestObjectAlternatesLookupOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-alternates")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	oid := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	write := func(objects, contents string) string {
+		path := filepath.Join(dir, objects, oid[0:2], oid[2:4], oid)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+		return path
+	}
+
+	fs := &Filesystem{
+		LFSStorageDir: filepath.Join(dir, "primary"),
+		Alternates: []string{
+			filepath.Join(dir, "missing"),
+			filepath.Join(dir, "first"),
+			filepath.Join(dir, "second"),
+		},
+	}
+	primary := fs.ObjectPathname(oid)
+
+	assert.False(t, fs.ObjectExists(oid, 4))
+	assert.Equal(t, primary, fs.ObjectReadPathname(oid, 4))
+
+	second := write("second", "abcd")
+	assert.True(t, fs.ObjectExists(oid, 4))
+	assert.Equal(t, second, fs.ObjectReadPathname(oid, 4))
+
+	// Files of the wrong size are skipped.
+	first := write("first", "abc")
+	assert.Equal(t, second, fs.ObjectReadPathname(oid, 4))
+	assert.Equal(t, first, fs.ObjectReadPathname(oid, -1))
+
+	first = write("first", "abcd")
+	alternate, ok := fs.ObjectAlternatePathname(oid, 4)
+	assert.True(t, ok)
+	assert.Equal(t, first, alternate)
+	assert.Equal(t, first, fs.ObjectReadPathname(oid, 4))
+
+	write(filepath.Join("primary", "objects"), "abcd")
+	assert.True(t, fs.ObjectExists(oid, 4))
+	assert.Equal(t, primary, fs.ObjectReadPathname(oid, 4))
+}
+
+func TestResolveAlternates(t *testing.T) {
+	fs := &Filesystem{GitStorageDir: filepath.Join("repo", ".git")}
+
+	abs, err := filepath.Abs(filepath.Join("warehouse", "objects"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		abs,
+		filepath.Join("repo", ".git", "shared", "objects"),
+	}, fs.ResolveAlternates([]string{abs, "", filepath.Join("shared", "objects")}))
+}
+
+func TestStaleTmpFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-tmp")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := &Filesystem{LFSStorageDir: dir, TmpMaxAge: 2 * time.Hour}
+	tmpdir := fs.TempDir()
+
+	fresh := filepath.Join(tmpdir, "fresh")
+	recent := filepath.Join(tmpdir, "recent")
+	old := filepath.Join(tmpdir, "old")
+	for path, age := range map[string]time.Duration{fresh: 0, recent: time.Hour, old: 3 * time.Hour} {
+		assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+		when := time.Now().Add(-age)
+		assert.Nil(t, os.Chtimes(path, when, when))
+	}
+
+	stale, err := fs.StaleTmpFiles()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{old}, stale)
+
+	fs.RemoveOnCleanup(fresh)
+	assert.Nil(t, fs.Cleanup())
+
+	for path, exists := range map[string]bool{fresh: false, recent: true, old: false} {
+		_, err := os.Stat(path)
+		assert.Equal(t, exists, err == nil, path)
+	}
+}
+
+func TestRemoveTempFileForgetsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-remove-temp-file")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := &Filesystem{LFSStorageDir: dir}
+	path := filepath.Join(dir, "tmp-file")
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+
+	fs.RemoveOnCleanup(path)
+	assert.Len(t, fs.tmpfiles, 1)
+
+	assert.Nil(t, fs.RemoveTempFile(path))
+	assert.Empty(t, fs.tmpfiles)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCleanObjectPrefix(t *testing.T) {
+	for given, expected := range map[string]string{
+		"":                 "",
+		"tenant":           "tenant",
+		"/org/tenant/":     "org/tenant",
+		"org\\tenant":      "org/tenant",
+		"../tenant/./a//b": "tenant/a/b",
+		"..":               "",
+	} {
+		assert.Equal(t, expected, CleanObjectPrefix(given), given)
+	}
+}
+
+func TestObjectPathWithPrefix(t *testing.T) {
+	oid := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	assert.Equal(t, "01/23/"+oid, ObjectKey("", oid))
+	assert.Equal(t, "org/tenant/01/23/"+oid, ObjectKey("org/tenant", oid))
+
+	dir, err := ioutil.TempDir("", "fs-prefix")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := &Filesystem{LFSStorageDir: dir}
+	assert.Equal(t, filepath.Join(dir, "objects", "01", "23", oid), fs.ObjectPathname(oid))
+
+	fs = &Filesystem{LFSStorageDir: dir, ObjectPrefix: "org/tenant"}
+	path, err := fs.ObjectPath(oid)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "objects", "org", "tenant", "01", "23", oid), path)
+	assert.Equal(t, path, fs.ObjectPathname(oid))
+}
+
+func TestEachObjectSkipsPrefixedObjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-prefix")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	unprefixed := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	prefixed := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+	for _, fs := range []*Filesystem{
+		{LFSStorageDir: dir},
+		{LFSStorageDir: dir, ObjectPrefix: "tenant"},
+	} {
+		oid := unprefixed
+		if len(fs.ObjectPrefix) > 0 {
+			oid = prefixed
+		}
+		path, err := fs.ObjectPath(oid)
+		assert.Nil(t, err)
+		assert.Nil(t, ioutil.WriteFile(path, []byte("x"), 0644))
+	}
+
+	for expected, fs := range map[string]*Filesystem{
+		unprefixed: {LFSStorageDir: dir},
+		prefixed:   {LFSStorageDir: dir, ObjectPrefix: "tenant"},
+	} {
+		var oids []string
+		assert.Nil(t, fs.EachObject(func(obj Object) error {
+			oids = append(oids, obj.Oid)
+			return nil
+		}))
+		assert.Equal(t, []string{expected}, oids)
+	}
+}
+
+func TestLockObject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-object")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := &Filesystem{LFSStorageDir: dir, repoPerms: 0644}
+	oid := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	unlock, err := fs.LockObject(oid)
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	go func() {
+		// Objects starting with the same characters share a lock.
+		unlock, err := fs.LockObject("012" + oid[3:63] + "0")
+		assert.NoError(t, err)
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("expected the object to stay locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the object to be unlocked")
+	}
+
+	unlock, err = fs.LockObject("fedcba" + oid[6:])
+	assert.NoError(t, err)
+	unlock()
+
+	_, err = fs.LockObject("01")
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/git-lfs/git-lfs/tools"
+)
+
+// objectLockStripes is the number of oid characters naming the file locked
+// for an object, so that objects share one of 16^3 lock files rather than each
+// leaving one behind.
+const objectLockStripes = 3
+
+// ObjectLockDir returns the directory holding the files locked by LockObject.
+func (f *Filesystem) ObjectLockDir() string {
+	return filepath.Join(f.LFSStorageDir, "objectlocks")
+}
+
+// LockObject waits until no other process, nor any other caller in this one,
+// holds the lock of the object "oid" in a shared object store (see:
+// "lfs.storage.shared"), then takes it, and returns a function to release it.
+// The lock is that of a file, which the operating system releases should the
+// process exit without releasing it. Objects whose oids start the same share
+// their lock.
+func (f *Filesystem) LockObject(oid string) (func(), error) {
+	if len(oid) < objectLockStripes {
+		return nil, os.ErrInvalid
+	}
+
+	dir := f.ObjectLockDir()
+	if err := tools.MkdirAll(dir, f); err != nil {
+		return nil, NewStorageError(dir, err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, oid[:objectLockStripes]), os.O_RDWR|os.O_CREATE, f.RepositoryPermissions(false))
+	if err != nil {
+		return nil, NewStorageError(dir, err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+)
+
+// StorageError is returned when a file can't be written to the local object
+// store because its filesystem is full or read-only, or the current user may
+// not write to it. Its message says what to do about it, since the error from
+// the OS alone is easily mistaken for a problem with Git LFS itself.
+type StorageError struct {
+	// Path is the file or directory which couldn't be written.
+	Path string
+	// Err is the error from the OS.
+	Err error
+
+	reason string
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("cannot write to %s: %s", e.Path, e.reason)
+}
+
+// Cause returns the error from the OS, so that errors.Cause finds it.
+func (e *StorageError) Cause() error {
+	return e.Err
+}
+
+// NewStorageError returns a *StorageError for the failure "err" to write
+// "path" if the filesystem holding it is full or read-only, or its permissions
+// don't allow it to be written, and otherwise returns "err" as it is.
+func NewStorageError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*StorageError); ok {
+		return err
+	}
+
+	errno, ok := storageErrno(err)
+	if !ok {
+		return err
+	}
+
+	var reason string
+	switch {
+	case isNoSpaceErrno(errno):
+		reason = fmt.Sprintf("%s (%s free); free some space, such as with 'git lfs prune', and try again",
+			errno, freeSpace(path))
+	case isReadOnlyErrno(errno):
+		reason = fmt.Sprintf("%s; make it writable, or set lfs.storage to a directory on a writable filesystem",
+			errno)
+	case os.IsPermission(errno):
+		reason = fmt.Sprintf("%s (%s); make sure the current user may write to it",
+			errno, permissions(path))
+	default:
+		return err
+	}
+	return &StorageError{Path: path, Err: err, reason: reason}
+}
+
+// IsStorageError returns whether "err" is, or was caused by, a
+// *StorageError.
+func IsStorageError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*StorageError); ok {
+			return true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+// storageErrno returns the errno underlying "err", if it has one.
+func storageErrno(err error) (syscall.Errno, bool) {
+	switch e := errors.Cause(err).(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	default:
+		err = e
+	}
+	errno, ok := err.(syscall.Errno)
+	return errno, ok
+}
+
+// freeSpace describes the space available on the filesystem holding "path".
+func freeSpace(path string) string {
+	free, err := tools.DiskFree(existingParent(path))
+	if err != nil {
+		return "unknown space"
+	}
+	return humanize.FormatBytes(free)
+}
+
+// permissions describes the mode of "path", or of its closest existing
+// parent if it doesn't exist yet.
+func permissions(path string) string {
+	dir := existingParent(path)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "unknown permissions"
+	}
+	return fmt.Sprintf("%s has mode %s", dir, fi.Mode().Perm())
+}
+
+// existingParent returns "path" if it exists, and otherwise its closest
+// parent which does.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,73 @@
+// +build !windows
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStorageErrorNoSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage-error")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tmp", "object")
+	cause := &os.PathError{Op: "write", Path: path, Err: syscall.ENOSPC}
+	err = NewStorageError(path, errors.Wrap(cause, "copy"))
+
+	require.True(t, IsStorageError(err))
+	assert.Equal(t, cause, errors.Cause(err))
+	assert.Contains(t, err.Error(), "cannot write to "+path+": no space left on device (")
+	assert.Contains(t, err.Error(), "free); free some space, such as with 'git lfs prune', and try again")
+	assert.NotContains(t, err.Error(), "unknown space")
+}
+
+func TestNewStorageErrorQuota(t *testing.T) {
+	err := NewStorageError("object", &os.PathError{Op: "write", Path: "object", Err: syscall.EDQUOT})
+
+	require.True(t, IsStorageError(err))
+	assert.Contains(t, err.Error(), "free some space")
+}
+
+func TestNewStorageErrorReadOnly(t *testing.T) {
+	err := NewStorageError("objects", &os.LinkError{Op: "rename", Old: "tmp", New: "objects", Err: syscall.EROFS})
+
+	require.True(t, IsStorageError(err))
+	assert.Equal(t, "cannot write to objects: read-only file system; make it writable, or set lfs.storage to a directory on a writable filesystem", err.Error())
+}
+
+func TestNewStorageErrorPermission(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage-error")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, os.Chmod(dir, 0500))
+
+	path := filepath.Join(dir, "ab", "cd")
+	err = NewStorageError(path, &os.PathError{Op: "mkdir", Path: path, Err: syscall.EACCES})
+
+	require.True(t, IsStorageError(err))
+	assert.Equal(t, "cannot write to "+path+": permission denied ("+dir+" has mode -r-x------); make sure the current user may write to it", err.Error())
+}
+
+func TestNewStorageErrorOther(t *testing.T) {
+	cause := &os.PathError{Op: "open", Path: "object", Err: syscall.ENOENT}
+
+	assert.Equal(t, cause, NewStorageError("object", cause))
+	assert.False(t, IsStorageError(cause))
+	assert.Nil(t, NewStorageError("object", nil))
+}
+
+func TestIsStorageErrorWrapped(t *testing.T) {
+	err := NewStorageError("object", &os.PathError{Op: "write", Path: "object", Err: syscall.ENOSPC})
+
+	assert.True(t, IsStorageError(errors.Wrap(err, "Error cleaning LFS object")))
+	assert.Equal(t, err, NewStorageError("other", err))
+}
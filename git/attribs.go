@@ -1,31 +1,43 @@
 package git
 
 import (
+	"math"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git/gitattr"
 	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/wildmatch"
 	"github.com/rubyist/tracerx"
 )
 
 const (
 	LockableAttrib = "lockable"
 	FilterAttrib   = "filter"
+	MagicAttrib    = "lfs-magic"
 )
 
 // AttributePath is a path entry in a gitattributes file which has the LFS filter
 type AttributePath struct {
 	// Path entry in the attribute file
 	Path string
+	// Pattern is the path entry as given in the attribute file, before it
+	// was made relative to the root of the working copy
+	Pattern string
 	// The attribute file which was the source of this entry
 	Source *AttributeSource
 	// Path also has the 'lockable' attribute
 	Lockable bool
 	// Path is handled by Git LFS (i.e., filter=lfs)
 	Tracked bool
+	// Path also has the 'lfs-magic' attribute, and so is a catch-all for
+	// files stored in Git LFS only if their contents are of one of the
+	// types given by lfs.magic.types
+	Magic bool
 }
 
 type AttributeSource struct {
@@ -81,11 +93,21 @@ func GetSystemAttributePaths(mp *gitattr.MacroProcessor, env Env) []AttributePat
 // configured with the filter=lfs attribute
 // workingDir is the root of the working copy
 // gitDir is the root of the git repo
+//
+// The attribute files are parsed concurrently, and cached for the rest of the
+// process; see attrCache.
 func GetAttributePaths(mp *gitattr.MacroProcessor, workingDir, gitDir string) []AttributePath {
 	paths := make([]AttributePath, 0)
 
-	for _, file := range findAttributeFiles(workingDir, gitDir) {
-		paths = append(paths, attrPaths(mp, file.path, workingDir, file.readMacros)...)
+	files := findAttributeFiles(workingDir, gitDir)
+
+	// Macros must still be expanded one file at a time, in order, since
+	// a file may use those defined by one before it.
+	for i, entry := range attributeCache.Load(files) {
+		if entry == nil {
+			continue
+		}
+		paths = append(paths, linePaths(mp, entry.lines, entry.eol, files[i].path, workingDir, files[i].readMacros)...)
 	}
 
 	return paths
@@ -98,22 +120,29 @@ func attrPaths(mp *gitattr.MacroProcessor, path, workingDir string, readMacros b
 	}
 	defer attributes.Close()
 
+	lines, eol, err := gitattr.ParseLines(attributes)
+	if err != nil {
+		return nil
+	}
+
+	return linePaths(mp, lines, eol, path, workingDir, readMacros)
+}
+
+// linePaths returns the entries of the parsed attribute file at "path" which
+// set the filter or lockable attributes.
+func linePaths(mp *gitattr.MacroProcessor, lines []*gitattr.Line, eol, path, workingDir string, readMacros bool) []AttributePath {
 	var paths []AttributePath
 
 	relfile, _ := filepath.Rel(workingDir, path)
 	reldir := filepath.Dir(relfile)
 	source := &AttributeSource{Path: relfile}
 
-	lines, eol, err := gitattr.ParseLines(attributes)
-	if err != nil {
-		return nil
-	}
-
 	lines = mp.ProcessLines(lines, readMacros)
 
 	for _, line := range lines {
 		lockable := false
 		tracked := false
+		magic := false
 		hasFilter := false
 
 		for _, attr := range line.Attrs {
@@ -122,6 +151,8 @@ func attrPaths(mp *gitattr.MacroProcessor, path, workingDir string, readMacros b
 				tracked = attr.V == "lfs"
 			} else if attr.K == LockableAttrib && attr.V == "true" {
 				lockable = true
+			} else if attr.K == MagicAttrib && attr.V == "true" {
+				magic = true
 			}
 		}
 
@@ -130,15 +161,18 @@ func attrPaths(mp *gitattr.MacroProcessor, path, workingDir string, readMacros b
 		}
 
 		pattern := line.Pattern.String()
+		entry := pattern
 		if len(reldir) > 0 {
-			pattern = filepath.Join(reldir, pattern)
+			entry = filepath.Join(reldir, pattern)
 		}
 
 		paths = append(paths, AttributePath{
-			Path:     pattern,
+			Path:     entry,
+			Pattern:  pattern,
 			Source:   source,
 			Lockable: lockable,
 			Tracked:  tracked,
+			Magic:    magic,
 		})
 	}
 
@@ -157,6 +191,12 @@ func GetAttributeFilter(workingDir, gitDir string) *filepathfilter.Filter {
 	patterns := make([]filepathfilter.Pattern, 0, len(paths))
 
 	for _, path := range paths {
+		if path.Magic {
+			// Files matching a catch-all are only known to be
+			// tracked once their contents have been examined.
+			continue
+		}
+
 		// Convert all separators to `/` before creating a pattern to
 		// avoid characters being escaped in situations like `subtree\*.md`
 		patterns = append(patterns, filepathfilter.NewPattern(filepath.ToSlash(path.Path), filepathfilter.Strict(true)))
@@ -173,7 +213,9 @@ func findAttributeFiles(workingDir, gitDir string) []attrFile {
 		paths = append(paths, attrFile{path: repoAttributes, readMacros: true})
 	}
 
-	lsFiles, err := NewLsFiles(workingDir, true)
+	// Only list the attribute files themselves, rather than the whole
+	// tree, which is much faster in large repositories.
+	lsFiles, err := NewLsFiles(workingDir, true, ":(glob)**/.gitattributes")
 	if err != nil {
 		tracerx.Printf("Error finding .gitattributes: %v", err)
 		return paths
@@ -197,3 +239,94 @@ func findAttributeFiles(workingDir, gitDir string) []attrFile {
 
 	return paths
 }
+
+// AttributeMatcher finds which of a set of attribute entries decides whether
+// a file is tracked by Git LFS.
+type AttributeMatcher struct {
+	entries []attributeMatch
+}
+
+type attributeMatch struct {
+	path *AttributePath
+	// dir is the directory of the .gitattributes file the entry was read
+	// from, relative to the root of the working copy, with forward
+	// slashes, or empty for an attributes file which applies to the whole
+	// tree.
+	dir string
+	w   *wildmatch.Wildmatch
+	// rank is the precedence of the attributes file the entry was read
+	// from.
+	rank int
+}
+
+// NewAttributeMatcher returns an AttributeMatcher for the entries "paths", as
+// returned by GetAttributePaths, GetRootAttributePaths and
+// GetSystemAttributePaths.
+//
+// As in Git, entries in "$GIT_DIR/info/attributes" take precedence over those
+// in any .gitattributes file, those in a .gitattributes file over those in the
+// .gitattributes files of the directories above it, and those over the global
+// and system attributes. Later entries in a file take precedence over earlier
+// ones.
+func NewAttributeMatcher(paths []AttributePath) *AttributeMatcher {
+	m := &AttributeMatcher{}
+	for i := range paths {
+		p := &paths[i]
+		if !p.Tracked && p.Lockable {
+			// The entry doesn't set the filter attribute.
+			continue
+		}
+
+		var dir string
+		var rank int
+		source := ""
+		if p.Source != nil {
+			source = filepath.ToSlash(p.Source.Path)
+		}
+		switch {
+		case path.Base(source) == "attributes" && path.Base(path.Dir(source)) == "info":
+			rank = math.MaxInt32
+		case path.Base(source) == ".gitattributes" && !strings.HasPrefix(source, "../"):
+			if dir = path.Dir(source); dir == "." {
+				dir = ""
+				rank = 1
+			} else {
+				rank = 2 + strings.Count(dir, "/")
+			}
+		}
+
+		m.entries = append(m.entries, attributeMatch{
+			path: p,
+			dir:  dir,
+			w:    wildmatch.NewWildmatch(p.Pattern, wildmatch.Basename, wildmatch.SystemCase),
+			rank: rank,
+		})
+	}
+
+	sort.SliceStable(m.entries, func(i, j int) bool {
+		return m.entries[i].rank < m.entries[j].rank
+	})
+	return m
+}
+
+// Match returns the entry which decides whether the file at "name", relative
+// to the root of the working copy, is tracked by Git LFS, or nil if none
+// applies to it. The file is tracked if the entry returned is.
+func (m *AttributeMatcher) Match(name string) *AttributePath {
+	name = filepath.ToSlash(name)
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		e := m.entries[i]
+
+		rel := name
+		if len(e.dir) > 0 {
+			if !strings.HasPrefix(name, e.dir+"/") {
+				continue
+			}
+			rel = name[len(e.dir)+1:]
+		}
+		if e.w.Match(rel) {
+			return e.path
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/git-lfs/git-lfs/git/gitattr"
+)
+
+// attrCache is an in-memory index of parsed attribute files, keyed by the
+// directory containing each, which holds at most one: either ".gitattributes",
+// or "attributes" in "$GIT_DIR/info". It is shared by every scan in the
+// process, so that each file is read and parsed at most once unless it
+// changes.
+type attrCache struct {
+	mu      sync.Mutex
+	entries map[string]*attrCacheEntry
+}
+
+// attrCacheEntry is the parsed contents of a single attribute file, along
+// with the size and modification time it had when it was parsed.
+type attrCacheEntry struct {
+	size    int64
+	modTime time.Time
+
+	lines []*gitattr.Line
+	eol   string
+}
+
+var attributeCache = &attrCache{entries: make(map[string]*attrCacheEntry)}
+
+// Load returns the parsed contents of each of the given attribute files, in
+// the same order. Files which are not in the cache, or which have changed
+// since they were cached, are parsed concurrently. Files which cannot be read
+// or parsed have a nil entry.
+func (c *attrCache) Load(files []attrFile) []*attrCacheEntry {
+	loaded := make([]*attrCacheEntry, len(files))
+
+	workers := runtime.GOMAXPROCS(-1)
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	indexes := make(chan int, len(files))
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				loaded[i] = c.load(files[i].path)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return loaded
+}
+
+// load returns the parsed contents of the attribute file at "path", from the
+// cache if it has not changed since it was last parsed.
+func (c *attrCache) load(path string) *attrCacheEntry {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+
+	c.mu.Lock()
+	entry, ok := c.entries[dir]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	lines, eol, err := gitattr.ParseLines(f)
+	if err != nil {
+		return nil
+	}
+
+	entry = &attrCacheEntry{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		lines:   lines,
+		eol:     eol,
+	}
+
+	c.mu.Lock()
+	c.entries[dir] = entry
+	c.mu.Unlock()
+
+	return entry
+}
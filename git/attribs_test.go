@@ -0,0 +1,144 @@
+package git_test // to avoid import cycles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/git/gitattr"
+	test "github.com/git-lfs/git-lfs/t/cmd/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAttributePathsNested(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeAttributes(t, ".gitattributes", "*.dat filter=lfs diff=lfs merge=lfs -text\n")
+	writeAttributes(t, "a/.gitattributes", "*.dat -filter\n")
+	writeAttributes(t, "a/b/.gitattributes", "*.dat filter=lfs lockable\n")
+
+	paths := GetAttributePaths(gitattr.NewMacroProcessor(), repo.Path, repo.GitDir)
+	require.Len(t, paths, 3)
+
+	assert.Equal(t, "a/b/*.dat", filepath.ToSlash(paths[0].Path))
+	assert.True(t, paths[0].Tracked)
+	assert.True(t, paths[0].Lockable)
+
+	assert.Equal(t, "a/*.dat", filepath.ToSlash(paths[1].Path))
+	assert.False(t, paths[1].Tracked)
+
+	assert.Equal(t, "*.dat", paths[2].Path)
+	assert.True(t, paths[2].Tracked)
+	assert.False(t, paths[2].Lockable)
+
+	// A changed file is parsed again.
+	writeAttributes(t, "a/.gitattributes", "*.dat filter=lfs\n*.bin filter=lfs\n")
+
+	paths = GetAttributePaths(gitattr.NewMacroProcessor(), repo.Path, repo.GitDir)
+	require.Len(t, paths, 4)
+	assert.Equal(t, "a/*.dat", filepath.ToSlash(paths[1].Path))
+	assert.True(t, paths[1].Tracked)
+	assert.Equal(t, "a/*.bin", filepath.ToSlash(paths[2].Path))
+	assert.True(t, paths[2].Tracked)
+}
+
+func TestAttributeMatcher(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeAttributes(t, ".gitattributes", "*.dat filter=lfs\n*.psd filter=lfs\nart/*.psd filter=lfs\n")
+	writeAttributes(t, "a/.gitattributes", "*.dat -filter\n")
+	writeAttributes(t, "a/b/.gitattributes", "*.dat filter=lfs lockable\n*.bin lockable\n")
+	writeAttributes(t, filepath.Join(repo.GitDir, "info", "attributes"), "secret.dat -filter\n")
+
+	m := NewAttributeMatcher(GetAttributePaths(gitattr.NewMacroProcessor(), repo.Path, repo.GitDir))
+
+	for name, expected := range map[string]string{
+		"x.dat":       "*.dat",
+		"c/x.dat":     "*.dat",
+		"a/x.dat":     "a/*.dat",
+		"a/b/x.dat":   "a/b/*.dat",
+		"a/b/c/x.dat": "a/b/*.dat",
+		"x.psd":       "*.psd",
+		"art/x.psd":   "art/*.psd",
+		"a/b/x.bin":   "",
+		"other/x.txt": "",
+	} {
+		p := m.Match(name)
+		if len(expected) == 0 {
+			assert.Nil(t, p, name)
+		} else if assert.NotNil(t, p, name) {
+			assert.Equal(t, expected, filepath.ToSlash(p.Path), name)
+		}
+	}
+
+	assert.True(t, m.Match("a/b/x.dat").Tracked)
+	assert.False(t, m.Match("a/x.dat").Tracked)
+	if p := m.Match("c/secret.dat"); assert.NotNil(t, p) {
+		assert.Equal(t, "secret.dat", p.Pattern)
+		assert.False(t, p.Tracked)
+	}
+}
+
+func TestAttributeMatcherMagic(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeAttributes(t, ".gitattributes", "* filter=lfs lfs-magic\n*.psd filter=lfs\n*.txt -filter\n")
+
+	paths := GetAttributePaths(gitattr.NewMacroProcessor(), repo.Path, repo.GitDir)
+	require.Len(t, paths, 3)
+	assert.True(t, paths[0].Magic)
+	assert.True(t, paths[0].Tracked)
+	assert.False(t, paths[1].Magic)
+
+	m := NewAttributeMatcher(paths)
+	assert.True(t, m.Match("a/x.bin").Magic)
+	assert.False(t, m.Match("a/x.psd").Magic)
+	assert.False(t, m.Match("a/x.txt").Tracked)
+}
+
+func BenchmarkGetAttributePaths(b *testing.B) {
+	repo := test.NewRepo(b)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	for i := 0; i < 5000; i++ {
+		dir := filepath.Join(fmt.Sprintf("d%02d", i%50), fmt.Sprintf("e%04d", i))
+		writeAttributes(b, filepath.Join(dir, ".gitattributes"),
+			fmt.Sprintf("*.dat filter=lfs diff=lfs merge=lfs -text\nf%d.bin -filter\n", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		paths := GetAttributePaths(gitattr.NewMacroProcessor(), repo.Path, repo.GitDir)
+		if len(paths) != 10000 {
+			b.Fatalf("expected 10000 paths, got %d", len(paths))
+		}
+	}
+}
+
+func writeAttributes(tb testing.TB, path, contents string) {
+	require.Nil(tb, os.MkdirAll(filepath.Dir(path), 0755))
+	require.Nil(tb, ioutil.WriteFile(path, []byte(contents), 0644))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -90,6 +91,43 @@ func (c *Configuration) FindWorktree(key string) string {
 	return output
 }
 
+// ConfigEntry is a single key and value read from a configuration file.
+type ConfigEntry struct {
+	// Origin is the file the entry was read from, as given by git config
+	// --show-origin but without the "file:" prefix.
+	Origin string
+	Key    string
+	Value  string
+}
+
+// FindSection returns the entries in the named section of the config in the
+// given scope, one of "global", "system", "local" or "worktree", along with the
+// files they were read from. Like the other Find methods, it returns nothing if
+// the section is not set or cannot be read.
+func (c *Configuration) FindSection(scope, section string) []*ConfigEntry {
+	output, _ := c.gitConfig("--"+scope, "--show-origin", "--get-regexp",
+		"^"+regexp.QuoteMeta(section)+`\.`)
+
+	var entries []*ConfigEntry
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		kv := strings.SplitN(parts[1], " ", 2)
+		entry := &ConfigEntry{
+			Origin: strings.TrimPrefix(parts[0], "file:"),
+			Key:    kv[0],
+		}
+		if len(kv) > 1 {
+			entry.Value = kv[1]
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
 // SetGlobal sets the git config value for the key in the global config
 func (c *Configuration) SetGlobal(key, val string) (string, error) {
 	return c.gitConfigWrite("--global", "--replace-all", key, val)
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	. "github.com/git-lfs/git-lfs/git"
+	test "github.com/git-lfs/git-lfs/t/cmd/util"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,3 +13,26 @@ func TestReadOnlyConfig(t *testing.T) {
 	_, err := cfg.SetLocal("lfs.this.should", "fail")
 	assert.Equal(t, err, ErrReadOnly)
 }
+
+func TestFindSection(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	cfg := NewConfig("", "")
+	_, err := cfg.SetLocal("filter.lfs.clean", "git-lfs clean -- %f")
+	assert.Nil(t, err)
+	_, err = cfg.SetLocal("filter.lfsother.clean", "other")
+	assert.Nil(t, err)
+
+	entries := cfg.FindSection("local", "filter.lfs")
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "filter.lfs.clean", entries[0].Key)
+	assert.Equal(t, "git-lfs clean -- %f", entries[0].Value)
+	assert.Equal(t, ".git/config", entries[0].Origin)
+
+	assert.Empty(t, cfg.FindSection("local", "filter.missing"))
+}
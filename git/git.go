@@ -329,6 +329,102 @@ func ResolveRefs(refnames []string) ([]*Ref, error) {
 	return refs, nil
 }
 
+// IsRevisionRange returns whether the given revision specifier is a range of
+// the form "A..B" or "A...B", as understood by gitrevisions(7).
+func IsRevisionRange(spec string) bool {
+	return strings.Contains(spec, "..")
+}
+
+// ResolveRevisionRange resolves a revision range of the form "A..B" or "A...B"
+// into the commits which must be included and excluded in order to traverse
+// that range, as given by git-rev-parse(1).
+func ResolveRevisionRange(spec string) (include, exclude []string, err error) {
+	outp, err := gitNoLFSSimple("rev-parse", "--revs-only", spec, "--")
+	if err != nil || len(outp) == 0 {
+		return nil, nil, fmt.Errorf("Git can't resolve revision range: %q", spec)
+	}
+
+	for _, line := range strings.Split(outp, "\n") {
+		if strings.HasPrefix(line, "^") {
+			exclude = append(exclude, line[1:])
+		} else if len(line) > 0 {
+			include = append(include, line)
+		}
+	}
+
+	if len(include) == 0 {
+		return nil, nil, fmt.Errorf("Git can't resolve revision range: %q", spec)
+	}
+	return include, exclude, nil
+}
+
+// MergeBase returns the best common ancestor of the commits "a" and "b", as
+// given by git-merge-base(1), or the empty string if the two commits share no
+// history.
+func MergeBase(a, b string) (string, error) {
+	out, err := gitNoLFS("merge-base", a, b).Output()
+	if err != nil {
+		// git-merge-base(1) exits with status 1, and prints nothing,
+		// when there is no common ancestor.
+		if e, ok := err.(*exec.ExitError); ok {
+			var ws syscall.WaitStatus
+			ws, ok = e.ProcessState.Sys().(syscall.WaitStatus)
+			if ok && ws.ExitStatus() == 1 && len(out) == 0 {
+				return "", nil
+			}
+		}
+		return "", fmt.Errorf("failed to call git merge-base %s %s: %v", a, b, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ExpireReflogs immediately expires every entry in the reflogs of the given
+// refs, so that the commits they refer to may be pruned. This cannot be undone.
+func ExpireReflogs(refs ...string) error {
+	args := append([]string{"reflog", "expire", "--expire=now", "--expire-unreachable=now"}, refs...)
+	if _, err := gitNoLFSSimple(args...); err != nil {
+		return fmt.Errorf("failed to call git reflog expire: %v", err)
+	}
+	return nil
+}
+
+// GarbageCollect aggressively repacks the repository and removes all
+// unreachable objects, regardless of their age.
+func GarbageCollect() error {
+	if _, err := gitNoLFSSimple("gc", "--aggressive", "--prune=now", "--quiet"); err != nil {
+		return fmt.Errorf("failed to call git gc: %v", err)
+	}
+	return nil
+}
+
+// ObjectDatabaseSize returns the disk space, in bytes, used by loose objects,
+// packs and garbage in the object database, as reported by
+// git-count-objects(1).
+func ObjectDatabaseSize() (int64, error) {
+	out, err := gitNoLFSSimple("count-objects", "-v")
+	if err != nil {
+		return 0, fmt.Errorf("failed to call git count-objects: %v", err)
+	}
+
+	var size int64
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "size", "size-pack", "size-garbage":
+			kib, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse git count-objects output %q: %v", line, err)
+			}
+			size += kib * 1024
+		}
+	}
+	return size, nil
+}
+
 func CurrentRef() (*Ref, error) {
 	return ResolveRef("HEAD")
 }
@@ -1156,6 +1252,18 @@ func Fetch(remotes ...string) error {
 	return err
 }
 
+// FetchObjects fetches the objects "oids" from the remote "remote", such as the
+// blobs left out of a partial clone, without updating any refs.
+func FetchObjects(remote string, oids ...string) error {
+	if len(oids) == 0 {
+		return nil
+	}
+
+	args := []string{"fetch", "--quiet", "--no-tags", "--recurse-submodules=no", remote}
+	_, err := gitNoLFSSimple(append(args, oids...)...)
+	return err
+}
+
 // RemoteRefs returns a list of branches & tags for a remote by actually
 // accessing the remote via git ls-remote.
 func RemoteRefs(remoteName string) ([]*Ref, error) {
@@ -1256,6 +1364,98 @@ func AllRefsIn(wd string) ([]*Ref, error) {
 	return refs, nil
 }
 
+// IsFileTracked returns whether the file at "path", which is relative to the
+// root of the repository, is in the index.
+func IsFileTracked(path string) (bool, error) {
+	out, err := gitNoLFSSimple("ls-files", "--cached", "--", ":(top,literal)"+path)
+	if err != nil {
+		return false, fmt.Errorf("failed to call git ls-files: %v", err)
+	}
+	return len(out) > 0, nil
+}
+
+// IndexEntry is a file in stage zero of the index, which is where files are
+// when there is no merge conflict.
+type IndexEntry struct {
+	// Path is the path of the file, relative to the root of the
+	// repository.
+	Path string
+	// Sha is the ID of the blob staged for the file.
+	Sha string
+}
+
+// IndexEntries returns the regular files in stage zero of the index of the
+// repository whose working tree is "workingDir". Symbolic links and
+// submodules are left out, since they are never converted to pointers.
+func IndexEntries(workingDir string) ([]*IndexEntry, error) {
+	cmd := gitNoLFS("ls-files", "--cached", "--stage", "-z")
+	cmd.Dir = workingDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to call git ls-files: %v", err)
+	}
+
+	var entries []*IndexEntry
+	for _, line := range strings.Split(string(out), "\x00") {
+		// Each entry is "<mode> <sha> <stage>\t<path>".
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 3 || fields[2] != "0" || fields[0] == "120000" || fields[0] == "160000" {
+			continue
+		}
+		entries = append(entries, &IndexEntry{Path: line[tab+1:], Sha: fields[1]})
+	}
+	return entries, nil
+}
+
+// AttributeValues returns the value of the attribute "attr" for each of
+// "paths", which are relative to "workingDir", the root of the working tree,
+// as given by git-check-attr(1): "set", "unset", "unspecified", or the value
+// itself.
+func AttributeValues(workingDir, attr string, paths []string) (map[string]string, error) {
+	cmd := gitNoLFS("check-attr", "-z", "--stdin", attr)
+	cmd.Dir = workingDir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to call git check-attr: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to call git check-attr: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to call git check-attr: %v", err)
+	}
+
+	go func() {
+		for _, path := range paths {
+			stdin.Write([]byte(path + "\x00"))
+		}
+		stdin.Close()
+	}()
+
+	// Each result is "<path> NUL <attribute> NUL <value> NUL".
+	values := make(map[string]string, len(paths))
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(tools.SplitOnNul)
+	var fields []string
+	for scanner.Scan() {
+		fields = append(fields, scanner.Text())
+		if len(fields) == 3 {
+			values[fields[0]] = fields[2]
+			fields = fields[:0]
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to call git check-attr: %v", err)
+	}
+	return values, scanner.Err()
+}
+
 // GetTrackedFiles returns a list of files which are tracked in Git which match
 // the pattern specified (standard wildcard form)
 // Both pattern and the results are relative to the current working directory, not
@@ -1349,9 +1549,9 @@ func GetFilesChanged(from, to string) ([]string, error) {
 func IsFileModified(filepath string) (bool, error) {
 
 	args := []string{
-		"-c", "core.quotepath=false", // handle special chars in filenames
 		"status",
 		"--porcelain",
+		"-z", // NUL-terminate entries so that odd filenames aren't quoted
 		"--", // separator in case filename ambiguous
 		filepath,
 	}
@@ -1364,17 +1564,25 @@ func IsFileModified(filepath string) (bool, error) {
 		return false, lfserrors.Wrap(err, "Failed to start git status")
 	}
 	matched := false
-	for scanner := bufio.NewScanner(outp); scanner.Scan(); {
+	scanner := bufio.NewScanner(outp)
+	scanner.Split(tools.SplitOnNul)
+	for scanner.Scan() {
 		line := scanner.Text()
 		// Porcelain format is "<I><W> <filename>"
 		// Where <I> = index status, <W> = working copy status
 		if len(line) > 3 {
 			// Double-check even though should be only match
-			if strings.TrimSpace(line[3:]) == filepath {
+			if line[3:] == filepath {
 				matched = true
 				// keep consuming output to exit cleanly
 				// will typically fall straight through anyway due to 1 line output
 			}
+
+			// Renames and copies are followed by an additional
+			// entry naming the original path, which we skip.
+			if line[0] == 'R' || line[0] == 'C' {
+				scanner.Scan()
+			}
 		}
 	}
 	if err := cmd.Wait(); err != nil {
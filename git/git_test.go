@@ -674,6 +674,115 @@ func TestGetFilesChanges(t *testing.T) {
 
 }
 
+func TestResolveRevisionRange(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	commits := repo.AddCommits([]*test.CommitInput{
+		{
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+			},
+		},
+		{
+			Files: []*test.FileInput{
+				{Filename: "file2.txt", Size: 20},
+			},
+			Tags: []string{"tag1"},
+		},
+		{
+			NewBranch:      "abranch",
+			ParentBranches: []string{"master"},
+			Files: []*test.FileInput{
+				{Filename: "file3.txt", Size: 30},
+			},
+		},
+	})
+
+	assert.True(t, IsRevisionRange("tag1..abranch"))
+	assert.True(t, IsRevisionRange("tag1...abranch"))
+	assert.False(t, IsRevisionRange("abranch"))
+
+	include, exclude, err := ResolveRevisionRange(commits[1].Sha + "..abranch")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{commits[2].Sha}, include)
+	assert.Equal(t, []string{commits[1].Sha}, exclude)
+
+	include, exclude, err = ResolveRevisionRange(commits[0].Sha + "...abranch")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{commits[2].Sha, commits[0].Sha}, include)
+	assert.Equal(t, []string{commits[0].Sha}, exclude)
+
+	_, _, err = ResolveRevisionRange("tag1..nonexisting")
+	assert.NotNil(t, err)
+}
+
+func TestMergeBase(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	commits := repo.AddCommits([]*test.CommitInput{
+		{
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+			},
+		},
+		{
+			NewBranch:      "abranch",
+			ParentBranches: []string{"master"},
+			Files: []*test.FileInput{
+				{Filename: "file2.txt", Size: 20},
+			},
+		},
+	})
+
+	base, err := MergeBase("master", "abranch")
+	assert.Nil(t, err)
+	assert.Equal(t, commits[0].Sha, base)
+
+	_, err = MergeBase("master", "nonexisting")
+	assert.NotNil(t, err)
+}
+
+func TestIsFileModified(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	repo.AddCommits([]*test.CommitInput{
+		{
+			Files: []*test.FileInput{
+				{Filename: "-r.dat", Size: 20},
+				{Filename: "a file.dat", Size: 20},
+				{Filename: "a\"quote.dat", Size: 20},
+			},
+		},
+	})
+
+	for _, name := range []string{"-r.dat", "a file.dat", "a\"quote.dat"} {
+		modified, err := IsFileModified(name)
+		assert.Nil(t, err, name)
+		assert.False(t, modified, name)
+
+		assert.Nil(t, ioutil.WriteFile(name, []byte("changed"), 0644))
+
+		modified, err = IsFileModified(name)
+		assert.Nil(t, err, name)
+		assert.True(t, modified, name)
+	}
+}
+
 func TestValidateRemoteURL(t *testing.T) {
 	assert.Nil(t, ValidateRemoteURL("https://github.com/git-lfs/git-lfs"))
 	assert.Nil(t, ValidateRemoteURL("http://github.com/git-lfs/git-lfs"))
@@ -26,6 +26,9 @@ type refUpdater struct {
 	// Root is the given directory on disk in which the repository is
 	// located.
 	Root string
+	// Prefix, if non-empty, is the prefix under which to write the
+	// migrated counterpart of each ref, instead of moving the ref itself.
+	Prefix string
 
 	db *gitobj.ObjectDatabase
 }
@@ -57,6 +60,27 @@ func (r *refUpdater) UpdateRefs() error {
 	return nil
 }
 
+// targetName returns the name of the ref to which the migrated counterpart of
+// the ref named "refspec" should be written.
+func (r *refUpdater) targetName(refspec string) string {
+	if len(r.Prefix) == 0 {
+		return refspec
+	}
+	return r.Prefix + "/" + strings.TrimPrefix(refspec, "refs/")
+}
+
+// resolveUpdated returns the ref named "refspec" as it is after being
+// migrated, which is its counterpart under the prefix, if any, or the ref
+// itself if that hasn't been written.
+func (r *refUpdater) resolveUpdated(refspec string) (*git.Ref, error) {
+	if target := r.targetName(refspec); target != refspec {
+		if ref, err := git.ResolveRef(target); err == nil {
+			return ref, nil
+		}
+	}
+	return git.ResolveRef(refspec)
+}
+
 func (r *refUpdater) updateOneTag(tag *gitobj.Tag, toObj []byte) ([]byte, error) {
 	newTag, err := r.db.WriteTag(&gitobj.Tag{
 		Object:     toObj,
@@ -104,7 +128,7 @@ func (r *refUpdater) updateOneRef(list *tasklog.ListTask, maxNameLen int, seen m
 				}
 			}
 
-			updated, err := git.ResolveRef(name)
+			updated, err := r.resolveUpdated(name)
 			if err != nil {
 				return err
 			}
@@ -138,11 +162,24 @@ func (r *refUpdater) updateOneRef(list *tasklog.ListTask, maxNameLen int, seen m
 		return nil
 	}
 
-	if err := git.UpdateRefIn(r.Root, ref, to, ""); err != nil {
+	target := ref
+	if len(r.Prefix) > 0 {
+		target = &git.Ref{
+			Name: r.targetName(refspec),
+			Type: git.RefTypeOther,
+			Sha:  ref.Sha,
+		}
+	}
+
+	if err := git.UpdateRefIn(r.Root, target, to, ""); err != nil {
 		return err
 	}
 
 	namePadding := tools.MaxInt(maxNameLen-len(ref.Name), 0)
-	list.Entry(fmt.Sprintf("  %s%s\t%s -> %x", ref.Name, strings.Repeat(" ", namePadding), ref.Sha, to))
+	if target != ref {
+		list.Entry(fmt.Sprintf("  %s%s\t%s -> %s %x", ref.Name, strings.Repeat(" ", namePadding), ref.Sha, target.Name, to))
+	} else {
+		list.Entry(fmt.Sprintf("  %s%s\t%s -> %x", ref.Name, strings.Repeat(" ", namePadding), ref.Sha, to))
+	}
 	return nil
 }
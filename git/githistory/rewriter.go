@@ -13,6 +13,7 @@ import (
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/tasklog"
 	"github.com/git-lfs/gitobj/v2"
+	gitobjerrors "github.com/git-lfs/gitobj/v2/errors"
 )
 
 // Rewriter allows rewriting topologically equivalent Git histories
@@ -51,6 +52,11 @@ type RewriteOptions struct {
 	// original graph onto the migrated one. If true, the refs will be
 	// moved, and a reflog entry will be created.
 	UpdateRefs bool
+	// RefPrefix, if non-empty, specifies that the refs should be left
+	// where they are, and their migrated counterparts written under the
+	// prefix instead. For example, with a prefix of "refs/migrated",
+	// "refs/heads/main" is migrated to "refs/migrated/heads/main".
+	RefPrefix string
 
 	// Verbose mode prints migrated objects.
 	Verbose bool
@@ -59,6 +65,14 @@ type RewriteOptions struct {
 	// commits
 	ObjectMapFilePath string
 
+	// Workers is the number of blobs which may be rewritten at once. If
+	// greater than one, the blobs of each commit are all rewritten by that
+	// many goroutines before its trees are reassembled, and the BlobFn
+	// must therefore be safe to call concurrently. Commits are still
+	// rewritten one at a time and in order, so the history written is the
+	// same as when rewriting serially.
+	Workers int
+
 	// BlobFn specifies a function to rewrite blobs.
 	//
 	// It is called once per unique, unchanged path. That is to say, if
@@ -67,6 +81,25 @@ type RewriteOptions struct {
 	// each blob for subsequent revisions, so long as each entry remains
 	// unchanged.
 	BlobFn BlobRewriteFn
+	// MissingBlobFn specifies a function to find the blobs which the
+	// BlobFn is to be called on, but which are not in the object
+	// database, such as those left out of a partial clone. If nil, a
+	// missing blob is an error.
+	MissingBlobFn MissingBlobFn
+	// MissingBlobsFn, if non-nil, is called once before any commit is
+	// rewritten with the SHA1s of all the blobs the MissingBlobFn will be
+	// called on, so that they can be found in one go, such as by a single
+	// fetch, rather than one at a time.
+	MissingBlobsFn MissingBlobsFn
+	// BlobCache, if non-nil, holds blobs rewritten by the BlobFn before,
+	// such as by an earlier rewrite of the same history, which are used
+	// again instead of calling the BlobFn on them. Each blob the BlobFn
+	// rewrites is added to it.
+	BlobCache BlobCache
+	// ProgressFn, if non-nil, returns more about the work done so far,
+	// such as its throughput, which is shown after the count of commits
+	// rewritten each time a commit is.
+	ProgressFn func() string
 	// TreePreCallbackFn specifies a function to be called before opening a
 	// tree for rewriting. It will be called on all trees throughout history
 	// in topological ordering through the tree, starting at the root.
@@ -120,6 +153,30 @@ func (r *RewriteOptions) treeFn() TreeCallbackFn {
 // of filepath.Join(...) or os.PathSeparator.
 type BlobRewriteFn func(path string, b *gitobj.Blob) (*gitobj.Blob, error)
 
+// MissingBlobFn is a function that takes the SHA1 "oid" of a blob which is
+// not in the object database, along with the commit and path at which it was
+// found, and returns the blob, having found it elsewhere. If it returns an
+// error, that error will be returned from the Rewrite() function.
+type MissingBlobFn func(commitOID []byte, path string, oid []byte) (*gitobj.Blob, error)
+
+// MissingBlobsFn is a function that takes the SHA1s of the blobs which are not
+// in the object database, but which are to be rewritten. If it returns an
+// error, that error will be returned from the Rewrite() function.
+type MissingBlobsFn func(oids [][]byte) error
+
+// BlobCache holds the blobs which the BlobFn has rewritten, by the SHA1 of the
+// original blob. If the Workers of the *RewriteOptions are greater than one,
+// it must be safe for concurrent use.
+type BlobCache interface {
+	// Rewritten returns the SHA1 of the blob which the blob "from", of
+	// size "size", was rewritten to by the BlobFn, if the BlobFn would
+	// rewrite it the same way at "path", and that blob is in the object
+	// database.
+	Rewritten(path string, from []byte, size int64) ([]byte, bool)
+	// Add records that the blob "from" was rewritten to the blob "to".
+	Add(from, to []byte)
+}
+
 // TreePreCallbackFn specifies a function to call upon opening a new tree for
 // rewriting.
 //
@@ -217,11 +274,27 @@ func (r *Rewriter) Rewrite(opt *RewriteOptions) ([]byte, error) {
 		perc = r.l.Percentage("migrate: Examining commits", uint64(len(commits)))
 	}
 
+	if opt.ProgressFn != nil {
+		perc.Detail(opt.ProgressFn)
+	}
+
 	var vPerc *tasklog.PercentageTask
 	if opt.Verbose {
 		vPerc = perc
 	}
 
+	if opt.MissingBlobsFn != nil {
+		missing, err := r.missingBlobs(commits)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			if err := opt.MissingBlobsFn(missing); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	var objectMapFile *os.File
 	if len(opt.ObjectMapFilePath) > 0 {
 		objectMapFile, err = os.OpenFile(opt.ObjectMapFilePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
@@ -243,7 +316,7 @@ func (r *Rewriter) Rewrite(opt *RewriteOptions) ([]byte, error) {
 		}
 
 		// Rewrite the tree given at that commit.
-		rewrittenTree, err := r.rewriteTree(oid, original.TreeID, "", opt.blobFn(), opt.treePreFn(), opt.treeFn(), vPerc)
+		rewrittenTree, err := r.rewriteTree(oid, original.TreeID, "", opt.blobFn(), opt.MissingBlobFn, opt.BlobCache, opt.treePreFn(), opt.treeFn(), opt.Workers, vPerc)
 		if err != nil {
 			return nil, err
 		}
@@ -327,6 +400,7 @@ func (r *Rewriter) Rewrite(opt *RewriteOptions) ([]byte, error) {
 			Logger:  r.l,
 			Refs:    refs,
 			Root:    root,
+			Prefix:  opt.RefPrefix,
 
 			db: r.db,
 		}
@@ -348,11 +422,15 @@ func (r *Rewriter) Rewrite(opt *RewriteOptions) ([]byte, error) {
 // TreeCallbackFn, "tfn" to perform a final traversal of the subtree before
 // saving it to the object database.
 //
+// If "workers" is greater than one, the blobs beneath the root tree are
+// rewritten concurrently by rewriteBlobs once the TreePreCallbackFn of the root
+// tree has been called, and found in the cache afterwards.
+//
 // It returns the new SHA of the rewritten tree, or an error if the tree was
 // unable to be rewritten.
 func (r *Rewriter) rewriteTree(commitOID []byte, treeOID []byte, path string,
-	fn BlobRewriteFn, tpfn TreePreCallbackFn, tfn TreeCallbackFn,
-	perc *tasklog.PercentageTask) ([]byte, error) {
+	fn BlobRewriteFn, mfn MissingBlobFn, cache BlobCache, tpfn TreePreCallbackFn,
+	tfn TreeCallbackFn, workers int, perc *tasklog.PercentageTask) ([]byte, error) {
 
 	tree, err := r.db.Tree(treeOID)
 	if err != nil {
@@ -363,6 +441,12 @@ func (r *Rewriter) rewriteTree(commitOID []byte, treeOID []byte, path string,
 		return nil, err
 	}
 
+	if len(path) == 0 && workers > 1 {
+		if err := r.rewriteBlobs(commitOID, tree, fn, mfn, cache, workers, perc); err != nil {
+			return nil, err
+		}
+	}
+
 	entries := make([]*gitobj.TreeEntry, 0, len(tree.Entries))
 	for _, entry := range tree.Entries {
 		var fullpath string
@@ -393,9 +477,9 @@ func (r *Rewriter) rewriteTree(commitOID []byte, treeOID []byte, path string,
 
 		switch entry.Type() {
 		case gitobj.BlobObjectType:
-			oid, err = r.rewriteBlob(commitOID, entry.Oid, fullpath, fn, perc)
+			oid, err = r.rewriteBlob(commitOID, entry.Oid, fullpath, fn, mfn, cache, perc)
 		case gitobj.TreeObjectType:
-			oid, err = r.rewriteTree(commitOID, entry.Oid, fullpath, fn, tpfn, tfn, perc)
+			oid, err = r.rewriteTree(commitOID, entry.Oid, fullpath, fn, mfn, cache, tpfn, tfn, workers, perc)
 		default:
 			oid = entry.Oid
 
@@ -422,6 +506,191 @@ func (r *Rewriter) rewriteTree(commitOID []byte, treeOID []byte, path string,
 	return r.db.WriteTree(rewritten)
 }
 
+// blobJob is a blob for rewriteBlobs to rewrite.
+type blobJob struct {
+	// path is the path of the entry "entry", as given to the BlobFn.
+	path  string
+	entry *gitobj.TreeEntry
+}
+
+// rewriteBlobs rewrites each blob beneath the root tree "tree" which
+// rewriteTree would otherwise rewrite, using "workers" goroutines at once, and
+// caches the rewritten entries, so that rewriteTree then finds them there.
+//
+// The same blobs are rewritten as by rewriteTree: those entries which are
+// already cached, or appear more than once, are skipped, along with the
+// subtrees which are.
+func (r *Rewriter) rewriteBlobs(commitOID []byte, tree *gitobj.Tree, fn BlobRewriteFn, mfn MissingBlobFn, cache BlobCache, workers int, perc *tasklog.PercentageTask) error {
+	var jobs []*blobJob
+	if err := r.collectBlobs(tree, "", make(map[string]struct{}), &jobs); err != nil {
+		return err
+	}
+
+	queue := make(chan *blobJob, len(jobs))
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		errBlob error
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range queue {
+				errMu.Lock()
+				failed := errBlob != nil
+				errMu.Unlock()
+				if failed {
+					// Drain the rest without rewriting them.
+					continue
+				}
+
+				oid, err := r.rewriteBlob(commitOID, job.entry.Oid, job.path, fn, mfn, cache, perc)
+				if err != nil {
+					errMu.Lock()
+					if errBlob == nil {
+						errBlob = err
+					}
+					errMu.Unlock()
+					continue
+				}
+
+				r.cacheEntry(job.entry, &gitobj.TreeEntry{
+					Filemode: job.entry.Filemode,
+					Name:     job.entry.Name,
+					Oid:      oid,
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errBlob
+}
+
+// collectBlobs appends a blobJob for each entry beneath "tree", whose path is
+// "path", that rewriteTree would give to rewriteBlob, in the order it would do
+// so. Entries in "seen" are skipped, and those visited added to it.
+func (r *Rewriter) collectBlobs(tree *gitobj.Tree, path string, seen map[string]struct{}, jobs *[]*blobJob) error {
+	for _, entry := range tree.Entries {
+		var fullpath string
+		if len(path) > 0 {
+			fullpath = strings.Join([]string{path, entry.Name}, "/")
+		} else {
+			fullpath = entry.Name
+		}
+
+		if !r.allows(entry.Type(), fullpath) || entry.Filemode == 0120000 {
+			continue
+		}
+		if cached := r.uncacheEntry(entry); cached != nil {
+			continue
+		}
+
+		key := r.entryKey(entry)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		switch entry.Type() {
+		case gitobj.BlobObjectType:
+			*jobs = append(*jobs, &blobJob{path: fullpath, entry: entry})
+		case gitobj.TreeObjectType:
+			subtree, err := r.db.Tree(entry.Oid)
+			if err != nil {
+				return err
+			}
+			if err := r.collectBlobs(subtree, fullpath, seen, jobs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// missingBlobs returns the SHA1s of the blobs in the trees of "commits" which
+// would be rewritten, but which are not in the object database, each once.
+func (r *Rewriter) missingBlobs(commits [][]byte) ([][]byte, error) {
+	var missing [][]byte
+	seen := make(map[string]struct{})
+	for _, oid := range commits {
+		commit, err := r.db.Commit(oid)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.collectMissingBlobs(commit.TreeID, "", seen, &missing); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// collectMissingBlobs appends to "missing" the SHA1 of each blob beneath the
+// tree "treeOID", whose path is "path", which missingBlobs would return.
+// Blobs, and the trees at each path, in "seen" are skipped, and those visited
+// added to it.
+func (r *Rewriter) collectMissingBlobs(treeOID []byte, path string, seen map[string]struct{}, missing *[][]byte) error {
+	tree, err := r.db.Tree(treeOID)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range tree.Entries {
+		var fullpath string
+		if len(path) > 0 {
+			fullpath = strings.Join([]string{path, entry.Name}, "/")
+		} else {
+			fullpath = entry.Name
+		}
+
+		if !r.allows(entry.Type(), fullpath) || entry.Filemode == 0120000 {
+			continue
+		}
+
+		switch entry.Type() {
+		case gitobj.BlobObjectType:
+			key := hex.EncodeToString(entry.Oid)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			blob, err := r.db.Blob(entry.Oid)
+			if err != nil {
+				if !gitobjerrors.IsNoSuchObject(err) {
+					return err
+				}
+				*missing = append(*missing, entry.Oid)
+				continue
+			}
+			if err := blob.Close(); err != nil {
+				return err
+			}
+		case gitobj.TreeObjectType:
+			// The same tree may hold different blobs to rewrite
+			// at different paths, as the filter gives them.
+			key := fullpath + ":" + hex.EncodeToString(entry.Oid)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			if err := r.collectMissingBlobs(entry.Oid, fullpath, seen, missing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func copyEntry(e *gitobj.TreeEntry) *gitobj.TreeEntry {
 	if e == nil {
 		return nil
@@ -459,10 +728,33 @@ func (r *Rewriter) allows(typ gitobj.ObjectType, abs string) bool {
 // database by the SHA1 "from" []byte. It writes and returns the new blob SHA,
 // or an error if either the BlobRewriteFn returned one, or if the object could
 // not be loaded/saved.
-func (r *Rewriter) rewriteBlob(commitOID, from []byte, path string, fn BlobRewriteFn, perc *tasklog.PercentageTask) ([]byte, error) {
+//
+// If the BlobCache "cache" has the blob, the rewritten blob it gives is used
+// instead of calling "fn", and otherwise the blob "fn" returns is added to it.
+func (r *Rewriter) rewriteBlob(commitOID, from []byte, path string, fn BlobRewriteFn, mfn MissingBlobFn, cache BlobCache, perc *tasklog.PercentageTask) ([]byte, error) {
 	blob, err := r.db.Blob(from)
 	if err != nil {
-		return nil, err
+		if !gitobjerrors.IsNoSuchObject(err) {
+			return nil, err
+		}
+		if mfn == nil {
+			return nil, fmt.Errorf("blob %x of %s in commit %x is missing, such as from a partial clone", from, path, commitOID)
+		}
+		if blob, err = mfn(commitOID, path, from); err != nil {
+			return nil, err
+		}
+	}
+
+	if cache != nil {
+		if sha, ok := cache.Rewritten(path, from, blob.Size); ok {
+			if err := blob.Close(); err != nil {
+				return nil, err
+			}
+			if perc != nil {
+				perc.Entry(fmt.Sprintf("migrate: commit %s: %s (unchanged)", hex.EncodeToString(commitOID), path))
+			}
+			return sha, nil
+		}
 	}
 
 	b, err := fn(path, blob)
@@ -487,6 +779,10 @@ func (r *Rewriter) rewriteBlob(commitOID, from []byte, path string, fn BlobRewri
 			return nil, err
 		}
 
+		if cache != nil {
+			cache.Add(from, sha)
+		}
+
 		if perc != nil {
 			perc.Entry(fmt.Sprintf("migrate: commit %s: %s", hex.EncodeToString(commitOID), path))
 		}
@@ -5,9 +5,12 @@ import (
 	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/git-lfs/git-lfs/errors"
@@ -168,6 +171,244 @@ func TestRewriterDoesntVisitUnchangedSubtrees(t *testing.T) {
 	assert.Equal(t, 1, seen["subdir/b.txt"])
 }
 
+func TestRewriterRewritesHistoryWithWorkers(t *testing.T) {
+	fn := func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+		contents, err := ioutil.ReadAll(b.Contents)
+		if err != nil {
+			return nil, err
+		}
+
+		rewritten := string(contents) + "rewritten\n"
+
+		return &gitobj.Blob{
+			Contents: strings.NewReader(rewritten),
+			Size:     int64(len(rewritten)),
+		}, nil
+	}
+
+	for _, fixture := range []string{"linear-history.git", "non-repeated-subtrees.git", "repeated-subtrees.git"} {
+		t.Run(fixture, func(t *testing.T) {
+			db := DatabaseFromFixture(t, fixture)
+
+			serial, err := NewRewriter(db).Rewrite(&RewriteOptions{
+				Include: []string{"refs/heads/master"},
+				BlobFn:  fn,
+			})
+			assert.Nil(t, err)
+
+			parallel, err := NewRewriter(db).Rewrite(&RewriteOptions{
+				Include: []string{"refs/heads/master"},
+				BlobFn:  fn,
+				Workers: 4,
+			})
+			assert.Nil(t, err)
+
+			assert.Equal(t, hex.EncodeToString(serial), hex.EncodeToString(parallel))
+		})
+	}
+}
+
+func TestRewriterDoesntVisitUnchangedSubtreesWithWorkers(t *testing.T) {
+	db := DatabaseFromFixture(t, "repeated-subtrees.git")
+	r := NewRewriter(db)
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+
+	_, err := r.Rewrite(&RewriteOptions{Include: []string{"refs/heads/master"},
+		BlobFn: func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			seen[path] = seen[path] + 1
+
+			return b, nil
+		},
+		Workers: 4,
+	})
+
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, seen["a.txt"])
+	assert.Equal(t, 1, seen["subdir/b.txt"])
+}
+
+func TestRewriterReturnsBlobErrorsWithWorkers(t *testing.T) {
+	db := DatabaseFromFixture(t, "non-repeated-subtrees.git")
+	r := NewRewriter(db)
+
+	_, err := r.Rewrite(&RewriteOptions{Include: []string{"refs/heads/master"},
+		BlobFn: func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+			return nil, errors.New("example error")
+		},
+		Workers: 4,
+	})
+
+	assert.EqualError(t, err, "example error")
+}
+
+// mapBlobCache is a BlobCache holding every blob added to it.
+type mapBlobCache struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (c *mapBlobCache) Rewritten(path string, from []byte, size int64) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	to, ok := c.blobs[hex.EncodeToString(from)]
+	return to, ok
+}
+
+func (c *mapBlobCache) Add(from, to []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blobs[hex.EncodeToString(from)] = to
+}
+
+func TestRewriterUsesBlobCache(t *testing.T) {
+	db := DatabaseFromFixture(t, "linear-history.git")
+	cache := &mapBlobCache{blobs: make(map[string][]byte)}
+
+	var calls int
+	fn := func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+		calls++
+
+		contents, err := ioutil.ReadAll(b.Contents)
+		if err != nil {
+			return nil, err
+		}
+
+		rewritten := string(contents) + "rewritten\n"
+
+		return &gitobj.Blob{
+			Contents: strings.NewReader(rewritten),
+			Size:     int64(len(rewritten)),
+		}, nil
+	}
+
+	first, err := NewRewriter(db).Rewrite(&RewriteOptions{
+		Include:   []string{"refs/heads/master"},
+		BlobFn:    fn,
+		BlobCache: cache,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, cache.blobs, 3)
+
+	calls = 0
+	second, err := NewRewriter(db).Rewrite(&RewriteOptions{
+		Include:   []string{"refs/heads/master"},
+		BlobFn:    fn,
+		BlobCache: cache,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, hex.EncodeToString(first), hex.EncodeToString(second))
+}
+
+// databaseWithoutObject returns a copy of the fixture "name" which is missing
+// the loose object "oid", as from a partial clone.
+func databaseWithoutObject(t *testing.T, name, oid string) *gitobj.ObjectDatabase {
+	path, err := copyToTmp(filepath.Join("fixtures", name))
+	if err != nil {
+		t.Fatalf("gitobj: could not copy fixture %s: %v", name, err)
+	}
+	if err := os.Remove(filepath.Join(path, "objects", oid[:2], oid[2:])); err != nil {
+		t.Fatalf("gitobj: could not remove object %s: %v", oid, err)
+	}
+
+	db, err := gitobj.FromFilesystem(filepath.Join(path, "objects"), "")
+	if err != nil {
+		t.Fatalf("gitobj: could not create object database: %v", err)
+	}
+	return db
+}
+
+func TestRewriterFindsMissingBlobs(t *testing.T) {
+	// The blob of "hello.txt" in the root commit, holding "1".
+	missing := "56a6051ca2b02b04ef92d5150c9ef600403cb1de"
+	db := databaseWithoutObject(t, "linear-history.git", missing)
+	r := NewRewriter(db)
+
+	var found []string
+	tip, err := r.Rewrite(&RewriteOptions{Include: []string{"refs/heads/master"},
+		BlobFn: func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+			contents, err := ioutil.ReadAll(b.Contents)
+			if err != nil {
+				return nil, err
+			}
+			return gitobj.NewBlobFromBytes(append(contents, '!')), nil
+		},
+		MissingBlobFn: func(commitOID []byte, path string, oid []byte) (*gitobj.Blob, error) {
+			found = append(found, path+" "+hex.EncodeToString(oid))
+			return gitobj.NewBlobFromBytes([]byte("1")), nil
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hello.txt " + missing}, found)
+
+	root := hex.EncodeToString(tip)
+	for i := 0; i < 2; i++ {
+		commit, err := db.Commit(HexDecode(t, root))
+		assert.Nil(t, err)
+		root = hex.EncodeToString(commit.ParentIDs[0])
+	}
+	commit, err := db.Commit(HexDecode(t, root))
+	assert.Nil(t, err)
+	AssertBlobContents(t, db, hex.EncodeToString(commit.TreeID), "hello.txt", "1!")
+}
+
+func TestRewriterFindsMissingBlobsInOneGo(t *testing.T) {
+	missing := "56a6051ca2b02b04ef92d5150c9ef600403cb1de"
+	db := databaseWithoutObject(t, "linear-history.git", missing)
+	r := NewRewriter(db)
+
+	var batches [][]string
+	var found []string
+	_, err := r.Rewrite(&RewriteOptions{Include: []string{"refs/heads/master"},
+		MissingBlobsFn: func(oids [][]byte) error {
+			var batch []string
+			for _, oid := range oids {
+				batch = append(batch, hex.EncodeToString(oid))
+			}
+			batches = append(batches, batch)
+			return nil
+		},
+		MissingBlobFn: func(commitOID []byte, path string, oid []byte) (*gitobj.Blob, error) {
+			if len(batches) == 0 {
+				t.Fatalf("blob %x requested before the missing blobs were listed", oid)
+			}
+			found = append(found, path+" "+hex.EncodeToString(oid))
+			return gitobj.NewBlobFromBytes([]byte("1")), nil
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{missing}}, batches)
+	assert.Equal(t, []string{"hello.txt " + missing}, found)
+}
+
+func TestRewriterReturnsErrorsForMissingBlobs(t *testing.T) {
+	missing := "56a6051ca2b02b04ef92d5150c9ef600403cb1de"
+	db := databaseWithoutObject(t, "linear-history.git", missing)
+	r := NewRewriter(db)
+
+	_, err := r.Rewrite(&RewriteOptions{Include: []string{"refs/heads/master"},
+		BlobFn: func(path string, b *gitobj.Blob) (*gitobj.Blob, error) {
+			return b, nil
+		},
+	})
+
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "blob "+missing+" of hello.txt")
+		assert.Contains(t, err.Error(), "is missing")
+	}
+}
+
 func TestRewriterVisitsUniqueEntriesWithIdenticalContents(t *testing.T) {
 	db := DatabaseFromFixture(t, "identical-blobs.git")
 	r := NewRewriter(db)
@@ -20,7 +20,9 @@ type LsFiles struct {
 	FilesByName map[string][]*lsFileInfo
 }
 
-func NewLsFiles(workingDir string, standardExclude bool) (*LsFiles, error) {
+// NewLsFiles lists the tracked and untracked files in "workingDir", limited to
+// those matching "pathspecs", if any are given.
+func NewLsFiles(workingDir string, standardExclude bool, pathspecs ...string) (*LsFiles, error) {
 
 	args := []string{
 		"ls-files",
@@ -32,6 +34,9 @@ func NewLsFiles(workingDir string, standardExclude bool) (*LsFiles, error) {
 	if standardExclude {
 		args = append(args, "--exclude-standard")
 	}
+	if len(pathspecs) > 0 {
+		args = append(append(args, "--"), pathspecs...)
+	}
 	cmd := gitNoLFS(args...)
 	cmd.Dir = workingDir
 
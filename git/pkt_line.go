@@ -44,28 +44,46 @@ func newPktline(r io.Reader, w io.Writer) *pktline {
 // If none of the above cases fit the state of the data on the wire, the packet
 // is returned along with a nil error.
 func (p *pktline) readPacket() ([]byte, error) {
+	data, pktLen, err := p.readPacketWithLength()
+	if err == nil && pktLen == delimPacketLength {
+		return nil, errors.New("invalid packet length")
+	}
+	return data, err
+}
+
+const (
+	// flushPacketLength and delimPacketLength are the lengths returned by
+	// readPacketWithLength for flush packets, and for the delimiter
+	// packets of Git's protocol version 2.
+	flushPacketLength = 0
+	delimPacketLength = 1
+)
+
+// readPacketWithLength reads a single packet like readPacket, but also returns
+// the length given in its header, telling flush packets from delimiter
+// packets, neither of which hold any data.
+func (p *pktline) readPacketWithLength() ([]byte, int, error) {
 	var pktLenHex [4]byte
 	if n, err := io.ReadFull(p.r, pktLenHex[:]); err != nil {
-		return nil, err
+		return nil, 0, err
 	} else if n != 4 {
-		return nil, io.ErrShortBuffer
+		return nil, 0, io.ErrShortBuffer
 	}
 
 	pktLen, err := strconv.ParseInt(string(pktLenHex[:]), 16, 0)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	// pktLen==0: flush packet
-	if pktLen == 0 {
-		return nil, nil
+	if pktLen == flushPacketLength || pktLen == delimPacketLength {
+		return nil, int(pktLen), nil
 	}
 	if pktLen <= 4 {
-		return nil, errors.New("invalid packet length")
+		return nil, 0, errors.New("invalid packet length")
 	}
 
 	payload, err := ioutil.ReadAll(io.LimitReader(p.r, pktLen-4))
-	return payload, err
+	return payload, int(pktLen), err
 }
 
 // readPacketText follows identical semantics to the `readPacket()` function,
@@ -138,6 +156,14 @@ func (p *pktline) writeFlush() error {
 	return nil
 }
 
+// writeDelim writes a "delimiter" packet, which separates the sections of a
+// message in Git's protocol version 2. Like writePacket, it does not flush the
+// underlying buffered writer.
+func (p *pktline) writeDelim() error {
+	_, err := p.w.WriteString(fmt.Sprintf("%04x", delimPacketLength))
+	return err
+}
+
 // writePacketText follows the same semantics as `writePacket`, but appends a
 // trailing "\n" LF character to the end of the data.
 func (p *pktline) writePacketText(data string) error {
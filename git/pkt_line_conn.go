@@ -0,0 +1,83 @@
+package git
+
+import (
+	"io"
+	"strings"
+)
+
+// Pktline reads and writes the messages of protocols built on Git's pkt-line
+// format other than the filter process protocol, such as the SSH transfer
+// protocol of git-lfs-transfer(1), whose messages may be divided into
+// sections by delimiter packets.
+type Pktline struct {
+	pl *pktline
+}
+
+// NewPktline returns a *Pktline reading packets from "r" and writing them to
+// "w".
+func NewPktline(r io.Reader, w io.Writer) *Pktline {
+	return &Pktline{pl: newPktline(r, w)}
+}
+
+// ReadPacketTextWithLength reads a single packet, returning its data without
+// any trailing LF, along with the length given in its header, which is 0 for
+// a flush packet and 1 for a delimiter packet.
+func (p *Pktline) ReadPacketTextWithLength() (string, int, error) {
+	data, pktLen, err := p.pl.readPacketWithLength()
+	return strings.TrimSuffix(string(data), "\n"), pktLen, err
+}
+
+// ReadPacketListToDelim reads packets of text until a flush packet or a
+// delimiter packet, returning their data and whether a delimiter packet ended
+// them.
+func (p *Pktline) ReadPacketListToDelim() ([]string, bool, error) {
+	var list []string
+	for {
+		data, pktLen, err := p.ReadPacketTextWithLength()
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch pktLen {
+		case flushPacketLength:
+			return list, false, nil
+		case delimPacketLength:
+			return list, true, nil
+		}
+		list = append(list, data)
+	}
+}
+
+// ReadPacketList reads packets of text until a flush packet, returning their
+// data.
+func (p *Pktline) ReadPacketList() ([]string, error) {
+	return p.pl.readPacketList()
+}
+
+// Reader returns an io.Reader of the data of the packets read until the next
+// flush packet, at which it returns io.EOF.
+func (p *Pktline) Reader() io.Reader {
+	return &pktlineReader{pl: p.pl}
+}
+
+// WritePacketText writes "data" in a single packet, followed by an LF.
+func (p *Pktline) WritePacketText(data string) error {
+	return p.pl.writePacketText(data)
+}
+
+// WriteDelim writes a delimiter packet.
+func (p *Pktline) WriteDelim() error {
+	return p.pl.writeDelim()
+}
+
+// WriteFlush writes a flush packet, and then everything written so far to the
+// underlying writer.
+func (p *Pktline) WriteFlush() error {
+	return p.pl.writeFlush()
+}
+
+// Writer returns a *PktlineWriter writing data in packets of the same stream,
+// whose Flush method writes a flush packet.
+func (p *Pktline) Writer() *PktlineWriter {
+	return &PktlineWriter{buf: make([]byte, 0, MaxPacketLength), pl: p.pl}
+}
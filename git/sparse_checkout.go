@@ -0,0 +1,134 @@
+package git
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/git-lfs/wildmatch"
+)
+
+// SparseCheckout matches paths against the patterns of a sparse checkout, as
+// written to "info/sparse-checkout" by git-sparse-checkout(1). The patterns
+// are matched like those of a .gitignore file, as Git does when cone mode is
+// off, which gives the same result as cone mode for the patterns it writes.
+type SparseCheckout struct {
+	patterns []*sparsePattern
+}
+
+type sparsePattern struct {
+	w *wildmatch.Wildmatch
+	// negated is set for patterns starting with "!", which exclude the
+	// paths they match.
+	negated bool
+	// dirOnly is set for patterns ending in "/", which match directories
+	// only.
+	dirOnly bool
+}
+
+// ReadSparseCheckout returns the sparse-checkout patterns of the Git directory
+// "gitDir", or nil if it has none.
+func ReadSparseCheckout(gitDir string) (*SparseCheckout, error) {
+	f, err := os.Open(filepath.Join(gitDir, "info", "sparse-checkout"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewSparseCheckout(f)
+}
+
+// SparseCheckoutChanging returns whether git-sparse-checkout(1) is changing
+// the sparse-checkout patterns of the Git directory "gitDir". While it does,
+// the working tree is updated for patterns which haven't been written yet, so
+// those read by ReadSparseCheckout are out of date.
+func SparseCheckoutChanging(gitDir string) bool {
+	_, err := os.Stat(filepath.Join(gitDir, "info", "sparse-checkout.lock"))
+	return err == nil
+}
+
+// NewSparseCheckout parses the sparse-checkout patterns read from "r", one per
+// line. Empty lines and those starting with "#" are ignored.
+func NewSparseCheckout(r io.Reader) (*SparseCheckout, error) {
+	s := &SparseCheckout{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := &sparsePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.negated = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		// Patterns with a slash in them are anchored to the root of
+		// the repository, and others match the last element of a path
+		// at any depth.
+		if strings.Contains(line, "/") {
+			p.w = wildmatch.NewWildmatch(strings.TrimPrefix(line, "/"))
+		} else {
+			p.w = wildmatch.NewWildmatch(line, wildmatch.Basename)
+		}
+		s.patterns = append(s.patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Includes returns whether the file at "path", relative to the root of the
+// repository and with forward slashes, is in the sparse checkout. As in Git,
+// the last pattern to match the path decides, and if none match, those
+// matching the closest directory holding it do. A nil *SparseCheckout
+// includes every path.
+func (s *SparseCheckout) Includes(path string) bool {
+	if s == nil {
+		return true
+	}
+
+	isDir := false
+	for len(path) > 0 {
+		if included, ok := s.match(path, isDir); ok {
+			return included
+		}
+
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			break
+		}
+		path, isDir = path[:i], true
+	}
+	return false
+}
+
+// match returns whether the last pattern matching "path" includes it, and
+// whether any pattern matched it at all.
+func (s *SparseCheckout) match(path string, isDir bool) (included, ok bool) {
+	for i := len(s.patterns) - 1; i >= 0; i-- {
+		p := s.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.w.Match(path) {
+			return !p.negated, true
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,103 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparseCheckoutCone(t *testing.T) {
+	// As written by "git sparse-checkout set a/b c".
+	s, err := NewSparseCheckout(strings.NewReader(strings.Join([]string{
+		"/*",
+		"!/*/",
+		"/a/",
+		"!/a/*/",
+		"/a/b/",
+		"/c/",
+	}, "\n")))
+	require.Nil(t, err)
+
+	for path, expected := range map[string]bool{
+		"root.dat":       true,
+		"a/file.dat":     true,
+		"a/b/file.dat":   true,
+		"a/b/d/file.dat": true,
+		"a/x/file.dat":   false,
+		"c/d/e/file.dat": true,
+		"d/file.dat":     false,
+		"ab/file.dat":    false,
+	} {
+		assert.Equal(t, expected, s.Includes(path), path)
+	}
+}
+
+func TestSparseCheckoutPatterns(t *testing.T) {
+	s, err := NewSparseCheckout(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"",
+		"*.dat",
+		"!skip/",
+		"docs/**/*.md",
+		"/top.bin",
+		"keep/",
+	}, "\n")))
+	require.Nil(t, err)
+
+	for path, expected := range map[string]bool{
+		"a.dat":              true,
+		"x/y/a.dat":          true,
+		"skip/a.dat":         true,
+		"skip/other.bin":     false,
+		"docs/a/b/readme.md": true,
+		"docs/readme.txt":    false,
+		"top.bin":            true,
+		"x/top.bin":          false,
+		"keep/x/y.bin":       true,
+		"keep":               false,
+		"other.bin":          false,
+	} {
+		assert.Equal(t, expected, s.Includes(path), path)
+	}
+}
+
+func TestSparseCheckoutNilIncludesAll(t *testing.T) {
+	var s *SparseCheckout
+	assert.True(t, s.Includes("any/path.dat"))
+}
+
+func TestReadSparseCheckout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sparse-checkout")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := ReadSparseCheckout(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, s)
+
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "info"), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "info", "sparse-checkout"), []byte("/a/\r\n"), 0644))
+
+	s, err = ReadSparseCheckout(dir)
+	require.Nil(t, err)
+	assert.True(t, s.Includes("a/file.dat"))
+	assert.False(t, s.Includes("b/file.dat"))
+}
+
+func TestSparseCheckoutChanging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sparse-checkout")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "info"), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "info", "sparse-checkout"), []byte("/a/\n"), 0644))
+	assert.False(t, SparseCheckoutChanging(dir))
+
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "info", "sparse-checkout.lock"), nil, 0644))
+	assert.True(t, SparseCheckoutChanging(dir))
+}
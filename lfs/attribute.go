@@ -2,6 +2,7 @@ package lfs
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/git-lfs/git-lfs/git"
@@ -43,10 +44,63 @@ func (o *FilterOptions) Install() error {
 	return filterAttribute().Install(o)
 }
 
+// ConfigChange describes what Install would do to one configuration key.
+type ConfigChange struct {
+	Key     string `json:"key"`
+	Current string `json:"current"`
+	Value   string `json:"value"`
+	// Action is "none" if the key already has the value, "set" if Install
+	// would set it, and "conflict" if it is set to another value which
+	// Install would refuse to overwrite without Force.
+	Action string `json:"action"`
+}
+
+// DryRun returns what Install would do to each of the configuration keys it
+// sets, sorted by key, without changing any of them.
+func (o *FilterOptions) DryRun() []*ConfigChange {
+	if o.SkipSmudge {
+		return skipSmudgeFilterAttribute().dryRun(o)
+	}
+	return filterAttribute().dryRun(o)
+}
+
 func (o *FilterOptions) Uninstall() error {
 	return filterAttribute().Uninstall(o)
 }
 
+// UninstallSkipSmudge undoes an Install with SkipSmudge set, by replacing the
+// filters which skip downloading objects with the ordinary ones. Other
+// configuration is left alone. An error is returned if the filters were not
+// installed with SkipSmudge.
+func (o *FilterOptions) UninstallSkipSmudge() error {
+	skip := skipSmudgeFilterAttribute()
+	key := skip.normalizeKey("smudge")
+	if value := skip.get(o.GitConfig, key, o); value != skip.Properties["smudge"] {
+		return fmt.Errorf("the %q attribute is %q, not %q; Git LFS was not installed with --skip-smudge",
+			key, value, skip.Properties["smudge"])
+	}
+
+	opt := *o
+	opt.Force = false
+	opt.SkipSmudge = false
+	return filterAttribute().Install(&opt)
+}
+
+// Scope returns the name of the configuration scope which the options apply
+// to: "local", "worktree", "system" or "global".
+func (o *FilterOptions) Scope() string {
+	switch {
+	case o.Local:
+		return "local"
+	case o.Worktree:
+		return "worktree"
+	case o.System:
+		return "system"
+	default:
+		return "global"
+	}
+}
+
 func filterAttribute() *Attribute {
 	return &Attribute{
 		Section: "filter.lfs",
@@ -123,27 +177,60 @@ func (a *Attribute) Install(opt *FilterOptions) error {
 	return nil
 }
 
+// dryRun returns what Install would do to each of the keys of this Attribute,
+// sorted by key, following the same rules as set.
+func (a *Attribute) dryRun(opt *FilterOptions) []*ConfigChange {
+	changes := make([]*ConfigChange, 0, len(a.Properties))
+	for k, v := range a.Properties {
+		key := a.normalizeKey(k)
+		change := &ConfigChange{
+			Key:     key,
+			Current: a.get(opt.GitConfig, key, opt),
+			Value:   v,
+		}
+
+		switch {
+		case change.Current == v:
+			change.Action = "none"
+		case opt.Force || shouldReset(change.Current, a.Upgradeables[k]):
+			change.Action = "set"
+		default:
+			change.Action = "conflict"
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
 // normalizeKey makes an absolute path out of a partial relative one. For a
 // relative path of "foo", and a root Section of "bar", "bar.foo" will be returned.
 func (a *Attribute) normalizeKey(relative string) string {
 	return strings.Join([]string{a.Section, relative}, ".")
 }
 
+// get returns the value of a single key of this Attribute in the scope given by
+// the options.
+func (a *Attribute) get(gitConfig *git.Configuration, key string, opt *FilterOptions) string {
+	if opt.Local {
+		return gitConfig.FindLocal(key)
+	} else if opt.Worktree {
+		return gitConfig.FindWorktree(key)
+	} else if opt.System {
+		return gitConfig.FindSystem(key)
+	}
+	return gitConfig.FindGlobal(key)
+}
+
 // set attempts to set a single key/value pair portion of this Attribute. If a
 // matching key already exists and the value is not equal to the desired value,
 // an error will be thrown if force is set to false. If force is true, the value
 // will be overridden.
 func (a *Attribute) set(gitConfig *git.Configuration, key, value string, upgradeables []string, opt *FilterOptions) error {
-	var currentValue string
-	if opt.Local {
-		currentValue = gitConfig.FindLocal(key)
-	} else if opt.Worktree {
-		currentValue = gitConfig.FindWorktree(key)
-	} else if opt.System {
-		currentValue = gitConfig.FindSystem(key)
-	} else {
-		currentValue = gitConfig.FindGlobal(key)
-	}
+	currentValue := a.get(gitConfig, key, opt)
 
 	if opt.Force || shouldReset(currentValue, upgradeables) {
 		var err error
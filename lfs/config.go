@@ -1,6 +1,20 @@
 package lfs
 
-import "github.com/git-lfs/git-lfs/config"
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/filepathfilter"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	pruneRetainKeyPrefix = "lfs.pruneretain."
+)
 
 // FetchPruneConfig collects together the config options that control fetching and pruning
 type FetchPruneConfig struct {
@@ -17,6 +31,14 @@ type FetchPruneConfig struct {
 	// Number of days added to FetchRecent*; data outside combined window will be
 	// deleted when prune is run. (default 3)
 	PruneOffsetDays int
+	// Number of days used in place of PruneOffsetDays for refs and commits
+	// found through remote tracking refs only, i.e., fetched from the
+	// remote (default PruneOffsetDays)
+	PruneIncomingOffsetDays int
+	// Number of days used in place of PruneOffsetDays for refs and commits
+	// found through local refs, i.e., committed locally and pushed from
+	// here (default PruneOffsetDays)
+	PruneOutgoingOffsetDays int
 	// Always verify with remote before pruning
 	PruneVerifyRemoteAlways bool
 	// Name of remote to check for unpushed and verify checks
@@ -25,6 +47,95 @@ type FetchPruneConfig struct {
 	PruneRecent bool
 	// Whether to delete everything pushed.
 	PruneForce bool
+	// Whether to retain objects referenced by commits which the remote
+	// tracking refs show to be unpushed (default true).
+	PruneKeepUnpushed bool
+	// Whether to ask the remote which of the objects to be deleted it has,
+	// and retain those it doesn't.
+	PruneVerifyUnpushed bool
+	// The largest total size of the local objects to keep, deleting only
+	// as many of those which aren't retained, least recently accessed
+	// first, as needed to stay under it (default 0 = delete all of them)
+	PruneCacheSizeLimit uint64
+	// The time since which the refs and commits to be retained are
+	// found, in place of the recent windows given by the days above, if
+	// not zero.
+	PruneKeepSince time.Time
+	// The rules retaining objects of files matching their patterns
+	// beyond what the options above retain, from
+	// "lfs.pruneretain.<pattern>.versions" and
+	// "lfs.pruneretain.<pattern>.days".
+	PruneRetentionRules []*PruneRetentionRule
+}
+
+// PruneRetentionRule retains the objects of the files matching a pattern,
+// either the given number of their latest versions, or every version of them
+// in the current checkout's history for the given number of days, or both.
+type PruneRetentionRule struct {
+	// Pattern is matched against the paths of files like the patterns
+	// given to --include.
+	Pattern string
+	// Versions is the number of the latest versions of each matching
+	// file to retain, including the one in the current checkout (0 =
+	// none).
+	Versions int
+	// Days is the number of days back from now for which every version
+	// of the matching files is retained (0 = none).
+	Days int
+
+	filter *filepathfilter.Filter
+}
+
+// Matches returns whether the file "name" matches the rule's pattern.
+func (r *PruneRetentionRule) Matches(name string) bool {
+	return r.filter.Allows(name)
+}
+
+// pruneRetentionRules returns the rules configured in "git", in the order of
+// their patterns. Values which aren't positive numbers are ignored, as are
+// rules with neither.
+func pruneRetentionRules(git config.Environment) []*PruneRetentionRule {
+	byPattern := make(map[string]*PruneRetentionRule)
+	for key, values := range git.All() {
+		if len(values) == 0 || !strings.HasPrefix(key, pruneRetainKeyPrefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(key, pruneRetainKeyPrefix)
+		dot := strings.LastIndex(rest, ".")
+		n, err := strconv.Atoi(values[len(values)-1])
+		if dot <= 0 || err != nil || n <= 0 {
+			tracerx.Printf("prune: ignoring retention rule %q", key)
+			continue
+		}
+		pattern := rest[:dot]
+
+		rule, ok := byPattern[pattern]
+		if !ok {
+			rule = &PruneRetentionRule{
+				Pattern: pattern,
+				filter:  filepathfilter.New([]string{pattern}, nil),
+			}
+		}
+		switch rest[dot+1:] {
+		case "versions":
+			rule.Versions = n
+		case "days":
+			rule.Days = n
+		default:
+			continue
+		}
+		byPattern[pattern] = rule
+	}
+
+	rules := make([]*PruneRetentionRule, 0, len(byPattern))
+	for _, rule := range byPattern {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Pattern < rules[j].Pattern
+	})
+	return rules
 }
 
 func NewFetchPruneConfig(git config.Environment) FetchPruneConfig {
@@ -33,15 +144,30 @@ func NewFetchPruneConfig(git config.Environment) FetchPruneConfig {
 		pruneRemote = "origin"
 	}
 
+	pruneOffsetDays := git.Int("lfs.pruneoffsetdays", 3)
+
+	// An invalid lfs.prune.cachesizelimit sets no limit here, and is
+	// reported by git-lfs-prune(1).
+	var cacheSizeLimit uint64
+	if v, ok := git.Get("lfs.prune.cachesizelimit"); ok {
+		cacheSizeLimit, _ = humanize.ParseBytes(v)
+	}
+
 	return FetchPruneConfig{
 		FetchRecentRefsDays:           git.Int("lfs.fetchrecentrefsdays", 7),
 		FetchRecentRefsIncludeRemotes: git.Bool("lfs.fetchrecentremoterefs", true),
 		FetchRecentCommitsDays:        git.Int("lfs.fetchrecentcommitsdays", 0),
 		FetchRecentAlways:             git.Bool("lfs.fetchrecentalways", false),
-		PruneOffsetDays:               git.Int("lfs.pruneoffsetdays", 3),
+		PruneOffsetDays:               pruneOffsetDays,
+		PruneIncomingOffsetDays:       git.Int("lfs.pruneincomingoffsetdays", pruneOffsetDays),
+		PruneOutgoingOffsetDays:       git.Int("lfs.pruneoutgoingoffsetdays", pruneOffsetDays),
 		PruneVerifyRemoteAlways:       git.Bool("lfs.pruneverifyremotealways", false),
 		PruneRemoteName:               pruneRemote,
 		PruneRecent:                   false,
 		PruneForce:                    false,
+		PruneKeepUnpushed:             true,
+		PruneVerifyUnpushed:           false,
+		PruneCacheSizeLimit:           cacheSizeLimit,
+		PruneRetentionRules:           pruneRetentionRules(git),
 	}
 }
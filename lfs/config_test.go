@@ -16,6 +16,8 @@ func TestFetchPruneConfigDefault(t *testing.T) {
 	assert.Equal(t, 3, fp.PruneOffsetDays)
 	assert.True(t, fp.FetchRecentRefsIncludeRemotes)
 	assert.Equal(t, 3, fp.PruneOffsetDays)
+	assert.Equal(t, 3, fp.PruneIncomingOffsetDays)
+	assert.Equal(t, 3, fp.PruneOutgoingOffsetDays)
 	assert.Equal(t, "origin", fp.PruneRemoteName)
 	assert.False(t, fp.PruneVerifyRemoteAlways)
 }
@@ -37,6 +39,50 @@ func TestFetchPruneConfigCustom(t *testing.T) {
 	assert.Equal(t, 9, fp.FetchRecentCommitsDays)
 	assert.False(t, fp.FetchRecentRefsIncludeRemotes)
 	assert.Equal(t, 30, fp.PruneOffsetDays)
+	assert.Equal(t, 30, fp.PruneIncomingOffsetDays)
+	assert.Equal(t, 30, fp.PruneOutgoingOffsetDays)
 	assert.Equal(t, "upstream", fp.PruneRemoteName)
 	assert.True(t, fp.PruneVerifyRemoteAlways)
 }
+
+func TestFetchPruneConfigIncomingOutgoingOffsets(t *testing.T) {
+	cfg := config.NewFrom(config.Values{
+		Git: map[string][]string{
+			"lfs.pruneoffsetdays":         []string{"5"},
+			"lfs.pruneincomingoffsetdays": []string{"1"},
+			"lfs.pruneoutgoingoffsetdays": []string{"30"},
+		},
+	})
+	fp := NewFetchPruneConfig(cfg.Git)
+
+	assert.Equal(t, 5, fp.PruneOffsetDays)
+	assert.Equal(t, 1, fp.PruneIncomingOffsetDays)
+	assert.Equal(t, 30, fp.PruneOutgoingOffsetDays)
+}
+
+func TestFetchPruneConfigRetentionRules(t *testing.T) {
+	cfg := config.NewFrom(config.Values{
+		Git: map[string][]string{
+			"lfs.pruneretain.*.uasset.versions":  []string{"5"},
+			"lfs.pruneretain.*.uasset.days":      []string{"2"},
+			"lfs.pruneretain.Binaries/.days":     []string{"30"},
+			"lfs.pruneretain.Content/.versions":  []string{"none"},
+			"lfs.pruneretain.Content/.unknown":   []string{"1"},
+			"lfs.pruneretain.Intermediate/.days": []string{"-1"},
+		},
+	})
+	fp := NewFetchPruneConfig(cfg.Git)
+
+	if assert.Len(t, fp.PruneRetentionRules, 2) {
+		assert.Equal(t, "*.uasset", fp.PruneRetentionRules[0].Pattern)
+		assert.Equal(t, 5, fp.PruneRetentionRules[0].Versions)
+		assert.Equal(t, 2, fp.PruneRetentionRules[0].Days)
+		assert.True(t, fp.PruneRetentionRules[0].Matches("Content/Maps/a.uasset"))
+		assert.False(t, fp.PruneRetentionRules[0].Matches("Content/Maps/a.umap"))
+
+		assert.Equal(t, "Binaries/", fp.PruneRetentionRules[1].Pattern)
+		assert.Equal(t, 0, fp.PruneRetentionRules[1].Versions)
+		assert.Equal(t, 30, fp.PruneRetentionRules[1].Days)
+		assert.True(t, fp.PruneRetentionRules[1].Matches("Binaries/Win64/a.dll"))
+	}
+}
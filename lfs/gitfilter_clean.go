@@ -8,12 +8,25 @@ import (
 	"os"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
 	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	// defaultCleanBufferSize is the size of the buffer through which the
+	// clean filter copies each file, unless "lfs.clean.buffersize" is set.
+	defaultCleanBufferSize = 32 * 1024
+	// maxCleanBufferSize is the largest value of "lfs.clean.buffersize".
+	maxCleanBufferSize = 64 * 1024 * 1024
 )
 
 type cleanedAsset struct {
 	Filename string
 	*Pointer
+
+	fs *fs.Filesystem
 }
 
 func (f *GitFilter) Clean(reader io.Reader, fileName string, fileSize int64, cb tools.CopyCallback) (*cleanedAsset, error) {
@@ -31,13 +44,18 @@ func (f *GitFilter) Clean(reader io.Reader, fileName string, fileSize int64, cb
 
 		var response pipeResponse
 		if response, err = pipeExtensions(f.cfg, request); err != nil {
+			if response.file != nil {
+				f.fs.RemoveTempFile(response.file.Name())
+			}
 			return nil, err
 		}
+		f.fs.RemoveOnCleanup(response.file.Name())
 
 		oid = response.results[len(response.results)-1].oidOut
 		tmp = response.file
 		var stat os.FileInfo
 		if stat, err = os.Stat(tmp.Name()); err != nil {
+			f.fs.RemoveTempFile(tmp.Name())
 			return nil, err
 		}
 		size = stat.Size()
@@ -56,16 +74,29 @@ func (f *GitFilter) Clean(reader io.Reader, fileName string, fileSize int64, cb
 	}
 
 	pointer := NewPointer(oid, size, exts)
-	return &cleanedAsset{tmp.Name(), pointer}, err
+	if pointer.Version, err = PointerVersion(f.cfg.Git); err != nil {
+		return nil, err
+	}
+	return &cleanedAsset{tmp.Name(), pointer, f.fs}, err
 }
 
+// copyToTemp copies the file from "reader" into a new temporary file, which is
+// removed if it fails, or the file is already a pointer, and otherwise when
+// the process exits unless it has been moved elsewhere by then.
 func (f *GitFilter) copyToTemp(reader io.Reader, fileSize int64, cb tools.CopyCallback) (oid string, size int64, tmp *os.File, err error) {
 	tmp, err = TempFile(f.cfg, "")
 	if err != nil {
+		err = fs.NewStorageError(f.cfg.TempDir(), err)
 		return
 	}
+	f.fs.RemoveOnCleanup(tmp.Name())
 
-	defer tmp.Close()
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			f.fs.RemoveTempFile(tmp.Name())
+		}
+	}()
 
 	oidHash := sha256.New()
 	writer := io.MultiWriter(oidHash, tmp)
@@ -92,9 +123,10 @@ func (f *GitFilter) copyToTemp(reader io.Reader, fileSize int64, cb tools.CopyCa
 		from = io.MultiReader(from, reader)
 	}
 
-	size, err = tools.CopyWithCallback(writer, from, fileSize, cb)
+	size, err = tools.CopyWithCallbackBuffer(writer, from, fileSize, cb, make([]byte, f.cleanBufferSize()))
 
 	if err != nil {
+		err = fs.NewStorageError(tmp.Name(), err)
 		return
 	}
 
@@ -102,6 +134,25 @@ func (f *GitFilter) copyToTemp(reader io.Reader, fileSize int64, cb tools.CopyCa
 	return
 }
 
+// cleanBufferSize returns the size of the buffer through which the clean
+// filter should copy each file, which bounds the memory it uses regardless of
+// the size of the file.
+func (f *GitFilter) cleanBufferSize() int {
+	v, ok := f.cfg.Git.Get("lfs.clean.buffersize")
+	if !ok {
+		return defaultCleanBufferSize
+	}
+
+	size, err := humanize.ParseBytes(v)
+	if err != nil || size == 0 || size > maxCleanBufferSize {
+		tracerx.Printf("lfs: ignoring invalid lfs.clean.buffersize %q", v)
+		return defaultCleanBufferSize
+	}
+	return int(size)
+}
+
+// Teardown removes the temporary file the asset was cleaned into, unless it has
+// already been moved into the object store.
 func (a *cleanedAsset) Teardown() error {
-	return os.Remove(a.Filename)
+	return a.fs.RemoveTempFile(a.Filename)
 }
@@ -0,0 +1,73 @@
+package lfs_test // avoid import cycle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/lfs"
+	test "github.com/git-lfs/git-lfs/t/cmd/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patternReader produces "size" bytes of a repeating pattern, without holding
+// more than a single byte of it in memory, and records the largest buffer it
+// is asked to read into.
+type patternReader struct {
+	size, offset int64
+	maxRead      int
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if len(p) > r.maxRead {
+		r.maxRead = len(p)
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if remaining := r.size - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	for i := range p {
+		p[i] = byte((r.offset + int64(i)) % 251)
+	}
+	r.offset += int64(len(p))
+	return len(p), nil
+}
+
+func TestCleanUsesBoundedMemory(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	test.RunGitCommand(t, true, "config", "lfs.clean.buffersize", "16KiB")
+
+	const size = 64 * 1024 * 1024
+
+	expected := sha256.New()
+	_, err := io.Copy(expected, &patternReader{size: size})
+	require.Nil(t, err)
+
+	// The repository's configuration was read before lfs.clean.buffersize
+	// was set, so read it afresh.
+	filter := lfs.NewGitFilter(config.NewIn(repo.Path, repo.GitDir))
+
+	reader := &patternReader{size: size}
+	cleaned, err := filter.Clean(reader, "", size, nil)
+	require.Nil(t, err)
+	defer cleaned.Teardown()
+
+	assert.Equal(t, hex.EncodeToString(expected.Sum(nil)), cleaned.Oid)
+	assert.EqualValues(t, size, cleaned.Size)
+
+	// The file is read through the buffer given by lfs.clean.buffersize,
+	// rather than into one as large as the file.
+	assert.Equal(t, 16*1024, reader.maxRead)
+}
@@ -1,6 +1,7 @@
 package lfs
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -15,9 +16,38 @@ import (
 )
 
 func (f *GitFilter) SmudgeToFile(filename string, ptr *Pointer, download bool, manifest *tq.Manifest, cb tools.CopyCallback) error {
+	return f.smudgeToFile(filename, ptr, func(file *os.File) error {
+		if _, err := f.Smudge(file, ptr, filename, download, manifest, cb); err != nil {
+			if errors.IsDownloadDeclinedError(err) {
+				// write placeholder data instead
+				file.Seek(0, io.SeekStart)
+				ptr.Encode(file)
+				return err
+			} else {
+				return fmt.Errorf("could not write working directory file: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// smudgeToFile writes the working tree file "filename" for "ptr" with
+// "write", unless the local object can be cloned or linked to it instead.
+func (f *GitFilter) smudgeToFile(filename string, ptr *Pointer, write func(*os.File) error) error {
 	tools.MkdirAll(filepath.Dir(filename), f.cfg)
 
+	// A file hard linked to an object, as lfs.checkout.hardlink makes
+	// them, must be replaced rather than written over, or the object
+	// would be changed too.
+	if tools.IsHardLinked(filename) {
+		if err := unlinkFile(filename); err != nil {
+			return errors.Wrap(err, "Could not replace hard linked file")
+		}
+	}
+
+	readOnly := false
 	if stat, _ := os.Stat(filename); stat != nil && stat.Mode()&0200 == 0 {
+		readOnly = true
 		if err := os.Chmod(filename, stat.Mode()|0200); err != nil {
 			return errors.Wrap(err,
 				"Could not restore write permission")
@@ -33,24 +63,224 @@ func (f *GitFilter) SmudgeToFile(filename string, ptr *Pointer, download bool, m
 		return fmt.Errorf("could not produce absolute path for %q", filename)
 	}
 
+	if f.cloneLocalObject(abs, ptr) || (!readOnly && f.linkLocalObject(abs, ptr)) {
+		return nil
+	}
+
 	file, err := os.Create(abs)
 	if err != nil {
 		return fmt.Errorf("could not create working directory file: %v", err)
 	}
 	defer file.Close()
-	if _, err := f.Smudge(file, ptr, filename, download, manifest, cb); err != nil {
-		if errors.IsDownloadDeclinedError(err) {
-			// write placeholder data instead
-			file.Seek(0, io.SeekStart)
-			ptr.Encode(file)
-			return err
-		} else {
-			return fmt.Errorf("could not write working directory file: %v", err)
+	return write(file)
+}
+
+// cloneLocalObject clones the local object for "ptr" to the working tree file
+// at "abs", so that they share their data on disk rather than it being copied,
+// if the object is present and the filesystem holding the object store
+// supports it. It returns whether it did so, and otherwise leaves the file to
+// be written by copying the object as usual.
+func (f *GitFilter) cloneLocalObject(abs string, ptr *Pointer) bool {
+	if len(ptr.Extensions) > 0 || f.cfg.Git.Bool("lfs.verifycachedobjects", false) {
+		return false
+	}
+
+	LinkOrCopyFromReference(f.cfg, ptr.Oid, ptr.Size)
+	mediafile := f.fs.ObjectReadPathname(ptr.Oid, ptr.Size)
+	if !tools.FileExistsOfSize(mediafile, ptr.Size) || !tools.CanCloneFilesIn(f.cfg.TempDir()) {
+		return false
+	}
+
+	// Create the file as it would be otherwise, to find the mode it
+	// should have, since a clone may take that of the object.
+	file, err := os.Create(abs)
+	if err != nil {
+		return false
+	}
+	stat, err := file.Stat()
+	file.Close()
+	if err != nil {
+		return false
+	}
+
+	if ok, err := tools.CloneFileByPath(abs, mediafile); !ok {
+		tracerx.Printf("smudge: could not clone %s to %s, copying instead: %v", mediafile, abs, err)
+		return false
+	}
+	if err := os.Chmod(abs, stat.Mode().Perm()); err != nil {
+		tracerx.Printf("smudge: could not set the mode of %s: %v", abs, err)
+	}
+
+	tracerx.Printf("smudge: cloned %s to %s", mediafile, abs)
+	return true
+}
+
+// linkLocalObject hard links the working tree file at "abs" to the local object
+// for "ptr", if lfs.checkout.hardlink is set, the object is present on the
+// same filesystem, and the file would be executable only if the object is. The
+// object is made read-only first, and the file takes its mode, so that the
+// object can't be changed in place through the file. It returns whether it did
+// so, and otherwise leaves the file to be written by copying the object as
+// usual.
+func (f *GitFilter) linkLocalObject(abs string, ptr *Pointer) bool {
+	if len(ptr.Extensions) > 0 || !f.cfg.Git.Bool("lfs.checkout.hardlink", false) || f.cfg.Git.Bool("lfs.verifycachedobjects", false) {
+		return false
+	}
+
+	LinkOrCopyFromReference(f.cfg, ptr.Oid, ptr.Size)
+	mediafile := f.fs.ObjectReadPathname(ptr.Oid, ptr.Size)
+	object, err := os.Stat(mediafile)
+	if err != nil || object.Size() != ptr.Size {
+		return false
+	}
+
+	// Create the file as it would be otherwise, to find the mode it
+	// should have, since a link has that of the object.
+	file, err := os.Create(abs)
+	if err != nil {
+		return false
+	}
+	stat, err := file.Stat()
+	file.Close()
+	if err != nil {
+		return false
+	}
+	readOnly := stat.Mode().Perm() &^ 0222
+	if readOnly != object.Mode().Perm()&^0222 {
+		tracerx.Printf("smudge: not linking %s to %s, whose mode is %s rather than %s", mediafile, abs, object.Mode().Perm(), stat.Mode().Perm())
+		return false
+	}
+	if object.Mode().Perm() != readOnly {
+		if err := os.Chmod(mediafile, readOnly); err != nil {
+			tracerx.Printf("smudge: not linking %s to %s, which can't be made read-only: %v", mediafile, abs, err)
+			return false
 		}
 	}
+
+	if err := os.Remove(abs); err != nil {
+		return false
+	}
+	if err := os.Link(mediafile, abs); err != nil {
+		tracerx.Printf("smudge: could not link %s to %s, copying instead: %v", mediafile, abs, err)
+		return false
+	}
+
+	tracerx.Printf("smudge: linked %s to %s", mediafile, abs)
+	return true
+}
+
+// StreamPath returns the path to which the object of "ptr" is downloaded when
+// the working tree file "filename" is checked out with lfs.smudge.stream set:
+// the file itself, which the transfer adapter replaces with the object once it
+// has been downloaded and its hash verified, rather than the object being
+// downloaded to the local object store and then copied. It returns false if
+// the object can't be streamed, because lfs.smudge.stream isn't set, the
+// pointer has extensions, or the file is neither missing nor still the pointer.
+func (f *GitFilter) StreamPath(filename string, ptr *Pointer) (string, bool) {
+	if len(ptr.Extensions) > 0 || !f.cfg.SmudgeStream() {
+		return "", false
+	}
+
+	filepointer, err := DecodePointerFromFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false
+	}
+	if filepointer != nil && filepointer.Oid != ptr.Oid {
+		return "", false
+	}
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	if err := tools.MkdirAll(filepath.Dir(abs), f.cfg); err != nil {
+		return "", false
+	}
+	return abs, true
+}
+
+// KeepStreamedObject adds the object of "ptr", which was streamed to the
+// working tree file "filename", to the local object store, without copying
+// it: by cloning the file if the filesystem supports it, or by hard linking it
+// if lfs.checkout.hardlink is set, in which case the file is made read-only
+// first, as linkLocalObject does, so that the object can't be changed in place
+// through the file. It returns false if the object is only in
+// the working tree, until the clean filter adds it to the object store as the
+// git index is updated.
+func (f *GitFilter) KeepStreamedObject(filename string, ptr *Pointer) bool {
+	mediafile, err := f.ObjectPath(ptr.Oid)
+	if err != nil {
+		return false
+	}
+
+	if tools.CanCloneFilesIn(f.cfg.TempDir()) {
+		ok, err := tools.CloneFileByPath(mediafile, filename)
+		if ok {
+			tracerx.Printf("smudge: cloned streamed %s to %s", filename, mediafile)
+			return true
+		}
+		tracerx.Printf("smudge: could not clone streamed %s to %s: %v", filename, mediafile, err)
+	}
+
+	if f.cfg.Git.Bool("lfs.checkout.hardlink", false) {
+		err := linkStreamedObject(filename, mediafile)
+		if err == nil {
+			tracerx.Printf("smudge: linked streamed %s to %s", filename, mediafile)
+			return true
+		}
+		tracerx.Printf("smudge: could not link streamed %s to %s: %v", filename, mediafile, err)
+	}
+
+	tracerx.Printf("smudge: streamed object %s is only in the working tree", ptr.Oid)
+	return false
+}
+
+// linkStreamedObject makes the working tree file "filename" read-only and hard
+// links the object file "mediafile" to it, restoring the mode of the file if
+// it can't be linked.
+func linkStreamedObject(filename, mediafile string) error {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(filename, stat.Mode().Perm()&^0222); err != nil {
+		return err
+	}
+	if err := os.Link(filename, mediafile); err != nil {
+		os.Chmod(filename, stat.Mode().Perm())
+		return err
+	}
 	return nil
 }
 
+// SmudgeStreamedToFile writes the object of "ptr", which was streamed to the
+// working tree file "src" but isn't in the local object store, to the working
+// tree file "filename", as SmudgeToFile would from the object store.
+func (f *GitFilter) SmudgeStreamedToFile(filename, src string, ptr *Pointer) error {
+	return f.smudgeToFile(filename, ptr, func(file *os.File) error {
+		_, err := f.readLocalFile(file, ptr, src, filename, nil)
+		return err
+	})
+}
+
+// unlinkFile replaces the file at "path" with an empty one of the same mode.
+func unlinkFile(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Chmod(path, stat.Mode().Perm())
+}
+
 func (f *GitFilter) Smudge(writer io.Writer, ptr *Pointer, workingfile string, download bool, manifest *tq.Manifest, cb tools.CopyCallback) (int64, error) {
 	mediafile, err := f.ObjectPath(ptr.Oid)
 	if err != nil {
@@ -59,6 +289,14 @@ func (f *GitFilter) Smudge(writer io.Writer, ptr *Pointer, workingfile string, d
 
 	LinkOrCopyFromReference(f.cfg, ptr.Oid, ptr.Size)
 
+	var inAlternate bool
+	if !tools.FileExists(mediafile) {
+		if alternate, ok := f.cfg.Filesystem().ObjectAlternatePathname(ptr.Oid, ptr.Size); ok {
+			mediafile = alternate
+			inAlternate = true
+		}
+	}
+
 	stat, statErr := os.Stat(mediafile)
 	if statErr == nil && stat != nil {
 		fileSize := stat.Size()
@@ -78,7 +316,12 @@ func (f *GitFilter) Smudge(writer io.Writer, ptr *Pointer, workingfile string, d
 			return 0, errors.NewDownloadDeclinedError(statErr, "smudge")
 		}
 	} else {
-		n, err = f.readLocalFile(writer, ptr, mediafile, workingfile, cb)
+		if f.cfg.Git.Bool("lfs.verifycachedobjects", false) {
+			err = f.verifyCachedObject(ptr, mediafile, inAlternate)
+		}
+		if err == nil {
+			n, err = f.readLocalFile(writer, ptr, mediafile, workingfile, cb)
+		}
 	}
 
 	if err != nil {
@@ -88,6 +331,48 @@ func (f *GitFilter) Smudge(writer io.Writer, ptr *Pointer, workingfile string, d
 	return n, nil
 }
 
+// verifyCachedObject re-hashes the object at "mediafile", which is already
+// present in the local object store, and checks that it matches the OID of
+// "ptr". If it doesn't, the object is moved into the "bad" directory, where
+// "git lfs fsck" also moves corrupt objects, so that it is downloaded again
+// the next time it is needed, unless it is "inAlternate", an alternate object
+// directory, which is read-only and so is left as it is.
+func (f *GitFilter) verifyCachedObject(ptr *Pointer, mediafile string, inAlternate bool) error {
+	reader, err := tools.RobustOpen(mediafile)
+	if err != nil {
+		return errors.Wrapf(err, "error opening media file")
+	}
+
+	hasher := tools.NewLfsContentHash()
+	_, err = io.Copy(hasher, reader)
+	reader.Close()
+	if err != nil {
+		return errors.Wrapf(err, "error verifying media file")
+	}
+
+	oid := hex.EncodeToString(hasher.Sum(nil))
+	if oid == ptr.Oid {
+		return nil
+	}
+
+	tracerx.Printf("Cached object %s has actual oid %s", ptr.Oid, oid)
+
+	if inAlternate {
+		return errors.Errorf("cached object %s is corrupt, and has been left in its alternate object directory at %s", ptr.Oid, mediafile)
+	}
+
+	badDir := filepath.Join(f.cfg.LFSStorageDir(), "bad")
+	if err := tools.MkdirAll(badDir, f.cfg); err != nil {
+		return errors.Wrapf(err, "cached object %s is corrupt", ptr.Oid)
+	}
+
+	badFile := filepath.Join(badDir, ptr.Oid)
+	if err := MoveFile(f.cfg, mediafile, badFile); err != nil {
+		return errors.Wrapf(err, "cached object %s is corrupt, and could not be moved to %s", ptr.Oid, badFile)
+	}
+	return errors.Errorf("cached object %s is corrupt, and has been moved to %s", ptr.Oid, badFile)
+}
+
 func (f *GitFilter) downloadFile(writer io.Writer, ptr *Pointer, workingfile, mediafile string, manifest *tq.Manifest, cb tools.CopyCallback) (int64, error) {
 	fmt.Fprintf(os.Stderr, "Downloading %s (%s)\n", workingfile, humanize.FormatBytes(uint64(ptr.Size)))
 
@@ -114,6 +114,22 @@ func (s *GitScanner) ScanMultiRangeToRemote(left string, rights []string, cb Git
 	return scanMultiLeftRightToChan(s, callback, left, rights, s.cfg.GitEnv(), s.cfg.OSEnv(), s.opts(ScanRangeToRemoteMode))
 }
 
+// ScanChangedPathsToRemote calls "cb" with each path changed by the commits
+// starting at the left ref but not reachable from any of the right refs, that
+// the given remote does not have, along with the newest of those commits which
+// changed it. Each path is given once. See RemoteForPush().
+func (s *GitScanner) ScanChangedPathsToRemote(left string, rights []string, cb func(path, sha string)) error {
+	s.mu.Lock()
+	if len(s.remote) == 0 {
+		s.mu.Unlock()
+		return fmt.Errorf("unable to scan starting at %q: no remote set", left)
+	}
+	remote, skippedRefs := s.remote, s.skippedRefs
+	s.mu.Unlock()
+
+	return logChangedPaths(cb, left, rights, remote, skippedRefs)
+}
+
 // ScanRefs through all commits reachable by refs contained in "include" and
 // not reachable by any refs included in "excluded"
 func (s *GitScanner) ScanRefs(include, exclude []string, cb GitScannerFoundPointer) error {
@@ -182,6 +198,32 @@ func (s *GitScanner) ScanTree(ref string) error {
 	return runScanTree(callback, ref, s.Filter, s.cfg.GitEnv(), s.cfg.OSEnv())
 }
 
+// ScanRefByTree returns the pointers of all the Git LFS files in the tree at
+// "ref" of the repository "cfg" is for, which may be bare. Only the objects
+// in the repository are read, so no working tree is needed and no filters are
+// run. Like ScanTree, files with the same content are each returned.
+func ScanRefByTree(cfg *config.Configuration, ref string) ([]*WrappedPointer, error) {
+	var pointers []*WrappedPointer
+	var scanErr error
+	err := runScanTree(func(p *WrappedPointer, err error) {
+		if err != nil {
+			if scanErr == nil {
+				scanErr = err
+			}
+			return
+		}
+		pointers = append(pointers, p)
+	}, ref, nil, cfg.GitEnv(), cfg.OSEnv())
+
+	if err != nil {
+		return nil, err
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return pointers, nil
+}
+
 // ScanUnpushed scans history for all LFS pointers which have been added but not
 // pushed to the named remote. remote can be left blank to mean 'any remote'.
 func (s *GitScanner) ScanUnpushed(remote string, cb GitScannerFoundPointer) error {
@@ -214,6 +256,27 @@ func (s *GitScanner) ScanPreviousVersions(ref string, since time.Time, cb GitSca
 	return logPreviousSHAs(callback, ref, since)
 }
 
+// ScanRefVersions scans the history of ref (commit) for every version of the
+// LFS pointers its commits added, newest first, including those still in use
+// at ref.
+func (s *GitScanner) ScanRefVersions(ref string, cb GitScannerFoundPointer) error {
+	callback, err := firstGitScannerCallback(cb, s.FoundPointer)
+	if err != nil {
+		return err
+	}
+	return logRefVersions(callback, ref)
+}
+
+// ScanAllVersions scans the history of all refs for every version of the LFS
+// pointers their commits added, newest first.
+func (s *GitScanner) ScanAllVersions(cb GitScannerFoundPointer) error {
+	callback, err := firstGitScannerCallback(cb, s.FoundPointer)
+	if err != nil {
+		return err
+	}
+	return logRefVersions(callback, "--all")
+}
+
 // ScanIndex scans the git index for modified LFS objects.
 func (s *GitScanner) ScanIndex(ref string, cb GitScannerFoundPointer) error {
 	callback, err := firstGitScannerCallback(cb, s.FoundPointer)
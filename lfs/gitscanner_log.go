@@ -13,6 +13,7 @@ import (
 	"github.com/git-lfs/git-lfs/filepathfilter"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/subprocess"
+	"github.com/git-lfs/git-lfs/tools"
 	"github.com/rubyist/tracerx"
 )
 
@@ -171,6 +172,76 @@ func logPreviousSHAs(cb GitScannerFoundPointer, ref string, since time.Time) err
 	return nil
 }
 
+// logRefVersions scans the history of ref for every version of the LFS
+// pointers added by its commits, newest first, including those still at ref
+func logRefVersions(cb GitScannerFoundPointer, ref string) error {
+	// Add standard search args to find lfs references
+	logArgs := append([]string{}, logLfsSearchArgs...)
+	// ending at ref
+	logArgs = append(logArgs, ref)
+
+	cmd, err := git.Log(logArgs...)
+	if err != nil {
+		return err
+	}
+
+	parseScannerLogOutput(cb, LogDiffAdditions, cmd)
+	return nil
+}
+
+// logChangedPaths calls "cb" with each path changed by the commits reachable
+// from "left" but from none of "rights", nor from the refs of "remote", or the
+// given "skippedRefs" instead if there are any, as with ScanRangeToRemoteMode.
+// Renames are given as the old and the new path.
+func logChangedPaths(cb func(path, sha string), left string, rights []string, remote string, skippedRefs []string) error {
+	logArgs := []string{"-z", "--name-only", "--no-renames", "--format=%x01%H", "--ignore-missing"}
+	revs := []string{left}
+	for _, right := range rights {
+		if len(right) > 0 && !git.IsZeroObjectID(right) {
+			revs = append(revs, "^"+right)
+		}
+	}
+	if len(skippedRefs) == 0 {
+		logArgs = append(logArgs, "--not", "--remotes="+remote)
+	} else {
+		revs = append(revs, skippedRefs...)
+	}
+	logArgs = append(logArgs, "--stdin", "--")
+
+	cmd, err := git.Log(logArgs...)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		cmd.Stdin.Write([]byte(strings.Join(revs, "\n") + "\n"))
+		cmd.Stdin.Close()
+	}()
+
+	seen := make(map[string]bool)
+	var sha string
+	scanner := bufio.NewScanner(cmd.Stdout)
+	scanner.Split(tools.SplitOnNul)
+	for scanner.Scan() {
+		entry := strings.TrimPrefix(scanner.Text(), "\n")
+		if strings.HasPrefix(entry, "\x01") {
+			sha = entry[1:]
+			continue
+		}
+		if len(entry) == 0 || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		cb(entry, sha)
+	}
+
+	stderr, _ := ioutil.ReadAll(cmd.Stderr)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("error in git log: %v %v", err, string(stderr))
+	}
+	return scanner.Err()
+}
+
 func parseLogOutputToPointers(log io.Reader, dir LogDiffDirection,
 	includePaths, excludePaths []string, results chan *WrappedPointer) {
 	scanner := newLogScanner(dir, log)
@@ -55,6 +55,57 @@ func NewStandardHook(theType, hookDir string, upgradeables []string, cfg *config
 	}
 }
 
+// HookStatus describes what Install would do to a hook.
+type HookStatus struct {
+	Type   string `json:"type"`
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	// Managed is whether the existing hook was written by Git LFS, either
+	// this version or an earlier one, or is empty, so that Install may
+	// replace it.
+	Managed bool `json:"managed"`
+	// Action is "create" if the hook doesn't exist, "none" if it is
+	// already the current version, "upgrade" if it was written by an
+	// earlier version, "overwrite" if it has been customized and Install
+	// would overwrite it because of "force", and "conflict" if it has been
+	// customized and Install would fail instead.
+	Action string `json:"action"`
+	// Contents is what Install would write to the hook, if anything.
+	Contents string `json:"contents,omitempty"`
+}
+
+// DryRun returns what Install would do to this hook, with "force" as given to
+// it, without changing anything.
+func (h *Hook) DryRun(force bool) (*HookStatus, error) {
+	status := &HookStatus{Type: h.Type, Path: h.Path(), Exists: h.Exists()}
+
+	if !status.Exists {
+		status.Action = "create"
+	} else {
+		contents, err := h.existingContents()
+		if err != nil {
+			return nil, err
+		}
+
+		status.Managed = h.isUpgradeable(contents)
+		switch {
+		case contents == h.Contents:
+			status.Action = "none"
+		case status.Managed:
+			status.Action = "upgrade"
+		case force:
+			status.Action = "overwrite"
+		default:
+			status.Action = "conflict"
+		}
+	}
+
+	if status.Action != "none" && status.Action != "conflict" {
+		status.Contents = h.Contents + "\n"
+	}
+	return status, nil
+}
+
 func (h *Hook) Exists() bool {
 	_, err := os.Stat(h.Path())
 
@@ -135,27 +186,47 @@ func (h *Hook) Uninstall() error {
 // its contents match the current contents, or any past "upgrade-able" contents
 // of this hook.
 func (h *Hook) matchesCurrent() (bool, error) {
-	file, err := os.Open(h.Path())
+	contents, err := h.existingContents()
 	if err != nil {
 		return false, err
 	}
 
+	if h.isUpgradeable(contents) {
+		return true, nil
+	}
+
+	return false, fmt.Errorf("Hook already exists: %s\n\n%s\n", string(h.Type), tools.Indent(contents))
+}
+
+// existingContents returns the start of the existing hook, with surrounding
+// whitespace and any common indentation removed.
+func (h *Hook) existingContents() (string, error) {
+	file, err := os.Open(h.Path())
+	if err != nil {
+		return "", err
+	}
+
 	by, err := ioutil.ReadAll(io.LimitReader(file, 1024))
 	file.Close()
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
-	contents := strings.TrimSpace(tools.Undent(string(by)))
+	return strings.TrimSpace(tools.Undent(string(by))), nil
+}
+
+// isUpgradeable returns whether the existing hook "contents", as returned by
+// existingContents, may be replaced: that is, if it is empty, or matches the
+// current contents or any past "upgrade-able" contents of this hook.
+func (h *Hook) isUpgradeable(contents string) bool {
 	if contents == h.Contents || len(contents) == 0 {
-		return true, nil
+		return true
 	}
 
 	for _, u := range h.upgradeables {
 		if u == contents {
-			return true, nil
+			return true
 		}
 	}
-
-	return false, fmt.Errorf("Hook already exists: %s\n\n%s\n", string(h.Type), tools.Indent(contents))
+	return false
 }
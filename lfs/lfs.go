@@ -107,8 +107,13 @@ const (
 	gitPtrPrefix = "gitdir: "
 )
 
+// LinkOrCopyFromReference links or copies the object "oid" of "size" bytes into
+// the primary object directory from the object directory of a clone reference
+// repository, if it is missing from the former and present in the latter.
+// If lfs.storage.linkalternates is set, objects found in one of the
+// lfs.storage.alternates are hard linked into it too.
 func LinkOrCopyFromReference(cfg *config.Configuration, oid string, size int64) error {
-	if cfg.LFSObjectExists(oid, size) {
+	if tools.FileExistsOfSize(cfg.Filesystem().ObjectPathname(oid), size) {
 		return nil
 	}
 	altMediafiles := cfg.Filesystem().ObjectReferencePaths(oid)
@@ -121,7 +126,19 @@ func LinkOrCopyFromReference(cfg *config.Configuration, oid string, size int64)
 		if altMediafile != "" && tools.FileExistsOfSize(altMediafile, size) {
 			err = LinkOrCopy(cfg, altMediafile, mediafile)
 			if err == nil {
-				break
+				return nil
+			}
+		}
+	}
+
+	if cfg.Git.Bool("lfs.storage.linkalternates", false) {
+		if alternate, ok := cfg.Filesystem().ObjectAlternatePathname(oid, size); ok {
+			// Objects which can't be linked, as when the alternate
+			// is on another filesystem, are still read from there.
+			if lerr := os.Link(alternate, mediafile); lerr != nil {
+				tracerx.Printf("could not link %s from alternate %s: %s", oid, alternate, lerr)
+			} else {
+				tracerx.Printf("linked %s from alternate %s", oid, alternate)
 			}
 		}
 	}
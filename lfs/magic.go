@@ -0,0 +1,70 @@
+package lfs
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MagicHeaderSize is the number of bytes at the start of a file which are
+// examined to find the type of its contents.
+const MagicHeaderSize = 512
+
+// ContentTypes is a set of MIME types, as given by lfs.magic.types, which the
+// contents of files tracked only by "git lfs track --magic" must have to be
+// stored in Git LFS.
+type ContentTypes struct {
+	types    map[string]bool
+	prefixes []string
+}
+
+// NewContentTypes returns the ContentTypes given by "values", each of which
+// lists MIME types separated by commas or spaces. A type of the form
+// "image/*" matches all those of the "image" media type.
+func NewContentTypes(values []string) *ContentTypes {
+	c := &ContentTypes{types: make(map[string]bool)}
+	for _, value := range values {
+		for _, t := range strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			t = strings.ToLower(t)
+			if strings.HasSuffix(t, "/*") {
+				c.prefixes = append(c.prefixes, strings.TrimSuffix(t, "*"))
+			} else {
+				c.types[t] = true
+			}
+		}
+	}
+	return c
+}
+
+// Empty returns whether no types were given.
+func (c *ContentTypes) Empty() bool {
+	return c == nil || (len(c.types) == 0 && len(c.prefixes) == 0)
+}
+
+// Match returns whether "header", the first MagicHeaderSize bytes of a file,
+// or all of it if it is shorter, is the start of contents of one of the
+// types. The type is found as by http.DetectContentType, which recognises
+// the magic numbers of common image, audio, video and archive formats, and
+// otherwise tells text, "text/plain", from other binary contents,
+// "application/octet-stream".
+func (c *ContentTypes) Match(header []byte) bool {
+	if c.Empty() {
+		return false
+	}
+
+	t, _, err := mime.ParseMediaType(http.DetectContentType(header))
+	if err != nil {
+		return false
+	}
+	if c.types[t] {
+		return true
+	}
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
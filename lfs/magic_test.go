@@ -0,0 +1,34 @@
+package lfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentTypesMatch(t *testing.T) {
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0dIHDR")
+	binary := []byte("\x00\x01\x02\x03binary")
+	text := []byte("just some text\n")
+
+	c := NewContentTypes([]string{"image/png, application/octet-stream"})
+	assert.True(t, c.Match(png))
+	assert.True(t, c.Match(binary))
+	assert.False(t, c.Match(text))
+	assert.False(t, c.Match(nil))
+
+	c = NewContentTypes([]string{"Image/*", "application/pdf"})
+	assert.True(t, c.Match(png))
+	assert.True(t, c.Match([]byte("%PDF-1.4\n")))
+	assert.False(t, c.Match(binary))
+}
+
+func TestContentTypesEmpty(t *testing.T) {
+	var c *ContentTypes
+	assert.True(t, c.Empty())
+	assert.False(t, c.Match([]byte("\x00\x01")))
+
+	c = NewContentTypes([]string{"", " , "})
+	assert.True(t, c.Empty())
+	assert.False(t, c.Match([]byte("\x00\x01")))
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/gitobj/v2"
 )
@@ -24,11 +25,16 @@ var (
 	latest      = "https://git-lfs.github.com/spec/v1"
 	oidType     = "sha256"
 	oidRE       = regexp.MustCompile(`\A[0-9a-f]{64}\z`)
+	sizeRE      = regexp.MustCompile(`\A(0|[1-9][0-9]*)\z`)
 	matcherRE   = regexp.MustCompile("git-media|hawser|git-lfs")
 	extRE       = regexp.MustCompile(`\Aext-\d{1}-\w+`)
 	pointerKeys = []string{"version", "oid", "size"}
 )
 
+// maxPointerLines is the most lines a pointer can have: one for each key, and
+// one for each of the extensions, whose priorities are single digits.
+var maxPointerLines = len(pointerKeys) + 10
+
 type Pointer struct {
 	Version    string
 	Oid        string
@@ -55,6 +61,23 @@ func NewPointer(oid string, size int64, exts []*PointerExtension) *Pointer {
 	return &Pointer{latest, oid, size, oidType, exts}
 }
 
+// PointerVersion returns the version line new pointers are written with, which
+// is the latest one unless lfs.pointer.version gives another of those known,
+// for older tools which accept only that one. Any other version is an error.
+func PointerVersion(git config.Environment) (string, error) {
+	version, ok := git.Get("lfs.pointer.version")
+	if !ok || len(version) == 0 {
+		return latest, nil
+	}
+
+	for _, v := range v1Aliases {
+		if v == version {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("unknown lfs.pointer.version %q, expected one of: %s", version, strings.Join(v1Aliases, ", "))
+}
+
 func NewPointerExtension(name string, priority int, oid string) *PointerExtension {
 	return &PointerExtension{name, priority, oid, oidType}
 }
@@ -68,8 +91,13 @@ func (p *Pointer) Encoded() string {
 		return ""
 	}
 
+	version := p.Version
+	if len(version) == 0 {
+		version = latest
+	}
+
 	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("version %s\n", latest))
+	buffer.WriteString(fmt.Sprintf("version %s\n", version))
 	for _, ext := range p.Extensions {
 		buffer.WriteString(fmt.Sprintf("ext-%d-%s %s:%s\n", ext.Priority, ext.Name, ext.OidType, ext.Oid))
 	}
@@ -117,18 +145,22 @@ func DecodePointer(reader io.Reader) (*Pointer, error) {
 //
 // If the pointer could not be decoded, an io.Reader containing the entire
 // blob's data will be returned, along with a parse error.
+//
+// No more than blobSizeCutoff bytes are read from "reader" before deciding
+// whether it holds a pointer, and any data that long is not one.
 func DecodeFrom(reader io.Reader) (*Pointer, io.Reader, error) {
 	buf := make([]byte, blobSizeCutoff)
-	n, err := reader.Read(buf)
+	n, err := io.ReadFull(reader, buf)
 	buf = buf[:n]
 
 	var contents io.Reader = bytes.NewReader(buf)
-	if err != io.EOF {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+	case nil:
 		contents = io.MultiReader(contents, reader)
-	}
-
-	if err != nil && err != io.EOF {
-		return nil, contents, err
+		return nil, contents, errors.NewNotAPointerError(errors.New("data size exceeds lfs pointer size cutoff"))
+	default:
+		return nil, io.MultiReader(contents, reader), err
 	}
 
 	p, err := decodeKV(bytes.TrimSpace(buf))
@@ -173,9 +205,9 @@ func decodeKV(data []byte) (*Pointer, error) {
 	}
 
 	value, ok = kvps["size"]
-	size, err := strconv.ParseInt(value, 10, 64)
-	if err != nil || size < 0 {
-		return nil, fmt.Errorf("invalid size: %q", value)
+	size, err := parseSize(value)
+	if err != nil {
+		return nil, err
 	}
 
 	var extensions []*PointerExtension
@@ -211,6 +243,19 @@ func parseOid(value string) (string, error) {
 	return oid, nil
 }
 
+// parseSize parses the value of the size key, which must be a non-negative
+// decimal number, without a sign or leading zeros, which fits in an int64.
+func parseSize(value string) (int64, error) {
+	if !sizeRE.MatchString(value) {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	return size, nil
+}
+
 func parsePointerExtension(key string, value string) (*PointerExtension, error) {
 	keyParts := strings.SplitN(key, "-", 3)
 	if len(keyParts) != 3 || keyParts[0] != "ext" {
@@ -254,7 +299,12 @@ func decodeKVData(data []byte) (kvps map[string]string, exts map[string]string,
 	scanner := bufio.NewScanner(bytes.NewBuffer(data))
 	line := 0
 	numKeys := len(pointerKeys)
-	for scanner.Scan() {
+	for numLines := 1; scanner.Scan(); numLines++ {
+		if numLines > maxPointerLines {
+			err = errors.NewNotAPointerError(fmt.Errorf("more than %d lines", maxPointerLines))
+			return
+		}
+
 		text := scanner.Text()
 		if len(text) == 0 {
 			continue
@@ -3,11 +3,13 @@ package lfs
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/errors"
 	"github.com/stretchr/testify/assert"
 )
@@ -168,6 +170,48 @@ size 12345`
 	assertEqualWithExample(t, ex, int64(12345), p.Size)
 }
 
+func TestEncodeDecodeWithVersion(t *testing.T) {
+	for _, version := range v1Aliases {
+		pointer := NewPointer("4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", 12345, nil)
+		pointer.Version = version
+
+		encoded := pointer.Encoded()
+		assert.True(t, strings.HasPrefix(encoded, "version "+version+"\n"), encoded)
+
+		p, err := DecodePointer(strings.NewReader(encoded))
+		if assert.Nil(t, err, version) {
+			assert.Equal(t, pointer.Oid, p.Oid)
+			assert.Equal(t, pointer.Size, p.Size)
+		}
+
+		p.Version = version
+		assert.Equal(t, encoded, p.Encoded())
+	}
+}
+
+func TestPointerVersion(t *testing.T) {
+	version, err := PointerVersion(config.NewFrom(config.Values{}).Git)
+	assert.Nil(t, err)
+	assert.Equal(t, latest, version)
+
+	version, err = PointerVersion(config.NewFrom(config.Values{
+		Git: map[string][]string{
+			"lfs.pointer.version": []string{"https://hawser.github.com/spec/v1"},
+		},
+	}).Git)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://hawser.github.com/spec/v1", version)
+
+	_, err = PointerVersion(config.NewFrom(config.Values{
+		Git: map[string][]string{
+			"lfs.pointer.version": []string{"https://example.com/spec/v2"},
+		},
+	}).Git)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unknown lfs.pointer.version")
+	}
+}
+
 func TestDecodeFromEmptyReader(t *testing.T) {
 	p, buf, err := DecodeFrom(strings.NewReader(""))
 	by, rerr := ioutil.ReadAll(buf)
@@ -178,6 +222,88 @@ func TestDecodeFromEmptyReader(t *testing.T) {
 	assert.Empty(t, by)
 }
 
+// countingReader returns endless copies of "data", counting the bytes read.
+type countingReader struct {
+	data []byte
+	n    int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		n += copy(p[n:], r.data[(r.n+n)%len(r.data):])
+	}
+	r.n += n
+	return n, nil
+}
+
+func TestDecodeFromReadsBoundedData(t *testing.T) {
+	r := &countingReader{data: []byte("version https://git-lfs.github.com/spec/v1\n")}
+	p, buf, err := DecodeFrom(r)
+
+	assert.Nil(t, p)
+	assert.True(t, errors.IsNotAPointerError(err))
+	assert.NotNil(t, buf)
+	assert.True(t, r.n <= blobSizeCutoff, "read %d bytes", r.n)
+}
+
+func TestDecodeFromOversizedPointer(t *testing.T) {
+	ex := `version https://git-lfs.github.com/spec/v1
+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size 12345
+` + strings.Repeat(" ", 2*blobSizeCutoff)
+
+	p, buf, err := DecodeFrom(strings.NewReader(ex))
+	by, rerr := ioutil.ReadAll(buf)
+
+	assert.Nil(t, rerr)
+	assert.Nil(t, p)
+	assert.True(t, errors.IsNotAPointerError(err))
+	assert.Equal(t, ex, string(by))
+}
+
+func TestDecodeManyLines(t *testing.T) {
+	ex := "version https://git-lfs.github.com/spec/v1\n" +
+		strings.Repeat("\n", maxPointerLines) +
+		`oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size 12345`
+
+	p, err := DecodePointer(bytes.NewBufferString(ex))
+	assert.Nil(t, p)
+	assert.True(t, errors.IsNotAPointerError(err))
+}
+
+func TestDecodeLargestSize(t *testing.T) {
+	ex := `version https://git-lfs.github.com/spec/v1
+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size 9223372036854775807`
+
+	p, err := DecodePointer(bytes.NewBufferString(ex))
+	assertEqualWithExample(t, ex, nil, err)
+	assertEqualWithExample(t, ex, int64(9223372036854775807), p.Size)
+}
+
+func TestDecodeInvalidSize(t *testing.T) {
+	for _, size := range []string{
+		"9223372036854775808",
+		"99999999999999999999999999999999999999999999999999",
+		"-1",
+		"+12345",
+		"012345",
+		"0x1f",
+		"1e9",
+		"12345 6",
+	} {
+		ex := `version https://git-lfs.github.com/spec/v1
+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size ` + size
+
+		p, err := DecodePointer(bytes.NewBufferString(ex))
+		assert.Nil(t, p, "size %q", size)
+		assert.EqualError(t, err, fmt.Sprintf("invalid size: %q", size))
+	}
+}
+
 func TestDecodeInvalid(t *testing.T) {
 	examples := []string{
 		"invalid stuff",
@@ -202,6 +328,11 @@ size 12345`,
 		// bad version
 		`version http://git-media.io/v/whatever
 oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size 12345`,
+
+		// unknown version
+		`version https://git-lfs.github.com/spec/v2
+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
 size 12345`,
 
 		// no version
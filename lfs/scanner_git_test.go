@@ -192,6 +192,56 @@ func TestScanPreviousVersions(t *testing.T) {
 	assert.Equal(t, expected, pointers)
 }
 
+func TestScanRefByTreeInBareRepo(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	inputs := []*test.CommitInput{
+		{ // 0
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+				{Filename: "folder/nested.txt", Size: 30},
+			},
+		},
+		{ // 1
+			NewBranch: "branch2",
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 25},
+			},
+		},
+	}
+	outputs := repo.AddCommits(inputs)
+
+	remote := repo.AddRemote("origin")
+	test.RunGitCommand(t, true, "push", "origin", "master", "branch2")
+
+	remote.Pushd()
+	defer remote.Popd()
+
+	pointers, err := ScanRefByTree(config.New(), "branch2")
+	assert.Nil(t, err)
+
+	expected := []*WrappedPointer{
+		{Name: "file1.txt", Pointer: outputs[1].Files[0]},
+		{Name: "folder/nested.txt", Pointer: outputs[0].Files[1]},
+	}
+	sort.Sort(test.WrappedPointersByOid(expected))
+	sort.Sort(test.WrappedPointersByOid(pointers))
+	assert.Equal(t, len(expected), len(pointers))
+	for i := range expected {
+		assert.Equal(t, expected[i].Name, pointers[i].Name)
+		assert.Equal(t, expected[i].Oid, pointers[i].Oid)
+		assert.Equal(t, expected[i].Size, pointers[i].Size)
+	}
+
+	_, err = ScanRefByTree(config.New(), "missing")
+	assert.NotNil(t, err)
+}
+
 func scanPreviousVersions(t *testing.T, ref string, since time.Time) ([]*WrappedPointer, error) {
 	pointers := make([]*WrappedPointer, 0, 10)
 	gitscanner := NewGitScanner(config.New(), func(p *WrappedPointer, err error) {
@@ -212,6 +212,24 @@ func CopyFileContents(cfg *config.Configuration, src string, dst string) error {
 	return os.Rename(tmp.Name(), dst)
 }
 
+// MoveFile moves "src" to "dst", and falls back to copying "src" to "dst" and
+// removing "src" when it cannot be renamed, for example when "dst" is on
+// another filesystem. If it returns an error, "src" is left where it was.
+func MoveFile(cfg *config.Configuration, src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := CopyFileContents(cfg, src, dst); err != nil {
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
 func LinkOrCopy(cfg *config.Configuration, src string, dst string) error {
 	if src == dst {
 		return nil
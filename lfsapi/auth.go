@@ -116,7 +116,8 @@ func (c *Client) doWithCreds(req *http.Request, credWrapper creds.CredentialHelp
 // 2. Existing Authorization or ?token query tells LFS that the request is ready.
 // 3. Netrc based on the hostname.
 // 4. URL authentication on the Endpoint URL or the Git Remote URL.
-// 5. Git Credential Helper, potentially prompting the user.
+// 5. Environment variables based on the hostname, e.g. GIT_LFS_<HOST>_TOKEN.
+// 6. Git Credential Helper, potentially prompting the user.
 //
 // There are three URLs in play, that make this a little confusing.
 //
@@ -51,5 +51,10 @@ func (c *Client) LogHTTPStats(w io.WriteCloser) {
 }
 
 func (c *Client) Close() error {
-	return c.client.Close()
+	c.credContext.Close()
+	err := c.ssh.close()
+	if cerr := c.client.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
@@ -28,6 +28,17 @@ type EndpointFinder interface {
 	AccessFor(rawurl string) creds.Access
 	SetAccess(access creds.Access)
 	GitProtocol() string
+
+	// ReplaceUrlAlias returns "rawurl" rewritten by the longest matching
+	// "url.*.insteadof" setting, or "url.*.pushinsteadof" setting for
+	// uploads.
+	ReplaceUrlAlias(operation, rawurl string) string
+
+	// Failover records that a request to the given endpoint failed with a
+	// server error, and returns the endpoint to retry it against, which
+	// Endpoint returns in its place once it has been failed over. It
+	// returns false if the request should not be retried.
+	Failover(e lfshttp.Endpoint) (lfshttp.Endpoint, bool)
 }
 
 type endpointGitFinder struct {
@@ -42,6 +53,8 @@ type endpointGitFinder struct {
 	accessMu  sync.Mutex
 	urlAccess map[string]creds.AccessMode
 	urlConfig *config.URLConfig
+
+	failover *endpointFailover
 }
 
 func NewEndpointFinder(ctx lfshttp.Context) EndpointFinder {
@@ -63,12 +76,16 @@ func NewEndpointFinder(ctx lfshttp.Context) EndpointFinder {
 		e.gitProtocol = v
 	}
 	initAliases(e, e.gitEnv)
+	e.failover = newEndpointFailover(e.gitEnv)
 
 	return e
 }
 
 func (e *endpointGitFinder) Endpoint(operation, remote string) lfshttp.Endpoint {
 	ep := e.getEndpoint(operation, remote)
+	if active := e.failover.Active(ep.Url); active != ep.Url {
+		ep = e.NewEndpoint(operation, active)
+	}
 	ep.Operation = operation
 	return ep
 }
@@ -175,24 +192,26 @@ func (e *endpointGitFinder) NewEndpoint(operation, rawurl string) lfshttp.Endpoi
 	}
 	u, err := url.Parse(rawurl)
 	if err != nil {
-		return lfshttp.EndpointFromBareSshUrl(rawurl)
+		return e.derivedEndpoint(operation, lfshttp.EndpointFromBareSshUrl(rawurl))
 	}
 
 	switch u.Scheme {
 	case "ssh", "git+ssh", "ssh+git":
-		return lfshttp.EndpointFromSshUrl(u)
+		return e.derivedEndpoint(operation, lfshttp.EndpointFromSshUrl(u))
 	case "http", "https":
 		return lfshttp.EndpointFromHttpUrl(u)
 	case "git":
-		return endpointFromGitUrl(u, e)
+		return e.derivedEndpoint(operation, endpointFromGitUrl(u, e))
 	case "file":
 		return lfshttp.EndpointFromFileUrl(u)
+	case "sftp":
+		return lfshttp.EndpointFromSftpUrl(u)
 	case "":
 		// If it looks like a local path, it probably is.
 		if _, err := os.Stat(rawurl); err == nil {
 			return lfshttp.EndpointFromLocalPath(rawurl)
 		}
-		return lfshttp.EndpointFromBareSshUrl(u.String())
+		return e.derivedEndpoint(operation, lfshttp.EndpointFromBareSshUrl(u.String()))
 	default:
 		if strings.HasPrefix(rawurl, u.Scheme+"::") {
 			// Looks like a remote helper; just pass it through.
@@ -207,10 +226,21 @@ func (e *endpointGitFinder) NewEndpoint(operation, rawurl string) lfshttp.Endpoi
 		// didn't know what to do with it.  Do what Git does and treat
 		// it as an SSH URL.  This ensures we handle SSH config aliases
 		// properly.
-		return lfshttp.EndpointFromBareSshUrl(u.String())
+		return e.derivedEndpoint(operation, lfshttp.EndpointFromBareSshUrl(u.String()))
 	}
 }
 
+// derivedEndpoint returns "ep", an endpoint whose HTTP URL was derived from a
+// URL of another scheme, with that URL rewritten by any "url.*.insteadof"
+// settings. Git only ever sees the URL it was derived from, so without this,
+// requests to the LFS API would bypass the hosts the settings point Git at.
+func (e *endpointGitFinder) derivedEndpoint(operation string, ep lfshttp.Endpoint) lfshttp.Endpoint {
+	if ep.Url != lfshttp.UrlUnknown {
+		ep.Url = e.ReplaceUrlAlias(operation, ep.Url)
+	}
+	return ep
+}
+
 func (e *endpointGitFinder) AccessFor(rawurl string) creds.Access {
 	accessurl := urlWithoutAuth(rawurl)
 
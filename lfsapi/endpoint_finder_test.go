@@ -688,6 +688,51 @@ func TestInsteadOf(t *testing.T) {
 	}
 }
 
+func TestInsteadOfDerivedEndpoint(t *testing.T) {
+	for desc, c := range map[string]struct {
+		Given     string
+		Operation string
+		Expected  string
+	}{
+		"https remote": {
+			"https://example.com/foo/bar.git", "download",
+			"https://mirror.example.com/foo/bar.git/info/lfs",
+		},
+		"ssh remote": {
+			"ssh://git@example.com/foo/bar.git", "download",
+			"https://mirror.example.com/foo/bar.git/info/lfs",
+		},
+		"bare ssh remote": {
+			"git@example.com:foo/bar.git", "download",
+			"https://mirror.example.com/foo/bar.git/info/lfs",
+		},
+		"git remote": {
+			"git://example.com/foo/bar.git", "download",
+			"https://mirror.example.com/foo/bar.git/info/lfs",
+		},
+		"ssh remote (upload)": {
+			"ssh://git@example.com/foo/bar.git", "upload",
+			"https://push.example.com/foo/bar.git/info/lfs",
+		},
+		"ssh remote aliased to ssh": {
+			"gh:foo/bar.git", "download",
+			"https://mirror.example.com/foo/bar.git/info/lfs",
+		},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			finder := NewEndpointFinder(lfshttp.NewContext(nil, nil, map[string]string{
+				"remote.origin.url":                           c.Given,
+				"url.https://mirror.example.com/.insteadof":   "https://example.com/",
+				"url.https://push.example.com/.pushinsteadof": "https://example.com/",
+				"url.ssh://git@example.com/.insteadof":        "gh:",
+			}))
+
+			e := finder.Endpoint(c.Operation, "origin")
+			assert.Equal(t, c.Expected, e.Url)
+		})
+	}
+}
+
 func TestNewEndpointFromCloneURLWithConfig(t *testing.T) {
 	expected := "https://foo/bar.git/info/lfs"
 	tests := []string{
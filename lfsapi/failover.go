@@ -0,0 +1,131 @@
+package lfsapi
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	// failoverServerErrors is the number of server errors after which an
+	// endpoint is replaced by the next one in "lfs.url.failover".
+	failoverServerErrors = 3
+)
+
+// endpointFailover replaces endpoints which keep failing with server errors
+// by those listed in "lfs.url.failover", in order, for the rest of the
+// process.
+type endpointFailover struct {
+	mu sync.Mutex
+
+	// urls are the failover endpoint URLs, in the order in which they are
+	// to be tried.
+	urls []string
+	// failures counts the server errors from each endpoint URL.
+	failures map[string]int
+	// active is the URL which replaces each endpoint URL that failed.
+	active map[string]string
+}
+
+func newEndpointFailover(git config.Environment) *endpointFailover {
+	f := &endpointFailover{
+		failures: make(map[string]int),
+		active:   make(map[string]string),
+	}
+
+	if git == nil {
+		return f
+	}
+	for _, value := range git.GetAll("lfs.url.failover") {
+		f.urls = append(f.urls, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	}
+	return f
+}
+
+// Active returns the URL of the endpoint to use in place of "rawurl", which is
+// "rawurl" itself unless it has been failed over.
+func (f *endpointFailover) Active(rawurl string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if active, ok := f.active[rawurl]; ok {
+		return active
+	}
+	return rawurl
+}
+
+// ServerError records a server error from the endpoint at "rawurl", and
+// returns the URL to which the request should be retried, or false if it
+// should not be. That is "rawurl" itself until it has failed
+// failoverServerErrors times, and then the next failover URL, which replaces it
+// from then on.
+func (f *endpointFailover) ServerError(rawurl string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.urls) == 0 {
+		return "", false
+	}
+
+	f.failures[rawurl]++
+	if f.failures[rawurl] < failoverServerErrors {
+		return rawurl, true
+	}
+
+	next := f.next(rawurl)
+	if len(next) == 0 {
+		tracerx.Printf("api: endpoint %s failed %d times, and no failover endpoints remain", rawurl, f.failures[rawurl])
+		return "", false
+	}
+
+	tracerx.Printf("api: endpoint %s failed %d times, failing over to %s", rawurl, f.failures[rawurl], next)
+	for from, to := range f.active {
+		if to == rawurl {
+			f.active[from] = next
+		}
+	}
+	f.active[rawurl] = next
+	return next, true
+}
+
+// next returns the failover URL to try after "rawurl", which is the first one
+// if "rawurl" isn't one of them, or the empty string if none is left. URLs
+// which have already failed over are skipped.
+func (f *endpointFailover) next(rawurl string) string {
+	start := 0
+	for i, u := range f.urls {
+		if u == rawurl {
+			start = i + 1
+			break
+		}
+	}
+
+	for _, u := range f.urls[start:] {
+		if _, failed := f.active[u]; !failed && u != rawurl {
+			return u
+		}
+	}
+	return ""
+}
+
+// Failover records that a request to "e" failed with a server error, and
+// returns the endpoint to which the request should be retried, if any. See
+// endpointFailover.ServerError.
+func (e *endpointGitFinder) Failover(ep lfshttp.Endpoint) (lfshttp.Endpoint, bool) {
+	next, ok := e.failover.ServerError(ep.Url)
+	if !ok {
+		return ep, false
+	}
+	if next == ep.Url {
+		return ep, true
+	}
+
+	failover := e.NewEndpoint(ep.Operation, next)
+	failover.Operation = ep.Operation
+	return failover, true
+}
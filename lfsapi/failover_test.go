@@ -0,0 +1,51 @@
+package lfsapi
+
+import (
+	"testing"
+
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointFailover(t *testing.T) {
+	finder := NewEndpointFinder(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url":          "https://primary.example.com/lfs",
+		"lfs.url.failover": "https://backup1.example.com/lfs, https://backup2.example.com/lfs",
+	}))
+
+	e := finder.Endpoint("download", "")
+	for i := 1; i < failoverServerErrors; i++ {
+		next, ok := finder.Failover(e)
+		assert.True(t, ok)
+		assert.Equal(t, e.Url, next.Url)
+	}
+
+	next, ok := finder.Failover(e)
+	assert.True(t, ok)
+	assert.Equal(t, "https://backup1.example.com/lfs", next.Url)
+	assert.Equal(t, "download", next.Operation)
+	assert.Equal(t, "https://backup1.example.com/lfs", finder.Endpoint("download", "").Url)
+
+	for i := 0; i < failoverServerErrors; i++ {
+		next, ok = finder.Failover(next)
+	}
+	assert.True(t, ok)
+	assert.Equal(t, "https://backup2.example.com/lfs", next.Url)
+	assert.Equal(t, "https://backup2.example.com/lfs", finder.Endpoint("download", "").Url)
+
+	for i := 1; i < failoverServerErrors; i++ {
+		_, ok = finder.Failover(next)
+		assert.True(t, ok)
+	}
+	_, ok = finder.Failover(next)
+	assert.False(t, ok)
+}
+
+func TestEndpointFailoverWithoutFailoverURLs(t *testing.T) {
+	finder := NewEndpointFinder(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": "https://primary.example.com/lfs",
+	}))
+
+	_, ok := finder.Failover(finder.Endpoint("download", ""))
+	assert.False(t, ok)
+}
@@ -15,6 +15,7 @@ type Client struct {
 	credContext *creds.CredentialHelperContext
 
 	client *lfshttp.Client
+	ssh    sshConnections
 }
 
 func NewClient(ctx lfshttp.Context) (*Client, error) {
@@ -35,6 +36,11 @@ func NewClient(ctx lfshttp.Context) (*Client, error) {
 		client:      httpClient,
 		credContext: creds.NewCredentialHelperContext(gitEnv, osEnv),
 	}
+	// The href the server hands out over SSH is rewritten like the
+	// endpoint it's used in place of.
+	httpClient.RewriteHref = func(operation, href string) string {
+		return c.Endpoints.ReplaceUrlAlias(operation, href)
+	}
 
 	return c, nil
 }
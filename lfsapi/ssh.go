@@ -0,0 +1,120 @@
+package lfsapi
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/git-lfs/git-lfs/ssh"
+	"github.com/rubyist/tracerx"
+)
+
+// Values of lfs.<url>.sshtransfer, which determines whether objects and locks
+// are transferred through git-lfs-transfer(1) on the SSH server of an SSH
+// endpoint, rather than through the HTTPS endpoint given by
+// git-lfs-authenticate.
+const (
+	// sshTransferNegotiate uses git-lfs-transfer(1) if the server supports
+	// it, and git-lfs-authenticate otherwise.
+	sshTransferNegotiate = "negotiate"
+	// sshTransferAlways uses git-lfs-transfer(1), failing if the server
+	// doesn't support it.
+	sshTransferAlways = "always"
+	// sshTransferNever uses git-lfs-authenticate.
+	sshTransferNever = "never"
+)
+
+// sshConnections holds the connections to git-lfs-transfer(1) started by a
+// Client, by operation and SSH endpoint. Those which couldn't be started hold
+// nil, and the error starting them, so that each is only attempted once.
+type sshConnections struct {
+	conns map[string]*ssh.Connection
+	errs  map[string]error
+	mu    sync.Mutex
+}
+
+// SSHTransfer returns the connection to git-lfs-transfer(1) for "operation" on
+// the SSH server of the endpoint of "remote", starting it the first time it is
+// asked for. It returns nil if the endpoint isn't an SSH one, or if the pure
+// SSH transfer protocol isn't used for it, as set by lfs.<url>.sshtransfer.
+// An error is only returned if the protocol must be used, and can't be.
+func (c *Client) SSHTransfer(operation, remote string) (*ssh.Connection, error) {
+	return c.SSHTransferFor(c.Endpoints.Endpoint(operation, remote), operation)
+}
+
+// SSHTransferFor returns the connection to git-lfs-transfer(1) for "operation"
+// on the SSH server of the endpoint "e", like SSHTransfer.
+func (c *Client) SSHTransferFor(e lfshttp.Endpoint, operation string) (*ssh.Connection, error) {
+	if len(e.SshUserAndHost) == 0 {
+		return nil, nil
+	}
+
+	mode := sshTransferMode(c.GitEnv(), e.Url)
+	if mode == sshTransferNever {
+		return nil, nil
+	}
+
+	c.ssh.mu.Lock()
+	defer c.ssh.mu.Unlock()
+
+	key := strings.Join([]string{operation, e.SshUserAndHost, e.SshPort, e.SshPath}, "//")
+	if conn, ok := c.ssh.conns[key]; ok {
+		if conn == nil && mode == sshTransferAlways {
+			return nil, c.ssh.errs[key]
+		}
+		return conn, nil
+	}
+	if c.ssh.conns == nil {
+		c.ssh.conns = make(map[string]*ssh.Connection)
+		c.ssh.errs = make(map[string]error)
+	}
+
+	tracerx.Printf("ssh: starting git-lfs-transfer for %q on %s", operation, e.SshUserAndHost)
+	conn, err := ssh.Start(c.OSEnv(), c.GitEnv(), e, operation)
+	c.ssh.conns[key] = conn
+	if err != nil {
+		c.ssh.errs[key] = err
+		if mode == sshTransferAlways {
+			return nil, err
+		}
+		tracerx.Printf("ssh: git-lfs-transfer is not available, using git-lfs-authenticate: %s", err)
+		return nil, nil
+	}
+	return conn, nil
+}
+
+// sshTransferMode returns the value of lfs.<url>.sshtransfer for the endpoint
+// URL "rawurl", or the default, sshTransferNegotiate, if it isn't set or isn't
+// valid.
+func sshTransferMode(git config.Environment, rawurl string) string {
+	v, ok := config.NewURLConfig(git).Get("lfs", rawurl, "sshtransfer")
+	if !ok {
+		return sshTransferNegotiate
+	}
+
+	switch mode := strings.ToLower(v); mode {
+	case sshTransferNegotiate, sshTransferAlways, sshTransferNever:
+		return mode
+	}
+	tracerx.Printf("ssh: ignoring invalid lfs.sshtransfer value %q", v)
+	return sshTransferNegotiate
+}
+
+// close closes every connection which was started.
+func (s *sshConnections) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for key, conn := range s.conns {
+		if conn == nil {
+			continue
+		}
+		if cerr := conn.Close(); err == nil {
+			err = cerr
+		}
+		delete(s.conns, key)
+	}
+	return err
+}
@@ -52,10 +52,18 @@ type Client struct {
 	ConcurrentTransfers int
 	SkipSSLVerify       bool
 
+	// MaxIdleConnsPerHost is the number of idle connections kept open to
+	// each host for reuse. If less than one, ConcurrentTransfers is used.
+	MaxIdleConnsPerHost int
+
 	Verbose          bool
 	DebuggingVerbose bool
 	VerboseOut       io.Writer
 
+	// RewriteHref, if set, rewrites the href returned by
+	// git-lfs-authenticate for an endpoint, given the endpoint's operation.
+	RewriteHref func(operation, href string) string
+
 	hostClients map[hostData]*http.Client
 	clientMu    sync.Mutex
 
@@ -68,6 +76,10 @@ type Client struct {
 	credHelperContext *creds.CredentialHelperContext
 
 	sshTries int
+
+	// dnsCache caches the addresses of the hosts connected to, or is nil
+	// if they're resolved for every connection.
+	dnsCache *dnsCache
 }
 
 func NewClient(ctx Context) (*Client, error) {
@@ -90,6 +102,7 @@ func NewClient(ctx Context) (*Client, error) {
 		KeepaliveTimeout:    gitEnv.Int("lfs.keepalive", 0),
 		TLSTimeout:          gitEnv.Int("lfs.tlstimeout", 0),
 		ConcurrentTransfers: gitEnv.Int("lfs.concurrenttransfers", 8),
+		MaxIdleConnsPerHost: gitEnv.Int("lfs.transfer.maxidleconnsperhost", 0),
 		SkipSSLVerify:       !gitEnv.Bool("http.sslverify", true) || osEnv.Bool("GIT_SSL_NO_VERIFY", false),
 		Verbose:             osEnv.Bool("GIT_CURL_VERBOSE", false),
 		DebuggingVerbose:    osEnv.Bool("LFS_DEBUG_HTTP", false),
@@ -98,6 +111,7 @@ func NewClient(ctx Context) (*Client, error) {
 		uc:                  config.NewURLConfig(gitEnv),
 		sshTries:            gitEnv.Int("lfs.ssh.retries", 5),
 		credHelperContext:   creds.NewCredentialHelperContext(gitEnv, osEnv),
+		dnsCache:            newDNSCache(time.Duration(gitEnv.Int("lfs.transfer.dnscachettl", 0)) * time.Second),
 	}
 
 	return c, nil
@@ -129,6 +143,9 @@ func (c *Client) NewRequest(method string, e Endpoint, suffix string, body inter
 	prefix := e.Url
 	if len(sshRes.Href) > 0 {
 		prefix = sshRes.Href
+		if c.RewriteHref != nil {
+			prefix = c.RewriteHref(e.Operation, prefix)
+		}
 	}
 
 	if !httpRE.MatchString(prefix) {
@@ -145,6 +162,7 @@ func (c *Client) NewRequest(method string, e Endpoint, suffix string, body inter
 		req.Header.Set(key, value)
 	}
 	req.Header.Set("Accept", MediaType)
+	req.Header.Set("Accept-Encoding", "gzip")
 
 	if body != nil {
 		if merr := MarshalToRequest(req, body); merr != nil {
@@ -311,6 +329,7 @@ func (c *Client) DoWithRedirect(cli *http.Client, req *http.Request, remote stri
 		return nil, nil, nil
 	}
 
+	decompressResponse(res)
 	if res.Uncompressed {
 		tracerx.Printf("http: decompressed gzipped response")
 	}
@@ -401,6 +420,11 @@ func (c *Client) Transport(u *url.URL, access creds.AccessMode) (http.RoundTripp
 		concurrentTransfers = 8
 	}
 
+	maxIdleConnsPerHost := c.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost < 1 {
+		maxIdleConnsPerHost = concurrentTransfers
+	}
+
 	dialtime := c.DialTimeout
 	if dialtime < 1 {
 		dialtime = 30
@@ -418,7 +442,10 @@ func (c *Client) Transport(u *url.URL, access creds.AccessMode) (http.RoundTripp
 	tr := &http.Transport{
 		Proxy:               proxyFromClient(c),
 		TLSHandshakeTimeout: time.Duration(tlstime) * time.Second,
-		MaxIdleConnsPerHost: concurrentTransfers,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		// Compression is only asked for where it is likely to help;
+		// see decompressResponse.
+		DisableCompression: true,
 	}
 
 	activityTimeout := 30
@@ -436,10 +463,15 @@ func (c *Client) Transport(u *url.URL, access creds.AccessMode) (http.RoundTripp
 		DualStack: true,
 	}
 
+	dial := dialFunc(dialer.DialContext)
+	if c.dnsCache != nil {
+		dial = c.dnsCache.Dial(dialer)
+	}
+
 	if activityTimeout > 0 {
 		activityDuration := time.Duration(activityTimeout) * time.Second
 		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			c, err := dialer.DialContext(ctx, network, addr)
+			c, err := dial(ctx, network, addr)
 			if c == nil {
 				return c, err
 			}
@@ -450,7 +482,7 @@ func (c *Client) Transport(u *url.URL, access creds.AccessMode) (http.RoundTripp
 			return &deadlineConn{Timeout: activityDuration, Conn: c}, err
 		}
 	} else {
-		tr.DialContext = dialer.DialContext
+		tr.DialContext = dial
 	}
 
 	tr.TLSClientConfig = &tls.Config{
@@ -7,8 +7,10 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -178,10 +180,12 @@ func TestClientRedirect(t *testing.T) {
 
 func TestNewClient(t *testing.T) {
 	c, err := NewClient(NewContext(nil, nil, map[string]string{
-		"lfs.dialtimeout":         "151",
-		"lfs.keepalive":           "152",
-		"lfs.tlstimeout":          "153",
-		"lfs.concurrenttransfers": "154",
+		"lfs.dialtimeout":                  "151",
+		"lfs.keepalive":                    "152",
+		"lfs.tlstimeout":                   "153",
+		"lfs.concurrenttransfers":          "154",
+		"lfs.transfer.maxidleconnsperhost": "155",
+		"lfs.transfer.dnscachettl":         "156",
 	}))
 
 	require.Nil(t, err)
@@ -189,6 +193,9 @@ func TestNewClient(t *testing.T) {
 	assert.Equal(t, 152, c.KeepaliveTimeout)
 	assert.Equal(t, 153, c.TLSTimeout)
 	assert.Equal(t, 154, c.ConcurrentTransfers)
+	assert.Equal(t, 155, c.MaxIdleConnsPerHost)
+	require.NotNil(t, c.dnsCache)
+	assert.Equal(t, 156*time.Second, c.dnsCache.ttl)
 }
 
 func TestNewClientWithGitSSLVerify(t *testing.T) {
@@ -258,6 +265,28 @@ func TestNewRequest(t *testing.T) {
 	}
 }
 
+func TestNewRequestRewritesSSHHref(t *testing.T) {
+	c, err := NewClient(NewContext(nil, nil, nil))
+	require.Nil(t, err)
+
+	ssh := newFakeResolver()
+	ssh.responses["git@example.com"] = sshAuthResponse{Href: "https://example.com/repo.git/info/lfs"}
+	c.SSH = ssh
+	c.RewriteHref = func(operation, href string) string {
+		assert.Equal(t, "download", operation)
+		return strings.Replace(href, "https://example.com/", "https://mirror.example.com/", 1)
+	}
+
+	req, err := c.NewRequest("POST", Endpoint{
+		Url:            "https://example.com/repo.git/info/lfs",
+		SshUserAndHost: "git@example.com",
+		SshPath:        "repo.git",
+		Operation:      "download",
+	}, "objects/batch", nil)
+	require.Nil(t, err)
+	assert.Equal(t, "https://mirror.example.com/repo.git/info/lfs/objects/batch", req.URL.String())
+}
+
 func TestNewRequestWithBody(t *testing.T) {
 	c, err := NewClient(NewContext(nil, nil, nil))
 	require.Nil(t, err)
@@ -0,0 +1,52 @@
+package lfshttp
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decompressResponse replaces the body of "res" with its decompressed form if
+// the server compressed it with gzip, as it may for API responses, which ask
+// for it with an Accept-Encoding header, or for an object whose action asked
+// for it.
+//
+// Go's own transparent decompression is disabled, since it would ask for every
+// object to be compressed, and most objects are already compressed media.
+func decompressResponse(res *http.Response) {
+	if res.Body == nil || !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return
+	}
+
+	res.Body = &gzipBody{body: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+}
+
+// gzipBody decompresses the body of a response as it is read. The gzip header
+// is not read until the body is, since responses to HEAD requests and the
+// like have none.
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (g *gzipBody) Read(p []byte) (int, error) {
+	if g.err != nil {
+		return 0, g.err
+	}
+	if g.zr == nil {
+		if g.zr, g.err = gzip.NewReader(g.body); g.err != nil {
+			return 0, g.err
+		}
+	}
+	return g.zr.Read(p)
+}
+
+func (g *gzipBody) Close() error {
+	return g.body.Close()
+}
@@ -0,0 +1,86 @@
+package lfshttp
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipHandler(t *testing.T, wantAccept string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, wantAccept, r.Header.Get("Accept-Encoding"))
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(`{"objects":[]}`))
+		gz.Close()
+	})
+}
+
+func TestClientDecompressesAPIResponses(t *testing.T) {
+	srv := httptest.NewServer(gzipHandler(t, "gzip"))
+	defer srv.Close()
+
+	c, err := NewClient(nil)
+	require.Nil(t, err)
+
+	req, err := c.NewRequest("POST", Endpoint{Url: srv.URL}, "objects/batch", nil)
+	require.Nil(t, err)
+
+	res, err := c.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	assert.True(t, res.Uncompressed)
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	assert.EqualValues(t, -1, res.ContentLength)
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.Nil(t, err)
+	assert.Equal(t, `{"objects":[]}`, string(body))
+}
+
+func TestClientDoesNotAskForCompressedObjects(t *testing.T) {
+	srv := httptest.NewServer(gzipHandler(t, ""))
+	defer srv.Close()
+
+	c, err := NewClient(nil)
+	require.Nil(t, err)
+
+	req, err := http.NewRequest("GET", srv.URL+"/objects/oid", nil)
+	require.Nil(t, err)
+
+	res, err := c.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	// The server may still compress an object of its own accord.
+	body, err := ioutil.ReadAll(res.Body)
+	require.Nil(t, err)
+	assert.Equal(t, `{"objects":[]}`, string(body))
+}
+
+func TestClientDecompressesResponsesWithoutBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(204)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(nil)
+	require.Nil(t, err)
+
+	req, err := c.NewRequest("GET", Endpoint{Url: srv.URL}, "locks", nil)
+	require.Nil(t, err)
+
+	res, err := c.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, 204, res.StatusCode)
+}
@@ -0,0 +1,268 @@
+package lfshttp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rubyist/tracerx"
+)
+
+// dialFunc dials a connection to "addr" on "network", like
+// (*net.Dialer).DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dnsCache caches the addresses host names resolve to for a fixed time, so that
+// making many connections to the same host in a short time doesn't resolve its
+// name for each one. The system resolver is consulted again once an entry has
+// expired, or after a connection to the cached addresses fails, so that
+// changes to DNS are picked up.
+type dnsCache struct {
+	ttl time.Duration
+
+	// lookup resolves a host name to its addresses.
+	lookup func(ctx context.Context, host string) ([]string, error)
+	// now returns the current time.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+// dnsCacheEntry is the result of resolving a single host name. Until done is
+// closed, the name is still being resolved, and addrs and err are not set.
+type dnsCacheEntry struct {
+	done    chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// newDNSCache returns a *dnsCache keeping the addresses it resolves through the
+// system resolver for "ttl", or nil if "ttl" isn't positive.
+func newDNSCache(ttl time.Duration) *dnsCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &dnsCache{
+		ttl:     ttl,
+		lookup:  net.DefaultResolver.LookupHost,
+		now:     time.Now,
+		entries: make(map[string]*dnsCacheEntry),
+	}
+}
+
+// LookupHost returns the addresses of "host", resolving it only if it hasn't
+// been resolved within the TTL. Callers looking up the same host while it is
+// resolved wait for, and share, that result. Failures aren't cached.
+func (d *dnsCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	e, ok := d.entries[host]
+	if ok {
+		select {
+		case <-e.done:
+			if e.err != nil || !d.now().Before(e.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		e = &dnsCacheEntry{done: make(chan struct{})}
+		d.entries[host] = e
+		d.mu.Unlock()
+
+		tracerx.Printf("dns: resolving %s", host)
+		e.addrs, e.err = d.lookup(ctx, host)
+		e.expires = d.now().Add(d.ttl)
+		close(e.done)
+
+		return e.addrs, e.err
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Forget removes the cached addresses of "host", so that it is resolved again
+// when next looked up.
+func (d *dnsCache) Forget(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.entries, host)
+}
+
+// defaultFallbackDelay is how long to wait for a connection to the addresses of
+// the first address family of a host before also trying those of the other,
+// when the *net.Dialer doesn't say, as in (*net.Dialer).FallbackDelay.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// Dial returns a dialFunc which dials the cached addresses of the host in
+// "addr" with "dialer", as "dialer" would dial those it resolved itself: within
+// its Timeout overall, and racing the addresses of the second address family
+// against those of the first after its FallbackDelay, as in RFC 6555 ("Happy
+// Eyeballs"), unless that is negative. Addresses which are already IP
+// addresses are dialed directly.
+func (d *dnsCache) Dial(dialer *net.Dialer) dialFunc {
+	fallbackDelay := dialer.FallbackDelay
+	if fallbackDelay == 0 {
+		fallbackDelay = defaultFallbackDelay
+	}
+	return d.dial(dialer.DialContext, dialer.Timeout, fallbackDelay)
+}
+
+// dial returns a dialFunc which dials the cached addresses of the host in
+// "addr" with "dial", each in turn, within "timeout", if it is positive. The
+// addresses of the second address family are tried alongside those of the
+// first once "fallbackDelay" has passed, or once those of the first have
+// failed, unless "fallbackDelay" is negative.
+func (d *dnsCache) dial(dial dialFunc, timeout, fallbackDelay time.Duration) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		ips, err := d.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			d.Forget(host)
+			return nil, &net.DNSError{Err: "no such host", Name: host}
+		}
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		var conn net.Conn
+		primaries, fallbacks := partitionAddrs(ips)
+		if len(fallbacks) == 0 || fallbackDelay < 0 {
+			conn, err = dialSerial(ctx, dial, network, port, append(primaries, fallbacks...))
+		} else {
+			conn, err = dialParallel(ctx, dial, network, port, primaries, fallbacks, fallbackDelay)
+		}
+		if err != nil {
+			// The host may have moved, so don't keep addresses
+			// which can't be connected to.
+			d.Forget(host)
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// partitionAddrs divides "ips" into those of the address family of the first
+// of them, and those of the other, keeping their order.
+func partitionAddrs(ips []string) (primaries, fallbacks []string) {
+	var primaryIsV4 bool
+	for i, ip := range ips {
+		isV4 := net.ParseIP(ip).To4() != nil
+		if i == 0 {
+			primaryIsV4 = isV4
+		}
+		if isV4 == primaryIsV4 {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialSerial dials each of "ips" at "port" with "dial" in turn, returning the
+// first connection made, or the error from the last if none can be.
+func dialSerial(ctx context.Context, dial dialFunc, network, port string, ips []string) (net.Conn, error) {
+	var err error
+	for _, ip := range ips {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		var conn net.Conn
+		conn, err = dial(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dialParallel races dialing "primaries" against dialing "fallbacks", which
+// starts after "fallbackDelay", or as soon as "primaries" have all failed. It
+// returns the first connection made, closing any made after it, or the error
+// from dialing "primaries" if neither can connect.
+func dialParallel(ctx context.Context, dial dialFunc, network, port string, primaries, fallbacks []string, fallbackDelay time.Duration) (net.Conn, error) {
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+
+	results := make(chan dialResult)
+	returned := make(chan struct{})
+	defer close(returned)
+
+	race := func(ctx context.Context, ips []string, primary bool) {
+		conn, err := dialSerial(ctx, dial, network, port, ips)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go race(primaryCtx, primaries, true)
+
+	fallbackTimer := time.NewTimer(fallbackDelay)
+	defer fallbackTimer.Stop()
+
+	var primaryErr error
+	var primaryDone, fallbackStarted, fallbackDone bool
+	for {
+		select {
+		case <-fallbackTimer.C:
+			fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+			defer fallbackCancel()
+			go race(fallbackCtx, fallbacks, false)
+			fallbackStarted = true
+
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryDone = true
+				primaryErr = res.err
+			} else {
+				fallbackDone = true
+				if primaryErr == nil {
+					primaryErr = res.err
+				}
+			}
+			if primaryDone && fallbackDone {
+				return nil, primaryErr
+			}
+			if res.primary && !fallbackStarted && fallbackTimer.Stop() {
+				// Try the fallbacks now, rather than waiting
+				// for the timer.
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package lfshttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSCacheDisabled(t *testing.T) {
+	assert.Nil(t, newDNSCache(0))
+	assert.Nil(t, newDNSCache(-1*time.Second))
+
+	c, err := NewClient(nil)
+	require.Nil(t, err)
+	assert.Nil(t, c.dnsCache)
+}
+
+func TestDNSCacheHonorsTTL(t *testing.T) {
+	var lookups uint32
+	now := time.Unix(1000, 0)
+
+	d := newDNSCache(10 * time.Second)
+	d.now = func() time.Time { return now }
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddUint32(&lookups, 1)
+		return []string{"127.0.0.1"}, nil
+	}
+
+	for i := 0; i < 100; i++ {
+		addrs, err := d.LookupHost(context.Background(), "lfs.example.com")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"127.0.0.1"}, addrs)
+	}
+	assert.EqualValues(t, 1, lookups)
+
+	now = now.Add(9 * time.Second)
+	d.LookupHost(context.Background(), "lfs.example.com")
+	assert.EqualValues(t, 1, lookups)
+
+	now = now.Add(1 * time.Second)
+	d.LookupHost(context.Background(), "lfs.example.com")
+	assert.EqualValues(t, 2, lookups)
+
+	d.LookupHost(context.Background(), "other.example.com")
+	assert.EqualValues(t, 3, lookups)
+}
+
+func TestDNSCacheDoesNotCacheFailures(t *testing.T) {
+	var lookups uint32
+
+	d := newDNSCache(time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddUint32(&lookups, 1)
+		return nil, errors.New("lookup failed")
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := d.LookupHost(context.Background(), "lfs.example.com")
+		assert.NotNil(t, err)
+	}
+	assert.EqualValues(t, 3, lookups)
+}
+
+func TestDNSCacheSharesConcurrentLookups(t *testing.T) {
+	var lookups uint32
+	release := make(chan struct{})
+
+	d := newDNSCache(time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddUint32(&lookups, 1)
+		<-release
+		return []string{"127.0.0.1"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			addrs, err := d.LookupHost(context.Background(), "lfs.example.com")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"127.0.0.1"}, addrs)
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, lookups)
+}
+
+func TestDNSCacheForgetsUnreachableAddresses(t *testing.T) {
+	var lookups uint32
+
+	d := newDNSCache(time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddUint32(&lookups, 1)
+		return []string{"192.0.2.1", "192.0.2.2"}, nil
+	}
+
+	var dialed []string
+	dial := d.dial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, errors.New("connection refused")
+	}, 0, defaultFallbackDelay)
+
+	_, err := dial(context.Background(), "tcp", "lfs.example.com:443")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"192.0.2.1:443", "192.0.2.2:443"}, dialed)
+
+	dial(context.Background(), "tcp", "lfs.example.com:443")
+	assert.EqualValues(t, 2, lookups)
+
+	// IP addresses are dialed as they are.
+	dialed = nil
+	dial(context.Background(), "tcp", "192.0.2.3:443")
+	assert.Equal(t, []string{"192.0.2.3:443"}, dialed)
+	assert.EqualValues(t, 2, lookups)
+}
+
+func TestDNSCacheFallsBackToOtherAddressFamily(t *testing.T) {
+	d := newDNSCache(time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"2001:db8::1", "192.0.2.1"}, nil
+	}
+
+	var dialedMu sync.Mutex
+	var dialed []string
+	dial := d.dial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialedMu.Lock()
+		dialed = append(dialed, addr)
+		dialedMu.Unlock()
+
+		if addr == "[2001:db8::1]:443" {
+			// An unreachable address which never answers.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		conn, _ := net.Pipe()
+		return conn, nil
+	}, time.Minute, 10*time.Millisecond)
+
+	start := time.Now()
+	conn, err := dial(context.Background(), "tcp", "lfs.example.com:443")
+	require.Nil(t, err)
+	conn.Close()
+
+	assert.True(t, time.Since(start) < 10*time.Second, "waited %s to fall back", time.Since(start))
+	dialedMu.Lock()
+	assert.Equal(t, []string{"[2001:db8::1]:443", "192.0.2.1:443"}, dialed)
+	dialedMu.Unlock()
+}
+
+func TestDNSCacheDialsWithinTimeout(t *testing.T) {
+	d := newDNSCache(time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, nil
+	}
+
+	var dialed uint32
+	dial := d.dial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddUint32(&dialed, 1)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, 20*time.Millisecond, defaultFallbackDelay)
+
+	// The timeout bounds dialing all of the addresses, not each one.
+	_, err := dial(context.Background(), "tcp", "lfs.example.com:443")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.EqualValues(t, 1, dialed)
+}
+
+func TestDNSCacheDialKeepsDialerSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.Nil(t, err)
+
+	d := newDNSCache(time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	}
+
+	// A dialer whose timeout has already passed can't connect, even
+	// though the address is reachable.
+	dial := d.Dial(&net.Dialer{Timeout: time.Nanosecond})
+	_, err = dial(context.Background(), "tcp", "lfs.example.test:"+u.Port())
+	assert.NotNil(t, err)
+
+	dial = d.Dial(&net.Dialer{Timeout: time.Minute})
+	conn, err := dial(context.Background(), "tcp", "lfs.example.test:"+u.Port())
+	require.Nil(t, err)
+	conn.Close()
+}
+
+func TestClientDNSCacheManyTransfers(t *testing.T) {
+	var called uint32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint32(&called, 1)
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.Nil(t, err)
+
+	c, err := NewClient(NewContext(nil, nil, map[string]string{
+		"lfs.transfer.dnscachettl": "60",
+	}))
+	require.Nil(t, err)
+	require.NotNil(t, c.dnsCache)
+
+	var lookups uint32
+	c.dnsCache.lookup = func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddUint32(&lookups, 1)
+		assert.Equal(t, "lfs.example.test", host)
+		return []string{"127.0.0.1"}, nil
+	}
+
+	const transfers = 100
+	var wg sync.WaitGroup
+	for i := 0; i < transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, err := http.NewRequest("GET", "http://lfs.example.test:"+u.Port()+"/objects", nil)
+			require.Nil(t, err)
+			// Make a new connection for every request.
+			req.Close = true
+
+			res, err := c.Do(req)
+			if assert.Nil(t, err) {
+				res.Body.Close()
+				assert.Equal(t, 200, res.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, transfers, called)
+	assert.EqualValues(t, 1, lookups)
+}
+
+func TestClientMaxIdleConnsPerHost(t *testing.T) {
+	u, err := url.Parse("https://lfs.example.com")
+	require.Nil(t, err)
+
+	for desc, c := range map[string]struct {
+		Config   map[string]string
+		Expected int
+	}{
+		"default": {
+			Config:   map[string]string{},
+			Expected: 8,
+		},
+		"concurrent transfers": {
+			Config:   map[string]string{"lfs.concurrenttransfers": "3"},
+			Expected: 3,
+		},
+		"configured": {
+			Config: map[string]string{
+				"lfs.concurrenttransfers":          "3",
+				"lfs.transfer.maxidleconnsperhost": "64",
+			},
+			Expected: 64,
+		},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			client, err := NewClient(NewContext(nil, nil, c.Config))
+			require.Nil(t, err)
+
+			tr, err := client.Transport(u, "basic")
+			require.Nil(t, err)
+			require.IsType(t, &http.Transport{}, tr)
+			assert.Equal(t, c.Expected, tr.(*http.Transport).MaxIdleConnsPerHost)
+		})
+	}
+}
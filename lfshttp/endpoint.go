@@ -117,3 +117,9 @@ func EndpointFromFileUrl(u *url.URL) Endpoint {
 	// just pass this straight through
 	return Endpoint{Url: u.String()}
 }
+
+// Construct a new endpoint from an SFTP URL
+func EndpointFromSftpUrl(u *url.URL) Endpoint {
+	// just pass this straight through
+	return Endpoint{Url: u.String()}
+}
@@ -51,7 +51,22 @@ func proxyFromClient(c *Client) func(req *http.Request) (*url.URL, error) {
 	}
 }
 
+// getProxyServers returns the proxies to use for "u", and the hosts which
+// bypass them, with the same precedence as Git: "http.<url>.proxy" for the
+// best matching URL, then "http.proxy", then the HTTPS_PROXY and HTTP_PROXY
+// environment variables. A proxy configured with an empty value disables
+// proxying for the URLs it matches, even if the environment names one.
 func getProxyServers(u *url.URL, urlCfg *config.URLConfig, osEnv config.Environment) (httpsProxy string, httpProxy string, noProxy string) {
+	if urlCfg != nil {
+		if gitProxy, ok := urlCfg.Get("http", u.String(), "proxy"); ok {
+			httpsProxy = gitProxy
+			httpProxy = gitProxy
+			if len(gitProxy) == 0 {
+				return
+			}
+		}
+	}
+
 	if osEnv == nil {
 		return
 	}
@@ -72,16 +87,6 @@ func getProxyServers(u *url.URL, urlCfg *config.URLConfig, osEnv config.Environm
 		httpProxy, _ = osEnv.Get("http_proxy")
 	}
 
-	if urlCfg != nil {
-		gitProxy, ok := urlCfg.Get("http", u.String(), "proxy")
-		if len(gitProxy) > 0 && ok {
-			if u.Scheme == "https" {
-				httpsProxy = gitProxy
-			}
-			httpProxy = gitProxy
-		}
-	}
-
 	noProxy, _ = osEnv.Get("NO_PROXY")
 	if len(noProxy) == 0 {
 		noProxy, _ = osEnv.Get("no_proxy")
@@ -39,6 +39,72 @@ func TestProxyForURL(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestProxyForURLOverridesEnvironment(t *testing.T) {
+	c, err := NewClient(NewContext(nil, map[string]string{
+		"HTTPS_PROXY": "https://proxy-from-env:8080",
+	}, map[string]string{
+		"http.https://some-host.com.proxy": "https://proxy-for-some-host:8080",
+	}))
+	require.Nil(t, err)
+
+	req, err := http.NewRequest("GET", "https://some-host.com/foo/bar", nil)
+	require.Nil(t, err)
+
+	proxyURL, err := proxyFromClient(c)(req)
+	assert.Equal(t, "proxy-for-some-host:8080", proxyURL.Host)
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("GET", "https://other-host.com/foo/bar", nil)
+	require.Nil(t, err)
+
+	proxyURL, err = proxyFromClient(c)(req)
+	assert.Equal(t, "proxy-from-env:8080", proxyURL.Host)
+	assert.Nil(t, err)
+}
+
+func TestProxyForURLDisabledByEmptyValue(t *testing.T) {
+	c, err := NewClient(NewContext(nil, map[string]string{
+		"HTTPS_PROXY": "https://proxy-from-env:8080",
+	}, map[string]string{
+		"http.proxy":                       "https://proxy-for-everyone:8080",
+		"http.https://some-host.com.proxy": "",
+	}))
+	require.Nil(t, err)
+
+	req, err := http.NewRequest("GET", "https://some-host.com/foo/bar", nil)
+	require.Nil(t, err)
+
+	proxyURL, err := proxyFromClient(c)(req)
+	assert.Nil(t, proxyURL)
+	assert.Nil(t, err)
+
+	req, err = http.NewRequest("GET", "https://other-host.com/foo/bar", nil)
+	require.Nil(t, err)
+
+	proxyURL, err = proxyFromClient(c)(req)
+	assert.Equal(t, "proxy-for-everyone:8080", proxyURL.Host)
+	assert.Nil(t, err)
+}
+
+func TestProxyDisabledByEmptyGitConfig(t *testing.T) {
+	c, err := NewClient(NewContext(nil, map[string]string{
+		"HTTPS_PROXY": "https://proxy-from-env:8080",
+		"HTTP_PROXY":  "http://proxy-from-env:8080",
+	}, map[string]string{
+		"http.proxy": "",
+	}))
+	require.Nil(t, err)
+
+	for _, rawurl := range []string{"https://some-host.com/foo", "http://some-host.com/foo"} {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		require.Nil(t, err)
+
+		proxyURL, err := proxyFromClient(c)(req)
+		assert.Nil(t, proxyURL, rawurl)
+		assert.Nil(t, err)
+	}
+}
+
 func TestHttpProxyFromGitConfig(t *testing.T) {
 	c, err := NewClient(NewContext(nil, map[string]string{
 		"HTTPS_PROXY": "https://proxy-from-env:8080",
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/subprocess"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/rubyist/tracerx"
@@ -124,9 +127,15 @@ func sshFormatArgs(cmd string, args []string, needShell bool) (string, []string)
 }
 
 func sshGetLFSExeAndArgs(osEnv config.Environment, gitEnv config.Environment, e Endpoint, method string) (string, []string) {
-	exe, args, needShell := sshGetExeAndArgs(osEnv, gitEnv, e)
 	operation := endpointOperation(e, method)
-	args = append(args, fmt.Sprintf("git-lfs-authenticate %s %s", e.SshPath, operation))
+	return SSHCommand(osEnv, gitEnv, e, fmt.Sprintf("git-lfs-authenticate %s %s", e.SshPath, operation))
+}
+
+// SSHCommand returns the executable and arguments which run "command" on the
+// SSH server of the endpoint "e", as git-lfs-authenticate is run.
+func SSHCommand(osEnv config.Environment, gitEnv config.Environment, e Endpoint, command string) (string, []string) {
+	exe, args, needShell := sshGetExeAndArgs(osEnv, gitEnv, e)
+	args = append(args, command)
 	exe, args = sshFormatArgs(exe, args, needShell)
 	tracerx.Printf("run_command: %s %s", exe, strings.Join(args, " "))
 	return exe, args
@@ -193,6 +202,10 @@ func sshGetExeAndArgs(osEnv config.Environment, gitEnv config.Environment, e End
 		args = append(args, e.SshPort)
 	}
 
+	if basessh == defaultSSHCmd {
+		args = append(args, sshMultiplexArgs(osEnv, gitEnv)...)
+	}
+
 	if sep, ok := sshSeparators[basessh]; ok {
 		// inserts a separator between cli -options and host/cmd commands
 		// example: $ ssh -p 12345 -- user@host.com git-lfs-authenticate ...
@@ -210,7 +223,71 @@ func sshGetExeAndArgs(osEnv config.Environment, gitEnv config.Environment, e End
 	return cmd, args, needShell
 }
 
-const defaultSSHCmd = "ssh"
+// sshMultiplexArgs returns the options for OpenSSH to share a single
+// connection to each host between the invocations of git-lfs-authenticate, as
+// set up by lfs.ssh.automultiplex, or none if it isn't set. The first
+// invocation starts a master connection, which the rest reuse, and which is
+// kept open for lfs.ssh.controlpersist once they are done.
+func sshMultiplexArgs(osEnv, gitEnv config.Environment) []string {
+	if runtime.GOOS == "windows" || !gitEnv.Bool("lfs.ssh.automultiplex", false) {
+		return nil
+	}
+
+	controlPath, ok := gitEnv.Get("lfs.ssh.controlpath")
+	if !ok || len(controlPath) == 0 {
+		dir, err := sshControlDir(osEnv)
+		if err != nil {
+			tracerx.Printf("ssh: not multiplexing connections: %v", err)
+			return nil
+		}
+		controlPath = filepath.Join(dir, "%C")
+	}
+	controlPersist, ok := gitEnv.Get("lfs.ssh.controlpersist")
+	if !ok || len(controlPersist) == 0 {
+		controlPersist = defaultSSHControlPersist
+	}
+
+	return []string{
+		"-oControlMaster=auto",
+		"-oControlPath=" + controlPath,
+		"-oControlPersist=" + controlPersist,
+	}
+}
+
+// sshControlDir returns the directory holding the sockets of the master
+// connections made with lfs.ssh.automultiplex when lfs.ssh.controlpath isn't
+// set, creating it if need be: "git-lfs-ssh" in $XDG_RUNTIME_DIR, or in ~/.ssh
+// if that isn't set. Since anyone able to write to the directory could take
+// over the connections, an error is returned unless only the current user has
+// access to it.
+func sshControlDir(osEnv config.Environment) (string, error) {
+	base, _ := osEnv.Get("XDG_RUNTIME_DIR")
+	if len(base) == 0 {
+		home, _ := osEnv.Get("HOME")
+		if len(home) == 0 {
+			return "", errors.New("neither XDG_RUNTIME_DIR nor HOME is set")
+		}
+		base = filepath.Join(home, ".ssh")
+	}
+
+	dir := filepath.Join(base, "git-lfs-ssh")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !stat.IsDir() || stat.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s is accessible to other users", dir)
+	}
+	return dir, nil
+}
+
+const (
+	defaultSSHCmd            = "ssh"
+	defaultSSHControlPersist = "60s"
+)
 
 var (
 	sshOptPrefixRE = regexp.MustCompile(`\A\-+`)
@@ -1,8 +1,11 @@
 package lfshttp
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -286,6 +289,143 @@ func TestSSHGetExeAndArgsSshCustomPort(t *testing.T) {
 	assert.Equal(t, []string{"-p", "8888", "--", "user@foo.com"}, args)
 }
 
+func TestSSHGetExeAndArgsSshMultiplex(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lfshttp: connections are not multiplexed on Windows")
+	}
+
+	cli, err := NewClient(NewContext(nil, map[string]string{
+		"GIT_SSH_COMMAND": "",
+		"GIT_SSH":         "",
+	}, map[string]string{
+		"lfs.ssh.automultiplex": "true",
+		"lfs.ssh.controlpath":   "/tmp/lfs ssh/%C",
+	}))
+	require.Nil(t, err)
+
+	endpoint := Endpoint{Operation: "download"}
+	endpoint.SshUserAndHost = "user@foo.com"
+	endpoint.SshPort = "8888"
+
+	exe, args := sshFormatArgs(sshGetExeAndArgs(cli.OSEnv(), cli.GitEnv(), endpoint))
+	assert.Equal(t, "ssh", exe)
+	assert.Equal(t, []string{
+		"-p", "8888",
+		"-oControlMaster=auto",
+		"-oControlPath=/tmp/lfs ssh/%C",
+		"-oControlPersist=60s",
+		"--", "user@foo.com",
+	}, args)
+}
+
+func TestSSHGetExeAndArgsSshMultiplexDefaults(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lfshttp: connections are not multiplexed on Windows")
+	}
+
+	runtimeDir, err := ioutil.TempDir("", "lfshttp-ssh")
+	require.Nil(t, err)
+	defer os.RemoveAll(runtimeDir)
+
+	cli, err := NewClient(NewContext(nil, map[string]string{
+		"GIT_SSH_COMMAND": "",
+		"GIT_SSH":         "",
+		"XDG_RUNTIME_DIR": runtimeDir,
+	}, map[string]string{
+		"lfs.ssh.automultiplex":  "true",
+		"lfs.ssh.controlpersist": "10m",
+	}))
+	require.Nil(t, err)
+
+	endpoint := Endpoint{Operation: "download"}
+	endpoint.SshUserAndHost = "user@foo.com"
+
+	exe, args := sshFormatArgs(sshGetExeAndArgs(cli.OSEnv(), cli.GitEnv(), endpoint))
+	assert.Equal(t, "ssh", exe)
+	assert.Equal(t, []string{
+		"-oControlMaster=auto",
+		"-oControlPath=" + filepath.Join(runtimeDir, "git-lfs-ssh", "%C"),
+		"-oControlPersist=10m",
+		"--", "user@foo.com",
+	}, args)
+
+	stat, err := os.Stat(filepath.Join(runtimeDir, "git-lfs-ssh"))
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0700), stat.Mode().Perm())
+}
+
+func TestSSHGetExeAndArgsSshMultiplexSharedControlDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lfshttp: connections are not multiplexed on Windows")
+	}
+
+	runtimeDir, err := ioutil.TempDir("", "lfshttp-ssh")
+	require.Nil(t, err)
+	defer os.RemoveAll(runtimeDir)
+
+	controlDir := filepath.Join(runtimeDir, "git-lfs-ssh")
+	require.Nil(t, os.Mkdir(controlDir, 0700))
+	require.Nil(t, os.Chmod(controlDir, 0777))
+
+	cli, err := NewClient(NewContext(nil, map[string]string{
+		"GIT_SSH_COMMAND": "",
+		"GIT_SSH":         "",
+		"XDG_RUNTIME_DIR": runtimeDir,
+	}, map[string]string{
+		"lfs.ssh.automultiplex": "true",
+	}))
+	require.Nil(t, err)
+
+	endpoint := Endpoint{Operation: "download"}
+	endpoint.SshUserAndHost = "user@foo.com"
+
+	exe, args := sshFormatArgs(sshGetExeAndArgs(cli.OSEnv(), cli.GitEnv(), endpoint))
+	assert.Equal(t, "ssh", exe)
+	assert.Equal(t, []string{"--", "user@foo.com"}, args)
+}
+
+func TestSSHGetExeAndArgsSshCommandMultiplex(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lfshttp: connections are not multiplexed on Windows")
+	}
+
+	cli, err := NewClient(NewContext(nil, map[string]string{
+		"GIT_SSH_COMMAND": "",
+		"GIT_SSH":         "",
+	}, map[string]string{
+		"core.sshcommand":       "ssh -i 'my key'",
+		"lfs.ssh.automultiplex": "true",
+		"lfs.ssh.controlpath":   "/tmp/lfs ssh/%C",
+	}))
+	require.Nil(t, err)
+
+	endpoint := Endpoint{Operation: "download"}
+	endpoint.SshUserAndHost = "user@foo.com"
+
+	exe, args := sshFormatArgs(sshGetExeAndArgs(cli.OSEnv(), cli.GitEnv(), endpoint))
+	assert.Equal(t, "sh", exe)
+	assert.Equal(t, []string{"-c", "ssh -i 'my key' '-oControlMaster=auto' '-oControlPath=/tmp/lfs ssh/%C' '-oControlPersist=60s' -- user@foo.com"}, args)
+}
+
+func TestSSHGetExeAndArgsPlinkMultiplex(t *testing.T) {
+	plink := filepath.Join("Users", "joebloggs", "bin", "plink.exe")
+
+	cli, err := NewClient(NewContext(nil, map[string]string{
+		"GIT_SSH_COMMAND": "",
+		"GIT_SSH":         plink,
+	}, map[string]string{
+		"lfs.ssh.automultiplex": "true",
+	}))
+	require.Nil(t, err)
+
+	endpoint := Endpoint{Operation: "download"}
+	endpoint.SshUserAndHost = "user@foo.com"
+
+	exe, args := sshFormatArgs(sshGetExeAndArgs(cli.OSEnv(), cli.GitEnv(), endpoint))
+	assert.Equal(t, plink, exe)
+	assert.Equal(t, []string{"user@foo.com"}, args)
+}
+
 func TestSSHGetExeAndArgsPlink(t *testing.T) {
 	plink := filepath.Join("Users", "joebloggs", "bin", "plink.exe")
 
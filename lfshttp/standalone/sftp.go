@@ -0,0 +1,236 @@
+package standalone
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/git-lfs/git-lfs/subprocess"
+	"github.com/git-lfs/git-lfs/tools"
+	"github.com/rubyist/tracerx"
+)
+
+// sftpHandler transfers objects to and from a directory on an SFTP server by
+// running the sftp(1) program of OpenSSH, which authenticates as configured
+// for ssh(1). Objects are stored in the directory like in the objects
+// directory of a repository, at "<oid[0:2]>/<oid[2:4]>/<oid>", under the
+// directory named by lfs.object.prefix, if it is set.
+type sftpHandler struct {
+	// program is the sftp program to run.
+	program string
+	// target is the host to connect to, with the user name, if any.
+	target string
+	// port is the port to connect to, or empty for the default.
+	port string
+	// root is the directory the objects are stored in on the server.
+	root string
+	// prefix is the namespace of the objects within root.
+	prefix string
+
+	output  *os.File
+	tempdir string
+}
+
+// newSFTPHandler creates a new handler for the protocol which transfers objects
+// over SFTP.
+func newSFTPHandler(cfg *config.Configuration, output *os.File, msg *inputMessage) (handler, error) {
+	u, err := urlFromRemote(cfg, msg.Remote, msg.Operation, "sftp")
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errors.New("no valid sftp:// URLs found")
+	}
+	if len(u.Hostname()) == 0 {
+		return nil, errors.Errorf("no host given in %q", u.String())
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return nil, errors.New("passwords are not supported in sftp:// URLs, use a key instead")
+		}
+	}
+
+	tempdir, err := ioutil.TempDir(cfg.TempDir(), "lfs-standalone-sftp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	program, ok := cfg.Git.Get("lfs.sftp.program")
+	if !ok || len(program) == 0 {
+		program = "sftp"
+	}
+
+	target := u.Hostname()
+	if u.User != nil && len(u.User.Username()) > 0 {
+		target = u.User.Username() + "@" + target
+	}
+
+	root := u.Path
+	if len(root) == 0 {
+		root = "."
+	}
+
+	tracerx.Printf("sftp: using %q on %s as remote object directory", root, target)
+
+	return &sftpHandler{
+		program: program,
+		target:  target,
+		port:    u.Port(),
+		root:    root,
+		prefix:  cfg.ObjectPrefix(),
+		output:  output,
+		tempdir: tempdir,
+	}, nil
+}
+
+// dispatch dispatches the event depending on the message type.
+func (h *sftpHandler) dispatch(msg *inputMessage) bool {
+	switch msg.Event {
+	case "init":
+		fmt.Fprintln(h.output, "{}")
+	case "upload":
+		respond(h.output, msg.Oid, "", h.upload(msg.Oid, msg.Path))
+	case "download":
+		path, err := h.download(msg.Oid, msg.Size)
+		respond(h.output, msg.Oid, path, err)
+	case "terminate":
+		return false
+	default:
+		standaloneFailure(fmt.Sprintf("unknown event %q", msg.Event), nil)
+	}
+	return true
+}
+
+// cleanup removes the temporary directory of downloaded objects.
+func (h *sftpHandler) cleanup() {
+	os.RemoveAll(h.tempdir)
+}
+
+// objectPath returns the path of the object "oid" on the server.
+func (h *sftpHandler) objectPath(oid string) string {
+	return path.Join(h.root, fs.ObjectKey(h.prefix, oid))
+}
+
+// upload uploads the object "oid" from the file at "localPath", unless the
+// server already has it. The object is written to a temporary file first,
+// and renamed once complete, so that partial uploads are never taken to be
+// the object.
+func (h *sftpHandler) upload(oid, localPath string) error {
+	if len(oid) < 5 {
+		return errors.Errorf("invalid object ID %q", oid)
+	}
+
+	dest := h.objectPath(oid)
+	if err := h.run("ls " + sftpQuote(dest)); err == nil {
+		tracerx.Printf("sftp: %s already exists on the server", oid)
+		return nil
+	}
+
+	// Missing directories are created, and errors from those which exist
+	// already ignored.
+	var commands []string
+	root := path.Clean(h.root)
+	for dir := path.Dir(dest); dir != root && dir != "." && dir != "/"; dir = path.Dir(dir) {
+		commands = append([]string{"-mkdir " + sftpQuote(dir)}, commands...)
+	}
+	commands = append([]string{"-mkdir " + sftpQuote(h.root)}, commands...)
+
+	tmp := fmt.Sprintf("%s.tmp-%d", dest, os.Getpid())
+	return h.run(append(commands,
+		"put "+sftpQuote(localPath)+" "+sftpQuote(tmp),
+		"rename "+sftpQuote(tmp)+" "+sftpQuote(dest),
+	)...)
+}
+
+// download downloads the object "oid" of "size" bytes to a temporary file, and
+// returns its path once it has been verified to be the object.
+func (h *sftpHandler) download(oid string, size int64) (string, error) {
+	if len(oid) < 5 {
+		return "", errors.Errorf("invalid object ID %q", oid)
+	}
+
+	tmp, err := ioutil.TempFile(h.tempdir, "download")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+
+	if err := h.run("get " + sftpQuote(h.objectPath(oid)) + " " + sftpQuote(tmp.Name())); err != nil {
+		os.Remove(tmp.Name())
+		return "", errors.Wrapf(err, "remote missing object %s", oid)
+	}
+
+	if err := verifyObject(tmp.Name(), oid, size); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// verifyObject returns an error unless the file at "path" is "size" bytes
+// long and has the OID "oid".
+func verifyObject(path, oid string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := tools.NewLfsContentHash()
+	n, err := io.Copy(hasher, f)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return errors.Errorf("expected object %s to be %d byte(s), received %d", oid, size, n)
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != oid {
+		return errors.Errorf("expected OID %s, got %s", oid, actual)
+	}
+	return nil
+}
+
+// run runs the sftp program with "commands" as its batch file, returning an
+// error with what it printed if any command fails. Commands prefixed with "-"
+// don't cause it to fail.
+func (h *sftpHandler) run(commands ...string) error {
+	args := []string{"-q", "-b", "-", "-o", "BatchMode=yes"}
+	if len(h.port) > 0 {
+		args = append(args, "-P", h.port)
+	}
+	args = append(args, h.target)
+
+	tracerx.Printf("sftp: %s", strings.Join(commands, "; "))
+
+	var stderr bytes.Buffer
+	cmd := subprocess.ExecCommand(h.program, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return errors.Errorf("%s: %s", h.program, msg)
+		}
+		return errors.Wrap(err, h.program)
+	}
+	return nil
+}
+
+// sftpQuote quotes "s" as a single argument to a command in an sftp batch file.
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ProcessStandaloneSFTPData is the endpoint for processing data with the
+// standalone transfer agent for SFTP URLs, like ProcessStandaloneData.
+func ProcessStandaloneSFTPData(cfg *config.Configuration, input *os.File, output *os.File) error {
+	return processStandaloneData(cfg, input, output, newSFTPHandler)
+}
@@ -54,12 +54,28 @@ type fileHandler struct {
 	tempdir      string
 }
 
+// handler handles the messages sent to a standalone transfer agent.
+type handler interface {
+	// dispatch handles "msg", returning false when no more messages
+	// should be read.
+	dispatch(msg *inputMessage) bool
+	// cleanup removes anything the handler left behind.
+	cleanup()
+}
+
 // fileUrlFromRemote looks up the URL depending on the remote. The remote can be
 // a literal URL or the name of a remote.
 //
 // In this situation, we only accept file URLs.
 func fileUrlFromRemote(cfg *config.Configuration, name string, direction string) (*url.URL, error) {
-	if strings.HasPrefix(name, "file://") {
+	return urlFromRemote(cfg, name, direction, "file")
+}
+
+// urlFromRemote looks up the URL depending on the remote, like
+// fileUrlFromRemote, but accepts only URLs with the given scheme.
+func urlFromRemote(cfg *config.Configuration, name, direction, scheme string) (*url.URL, error) {
+	prefix := scheme + "://"
+	if strings.HasPrefix(name, prefix) {
 		if url, err := url.Parse(name); err == nil {
 			return url, nil
 		}
@@ -75,7 +91,7 @@ func fileUrlFromRemote(cfg *config.Configuration, name string, direction string)
 			continue
 		}
 		remoteEndpoint := apiClient.Endpoints.Endpoint(direction, remote)
-		if !strings.HasPrefix(remoteEndpoint.Url, "file://") {
+		if !strings.HasPrefix(remoteEndpoint.Url, prefix) {
 			return nil, nil
 		}
 		return url.Parse(remoteEndpoint.Url)
@@ -159,7 +175,7 @@ func fixUrlPath(path string) string {
 }
 
 // newHandler creates a new handler for the protocol.
-func newHandler(cfg *config.Configuration, output *os.File, msg *inputMessage) (*fileHandler, error) {
+func newHandler(cfg *config.Configuration, output *os.File, msg *inputMessage) (handler, error) {
 	url, err := fileUrlFromRemote(cfg, msg.Remote, msg.Operation)
 	if err != nil {
 		return nil, err
@@ -185,9 +201,15 @@ func newHandler(cfg *config.Configuration, output *os.File, msg *inputMessage) (
 
 	tracerx.Printf("using %q as remote git directory", gitdir)
 
+	// The objects are stored under the namespace of the local repository,
+	// not any the remote one is configured with, so that they are found
+	// where they were uploaded from another clone.
+	remoteConfig := config.NewIn(gitdir, gitdir)
+	remoteConfig.Filesystem().ObjectPrefix = cfg.ObjectPrefix()
+
 	return &fileHandler{
 		remotePath:   path,
-		remoteConfig: config.NewIn(gitdir, gitdir),
+		remoteConfig: remoteConfig,
 		output:       output,
 		config:       cfg,
 		tempdir:      tempdir,
@@ -214,6 +236,11 @@ func (h *fileHandler) dispatch(msg *inputMessage) bool {
 // respond sends a response to an upload or download command, using the return
 // values from those functions.
 func (h *fileHandler) respond(oid string, path string, err error) {
+	respond(h.output, oid, path, err)
+}
+
+// respond writes a completion response for the transfer of "oid" to "output".
+func respond(output *os.File, oid string, path string, err error) {
 	response := &completeMessage{
 		Event: "complete",
 		Oid:   oid,
@@ -222,7 +249,7 @@ func (h *fileHandler) respond(oid string, path string, err error) {
 	if err != nil {
 		response.Error = &errorMessage{Message: err.Error()}
 	}
-	json.NewEncoder(h.output).Encode(response)
+	json.NewEncoder(output).Encode(response)
 }
 
 // upload performs the upload action for the given OID, size, and path. It
@@ -247,10 +274,7 @@ func (h *fileHandler) download(oid string, size int64) (string, string, error) {
 		return oid, "", errors.Errorf("remote missing object %s", oid)
 	}
 
-	src, err := h.remoteConfig.Filesystem().ObjectPath(oid)
-	if err != nil {
-		return oid, "", err
-	}
+	src := h.remoteConfig.Filesystem().ObjectReadPathname(oid, size)
 
 	tmp, err := ioutil.TempFile(h.tempdir, "download")
 	if err != nil {
@@ -262,6 +286,11 @@ func (h *fileHandler) download(oid string, size int64) (string, string, error) {
 	return oid, path, lfs.LinkOrCopy(h.config, src, path)
 }
 
+// cleanup removes the temporary directory of downloaded objects.
+func (h *fileHandler) cleanup() {
+	os.RemoveAll(h.tempdir)
+}
+
 // standaloneFailure reports a fatal error.
 func standaloneFailure(msg string, err error) {
 	fmt.Fprintf(os.Stderr, "%s: %s\n", msg, err)
@@ -272,7 +301,14 @@ func standaloneFailure(msg string, err error) {
 // standalone transfer agent. It reads input from the specified input file and
 // produces output to the specified output file.
 func ProcessStandaloneData(cfg *config.Configuration, input *os.File, output *os.File) error {
-	var handler *fileHandler
+	return processStandaloneData(cfg, input, output, newHandler)
+}
+
+// processStandaloneData reads input from "input" and produces output to
+// "output", handling the messages with the handler created by "newHandler"
+// for the first message.
+func processStandaloneData(cfg *config.Configuration, input *os.File, output *os.File, newHandler func(*config.Configuration, *os.File, *inputMessage) (handler, error)) error {
+	var h handler
 
 	scanner := bufio.NewScanner(input)
 	for scanner.Scan() {
@@ -280,19 +316,19 @@ func ProcessStandaloneData(cfg *config.Configuration, input *os.File, output *os
 		if err := json.NewDecoder(strings.NewReader(scanner.Text())).Decode(&msg); err != nil {
 			return errors.Wrapf(err, "error decoding json")
 		}
-		if handler == nil {
+		if h == nil {
 			var err error
-			handler, err = newHandler(cfg, output, &msg)
+			h, err = newHandler(cfg, output, &msg)
 			if err != nil {
 				return errors.Wrapf(err, "error creating handler")
 			}
 		}
-		if !handler.dispatch(&msg) {
+		if !h.dispatch(&msg) {
 			break
 		}
 	}
-	if handler != nil {
-		os.RemoveAll(handler.tempdir)
+	if h != nil {
+		h.cleanup()
 	}
 	if err := scanner.Err(); err != nil {
 		return errors.Wrapf(err, "error reading input")
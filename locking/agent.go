@@ -0,0 +1,396 @@
+package locking
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+var lockingAgentRegex = regexp.MustCompile(`^lfs\.customtransfer\.([^.]+)\.locking$`)
+
+// findLockingAgent returns the name of the custom transfer agent configured to
+// handle locks with "lfs.customtransfer.<name>.locking", or the empty string if
+// there is none. If several are, the first by name is used.
+func findLockingAgent(git config.Environment) string {
+	var names []string
+	for key := range git.All() {
+		match := lockingAgentRegex.FindStringSubmatch(key)
+		if match == nil || !git.Bool(key, false) {
+			continue
+		}
+		if path, _ := git.Get(fmt.Sprintf("lfs.customtransfer.%s.path", match[1])); len(path) == 0 {
+			continue
+		}
+		names = append(names, match[1])
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	if len(names) > 1 {
+		tracerx.Printf("locking: several custom transfer agents handle locks, using %q", names[0])
+	}
+	return names[0]
+}
+
+// HasLockingAgent returns whether a custom transfer agent is configured to
+// handle locks, instead of the LFS API.
+func HasLockingAgent(git config.Environment) bool {
+	return len(findLockingAgent(git)) > 0
+}
+
+// agentLockClient makes lock requests through a custom transfer agent, rather
+// than the LFS API. The agent is started when it is first needed, and if it
+// doesn't advertise that it handles locks in its response to the "init"
+// message, every request is made through "fallback" instead.
+type agentLockClient struct {
+	name     string
+	path     string
+	args     string
+	fallback lockBackend
+
+	mu      sync.Mutex
+	started bool
+	agent   *lockAgent
+}
+
+func newAgentLockClient(git config.Environment, fallback lockBackend) *agentLockClient {
+	name := findLockingAgent(git)
+	if len(name) == 0 {
+		return nil
+	}
+
+	path, _ := git.Get(fmt.Sprintf("lfs.customtransfer.%s.path", name))
+	args, _ := git.Get(fmt.Sprintf("lfs.customtransfer.%s.args", name))
+	return &agentLockClient{name: name, path: path, args: args, fallback: fallback}
+}
+
+// lockAgent is a running custom transfer agent which handles locks.
+type lockAgent struct {
+	cmd    *subprocess.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+type lockAgentInitRequest struct {
+	Event               string `json:"event"`
+	Operation           string `json:"operation"`
+	Remote              string `json:"remote"`
+	Concurrent          bool   `json:"concurrent"`
+	ConcurrentTransfers int    `json:"concurrenttransfers"`
+}
+
+// lockAgentRequest is any of the "lock", "unlock", "list" and "terminate"
+// messages sent to the agent.
+type lockAgentRequest struct {
+	Event  string `json:"event"`
+	Path   string `json:"path,omitempty"`
+	Id     string `json:"id,omitempty"`
+	Force  bool   `json:"force,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Verify bool   `json:"verify,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
+// lockAgentResponse is any of the messages sent by the agent in response to
+// a lockAgentInitRequest or lockAgentRequest.
+type lockAgentResponse struct {
+	Event      string          `json:"event"`
+	Locking    bool            `json:"locking"`
+	Error      *lockAgentError `json:"error"`
+	Lock       *Lock           `json:"lock"`
+	Locks      []Lock          `json:"locks"`
+	Ours       []Lock          `json:"ours"`
+	Theirs     []Lock          `json:"theirs"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+// lockAgentError is an error returned by the agent for a single request. Its
+// code has the meaning of the equivalent HTTP status code of the LFS API.
+type lockAgentError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lockAgentError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// start returns the running agent, starting it first if need be, or nil if
+// the agent doesn't handle locks. The caller must hold c.mu.
+func (c *agentLockClient) start(remote string) (*lockAgent, error) {
+	if c.started {
+		return c.agent, nil
+	}
+	c.started = true
+
+	tracerx.Printf("locking: starting custom transfer agent %q for locks", c.name)
+	cmdName, cmdArgs := subprocess.FormatForShell(subprocess.ShellQuoteSingle(c.path), c.args)
+	cmd := subprocess.ExecCommand(cmdName, cmdArgs...)
+	outp, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdout for custom transfer command %q: %v", c.path, err)
+	}
+	inp, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdin for custom transfer command %q: %v", c.path, err)
+	}
+	cmd.Stderr = &lockAgentTracer{name: c.name}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start custom transfer command %q: %v", c.path, err)
+	}
+
+	agent := &lockAgent{cmd: cmd, stdin: inp, stdout: bufio.NewReader(outp)}
+	resp, err := agent.exchange(&lockAgentInitRequest{
+		Event:               "init",
+		Operation:           "lock",
+		Remote:              remote,
+		ConcurrentTransfers: 1,
+	}, "")
+	if err == nil && resp.Error != nil {
+		err = fmt.Errorf("error initializing custom transfer agent %q for locks: %v", c.name, resp.Error)
+	}
+	if err != nil {
+		agent.terminate()
+		return nil, err
+	}
+
+	if !resp.Locking {
+		tracerx.Printf("locking: custom transfer agent %q does not handle locks, using the LFS API", c.name)
+		agent.terminate()
+		return nil, nil
+	}
+
+	c.agent = agent
+	return agent, nil
+}
+
+// exchange sends the message "req" to the agent and returns its response,
+// which must be for the event "event", unless it is empty.
+func (a *lockAgent) exchange(req interface{}, event string) (*lockAgentResponse, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	tracerx.Printf("locking: sending message to custom transfer agent: %s", b)
+	if _, err := a.stdin.Write(append(b, '\n')); err != nil {
+		return nil, errors.Wrap(err, "custom transfer agent")
+	}
+
+	line, err := a.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "custom transfer agent")
+	}
+	tracerx.Printf("locking: received response from custom transfer agent: %s", line)
+
+	resp := &lockAgentResponse{}
+	if err := json.Unmarshal(line, resp); err != nil {
+		return nil, errors.Wrap(err, "custom transfer agent")
+	}
+	if len(event) > 0 && resp.Event != event {
+		return nil, fmt.Errorf("invalid message %q from custom transfer agent, expecting %q", resp.Event, event)
+	}
+	return resp, nil
+}
+
+// lockAgentTracer writes each line the agent writes to stderr to the trace.
+type lockAgentTracer struct {
+	name string
+	buf  bytes.Buffer
+}
+
+func (t *lockAgentTracer) Write(b []byte) (int, error) {
+	n, err := t.buf.Write(b)
+	for {
+		i := bytes.IndexByte(t.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		tracerx.Printf("locking[%s]: %s", t.name, t.buf.Next(i + 1)[:i])
+	}
+	return n, err
+}
+
+// terminate asks the agent to exit, and waits for it to do so.
+func (a *lockAgent) terminate() error {
+	b, _ := json.Marshal(&lockAgentRequest{Event: "terminate"})
+	a.stdin.Write(append(b, '\n'))
+	a.stdin.Close()
+	return a.cmd.Wait()
+}
+
+// request sends "req" to the agent, or returns a nil response if the agent
+// doesn't handle locks.
+func (c *agentLockClient) request(remote string, req *lockAgentRequest) (*lockAgentResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, err := c.start(remote)
+	if agent == nil || err != nil {
+		return nil, err
+	}
+	return agent.exchange(req, req.Event)
+}
+
+func (c *agentLockClient) Lock(remote string, lockReq *lockRequest) (*lockResponse, *http.Response, error) {
+	req := &lockAgentRequest{Event: "lock", Path: lockReq.Path, Message: lockReq.Message}
+	if lockReq.Ref != nil {
+		req.Ref = lockReq.Ref.Name
+	}
+
+	resp, err := c.request(remote, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return c.fallback.Lock(remote, lockReq)
+	}
+
+	lockRes := &lockResponse{Lock: resp.Lock}
+	if resp.Error != nil {
+		lockRes.Lock = nil
+		lockRes.Message = resp.Error.Message
+	}
+	if lockRes.Lock == nil && len(lockRes.Message) == 0 {
+		return nil, nil, fmt.Errorf("invalid custom transfer agent response")
+	}
+	return lockRes, nil, nil
+}
+
+func (c *agentLockClient) Unlock(ref *git.Ref, remote, id string, force bool) (*unlockResponse, *http.Response, error) {
+	resp, err := c.request(remote, &lockAgentRequest{
+		Event: "unlock",
+		Id:    id,
+		Force: force,
+		Ref:   ref.Refspec(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return c.fallback.Unlock(ref, remote, id, force)
+	}
+
+	unlockRes := &unlockResponse{Lock: resp.Lock}
+	if resp.Error != nil {
+		unlockRes.Lock = nil
+		unlockRes.Message = resp.Error.Message
+	}
+	if unlockRes.Lock == nil && len(unlockRes.Message) == 0 {
+		return nil, nil, fmt.Errorf("invalid custom transfer agent response")
+	}
+	return unlockRes, nil, nil
+}
+
+// Transfer fails with a "not implemented" error if the agent handles locks,
+// since there is no message to hand a lock over to another user.
+func (c *agentLockClient) Transfer(ref *git.Ref, remote, id, owner string) (*transferResponse, *http.Response, error) {
+	c.mu.Lock()
+	agent, err := c.start(remote)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	if agent == nil {
+		return c.fallback.Transfer(ref, remote, id, owner)
+	}
+	return nil, nil, errors.NewNotImplementedError(fmt.Errorf("custom transfer agent %q can't transfer locks", c.name))
+}
+
+func (c *agentLockClient) Search(remote string, searchReq *lockSearchRequest) (*lockList, *http.Response, error) {
+	req := &lockAgentRequest{
+		Event:  "list",
+		Cursor: searchReq.Cursor,
+		Limit:  searchReq.Limit,
+		Ref:    searchReq.Refspec,
+	}
+	for _, filter := range searchReq.Filters {
+		switch filter.Property {
+		case "path":
+			req.Path = filter.Value
+		case "id":
+			req.Id = filter.Value
+		default:
+			return nil, nil, fmt.Errorf("custom transfer agent can't search locks by %q", filter.Property)
+		}
+	}
+
+	resp, err := c.request(remote, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return c.fallback.Search(remote, searchReq)
+	}
+
+	list := &lockList{Locks: resp.Locks, NextCursor: resp.NextCursor}
+	if resp.Error != nil {
+		list.Message = resp.Error.Message
+	}
+	return list, nil, nil
+}
+
+func (c *agentLockClient) SearchVerifiable(remote string, vreq *lockVerifiableRequest) (*lockVerifiableList, *http.Response, error) {
+	req := &lockAgentRequest{
+		Event:  "list",
+		Verify: true,
+		Cursor: vreq.Cursor,
+		Limit:  vreq.Limit,
+	}
+	if vreq.Ref != nil {
+		req.Ref = vreq.Ref.Name
+	}
+
+	resp, err := c.request(remote, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return c.fallback.SearchVerifiable(remote, vreq)
+	}
+
+	if resp.Error != nil {
+		switch resp.Error.Code {
+		case http.StatusNotFound, http.StatusNotImplemented:
+			return nil, nil, errors.NewNotImplementedError(resp.Error)
+		case http.StatusForbidden:
+			return nil, nil, errors.NewAuthError(resp.Error)
+		}
+		return &lockVerifiableList{Message: resp.Error.Message}, nil, nil
+	}
+	return &lockVerifiableList{
+		Ours:       resp.Ours,
+		Theirs:     resp.Theirs,
+		NextCursor: resp.NextCursor,
+	}, nil, nil
+}
+
+// Close terminates the agent, if it was started.
+func (c *agentLockClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.agent == nil {
+		return nil
+	}
+	tracerx.Printf("locking: shutting down custom transfer agent %q", c.name)
+	err := c.agent.terminate()
+	c.agent = nil
+	return err
+}
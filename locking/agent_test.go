@@ -0,0 +1,33 @@
+package locking
+
+import (
+	"testing"
+
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindLockingAgent(t *testing.T) {
+	git := lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.customtransfer.zeta.path":      "zeta-agent",
+		"lfs.customtransfer.zeta.locking":   "true",
+		"lfs.customtransfer.alpha.path":     "alpha-agent",
+		"lfs.customtransfer.alpha.locking":  "true",
+		"lfs.customtransfer.nopath.locking": "true",
+		"lfs.customtransfer.off.path":       "off-agent",
+		"lfs.customtransfer.off.locking":    "false",
+	}).GitEnv()
+
+	assert.Equal(t, "alpha", findLockingAgent(git))
+	assert.True(t, HasLockingAgent(git))
+}
+
+func TestFindLockingAgentNone(t *testing.T) {
+	git := lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.customtransfer.testcustom.path": "agent",
+	}).GitEnv()
+
+	assert.Equal(t, "", findLockingAgent(git))
+	assert.False(t, HasLockingAgent(git))
+	assert.Nil(t, newAgentLockClient(git, nil))
+}
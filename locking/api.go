@@ -10,6 +10,17 @@ import (
 	"github.com/git-lfs/git-lfs/lfshttp"
 )
 
+// lockBackend makes the requests of the Client, either through the LFS API,
+// through git-lfs-transfer(1) on an SSH server, or through a custom transfer
+// agent. The HTTP response is nil if none was made.
+type lockBackend interface {
+	Lock(remote string, lockReq *lockRequest) (*lockResponse, *http.Response, error)
+	Unlock(ref *git.Ref, remote, id string, force bool) (*unlockResponse, *http.Response, error)
+	Transfer(ref *git.Ref, remote, id, owner string) (*transferResponse, *http.Response, error)
+	Search(remote string, searchReq *lockSearchRequest) (*lockList, *http.Response, error)
+	SearchVerifiable(remote string, vreq *lockVerifiableRequest) (*lockVerifiableList, *http.Response, error)
+}
+
 type lockClient struct {
 	*lfsapi.Client
 }
@@ -24,6 +35,9 @@ type lockRequest struct {
 	// Path is the path that the client would like to obtain a lock against.
 	Path string   `json:"path"`
 	Ref  *lockRef `json:"ref,omitempty"`
+	// Message is the optional reason for the lock, to be stored with it by
+	// servers which support it.
+	Message string `json:"message,omitempty"`
 }
 
 // LockResponse encapsulates the information sent over the API in response to
@@ -126,6 +140,57 @@ func (c *lockClient) Unlock(ref *git.Ref, remote, id string, force bool) (*unloc
 	return unlockRes, res, nil
 }
 
+// transferRequest encapsulates the data sent in an API request to hand a lock
+// over to another user, which not every server supports.
+type transferRequest struct {
+	// Owner is the user who is to hold the lock.
+	Owner *User    `json:"owner"`
+	Ref   *lockRef `json:"ref,omitempty"`
+}
+
+// transferResponse is the result sent back from the API when asked to hand a
+// lock over to another user.
+type transferResponse struct {
+	// Lock is the lock as it is once transferred, held by the new owner.
+	// If the lock could not be transferred, this field will take the
+	// zero-value of Lock, and Message will be set.
+	Lock *Lock `json:"lock"`
+
+	// Message is an optional field which holds any error that was experienced
+	// while transferring the lock.
+	Message          string `json:"message,omitempty"`
+	DocumentationURL string `json:"documentation_url,omitempty"`
+	RequestID        string `json:"request_id,omitempty"`
+}
+
+func (c *lockClient) Transfer(ref *git.Ref, remote, id, owner string) (*transferResponse, *http.Response, error) {
+	e := c.Endpoints.Endpoint("upload", remote)
+	suffix := fmt.Sprintf("locks/%s/transfer", id)
+	req, err := c.NewRequest("POST", e, suffix, &transferRequest{
+		Owner: NewUser(owner),
+		Ref:   &lockRef{Name: ref.Refspec()},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req = c.Client.LogRequest(req, "lfs.locks.transfer")
+	res, err := c.DoAPIRequestWithAuth(remote, req)
+	if err != nil {
+		return nil, res, err
+	}
+
+	transferRes := &transferResponse{}
+	err = lfshttp.DecodeJSON(res, transferRes)
+	if err != nil {
+		return nil, res, err
+	}
+	if transferRes.Lock == nil && len(transferRes.Message) == 0 {
+		return nil, res, fmt.Errorf("invalid server response")
+	}
+	return transferRes, res, nil
+}
+
 // Filter represents a single qualifier to apply against a set of locks.
 type lockFilter struct {
 	// Property is the property to search against.
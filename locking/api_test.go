@@ -120,6 +120,60 @@ func TestAPIUnlock(t *testing.T) {
 	assert.Equal(t, "response", unlockRes.Lock.Path)
 }
 
+func TestAPITransfer(t *testing.T) {
+	require.NotNil(t, transferReqSchema)
+	require.NotNil(t, createResSchema)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/locks/123/transfer" {
+			w.WriteHeader(404)
+			return
+		}
+
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, lfshttp.MediaType, r.Header.Get("Accept"))
+		assert.Equal(t, lfshttp.MediaType, r.Header.Get("Content-Type"))
+
+		reqLoader, body := gojsonschema.NewReaderLoader(r.Body)
+		transferReq := &transferRequest{}
+		err := json.NewDecoder(body).Decode(transferReq)
+		r.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, "Jane Doe", transferReq.Owner.Name)
+		assert.Equal(t, "refs/heads/master", transferReq.Ref.Name)
+		assertSchema(t, transferReqSchema, reqLoader)
+
+		w.Header().Set("Content-Type", "application/json")
+		resLoader, resWriter := gojsonschema.NewWriterLoader(w)
+		err = json.NewEncoder(resWriter).Encode(&transferResponse{
+			Lock: &Lock{
+				Id:    "123",
+				Path:  "response",
+				Owner: NewUser("Jane Doe"),
+			},
+		})
+		assert.Nil(t, err)
+		assertSchema(t, createResSchema, resLoader)
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	lc := &lockClient{Client: c}
+	transferRes, res, err := lc.Transfer(&git.Ref{
+		Name: "master",
+		Sha:  "6161616161616161616161616161616161616161",
+		Type: git.RefTypeLocalBranch,
+	}, "", "123", "Jane Doe")
+	require.Nil(t, err)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "123", transferRes.Lock.Id)
+	assert.Equal(t, "Jane Doe", transferRes.Lock.Owner.Name)
+}
+
 func TestAPISearch(t *testing.T) {
 	require.NotNil(t, listResSchema)
 
@@ -228,9 +282,10 @@ func TestAPISearchVerifiable(t *testing.T) {
 var (
 	createReqSchema *sourcedSchema
 	createResSchema *sourcedSchema
-	delReqSchema    *sourcedSchema
-	listResSchema   *sourcedSchema
-	verifyResSchema *sourcedSchema
+	delReqSchema      *sourcedSchema
+	transferReqSchema *sourcedSchema
+	listResSchema     *sourcedSchema
+	verifyResSchema   *sourcedSchema
 )
 
 func init() {
@@ -243,6 +298,7 @@ func init() {
 	createReqSchema = getSchema(wd, "schemas/http-lock-create-request-schema.json")
 	createResSchema = getSchema(wd, "schemas/http-lock-create-response-schema.json")
 	delReqSchema = getSchema(wd, "schemas/http-lock-delete-request-schema.json")
+	transferReqSchema = getSchema(wd, "schemas/http-lock-transfer-request-schema.json")
 	listResSchema = getSchema(wd, "schemas/http-lock-list-response-schema.json")
 	verifyResSchema = getSchema(wd, "schemas/http-lock-verify-response-schema.json")
 }
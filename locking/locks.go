@@ -48,7 +48,7 @@ type LockCacher interface {
 type Client struct {
 	Remote    string
 	RemoteRef *git.Ref
-	client    *lockClient
+	client    lockBackend
 	cache     LockCacher
 	cacheDir  string
 	cfg       *config.Configuration
@@ -75,9 +75,15 @@ func NewClient(remote string, lfsClient *lfsapi.Client, cfg *config.Configuratio
 		return nil, err
 	}
 
+	var client lockBackend = &lockClient{Client: lfsClient}
+	client = &sshLockClient{Client: lfsClient, fallback: client}
+	if agent := newAgentLockClient(lfsClient.GitEnv(), client); agent != nil {
+		client = agent
+	}
+
 	return &Client{
 		Remote:             remote,
-		client:             &lockClient{Client: lfsClient},
+		client:             client,
 		cache:              &nilLockCacher{},
 		cfg:                cfg,
 		gitRoot:            root,
@@ -109,16 +115,25 @@ func (c *Client) SetupFileCache(path string) error {
 
 // Close this client instance; must be called to dispose of resources
 func (c *Client) Close() error {
-	return c.cache.Save()
+	err := c.cache.Save()
+	if closer, ok := c.client.(io.Closer); ok {
+		if cerr := closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
 // LockFile attempts to lock a file on the current remote
 // path must be relative to the root of the repository
+// message is the optional reason for the lock, which is only kept by servers
+// supporting it, in which case it is set on the lock returned
 // Returns the lock id if successful, or an error
-func (c *Client) LockFile(path string) (Lock, error) {
+func (c *Client) LockFile(path, message string) (Lock, error) {
 	lockRes, _, err := c.client.Lock(c.Remote, &lockRequest{
-		Path: path,
-		Ref:  &lockRef{Name: c.RemoteRef.Refspec()},
+		Path:    path,
+		Ref:     &lockRef{Name: c.RemoteRef.Refspec()},
+		Message: message,
 	})
 	if err != nil {
 		return Lock{}, errors.Wrap(err, "api")
@@ -148,8 +163,8 @@ func (c *Client) LockFile(path string) (Lock, error) {
 	return lock, nil
 }
 
-// LockMultipleFiles locks multiple files.
-func (c *Client) LockMultipleFiles(paths []string) ([]Lock, error) {
+// LockMultipleFiles locks multiple files, with the same message.
+func (c *Client) LockMultipleFiles(paths []string, message string) ([]Lock, error) {
 	errs := make([]error, 0, len(paths))
 	locks := make([]Lock, 0, len(paths))
 	mutex := sync.Mutex{}
@@ -161,7 +176,7 @@ func (c *Client) LockMultipleFiles(paths []string) ([]Lock, error) {
 		go func(path string) {
 			defer func() { <-requestLimiter }()
 
-			lock, err := c.LockFile(path)
+			lock, err := c.LockFile(path, message)
 
 			mutex.Lock()
 			if err != nil {
@@ -224,7 +239,7 @@ func (c *Client) UnlockMultipleFiles(paths []string, force bool) ([]Lock, error)
 		}}
 	}
 
-	matching := make([]*Lock, 0, len(paths))
+	matching := make([]Lock, 0, len(paths))
 	missing := make(map[string]struct{})
 	for _, path := range paths {
 		missing[path] = struct{}{}
@@ -239,7 +254,7 @@ func (c *Client) UnlockMultipleFiles(paths []string, force bool) ([]Lock, error)
 		for i := range list.Locks {
 			for _, path := range paths {
 				if list.Locks[i].Path == path {
-					matching = append(matching, &list.Locks[i])
+					matching = append(matching, list.Locks[i])
 					delete(missing, path)
 					break
 				}
@@ -255,9 +270,14 @@ func (c *Client) UnlockMultipleFiles(paths []string, force bool) ([]Lock, error)
 		req.Cursor = list.NextCursor
 	}
 
-	errs := make([]error, 0, len(matching))
+	var errs []error
 	locks := make([]Lock, 0, len(matching))
-	mutex := sync.Mutex{}
+
+	// The server has no locks at these paths, so any cached locks at
+	// them are stale, e.g., because they were broken by another user.
+	for path := range missing {
+		c.forgetLockAtPath(path)
+	}
 
 	switch true {
 	case len(paths) == 1 && len(matching) == 0:
@@ -270,40 +290,71 @@ func (c *Client) UnlockMultipleFiles(paths []string, force bool) ([]Lock, error)
 		}
 	}
 
+	locks, unlockErrs := c.eachLock(matching, func(l Lock) (Lock, error) {
+		return l, c.UnlockFileById(l.Id, force)
+	})
+	errs = append(errs, unlockErrs...)
+
+	if len(errs) > 0 {
+		return locks, errors.Combine(errs)
+	}
+	return locks, nil
+}
+
+// UnlockMultipleLocks unlocks each of the given locks by its ID, as found
+// with SearchLocks, several at a time, and returns those which were unlocked.
+// Force causes locks held by other users to be unlocked as well
+func (c *Client) UnlockMultipleLocks(locks []Lock, force bool) ([]Lock, error) {
+	unlocked, errs := c.eachLock(locks, func(l Lock) (Lock, error) {
+		return l, c.UnlockFileById(l.Id, force)
+	})
+	if len(errs) > 0 {
+		return unlocked, errors.Combine(errs)
+	}
+	return unlocked, nil
+}
+
+// eachLock calls "fn" with each of "locks", at most c.ConcurrentRequests at a
+// time, and returns the locks it returned for those it succeeded on, along with
+// the errors of those it failed on, wrapped with their paths.
+func (c *Client) eachLock(locks []Lock, fn func(Lock) (Lock, error)) ([]Lock, []error) {
+	var errs []error
+	done := make([]Lock, 0, len(locks))
+	mutex := sync.Mutex{}
+
 	requestLimiter := make(chan struct{}, c.ConcurrentRequests)
-	for i := range matching {
+	for _, l := range locks {
 		requestLimiter <- struct{}{}
 
-		go func(idx int) {
+		go func(l Lock) {
 			defer func() { <-requestLimiter }()
 
-			err := c.UnlockFileById(matching[idx].Id, force)
+			lock, err := fn(l)
 
 			mutex.Lock()
 			if err != nil {
-				errs = append(errs, errors.Wrap(err, matching[idx].Path))
+				errs = append(errs, errors.Wrap(err, l.Path))
 			} else {
-				locks = append(locks, *matching[idx])
+				done = append(done, lock)
 			}
 			mutex.Unlock()
-		}(i)
+		}(l)
 	}
 
 	for i := 0; i < cap(requestLimiter); i++ {
 		requestLimiter <- struct{}{}
 	}
-
-	if len(errs) > 0 {
-		return locks, errors.Combine(errs)
-	}
-	return locks, nil
+	return done, errs
 }
 
 // UnlockFileById attempts to unlock a lock with a given id on the current remote
 // Force causes the file to be unlocked from other users as well
 func (c *Client) UnlockFileById(id string, force bool) error {
-	unlockRes, _, err := c.client.Unlock(c.RemoteRef, c.Remote, id, force)
+	unlockRes, res, err := c.client.Unlock(c.RemoteRef, c.Remote, id, force)
 	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			c.forgetLockById(id)
+		}
 		return errors.Wrap(err, "api")
 	}
 
@@ -311,25 +362,167 @@ func (c *Client) UnlockFileById(id string, force bool) error {
 		if len(unlockRes.RequestID) > 0 {
 			tracerx.Printf("Server Request ID: %s", unlockRes.RequestID)
 		}
+		if !c.remoteLockExists(id) {
+			c.forgetLockById(id)
+		}
 		return fmt.Errorf("server unable to unlock: %s", unlockRes.Message)
 	}
 
 	if err := c.cache.RemoveById(id); err != nil {
 		return fmt.Errorf("error caching unlock information: %v", err)
 	}
+	if unlockRes.Lock != nil {
+		// A forced unlock may break a lock at a path which is cached
+		// under another ID, so forget it by path as well.
+		if err := c.cache.RemoveByPath(unlockRes.Lock.Path); err != nil {
+			return fmt.Errorf("error caching unlock information: %v", err)
+		}
+	}
 
 	if unlockRes.Lock != nil {
 		abs := filepath.Join(c.gitRoot, unlockRes.Lock.Path)
 
-		// Make non-writeable if required
+		// Make non-writeable if required, unless the file has been
+		// deleted since it was locked
 		if c.SetLockableFilesReadOnly && c.IsFileLockable(unlockRes.Lock.Path) {
-			return tools.SetFileWriteFlag(abs, false)
+			if err := tools.SetFileWriteFlag(abs, false); err != nil && !os.IsNotExist(err) {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// TransferLockById hands the lock with the given id over to the user "owner"
+// on the current remote, and returns the lock as it then is. If the server
+// doesn't support transferring locks, the error returned is a "not implemented"
+// one.
+func (c *Client) TransferLockById(id, owner string) (Lock, error) {
+	transferRes, res, err := c.client.Transfer(c.RemoteRef, c.Remote, id, owner)
+	if err != nil {
+		if res != nil {
+			switch res.StatusCode {
+			case http.StatusNotFound:
+				// The server may not know the request, rather
+				// than the lock.
+				if c.remoteLockExists(id) {
+					return Lock{}, errors.NewNotImplementedError(err)
+				}
+				c.forgetLockById(id)
+			case http.StatusNotImplemented:
+				return Lock{}, errors.NewNotImplementedError(err)
+			}
+		}
+		if errors.IsNotImplementedError(err) {
+			return Lock{}, err
+		}
+		return Lock{}, errors.Wrap(err, "api")
+	}
+
+	if len(transferRes.Message) > 0 {
+		if len(transferRes.RequestID) > 0 {
+			tracerx.Printf("Server Request ID: %s", transferRes.RequestID)
+		}
+		return Lock{}, fmt.Errorf("server unable to transfer lock: %s", transferRes.Message)
+	}
+
+	// The lock is no longer ours, unless it was handed over to ourselves,
+	// in which case it is cached again the next time locks are verified.
+	if err := c.cache.RemoveById(id); err != nil {
+		return Lock{}, fmt.Errorf("error caching transfer information: %v", err)
+	}
+
+	lock := *transferRes.Lock
+	if c.SetLockableFilesReadOnly && c.IsFileLockable(lock.Path) {
+		abs := filepath.Join(c.gitRoot, lock.Path)
+		if err := tools.SetFileWriteFlag(abs, false); err != nil && !os.IsNotExist(err) {
+			return Lock{}, err
+		}
+	}
+	return lock, nil
+}
+
+// TransferMultipleLocks hands each of the given locks over to the user "owner",
+// several at a time, and returns them as they are once transferred. If the
+// server doesn't support transferring locks, no more are attempted once that
+// is found, and the error returned is a "not implemented" one.
+func (c *Client) TransferMultipleLocks(locks []Lock, owner string) ([]Lock, error) {
+	if len(locks) == 0 {
+		return nil, nil
+	}
+
+	// Try the first lock on its own, so that a server without support
+	// for transfers fails once, rather than for every lock.
+	first, err := c.TransferLockById(locks[0].Id, owner)
+	if errors.IsNotImplementedError(err) {
+		return nil, err
+	}
+
+	var errs []error
+	transferred := make([]Lock, 0, len(locks))
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, locks[0].Path))
+	} else {
+		transferred = append(transferred, first)
+	}
+
+	rest, restErrs := c.eachLock(locks[1:], func(l Lock) (Lock, error) {
+		return c.TransferLockById(l.Id, owner)
+	})
+	transferred = append(transferred, rest...)
+	errs = append(errs, restErrs...)
+
+	if len(errs) > 0 {
+		return transferred, errors.Combine(errs)
+	}
+	return transferred, nil
+}
+
+// remoteLockExists returns whether the server has a lock with the given id,
+// assuming that it does if that can't be determined.
+func (c *Client) remoteLockExists(id string) bool {
+	req := &lockSearchRequest{
+		Filters: []lockFilter{{Property: "id", Value: id}},
+	}
+
+	for {
+		list, _, err := c.client.Search(c.Remote, req)
+		if err != nil || len(list.Message) > 0 {
+			return true
+		}
+
+		for _, l := range list.Locks {
+			if l.Id == id {
+				return true
+			}
+		}
+
+		if list.NextCursor == "" {
+			return false
+		}
+		req.Cursor = list.NextCursor
+	}
+}
+
+// forgetLockById removes the cached lock with the given id, which the server no
+// longer has.
+func (c *Client) forgetLockById(id string) {
+	tracerx.Printf("locking: lock %s no longer exists on the server, removing it from the cache", id)
+	c.cache.RemoveById(id)
+}
+
+// forgetLockAtPath removes the cached lock at the given path, if any, which the
+// server no longer has.
+func (c *Client) forgetLockAtPath(path string) {
+	for _, l := range c.cache.Locks() {
+		if l.Path == path {
+			tracerx.Printf("locking: lock %s at %s no longer exists on the server, removing it from the cache", l.Id, path)
+			c.cache.RemoveByPath(path)
+		}
+	}
+}
+
 // Lock is a record of a locked file
 type Lock struct {
 	// Id is the unique identifier corresponding to this particular Lock. It
@@ -342,6 +535,9 @@ type Lock struct {
 	Owner *User `json:"owner,omitempty"`
 	// LockedAt is the time at which this lock was acquired.
 	LockedAt time.Time `json:"locked_at"`
+	// Message is the reason given for the lock when it was acquired, if
+	// any, and if the server keeps it.
+	Message string `json:"message,omitempty"`
 }
 
 // SearchLocks returns a channel of locks which match the given name/value filter
@@ -593,6 +789,30 @@ func (c *Client) prepareCacheDirectory(kind string) (string, error) {
 	return filepath.Join(cacheDir, kind), nil
 }
 
+// LocksCachedAt returns when the locks listed by SearchLocks, or by
+// SearchLocksVerifiable if "verifiable" is set, were last cached for the
+// current RemoteRef, as they are listed when "cached" is given.
+func (c *Client) LocksCachedAt(verifiable bool) (time.Time, error) {
+	kind := "remote"
+	if verifiable {
+		kind = "verifiable"
+	}
+
+	cacheFile, err := c.prepareCacheDirectory(kind)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	stat, err := os.Stat(cacheFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, errors.New("no cached locks present")
+		}
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
+
 func (c *Client) readLocksFromCacheFile(kind string, decoder func(*json.Decoder) error) error {
 	cacheFile, err := c.prepareCacheDirectory(kind)
 	if err != nil {
@@ -7,10 +7,13 @@ import (
 	"net/http/httptest"
 	"os"
 	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfsapi"
 	"github.com/git-lfs/git-lfs/lfshttp"
@@ -129,6 +132,46 @@ func TestRemoteLocksWithCache(t *testing.T) {
 	assert.Equal(t, expectedLocks, locks)
 }
 
+func TestLocksCachedAt(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "testLocksCachedAt")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(&lockList{
+			Locks: []Lock{{Id: "100", Path: "folder/test1.dat"}},
+		})
+		assert.Nil(t, err)
+	}))
+	defer srv.Close()
+
+	lfsclient, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	client, err := NewClient("", lfsclient, config.New())
+	require.Nil(t, err)
+	require.Nil(t, client.SetupFileCache(tempDir))
+	client.RemoteRef = &git.Ref{Name: "refs/heads/master"}
+
+	_, err = client.LocksCachedAt(false)
+	assert.EqualError(t, err, "no cached locks present")
+
+	before := time.Now().Add(-time.Second)
+	_, err = client.SearchLocks(nil, 0, false, false)
+	require.Nil(t, err)
+
+	at, err := client.LocksCachedAt(false)
+	require.Nil(t, err)
+	assert.True(t, at.After(before))
+
+	// Only the locks listed by SearchLocks were cached.
+	_, err = client.LocksCachedAt(true)
+	assert.EqualError(t, err, "no cached locks present")
+}
+
 func TestRefreshCache(t *testing.T) {
 	var err error
 	tempDir, err := ioutil.TempDir("", "testCacheLock")
@@ -314,3 +357,187 @@ func TestSearchLocksVerifiableWithCache(t *testing.T) {
 	sort.Sort(LocksById(theirLocks))
 	assert.Equal(t, expectedTheirLocks, theirLocks)
 }
+
+func TestUnlockForgetsLocksDeletedOnServer(t *testing.T) {
+	var err error
+	tempDir, err := ioutil.TempDir("", "testCacheLock")
+	assert.Nil(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/locks":
+			assert.Equal(t, "GET", r.Method)
+			err = json.NewEncoder(w).Encode(&lockList{Locks: []Lock{}})
+		case "/api/locks/102/unlock":
+			w.WriteHeader(http.StatusNotFound)
+			err = json.NewEncoder(w).Encode(&unlockResponse{Message: "not found"})
+		default:
+			err = json.NewEncoder(w).Encode(&unlockResponse{Message: "unable to find lock"})
+		}
+		assert.Nil(t, err)
+	}))
+
+	defer func() {
+		srv.Close()
+	}()
+
+	lfsclient, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url":    srv.URL + "/api",
+		"user.name":  "Fred",
+		"user.email": "fred@bloggs.com",
+	}))
+	require.Nil(t, err)
+
+	client, err := NewClient("", lfsclient, config.New())
+	assert.Nil(t, err)
+	assert.Nil(t, client.SetupFileCache(tempDir))
+	client.RemoteRef = &git.Ref{Name: "refs/heads/master"}
+
+	client.cache.Add(Lock{Id: "101", Path: "folder/test1.dat"})
+	client.cache.Add(Lock{Id: "102", Path: "folder/test2.dat"})
+	client.cache.Add(Lock{Id: "103", Path: "folder/test3.dat"})
+
+	// By path: the server has no lock at the path.
+	_, err = client.UnlockMultipleFiles([]string{"folder/test1.dat"}, true)
+	assert.Equal(t, ErrNoMatchingLocks, err)
+
+	// By ID: the server responds with a 404.
+	assert.NotNil(t, client.UnlockFileById("102", false))
+
+	// By ID: the server can't unlock the lock, and doesn't list it.
+	assert.NotNil(t, client.UnlockFileById("103", true))
+
+	locks, err := client.SearchLocks(nil, 0, true, false)
+	assert.Nil(t, err)
+	assert.Empty(t, locks)
+}
+
+func TestTransferMultipleLocks(t *testing.T) {
+	var err error
+	tempDir, err := ioutil.TempDir("", "testCacheLock")
+	assert.Nil(t, err)
+
+	var transfers int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/locks/102/transfer":
+			atomic.AddInt32(&transfers, 1)
+			assert.Nil(t, json.NewEncoder(w).Encode(&transferResponse{Message: "not allowed"}))
+		default:
+			atomic.AddInt32(&transfers, 1)
+			req := &transferRequest{}
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/locks/"), "/transfer")
+			assert.Nil(t, json.NewEncoder(w).Encode(&transferResponse{
+				Lock: &Lock{Id: id, Path: "folder/test" + id + ".dat", Owner: req.Owner},
+			}))
+		}
+	}))
+	defer srv.Close()
+
+	lfsclient, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	client, err := NewClient("", lfsclient, config.New())
+	assert.Nil(t, err)
+	assert.Nil(t, client.SetupFileCache(tempDir))
+	client.RemoteRef = &git.Ref{Name: "refs/heads/master"}
+
+	locks := []Lock{
+		{Id: "101", Path: "folder/test101.dat"},
+		{Id: "102", Path: "folder/test102.dat"},
+		{Id: "103", Path: "folder/test103.dat"},
+	}
+	for _, l := range locks {
+		client.cache.Add(l)
+	}
+
+	transferred, err := client.TransferMultipleLocks(locks, "Jane Doe")
+	assert.NotNil(t, err)
+	assert.False(t, errors.IsNotImplementedError(err))
+	assert.EqualValues(t, 3, transfers)
+
+	sort.Sort(LocksById(transferred))
+	require.Len(t, transferred, 2)
+	assert.Equal(t, "101", transferred[0].Id)
+	assert.Equal(t, "103", transferred[1].Id)
+	assert.Equal(t, "Jane Doe", transferred[1].Owner.Name)
+
+	// Only the lock which couldn't be handed over is still ours.
+	cached, err := client.SearchLocks(nil, 0, true, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []Lock{locks[1]}, cached)
+}
+
+func TestTransferMultipleLocksUnsupported(t *testing.T) {
+	var transfers int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/locks" {
+			// The lock still exists, so the server doesn't know
+			// the transfer request.
+			assert.Nil(t, json.NewEncoder(w).Encode(&lockList{
+				Locks: []Lock{{Id: "101", Path: "folder/test1.dat"}},
+			}))
+			return
+		}
+		atomic.AddInt32(&transfers, 1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+	}))
+	defer srv.Close()
+
+	lfsclient, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	client, err := NewClient("", lfsclient, config.New())
+	assert.Nil(t, err)
+	client.RemoteRef = &git.Ref{Name: "refs/heads/master"}
+
+	transferred, err := client.TransferMultipleLocks([]Lock{
+		{Id: "101", Path: "folder/test1.dat"},
+		{Id: "102", Path: "folder/test2.dat"},
+	}, "Jane Doe")
+	assert.True(t, errors.IsNotImplementedError(err))
+	assert.Empty(t, transferred)
+	assert.EqualValues(t, 1, transfers)
+}
+
+func TestUnlockMultipleLocks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		req := &unlockRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		assert.True(t, req.Force)
+
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/locks/"), "/unlock")
+		assert.Nil(t, json.NewEncoder(w).Encode(&unlockResponse{
+			Lock: &Lock{Id: id, Path: "folder/test" + id + ".dat"},
+		}))
+	}))
+	defer srv.Close()
+
+	lfsclient, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	client, err := NewClient("", lfsclient, config.New())
+	assert.Nil(t, err)
+	client.RemoteRef = &git.Ref{Name: "refs/heads/master"}
+
+	locks := []Lock{
+		{Id: "101", Path: "folder/test101.dat"},
+		{Id: "102", Path: "folder/test102.dat"},
+	}
+	unlocked, err := client.UnlockMultipleLocks(locks, true)
+	assert.Nil(t, err)
+	sort.Sort(LocksById(unlocked))
+	assert.Equal(t, locks, unlocked)
+}
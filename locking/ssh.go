@@ -0,0 +1,302 @@
+package locking
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/ssh"
+)
+
+// sshLockClient makes lock requests through git-lfs-transfer(1) on the SSH
+// server of the remote's endpoint, over the connections used for objects.
+// Requests to endpoints which don't use the SSH transfer protocol are made
+// through "fallback" instead.
+type sshLockClient struct {
+	*lfsapi.Client
+	fallback lockBackend
+}
+
+// conn returns the connection to git-lfs-transfer(1) for "operation" on the
+// endpoint of "remote", or nil if requests are made through the fallback.
+func (c *sshLockClient) conn(operation, remote string) (*ssh.Connection, error) {
+	return c.Client.SSHTransfer(operation, remote)
+}
+
+func (c *sshLockClient) Lock(remote string, lockReq *lockRequest) (*lockResponse, *http.Response, error) {
+	conn, err := c.conn("upload", remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conn == nil {
+		return c.fallback.Lock(remote, lockReq)
+	}
+
+	args := []string{"path=" + lockReq.Path}
+	if lockReq.Ref != nil {
+		args = append(args, "refname="+lockReq.Ref.Name)
+	}
+	if len(lockReq.Message) > 0 {
+		args = append(args, "message="+lockReq.Message)
+	}
+
+	res, err := conn.Exchange(&ssh.Message{Command: "lock", Args: args}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lockRes := &lockResponse{}
+	switch res.Status {
+	case http.StatusCreated:
+		lockRes.Lock, err = sshLockFromArgs(res)
+		if err != nil {
+			return nil, nil, err
+		}
+	case http.StatusConflict:
+		// The existing lock is given with the conflict, as it is in
+		// the LFS API.
+		lockRes.Lock, _ = sshLockFromArgs(res)
+		lockRes.Message = sshMessage(res, "lock already exists")
+	default:
+		lockRes.Message = sshMessage(res, "")
+	}
+	return lockRes, nil, nil
+}
+
+func (c *sshLockClient) Unlock(ref *git.Ref, remote, id string, force bool) (*unlockResponse, *http.Response, error) {
+	conn, err := c.conn("upload", remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conn == nil {
+		return c.fallback.Unlock(ref, remote, id, force)
+	}
+
+	args := []string{"refname=" + ref.Refspec()}
+	if force {
+		args = append(args, "force=true")
+	}
+
+	res, err := conn.Exchange(&ssh.Message{Command: "unlock " + id, Args: args}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unlockRes := &unlockResponse{}
+	if res.Status != http.StatusOK {
+		unlockRes.Message = sshMessage(res, "")
+		return unlockRes, nil, nil
+	}
+	unlockRes.Lock, err = sshLockFromArgs(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	return unlockRes, nil, nil
+}
+
+// Transfer fails with a "not implemented" error for endpoints using the SSH
+// transfer protocol, which has no request to hand a lock over to another user.
+func (c *sshLockClient) Transfer(ref *git.Ref, remote, id, owner string) (*transferResponse, *http.Response, error) {
+	conn, err := c.conn("upload", remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conn == nil {
+		return c.fallback.Transfer(ref, remote, id, owner)
+	}
+	return nil, nil, errors.NewNotImplementedError(errors.New("ssh: git-lfs-transfer can't transfer locks"))
+}
+
+func (c *sshLockClient) Search(remote string, searchReq *lockSearchRequest) (*lockList, *http.Response, error) {
+	conn, err := c.conn("download", remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conn == nil {
+		return c.fallback.Search(remote, searchReq)
+	}
+
+	args := sshListArgs(searchReq.Refspec, searchReq.Cursor, searchReq.Limit)
+	for _, filter := range searchReq.Filters {
+		switch filter.Property {
+		case "path", "id":
+			args = append(args, filter.Property+"="+filter.Value)
+		default:
+			return nil, nil, fmt.Errorf("ssh: can't search locks by %q", filter.Property)
+		}
+	}
+
+	res, err := conn.Exchange(&ssh.Message{Command: "list-lock", Args: args}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.Status != http.StatusOK {
+		return &lockList{Message: sshMessage(res, "")}, nil, nil
+	}
+
+	locks, _, err := sshParseLocks(res.Lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := &lockList{NextCursor: sshNextCursor(res)}
+	for _, l := range locks {
+		list.Locks = append(list.Locks, *l)
+	}
+	return list, nil, nil
+}
+
+func (c *sshLockClient) SearchVerifiable(remote string, vreq *lockVerifiableRequest) (*lockVerifiableList, *http.Response, error) {
+	conn, err := c.conn("upload", remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conn == nil {
+		return c.fallback.SearchVerifiable(remote, vreq)
+	}
+
+	var refspec string
+	if vreq.Ref != nil {
+		refspec = vreq.Ref.Name
+	}
+	args := sshListArgs(refspec, vreq.Cursor, vreq.Limit)
+
+	res, err := conn.Exchange(&ssh.Message{Command: "list-lock", Args: args}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch res.Status {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusNotImplemented:
+		return nil, nil, errors.NewNotImplementedError(res.Err())
+	case http.StatusForbidden:
+		return nil, nil, errors.NewAuthError(res.Err())
+	default:
+		return &lockVerifiableList{Message: sshMessage(res, "")}, nil, nil
+	}
+
+	locks, owners, err := sshParseLocks(res.Lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := &lockVerifiableList{NextCursor: sshNextCursor(res)}
+	for _, l := range locks {
+		switch owners[l.Id] {
+		case "ours":
+			list.Ours = append(list.Ours, *l)
+		case "theirs":
+			list.Theirs = append(list.Theirs, *l)
+		default:
+			return nil, nil, fmt.Errorf("ssh: lock %q has no owner", l.Id)
+		}
+	}
+	return list, nil, nil
+}
+
+// sshListArgs returns the arguments of a "list-lock" request for the locks on
+// "refspec", starting at "cursor", and at most "limit" of them, if not zero.
+func sshListArgs(refspec, cursor string, limit int) []string {
+	var args []string
+	if len(refspec) > 0 {
+		args = append(args, "refname="+refspec)
+	}
+	if len(cursor) > 0 {
+		args = append(args, "cursor="+cursor)
+	}
+	if limit > 0 {
+		args = append(args, "limit="+strconv.Itoa(limit))
+	}
+	return args
+}
+
+// sshNextCursor returns the cursor of the next page of a "list-lock" response,
+// or the empty string if it was the last one.
+func sshNextCursor(res *ssh.Response) string {
+	cursor, _ := res.Arg("next-cursor")
+	return cursor
+}
+
+// sshMessage returns the message of the failed response "res", or "fallback"
+// if it has none.
+func sshMessage(res *ssh.Response, fallback string) string {
+	if msg := strings.Join(res.Lines, "\n"); len(msg) > 0 {
+		return msg
+	}
+	if len(fallback) > 0 {
+		return fallback
+	}
+	return fmt.Sprintf("server returned status %d", res.Status)
+}
+
+// sshLockFromArgs returns the lock described by the arguments of the response
+// to a "lock" or "unlock" request.
+func sshLockFromArgs(res *ssh.Response) (*Lock, error) {
+	id, ok := res.Arg("id")
+	if !ok {
+		return nil, fmt.Errorf("ssh: invalid server response, missing lock id")
+	}
+
+	lock := &Lock{Id: id}
+	lock.Path, _ = res.Arg("path")
+	if name, ok := res.Arg("ownername"); ok {
+		lock.Owner = NewUser(name)
+	}
+	if at, ok := res.Arg("locked-at"); ok {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, fmt.Errorf("ssh: invalid lock time %q", at)
+		}
+		lock.LockedAt = t
+	}
+	lock.Message, _ = res.Arg("message")
+	return lock, nil
+}
+
+// sshParseLocks parses the lines of a "list-lock" response, each of the form
+// "lock <id>" or "<property> <id> <value>", returning the locks in the order
+// they were given, and whether each is "ours" or "theirs", if that was given.
+func sshParseLocks(lines []string) ([]*Lock, map[string]string, error) {
+	var locks []*Lock
+	byId := make(map[string]*Lock)
+	owners := make(map[string]string)
+
+	for _, line := range lines {
+		fields := strings.SplitN(line, " ", 3)
+		if fields[0] == "lock" && len(fields) == 2 {
+			lock := &Lock{Id: fields[1]}
+			byId[lock.Id] = lock
+			locks = append(locks, lock)
+			continue
+		}
+
+		if len(fields) != 3 {
+			return nil, nil, fmt.Errorf("ssh: invalid lock line %q", line)
+		}
+		lock, ok := byId[fields[1]]
+		if !ok {
+			return nil, nil, fmt.Errorf("ssh: unknown lock %q in line %q", fields[1], line)
+		}
+
+		switch fields[0] {
+		case "path":
+			lock.Path = fields[2]
+		case "locked-at":
+			t, err := time.Parse(time.RFC3339, fields[2])
+			if err != nil {
+				return nil, nil, fmt.Errorf("ssh: invalid lock time in line %q", line)
+			}
+			lock.LockedAt = t
+		case "ownername":
+			lock.Owner = NewUser(fields[2])
+		case "owner":
+			owners[lock.Id] = fields[2]
+		case "message":
+			lock.Message = fields[2]
+		}
+	}
+	return locks, owners, nil
+}
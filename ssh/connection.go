@@ -0,0 +1,310 @@
+// Package ssh implements the client side of the SSH transfer protocol, in
+// which batch requests, the objects themselves and locks are all sent over a
+// single connection to git-lfs-transfer(1) on an SSH server, without any HTTPS
+// endpoint.
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/git-lfs/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+// ProtocolVersion is the version of the SSH transfer protocol spoken by the
+// client, which the server must advertise.
+const ProtocolVersion = 1
+
+// Connection is a connection to git-lfs-transfer(1) for a single operation,
+// "upload" or "download". Messages are exchanged one at a time, so it may be
+// shared by several goroutines.
+type Connection struct {
+	// Operation is the operation given to git-lfs-transfer(1) when it was
+	// started.
+	Operation string
+
+	cmd    *subprocess.Cmd
+	stdin  io.WriteCloser
+	stderr *bytes.Buffer
+	pl     *git.Pktline
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Message is a request sent to the server.
+type Message struct {
+	// Command is the request, such as "batch" or "get-object <oid>".
+	Command string
+	// Args are the arguments of the command, each of the form
+	// "key=value".
+	Args []string
+	// Lines are sent after the arguments, unless Data isn't nil, in which
+	// case its data is sent instead.
+	Lines []string
+	Data  io.Reader
+}
+
+// Response is the response of the server to a Message.
+type Response struct {
+	// Status has the meaning of the equivalent HTTP status code.
+	Status int
+	// Args are the arguments of the response, each of the form
+	// "key=value".
+	Args []string
+	// Lines are the lines of text sent after the arguments, including the
+	// message of a failed request. They are empty if the data of the
+	// response was written elsewhere.
+	Lines []string
+}
+
+// Arg returns the value of the argument "key" of the response, and whether it
+// was given.
+func (r *Response) Arg(key string) (string, bool) {
+	return findArg(r.Args, key)
+}
+
+// Err returns a *StatusError if the request failed, or nil otherwise.
+func (r *Response) Err() error {
+	if r.Status < 400 {
+		return nil
+	}
+	return &StatusError{Status: r.Status, Message: strings.Join(r.Lines, "\n")}
+}
+
+// StatusError is returned for a request which the server failed with the
+// status "Status".
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("ssh: server returned status %d", e.Status)
+	}
+	return fmt.Sprintf("ssh: server returned status %d: %s", e.Status, e.Message)
+}
+
+// Start runs git-lfs-transfer(1) with the operation "operation" on the SSH
+// server of the endpoint "e", as git-lfs-authenticate is run, and negotiates
+// the version of the protocol, returning an error if the server doesn't
+// support it.
+func Start(osEnv config.Environment, gitEnv config.Environment, e lfshttp.Endpoint, operation string) (*Connection, error) {
+	exe, args := lfshttp.SSHCommand(osEnv, gitEnv, e, fmt.Sprintf("git-lfs-transfer %s %s", e.SshPath, operation))
+	cmd := subprocess.ExecCommand(exe, args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh: failed to get stdin")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh: failed to get stdout")
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "ssh: failed to start git-lfs-transfer")
+	}
+
+	c := newConnection(operation, stdout, stdin)
+	c.cmd = cmd
+	c.stderr = stderr
+	if err := c.negotiate(); err != nil {
+		stdin.Close()
+		cmd.Wait()
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func newConnection(operation string, r io.Reader, w io.WriteCloser) *Connection {
+	return &Connection{
+		Operation: operation,
+		stdin:     w,
+		pl:        git.NewPktline(r, w),
+	}
+}
+
+// negotiate reads the capabilities advertised by the server, and agrees on
+// the version of the protocol.
+func (c *Connection) negotiate() error {
+	caps, err := c.pl.ReadPacketList()
+	if err != nil {
+		return errors.Wrap(err, "ssh: failed to read capabilities")
+	}
+
+	version := fmt.Sprintf("version=%d", ProtocolVersion)
+	supported := false
+	for _, capability := range caps {
+		if capability == version {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("ssh: server does not support version %d of the SSH transfer protocol", ProtocolVersion)
+	}
+
+	res, err := c.exchange(&Message{Command: fmt.Sprintf("version %d", ProtocolVersion)}, nil)
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// Exchange sends "msg" to the server and returns its response. If the request
+// succeeds and "to" isn't nil, the data of the response is written to "to".
+// Only a failure to talk to the server is returned as an error, since that of
+// a failed request is given by the Err method of the response.
+func (c *Connection) Exchange(msg *Message, to io.Writer) (*Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errors.New("ssh: connection is closed")
+	}
+	return c.exchange(msg, to)
+}
+
+func (c *Connection) exchange(msg *Message, to io.Writer) (*Response, error) {
+	// Only the command is traced, since the arguments may hold tokens.
+	tracerx.Printf("ssh: sending %q", msg.Command)
+	if err := c.writeMessage(msg); err != nil {
+		return nil, errors.Wrap(err, "ssh: failed to send request")
+	}
+
+	res, err := c.readResponse(to)
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh: failed to read response")
+	}
+	tracerx.Printf("ssh: received status %d for %q", res.Status, msg.Command)
+	return res, nil
+}
+
+func (c *Connection) writeMessage(msg *Message) error {
+	if err := c.pl.WritePacketText(msg.Command); err != nil {
+		return err
+	}
+	for _, arg := range msg.Args {
+		if err := c.pl.WritePacketText(arg); err != nil {
+			return err
+		}
+	}
+
+	if msg.Data != nil {
+		if err := c.pl.WriteDelim(); err != nil {
+			return err
+		}
+		w := c.pl.Writer()
+		if _, err := io.Copy(w, msg.Data); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	if len(msg.Lines) > 0 {
+		if err := c.pl.WriteDelim(); err != nil {
+			return err
+		}
+		for _, line := range msg.Lines {
+			if err := c.pl.WritePacketText(line); err != nil {
+				return err
+			}
+		}
+	}
+	return c.pl.WriteFlush()
+}
+
+func (c *Connection) readResponse(to io.Writer) (*Response, error) {
+	status, _, err := c.pl.ReadPacketTextWithLength()
+	if err != nil {
+		return nil, err
+	}
+	code, err := parseStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	args, delim, err := c.pl.ReadPacketListToDelim()
+	if err != nil {
+		return nil, err
+	}
+	res := &Response{Status: code, Args: args}
+	if !delim {
+		return res, nil
+	}
+
+	if to != nil && res.Err() == nil {
+		r := c.pl.Reader()
+		if _, err := io.Copy(to, r); err != nil {
+			// Read the rest of the data, so that the next
+			// response can be read.
+			io.Copy(ioutil.Discard, r)
+			return nil, err
+		}
+		return res, nil
+	}
+
+	res.Lines, err = c.pl.ReadPacketList()
+	return res, err
+}
+
+// parseStatus returns the status code of the status line "line".
+func parseStatus(line string) (int, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != "status" {
+		return 0, fmt.Errorf("invalid status line %q", line)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid status line %q", line)
+	}
+	return code, nil
+}
+
+// findArg returns the value of the argument "key" in "args", each of the form
+// "key=value", and whether it was given.
+func findArg(args []string, key string) (string, bool) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, key+"=") {
+			return arg[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// Close asks the server to quit, and waits for git-lfs-transfer(1) to exit.
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	tracerx.Printf("ssh: closing connection for %q", c.Operation)
+	_, err := c.exchange(&Message{Command: "quit"}, nil)
+	c.stdin.Close()
+	if c.cmd != nil {
+		if werr := c.cmd.Wait(); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
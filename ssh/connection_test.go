@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer answers each request read from its connection with "handle",
+// after advertising "caps".
+type fakeServer struct {
+	pl     *git.Pktline
+	caps   []string
+	handle func(command string, args []string, data []byte) (status string, resArgs []string, body []byte)
+}
+
+func (s *fakeServer) serve(t *testing.T) {
+	for _, c := range s.caps {
+		require.Nil(t, s.pl.WritePacketText(c))
+	}
+	require.Nil(t, s.pl.WriteFlush())
+
+	for {
+		command, _, err := s.pl.ReadPacketTextWithLength()
+		if err != nil {
+			return
+		}
+		args, delim, err := s.pl.ReadPacketListToDelim()
+		require.Nil(t, err)
+		if command == "version 1" {
+			require.Nil(t, s.pl.WritePacketText("status 200"))
+			require.Nil(t, s.pl.WriteFlush())
+			continue
+		}
+
+		var data []byte
+		if delim {
+			data, err = ioutil.ReadAll(s.pl.Reader())
+			require.Nil(t, err)
+		}
+
+		status, resArgs, body := s.handle(command, args, data)
+		require.Nil(t, s.pl.WritePacketText(status))
+		for _, arg := range resArgs {
+			require.Nil(t, s.pl.WritePacketText(arg))
+		}
+		if body != nil {
+			require.Nil(t, s.pl.WriteDelim())
+			w := s.pl.Writer()
+			_, err := w.Write(body)
+			require.Nil(t, err)
+			require.Nil(t, w.Flush())
+		} else {
+			require.Nil(t, s.pl.WriteFlush())
+		}
+	}
+}
+
+func newTestConnection(t *testing.T, caps []string, handle func(string, []string, []byte) (string, []string, []byte)) *Connection {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	s := &fakeServer{pl: git.NewPktline(sr, sw), caps: caps, handle: handle}
+	go s.serve(t)
+
+	return newConnection("download", cr, cw)
+}
+
+// newNegotiatedConnection returns a connection to a fake server which has
+// agreed on the version of the protocol.
+func newNegotiatedConnection(t *testing.T, handle func(string, []string, []byte) (string, []string, []byte)) *Connection {
+	c := newTestConnection(t, []string{"version=1"}, handle)
+	require.Nil(t, c.negotiate())
+	return c
+}
+
+func TestConnectionNegotiate(t *testing.T) {
+	c := newTestConnection(t, []string{"version=1", "locking"}, func(command string, args []string, data []byte) (string, []string, []byte) {
+		t.Fatalf("unexpected request %q", command)
+		return "", nil, nil
+	})
+	assert.Nil(t, c.negotiate())
+}
+
+func TestConnectionNegotiateUnsupportedVersion(t *testing.T) {
+	c := newTestConnection(t, []string{"version=2"}, func(command string, args []string, data []byte) (string, []string, []byte) {
+		t.Fatalf("unexpected request %q", command)
+		return "", nil, nil
+	})
+	err := c.negotiate()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "version 1")
+}
+
+func TestConnectionExchangeLines(t *testing.T) {
+	c := newNegotiatedConnection(t, func(command string, args []string, data []byte) (string, []string, []byte) {
+		assert.Equal(t, "batch", command)
+		assert.Equal(t, []string{"hash-algo=sha256"}, args)
+		assert.Equal(t, "abc 1\n", string(data))
+		return "status 200", []string{"next=yes"}, []byte("abc 1 download\n")
+	})
+
+	res, err := c.Exchange(&Message{Command: "batch", Args: []string{"hash-algo=sha256"}, Lines: []string{"abc 1"}}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 200, res.Status)
+	assert.Nil(t, res.Err())
+	assert.Equal(t, []string{"abc 1 download"}, res.Lines)
+
+	v, ok := res.Arg("next")
+	assert.True(t, ok)
+	assert.Equal(t, "yes", v)
+	_, ok = res.Arg("missing")
+	assert.False(t, ok)
+}
+
+func TestConnectionExchangeData(t *testing.T) {
+	c := newNegotiatedConnection(t, func(command string, args []string, data []byte) (string, []string, []byte) {
+		switch command {
+		case "put-object abc":
+			assert.Equal(t, "uploaded", string(data))
+			return "status 200", nil, nil
+		case "get-object abc":
+			return "status 200", []string{"size=10"}, []byte("downloaded")
+		}
+		return "status 400", nil, []byte("unknown command\n")
+	})
+
+	res, err := c.Exchange(&Message{Command: "put-object abc", Data: strings.NewReader("uploaded")}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 200, res.Status)
+
+	var buf bytes.Buffer
+	res, err = c.Exchange(&Message{Command: "get-object abc"}, &buf)
+	require.Nil(t, err)
+	assert.Equal(t, 200, res.Status)
+	assert.Equal(t, "downloaded", buf.String())
+	assert.Empty(t, res.Lines)
+
+	buf.Reset()
+	res, err = c.Exchange(&Message{Command: "get-object def"}, &buf)
+	require.Nil(t, err)
+	assert.Empty(t, buf.String())
+
+	serr, ok := res.Err().(*StatusError)
+	require.True(t, ok)
+	assert.Equal(t, 400, serr.Status)
+	assert.Equal(t, "ssh: server returned status 400: unknown command", serr.Error())
+}
+
+func TestParseStatus(t *testing.T) {
+	code, err := parseStatus("status 404")
+	assert.Nil(t, err)
+	assert.Equal(t, 404, code)
+
+	for _, line := range []string{"", "status", "status abc", "state 200", "status 200 OK"} {
+		_, err := parseStatus(line)
+		assert.NotNil(t, err, line)
+	}
+}
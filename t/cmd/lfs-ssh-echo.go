@@ -3,11 +3,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/git-lfs/git-lfs/git"
 )
 
 type sshResponse struct {
@@ -20,6 +28,8 @@ type sshResponse struct {
 func main() {
 	// expect args:
 	//   lfs-ssh-echo -p PORT -- git@127.0.0.1 git-lfs-authenticate REPO OPERATION
+	// or:
+	//   lfs-ssh-echo -p PORT -- git@127.0.0.1 git-lfs-transfer REPO OPERATION
 	if len(os.Args) != 6 {
 		fmt.Fprintf(os.Stderr, "got %d args: %v", len(os.Args), os.Args)
 		os.Exit(1)
@@ -48,6 +58,20 @@ func main() {
 
 	repo := authLine[1]
 
+	if authLine[0] == "git-lfs-transfer" {
+		// Only repositories named "ssh-transfer-*" act as if the
+		// server supports the SSH transfer protocol.
+		if len(authLine) != 3 || !strings.HasPrefix(repo, "ssh-transfer") {
+			fmt.Fprintf(os.Stderr, "git-lfs-transfer: command not found\n")
+			os.Exit(127)
+		}
+		if err := transfer(os.Args[2], repo, authLine[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "git-lfs-transfer: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	r := &sshResponse{
 		Href: fmt.Sprintf("http://127.0.0.1:%s/%s.git/info/lfs", os.Args[2], repo),
 	}
@@ -63,3 +87,499 @@ func main() {
 
 	json.NewEncoder(os.Stdout).Encode(r)
 }
+
+// sshTransfer serves the SSH transfer protocol of git-lfs-transfer(1) on
+// stdin and stdout, making the requests of the client through the LFS API of
+// lfstest-gitserver, so that the objects and locks it keeps can be checked as
+// usual.
+type sshTransfer struct {
+	api       string
+	operation string
+	pl        *git.Pktline
+
+	// actions holds the action returned by the LFS API for each object in
+	// the last batch response, by OID.
+	actions map[string]*transferAction
+}
+
+type transferAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type transferObject struct {
+	Oid     string                     `json:"oid"`
+	Size    int64                      `json:"size"`
+	Actions map[string]*transferAction `json:"actions,omitempty"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type transferLock struct {
+	Id       string    `json:"id"`
+	Path     string    `json:"path"`
+	LockedAt time.Time `json:"locked_at"`
+	Message  string    `json:"message,omitempty"`
+	Owner    *struct {
+		Name string `json:"name"`
+	} `json:"owner,omitempty"`
+}
+
+type transferRef struct {
+	Name string `json:"name,omitempty"`
+}
+
+// transferResponse is the response to a request of the client.
+type transferResponse struct {
+	status int
+	args   []string
+	lines  []string
+	data   io.Reader
+}
+
+func transfer(port, repo, operation string) error {
+	t := &sshTransfer{
+		api:       fmt.Sprintf("http://127.0.0.1:%s/%s.git/info/lfs", port, repo),
+		operation: operation,
+		pl:        git.NewPktline(os.Stdin, os.Stdout),
+		actions:   make(map[string]*transferAction),
+	}
+
+	if err := t.pl.WritePacketText("version=1"); err != nil {
+		return err
+	}
+	if err := t.pl.WriteFlush(); err != nil {
+		return err
+	}
+
+	for {
+		command, _, err := t.pl.ReadPacketTextWithLength()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		args, delim, err := t.pl.ReadPacketListToDelim()
+		if err != nil {
+			return err
+		}
+
+		var res *transferResponse
+		var lines []string
+		switch {
+		case strings.HasPrefix(command, "put-object "):
+			if !delim {
+				return fmt.Errorf("no data for %q", command)
+			}
+			res = t.putObject(strings.TrimPrefix(command, "put-object "), t.pl.Reader())
+		default:
+			if delim {
+				if lines, err = t.pl.ReadPacketList(); err != nil {
+					return err
+				}
+			}
+			res = t.handle(command, args, lines)
+		}
+
+		if err := t.respond(res); err != nil {
+			return err
+		}
+		if command == "quit" {
+			return nil
+		}
+	}
+}
+
+func (t *sshTransfer) handle(command string, args, lines []string) *transferResponse {
+	fields := strings.Fields(command)
+	switch {
+	case command == "version 1", command == "quit":
+		return &transferResponse{status: 200}
+	case command == "batch":
+		return t.batch(args, lines)
+	case len(fields) == 2 && fields[0] == "get-object":
+		return t.getObject(fields[1])
+	case command == "lock":
+		return t.lock(args)
+	case command == "list-lock":
+		return t.listLock(args)
+	case len(fields) == 2 && fields[0] == "unlock":
+		return t.unlock(fields[1], args)
+	}
+	return transferError(400, "unknown command %q", command)
+}
+
+func (t *sshTransfer) respond(res *transferResponse) error {
+	if err := t.pl.WritePacketText(fmt.Sprintf("status %03d", res.status)); err != nil {
+		return err
+	}
+	for _, arg := range res.args {
+		if err := t.pl.WritePacketText(arg); err != nil {
+			return err
+		}
+	}
+
+	if res.data != nil {
+		if err := t.pl.WriteDelim(); err != nil {
+			return err
+		}
+		w := t.pl.Writer()
+		if _, err := io.Copy(w, res.data); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	if len(res.lines) > 0 {
+		if err := t.pl.WriteDelim(); err != nil {
+			return err
+		}
+		for _, line := range res.lines {
+			if err := t.pl.WritePacketText(line); err != nil {
+				return err
+			}
+		}
+	}
+	return t.pl.WriteFlush()
+}
+
+func transferError(status int, format string, args ...interface{}) *transferResponse {
+	return &transferResponse{status: status, lines: []string{fmt.Sprintf(format, args...)}}
+}
+
+func transferArg(args []string, key string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, key+"=") {
+			return arg[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// request makes a request of the LFS API, decoding the JSON response into "v"
+// if it succeeds, and returns its status code.
+func (t *sshTransfer) request(method, path string, body, v interface{}) (int, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, t.api+"/"+path, r)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth("user", "pass")
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 300 && v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			return 0, err
+		}
+	}
+	return res.StatusCode, nil
+}
+
+func (t *sshTransfer) batch(args, lines []string) *transferResponse {
+	req := struct {
+		Operation string            `json:"operation"`
+		Transfers []string          `json:"transfers"`
+		Ref       *transferRef      `json:"ref,omitempty"`
+		Objects   []*transferObject `json:"objects"`
+	}{Operation: t.operation, Transfers: []string{"basic"}}
+	if name := transferArg(args, "refname"); len(name) > 0 {
+		req.Ref = &transferRef{Name: name}
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return transferError(400, "invalid object %q", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return transferError(400, "invalid object %q", line)
+		}
+		req.Objects = append(req.Objects, &transferObject{Oid: fields[0], Size: size})
+	}
+
+	res := struct {
+		Objects []*transferObject `json:"objects"`
+	}{}
+	status, err := t.request("POST", "objects/batch", req, &res)
+	if err != nil {
+		return transferError(500, "batch: %v", err)
+	}
+	if status != 200 {
+		return transferError(status, "batch failed")
+	}
+
+	out := &transferResponse{status: 200}
+	for _, obj := range res.Objects {
+		action := "noop"
+		if a, ok := obj.Actions[t.operation]; ok && obj.Error == nil {
+			action = t.operation
+			t.actions[obj.Oid] = a
+			if v, ok := obj.Actions["verify"]; ok {
+				t.actions[obj.Oid+"/verify"] = v
+			}
+		}
+		line := fmt.Sprintf("%s %d %s", obj.Oid, obj.Size, action)
+		if action != "noop" {
+			line += " id=" + obj.Oid
+		}
+		out.lines = append(out.lines, line)
+	}
+	return out
+}
+
+// do makes a request of the action "a" given by the LFS API.
+func (t *sshTransfer) do(method string, a *transferAction, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, a.Href, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range a.Header {
+		req.Header.Set(k, v)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (t *sshTransfer) getObject(oid string) *transferResponse {
+	a, ok := t.actions[oid]
+	if !ok {
+		return transferError(404, "object %s not found", oid)
+	}
+
+	res, err := t.do("GET", a, nil)
+	if err != nil {
+		return transferError(500, "get-object: %v", err)
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return transferError(res.StatusCode, "get-object failed")
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return transferError(500, "get-object: %v", err)
+	}
+	return &transferResponse{status: 200, args: []string{fmt.Sprintf("size=%d", len(b))}, data: bytes.NewReader(b)}
+}
+
+func (t *sshTransfer) putObject(oid string, data io.Reader) *transferResponse {
+	// Read all the data first, so that the next request can be read
+	// whatever happens.
+	b, err := ioutil.ReadAll(data)
+	if err != nil {
+		return transferError(400, "put-object: %v", err)
+	}
+
+	a, ok := t.actions[oid]
+	if !ok {
+		return transferError(404, "object %s not expected", oid)
+	}
+	res, err := t.do("PUT", a, bytes.NewReader(b))
+	if err != nil {
+		return transferError(500, "put-object: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		return transferError(res.StatusCode, "put-object failed")
+	}
+
+	if v, ok := t.actions[oid+"/verify"]; ok {
+		body, _ := json.Marshal(&transferObject{Oid: oid, Size: int64(len(b))})
+		res, err := t.do("POST", v, bytes.NewReader(body))
+		if err != nil {
+			return transferError(500, "verify: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			return transferError(res.StatusCode, "verify failed")
+		}
+	}
+	return &transferResponse{status: 200}
+}
+
+func lockArgs(l *transferLock) []string {
+	args := []string{
+		"id=" + l.Id,
+		"path=" + l.Path,
+		"locked-at=" + l.LockedAt.UTC().Format(time.RFC3339),
+	}
+	if l.Owner != nil {
+		args = append(args, "ownername="+l.Owner.Name)
+	}
+	if len(l.Message) > 0 {
+		args = append(args, "message="+l.Message)
+	}
+	return args
+}
+
+func lockLines(l *transferLock) []string {
+	lines := []string{
+		"lock " + l.Id,
+		fmt.Sprintf("path %s %s", l.Id, l.Path),
+		fmt.Sprintf("locked-at %s %s", l.Id, l.LockedAt.UTC().Format(time.RFC3339)),
+	}
+	if l.Owner != nil {
+		lines = append(lines, fmt.Sprintf("ownername %s %s", l.Id, l.Owner.Name))
+	}
+	if len(l.Message) > 0 {
+		lines = append(lines, fmt.Sprintf("message %s %s", l.Id, l.Message))
+	}
+	return lines
+}
+
+func (t *sshTransfer) lock(args []string) *transferResponse {
+	req := struct {
+		Path    string       `json:"path"`
+		Ref     *transferRef `json:"ref,omitempty"`
+		Message string       `json:"message,omitempty"`
+	}{Path: transferArg(args, "path"), Message: transferArg(args, "message")}
+	if name := transferArg(args, "refname"); len(name) > 0 {
+		req.Ref = &transferRef{Name: name}
+	}
+
+	res := struct {
+		Lock    *transferLock `json:"lock"`
+		Message string        `json:"message"`
+	}{}
+	status, err := t.request("POST", "locks", req, &res)
+	if err != nil {
+		return transferError(500, "lock: %v", err)
+	}
+	if status != 200 && status != 201 {
+		return transferError(status, "lock failed")
+	}
+	if res.Lock == nil {
+		return transferError(409, "%s", res.Message)
+	}
+	return &transferResponse{status: 201, args: lockArgs(res.Lock)}
+}
+
+func (t *sshTransfer) listLock(args []string) *transferResponse {
+	if t.operation == "upload" {
+		return t.verifyLocks(args)
+	}
+
+	query := url.Values{}
+	for key, param := range map[string]string{
+		"path":    "path",
+		"id":      "id",
+		"cursor":  "cursor",
+		"limit":   "limit",
+		"refname": "refspec",
+	} {
+		if v := transferArg(args, key); len(v) > 0 {
+			query.Set(param, v)
+		}
+	}
+
+	res := struct {
+		Locks      []*transferLock `json:"locks"`
+		NextCursor string          `json:"next_cursor"`
+		Message    string          `json:"message"`
+	}{}
+	status, err := t.request("GET", "locks?"+query.Encode(), nil, &res)
+	if err != nil {
+		return transferError(500, "list-lock: %v", err)
+	}
+	if status != 200 {
+		return transferError(status, "list-lock failed")
+	}
+	if len(res.Message) > 0 {
+		return transferError(500, "%s", res.Message)
+	}
+
+	out := &transferResponse{status: 200}
+	if len(res.NextCursor) > 0 {
+		out.args = append(out.args, "next-cursor="+res.NextCursor)
+	}
+	for _, l := range res.Locks {
+		out.lines = append(out.lines, lockLines(l)...)
+	}
+	return out
+}
+
+func (t *sshTransfer) verifyLocks(args []string) *transferResponse {
+	req := struct {
+		Ref    *transferRef `json:"ref,omitempty"`
+		Cursor string       `json:"cursor,omitempty"`
+		Limit  int          `json:"limit,omitempty"`
+	}{Cursor: transferArg(args, "cursor")}
+	if name := transferArg(args, "refname"); len(name) > 0 {
+		req.Ref = &transferRef{Name: name}
+	}
+	req.Limit, _ = strconv.Atoi(transferArg(args, "limit"))
+
+	res := struct {
+		Ours       []*transferLock `json:"ours"`
+		Theirs     []*transferLock `json:"theirs"`
+		NextCursor string          `json:"next_cursor"`
+		Message    string          `json:"message"`
+	}{}
+	status, err := t.request("POST", "locks/verify", req, &res)
+	if err != nil {
+		return transferError(500, "list-lock: %v", err)
+	}
+	if status != 200 {
+		return transferError(status, "list-lock failed")
+	}
+	if len(res.Message) > 0 {
+		return transferError(500, "%s", res.Message)
+	}
+
+	out := &transferResponse{status: 200}
+	if len(res.NextCursor) > 0 {
+		out.args = append(out.args, "next-cursor="+res.NextCursor)
+	}
+	for owner, locks := range map[string][]*transferLock{"ours": res.Ours, "theirs": res.Theirs} {
+		for _, l := range locks {
+			out.lines = append(out.lines, lockLines(l)...)
+			out.lines = append(out.lines, fmt.Sprintf("owner %s %s", l.Id, owner))
+		}
+	}
+	return out
+}
+
+func (t *sshTransfer) unlock(id string, args []string) *transferResponse {
+	req := struct {
+		Force bool         `json:"force"`
+		Ref   *transferRef `json:"ref,omitempty"`
+	}{Force: transferArg(args, "force") == "true"}
+	if name := transferArg(args, "refname"); len(name) > 0 {
+		req.Ref = &transferRef{Name: name}
+	}
+
+	res := struct {
+		Lock    *transferLock `json:"lock"`
+		Message string        `json:"message"`
+	}{}
+	status, err := t.request("POST", "locks/"+id+"/unlock", req, &res)
+	if err != nil {
+		return transferError(500, "unlock: %v", err)
+	}
+	if status != 200 {
+		return transferError(status, "unlock failed")
+	}
+	if res.Lock == nil {
+		return transferError(404, "%s", res.Message)
+	}
+	return &transferResponse{status: 200, args: lockArgs(res.Lock)}
+}
@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -34,6 +35,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/git-lfs/git-lfs/tools/delta"
 )
 
 var (
@@ -59,6 +62,7 @@ var (
 		"status-batch-resume-206", "batch-resume-fail-fallback", "return-expired-action", "return-expired-action-forever", "return-invalid-size",
 		"object-authenticated", "storage-download-retry", "storage-upload-retry", "storage-upload-retry-later", "unknown-oid",
 		"send-verify-action", "send-deprecated-links", "redirect-storage-upload", "storage-compress",
+		"storage-download-expiring-token", "storage-chunked-upload-retry",
 	}
 
 	reqCookieReposRE = regexp.MustCompile(`\A/require-cookie-`)
@@ -94,6 +98,8 @@ func main() {
 
 	mux.HandleFunc("/storage/", storageHandler)
 	mux.HandleFunc("/verify", verifyHandler)
+	mux.HandleFunc("/s3/", s3Handler)
+	mux.HandleFunc("/imds/", imdsHandler)
 	mux.HandleFunc("/redirect307/", redirect307Handler)
 	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "%s\n", time.Now().String())
@@ -104,6 +110,11 @@ func main() {
 			return
 		}
 
+		if strings.Contains(r.URL.Path, "clock-skew") {
+			// Pretend the server's clock is an hour slow.
+			w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		}
+
 		if reqCookieReposRE.MatchString(r.URL.Path) {
 			if skipIfNoCookie(w, r, id) {
 				return
@@ -345,6 +356,7 @@ type batchReq struct {
 	Operation string      `json:"operation"`
 	Objects   []lfsObject `json:"objects"`
 	Ref       *Ref        `json:"ref,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
 }
 
 func (r *batchReq) RefName() string {
@@ -397,6 +409,13 @@ func lfsBatchHandler(w http.ResponseWriter, r *http.Request, id, repo string) {
 		log.Fatal(err)
 	}
 
+	// Objects requested under a namespace are kept apart from those of
+	// the repository without one, as if in a repository of their own
+	// named "<repo>/<namespace>".
+	if len(objs.Namespace) > 0 {
+		repo = repo + "/" + objs.Namespace
+	}
+
 	if strings.HasSuffix(repo, "branch-required") {
 		parts := strings.Split(repo, "-")
 		lenParts := len(parts)
@@ -418,6 +437,10 @@ func lfsBatchHandler(w http.ResponseWriter, r *http.Request, id, repo string) {
 	var searchForTransfer string
 	if testingTus {
 		searchForTransfer = "tus"
+	} else if testingChunkedUpload(r) {
+		searchForTransfer = "chunked"
+	} else if testingDeltaTransfer(r) {
+		searchForTransfer = "delta"
 	} else if testingCustomTransfer {
 		searchForTransfer = "testcustom"
 	}
@@ -489,6 +512,14 @@ func lfsBatchHandler(w http.ResponseWriter, r *http.Request, id, repo string) {
 					Header: map[string]string{},
 				}
 				a = serveExpired(a, repo, handler)
+				if handler == "storage-compress" {
+					// Ask for this object alone to be
+					// compressed in transit.
+					a.Header["Accept-Encoding"] = "gzip"
+				}
+				if handler == "storage-download-expiring-token" && action == "download" && !checkingObject {
+					a.Href += fmt.Sprintf("&token=%d", issueToken(repo, obj.Oid))
+				}
 
 				if handler == "send-deprecated-links" {
 					o.Links[action] = a
@@ -535,6 +566,21 @@ func lfsBatchHandler(w http.ResponseWriter, r *http.Request, id, repo string) {
 	debug(id, "RESPONSE: 200")
 	debug(id, string(by))
 
+	if strings.Contains(repo, "batch-gzip") && !checkingObject {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"message":"batch response must be compressed"}`))
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(200)
+		gz := gzip.NewWriter(w)
+		gz.Write(by)
+		gz.Close()
+		return
+	}
+
 	w.WriteHeader(200)
 	w.Write(by)
 }
@@ -546,6 +592,24 @@ var emu sync.Mutex
 // has yet served an expired object.
 var expiredRepos = map[string]bool{}
 
+var (
+	// issuedTokens is the number of download tokens issued for each
+	// object by repository and OID, for "storage-download-expiring-token".
+	issuedTokens   = make(map[string]int)
+	issuedTokensMu sync.Mutex
+)
+
+// issueToken returns a new token for downloading the object "oid" of "repo",
+// numbered from 1.
+func issueToken(repo, oid string) int {
+	issuedTokensMu.Lock()
+	defer issuedTokensMu.Unlock()
+
+	key := repo + ":" + oid
+	issuedTokens[key]++
+	return issuedTokens[key]
+}
+
 // serveExpired marks the given repo as having served an expired object, making
 // it unable for that same repository to return an expired object in the future,
 func serveExpired(a *lfsLink, repo, handler string) *lfsLink {
@@ -610,6 +674,11 @@ func verifyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasSuffix(repo, "verify-404") {
+		writeLFSError(w, http.StatusNotFound, "verify not implemented")
+		return
+	}
+
 	var max int
 	if matches := verifyRetryRe.FindStringSubmatch(repo); len(matches) < 2 {
 		return
@@ -645,7 +714,21 @@ func storageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.Contains(repo, "batch-gzip") && len(r.Header.Get("Accept-Encoding")) > 0 {
+		w.WriteHeader(400)
+		w.Write([]byte("objects must not be compressed"))
+		return
+	}
+
 	debug(id, "storage %s %s repo: %s", r.Method, oid, repo)
+	if isDeltaTransferRequest(r) && deltaTransferHandler(w, r, id, repo, oid) {
+		return
+	}
+	if isChunkedUploadRequest(r) {
+		chunkedUploadHandler(w, r, id, repo, oid)
+		return
+	}
+
 	switch r.Method {
 	case "PUT":
 		switch oidHandlers[oid] {
@@ -720,6 +803,12 @@ func storageHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Acknowledge the upload, but don't keep the object, like a
+		// storage backend which loses data.
+		if buf.String() == "storage-upload-lost" {
+			return
+		}
+
 		largeObjects.Set(repo, oid, buf.Bytes())
 
 	case "GET":
@@ -750,6 +839,54 @@ func storageHandler(w http.ResponseWriter, r *http.Request) {
 				} else {
 					compress = true
 				}
+			} else if strings.HasPrefix(string(by), "storage-download-range") {
+				// Honor both bounds of a Range header, unlike the
+				// rest of the objects, which ignore it.
+				if rangeHdr := r.Header.Get("Range"); rangeHdr != "" {
+					regex := regexp.MustCompile(`bytes=(\d+)\-(\d+)`)
+					if match := regex.FindStringSubmatch(rangeHdr); match != nil {
+						first, _ := strconv.Atoi(match[1])
+						last, _ := strconv.Atoi(match[2])
+						if last >= len(by) {
+							last = len(by) - 1
+						}
+						statusCode = 206
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(by)))
+						by = by[first : last+1]
+					}
+				}
+			} else if string(by) == "storage-download-expiring-token" {
+				// The first token expires part of the way through
+				// the download, which is cut short, and the second
+				// has expired by the time it is used. The third can
+				// resume the download.
+				switch r.URL.Query().Get("token") {
+				case "1":
+					w.Header().Set("Content-Length", strconv.Itoa(len(by)))
+					byteLimit = 10
+				case "2":
+					writeLFSError(w, 401, "token expired")
+					return
+				}
+				if match := regexp.MustCompile(`bytes=(\d+)\-.*`).FindStringSubmatch(r.Header.Get("Range")); match != nil {
+					statusCode = 206
+					resumeAt, _ = strconv.ParseInt(match[1], 10, 32)
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", resumeAt, len(by)-1, len(by)))
+				}
+			} else if string(by) == "storage-download-corrupt" {
+				// Serve other content of the same size, like a
+				// storage backend whose data has been tampered with.
+				by = bytes.ToUpper(by)
+			} else if string(by) == "storage-download-content-type" {
+				w.Header().Set("Content-Type", "image/x-lfs-test")
+			} else if string(by) == "storage-download-digest" {
+				sum := sha256.Sum256(by)
+				w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+			} else if string(by) == "storage-download-bad-digest" {
+				// Advertise the digest of some other content so that
+				// clients verifying digests reject the download.
+				sum := md5.Sum([]byte("not the content"))
+				w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
 			} else if len(by) == len("status-batch-resume-206") && string(by) == "status-batch-resume-206" {
 				// Resume if header includes range, otherwise deliberately interrupt
 				if rangeHdr := r.Header.Get("Range"); rangeHdr != "" {
@@ -808,6 +945,12 @@ func storageHandler(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 			}
+			// Advertise Range requests, which most objects
+			// ignore, unless the repository asks not to.
+			if !strings.Contains(repo, "no-accept-ranges") {
+				w.Header().Set("Accept-Ranges", "bytes")
+			}
+
 			var wrtr io.Writer = w
 			if compress {
 				w.Header().Set("Content-Encoding", "gzip")
@@ -923,6 +1066,313 @@ func storageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// isDeltaTransferRequest returns whether "r" is one of the requests made by the
+// delta transfer adapter: a GET offering bases for a download, a POST offering
+// them for an upload, or a PUT sending a delta.
+func isDeltaTransferRequest(r *http.Request) bool {
+	switch r.Method {
+	case "GET":
+		return len(r.Header.Get("LFS-Delta-Bases")) > 0
+	case "PUT":
+		return r.Header.Get("Content-Type") == delta.MediaType
+	case "POST":
+		// The chunked upload adapter completes uploads with a POST
+		// too, without any bases.
+		by, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(by))
+		return bytes.Contains(by, []byte(`"bases"`))
+	}
+	return false
+}
+
+// deltaTransferHandler answers the requests of the delta transfer adapter, and
+// returns whether it has; a download for which no delta is sent is answered
+// by storageHandler, with the whole object.
+func deltaTransferHandler(w http.ResponseWriter, r *http.Request, id, repo, oid string) bool {
+	switch r.Method {
+	case "GET":
+		by, ok := largeObjects.Get(repo, oid)
+		if !ok {
+			return false
+		}
+		for _, base := range strings.Split(r.Header.Get("LFS-Delta-Bases"), ",") {
+			base = strings.TrimSpace(base)
+			baseBy, ok := largeObjects.Get(repo, base)
+			if !ok {
+				continue
+			}
+
+			var buf bytes.Buffer
+			if _, err := delta.Diff(bytes.NewReader(baseBy), int64(len(baseBy)), bytes.NewReader(by), &buf); err != nil || buf.Len() >= len(by) {
+				continue
+			}
+			debug(id, "Sending delta of %v from %v, %d bytes", oid, base, buf.Len())
+			w.Header().Set("Content-Type", delta.MediaType)
+			w.Header().Set("LFS-Delta-Base", base)
+			w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+			w.Write(buf.Bytes())
+			return true
+		}
+		return false
+	case "POST":
+		var offer struct {
+			Oid   string   `json:"oid"`
+			Bases []string `json:"bases"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&offer); err != nil {
+			writeLFSError(w, 422, err.Error())
+			return true
+		}
+
+		var chosen struct {
+			Base string `json:"base,omitempty"`
+		}
+		for _, base := range offer.Bases {
+			if largeObjects.Has(repo, base) {
+				chosen.Base = base
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+		json.NewEncoder(w).Encode(chosen)
+	case "PUT":
+		base := r.Header.Get("LFS-Delta-Base")
+		baseBy, ok := largeObjects.Get(repo, base)
+		if !ok {
+			writeLFSError(w, 409, fmt.Sprintf("no delta base %s", base))
+			return true
+		}
+
+		var buf bytes.Buffer
+		if _, err := delta.Apply(bytes.NewReader(baseBy), int64(len(baseBy)), r.Body, &buf); err != nil {
+			writeLFSError(w, 422, err.Error())
+			return true
+		}
+		hash := sha256.Sum256(buf.Bytes())
+		if hex.EncodeToString(hash[:]) != oid {
+			writeLFSError(w, 422, "delta does not make the object")
+			return true
+		}
+		debug(id, "Received delta of %v from %v, %d bytes", oid, base, r.ContentLength)
+		largeObjects.Set(repo, oid, buf.Bytes())
+	}
+	return true
+}
+
+var (
+	chunkedPartsMu sync.Mutex
+	// chunkedParts holds the parts of the chunked uploads which haven't
+	// been completed, by repository and OID, then by offset.
+	chunkedParts = make(map[string]map[int64][]byte)
+)
+
+// isChunkedUploadRequest returns whether "r" is one of the requests made by
+// the chunked upload adapter: a GET listing the parts the server has, a PUT
+// sending a single part, or a POST completing the upload.
+func isChunkedUploadRequest(r *http.Request) bool {
+	switch r.Method {
+	case "GET":
+		return strings.Contains(r.Header.Get("Accept"), "json")
+	case "PUT":
+		return len(r.Header.Get("Content-Range")) > 0
+	case "POST":
+		return true
+	}
+	return false
+}
+
+func chunkedUploadHandler(w http.ResponseWriter, r *http.Request, id, repo, oid string) {
+	key := repo + ":" + oid
+
+	switch r.Method {
+	case "GET":
+		type part struct {
+			Offset int64 `json:"offset"`
+			Size   int64 `json:"size"`
+		}
+		listing := struct {
+			Parts []part `json:"parts"`
+		}{Parts: []part{}}
+
+		chunkedPartsMu.Lock()
+		for offset, by := range chunkedParts[key] {
+			listing.Parts = append(listing.Parts, part{offset, int64(len(by))})
+		}
+		chunkedPartsMu.Unlock()
+
+		w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+		json.NewEncoder(w).Encode(listing)
+	case "PUT":
+		var start, end, total int64
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			debug(id, "Invalid Content-Range %q", r.Header.Get("Content-Range"))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Fail the second part of the object once, to check that
+		// the retry sends only the parts the server doesn't have.
+		if start > 0 && start == end-start+1 {
+			if retries, ok := incrementRetriesFor("storage", "chunked-upload", repo, oid, false); ok && retries < 2 {
+				io.Copy(ioutil.Discard, r.Body)
+				w.WriteHeader(500)
+				w.Write([]byte("malformed part"))
+				return
+			}
+		}
+
+		by, err := ioutil.ReadAll(r.Body)
+		if err != nil || int64(len(by)) != end-start+1 {
+			debug(id, "Incomplete part of %v at byte %d", oid, start)
+			w.WriteHeader(400)
+			return
+		}
+
+		chunkedPartsMu.Lock()
+		if chunkedParts[key] == nil {
+			chunkedParts[key] = make(map[int64][]byte)
+		}
+		chunkedParts[key][start] = by
+		chunkedPartsMu.Unlock()
+		debug(id, "Received part of %v at byte %d, %d bytes", oid, start, len(by))
+	case "POST":
+		chunkedPartsMu.Lock()
+		parts := chunkedParts[key]
+		delete(chunkedParts, key)
+		chunkedPartsMu.Unlock()
+
+		offsets := make([]int64, 0, len(parts))
+		for offset := range parts {
+			offsets = append(offsets, offset)
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+		buf := &bytes.Buffer{}
+		for _, offset := range offsets {
+			if offset != int64(buf.Len()) {
+				writeLFSError(w, 422, fmt.Sprintf("missing part at byte %d", buf.Len()))
+				return
+			}
+			buf.Write(parts[offset])
+		}
+
+		hash := sha256.Sum256(buf.Bytes())
+		if hex.EncodeToString(hash[:]) != oid {
+			writeLFSError(w, 422, "parts do not match the object")
+			return
+		}
+		largeObjects.Set(repo, oid, buf.Bytes())
+	}
+}
+
+var (
+	s3ObjectsMu sync.Mutex
+	// s3Objects holds the objects of the fake S3 buckets, by path, which
+	// starts with the name of the bucket.
+	s3Objects = make(map[string][]byte)
+)
+
+const (
+	s3TestAccessKeyID = "LFSTESTACCESSKEY"
+	s3TestRoleKeyID   = "LFSTESTROLEKEY"
+	s3TestRoleToken   = "lfs-test-role-token"
+)
+
+// s3Handler serves path-style S3 requests for the objects of any bucket,
+// signed by either the static test credentials or those of the fake IAM role
+// given by imdsHandler.
+func s3Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := reqId(w)
+	if !ok {
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/s3/")
+	debug(id, "s3 %s %s", r.Method, key)
+
+	auth := r.Header.Get("Authorization")
+	switch {
+	case !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") || len(r.Header.Get("X-Amz-Date")) == 0:
+		writeS3Error(w, 403, "AccessDenied", "request is not signed")
+		return
+	case strings.Contains(auth, "Credential="+s3TestAccessKeyID+"/"):
+	case strings.Contains(auth, "Credential="+s3TestRoleKeyID+"/") && r.Header.Get("X-Amz-Security-Token") == s3TestRoleToken:
+	default:
+		writeS3Error(w, 403, "InvalidAccessKeyId", "unknown credentials")
+		return
+	}
+
+	switch r.Method {
+	case "HEAD", "GET":
+		s3ObjectsMu.Lock()
+		by, ok := s3Objects[key]
+		s3ObjectsMu.Unlock()
+		if !ok {
+			writeS3Error(w, 404, "NoSuchKey", "no such key")
+			return
+		}
+
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(by)))
+		w.WriteHeader(200)
+		if r.Method == "GET" {
+			w.Write(by)
+		}
+	case "PUT":
+		by, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, 400, "IncompleteBody", err.Error())
+			return
+		}
+		sum := sha256.Sum256(by)
+		if hex.EncodeToString(sum[:]) != r.Header.Get("X-Amz-Content-Sha256") {
+			writeS3Error(w, 400, "XAmzContentSHA256Mismatch", "content does not match its signed SHA-256")
+			return
+		}
+
+		s3ObjectsMu.Lock()
+		s3Objects[key] = by
+		s3ObjectsMu.Unlock()
+	default:
+		w.WriteHeader(405)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, code int, s3Code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", s3Code, msg)
+}
+
+// imdsHandler serves the EC2 instance metadata of an instance with the IAM
+// role "lfs-test-role".
+func imdsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method + " " + strings.TrimPrefix(r.URL.Path, "/imds") {
+	case "PUT /latest/api/token":
+		w.Write([]byte("lfs-test-imds-token"))
+	case "GET /latest/meta-data/iam/security-credentials/":
+		if r.Header.Get("X-aws-ec2-metadata-token") != "lfs-test-imds-token" {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte("lfs-test-role"))
+	case "GET /latest/meta-data/iam/security-credentials/lfs-test-role":
+		if r.Header.Get("X-aws-ec2-metadata-token") != "lfs-test-imds-token" {
+			w.WriteHeader(401)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"Code":            "Success",
+			"AccessKeyId":     s3TestRoleKeyID,
+			"SecretAccessKey": "lfs-test-role-secret",
+			"Token":           s3TestRoleToken,
+			"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	default:
+		w.WriteHeader(404)
+	}
+}
+
 func validateTusHeaders(r *http.Request, id string) bool {
 	if len(r.Header.Get("Tus-Resumable")) == 0 {
 		debug(id, "Missing Tus-Resumable header in request")
@@ -1011,11 +1461,13 @@ type Lock struct {
 	Path     string    `json:"path"`
 	Owner    User      `json:"owner"`
 	LockedAt time.Time `json:"locked_at"`
+	Message  string    `json:"message,omitempty"`
 }
 
 type LockRequest struct {
-	Path string `json:"path"`
-	Ref  *Ref   `json:"ref,omitempty"`
+	Path    string `json:"path"`
+	Ref     *Ref   `json:"ref,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
 func (r *LockRequest) RefName() string {
@@ -1047,6 +1499,11 @@ type UnlockResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+type TransferRequest struct {
+	Owner *User `json:"owner"`
+	Ref   *Ref  `json:"ref,omitempty"`
+}
+
 type LockList struct {
 	Locks      []Lock `json:"locks"`
 	NextCursor string `json:"next_cursor,omitempty"`
@@ -1142,8 +1599,8 @@ func getFilteredLocks(repo, path, cursor, limit string) ([]Lock, string, error)
 			return nil, "", nil
 		}
 
-		if size+1 < len(locks) {
-			return locks[:size], locks[size+1].Id, nil
+		if size < len(locks) {
+			return locks[:size], locks[size].Id, nil
 		}
 	}
 
@@ -1167,6 +1624,28 @@ func delLock(repo string, id string) *Lock {
 	return deleted
 }
 
+// transferLock hands the lock with the given id over to "owner", and returns
+// it as it then is, or nil if there is no such lock.
+func transferLock(repo string, id string, owner User) *Lock {
+	lmu.Lock()
+	defer lmu.Unlock()
+
+	for i, l := range repoLocks[repo] {
+		if l.Id == id {
+			repoLocks[repo][i].Owner = owner
+			transferred := repoLocks[repo][i]
+			return &transferred
+		}
+	}
+	return nil
+}
+
+// lockOwnedByTests returns whether the lock "l" is held by the user making
+// requests in the tests, rather than by a user it was transferred to.
+func lockOwnedByTests(l Lock) bool {
+	return l.Owner.Name == "Git LFS Tests"
+}
+
 type LocksByCreatedAt []Lock
 
 func (c LocksByCreatedAt) Len() int           { return len(c) }
@@ -1174,8 +1653,9 @@ func (c LocksByCreatedAt) Less(i, j int) bool { return c[i].LockedAt.Before(c[j]
 func (c LocksByCreatedAt) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
 
 var (
-	lockRe   = regexp.MustCompile(`/locks/?$`)
-	unlockRe = regexp.MustCompile(`locks/([^/]+)/unlock\z`)
+	lockRe     = regexp.MustCompile(`/locks/?$`)
+	unlockRe   = regexp.MustCompile(`locks/([^/]+)/unlock\z`)
+	transferRe = regexp.MustCompile(`locks/([^/]+)/transfer\z`)
 )
 
 func locksHandler(w http.ResponseWriter, r *http.Request, repo string) {
@@ -1262,6 +1742,14 @@ func locksHandler(w http.ResponseWriter, r *http.Request, repo string) {
 				}
 			}
 
+			for _, l := range getLocks(repo) {
+				if l.Id == lockId && !lockOwnedByTests(l) && !unlockRequest.Force {
+					w.WriteHeader(403)
+					enc.Encode(&UnlockResponse{Message: "lock is owned by " + l.Owner.Name})
+					return
+				}
+			}
+
 			if l := delLock(repo, lockId); l != nil {
 				enc.Encode(&UnlockResponse{Lock: l})
 			} else {
@@ -1270,6 +1758,24 @@ func locksHandler(w http.ResponseWriter, r *http.Request, repo string) {
 			return
 		}
 
+		// Repositories whose names end in "no-lock-transfers" act like
+		// servers which don't support transferring locks.
+		if matches := transferRe.FindStringSubmatch(r.URL.Path); len(matches) > 1 && !strings.HasSuffix(repo, "no-lock-transfers") {
+			transferRequest := &TransferRequest{}
+			if err := dec.Decode(transferRequest); err != nil || transferRequest.Owner == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				enc.Encode(&LockResponse{Message: "invalid transfer request"})
+				return
+			}
+
+			if l := transferLock(repo, matches[1], *transferRequest.Owner); l != nil {
+				enc.Encode(&LockResponse{Lock: l})
+			} else {
+				enc.Encode(&LockResponse{Message: "unable to find lock"})
+			}
+			return
+		}
+
 		if strings.HasSuffix(r.URL.Path, "/locks/verify") {
 			if strings.HasSuffix(repo, "verify-5xx") {
 				w.WriteHeader(500)
@@ -1326,7 +1832,7 @@ func locksHandler(w http.ResponseWriter, r *http.Request, repo string) {
 				ll.NextCursor = nextCursor
 
 				for _, l := range locks {
-					if strings.Contains(l.Path, "theirs") {
+					if strings.Contains(l.Path, "theirs") || !lockOwnedByTests(l) {
 						ll.Theirs = append(ll.Theirs, l)
 					} else {
 						ll.Ours = append(ll.Ours, l)
@@ -1372,6 +1878,11 @@ func locksHandler(w http.ResponseWriter, r *http.Request, repo string) {
 				Owner:    User{Name: "Git LFS Tests"},
 				LockedAt: time.Now(),
 			}
+			// Repositories whose names end in "no-lock-messages"
+			// act like servers which don't support lock messages.
+			if !strings.HasSuffix(repo, "no-lock-messages") {
+				lock.Message = lockRequest.Message
+			}
 
 			addLocks(repo, *lock)
 
@@ -1423,6 +1934,12 @@ func testingTusUploadInBatchReq(r *http.Request) bool {
 func testingTusUploadInterruptedInBatchReq(r *http.Request) bool {
 	return strings.HasPrefix(r.URL.String(), "/test-tus-upload-interrupt")
 }
+func testingChunkedUpload(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.String(), "/test-chunked-upload")
+}
+func testingDeltaTransfer(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.String(), "/test-delta-transfer")
+}
 func testingCustomTransfer(r *http.Request) bool {
 	return strings.HasPrefix(r.URL.String(), "/test-custom-transfer")
 }
@@ -1590,7 +2107,13 @@ func skipIfBadAuth(w http.ResponseWriter, r *http.Request, id string) bool {
 		debug(id, "auth attempt against: %q", r.URL.Path)
 	}
 
-	w.WriteHeader(403)
+	// Some servers ask for credentials again when given the wrong ones,
+	// rather than refusing access.
+	if strings.Contains(r.URL.Path, "reject-bad-creds") {
+		w.WriteHeader(401)
+	} else {
+		w.WriteHeader(403)
+	}
 	debug(id, "Bad auth: %q", auth)
 	return true
 }
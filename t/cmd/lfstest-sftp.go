@@ -0,0 +1,130 @@
+// +build testtools
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// lfstest-sftp stands in for sftp(1) in batch mode, with "-b -", running the
+// commands read from standard input against the local filesystem, whatever
+// host is given. Only the commands used by the standalone SFTP transfer agent
+// are implemented.
+func main() {
+	args := os.Args[1:]
+	var host string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-b", "-o", "-P":
+			i++
+		case "-q":
+		default:
+			host = args[i]
+		}
+	}
+	if len(host) == 0 {
+		fail("no host given")
+	}
+
+	if log := os.Getenv("LFSTEST_SFTP_LOG"); len(log) > 0 {
+		f, err := os.OpenFile(log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%s\n", strings.Join(os.Args[1:], " "))
+			f.Close()
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		ignoreErrors := strings.HasPrefix(line, "-")
+		words := split(strings.TrimPrefix(line, "-"))
+
+		if err := run(words); err != nil && !ignoreErrors {
+			fail(fmt.Sprintf("%s: %s", words[0], err))
+		}
+	}
+}
+
+func run(words []string) error {
+	switch {
+	case words[0] == "ls" && len(words) == 2:
+		_, err := os.Stat(words[1])
+		return err
+	case words[0] == "mkdir" && len(words) == 2:
+		return os.Mkdir(words[1], 0755)
+	case words[0] == "put" && len(words) == 3, words[0] == "get" && len(words) == 3:
+		return copyFile(words[1], words[2])
+	case words[0] == "rename" && len(words) == 3:
+		if _, err := os.Stat(words[2]); err == nil {
+			return fmt.Errorf("%s exists", words[2])
+		}
+		return os.Rename(words[1], words[2])
+	default:
+		return fmt.Errorf("unsupported command %q", strings.Join(words, " "))
+	}
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// split splits "line" into words, which may be quoted with double quotes, in
+// which backslashes escape the following character.
+func split(line string) []string {
+	var words []string
+	var word strings.Builder
+	inWord, quoted, escaped := false, false, false
+	for _, c := range line {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			inWord = true
+		case c == ' ' && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -43,8 +44,21 @@ func main() {
 		switch req.Event {
 		case "init":
 			writeToStderr(fmt.Sprintf("Initialised test custom adapter for %s\n", req.Operation), errWriter)
-			resp := &initResponse{}
+			resp := &initResponse{
+				// Locks are handled unless the test asks for the
+				// agent to behave like one which predates them.
+				Locking: req.Operation == "lock" && os.Getenv("TEST_STANDALONE_NO_LOCKING") == "",
+			}
 			sendResponse(resp, writer, errWriter)
+		case "lock":
+			writeToStderr(fmt.Sprintf("Received lock request for %s\n", req.Path), errWriter)
+			performLock(req, writer, errWriter)
+		case "unlock":
+			writeToStderr(fmt.Sprintf("Received unlock request for %s\n", req.Id), errWriter)
+			performUnlock(req, writer, errWriter)
+		case "list":
+			writeToStderr(fmt.Sprintf("Received list request (verify: %v)\n", req.Verify), errWriter)
+			performList(req, writer, errWriter)
 		case "download":
 			writeToStderr(fmt.Sprintf("Received download request for %s\n", req.Oid), errWriter)
 			performDownload(req.Oid, req.Size, writer, errWriter)
@@ -170,6 +184,93 @@ func performUpload(oid string, size int64, fromPath string, writer, errWriter *b
 	}
 }
 
+// The locks are kept in a file next to the objects, so that they persist
+// between invocations.
+func locksPath() string {
+	return filepath.Join(backupDir, "locks.json")
+}
+
+func readLocks() []lock {
+	var locks []lock
+	if b, err := ioutil.ReadFile(locksPath()); err == nil {
+		json.Unmarshal(b, &locks)
+	}
+	return locks
+}
+
+func writeLocks(locks []lock) error {
+	b, err := json.Marshal(locks)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(locksPath(), b, 0644)
+}
+
+func sendLockError(event string, code int, message string, writer, errWriter *bufio.Writer) {
+	resp := &lockResponse{Event: event, Error: &transferError{code, message}}
+	if err := sendResponse(resp, writer, errWriter); err != nil {
+		writeToStderr(fmt.Sprintf("Unable to send lock error: %v\n", err), errWriter)
+	}
+}
+
+func performLock(req request, writer, errWriter *bufio.Writer) {
+	locks := readLocks()
+	for _, l := range locks {
+		if l.Path == req.Path {
+			sendLockError("lock", 409, "lock already created", writer, errWriter)
+			return
+		}
+	}
+
+	l := lock{
+		Id:       fmt.Sprintf("%x", sha256.Sum256([]byte(req.Path)))[:12],
+		Path:     req.Path,
+		Owner:    &lockOwner{Name: "Standalone User"},
+		LockedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := writeLocks(append(locks, l)); err != nil {
+		sendLockError("lock", 500, err.Error(), writer, errWriter)
+		return
+	}
+	sendResponse(&lockResponse{Event: "lock", Lock: &l}, writer, errWriter)
+}
+
+func performUnlock(req request, writer, errWriter *bufio.Writer) {
+	locks := readLocks()
+	for i, l := range locks {
+		if l.Id != req.Id {
+			continue
+		}
+		if err := writeLocks(append(locks[:i:i], locks[i+1:]...)); err != nil {
+			sendLockError("unlock", 500, err.Error(), writer, errWriter)
+			return
+		}
+		sendResponse(&lockResponse{Event: "unlock", Lock: &l}, writer, errWriter)
+		return
+	}
+	sendLockError("unlock", 404, "unable to find lock", writer, errWriter)
+}
+
+func performList(req request, writer, errWriter *bufio.Writer) {
+	locks := make([]lock, 0)
+	for _, l := range readLocks() {
+		if (len(req.Path) > 0 && l.Path != req.Path) || (len(req.Id) > 0 && l.Id != req.Id) {
+			continue
+		}
+		locks = append(locks, l)
+	}
+
+	resp := &lockResponse{Event: "list"}
+	if req.Verify {
+		// Every lock belongs to the only user of this agent.
+		resp.Ours = locks
+		resp.Theirs = make([]lock, 0)
+	} else {
+		resp.Locks = locks
+	}
+	sendResponse(resp, writer, errWriter)
+}
+
 // Structs reimplemented so closer to a real external implementation
 type header struct {
 	Key   string `json:"key"`
@@ -195,10 +296,32 @@ type request struct {
 	Size                int64   `json:"size"`
 	Path                string  `json:"path"`
 	Action              *action `json:"action"`
+	Id                  string  `json:"id"`
+	Force               bool    `json:"force"`
+	Ref                 string  `json:"ref"`
+	Verify              bool    `json:"verify"`
 }
 
 type initResponse struct {
-	Error *transferError `json:"error,omitempty"`
+	Error   *transferError `json:"error,omitempty"`
+	Locking bool           `json:"locking,omitempty"`
+}
+type lockOwner struct {
+	Name string `json:"name"`
+}
+type lock struct {
+	Id       string     `json:"id"`
+	Path     string     `json:"path"`
+	Owner    *lockOwner `json:"owner,omitempty"`
+	LockedAt time.Time  `json:"locked_at"`
+}
+type lockResponse struct {
+	Event  string         `json:"event"`
+	Lock   *lock          `json:"lock,omitempty"`
+	Locks  []lock         `json:"locks,omitempty"`
+	Ours   []lock         `json:"ours,omitempty"`
+	Theirs []lock         `json:"theirs,omitempty"`
+	Error  *transferError `json:"error,omitempty"`
 }
 type transferResponse struct {
 	Event string         `json:"event"`
@@ -280,7 +280,7 @@ func (infile *FileInput) AddToIndex(output *CommitOutput, repo *Repo) {
 		return
 	}
 	output.Files = append(output.Files, pointer)
-	RunGitCommand(repo.callback, true, "add", infile.Filename)
+	RunGitCommand(repo.callback, true, "add", "--", infile.Filename)
 }
 
 func (infile *FileInput) writeLFSPointer(repo *Repo, inputData io.Reader) (*lfs.Pointer, error) {
@@ -630,6 +630,38 @@ setup_local_branch_with_symlink() {
   git commit -m "add symlink"
 }
 
+# setup_local_branch_with_modes creates a repository as follows:
+#
+#   A
+#    \
+#     refs/heads/main
+#
+# - Commit 'A' has 120 random bytes in a.txt, 140 in the executable
+#   bin/run.txt, and the same contents in same.txt and the executable
+#   sub/same.txt, along with the symbolic links dir/link.txt to ../a.txt and
+#   dir/same.txt to same.txt, which is the same blob as the regular files of
+#   the same name.
+setup_local_branch_with_modes() {
+  set -e
+
+  reponame="migrate-single-local-branch-with-modes"
+
+  remove_and_create_local_repo "$reponame"
+
+  mkdir -p bin dir sub
+  base64 < /dev/urandom | head -c 120 > a.txt
+  base64 < /dev/urandom | head -c 140 > bin/run.txt
+  chmod +x bin/run.txt
+  printf "same.txt" > same.txt
+  cp same.txt sub/same.txt
+  chmod +x sub/same.txt
+
+  git add a.txt bin/run.txt same.txt sub/same.txt
+  add_symlink "../a.txt" "dir/link.txt"
+  add_symlink "same.txt" "dir/same.txt"
+  git commit -m "initial commit"
+}
+
 # setup_local_branch_with_dirty_copy creates a repository as follows:
 #
 #   A
@@ -153,3 +153,90 @@ begin_test "alternates (OS environment, multiple)"
     git lfs push "$(git config remote.origin.url)" main
 )
 end_test
+
+begin_test "alternates (lfs.storage.alternates)"
+(
+  set -e
+
+  reponame="alternates-storage-alternates"
+  setup_remote_repo_with_file "$reponame" "a.txt"
+
+  pushd "$TRASHDIR" > /dev/null
+    clone_repo "$reponame" "${reponame}_empty"
+    rm -rf .git/lfs/objects
+    mkdir -p .git/lfs/objects
+  popd > /dev/null
+  pushd "$TRASHDIR" > /dev/null
+    clone_repo "$reponame" "${reponame}_warehouse"
+  popd > /dev/null
+
+  oid="$(calc_oid "a.txt\n")"
+  rm -rf .git/lfs/objects a.txt
+
+  git config --add lfs.storage.alternates "$TRASHDIR/${reponame}_empty/.git/lfs/objects"
+  git config --add lfs.storage.alternates "$TRASHDIR/${reponame}_warehouse/.git/lfs/objects"
+
+  GIT_TRACE=1 git lfs fetch origin main 2>&1 | tee fetch.log
+  [ "0" -eq "$(grep -c "sending batch of size 1" fetch.log)" ]
+
+  git checkout -- a.txt
+  [ "a.txt" = "$(cat a.txt)" ]
+
+  # Objects read from an alternate are not copied into the repository.
+  refute_local_object "$oid"
+
+  git lfs fsck
+)
+end_test
+
+begin_test "alternates (lfs.storage.linkalternates)"
+(
+  set -e
+
+  reponame="alternates-storage-linkalternates"
+  setup_remote_repo_with_file "$reponame" "a.txt"
+
+  pushd "$TRASHDIR" > /dev/null
+    clone_repo "$reponame" "${reponame}_warehouse"
+  popd > /dev/null
+
+  oid="$(calc_oid "a.txt\n")"
+  rm -rf .git/lfs/objects a.txt
+
+  git config lfs.storage.alternates "$TRASHDIR/${reponame}_warehouse/.git/lfs/objects"
+  git config lfs.storage.linkalternates true
+
+  git checkout -- a.txt
+  [ "a.txt" = "$(cat a.txt)" ]
+
+  assert_local_object "$oid" 6
+)
+end_test
+
+begin_test "alternates (lfs.storage.alternates, migrate export)"
+(
+  set -e
+
+  reponame="alternates-storage-alternates-migrate-export"
+  setup_remote_repo_with_file "$reponame" "a.txt"
+
+  pushd "$TRASHDIR" > /dev/null
+    clone_repo "$reponame" "${reponame}_warehouse"
+  popd > /dev/null
+
+  # Use a bare repository, so that the object isn't cleaned back into the
+  # repository from the working copy.
+  mv .git "$TRASHDIR/$reponame.git"
+  cd "$TRASHDIR/$reponame.git"
+  git config --bool core.bare true
+
+  rm -rf lfs/objects
+  git config lfs.storage.alternates "$TRASHDIR/${reponame}_warehouse/.git/lfs/objects"
+
+  # The object is read from the alternate, rather than downloaded.
+  git config lfs.url "http://127.0.0.1:1/nonexistent"
+  git lfs migrate export --everything --include="a.txt"
+
+  [ "a.txt" = "$(git cat-file -p main:a.txt)" ]
+)
+end_test
@@ -77,3 +77,50 @@ begin_test "batch storage download causes retries"
   popd
 )
 end_test
+
+begin_test "batch storage download retries with fresh actions when its token expires"
+(
+  set -e
+
+  reponame="batch-storage-download-expiring-token"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" batch-storage-repo-expiring-token
+
+  # The server cuts the download short when the first token it issues for
+  # this object expires, and refuses the token from then on.
+  contents="storage-download-expiring-token"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+
+  git lfs track "*.dat"
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  git push origin main
+  assert_server_object "$reponame" "$oid"
+
+  pushd ..
+    git \
+      -c "filter.lfs.process=" \
+      -c "filter.lfs.smudge=cat" \
+      -c "filter.lfs.required=false" \
+      clone "$GITSERVER/$reponame" "$reponame-assert"
+
+    cd "$reponame-assert"
+
+    git config credential.helper lfstest
+
+    GIT_TRACE=1 git lfs pull origin main 2>&1 | tee pull.log
+    if [ "0" -ne "${PIPESTATUS[0]}" ]; then
+      echo >&2 "fatal: expected \`git lfs pull origin main\` to succeed ..."
+      exit 1
+    fi
+
+    grep "xfer: authorization refused for download of \"$oid\"" pull.log
+    grep "xfer: server accepted resume download request: \"$oid\"" pull.log
+    [ "3" -eq "$(grep -c "tq: sending batch of size 1" pull.log)" ]
+
+    assert_local_object "$oid" "${#contents}"
+  popd
+)
+end_test
@@ -123,3 +123,47 @@ begin_test "batch transfers with ssh endpoint"
   git push origin main 2>&1
 )
 end_test
+
+begin_test "batch transfer with compressed batch responses"
+(
+  set -e
+
+  # The server only answers batch requests asking for a compressed response,
+  # and refuses object transfers which ask for one.
+  reponame="batch-gzip"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" batch-gzip
+
+  git lfs track "*.dat"
+  contents="batch-gzip"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "http: decompressed gzipped response" push.log
+  assert_server_object "$reponame" "$oid"
+
+  rm -rf .git/lfs/objects
+  GIT_TRACE=1 git lfs fetch 2>&1 | tee fetch.log
+  grep "http: decompressed gzipped response" fetch.log
+  assert_local_object "$oid" "${#contents}"
+)
+end_test
+
+begin_test "batch transfer warns about an invalid lfs.transfer.batchsize"
+(
+  set -e
+
+  reponame="batch-transfer-invalid-batchsize"
+  git init "$reponame"
+  cd "$reponame"
+
+  git -c lfs.transfer.batchsize=many lfs env 2>&1 >/dev/null | tee env.log
+  [ "1" -eq "$(grep -c "warning: ignoring invalid lfs.transfer.batchsize \"many\", must be a positive integer; using 100" env.log)" ]
+
+  git -c lfs.transfer.batchsize=25 lfs env 2>&1 >/dev/null | tee env.log
+  [ ! -s env.log ]
+)
+end_test
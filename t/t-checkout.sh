@@ -51,6 +51,14 @@ begin_test "checkout"
   grep 'accepting "file1.dat"' checkout.log
   ! grep 'rejecting "file1.dat"' checkout.log
 
+  rm -rf file1.dat file2.dat file3.dat folder1/nested.dat folder2/nested.dat
+
+  echo "checkout --quiet should replace all without progress"
+  git lfs checkout --quiet 2>&1 | tee checkout.log
+  [ "$contents" = "$(cat file1.dat)" ]
+  [ "$contents" = "$(cat folder2/nested.dat)" ]
+  [ ! -s checkout.log ]
+
   # Remove the working directory
   rm -rf file1.dat file2.dat file3.dat folder1/nested.dat folder2/nested.dat
 
@@ -97,12 +105,38 @@ begin_test "checkout"
   git push origin main
   rm -rf .git/lfs/objects
   rm file*.dat
-  git lfs checkout
+  git lfs checkout 2>&1 | tee checkout.log
   [ "$(pointer $contents_oid $contentsize)" = "$(cat file1.dat)" ]
   [ "$(pointer $contents_oid $contentsize)" = "$(cat file2.dat)" ]
   [ "$(pointer $contents_oid $contentsize)" = "$(cat file3.dat)" ]
   [ "$contents" = "$(cat folder1/nested.dat)" ]
   [ "$contents" = "$(cat folder2/nested.dat)" ]
+  grep "Skipped checkout for 5 file(s) whose Git LFS objects are not present locally:" checkout.log
+  grep "	file1.dat" checkout.log
+  grep "	folder2/nested.dat" checkout.log
+  grep "git lfs pull --include=\"file1.dat,file2.dat,file3.dat,folder1/nested.dat,folder2/nested.dat\"" checkout.log
+
+  echo "test checkout --fail-on-missing"
+  set +e
+  git lfs checkout --fail-on-missing file1.dat 2>&1 | tee checkout.log
+  res=${PIPESTATUS[0]}
+  set -e
+  [ "$res" -ne 0 ]
+  grep "Skipped checkout for 1 file(s)" checkout.log
+
+  echo "test checkout --json"
+  git lfs checkout --json file1.dat 2>&1 | tee checkout.log
+  [ "{\"name\":\"file1.dat\",\"skipped\":true,\"files_done\":1,\"files_total\":1,\"bytes_done\":$contentsize,\"bytes_total\":$contentsize}" = "$(head -n 1 checkout.log)" ]
+  [ "{\"skipped\":[{\"name\":\"file1.dat\",\"oid\":\"$contents_oid\",\"size\":$contentsize}]}" = "$(tail -n 1 checkout.log)" ]
+  [ 2 -eq "$(wc -l < checkout.log)" ]
+
+  git lfs checkout --json --quiet file1.dat 2>&1 | tee checkout.log
+  [ "{\"skipped\":[{\"name\":\"file1.dat\",\"oid\":\"$contents_oid\",\"size\":$contentsize}]}" = "$(cat checkout.log)" ]
+
+  git lfs fetch
+  git lfs checkout --json --quiet --fail-on-missing 2>&1 | tee checkout.log
+  [ '{"skipped":[]}' = "$(cat checkout.log)" ]
+  [ "$contents" = "$(cat file1.dat)" ]
 )
 end_test
 
@@ -250,3 +284,204 @@ begin_test "checkout: GIT_WORK_TREE"
   [ "$contents" = "$(cat "$reponame/file1.dat")" ]
 )
 end_test
+
+begin_test "checkout: --bytes"
+(
+  set -e
+
+  reponame="checkout-bytes"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+
+  ranged="storage-download-range and then some"
+  ranged_oid="$(calc_oid "$ranged")"
+  plain="no ranges for this object"
+  plain_oid="$(calc_oid "$plain")"
+  printf "%s" "$ranged" > ranged.dat
+  printf "%s" "$plain" > plain.dat
+  git add .gitattributes ranged.dat plain.dat
+  git commit -m "add files"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$reponame-clone"
+
+  GIT_TRACE=1 git lfs checkout --to out/ranged.txt --bytes 8-15 ranged.dat 2>&1 | tee checkout.log
+  [ "download" = "$(cat out/ranged.txt)" ]
+  grep "downloading bytes 8-15 of $ranged_oid" checkout.log
+  grep "ignored byte range" checkout.log && exit 1
+  refute_local_object "$ranged_oid"
+
+  git lfs checkout --to tail.txt --bytes 27-100 ranged.dat
+  [ "then some" = "$(cat tail.txt)" ]
+
+  # The server ignores the Range header for this object.
+  GIT_TRACE=1 git lfs checkout --to plain.txt --bytes 3- plain.dat 2>&1 | tee checkout.log
+  [ "ranges for this object" = "$(cat plain.txt)" ]
+  grep "server ignored byte range for $plain_oid" checkout.log
+  refute_local_object "$plain_oid"
+
+  git lfs checkout --to strict.txt --bytes 0-1 --range-only plain.dat 2>&1 | tee checkout.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected checkout --range-only to fail"
+    exit 1
+  fi
+  grep "server ignored the byte range" checkout.log
+  [ ! -e strict.txt ]
+
+  git lfs checkout --to bad.txt --bytes 100-200 plain.dat 2>&1 | tee checkout.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected checkout of a range past the end to fail"
+    exit 1
+  fi
+  grep "Invalid --bytes" checkout.log
+
+  # Objects in the local store aren't downloaded.
+  git lfs fetch origin main
+  GIT_TRACE=1 git lfs checkout --to local.txt --bytes 0-1 plain.dat 2>&1 | tee checkout.log
+  [ "no" = "$(cat local.txt)" ]
+  grep "downloading bytes" checkout.log && exit 1
+  true
+)
+end_test
+
+begin_test "checkout: --write-meta"
+(
+  set -e
+
+  reponame="checkout-write-meta"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+
+  typed="storage-download-content-type"
+  typed_oid="$(calc_oid "$typed")"
+  printf "base" > typed.dat
+  git add .gitattributes typed.dat
+  git commit -m "base"
+  git push origin main
+
+  git checkout -b theirs
+  printf "%s" "$typed" > typed.dat
+  git add typed.dat
+  git commit -m "theirs"
+  git push origin theirs
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$reponame-clone"
+
+  git lfs checkout --write-meta typed.dat 2>&1 | tee checkout.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected checkout --write-meta without --to to fail"
+    exit 1
+  fi
+  grep -- "--write-meta requires --to" checkout.log
+
+  git checkout -b ours
+  printf "ours" > typed.dat
+  git add typed.dat
+  git commit -m "ours"
+
+  # This will cause a conflict.
+  ! git merge origin/theirs
+
+  # The server sends the object with its own Content-Type.
+  git lfs checkout --to range.txt --bytes 0-6 --theirs --write-meta typed.dat
+  [ "storage" = "$(cat range.txt)" ]
+  grep '"content_type":"image/x-lfs-test"' range.txt.meta
+  refute_local_object "$typed_oid"
+
+  git lfs checkout --to theirs.txt --theirs --write-meta typed.dat
+  [ "$typed" = "$(cat theirs.txt)" ]
+  grep "\"oid\":\"$typed_oid\"" theirs.txt.meta
+  grep '"content_type":"image/x-lfs-test"' theirs.txt.meta
+  assert_local_object "$typed_oid" "${#typed}"
+
+  # Objects which are already present have no Content-Type.
+  git lfs checkout --to ours.txt --ours --write-meta typed.dat
+  [ "ours" = "$(cat ours.txt)" ]
+  grep '"name":"typed.dat"' ours.txt.meta
+  grep "content_type" ours.txt.meta && exit 1
+  true
+)
+end_test
+
+begin_test "checkout: working tree files are separate from the objects"
+(
+  set -e
+
+  reponame="checkout-clone-objects"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+
+  contents="object contents"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  rm a.dat
+  GIT_TRACE=1 git lfs checkout a.dat 2>&1 | tee checkout.log
+  [ "$contents" = "$(cat a.dat)" ]
+
+  # Whether or not the file was cloned from the object, changing one must
+  # leave the other as it was.
+  printf "changed" >> a.dat
+  assert_local_object "$oid" "${#contents}"
+  objdir="$(git lfs env | grep LocalMediaDir | cut -d= -f2)"
+  [ "$oid" = "$(calc_oid_file "$objdir/${oid:0:2}/${oid:2:2}/$oid")" ]
+
+  if grep "smudge: cloned" checkout.log; then
+    echo "cloned the object into the working tree"
+  fi
+)
+end_test
+
+begin_test "checkout: --include and --exclude"
+(
+  set -e
+
+  reponame="checkout-include-exclude"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+
+  mkdir -p a/b c
+  printf "a" > a/a.dat
+  printf "b" > a/b/b.dat
+  printf "skip" > a/b/skip.dat
+  printf "c" > c/c.dat
+  git add .gitattributes a c
+  git commit -m "add files"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$reponame-clone"
+
+  # With a filter, missing objects of the matching files are fetched.
+  git lfs checkout --include "a/**" --exclude "skip.dat" 2>&1 | tee checkout.log
+  grep "Checked out 2 of 2 file(s) matching --include and --exclude" checkout.log
+  grep "Skipped checkout" checkout.log && exit 1
+
+  [ "a" = "$(cat a/a.dat)" ]
+  [ "b" = "$(cat a/b/b.dat)" ]
+  git lfs pointer --check --file a/b/skip.dat
+  git lfs pointer --check --file c/c.dat
+  refute_local_object "$(calc_oid "skip")"
+  refute_local_object "$(calc_oid "c")"
+
+  # Files already checked out are left as they are.
+  git lfs checkout -I "c/**"
+  [ "a" = "$(cat a/a.dat)" ]
+  [ "c" = "$(cat c/c.dat)" ]
+  git lfs pointer --check --file a/b/skip.dat
+
+  [ -z "$(git status --porcelain --untracked-files=no)" ]
+)
+end_test
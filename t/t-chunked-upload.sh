@@ -0,0 +1,92 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+begin_test "chunked upload"
+(
+  set -e
+
+  # this repo name is the indicator to the server to use chunked uploads
+  reponame="test-chunked-upload"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git config lfs.chunkedtransfers true
+  git config lfs.transfer.chunksize 4
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  contents="send-verify-action"
+  contents_oid=$(calc_oid "$contents")
+
+  printf "%s" "$contents" > a.dat
+  git add a.dat
+  git add .gitattributes
+  git commit -m "add a.dat" 2>&1 | tee commit.log
+  GIT_TRACE=1 GIT_CURL_VERBOSE=1 git push origin main 2>&1 | tee push.log
+  grep "xfer: chunked upload of \"$contents_oid\" in 5 parts" push.log
+  grep "Content-Range: bytes 16-17/18" push.log
+
+  assert_server_object "$reponame" "$contents_oid"
+)
+end_test
+
+begin_test "chunked upload resumes with the parts the server is missing"
+(
+  set -e
+
+  reponame="test-chunked-upload-resume"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git config lfs.chunkedtransfers true
+  git config lfs.transfer.chunksize 8
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  # this string announces to the server that we want it to fail the second
+  # part of the object once
+  contents="storage-chunked-upload-retry"
+  contents_oid=$(calc_oid "$contents")
+
+  printf "%s" "$contents" > a.dat
+  git add a.dat
+  git add .gitattributes
+  git commit -m "add a.dat" 2>&1 | tee commit.log
+  GIT_TRACE=1 GIT_CURL_VERBOSE=1 git push origin main 2>&1 | tee push.log
+  grep "xfer: chunked upload of \"$contents_oid\" in 4 parts" push.log
+  grep "HTTP: 500" push.log
+  grep "xfer: chunked upload of \"$contents_oid\" resuming with 3 of 4 parts already uploaded" push.log
+  [ 2 -eq "$(grep -c "Content-Range: bytes 8-15/28" push.log)" ]
+  [ 1 -eq "$(grep -c "Content-Range: bytes 0-7/28" push.log)" ]
+
+  assert_server_object "$reponame" "$contents_oid"
+)
+end_test
+
+begin_test "chunked upload falls back to basic when the server doesn't choose it"
+(
+  set -e
+
+  reponame="chunked-upload-unsupported"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git config lfs.chunkedtransfers true
+
+  git lfs track "*.dat"
+  contents="a"
+  contents_oid=$(calc_oid "$contents")
+  printf "%s" "$contents" > a.dat
+  git add a.dat .gitattributes
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 GIT_CURL_VERBOSE=1 git push origin main 2>&1 | tee push.log
+  grep '"transfers":\[[a-z",-]*"chunked"' push.log
+  grep "tq: starting transfer adapter \"basic\"" push.log
+
+  assert_server_object "$reponame" "$contents_oid"
+)
+end_test
@@ -87,3 +87,35 @@ begin_test "clean stdin"
   fi
 )
 end_test
+
+begin_test "clean with lfs.pointer.version"
+(
+  set -e
+
+  reponame="clean-pointer-version"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.dat"
+  printf "contents" > a.dat
+  oid="$(calc_oid "contents")"
+
+  git config lfs.pointer.version "https://hawser.github.com/spec/v1"
+  [ "$(pointer "$oid" 8 "https://hawser.github.com/spec/v1")" = "$(git lfs clean < a.dat)" ]
+
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  [ "$(pointer "$oid" 8 "https://hawser.github.com/spec/v1")" = "$(git cat-file -p :a.dat)" ]
+
+  # The pointer is still read as one.
+  rm a.dat
+  git checkout -- a.dat
+  [ "contents" = "$(cat a.dat)" ]
+  [ -z "$(git status --porcelain)" ]
+
+  git config lfs.pointer.version "https://example.com/spec/v2"
+  git lfs clean < a.dat 2>&1 | tee clean.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "unknown lfs.pointer.version" clean.log
+)
+end_test
@@ -223,6 +223,32 @@ begin_test "multiple config"
 end_test
 
 
+begin_test "url alias for derived endpoint"
+(
+  set -e
+
+  mkdir url-alias-derived
+  cd url-alias-derived
+
+  git init
+
+  git config url."https://mirror.example.com/".insteadOf "https://example.com/"
+
+  git remote add origin https://example.com/foo/bar.git
+  [ "https://mirror.example.com/foo/bar.git" = "$(git ls-remote --get-url origin)" ]
+  git lfs env | tee env.log
+  grep "Endpoint=https://mirror.example.com/foo/bar.git/info/lfs (auth=none)" env.log
+
+  # Git never sees the HTTPS URL derived from an SSH remote, but it's
+  # rewritten all the same.
+  git remote set-url origin ssh://git@example.com/foo/bar.git
+  [ "ssh://git@example.com/foo/bar.git" = "$(git ls-remote --get-url origin)" ]
+  git lfs env | tee env.log
+  grep "Endpoint=https://mirror.example.com/foo/bar.git/info/lfs (auth=none)" env.log
+  grep "SSH=git@example.com:foo/bar.git" env.log
+)
+end_test
+
 begin_test "url alias must be prefix"
 (
   set -e
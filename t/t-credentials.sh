@@ -521,3 +521,93 @@ begin_test "credentials from remote.origin.url"
   git lfs fsck
 )
 end_test
+
+begin_test "credentials from environment"
+(
+  set -e
+
+  # prevent prompts on Windows particularly
+  export SSH_ASKPASS=
+
+  reponame="credentials-from-environment"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  echo "push a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  hostkey="$(echo "$GITSERVER" | sed -e 's#^[a-z]*://##' | tr 'a-z.:-' 'A-Z___')"
+  export "GIT_LFS_${hostkey}_USERNAME=netrcuser"
+  export "GIT_LFS_${hostkey}_TOKEN=env-token-value"
+
+  GIT_TRACE=1 GIT_CURL_VERBOSE=1 git lfs push origin main 2>&1 | tee push.log
+  grep "Uploading LFS objects: 100% (1/1), 7 B" push.log
+
+  grep "env: git credential fill .* from GIT_LFS_${hostkey}_TOKEN" push.log
+  grep "env: git credential approve" push.log
+
+  # the credential helper is neither asked for, nor given, the credentials
+  [ "0" -eq "$(grep -c "creds: git credential" push.log)" ]
+
+  # and the token is never logged
+  [ "0" -eq "$(grep -c "env-token-value" push.log)" ]
+
+  assert_server_object "$reponame" "$(calc_oid_file a.dat)"
+)
+end_test
+
+begin_test "credentials cached between commands with lfs.credcachettl"
+(
+  set -e
+
+  git credential-cache exit 2>/dev/null || {
+    echo "skip: git credential-cache is not supported"
+    exit 0
+  }
+
+  reponame="credentials-cache-ttl-reject-bad-creds"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "cached" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$reponame-clone"
+  git config lfs.credcachettl 60
+  git config lfs.$GITSERVER/$reponame.git/info/lfs.access basic
+
+  GIT_TRACE=1 git lfs fetch > fetch.log 2>&1
+  grep "creds: git credential fill" fetch.log
+  grep "creds: git credential-cache" fetch.log && exit 1
+
+  # The next command finds the credentials in the cache.
+  rm -rf .git/lfs/objects
+  GIT_TRACE=1 git lfs fetch > fetch.log 2>&1
+  grep "creds: git credential-cache" fetch.log
+  grep "creds: git credential fill" fetch.log && exit 1
+  assert_local_object "$(calc_oid "cached")" 6
+
+  # Credentials which the server rejects are erased from the cache, and the
+  # credential helper asked for them again.
+  git credential-cache --timeout=60 store <<-EOF
+	protocol=http
+	host=$(echo "$GITSERVER" | sed -e 's|^http://||')
+	path=$reponame
+	username=user
+	password=wrong
+	EOF
+  rm -rf .git/lfs/objects
+  GIT_TRACE=1 git lfs fetch > fetch.log 2>&1
+  grep "creds: git credential-cache" fetch.log
+  grep "creds: git credential fill" fetch.log
+  assert_local_object "$(calc_oid "cached")" 6
+
+  git credential-cache exit
+)
+end_test
@@ -285,3 +285,83 @@ begin_test "custom-transfer-standalone-urlmatch"
   git lfs fsck
 )
 end_test
+
+begin_test "custom-transfer-standalone-locking"
+(
+  set -e
+
+  # setup a git repo to be used as a local repo, not remote
+  reponame="test-custom-transfer-standalone-locking"
+  setup_remote_repo "$reponame"
+
+  # clone directly, not through lfstest-gitserver
+  clone_repo_url "$REMOTEDIR/$reponame.git" $reponame
+
+  git config lfs.customtransfer.testcustom.path lfstest-standalonecustomadapter
+  git config lfs.customtransfer.testcustom.concurrent false
+  git config lfs.customtransfer.testcustom.locking true
+  git config lfs.standalonetransferagent testcustom
+  export TEST_STANDALONE_BACKUP_PATH="$(pwd)/test-custom-transfer-standalone-backup"
+  mkdir -p $TEST_STANDALONE_BACKUP_PATH
+  rm -rf $TEST_STANDALONE_BACKUP_PATH/*
+
+  git lfs track "*.dat"
+  echo "contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git lfs lock a.dat 2>&1 | tee lock.log
+  [ ${PIPESTATUS[0]} = "0" ]
+  grep "Locked a.dat" lock.log
+  grep "locking: starting custom transfer agent \"testcustom\" for locks" lock.log
+  grep "locking\[testcustom\]: Received lock request for a.dat" lock.log
+  grep "HTTP:" lock.log && false
+
+  git lfs locks 2>&1 | tee locks.log
+  grep "a.dat" locks.log
+  grep "Standalone User" locks.log
+
+  git lfs lock a.dat 2>&1 | tee lock.log
+  [ ${PIPESTATUS[0]} != "0" ]
+  grep "lock already created" lock.log
+
+  echo "more contents" > a.dat
+  git commit -am "update a.dat"
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  [ ${PIPESTATUS[0]} = "0" ]
+  grep "locking\[testcustom\]: Received list request (verify: true)" push.log
+
+  GIT_TRACE=1 git lfs unlock a.dat 2>&1 | tee unlock.log
+  [ ${PIPESTATUS[0]} = "0" ]
+  grep "Unlocked a.dat" unlock.log
+  grep "locking: shutting down custom transfer agent \"testcustom\"" unlock.log
+
+  [ "$(git lfs locks)" = "" ]
+)
+end_test
+
+begin_test "custom-transfer-standalone-locking-unsupported"
+(
+  set -e
+
+  reponame="test-custom-transfer-standalone-locking-unsupported"
+  setup_remote_repo "$reponame"
+  clone_repo_url "$REMOTEDIR/$reponame.git" $reponame
+
+  git config lfs.customtransfer.testcustom.path lfstest-standalonecustomadapter
+  git config lfs.customtransfer.testcustom.locking true
+  git config lfs.standalonetransferagent testcustom
+  export TEST_STANDALONE_BACKUP_PATH="$(pwd)/test-custom-transfer-standalone-backup"
+  export TEST_STANDALONE_NO_LOCKING=1
+  mkdir -p $TEST_STANDALONE_BACKUP_PATH
+
+  # An agent which doesn't advertise that it handles locks is only asked to
+  # initialize, and the locks API is used instead, which a standalone remote
+  # doesn't have.
+  GIT_TRACE=1 git lfs locks 2>&1 | tee locks.log
+  [ ${PIPESTATUS[0]} != "0" ]
+  grep "locking: custom transfer agent \"testcustom\" does not handle locks, using the LFS API" locks.log
+  grep "locking\[testcustom\]: Received list request" locks.log && false
+  grep "Error while retrieving locks" locks.log
+)
+end_test
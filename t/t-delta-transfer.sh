@@ -0,0 +1,183 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+# make_versions writes two versions of a.dat, each of which is committed, and
+# which share all but a few bytes, in the files v1 and v2.
+make_versions() {
+  base64 /dev/urandom | head -c 65536 > v1
+  (head -c 30000 v1 && printf "changed" && tail -c +30008 v1 && printf "appended") > v2
+}
+
+begin_test "delta transfer: upload a delta from an earlier version"
+(
+  set -e
+
+  # this repo name is the indicator to the server to use delta transfers
+  reponame="test-delta-transfer-upload"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git config lfs.deltatransfers true
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "track *.dat"
+
+  make_versions
+  v1_oid="$(calc_oid_file v1)"
+  v2_oid="$(calc_oid_file v2)"
+
+  cp v1 a.dat
+  git add a.dat
+  git commit -m "add a.dat"
+
+  # There is no earlier version to take a delta from.
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "delta upload" push.log && exit 1
+  assert_server_object "$reponame" "$v1_oid"
+
+  cp v2 a.dat
+  git add a.dat
+  git commit -m "change a.dat"
+
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "xfer: sending delta upload POST request for \"$v2_oid\" with 1 base(s)" push.log
+  grep "xfer: delta upload of \"$v2_oid\" from \"$v1_oid\"" push.log
+  assert_server_object "$reponame" "$v2_oid"
+
+  # The object the server made from the delta is the one pushed.
+  cd ..
+  clone_repo "$reponame" "$reponame-clone"
+  cmp a.dat ../$reponame/v2
+)
+end_test
+
+begin_test "delta transfer: download a delta from an earlier version"
+(
+  set -e
+
+  reponame="test-delta-transfer-download"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "track *.dat"
+
+  make_versions
+  v1_oid="$(calc_oid_file v1)"
+  v2_oid="$(calc_oid_file v2)"
+
+  cp v1 a.dat
+  git add a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  cd ..
+  clone_repo "$reponame" "$reponame-clone"
+  git config lfs.deltatransfers true
+  assert_local_object "$v1_oid" 65536
+
+  cd "../$reponame"
+  cp v2 a.dat
+  git add a.dat
+  git commit -m "change a.dat"
+  git push origin main
+
+  cd "../$reponame-clone"
+  git fetch origin
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git lfs fetch origin origin/main 2>&1 | tee fetch.log
+  grep "xfer: delta download of \"$v2_oid\" from \"$v1_oid\"" fetch.log
+  assert_local_object "$v2_oid" 65544
+  git reset --hard origin/main
+  cmp a.dat "../$reponame/v2"
+
+  # Without a local base, the object is downloaded whole.
+  rm -rf .git/lfs/objects
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git lfs pull 2>&1 | tee pull.log
+  grep "delta download" pull.log && exit 1
+  assert_local_object "$v2_oid" 65544
+)
+end_test
+
+begin_test "delta transfer: download whole when the local base is corrupt"
+(
+  set -e
+
+  reponame="test-delta-transfer-corrupt-base"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "track *.dat"
+
+  make_versions
+  v1_oid="$(calc_oid_file v1)"
+  v2_oid="$(calc_oid_file v2)"
+
+  cp v1 a.dat
+  git add a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  cd ..
+  clone_repo "$reponame" "$reponame-clone"
+  git config lfs.deltatransfers true
+
+  cd "../$reponame"
+  cp v2 a.dat
+  git add a.dat
+  git commit -m "change a.dat"
+  git push origin main
+
+  cd "../$reponame-clone"
+  # The local base keeps its size, but not its contents.
+  base64 /dev/urandom | head -c 65536 > ".git/lfs/objects/${v1_oid:0:2}/${v1_oid:2:2}/$v1_oid"
+
+  git fetch origin
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git lfs fetch origin origin/main 2>&1 | tee fetch.log
+  grep "xfer: delta download of \"$v2_oid\" from \"$v1_oid\"" fetch.log
+  grep "xfer: delta download of \"$v2_oid\" failed, retrying it whole" fetch.log
+  assert_local_object "$v2_oid" 65544
+)
+end_test
+
+begin_test "delta transfer: walk history once for all objects"
+(
+  set -e
+
+  reponame="test-delta-transfer-walk-once"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+  git config lfs.deltatransfers true
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "track *.dat"
+
+  make_versions
+  (printf "b" && cat v1) > b1
+  (printf "b" && cat v2) > b2
+  a2_oid="$(calc_oid_file v2)"
+  b2_oid="$(calc_oid_file b2)"
+
+  cp v1 a.dat
+  cp b1 b.dat
+  git add a.dat b.dat
+  git commit -m "add a.dat and b.dat"
+  git push origin main
+
+  cp v2 a.dat
+  cp b2 b.dat
+  git add a.dat b.dat
+  git commit -m "change a.dat and b.dat"
+
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "xfer: delta upload of \"$a2_oid\"" push.log
+  grep "xfer: delta upload of \"$b2_oid\"" push.log
+  [ "1" -eq "$(grep -c "exec: git .* 'log' .* '--all'$" push.log)" ]
+)
+end_test
@@ -0,0 +1,129 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+begin_test "doctor passes in a configured repository"
+(
+  set -e
+
+  reponame="doctor-pass"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs doctor 2>&1 | tee doctor.log
+  if [ "0" -ne "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs doctor\` to succeed ..."
+    exit 1
+  fi
+
+  grep "PASS  filters" doctor.log
+  grep "PASS  endpoint" doctor.log
+  grep "PASS  clock" doctor.log
+  grep "PASS  storage" doctor.log
+  grep "PASS  disk" doctor.log
+  [ "0" -eq "$(grep -c "FAIL" doctor.log)" ]
+)
+end_test
+
+begin_test "doctor --json"
+(
+  set -e
+
+  reponame="doctor-json"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git config lfs.concurrenttransfers lots
+
+  git lfs doctor --json > doctor.json
+  grep '"status":"WARN"' doctor.json
+  grep '{"name":"config","status":"WARN","message":"lfs.concurrenttransfers = \\"lots\\" is not an integer and will be ignored"}' doctor.json
+)
+end_test
+
+begin_test "doctor fails with missing filters"
+(
+  set -e
+
+  reponame="doctor-no-filters"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git -c filter.lfs.process= -c filter.lfs.clean= lfs doctor 2>&1 | tee doctor.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs doctor\` to fail ..."
+    exit 1
+  fi
+
+  grep "FAIL  filters" doctor.log
+  grep 'filter.lfs.clean, filter.lfs.process not set; run `git lfs install`' doctor.log
+)
+end_test
+
+begin_test "doctor fails with unreachable endpoint"
+(
+  set -e
+
+  reponame="doctor-unreachable"
+  git init "$reponame"
+  cd "$reponame"
+
+  git config lfs.url "http://127.0.0.1:1/"
+
+  git lfs doctor 2>&1 | tee doctor.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs doctor\` to fail ..."
+    exit 1
+  fi
+
+  grep "FAIL  endpoint" doctor.log
+  grep "batch request to http://127.0.0.1:1/ failed" doctor.log
+)
+end_test
+
+begin_test "doctor warns of clock skew"
+(
+  set -e
+
+  reponame="doctor-clock-skew"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs doctor 2>&1 | tee doctor.log
+  grep "WARN  clock" doctor.log
+  grep "local clock differs from the server's by 1h0m[01]s; authentication may fail" doctor.log
+
+  git lfs doctor --json > doctor.json
+  grep '"name":"clock","status":"WARN",.*"skew_seconds":360[01]' doctor.json
+)
+end_test
+
+begin_test "doctor fails with unwritable object store"
+(
+  set -e
+
+  # Windows lacks POSIX permissions.
+  [ "$IS_WINDOWS" -eq 1 ] && exit 0
+
+  # Root is exempt from permissions.
+  [ "$(id -u)" -eq 0 ] && exit 0
+
+  reponame="doctor-unwritable-storage"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  mkdir -p .git/lfs/objects
+  chmod 500 .git/lfs/objects
+
+  git lfs doctor 2>&1 | tee doctor.log
+  status="${PIPESTATUS[0]}"
+  chmod 700 .git/lfs/objects
+  if [ "0" -eq "$status" ]; then
+    echo >&2 "fatal: expected \`git lfs doctor\` to fail ..."
+    exit 1
+  fi
+
+  grep "FAIL  storage" doctor.log
+  grep "cannot write to .*/.git/lfs/objects: permission denied (.* has mode -r-x------); make sure the current user may write to it" doctor.log
+)
+end_test
@@ -4,7 +4,9 @@
 
 envInitConfig='git config filter.lfs.process = "git-lfs filter-process"
 git config filter.lfs.smudge = "git-lfs smudge -- %f"
-git config filter.lfs.clean = "git-lfs clean -- %f"'
+git config filter.lfs.clean = "git-lfs clean -- %f"
+git config filter.lfs.required = "true"
+FilterMode=process'
 
 unset_vars() {
     # If set, these will cause the test to fail.
@@ -51,8 +53,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -104,8 +106,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$endpoint" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -164,8 +166,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$endpoint" "$endpoint2" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -222,8 +224,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$endpoint" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -281,8 +283,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -342,8 +344,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -403,8 +405,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -472,8 +474,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -528,8 +530,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -582,12 +584,14 @@ PruneRemoteName=origin
 LfsStorageDir=lfs
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 git config filter.lfs.process = ""
 git config filter.lfs.smudge = ""
 git config filter.lfs.clean = ""
+git config filter.lfs.required = ""
+FilterMode=none
 ' "$(git lfs version)" "$(git version)" "$mediaDir5" "$tempDir5" "$envVars")
   actual5=$(GIT_DIR=$gitDir GIT_WORK_TREE=a/b git lfs env \
             | grep -v "^GIT_EXEC_PATH=")
@@ -618,8 +622,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -651,8 +655,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -697,8 +701,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 " "$(git lfs version)" "$(git version)" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -777,8 +781,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -810,8 +814,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -843,8 +847,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVarsEnabled" "$envInitConfig")
@@ -906,8 +910,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file,supertransfer
-UploadTransfers=basic,lfs-standalone-file,supertransfer,tus
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp,supertransfer
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp,supertransfer,tus
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -966,8 +970,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$endpoint" "$endpoint2" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -1031,8 +1035,8 @@ PruneRemoteName=origin
 LfsStorageDir=%s
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 %s
 %s
 ' "$(git lfs version)" "$(git version)" "$endpoint" "$endpoint2" "$localwd" "$localgit" "$localgitstore" "$localmedia" "$tempdir" "$lfsstorage" "$envVars" "$envInitConfig")
@@ -1078,3 +1082,63 @@ EOF
   grep 'WARNING.*same alias' test.log
 )
 end_test
+
+begin_test "env reports the filter mode"
+(
+  set -e
+
+  reponame="env-filter-mode"
+  git init $reponame
+  cd $reponame
+
+  git lfs env 2>env.err | tee env.log
+  grep "FilterMode=process" env.log
+  [ ! -s env.err ]
+
+  git lfs env --json | tee env.json
+  grep '"mode": "process"' env.json
+  grep '"required": true' env.json
+  grep '"process": "git-lfs filter-process"' env.json
+
+  git config filter.lfs.process ""
+  git lfs env 2>env.err | tee env.log
+  grep "FilterMode=clean-smudge" env.log
+  grep "filter.lfs.process is not set" env.err
+
+  git lfs env --json 2>/dev/null | tee env.json
+  grep '"mode": "clean-smudge"' env.json
+  grep '"warning": "filter.lfs.process is not set' env.json
+)
+end_test
+
+begin_test "env --check-clock"
+(
+  set -e
+
+  reponame="env-check-clock"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs env --check-clock 2>&1 | tee env.log
+  grep "^ClockSkew=" env.log
+  grep "warning: local clock" env.log && exit 1
+
+  git lfs env --json --check-clock > env.json
+  grep '"skew_seconds":' env.json
+  grep "\"remote\": \"origin\"" env.json
+
+  # Without --check-clock, no request is made.
+  git lfs env --json | grep '"clock"' && exit 1
+
+  git config lfs.url "$GITSERVER/clock-skew.git/info/lfs"
+  git lfs env --check-clock 2>&1 | tee env.log
+  grep "warning: local clock differs from that of $GITSERVER/clock-skew.git/info/lfs by 1h0m[01]s" env.log
+
+  git lfs env --json --check-clock > env.json
+  grep '"skew_seconds": 360[01]' env.json
+
+  git config lfs.url "http://127.0.0.1:1/"
+  git lfs env --check-clock 2>&1 | tee env.log
+  grep "warning: unable to determine the time of the Git LFS server" env.log
+)
+end_test
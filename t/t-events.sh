@@ -0,0 +1,170 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+begin_test "events: lock and unlock"
+(
+  set -e
+
+  reponame="events-lock-unlock"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+
+  GIT_LFS_EVENTS_FD=3 git lfs lock --json "a.dat" 3>events.log | tee lock.json
+  id=$(assert_lock lock.json a.dat)
+
+  grep '"event":"lock-acquired"' events.log
+  grep '"path":"a.dat"' events.log
+  grep "\"lock_id\":\"$id\"" events.log
+  ! grep "lock-acquired" lock.json
+
+  GIT_LFS_EVENTS_FD=3 git lfs unlock "a.dat" 3>events.log
+  grep '"event":"lock-released"' events.log
+  grep "\"lock_id\":\"$id\"" events.log
+)
+end_test
+
+begin_test "events: smudge and transfer"
+(
+  set -e
+
+  reponame="events-smudge-transfer"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents="contents"
+  contents_oid="$(calc_oid "$contents")"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_LFS_EVENTS_FD=3 git push origin main 3>push-events.log
+  grep '"event":"transfer-finished"' push-events.log
+  grep '"direction":"upload"' push-events.log
+  grep "\"oid\":\"$contents_oid\"" push-events.log
+
+  cd ..
+  GIT_LFS_EVENTS_FD=3 git clone "$GITSERVER/$reponame" "$reponame-clone" 3>events.log
+
+  grep '"event":"transfer-finished"' events.log
+  grep '"direction":"download"' events.log
+  grep '"event":"smudge-finished"' events.log
+  grep '"path":"a.dat"' events.log
+  grep "\"oid\":\"$contents_oid\"" events.log
+)
+end_test
+
+begin_test "events: missing object"
+(
+  set -e
+
+  reponame="events-missing-object"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents="missing"
+  contents_oid="$(calc_oid "$contents")"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  rm -rf .git/lfs/objects
+
+  GIT_LFS_EVENTS_FD=3 git lfs fetch 3>events.log || true
+  grep '"event":"object-missing"' events.log
+  grep "\"oid\":\"$contents_oid\"" events.log
+)
+end_test
+
+begin_test "events: --json-progress"
+(
+  set -e
+
+  reponame="events-json-progress"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents="json progress"
+  contents_oid="$(calc_oid "$contents")"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  git lfs push --json-progress origin main 2>push.log
+  grep '"event":"transfer-bytes"' push.log | grep "\"oid\":\"$contents_oid\"" | grep '"bytes":13' | grep '"size":13'
+  grep '"event":"transfer-finished"' push.log | grep '"direction":"upload"'
+  grep '"event":"transfer-progress"' push.log | grep '"total_files":1'
+  ! grep "Uploading LFS objects" push.log
+
+  rm -rf .git/lfs/objects
+  git lfs fetch --json-progress 2>fetch.log
+  grep '"event":"transfer-bytes"' fetch.log | grep "\"oid\":\"$contents_oid\"" | grep '"direction":"download"'
+  grep '"event":"transfer-finished"' fetch.log
+  ! grep "Downloading LFS objects" fetch.log
+
+  rm a.dat
+  git lfs checkout --json-progress 2>checkout.log >checkout.out
+  grep '"event":"smudge-finished"' checkout.log | grep '"path":"a.dat"'
+  grep '"event":"transfer-progress"' checkout.log | grep '"direction":"checkout"'
+  [ ! -s checkout.out ]
+  [ "$contents" = "$(cat a.dat)" ]
+)
+end_test
+
+begin_test "events: GIT_LFS_PROGRESS_FORMAT=json"
+(
+  set -e
+
+  reponame="events-progress-format"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents="progress format"
+  contents_oid="$(calc_oid "$contents")"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_LFS_PROGRESS_FORMAT=json git push origin main 2>push.log
+  grep '"event":"transfer-bytes"' push.log | grep "\"oid\":\"$contents_oid\""
+  ! grep "Uploading LFS objects" push.log
+
+  rm -rf .git/lfs/objects a.dat
+  GIT_LFS_PROGRESS_FORMAT=json git lfs pull 2>pull.log
+  grep '"event":"transfer-bytes"' pull.log | grep '"direction":"download"'
+  grep '"event":"smudge-finished"' pull.log | grep "\"oid\":\"$contents_oid\""
+  ! grep "Downloading LFS objects" pull.log
+  [ "$contents" = "$(cat a.dat)" ]
+)
+end_test
+
+begin_test "events: retried transfer"
+(
+  set -e
+
+  reponame="events-retried-transfer"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents="storage-upload-retry"
+  contents_oid="$(calc_oid "$contents")"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  git config --local lfs.transfer.maxretries 3
+
+  git lfs push --json-progress origin main 2>push.log
+  grep '"event":"transfer-retried"' push.log | grep "\"oid\":\"$contents_oid\"" | grep '"retries":1' | grep '"error":"Fatal error: Server error'
+  grep '"event":"transfer-retried"' push.log | grep '"retries":2'
+  grep '"event":"transfer-finished"' push.log | grep '"retries":2'
+)
+end_test
@@ -0,0 +1,71 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+push_digest_object() {
+  local reponame="$1"
+  local contents="$2"
+
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  git push origin main
+  assert_server_object "$reponame" "$(calc_oid "$contents")"
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$reponame-fetch"
+  cd "$reponame-fetch"
+}
+
+begin_test "fetch with lfs.transfer.verifydigests accepts matching digest"
+(
+  set -e
+
+  contents="storage-download-digest"
+  push_digest_object "fetch-digests-match" "$contents"
+
+  git config lfs.transfer.verifydigests true
+  git lfs fetch
+
+  assert_local_object "$(calc_oid "$contents")" "${#contents}"
+)
+end_test
+
+begin_test "fetch with lfs.transfer.verifydigests rejects mismatched digest"
+(
+  set -e
+
+  contents="storage-download-bad-digest"
+  push_digest_object "fetch-digests-mismatch" "$contents"
+  oid="$(calc_oid "$contents")"
+
+  git config lfs.transfer.verifydigests true
+  git config lfs.transfer.maxretries 1
+  git lfs fetch 2>&1 | tee fetch.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs fetch\` to fail ..."
+    exit 1
+  fi
+
+  grep "digest mismatch for $oid" fetch.log
+  refute_local_object "$oid"
+)
+end_test
+
+begin_test "fetch without lfs.transfer.verifydigests ignores digest"
+(
+  set -e
+
+  contents="storage-download-bad-digest"
+  push_digest_object "fetch-digests-disabled" "$contents"
+
+  git lfs fetch
+
+  assert_local_object "$(calc_oid "$contents")" "${#contents}"
+)
+end_test
@@ -197,3 +197,32 @@ begin_test "fetch-recent remote refs"
   refute_local_object "$oid1"
 )
 end_test
+
+begin_test "fetch-recent with --recent-remote"
+(
+  set -e
+
+  cd clone
+  rm -rf .git/lfs/objects
+
+  # Only the "upstream" remote has a tracking ref for other_branch.
+  git config lfs.fetchrecentremoterefs false
+  git update-ref -d refs/remotes/origin/other_branch
+  git remote add upstream "$GITSERVER/$reponame"
+  git fetch upstream
+
+  git lfs fetch --recent origin
+  refute_local_object "$oid4"
+
+  git lfs fetch --recent --recent-remote=upstream origin
+  assert_local_object "$oid4" "${#content4}"
+  refute_local_object "$oid0"
+  refute_local_object "$oid1"
+
+  git lfs fetch --recent-remote=upstream origin 2>&1 | tee fetch.log
+  grep "Cannot use --recent-remote without --recent" fetch.log
+
+  git lfs fetch --recent --recent-remote=missing origin 2>&1 | tee fetch.log
+  grep "Invalid remote name \"missing\"" fetch.log
+)
+end_test
@@ -120,6 +120,29 @@ begin_test "fetch with remote and branches"
 )
 end_test
 
+begin_test "fetch with revision range"
+(
+  set -e
+  cd clone
+  rm -rf .git/lfs/objects
+
+  git lfs fetch origin main..newbranch 2>&1 | tee fetch.log
+  grep "fetch: Fetching range main..newbranch" fetch.log
+  refute_local_object "$contents_oid"
+  assert_local_object "$b_oid" 1
+
+  rm -rf .git/lfs/objects
+
+  git lfs fetch --exclude="b*" origin main...newbranch
+  refute_local_object "$contents_oid"
+  refute_local_object "$b_oid"
+
+  git lfs fetch origin main newbranch..main
+  assert_local_object "$contents_oid" 1
+  refute_local_object "$b_oid"
+)
+end_test
+
 begin_test "fetch with main commit sha1"
 (
   set -e
@@ -636,6 +659,165 @@ begin_test "fetch fails when LFS directory has wrong permissions"
   chmod 400 .git/lfs/objects
 
   git lfs fetch 2>&1 | tee fetch.log
-  grep "error trying to create local storage directory" fetch.log
+  grep "cannot write to .*: permission denied (.* has mode -r--------)" fetch.log
+)
+end_test
+
+begin_test "fetch with --max-size"
+(
+  set -e
+
+  reponame="fetch-max-size"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "small" > small.dat
+  printf "much larger contents" > large.dat
+  git add .gitattributes small.dat large.dat
+  git commit -m "add files"
+  git push origin main
+
+  rm -rf .git/lfs/objects
+
+  git lfs fetch --max-size=10 2>&1 | tee fetch.log
+  grep "Skipped 1 object(s) larger than 10 B (20 B in total)" fetch.log
+  assert_local_object "$(calc_oid "small")" 5
+  refute_local_object "$(calc_oid "much larger contents")"
+
+  git lfs fetch --max-size=1KB 2>&1 | tee fetch.log
+  grep "Skipped" fetch.log && exit 1
+  assert_local_object "$(calc_oid "much larger contents")" 20
+
+  git lfs fetch --max-size=nonsense 2>&1 | tee fetch.log
+  grep "cannot parse --max-size=<size>" fetch.log
+)
+end_test
+
+begin_test "fetch --dry-run"
+(
+  set -e
+
+  reponame="fetch-dry-run"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "remote" > remote.dat
+  printf "cached" > cached.dat
+  git add .gitattributes remote.dat cached.dat
+  git commit -m "add files"
+  git push origin main
+
+  printf "not pushed" > missing.dat
+  git add missing.dat
+  git commit -m "add unpushed file"
+
+  remote_oid="$(calc_oid "remote")"
+  missing_oid="$(calc_oid "not pushed")"
+  rm -rf ".git/lfs/objects/${remote_oid:0:2}" ".git/lfs/objects/${missing_oid:0:2}"
+
+  git lfs fetch --dry-run 2>&1 | tee fetch.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "Would download 1 object(s) (6 B in total)" fetch.log
+  grep "$remote_oid remote.dat (6 B)" fetch.log
+  grep "Skipping 1 object(s) already present locally (6 B in total)" fetch.log
+  grep "$(calc_oid "cached") cached.dat (6 B)" fetch.log
+  grep "1 object(s) not available from the remote" fetch.log
+  grep "$missing_oid missing.dat" fetch.log
+  refute_local_object "$remote_oid"
+
+  git lfs fetch --dry-run --json 2>/dev/null | tee fetch.json
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "\"download\":\[{\"name\":\"remote.dat\",\"oid\":\"$remote_oid\",\"size\":6}\],\"download_size\":6" fetch.json
+  grep "\"cached\":\[{\"name\":\"cached.dat\",\"oid\":\"$(calc_oid "cached")\",\"size\":6}\],\"cached_size\":6" fetch.json
+  grep "\"unavailable\":\[{\"name\":\"missing.dat\",\"oid\":\"$missing_oid\",\"size\":10,\"error\":" fetch.json
+
+  git lfs pull --dry-run --json 2>/dev/null | tee pull.json
+  diff -u fetch.json pull.json
+  assert_pointer "main" "remote.dat" "$remote_oid" 6
+  refute_local_object "$remote_oid"
+
+  git lfs fetch --json 2>&1 | tee fetch.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep -- "--json requires --dry-run" fetch.log
+
+  git lfs fetch --dry-run --prune 2>&1 | tee fetch.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Cannot combine --dry-run with --prune" fetch.log
+)
+end_test
+
+begin_test "fetch --verbose"
+(
+  set -e
+
+  reponame="fetch-verbose"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "small" > small.dat
+  printf "larger contents" > large.dat
+  git add .gitattributes small.dat large.dat
+  git commit -m "add files"
+  git push origin main
+
+  small_oid="$(calc_oid "small")"
+  large_oid="$(calc_oid "larger contents")"
+
+  rm -rf .git/lfs/objects
+  git lfs fetch --verbose 2>&1 | tee fetch.log
+  grep "fetch: downloaded $small_oid small.dat (5 B) in .* at .*/s" fetch.log
+  grep "fetch: downloaded $large_oid large.dat (15 B) in .* at .*/s" fetch.log
+  assert_local_object "$small_oid" 5
+
+  rm -rf .git/lfs/objects
+  git lfs pull --verbose --json 2>/dev/null | tee pull.json
+  grep "^{\"name\":\"small.dat\",\"oid\":\"$small_oid\",\"size\":5,\"elapsed_ms\":[0-9]*,\"bytes_per_second\":[0-9]*}$" pull.json
+  grep "^{\"name\":\"large.dat\",\"oid\":\"$large_oid\",\"size\":15,\"elapsed_ms\":[0-9]*,\"bytes_per_second\":[0-9]*}$" pull.json
+  [ 2 -eq "$(wc -l < pull.json)" ]
+  [ "larger contents" = "$(cat large.dat)" ]
+)
+end_test
+
+begin_test "fetch: objects referenced from many refs are requested once"
+(
+  set -e
+
+  reponame="fetch-dedup-refs"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "shared" > a.dat
+  printf "shared" > b.dat
+  git add .gitattributes a.dat b.dat
+  git commit -m "add shared files"
+  git push origin main
+
+  # An object the server doesn't have is requested from it only once, too,
+  # rather than once for each ref.
+  git checkout -b other
+  printf "not pushed" > missing.dat
+  git add missing.dat
+  git commit -m "add unpushed file"
+  git checkout -b third
+
+  shared_oid="$(calc_oid "shared")"
+  missing_oid="$(calc_oid "not pushed")"
+  rm -rf .git/lfs/objects
+
+  GIT_TRACE=1 git lfs fetch --verbose origin main other third 2>&1 | tee fetch.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  # One batch for main, with the shared object, and one for other, with the
+  # missing one, and none for third.
+  [ 2 -eq "$(grep -c "POST .*/objects/batch" fetch.log)" ]
+  [ 1 -eq "$(grep -c "fetch a.dat \[$shared_oid\]" fetch.log)" ]
+  [ 1 -eq "$(grep -c "fetch missing.dat \[$missing_oid\]" fetch.log)" ]
+  [ 1 -eq "$(grep -c "fetch: downloaded $shared_oid" fetch.log)" ]
+  grep "fetch: 8 pointer(s) referred to 2 unique object(s) (4.00:1), 2 of which were requested" fetch.log
+  assert_local_object "$shared_oid" 6
+  refute_local_object "$missing_oid"
 )
 end_test
@@ -137,3 +137,116 @@ begin_test "fsck: outside git repository"
   grep "Not in a git repository" fsck.log
 )
 end_test
+
+begin_test "fsck: pointer text in place of contents"
+(
+  set -e
+
+  reponame="fsck-pointer-contents"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.dat"
+
+  # Store an object whose contents are themselves a pointer, and commit a
+  # pointer to it, as though a pointer had been cleaned a second time.
+  pointer "$(calc_oid "inner")" 5 > inner.txt
+  nested_oid="$(calc_oid_file inner.txt)"
+  nested_size="$(wc -c < inner.txt | tr -d ' ')"
+  mkdir -p ".git/lfs/objects/${nested_oid:0:2}/${nested_oid:2:2}"
+  mv inner.txt ".git/lfs/objects/${nested_oid:0:2}/${nested_oid:2:2}/$nested_oid"
+  pointer "$nested_oid" "$nested_size" > nested.dat
+
+  echo "unsmudged" > unsmudged.dat
+  unsmudged_oid="$(calc_oid "unsmudged\n")"
+
+  git add .gitattributes nested.dat unsmudged.dat
+  git commit -m "pointer contents"
+
+  rm nested.dat
+  git checkout -- nested.dat
+  git show HEAD:unsmudged.dat > unsmudged.dat
+
+  # Files are only read for pointer text when asked to.
+  git lfs fsck 2>&1 | tee fsck.log
+  [ "0" -eq "$(grep -c "^Pointer" fsck.log)" ]
+
+  set +e
+  git lfs fsck --pointers > fsck.log 2>&1
+  res=$?
+  set -e
+  cat fsck.log
+  [ "$res" -eq 1 ]
+  grep "Pointer nested.dat ($nested_oid): object is itself a pointer" fsck.log
+  grep "Pointer unsmudged.dat ($unsmudged_oid): working tree file is a pointer" fsck.log
+  [ "2" -eq "$(grep -c "^Pointer" fsck.log)" ]
+  grep "Git LFS fsck OK" fsck.log && exit 1
+
+  git lfs checkout unsmudged.dat
+  git lfs fsck --pointers 2>&1 | tee fsck.log
+  [ "1" -eq "$(grep -c "^Pointer" fsck.log)" ]
+  grep "Pointer nested.dat" fsck.log
+)
+end_test
+
+begin_test "fsck: --include, --exclude and --recent"
+(
+  set -e
+
+  reponame="fsck-include-recent"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "initial commit"
+
+  git checkout -b other
+  printf "other" > other.dat
+  git add other.dat
+  git commit -m "add other.dat"
+
+  git checkout main
+  mkdir dir
+  printf "a" > a.dat
+  printf "b" > dir/b.dat
+  git add a.dat dir/b.dat
+  git commit -m "add files"
+
+  # The first version of a.dat is only in the history of main.
+  printf "a2" > a.dat
+  git add a.dat
+  git commit -m "update a.dat"
+
+  otherOid="$(calc_oid "other")"
+  otherPath=".git/lfs/objects/${otherOid:0:2}/${otherOid:2:2}/$otherOid"
+  echo "CORRUPTION" >> "$otherPath"
+
+  [ "Git LFS fsck OK" = "$(git lfs fsck --dry-run)" ]
+
+  git lfs fsck --dry-run --include="dir/" | tee fsck.log
+  grep "fsck: 1 object(s) checked, 3 object(s) in the local store skipped" fsck.log
+  grep "Git LFS fsck OK" fsck.log
+
+  git lfs fsck --dry-run --exclude="dir/" | tee fsck.log
+  grep "fsck: 1 object(s) checked, 3 object(s) in the local store skipped" fsck.log
+
+  # Only the recent trees are checked, not the first version of a.dat, unless
+  # recent commits are too.
+  git lfs fsck --dry-run --recent | tee fsck.log
+  grep "Object other.dat ($otherOid) is corrupt" fsck.log
+  grep "fsck: 3 object(s) checked, 1 object(s) in the local store skipped" fsck.log
+
+  git -c lfs.fetchrecentcommitsdays=1 lfs fsck --dry-run --recent | tee fsck.log
+  grep "fsck: 4 object(s) checked, 0 object(s) in the local store skipped" fsck.log
+
+  git lfs fsck --dry-run --recent --exclude="*.dat" | tee fsck.log
+  grep "fsck: 0 object(s) checked, 4 object(s) in the local store skipped" fsck.log
+  grep "Git LFS fsck OK" fsck.log
+
+  # With no recent refs, nothing is checked, not even the current HEAD.
+  git -c lfs.fetchrecentrefsdays=0 lfs fsck --dry-run --recent | tee fsck.log
+  grep "fsck: 0 object(s) checked, 4 object(s) in the local store skipped" fsck.log
+  grep "Git LFS fsck OK" fsck.log
+)
+end_test
@@ -354,3 +354,57 @@ begin_test "can install when multiple global values registered"
   git lfs install --force
 )
 end_test
+
+begin_test "install --dry-run"
+(
+  set -e
+
+  mkdir install-dry-run
+  cd install-dry-run
+  git init
+
+  git lfs install
+  git config --global filter.lfs.smudge "git-lfs smudge --something %f"
+  rm .git/hooks/post-commit
+  echo "#!/bin/sh
+git lfs push --stdin \$*" > .git/hooks/pre-push
+  printf "#!/bin/sh\necho custom\n" > .git/hooks/post-merge
+  cp .git/hooks/pre-push ../pre-push.orig
+
+  git lfs install --dry-run 2>&1 | tee install.log
+  [ "${PIPESTATUS[0]}" = 0 ]
+  grep "filter.lfs.clean: already \"git-lfs clean -- %f\"" install.log
+  grep "filter.lfs.smudge: is \"git-lfs smudge --something %f\", which would not be changed to \"git-lfs smudge -- %f\" without --force" install.log
+  grep "pre-push: installed by Git LFS, would upgrade" install.log
+  grep "post-checkout: already installed" install.log
+  grep "post-commit: would create" install.log
+  grep "post-merge: customized, would not be overwritten without --force" install.log
+  grep "Would write $(pwd)/.git/hooks/post-commit:" install.log
+  grep "Would write $(pwd)/.git/hooks/post-merge:" install.log && exit 1
+  grep "\`git lfs install\` would fail" install.log
+
+  # nothing was changed
+  [ "git-lfs smudge --something %f" = "$(git config --global filter.lfs.smudge)" ]
+  cmp .git/hooks/pre-push ../pre-push.orig
+  [ ! -e .git/hooks/post-commit ]
+  [ "custom" = "$(sh .git/hooks/post-merge)" ]
+
+  git lfs install --dry-run --force --json | tee install.json
+  grep '"scope":"global"' install.json
+  grep '{"key":"filter.lfs.smudge","current":"git-lfs smudge --something %f","value":"git-lfs smudge -- %f","action":"set"}' install.json
+  grep '"type":"post-merge","path":"[^"]*/.git/hooks/post-merge","exists":true,"managed":false,"action":"overwrite","contents":"#!/bin/sh\\n' install.json
+  grep '"type":"post-checkout","path":"[^"]*","exists":true,"managed":true,"action":"none"}' install.json
+  [ "custom" = "$(sh .git/hooks/post-merge)" ]
+
+  git lfs install --local --dry-run --skip-repo --json | tee install.json
+  grep '"scope":"local"' install.json
+  grep '"hooks":\[\]' install.json
+
+  set +e
+  git lfs install --json > install.log 2>&1
+  res=$?
+  set -e
+  [ "$res" -ne 0 ]
+  grep -- "--json requires --dry-run" install.log
+)
+end_test
@@ -158,6 +158,54 @@ begin_test "locking a directory"
 )
 end_test
 
+begin_test "locking a directory with --recursive"
+(
+  set -e
+
+  reponame="lock-recursive"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir -p assets/maps other
+  echo "a" > assets/a.dat
+  echo "b" > assets/maps/b.dat
+  echo "c" > assets/c.txt
+  echo "d" > other/d.dat
+  git add .gitattributes assets other
+  git commit -m "add assets"
+  git push origin main
+
+  git lfs lock --recursive assets 2>&1 | tee lock.log
+  grep "Locked assets/a.dat" lock.log
+  grep "Locked assets/maps/b.dat" lock.log
+  grep "2 file(s) locked, 0 failed" lock.log
+  grep "c.txt" lock.log && exit 1
+  grep "other/d.dat" lock.log && exit 1
+
+  git lfs locks 2>&1 | tee locks.log
+  [ "2" -eq "$(grep -c "assets/" locks.log)" ]
+
+  # Locking them again fails, since they are already locked.
+  git lfs lock --recursive assets/ 2>&1 | tee lock.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs lock --recursive\` to fail ..."
+    exit 1
+  fi
+  grep "0 file(s) locked, 2 failed" lock.log
+
+  cd other
+  git lfs lock --recursive --json .. | tee lock.json
+  [ "1" -eq "$(grep -c "\"path\":\"other/d.dat\"" lock.json)" ]
+  grep "file(s) locked" lock.json && exit 1
+  cd ..
+
+  mkdir empty
+  git lfs lock --recursive empty 2>&1 | tee lock.log
+  grep "no Git LFS files in directory: empty" lock.log
+)
+end_test
+
 begin_test "locking a nested file"
 (
   set -e
@@ -294,3 +342,187 @@ begin_test "lock with .gitignore and lfs.lockignoredfiles"
   refute_file_writeable a.txt
 )
 end_test
+
+begin_test "lock with lfs.lock.warnnonlockable"
+(
+  set -e
+
+  reponame="lock-warn-non-lockable"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs lock "a.dat" 2>&1 | tee lock.log
+  grep "not marked as lockable" lock.log && exit 1
+  git lfs unlock "a.dat"
+
+  git config lfs.lock.warnnonlockable true
+
+  git lfs lock "a.dat" 2>&1 | tee lock.log
+  grep "Warning: a.dat is not marked as lockable" lock.log
+  grep "git lfs track --lockable \"a.dat\"" lock.log
+  grep "Locked a.dat" lock.log
+  git lfs unlock "a.dat"
+
+  git lfs lock --force "a.dat" 2>&1 | tee lock.log
+  grep "not marked as lockable" lock.log && exit 1
+  grep "Locked a.dat" lock.log
+  git lfs unlock "a.dat"
+
+  git lfs track --lockable "*.dat"
+  git add .gitattributes
+  git commit -m "mark *.dat as lockable"
+
+  git lfs lock "a.dat" 2>&1 | tee lock.log
+  grep "not marked as lockable" lock.log && exit 1
+  grep "Locked a.dat" lock.log
+)
+end_test
+
+begin_test "creating a lock (--root-relative)"
+(
+  set -e
+
+  reponame="lock_create_root_relative"
+  setup_remote_repo_with_file "$reponame" "sub/a.dat"
+
+  mkdir -p other/dir
+  cd other/dir
+
+  git lfs lock --json --root-relative "sub/a.dat" | tee lock.json
+  if [ "0" -ne "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs lock --root-relative sub/a.dat' to succeed"
+    exit 1
+  fi
+
+  id=$(assert_lock lock.json sub/a.dat)
+  assert_server_lock "$reponame" "$id"
+
+  touch ../../sub/untracked.dat
+  for path in "a.dat" "../sub/a.dat" "sub" "sub/untracked.dat"; do
+    git lfs lock --root-relative "$path" 2>&1 | tee lock.log
+    if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+      echo >&2 "fatal: expected 'git lfs lock --root-relative $path' to fail"
+      exit 1
+    fi
+  done
+  grep "file is not tracked by Git: sub/untracked.dat" lock.log
+
+  git lfs unlock --root-relative "sub/a.dat" 2>&1 | tee unlock.log
+  grep "Unlocked sub/a.dat" unlock.log
+  refute_server_lock "$reponame" "$id"
+)
+end_test
+
+begin_test "creating a lock (--message)"
+(
+  set -e
+
+  reponame="lock_create_message"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+
+  git lfs lock --json --message "WIP on feature X" "a.dat" | tee lock.json
+  id=$(assert_lock lock.json a.dat)
+  grep '"message":"WIP on feature X"' lock.json
+  assert_server_lock "$reponame" "$id"
+
+  git lfs locks | tee locks.log
+  grep "a.dat	Git LFS Tests	ID:$id	WIP on feature X" locks.log
+  git lfs locks --json | tee locks.json
+  grep '"message":"WIP on feature X"' locks.json
+  git lfs locks --format "%(path): %(message)" | tee locks.log
+  [ "a.dat: WIP on feature X" = "$(cat locks.log)" ]
+)
+end_test
+
+begin_test "creating a lock (--message, unsupported by the server)"
+(
+  set -e
+
+  reponame="lock_create_no-lock-messages"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+
+  git lfs lock -m "WIP on feature X" "a.dat" 2>&1 | tee lock.log
+  [ "0" -eq "${PIPESTATUS[0]}" ]
+  grep "Locked a.dat" lock.log
+  grep "Warning: the server did not keep the message of the lock on a.dat" lock.log
+
+  git lfs locks | tee locks.log
+  grep "a.dat" locks.log
+  [ "0" -eq "$(grep -c "WIP" locks.log)" ]
+)
+end_test
+
+begin_test "transferring locks (--transfer-to)"
+(
+  set -e
+
+  reponame="lock-transfer-to"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir -p assets
+  echo "a" > assets/a.dat
+  echo "b" > assets/b.dat
+  echo "c" > c.dat
+  git add .gitattributes assets c.dat
+  git commit -m "add assets"
+  git push origin main
+
+  git lfs lock --recursive . 2>&1 | tee lock.log
+  grep "3 file(s) locked, 0 failed" lock.log
+
+  git lfs lock --transfer-to "Jane Doe" "assets/" 2>&1 | tee transfer.log
+  grep "Transferred assets/a.dat to Jane Doe" transfer.log
+  grep "Transferred assets/b.dat to Jane Doe" transfer.log
+  grep "2 lock(s) transferred to Jane Doe, 0 failed" transfer.log
+
+  git lfs locks 2>&1 | tee locks.log
+  grep -E "^assets/a.dat\s+Jane Doe" locks.log
+  grep -E "^assets/b.dat\s+Jane Doe" locks.log
+  grep -E "^c.dat\s+Git LFS Tests" locks.log
+
+  # Patterns are relative to the current directory.
+  cd assets
+  git lfs lock --transfer-to "Git LFS Tests" --owner "Jane Doe" --json "a.dat" | tee transfer.json
+  grep '"path":"assets/a.dat"' transfer.json
+  grep '"name":"Git LFS Tests"' transfer.json
+  cd ..
+
+  git lfs lock --transfer-to "Git LFS Tests" --owner "Nobody" 2>&1 | tee transfer.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected transfer to fail"
+    exit 1
+  fi
+  grep "no matching locks held by Nobody" transfer.log
+
+  git lfs lock --owner "Jane Doe" c.dat 2>&1 | tee lock.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected lock to fail"
+    exit 1
+  fi
+  grep -- "--owner can only be given with --transfer-to" lock.log
+)
+end_test
+
+begin_test "transferring locks (--transfer-to, unsupported by the server)"
+(
+  set -e
+
+  reponame="lock_transfer_no-lock-transfers"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+
+  git lfs lock --json "a.dat" | tee lock.json
+  id=$(assert_lock lock.json a.dat)
+
+  git lfs lock --transfer-to "Jane Doe" 2>&1 | tee transfer.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected transfer to fail"
+    exit 1
+  fi
+  grep "the server does not support transferring locks" transfer.log
+
+  git lfs locks 2>&1 | tee locks.log
+  grep -E "^a.dat\s+Git LFS Tests\s+ID:$id" locks.log
+)
+end_test
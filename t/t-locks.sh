@@ -95,6 +95,37 @@ begin_test "list a single lock (--json)"
 )
 end_test
 
+begin_test "list locks (--format)"
+(
+  set -e
+
+  reponame="locks-list-format"
+  setup_remote_repo_with_file "$reponame" "f.dat"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs lock --json "f.dat" | tee lock.log
+  id=$(assert_lock lock.log f.dat)
+
+  git lfs locks --format "%(id)%09%(path) by %(owner) 100%%" | tee locks.log
+  [ "$(printf "%s\tf.dat by Git LFS Tests 100%%" "$id")" = "$(cat locks.log)" ]
+
+  git lfs locks --format "%(locked_at)" | tee locks.log
+  grep -E "^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}" locks.log
+
+  git lfs locks --format "%(id) %(name)" 2>&1 | tee locks.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep 'unknown field "%(name)", expected one of: %(id), %(locked_at), %(message), %(owner), %(path)' locks.log
+
+  git lfs locks --format "%(id" 2>&1 | tee locks.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "unterminated field" locks.log
+
+  git lfs locks --json --format "%(id)" 2>&1 | tee locks.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "can't be combined with --json" locks.log
+)
+end_test
+
 begin_test "list locks with a limit"
 (
   set -e
@@ -165,6 +196,63 @@ begin_test "list locks with pagination"
 )
 end_test
 
+begin_test "list locks matching a pattern (--path, --owner, --mine, --theirs)"
+(
+  set -e
+
+  reponame="locks-list-pattern"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.umap" "*.dat"
+  mkdir -p Content/Maps/Sub
+  for f in Content/Maps/a.umap Content/Maps/Sub/b.umap Content/Maps/theirs.umap Content/c.dat theirs.dat; do
+    echo "$f" > "$f"
+  done
+  git add .gitattributes Content theirs.dat
+  git commit -m "add files"
+  git push origin main
+
+  for f in Content/Maps/a.umap Content/Maps/Sub/b.umap Content/Maps/theirs.umap Content/c.dat theirs.dat; do
+    git lfs lock "$f"
+  done
+
+  git lfs locks --path 'Content/Maps/*.umap' | tee locks.log
+  [ "2" -eq "$(wc -l < locks.log)" ]
+  grep "Content/Maps/a.umap" locks.log
+  grep "Content/Maps/theirs.umap" locks.log
+
+  git lfs locks --path 'Content/Maps/**/*.umap' | tee locks.log
+  [ "3" -eq "$(wc -l < locks.log)" ]
+
+  # Like .gitattributes, a pattern without a slash matches in any directory.
+  git lfs locks --path '*.dat' --json | tee locks.json
+  grep '"path":"Content/c.dat"' locks.json
+  grep '"path":"theirs.dat"' locks.json
+  grep "umap" locks.json && exit 1
+
+  git lfs locks --path '*.umap' --limit 1 | tee locks.log
+  [ "1" -eq "$(wc -l < locks.log)" ]
+  grep "Content/Maps/Sub/b.umap" locks.log
+
+  git lfs locks --owner "Git LFS Tests" | tee locks.log
+  [ "5" -eq "$(wc -l < locks.log)" ]
+  git lfs locks --owner "someone else" | tee locks.log
+  [ "0" -eq "$(wc -l < locks.log)" ]
+
+  git lfs locks --mine | tee locks.log
+  [ "3" -eq "$(wc -l < locks.log)" ]
+  grep "theirs" locks.log && exit 1
+
+  git lfs locks --theirs --path '*.umap' --json | tee locks.json
+  grep '"path":"Content/Maps/theirs.umap"' locks.json
+  grep "theirs.dat" locks.json && exit 1
+
+  git lfs locks --mine --theirs 2>&1 | tee locks.log
+  grep "can't be combined with --theirs" locks.log
+)
+end_test
+
 begin_test "cached locks"
 (
   set -e
@@ -207,3 +295,135 @@ begin_test "cached locks"
   [ $(wc -l < locks.log) -eq 1 ]
 )
 end_test
+
+begin_test "locks --refresh caches locks for --cached"
+(
+  set -e
+
+  reponame="locks-refresh"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  echo "a" > a.dat
+  echo "b" > b_theirs.dat
+  git add .gitattributes a.dat b_theirs.dat
+  git commit -m "add files"
+  git push origin main
+
+  git lfs lock a.dat
+  git lfs lock b_theirs.dat
+
+  rm -rf .git/lfs/cache
+  git lfs locks --cached 2>&1 | tee locks.log
+  grep "no cached locks present" locks.log
+
+  git lfs locks --refresh 2>&1 | tee refresh.log
+  grep "Cached 2 lock(s), 1 of them our own" refresh.log
+
+  git lfs locks --refresh --json 2>&1 | tee refresh.log
+  grep "can't be combined" refresh.log
+
+  # The locks are listed from the cache, without the server.
+  git config lfs.url "http://127.0.0.1:1/$reponame.git/info/lfs"
+
+  git lfs locks --cached >locks.log 2>stderr.log
+  cat locks.log stderr.log
+  [ "2" -eq "$(wc -l < locks.log)" ]
+  grep "Listing locks cached at .* ago), which may be out of date." stderr.log
+
+  git lfs locks --cached --mine >locks.log 2>stderr.log
+  cat locks.log stderr.log
+  [ "1" -eq "$(wc -l < locks.log)" ]
+  grep "a.dat" locks.log
+
+  git lfs locks --cached --json 2>&1 | tee locks.json
+  grep "Listing locks" locks.json && exit 1
+  grep '"path":"b_theirs.dat"' locks.json
+)
+end_test
+
+begin_test "locks --local does not contact the server"
+(
+  set -e
+
+  reponame="locks_local_offline"
+  setup_remote_repo "remote_$reponame"
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat"
+  echo "foo" > "local.dat"
+  git add "local.dat" ".gitattributes"
+  git commit -m "add local.dat"
+  git push origin main 2>&1 | tee push.log
+
+  git lfs lock --json "local.dat" | tee lock.log
+  id=$(assert_lock lock.log local.dat)
+
+  # point at a server which can't be reached
+  git config lfs.url "http://127.0.0.1:1/unreachable"
+
+  GIT_TRACE=1 git lfs locks --local >locks.log 2>trace.log
+  cat locks.log trace.log
+  grep "local.dat" locks.log
+  grep "ID:$id" locks.log
+  grep "Listing locks recorded by this clone, which may be out of date." trace.log
+  [ "0" -eq "$(grep -c "HTTP:" trace.log)" ]
+
+  git lfs locks --local --json 2>stderr.log | tee locks.json
+  grep "\"path\":\"local.dat\"" locks.json
+  grep "\"id\":\"$id\"" locks.json
+  [ ! -s stderr.log ]
+)
+end_test
+
+begin_test "locks --watch"
+(
+  set -e
+
+  reponame="locks_watch"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  echo "a" > a.dat
+  echo "b" > b.dat
+  git add .gitattributes a.dat b.dat
+  git commit -m "add a.dat, b.dat"
+  git push origin main
+
+  git lfs lock --json "a.dat" | tee lock.log
+  a_id=$(assert_lock lock.log a.dat)
+
+  git config lfs.locks.watchinterval 100ms
+  # Run git-lfs itself, rather than through git, so that it is the process
+  # interrupted below.
+  git-lfs locks --watch >watch.json 2>watch.log &
+  pid=$!
+
+  wait_for_line() {
+    for i in $(seq 100); do
+      grep -q "$1" watch.json && return 0
+      sleep 0.1
+    done
+    cat watch.json watch.log
+    exit 1
+  }
+
+  wait_for_line "\"event\":\"added\",\"id\":\"$a_id\""
+
+  git lfs lock --json "b.dat" | tee lock.log
+  b_id=$(assert_lock lock.log b.dat)
+  wait_for_line "\"event\":\"added\",\"id\":\"$b_id\",\"path\":\"b.dat\""
+
+  git lfs unlock --id="$a_id"
+  wait_for_line "\"event\":\"removed\",\"id\":\"$a_id\",\"path\":\"a.dat\""
+
+  kill -INT "$pid"
+  wait "$pid"
+
+  cat watch.json
+  [ 3 -eq "$(wc -l < watch.json)" ]
+  [ 0 -eq "$(grep -c "Error" watch.log)" ]
+)
+end_test
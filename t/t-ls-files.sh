@@ -442,6 +442,53 @@ begin_test "ls-files: --name-only"
 )
 end_test
 
+begin_test "ls-files: --oid-only and --name-only print one column"
+(
+  set -e
+
+  reponame="ls-files-one-column"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.dat"
+  printf "larger file" > a.dat
+  printf "same" > b.dat
+  printf "same" > c.dat
+  mkdir dir
+  printf "other" > dir/d.dat
+  git add .gitattributes a.dat b.dat c.dat dir
+  git commit -m "initial commit"
+
+  a_oid="$(calc_oid "larger file")"
+  same_oid="$(calc_oid "same")"
+  d_oid="$(calc_oid "other")"
+
+  # The OID of b.dat and c.dat is listed once.
+  git lfs ls-files --oid-only --size --sort name | tee ls.log
+  [ "$a_oid
+$same_oid
+$d_oid" = "$(cat ls.log)" ]
+
+  git lfs ls-files --oid-only --sort size --exclude "a.dat" | tee ls.log
+  [ "$d_oid
+$same_oid" = "$(cat ls.log)" ]
+
+  git lfs ls-files --name-only --size --long --sort oid | tee ls.log
+  [ "$(printf "%s\n" "$same_oid b.dat" "$same_oid c.dat" "$a_oid a.dat" "$d_oid dir/d.dat" |
+    sort | cut -d " " -f 2)" = "$(cat ls.log)" ]
+
+  git rm -q a.dat
+  git commit -m "remove a.dat"
+  git lfs ls-files --ref HEAD~1 --oid-only --include "a.dat" | tee ls.log
+  [ "$a_oid" = "$(cat ls.log)" ]
+  git lfs ls-files --name-only --include "dir" | tee ls.log
+  [ "dir/d.dat" = "$(cat ls.log)" ]
+
+  git lfs ls-files --oid-only --name-only 2>&1 | tee ls.log
+  grep "cannot use --oid-only with --name-only" ls.log
+)
+end_test
+
 begin_test "ls-files: history with reference range"
 (
   set -e
@@ -515,4 +562,149 @@ begin_test "ls-files: not affected by lfs.fetchexclude"
   git config lfs.fetchexclude '*'
   [ "6bbd052ab0 * missing.dat" = "$(git lfs ls-files)" ]
 )
-end_test
\ No newline at end of file
+end_test
+
+begin_test "ls-files: --not-downloaded and --modified"
+(
+  set -e
+
+  mkdir repo-filters
+  cd repo-filters
+  git init
+  git lfs track "*.dat" | grep "Tracking \"\*.dat\""
+
+  for name in clean modified missing; do
+    printf "%s" "$name" > "$name.dat"
+  done
+  git add .gitattributes *.dat
+  git commit -m "add files"
+
+  printf "changed" > modified.dat
+
+  # Leave missing.dat as it would be after a clone with GIT_LFS_SKIP_SMUDGE.
+  oid="$(calc_oid missing)"
+  git show HEAD:missing.dat > missing.dat
+  rm -f ".git/lfs/objects/${oid:0:2}/${oid:2:2}/$oid"
+  refute_local_object "$oid"
+
+  git lfs ls-files --not-downloaded --name-only 2>&1 | tee ls-files.log
+  [ "missing.dat" = "$(cat ls-files.log)" ]
+
+  git lfs ls-files --modified --name-only 2>&1 | tee ls-files.log
+  [ "modified.dat" = "$(cat ls-files.log)" ]
+
+  git lfs ls-files --not-downloaded --modified --name-only 2>&1 | tee ls-files.log
+  [ 2 -eq "$(wc -l < ls-files.log)" ]
+  grep "missing.dat" ls-files.log
+  grep "modified.dat" ls-files.log
+
+  # A file still holding its pointer is not modified.
+  git show HEAD:clean.dat > clean.dat
+  git lfs ls-files --modified --name-only 2>&1 | tee ls-files.log
+  [ "modified.dat" = "$(cat ls-files.log)" ]
+
+  git lfs ls-files --not-downloaded --json 2>&1 | tee ls-files.json
+  expected="{\"files\":[{\"name\":\"missing.dat\",\"size\":7,\"checkout\":false,\"downloaded\":false,\"oid_type\":\"sha256\",\"oid\":\"$oid\",\"version\":\"https://git-lfs.github.com/spec/v1\"}]}"
+  [ "$expected" = "$(cat ls-files.json)" ]
+)
+end_test
+
+begin_test "ls-files: --all lists each object once"
+(
+  set -e
+
+  reponame="ls-files-all-dedup"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track '*.dat'
+  printf "same" > a.dat
+  printf "same" > b.dat
+  printf "other" > c.dat
+
+  git add .gitattributes a.dat b.dat c.dat
+  git commit -m "initial commit"
+
+  git checkout -b other
+  printf "same" > d.dat
+  printf "newer" > c.dat
+  git add d.dat c.dat
+  git commit -m "add d.dat, modify c.dat"
+  git checkout main
+
+  # Staged files are listed too, but not again for objects in history.
+  printf "same" > e.dat
+  git add e.dat
+
+  same_oid="$(calc_oid "same")"
+  other_oid="$(calc_oid "other")"
+  newer_oid="$(calc_oid "newer")"
+
+  git lfs ls-files --all --long 2>ls-files.err | tee ls-files.log
+  grep "Warning: scanning the history of every reference" ls-files.err
+
+  [ 3 -eq "$(wc -l < ls-files.log)" ]
+  [ 1 -eq "$(grep -c "$same_oid" ls-files.log)" ]
+  [ 1 -eq "$(grep -c "$other_oid" ls-files.log)" ]
+  [ 1 -eq "$(grep -c "$newer_oid" ls-files.log)" ]
+
+  git lfs ls-files --all --json 2>/dev/null | tee ls-files.json
+  [ 3 -eq "$(grep -o '"oid":' ls-files.json | wc -l)" ]
+  [ 1 -eq "$(grep -o "\"oid\":\"$same_oid\"" ls-files.json | wc -l)" ]
+  grep "\"size\":5" ls-files.json
+)
+end_test
+
+begin_test "ls-files: --ref lists another branch without checking it out"
+(
+  set -e
+
+  reponame="ls-files-ref"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track '*.dat'
+  printf "main" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  git checkout -b other
+  printf "larger file" > b.dat
+  printf "other" > a.dat
+  git add a.dat b.dat
+  git commit -m "add b.dat, modify a.dat"
+  git checkout main
+
+  other_oid="$(calc_oid "other")"
+  b_oid="$(calc_oid "larger file")"
+
+  # Staged and working tree files are not listed.
+  printf "staged" > c.dat
+  git add c.dat
+
+  git lfs ls-files --ref other --long | tee ls-files.log
+  [ 2 -eq "$(wc -l < ls-files.log)" ]
+  grep "^$other_oid a.dat$" ls-files.log
+  grep "^$b_oid b.dat$" ls-files.log
+
+  git lfs ls-files --ref other --size --sort size | tee ls-files.log
+  [ "${b_oid:0:10} b.dat (11 B)" = "$(head -n 1 ls-files.log)" ]
+  [ "${other_oid:0:10} a.dat (5 B)" = "$(tail -n 1 ls-files.log)" ]
+
+  git lfs ls-files --ref other --json --sort name | tee ls-files.json
+  grep '^{"ref":"other","files":\[{"name":"a.dat",' ls-files.json
+  grep "checkout" ls-files.json && exit 1
+
+  git lfs ls-files --ref other main 2>&1 | tee ls-files.log
+  grep "cannot use --ref with explicit reference" ls-files.log
+  git lfs ls-files --ref missing 2>&1 | tee ls-files.log
+  grep 'could not resolve "missing"' ls-files.log
+
+  cd ..
+  git clone --bare "$reponame" "$reponame-bare"
+  cd "$reponame-bare"
+  git lfs ls-files --ref other --name-only --sort name | tee ls-files.log
+  [ "a.dat
+b.dat" = "$(cat ls-files.log)" ]
+)
+end_test
@@ -263,3 +263,31 @@ EOF
   assert_local_object "$bar_oid" "3"
 )
 end_test
+
+begin_test "migrate import --no-rewrite (file modes)"
+(
+  set -e
+
+  setup_local_branch_with_modes
+  echo "bin/*.txt filter=lfs diff=lfs merge=lfs -text" > .gitattributes
+  echo "dir/*.txt filter=lfs diff=lfs merge=lfs -text" >> .gitattributes
+  git add .gitattributes
+  git commit -m "add .gitattributes"
+
+  run_oid="$(calc_oid_file bin/run.txt)"
+  git lfs migrate import --no-rewrite --yes bin/run.txt
+
+  git ls-tree -r HEAD bin/run.txt | grep -P "^100755 blob [0-9a-f]+\tbin/run.txt$"
+  assert_pointer "refs/heads/main" "bin/run.txt" "$run_oid" 140
+  [ -x bin/run.txt ]
+
+  head="$(git rev-parse HEAD)"
+  git lfs migrate import --no-rewrite --yes dir/link.txt 2>&1 | tee migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected migrate import --no-rewrite of a symlink to fail"
+    exit 1
+  fi
+  grep "dir/link.txt.*link.txt is a symbolic link" migrate.log
+  [ "$head" = "$(git rev-parse HEAD)" ]
+)
+end_test
@@ -693,6 +693,58 @@ begin_test "migrate import (--everything with args)"
 )
 end_test
 
+begin_test "migrate import (--include-ref with glob)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  git checkout -b throwaway
+  base64 < /dev/urandom | head -c 50 > b.md
+  git add b.md
+  git commit -m "add b.md"
+  git checkout main
+
+  md_feature_oid="$(calc_oid "$(git cat-file -p "refs/heads/my-feature:a.md")")"
+  throwaway="$(git rev-parse refs/heads/throwaway)"
+
+  git lfs migrate import --yes --include-ref="my-*" --include="*.md"
+
+  assert_pointer "refs/heads/my-feature" "a.md" "$md_feature_oid" "30"
+  [ "$throwaway" = "$(git rev-parse refs/heads/throwaway)" ]
+  refute_pointer "refs/heads/throwaway" "b.md"
+)
+end_test
+
+begin_test "migrate import (--include-ref with unmatched glob)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  git lfs migrate import --yes --include-ref="refs/heads/release/*" 2>&1 | tee migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs migrate import\` to fail ..."
+    exit 1
+  fi
+
+  grep "fatal: no references match \"refs/heads/release/\*\"" migrate.log
+)
+end_test
+
+begin_test "migrate import (--exclude-ref sharing history warns)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  git lfs migrate import --yes --include-ref=refs/heads/my-feature \
+    --exclude-ref="ma*" --include="*.md" 2>&1 | tee migrate.log
+
+  grep "migrate: warning: excluded ref refs/heads/main shares history with included ref refs/heads/my-feature" migrate.log
+)
+end_test
+
 begin_test "migrate import (--everything with --include-ref)"
 (
   set -e
@@ -1001,3 +1053,306 @@ begin_test "migrate import (non-standard refs)"
   assert_local_object "$md_feature_oid" "30"
 )
 end_test
+
+begin_test "migrate import (--compress)"
+(
+  set -e
+
+  setup_single_local_branch_untracked
+
+  blob="$(git rev-parse HEAD:a.md)"
+  oid="$(calc_oid "$(git cat-file -p :a.md)")"
+
+  # Keep the log outside the working copy, which must be clean.
+  git lfs migrate import --compress --force --include="*.md" 2>&1 | tee ../migrate.log
+  grep "migrate: Git objects took .*, and now take" ../migrate.log
+
+  assert_pointer "refs/heads/main" "a.md" "$oid" "140"
+  [ -z "$(git reflog show main)" ]
+  git cat-file -e "$blob" && exit 1
+  true
+)
+end_test
+
+begin_test "migrate import (--compress, negative answer)"
+(
+  set -e
+
+  setup_single_local_branch_untracked
+
+  main="$(git rev-parse refs/heads/main)"
+
+  echo "n" | git lfs migrate import --compress --include="*.md" 2>&1 | tee ../migrate.log
+  if [ "${PIPESTATUS[1]}" -eq 0 ]; then
+    echo >&2 "fatal: expected 'git lfs migrate import --compress' to fail"
+    exit 1
+  fi
+  grep "This cannot be undone!" ../migrate.log
+  grep "migrate: pass --force to compress without confirmation" ../migrate.log
+
+  [ "$main" = "$(git rev-parse refs/heads/main)" ]
+)
+end_test
+
+begin_test "migrate import (--compress with --no-rewrite)"
+(
+  set -e
+
+  setup_single_local_branch_untracked
+
+  git lfs migrate import --no-rewrite --compress a.md 2>&1 | tee ../migrate.log
+  grep "fatal: --no-rewrite and --compress cannot be combined" ../migrate.log
+)
+end_test
+
+begin_test "migrate import (--to-ref-prefix)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  md_oid="$(calc_oid "$(git cat-file -p :a.md)")"
+  main="$(git rev-parse refs/heads/main)"
+  feature="$(git rev-parse refs/heads/my-feature)"
+
+  git lfs migrate import --everything --include="*.md" \
+    --to-ref-prefix=refs/migrated/ --object-map=map.csv
+
+  # the original branches and working copy are untouched
+  [ "$main" = "$(git rev-parse refs/heads/main)" ]
+  [ "$feature" = "$(git rev-parse refs/heads/my-feature)" ]
+  [ "$md_oid" = "$(calc_oid "$(cat a.md)")" ]
+  [ -z "$(git status --porcelain --untracked-files=no)" ]
+
+  assert_pointer "refs/migrated/heads/main" "a.md" "$md_oid" "140"
+  assert_local_object "$md_oid" "140"
+  grep "^$main,$(git rev-parse refs/migrated/heads/main)$" map.csv
+  grep "^$feature,$(git rev-parse refs/migrated/heads/my-feature)$" map.csv
+
+  # promote the migrated refs, as described in git-lfs-migrate(1)
+  git for-each-ref --format="update refs/%(refname:strip=2) %(objectname)" \
+    refs/migrated/ | git update-ref --stdin
+  git for-each-ref --format="delete %(refname)" refs/migrated/ | git update-ref --stdin
+  git reset --hard
+
+  assert_pointer "refs/heads/main" "a.md" "$md_oid" "140"
+  [ -z "$(git for-each-ref refs/migrated/)" ]
+  git cat-file -p :a.md | grep "git-lfs"
+)
+end_test
+
+begin_test "migrate import (--to-ref-prefix outside refs/)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  git lfs migrate import --to-ref-prefix=migrated 2>&1 | tee ../migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs migrate import --to-ref-prefix=migrated' to fail"
+    exit 1
+  fi
+  grep -- "--to-ref-prefix must start with \"refs/\"" ../migrate.log
+)
+end_test
+
+begin_test "migrate import (--workers)"
+(
+  set -e
+
+  reponame="migrate-import-workers"
+  remove_and_create_local_repo "$reponame"
+
+  mkdir -p dir/sub
+  for ext in txt md bin png; do
+    for i in 1 2 3 4; do
+      base64 < /dev/urandom | head -c $((100 + i)) > "$i.$ext"
+      base64 < /dev/urandom | head -c $((200 + i)) > "dir/$i.$ext"
+      printf "same" > "dir/sub/$i.$ext"
+    done
+  done
+  git add .
+  git commit -m "initial commit"
+
+  base64 < /dev/urandom | head -c 50 > dir/1.md
+  printf "other" > dir/sub/2.png
+  git add .
+  git commit -m "second commit"
+
+  main="$(git rev-parse refs/heads/main)"
+
+  git lfs migrate import --everything --to-ref-prefix=refs/serial
+  git lfs migrate import --everything --to-ref-prefix=refs/parallel --workers=4
+
+  [ "$main" = "$(git rev-parse refs/heads/main)" ]
+  [ "$(git rev-parse refs/serial/heads/main)" = "$(git rev-parse refs/parallel/heads/main)" ]
+
+  git ls-tree -r refs/parallel/heads/main | grep -v .gitattributes | while read -r mode type oid path; do
+    git cat-file -p "$oid" | grep "git-lfs"
+  done
+)
+end_test
+
+begin_test "migrate import (--workers less than 1)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  git lfs migrate import --workers=0 2>&1 | tee ../migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs migrate import --workers=0' to fail"
+    exit 1
+  fi
+  grep -- "--workers must be at least 1" ../migrate.log
+)
+end_test
+
+begin_test "migrate import (lfs.migrate.workers)"
+(
+  set -e
+
+  setup_multiple_local_branches
+
+  git config lfs.migrate.workers 0
+  git lfs migrate import 2>&1 | tee ../migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs migrate import' to fail"
+    exit 1
+  fi
+  grep "lfs.migrate.workers must be at least 1" ../migrate.log
+
+  # --workers takes precedence.
+  git lfs migrate import --workers=2
+  git cat-file -p "$(git rev-parse refs/heads/main:a.txt)" | grep "git-lfs"
+)
+end_test
+
+begin_test "migrate import (--changes-only)"
+(
+  set -e
+
+  reponame="migrate-import-changes-only"
+  remove_and_create_local_repo "$reponame"
+
+  printf "a" > a.txt
+  printf "b" > b.txt
+  git add .
+  git commit -m "initial commit"
+
+  git lfs migrate import --everything --changes-only --to-ref-prefix=refs/first
+  [ -s .git/lfs/migrate/import-blobs ]
+
+  printf "c" > c.txt
+  git add c.txt
+  git commit -m "second commit"
+
+  git config lfs.migrate.workers 2
+  git lfs migrate import --everything --changes-only --verbose \
+    --to-ref-prefix=refs/second 2>&1 | tee ../migrate.log
+  grep "migrate: commit [0-9a-f]*: a.txt (unchanged)" ../migrate.log
+  grep "migrate: commit [0-9a-f]*: b.txt (unchanged)" ../migrate.log
+  grep "migrate: commit [0-9a-f]*: c.txt$" ../migrate.log
+  grep "Rewriting commits: 100% (2/2), 1 B converted, .*B/s, 2 unchanged" ../migrate.log
+
+  # The history is the same as if every file had been converted again.
+  [ "$(git rev-parse refs/first/heads/main)" = "$(git rev-parse refs/second/heads/main~1)" ]
+  git cat-file -p "$(git rev-parse refs/second/heads/main:.gitattributes)" | grep "*.txt filter=lfs"
+
+  # Files whose objects are no longer in the local object store are
+  # converted again.
+  rm -rf .git/lfs/objects
+  git lfs migrate import --everything --changes-only --verbose \
+    --to-ref-prefix=refs/third 2>&1 | tee ../migrate.log
+  grep "unchanged" ../migrate.log && exit 1
+  [ "$(git rev-parse refs/second/heads/main)" = "$(git rev-parse refs/third/heads/main)" ]
+  assert_local_object "$(calc_oid "a")" 1
+)
+end_test
+
+begin_test "migrate import (preserve file modes)"
+(
+  set -e
+
+  setup_local_branch_with_modes
+
+  same_blob="$(git rev-parse :same.txt)"
+  link_blob="$(git rev-parse :dir/link.txt)"
+  [ "$same_blob" = "$(git rev-parse :dir/same.txt)" ]
+
+  git lfs migrate import --include="*.txt"
+
+  git ls-tree -r refs/heads/main | tee tree.log
+  grep -P "^100644 blob [0-9a-f]+\ta.txt$" tree.log
+  grep -P "^100755 blob [0-9a-f]+\tbin/run.txt$" tree.log
+  grep -P "^100644 blob [0-9a-f]+\tsame.txt$" tree.log
+  grep -P "^100755 blob [0-9a-f]+\tsub/same.txt$" tree.log
+  grep -P "^120000 blob $link_blob\tdir/link.txt$" tree.log
+  grep -P "^120000 blob $same_blob\tdir/same.txt$" tree.log
+
+  same_oid="$(calc_oid "same.txt")"
+  git cat-file -p "refs/heads/main:same.txt" | grep "oid sha256:$same_oid"
+  git cat-file -p "refs/heads/main:sub/same.txt" | grep "oid sha256:$same_oid"
+
+  [ -x bin/run.txt ]
+  [ -x sub/same.txt ]
+  [ -L dir/link.txt ]
+  [ "../a.txt" = "$(readlink dir/link.txt)" ]
+)
+end_test
+
+begin_test "migrate import (--remote fetches blobs missing from a partial clone)"
+(
+  set -e
+
+  reponame="migrate-import-remote-partial"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents_1="$(printf "%0200d" 1)"
+  contents_2="$(printf "%0200d" 2)"
+  printf "%s" "$contents_1" > a.dat
+  printf "small" > b.txt
+  git add a.dat b.txt
+  git commit -m "initial commit"
+  printf "%s" "$contents_2" > a.dat
+  git commit -am "update a.dat"
+  git push origin main
+
+  git -C "$REMOTEDIR/$reponame.git" config uploadpack.allowFilter true
+  git -C "$REMOTEDIR/$reponame.git" config uploadpack.allowAnySHA1InWant true
+
+  cd ..
+  # The first version of a.dat is left out of the clone, and only the
+  # second is fetched, when it is checked out.
+  git clone --filter=blob:limit=100 "$GITSERVER/$reponame" "$reponame-partial"
+  cd "$reponame-partial"
+  missing="$(git rev-parse main~1:a.dat)"
+  git rev-list --objects --missing=print main | grep "^?$missing"
+
+  git lfs migrate import --everything --include="*.dat" 2>&1 | tee ../migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs migrate import --everything' to fail"
+    exit 1
+  fi
+  grep "blob $missing of a.dat in commit $(git rev-parse main~1) is missing" ../migrate.log
+  grep -- "pass --remote to fetch it" ../migrate.log
+
+  git lfs migrate import --everything --include="*.dat" --remote=nonexistent 2>&1 | tee ../migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs migrate import --remote=nonexistent' to fail"
+    exit 1
+  fi
+  grep "invalid remote name" ../migrate.log
+
+  commit="$(git rev-parse main~1)"
+  git lfs migrate import --everything --include="*.dat" --remote=origin 2>&1 | tee ../migrate.log
+  grep "migrate: Fetched 1 missing blob(s) from origin" ../migrate.log
+  grep "commit $commit: a.dat" ../migrate.log
+
+  assert_pointer "refs/heads/main~1" "a.dat" "$(calc_oid "$contents_1")" 200
+  assert_pointer "refs/heads/main" "a.dat" "$(calc_oid "$contents_2")" 200
+  assert_local_object "$(calc_oid "$contents_1")" 200
+  [ "small" = "$(git cat-file -p main:b.txt)" ]
+)
+end_test
@@ -491,6 +491,42 @@ begin_test "migrate info (all files tracked, --pointers=follow)"
 )
 end_test
 
+begin_test "migrate info (some files tracked, --pointers=by-extension)"
+(
+  set -e
+
+  setup_single_local_branch_tracked
+
+  # add a file which should have been tracked while the filter is broken
+  printf "%030d" 0 > b.txt
+  git -c filter.lfs.clean= -c filter.lfs.process= -c filter.lfs.required=false \
+    add b.txt
+  git commit -m "add b.txt"
+
+  original_head="$(git rev-parse HEAD)"
+
+  diff -u <(git lfs migrate info --pointers=by-extension 2>&1 | tail -n 5) <(cat <<-EOF
+	*.gitattributes    	83 B 	1/1 files(s)	100%
+	*.txt              	30 B 	1/1 files(s)	100%
+
+	*.md (LFS Objects) 	140 B	1/1 files(s)	100%
+	*.txt (LFS Objects)	120 B	1/1 files(s)	100%
+	EOF)
+
+  diff -u <(git lfs migrate info --pointers=by-extension --json 2>/dev/null) <(cat <<-EOF
+	{"files":[{"qualifier":"*.gitattributes","bytes_above":83,"total_above":1,"total":1},{"qualifier":"*.txt","bytes_above":30,"total_above":1,"total":1}],"lfs_objects":[{"qualifier":"*.md","bytes_above":140,"total_above":1,"total":1},{"qualifier":"*.txt","bytes_above":120,"total_above":1,"total":1}]}
+	EOF)
+
+  diff -u <(git lfs migrate info --json 2>/dev/null) <(cat <<-EOF
+	{"files":[{"qualifier":"*.gitattributes","bytes_above":83,"total_above":1,"total":1},{"qualifier":"*.txt","bytes_above":30,"total_above":1,"total":1}],"lfs_objects":[{"qualifier":"LFS Objects","bytes_above":260,"total_above":2,"total":2}]}
+	EOF)
+
+  migrated_head="$(git rev-parse HEAD)"
+
+  assert_ref_unmoved "HEAD" "$original_head" "$migrated_head"
+)
+end_test
+
 begin_test "migrate info (all files tracked, --pointers=no-follow)"
 (
   set -e
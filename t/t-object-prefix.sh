@@ -0,0 +1,112 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+begin_test "object prefix: batch requests are sent under the namespace"
+(
+  set -e
+
+  reponame="object-prefix-batch"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  contents="tenant a"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  # Setting the prefix in an existing clone leaves its objects visible,
+  # since local objects are stored without it.
+  git config lfs.object.prefix "tenant-a"
+  assert_local_object "$oid" "${#contents}"
+  [ ! -e ".git/lfs/objects/tenant-a" ]
+  git lfs fsck
+
+  # Batch requests are made to the usual path, with the namespace.
+  GIT_CURL_VERBOSE=1 git push origin main 2>&1 | tee push.log
+  grep "info/lfs/objects/batch" push.log
+  grep '"namespace":"tenant-a"' push.log
+
+  # The object is kept apart from those pushed without the prefix.
+  refute_server_object "$reponame" "$oid"
+
+  # Nor is the prefix read from .lfsconfig.
+  git config --unset lfs.object.prefix
+  git config -f .lfsconfig lfs.object.prefix "tenant-a"
+  git lfs push --all origin 2>&1 | tee push.log
+  assert_server_object "$reponame" "$oid"
+  rm .lfsconfig
+
+  cd ..
+  git clone -c lfs.object.prefix=tenant-a "$GITSERVER/$reponame" "$reponame-tenant-a"
+  cd "$reponame-tenant-a"
+  [ "$contents" = "$(cat a.dat)" ]
+  assert_local_object "$oid" "${#contents}"
+)
+end_test
+
+begin_test "object prefix: standalone file transfers store objects under the prefix"
+(
+  set -e
+
+  reponame="object-prefix-standalone-file"
+  setup_remote_repo "$reponame"
+
+  git init --bare "$reponame-2.git"
+  gitdir="$(pwd)/$reponame-2.git"
+
+  clone_repo_url "$REMOTEDIR/$reponame.git" "$reponame"
+  git remote set-url origin "file://$gitdir"
+  git config lfs.object.prefix "org/tenant-b"
+
+  git lfs track "*.dat"
+  contents="tenant b"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > b.dat
+  git add .gitattributes b.dat
+  git commit -m "add b.dat"
+
+  git push origin main
+  [ -f "$gitdir/lfs/objects/org/tenant-b/${oid:0:2}/${oid:2:2}/$oid" ]
+  [ ! -e "$gitdir/lfs/objects/${oid:0:2}" ]
+
+  rm -rf .git/lfs/objects
+  git lfs fetch
+  assert_local_object "$oid" "${#contents}"
+  [ ! -e ".git/lfs/objects/org" ]
+)
+end_test
+
+begin_test "object prefix: standalone SFTP transfers store objects under the prefix"
+(
+  set -e
+
+  reponame="object-prefix-standalone-sftp"
+  git init --bare "$reponame.git"
+  objdir="$(pwd)/$reponame-objects"
+  mkdir "$objdir"
+
+  git init "$reponame"
+  cd "$reponame"
+  git remote add origin "../$reponame.git"
+  git config lfs.url "sftp://user@example.com:2222$objdir"
+  git config lfs.sftp.program lfstest-sftp
+  git config lfs.object.prefix "org/tenant-c"
+
+  git lfs track "*.dat"
+  contents="tenant c"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > c.dat
+  git add .gitattributes c.dat
+  git commit -m "add c.dat"
+
+  git push origin main
+  [ "$contents" = "$(cat "$objdir/org/tenant-c/${oid:0:2}/${oid:2:2}/$oid")" ]
+
+  rm -rf .git/lfs/objects
+  git lfs fetch origin main
+  assert_local_object "$oid" "${#contents}"
+)
+end_test
@@ -263,6 +263,26 @@ size 7"
 )
 end_test
 
+begin_test "pointer --file (outside a repository, and with lfs.pointer.version)"
+(
+  set -e
+
+  mkdir pointer-outside-repo
+  cd pointer-outside-repo
+  echo "simple" > some-file
+
+  git lfs pointer --file=some-file >stdout.txt 2>stderr.txt
+  cat stderr.txt
+  [ "Git LFS pointer for some-file" = "$(cat stderr.txt)" ]
+  grep "version https://git-lfs.github.com/spec/v1" stdout.txt
+
+  git init
+  git config lfs.pointer.version "https://hawser.github.com/spec/v1"
+  git lfs pointer --file=some-file 2>&1 | tee pointer.log
+  grep "version https://hawser.github.com/spec/v1" pointer.log
+)
+end_test
+
 begin_test "pointer without args"
 (
   output=$(git lfs pointer 2>&1)
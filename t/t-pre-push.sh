@@ -756,6 +756,90 @@ begin_test "pre-push with their lock on lfs file"
 )
 end_test
 
+begin_test "pre-push with their lock verifies cached locks when the remote is unreachable"
+(
+  set -e
+
+  reponame="pre-push-unreachable-cached-lock"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "locked contents" > locked_theirs.dat
+  git add .gitattributes locked_theirs.dat
+  git commit -m "add locked_theirs.dat"
+  git push origin main
+
+  git lfs lock --json "locked_theirs.dat" | tee lock.log
+  assert_lock lock.log locked_theirs.dat
+
+  pushd "$TRASHDIR" >/dev/null
+    clone_repo "$reponame" "$reponame-assert"
+    git config lfs.locksverify true
+
+    git lfs locks --refresh 2>&1 | tee refresh.log
+    grep "Cached 1 lock(s), 0 of them our own" refresh.log
+
+    # Nothing listens on port 1.
+    git config lfs.url "http://127.0.0.1:1/$reponame.git/info/lfs"
+
+    printf "unauthorized changes" >> locked_theirs.dat
+    git add locked_theirs.dat
+    git commit --no-verify -m "add unauthorized changes"
+
+    git push origin main 2>&1 | tee push.log
+    if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+      echo >&2 "fatal: expected push to fail ..."
+      exit 1
+    fi
+
+    grep "WARNING: Unable to reach remote \"origin\" to verify locks" push.log
+    grep "WARNING: Verifying against the locks cached at .* ago), which may be out of date" push.log
+    grep "Unable to push locked files" push.log
+    grep "* locked_theirs.dat - Git LFS Tests" push.log
+  popd >/dev/null
+)
+end_test
+
+begin_test "pre-push lists locks once per push"
+(
+  set -e
+
+  reponame="pre_push_locks_once"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "initial commit"
+
+  git push origin main
+
+  # any lock path with "theirs" is returned as "their" lock by /locks/verify
+  printf "locked contents" > locked_theirs.dat
+  git add locked_theirs.dat
+  git commit -m "add locked_theirs.dat"
+
+  git lfs lock --json "locked_theirs.dat" | tee lock.log
+  id=$(assert_lock lock.log locked_theirs.dat)
+  assert_server_lock $id
+
+  printf "more contents" > other.dat
+  git add other.dat
+  git commit -m "add other.dat"
+
+  sha=$(git rev-parse HEAD)
+  refs="refs/heads/main $sha refs/heads/main 0000000000000000000000000000000000000000"
+
+  printf "%s\n%s\n" "$refs" "$refs" |
+    GIT_TRACE=1 git lfs pre-push origin "$GITSERVER/$reponame" 2>&1 |
+    tee push.log
+
+  [ "1" -eq "$(grep -c "HTTP: POST .*/locks/verify" push.log)" ]
+  [ "1" -eq "$(grep -c "^\* locked_theirs.dat" push.log)" ]
+)
+end_test
+
 begin_test "pre-push with their lock on non-lfs lockable file"
 (
   set -e
@@ -814,6 +898,97 @@ begin_test "pre-push with their lock on non-lfs lockable file"
 )
 end_test
 
+begin_test "pre-push with their lock and lfs.lockverify.block"
+(
+  set -e
+
+  reponame="pre_push_lockverify_block"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "locked contents" > locked_theirs.dat
+  git add .gitattributes locked_theirs.dat
+  git commit -m "add locked_theirs.dat"
+  git push origin main
+
+  git lfs lock --json "locked_theirs.dat" | tee lock.log
+  id=$(assert_lock lock.log locked_theirs.dat)
+  assert_server_lock $id
+
+  pushd "$TRASHDIR" >/dev/null
+    clone_repo "$reponame" "$reponame-assert"
+    git config lfs.locksverify true
+    git config lfs.lockverify.block block
+
+    # Deleting a file uploads no object for it, so only the paths changed by
+    # the pushed commits show it is locked.
+    printf "new contents" > new.dat
+    git add new.dat
+    git rm locked_theirs.dat
+    git commit --no-verify -m "remove locked_theirs.dat"
+
+    git push origin main 2>&1 | tee push.log
+    res="${PIPESTATUS[0]}"
+    if [ "0" -eq "$res" ]; then
+      echo "push should fail"
+      exit 1
+    fi
+
+    grep "Pushing changes to files locked by others:" push.log
+    grep "* locked_theirs.dat - Git LFS Tests (refs: main), changed in $(git rev-parse --short=10 HEAD)" push.log
+    grep "ERROR: Cannot push changes to locked files." push.log
+    refute_server_object "$reponame" "$(calc_oid "new contents")"
+
+    git config lfs.lockverify.block warn
+
+    git push origin main 2>&1 | tee push.log
+    grep "* locked_theirs.dat - Git LFS Tests" push.log
+    grep "WARNING: The above files are locked by others" push.log
+    assert_server_object "$reponame" "$(calc_oid "new contents")"
+  popd >/dev/null
+)
+end_test
+
+begin_test "pre-push with their lock and lfs.lockverify.block off"
+(
+  set -e
+
+  reponame="pre_push_lockverify_block_off"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "locked contents" > locked_theirs.dat
+  git add .gitattributes locked_theirs.dat
+  git commit -m "add locked_theirs.dat"
+  git push origin main
+
+  git lfs lock --json "locked_theirs.dat" | tee lock.log
+  assert_lock lock.log locked_theirs.dat
+
+  pushd "$TRASHDIR" >/dev/null
+    clone_repo "$reponame" "$reponame-assert"
+    git config lfs.locksverify true
+
+    git rm locked_theirs.dat
+    git commit --no-verify -m "remove locked_theirs.dat"
+
+    git push origin main 2>&1 | tee push.log
+    [ "0" -eq "$(grep -c "locked by others" push.log)" ]
+
+    git config lfs.lockverify.block invalid
+    printf "new contents" > new.dat
+    git add new.dat
+    git commit --no-verify -m "add new.dat"
+
+    git push origin main 2>&1 | tee push.log
+    grep "Invalid lfs.lockverify.block value \"invalid\"" push.log
+    [ "0" -eq "$(grep -c "locked by others" push.log)" ]
+  popd >/dev/null
+)
+end_test
+
 begin_test "pre-push locks verify 5xx with verification enabled"
 (
   set -e
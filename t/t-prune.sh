@@ -366,6 +366,88 @@ begin_test "prune keep recent"
 )
 end_test
 
+begin_test "prune keep recent (incoming and outgoing offsets)"
+(
+  set -e
+
+  reponame="prune_recent_incoming_outgoing"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  content_head="HEAD"
+  content_local="Local branch tip"
+  content_remote="Remote branch tip"
+  oid_head=$(calc_oid "$content_head")
+  oid_local=$(calc_oid "$content_local")
+  oid_remote=$(calc_oid "$content_remote")
+
+  echo "[
+  {
+    \"CommitDate\":\"$(get_date -10d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_head}, \"Data\":\"$content_head\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -8d)\",
+    \"ParentBranches\":[\"main\"],
+    \"NewBranch\":\"local_branch\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_local}, \"Data\":\"$content_local\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -8d)\",
+    \"ParentBranches\":[\"main\"],
+    \"NewBranch\":\"remote_branch\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_remote}, \"Data\":\"$content_remote\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git checkout main
+
+  # push everything so that's not a reason to retain, and leave only the
+  # remote tracking ref of remote_branch
+  git push origin main:main local_branch:local_branch remote_branch:remote_branch
+  git branch -D remote_branch
+
+  # keep local refs for 10 days, and remote refs for 6
+  git config lfs.fetchrecentrefsdays 5
+  git config lfs.fetchrecentremoterefs true
+  git config lfs.fetchrecentcommitsdays 0
+  git config lfs.pruneoffsetdays 5
+  git config lfs.pruneincomingoffsetdays 1
+
+  git lfs prune --dry-run 2>&1 | tee prune.log
+  grep "prune: 1 object(s) retained only by the recent windows: 1 outgoing (offset 5 day(s)), 0 incoming (offset 1 day(s))" prune.log
+  grep " \* $oid_local (${#content_local} B) retained by outgoing" prune.log
+  grep "prune: 1 file(s) would be pruned" prune.log
+  assert_local_object "$oid_remote" "${#content_remote}"
+
+  # keep remote refs for 10 days too
+  git config lfs.pruneincomingoffsetdays 5
+
+  git lfs prune --dry-run 2>&1 | tee prune.log
+  grep "prune: 2 object(s) retained only by the recent windows: 1 outgoing (offset 5 day(s)), 1 incoming (offset 5 day(s))" prune.log
+  grep " \* $oid_remote (${#content_remote} B) retained by incoming" prune.log
+  [ "0" -eq "$(grep -c "would be pruned" prune.log)" ]
+
+  # keep local refs for 6 days, and remote refs for 10
+  git config lfs.pruneoutgoingoffsetdays 1
+
+  git lfs prune --verbose 2>&1 | tee prune.log
+  grep "prune: 3 local object(s), 2 retained, done." prune.log
+  grep "$oid_local" prune.log
+
+  assert_local_object "$oid_head" "${#content_head}"
+  assert_local_object "$oid_remote" "${#content_remote}"
+  refute_local_object "$oid_local"
+)
+end_test
+
 begin_test "prune remote tests"
 (
   set -e
@@ -532,6 +614,86 @@ begin_test "prune verify"
 )
 end_test
 
+begin_test "prune --verify-unpushed"
+(
+  set -e
+
+  reponame="prune_verify_unpushed"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  content_head="HEAD content"
+  content_commit2_missing="Content for commit 2 (missing on remote)"
+  content_commit1="Content for commit 1 (prune)"
+  content_unpushed="Content for unpushed commit"
+  oid_head=$(calc_oid "$content_head")
+  oid_commit2_missing=$(calc_oid "$content_commit2_missing")
+  oid_commit1=$(calc_oid "$content_commit1")
+  oid_unpushed=$(calc_oid "$content_unpushed")
+
+  echo "[
+  {
+    \"CommitDate\":\"$(get_date -50d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_commit1}, \"Data\":\"$content_commit1\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -40d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_commit2_missing}, \"Data\":\"$content_commit2_missing\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -25d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_head}, \"Data\":\"$content_head\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin main
+
+  # an old commit on a branch which was never pushed
+  git checkout -b unpushed HEAD~1
+  echo "[
+  {
+    \"CommitDate\":\"$(get_date -30d)\",
+    \"Files\":[
+      {\"Filename\":\"other.dat\",\"Size\":${#content_unpushed}, \"Data\":\"$content_unpushed\"}]
+  }
+  ]" | lfstest-testutils addcommits
+  git checkout main
+
+  git config lfs.fetchrecentrefsdays 0
+  git config lfs.fetchrecentremoterefs true
+  git config lfs.fetchrecentcommitsdays 0
+  git config lfs.pruneoffsetdays 1
+
+  # the remote tracking refs say commit 2 was pushed, but the server has lost
+  # its object
+  delete_server_object "remote_$reponame" "$oid_commit2_missing"
+
+  # without the unpushed check, the unpushed object would be pruned too
+  git lfs prune --dry-run --keep-unpushed=false --verbose 2>&1 | tee prune.log
+  grep "prune: 3 file(s) would be pruned" prune.log
+  grep "$oid_unpushed" prune.log
+
+  git lfs prune --keep-unpushed=false --verify-unpushed 2>&1 | tee prune.log
+  grep "prune: 4 local object(s), 3 retained, 1 verified with remote, done." prune.log
+  grep "prune: 2 object(s) missing on remote retained, which unpushed commits alone would not have kept" prune.log
+  grep "$oid_commit2_missing" prune.log
+  grep "$oid_unpushed" prune.log
+  grep "prune: Deleting objects: 100% (1/1), done." prune.log
+
+  assert_local_object "$oid_head" "${#content_head}"
+  assert_local_object "$oid_commit2_missing" "${#content_commit2_missing}"
+  assert_local_object "$oid_unpushed" "${#content_unpushed}"
+  refute_local_object "$oid_commit1"
+)
+end_test
+
 begin_test "prune verify large numbers of refs"
 (
   set -e
@@ -961,6 +1123,101 @@ begin_test "prune recent changes with --recent"
 )
 end_test
 
+begin_test "prune --keep-since"
+(
+  set -e
+
+  reponame="prune_keep_since"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat"
+
+  content_old="Prune: old commit on HEAD"
+  content_before="Keep: checked out on HEAD at the date"
+  content_after="Keep: commit on HEAD after the date"
+  content_head="Keep: HEAD"
+  content_feature="Keep: branch with a commit after the date"
+  content_ancient="Prune: branch with no commit after the date"
+  oid_old=$(calc_oid "$content_old")
+  oid_before=$(calc_oid "$content_before")
+  oid_after=$(calc_oid "$content_after")
+  oid_head=$(calc_oid "$content_head")
+  oid_feature=$(calc_oid "$content_feature")
+  oid_ancient=$(calc_oid "$content_ancient")
+
+  echo "[
+  {
+    \"CommitDate\":\"2023-01-10T12:00:00Z\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_old}, \"Data\":\"$content_old\"}]
+  },
+  {
+    \"CommitDate\":\"2023-02-01T12:00:00Z\",
+    \"NewBranch\":\"ancient\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_ancient}, \"Data\":\"$content_ancient\"}]
+  },
+  {
+    \"CommitDate\":\"2023-06-01T12:00:00Z\",
+    \"ParentBranches\":[\"main\"],
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_before}, \"Data\":\"$content_before\"}]
+  },
+  {
+    \"CommitDate\":\"2023-08-01T12:00:00Z\",
+    \"NewBranch\":\"feature\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_feature}, \"Data\":\"$content_feature\"}]
+  },
+  {
+    \"CommitDate\":\"2023-09-01T12:00:00Z\",
+    \"ParentBranches\":[\"main\"],
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_after}, \"Data\":\"$content_after\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -1d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_head}, \"Data\":\"$content_head\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin main feature ancient
+
+  # The dates take precedence over the recent windows, however long.
+  git config lfs.fetchrecentrefsdays 10000
+  git config lfs.fetchrecentcommitsdays 10000
+
+  git lfs prune --dry-run --verbose --keep-since 2023-07-01 2>&1 | tee prune.log
+  grep "prune: 6 local object(s), 4 retained, done." prune.log
+  grep "prune: 3 object(s) retained only by refs and commits since 2023-07-01T00:00:00" prune.log
+  grep "prune: 2 file(s) would be pruned" prune.log
+  grep " \* $oid_old" prune.log
+  grep " \* $oid_ancient" prune.log
+
+  git lfs prune --since 2023-07-01
+
+  refute_local_object "$oid_old"
+  refute_local_object "$oid_ancient"
+  assert_local_object "$oid_before" "${#content_before}"
+  assert_local_object "$oid_after" "${#content_after}"
+  assert_local_object "$oid_feature" "${#content_feature}"
+  assert_local_object "$oid_head" "${#content_head}"
+
+  git lfs prune --keep-since 2023-13-01 2>&1 | tee prune.log
+  grep "cannot parse --keep-since=2023-13-01" prune.log
+  git lfs prune --keep-since "last week" 2>&1 | tee prune.log
+  grep "cannot parse --keep-since=last week" prune.log
+  git lfs prune --keep-since 2023-07-01 --recent 2>&1 | tee prune.log
+  grep "Cannot combine --keep-since with --recent or --force" prune.log
+  git lfs prune --keep-since 2023-07-01 --since 2023-07-01 2>&1 | tee prune.log
+  grep "Cannot specify both --since and --keep-since" prune.log
+  assert_local_object "$oid_after" "${#content_after}"
+)
+end_test
+
 begin_test "prune --force"
 (
   set -e
@@ -1023,6 +1280,93 @@ begin_test "prune --force"
 )
 end_test
 
+begin_test "prune --cache-size-limit"
+(
+  set -e
+
+  reponame="prune_cache_size_limit"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat"
+
+  content_1="version 01"
+  content_2="version 02"
+  content_3="version 03"
+  content_current="version 04"
+  oid_1=$(calc_oid "$content_1")
+  oid_2=$(calc_oid "$content_2")
+  oid_3=$(calc_oid "$content_3")
+  oid_current=$(calc_oid "$content_current")
+
+  echo "[
+  {
+    \"CommitDate\":\"$(get_date -30d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_1}, \"Data\":\"$content_1\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -29d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_2}, \"Data\":\"$content_2\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -28d)\",
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_3}, \"Data\":\"$content_3\"}]
+  },
+  {
+    \"Files\":[
+      {\"Filename\":\"file.dat\",\"Size\":${#content_current}, \"Data\":\"$content_current\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin main
+
+  git config lfs.fetchrecentrefsdays 0
+  git config lfs.fetchrecentcommitsdays 0
+
+  # Object 2 was accessed least recently, then object 3, then object 1.
+  touch -d "2020-01-03 00:00:00" ".git/lfs/objects/${oid_1:0:2}/${oid_1:2:2}/$oid_1"
+  touch -d "2020-01-01 00:00:00" ".git/lfs/objects/${oid_2:0:2}/${oid_2:2:2}/$oid_2"
+  touch -d "2020-01-02 00:00:00" ".git/lfs/objects/${oid_3:0:2}/${oid_3:2:2}/$oid_3"
+
+  git lfs prune --cache-size-limit=40B 2>&1 | tee prune.log
+  grep "within the cache size limit of 40 B" prune.log
+  assert_local_object "$oid_2" "${#content_2}"
+
+  git lfs prune --dry-run --verbose --cache-size-limit=30B 2>&1 | tee prune.log
+  grep "prune: 1 file(s) would be pruned" prune.log
+  grep "$oid_2" prune.log
+  assert_local_object "$oid_2" "${#content_2}"
+
+  git config lfs.prune.cachesizelimit 25B
+  git lfs prune --verbose 2>&1 | tee prune.log
+  grep "evicting 2 least recently accessed object(s) to stay within the cache size limit of 25 B" prune.log
+  refute_local_object "$oid_2"
+  refute_local_object "$oid_3"
+  assert_local_object "$oid_1" "${#content_1}"
+
+  git lfs prune --cache-size-limit=1B 2>&1 | tee prune.log
+  grep "still take 10 B after pruning, over the cache size limit of 1 B" prune.log
+  refute_local_object "$oid_1"
+  assert_local_object "$oid_current" "${#content_current}"
+
+  git lfs prune --cache-size-limit=nonsense 2>&1 | tee prune.log
+  grep "cannot parse --cache-size-limit=<size>" prune.log
+
+  git config lfs.prune.cachesizelimit nonsense
+  git lfs prune 2>&1 | tee prune.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected prune to fail with an invalid lfs.prune.cachesizelimit"
+    exit 1
+  fi
+  grep "cannot parse lfs.prune.cachesizelimit=\"nonsense\"" prune.log
+  assert_local_object "$oid_current" "${#content_current}"
+)
+end_test
+
 begin_test "prune does not invoke external diff programs"
 (
   set -e
@@ -1041,3 +1385,257 @@ begin_test "prune does not invoke external diff programs"
   git lfs prune
 )
 end_test
+
+begin_test "prune --objects-from"
+(
+  set -e
+
+  reponame="prune_objects_from"
+  setup_remote_repo "remote_$reponame"
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat"
+
+  content_secret="a leaked secret"
+  content_current="still current"
+  content_keep="keep this one"
+  oid_secret=$(calc_oid "$content_secret")
+  oid_current=$(calc_oid "$content_current")
+  oid_keep=$(calc_oid "$content_keep")
+
+  printf "%s" "$content_secret" > secret.dat
+  printf "%s" "$content_current" > current.dat
+  printf "%s" "$content_keep" > keep.dat
+  git add .gitattributes secret.dat current.dat keep.dat
+  git commit -m "add files"
+
+  # As if the history had been rewritten to remove the secret.
+  git rm secret.dat
+  git commit --amend -m "add files without the secret"
+  git reflog expire --expire=now --all
+
+  assert_local_object "$oid_secret" "${#content_secret}"
+
+  missing_oid=$(calc_oid "not stored here")
+  printf "# objects to delete\n%s\n\n%s\n%s\n" "$oid_secret" "$oid_current" "$missing_oid" > objects.txt
+
+  git lfs prune --objects-from objects.txt --dry-run --verbose 2>&1 | tee prune.log
+  grep "2 of 3 listed object(s) would be deleted" prune.log
+  grep "warning: $oid_current (current.dat) is still reachable" prune.log
+  grep "warning: $oid_secret" prune.log && exit 1
+  grep " \* $missing_oid is not in the local store" prune.log
+  assert_local_object "$oid_secret" "${#content_secret}"
+  assert_local_object "$oid_current" "${#content_current}"
+
+  # Without a terminal to ask on, --force is required.
+  git lfs prune --objects-from objects.txt </dev/null 2>&1 | tee prune.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected prune --objects-from without --force to fail"
+    exit 1
+  fi
+  grep "pass --force" prune.log
+  assert_local_object "$oid_secret" "${#content_secret}"
+
+  echo "$oid_secret" | git lfs prune --objects-from - --force 2>&1 | tee prune.log
+  grep "1 of 1 listed object(s) deleted" prune.log
+  refute_local_object "$oid_secret"
+  assert_local_object "$oid_current" "${#content_current}"
+  assert_local_object "$oid_keep" "${#content_keep}"
+
+  echo "not-an-oid" > bad.txt
+  git lfs prune --objects-from bad.txt --force 2>&1 | tee prune.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected prune --objects-from with a bad OID to fail"
+    exit 1
+  fi
+  grep "invalid OID \"not-an-oid\" on line 1" prune.log
+)
+end_test
+
+begin_test "prune retention rules"
+(
+  set -e
+
+  reponame="prune_retention_rules"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.uasset" "*.dll" "*.dat"
+
+  content_asset_1="Prune: asset version 1"
+  content_asset_2="Keep: asset version 2"
+  content_asset_3="Keep: asset version 3"
+  content_asset_4="Keep: asset version 4"
+  content_binary_1="Prune: binary replaced before the last 30 days"
+  content_binary_2="Keep: binary in use 30 days ago"
+  content_binary_3="Keep: binary in use 10 days ago"
+  content_binary_4="Keep: binary in the current checkout"
+  content_other_1="Prune: other file with no rule"
+  content_other_2="Keep: other file in the current checkout"
+  oid_asset_1=$(calc_oid "$content_asset_1")
+  oid_asset_2=$(calc_oid "$content_asset_2")
+  oid_asset_3=$(calc_oid "$content_asset_3")
+  oid_asset_4=$(calc_oid "$content_asset_4")
+  oid_binary_1=$(calc_oid "$content_binary_1")
+  oid_binary_2=$(calc_oid "$content_binary_2")
+  oid_binary_3=$(calc_oid "$content_binary_3")
+  oid_binary_4=$(calc_oid "$content_binary_4")
+  oid_other_1=$(calc_oid "$content_other_1")
+  oid_other_2=$(calc_oid "$content_other_2")
+
+  echo "[
+  {
+    \"CommitDate\":\"$(get_date -40d)\",
+    \"Files\":[
+      {\"Filename\":\"Content/map.uasset\",\"Size\":${#content_asset_1}, \"Data\":\"$content_asset_1\"},
+      {\"Filename\":\"Binaries/tool.dll\",\"Size\":${#content_binary_1}, \"Data\":\"$content_binary_1\"},
+      {\"Filename\":\"other.dat\",\"Size\":${#content_other_1}, \"Data\":\"$content_other_1\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -39d)\",
+    \"Files\":[
+      {\"Filename\":\"Content/map.uasset\",\"Size\":${#content_asset_2}, \"Data\":\"$content_asset_2\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -38d)\",
+    \"Files\":[
+      {\"Filename\":\"Content/map.uasset\",\"Size\":${#content_asset_3}, \"Data\":\"$content_asset_3\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -35d)\",
+    \"Files\":[
+      {\"Filename\":\"Binaries/tool.dll\",\"Size\":${#content_binary_2}, \"Data\":\"$content_binary_2\"}]
+  },
+  {
+    \"CommitDate\":\"$(get_date -10d)\",
+    \"Files\":[
+      {\"Filename\":\"Binaries/tool.dll\",\"Size\":${#content_binary_3}, \"Data\":\"$content_binary_3\"}]
+  },
+  {
+    \"Files\":[
+      {\"Filename\":\"Content/map.uasset\",\"Size\":${#content_asset_4}, \"Data\":\"$content_asset_4\"},
+      {\"Filename\":\"Binaries/tool.dll\",\"Size\":${#content_binary_4}, \"Data\":\"$content_binary_4\"},
+      {\"Filename\":\"other.dat\",\"Size\":${#content_other_2}, \"Data\":\"$content_other_2\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin main
+
+  git config lfs.fetchrecentrefsdays 0
+  git config lfs.fetchrecentcommitsdays 0
+
+  # The rules are read from .lfsconfig.
+  git config -f .lfsconfig lfs.pruneretain.*.uasset.versions 3
+  git config -f .lfsconfig lfs.pruneretain.Binaries/.days 30
+
+  git lfs prune --dry-run --verbose 2>&1 | tee prune.log
+  grep "prune: 10 local object(s), 7 retained, done." prune.log
+  grep "prune: 3 file(s) would be pruned" prune.log
+  [ "0" -eq "$(grep -c "unsafe lfsconfig keys" prune.log)" ]
+
+  git lfs prune
+
+  refute_local_object "$oid_asset_1"
+  refute_local_object "$oid_binary_1"
+  refute_local_object "$oid_other_1"
+  assert_local_object "$oid_asset_2" "${#content_asset_2}"
+  assert_local_object "$oid_asset_3" "${#content_asset_3}"
+  assert_local_object "$oid_asset_4" "${#content_asset_4}"
+  assert_local_object "$oid_binary_2" "${#content_binary_2}"
+  assert_local_object "$oid_binary_3" "${#content_binary_3}"
+  assert_local_object "$oid_binary_4" "${#content_binary_4}"
+  assert_local_object "$oid_other_2" "${#content_other_2}"
+
+  # Fewer versions, and fewer days, retain less.
+  git config -f .lfsconfig lfs.pruneretain.*.uasset.versions 2
+  git config -f .lfsconfig lfs.pruneretain.Binaries/.days 5
+
+  git lfs prune
+
+  refute_local_object "$oid_asset_2"
+  refute_local_object "$oid_binary_2"
+  assert_local_object "$oid_asset_3" "${#content_asset_3}"
+  assert_local_object "$oid_binary_3" "${#content_binary_3}"
+)
+end_test
+
+begin_test "prune --report"
+(
+  set -e
+
+  reponame="prune_report"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.uasset" "*.dat"
+
+  content_map_1="old map"
+  content_map_2="current map"
+  content_mesh_1="old mesh, which is the largest"
+  content_mesh_2="current mesh"
+  content_file_1="old file, in the middle"
+  content_file_2="current file"
+  content_unreferenced="an object no file refers to"
+  oid_map_1=$(calc_oid "$content_map_1")
+  oid_mesh_1=$(calc_oid "$content_mesh_1")
+  oid_file_1=$(calc_oid "$content_file_1")
+  oid_unreferenced=$(calc_oid "$content_unreferenced")
+
+  echo "[
+  {
+    \"CommitDate\":\"$(get_date -30d)\",
+    \"Files\":[
+      {\"Filename\":\"Content/Maps/level.uasset\",\"Size\":${#content_map_1}, \"Data\":\"$content_map_1\"},
+      {\"Filename\":\"Content/mesh.uasset\",\"Size\":${#content_mesh_1}, \"Data\":\"$content_mesh_1\"},
+      {\"Filename\":\"file.dat\",\"Size\":${#content_file_1}, \"Data\":\"$content_file_1\"}]
+  },
+  {
+    \"Files\":[
+      {\"Filename\":\"Content/Maps/level.uasset\",\"Size\":${#content_map_2}, \"Data\":\"$content_map_2\"},
+      {\"Filename\":\"Content/mesh.uasset\",\"Size\":${#content_mesh_2}, \"Data\":\"$content_mesh_2\"},
+      {\"Filename\":\"file.dat\",\"Size\":${#content_file_2}, \"Data\":\"$content_file_2\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin main
+
+  printf "%s" "$content_unreferenced" | git lfs clean >/dev/null
+  assert_local_object "$oid_unreferenced" "${#content_unreferenced}"
+
+  git config lfs.fetchrecentrefsdays 0
+  git config lfs.fetchrecentcommitsdays 0
+
+  size=$((${#content_map_1} + ${#content_mesh_1} + ${#content_file_1} + ${#content_unreferenced}))
+
+  git lfs prune --report 2>&1 | tee prune.log
+  grep "prune: $size B reclaimable from 4 object(s)" prune.log
+  grep "prune: 4 file(s) would be pruned" prune.log
+  grep -A 4 "prune: by directory:" prune.log | tr -d '\r' | tr -s ' \t' ' ' | sed 's/ *$//' > dirs.log
+  cat dirs.log
+  [ "$(sed -n 2p dirs.log)" = " Content 1 object(s) ${#content_mesh_1} B" ]
+  [ "$(sed -n 3p dirs.log)" = " (unreferenced) 1 object(s) ${#content_unreferenced} B" ]
+  [ "$(sed -n 4p dirs.log)" = " . 1 object(s) ${#content_file_1} B" ]
+  [ "$(sed -n 5p dirs.log)" = " Content/Maps 1 object(s) ${#content_map_1} B" ]
+  grep -A 3 "prune: by extension:" prune.log | tr -d '\r' | tr -s ' \t' ' ' | sed 's/ *$//' > exts.log
+  cat exts.log
+  [ "$(sed -n 2p exts.log)" = " *.uasset 2 object(s) $((${#content_mesh_1} + ${#content_map_1})) B" ]
+  [ "$(sed -n 3p exts.log)" = " (unreferenced) 1 object(s) ${#content_unreferenced} B" ]
+
+  assert_local_object "$oid_map_1" "${#content_map_1}"
+  assert_local_object "$oid_mesh_1" "${#content_mesh_1}"
+  assert_local_object "$oid_file_1" "${#content_file_1}"
+  assert_local_object "$oid_unreferenced" "${#content_unreferenced}"
+
+  git lfs prune --report --json 2>&1 | tee prune.json
+  grep "^{\"count\":4,\"size\":$size,\"directories\":\[{\"name\":\"Content\",\"count\":1," prune.json
+  grep "\"extensions\":\[{\"name\":\"\*.uasset\",\"count\":2," prune.json
+  grep "{\"oid\":\"$oid_map_1\",\"size\":${#content_map_1},\"name\":\"Content/Maps/level.uasset\"}" prune.json
+  grep "{\"oid\":\"$oid_unreferenced\",\"size\":${#content_unreferenced},\"name\":\"\"}" prune.json
+  assert_local_object "$oid_map_1" "${#content_map_1}"
+
+  git lfs prune --json 2>&1 | tee prune.log
+  grep "Cannot use --json without --report" prune.log
+)
+end_test
@@ -265,9 +265,6 @@ begin_test "pull with invalid insteadof"
 
   # set insteadOf to rewrite the href of downloading LFS object.
   git config url."$GITSERVER/storage/invalid".insteadOf "$GITSERVER/storage/"
-  # Enable href rewriting explicitly.
-  git config lfs.transfer.enablehrefrewrite true
-
   set +e
   git lfs pull > pull.log 2>&1
   res=$?
@@ -278,8 +275,8 @@ begin_test "pull with invalid insteadof"
   # check rewritten href is used to download LFS object.
   grep "LFS: Repository or object not found: $GITSERVER/storage/invalid" pull.log
 
-  # lfs-pull succeed after unsetting enableHrefRewrite config
-  git config --unset lfs.transfer.enablehrefrewrite
+  # lfs-pull succeed after disabling href rewriting
+  git config lfs.transfer.enablehrefrewrite false
   git lfs pull
 )
 end_test
@@ -357,3 +354,128 @@ begin_test "pull: outside git repository"
   grep "Not in a git repository" pull.log
 )
 end_test
+
+begin_test "pull with --max-size"
+(
+  set -e
+
+  reponame="pull-max-size"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir dir
+  printf "small" > small.dat
+  printf "much larger contents" > large.dat
+  printf "also larger contents" > dir/large.dat
+  git add .gitattributes small.dat large.dat dir
+  git commit -m "add files"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$reponame-clone"
+  cd "$reponame-clone"
+
+  git lfs pull --max-size=10 2>&1 | tee pull.log
+  grep "Skipped 2 object(s) larger than 10 B (40 B in total)" pull.log
+
+  [ "small" = "$(cat small.dat)" ]
+  assert_local_object "$(calc_oid "small")" 5
+  refute_local_object "$(calc_oid "much larger contents")"
+  git lfs pointer --check --file large.dat
+  git lfs pointer --check --file dir/large.dat
+
+  git lfs pull --max-size=10 --include="dir/*" 2>&1 | tee pull.log
+  grep "Skipped 1 object(s) larger than 10 B (20 B in total)" pull.log
+
+  git lfs pull 2>&1 | tee pull.log
+  grep "Skipped" pull.log && exit 1
+  [ "much larger contents" = "$(cat large.dat)" ]
+  [ "also larger contents" = "$(cat dir/large.dat)" ]
+)
+end_test
+
+begin_test "pull with lfs.smudge.stream"
+(
+  set -e
+
+  reponame="pull-smudge-stream"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir dir
+  printf "streamed" > a.dat
+  printf "streamed" > dir/copy.dat
+  printf "other" > b.dat
+  git add .gitattributes a.dat b.dat dir
+  git commit -m "add files"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$reponame-clone"
+  cd "$reponame-clone"
+  git config lfs.smudge.stream true
+
+  GIT_TRACE=1 git lfs pull 2>&1 | tee pull.log
+  grep "fetch a.dat \[$(calc_oid "streamed")\] to the working tree" pull.log ||
+    grep "fetch dir/copy.dat \[$(calc_oid "streamed")\] to the working tree" pull.log
+  [ "streamed" = "$(cat a.dat)" ]
+  [ "streamed" = "$(cat dir/copy.dat)" ]
+  [ "other" = "$(cat b.dat)" ]
+  [ -z "$(git status --porcelain --untracked-files=no)" ]
+
+  # The objects are added to the local object store as the index is updated,
+  # if they weren't cloned into it.
+  assert_local_object "$(calc_oid "streamed")" 8
+  assert_local_object "$(calc_oid "other")" 5
+
+  # Objects are streamed to files which are missing too.
+  rm b.dat
+  git lfs pull
+  [ "other" = "$(cat b.dat)" ]
+  [ -z "$(git status --porcelain --untracked-files=no)" ]
+)
+end_test
+
+begin_test "pull with lfs.smudge.stream and lfs.checkout.hardlink"
+(
+  set -e
+
+  reponame="pull-smudge-stream-hardlink"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "streamed" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$reponame-clone"
+  cd "$reponame-clone"
+  git config lfs.smudge.stream true
+  git config lfs.checkout.hardlink true
+
+  oid="$(calc_oid "streamed")"
+  GIT_TRACE=1 git lfs pull 2>&1 | tee pull.log
+  [ "streamed" = "$(cat a.dat)" ]
+  assert_local_object "$oid" 8
+
+  if grep "smudge: cloned streamed" pull.log; then
+    echo "cloned the object into the local store, which comes first"
+    exit 0
+  fi
+  grep "smudge: linked streamed" pull.log
+  [ a.dat -ef ".git/lfs/objects/${oid:0:2}/${oid:2:2}/$oid" ]
+
+  # The file is read-only, so the object can't be changed through it.
+  ls -l a.dat | cut -c1-10 | grep w && exit 1
+  if [ "$(id -u)" -ne 0 ]; then
+    echo "changed" >> a.dat && exit 1
+  fi
+  git lfs fsck 2>&1 | tee fsck.log
+  [ "0" -eq "${PIPESTATUS[0]}" ]
+)
+end_test
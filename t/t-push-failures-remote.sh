@@ -120,3 +120,33 @@ begin_test "push: upload file with api 500"
   push_fail_test "status-batch-500"
 )
 end_test
+
+begin_test "push --continue: upload remaining files past storage 403"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-continue"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "hi" > good.dat
+  printf "status-storage-403" > bad.dat
+  git add .gitattributes good.dat bad.dat
+  git commit -m "welp"
+
+  bad_oid="$(calc_oid "status-storage-403")"
+
+  git lfs push --continue origin main 2>&1 | tee push.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected \`git lfs push --continue origin main\` to fail ..."
+    exit 1
+  fi
+
+  grep "LFS upload failed for 1 object(s):" push.log
+  grep "  bad.dat ($bad_oid): " push.log
+
+  assert_server_object "$reponame" "$(calc_oid "hi")"
+  refute_server_object "$reponame" "$bad_oid"
+)
+end_test
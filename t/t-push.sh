@@ -673,9 +673,6 @@ begin_test "push with invalid pushInsteadof"
 
   # set pushInsteadOf to rewrite the href of uploading LFS object.
   git config url."$GITSERVER/storage/invalid".pushInsteadOf "$GITSERVER/storage/"
-  # Enable href rewriting explicitly.
-  git config lfs.transfer.enablehrefrewrite true
-
   set +e
   git lfs push origin main > push.log 2>&1
   res=$?
@@ -686,8 +683,8 @@ begin_test "push with invalid pushInsteadof"
   # check rewritten href is used to upload LFS object.
   grep "LFS: Authorization error: $GITSERVER/storage/invalid" push.log
 
-  # lfs-push succeed after unsetting enableHrefRewrite config
-  git config --unset lfs.transfer.enablehrefrewrite
+  # lfs-push succeed after disabling href rewriting
+  git config lfs.transfer.enablehrefrewrite false
   git lfs push origin main
 )
 end_test
@@ -872,3 +869,134 @@ begin_test "storage upload with compression"
   popd
 )
 end_test
+
+begin_test "push with endpoint routes"
+(
+  set -e
+
+  reponame="push-endpoint-routes"
+  setup_remote_repo "$reponame-routed"
+  push_repo_setup "$reponame"
+
+  routed="$(repo_endpoint "$GITSERVER" "$reponame-routed")"
+  git config "lfs.$routed.locksverify" false
+  git config -f .lfsconfig "lfs.route.routed/**.url" "$routed"
+  git add .lfsconfig
+
+  mkdir routed
+  echo "push b" > routed/b.dat
+  git add routed/b.dat
+  git commit -m "add routed/b.dat"
+
+  GIT_TRACE=1 git lfs push origin main 2>&1 | tee push.log
+  grep "tq: routing 1 object(s) to \"$routed\"" push.log
+
+  assert_server_object "$reponame" "$(calc_oid_file a.dat)"
+  refute_server_object "$reponame" "$(calc_oid_file routed/b.dat)"
+  assert_server_object "$reponame-routed" "$(calc_oid_file routed/b.dat)"
+  refute_server_object "$reponame-routed" "$(calc_oid_file a.dat)"
+)
+end_test
+
+begin_test "push --verify-objects"
+(
+  set -e
+
+  reponame="push-verify-objects"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "kept" > kept.dat
+  git add .gitattributes kept.dat
+  git commit -m "add kept.dat"
+
+  git lfs push --verify-objects origin main 2>&1 | tee push.log
+  grep "Uploading LFS objects" push.log
+  [ "0" -eq "$(grep -c "verification failed" push.log)" ]
+  assert_server_object "$reponame" "$(calc_oid_file kept.dat)"
+
+  # the server acknowledges this upload, but doesn't store the object
+  printf "storage-upload-lost" > lost.dat
+  git add lost.dat
+  git commit -m "add lost.dat"
+  lost_oid="$(calc_oid_file lost.dat)"
+
+  git lfs push origin main 2>&1 | tee push.log
+  [ "0" -eq "$(grep -c "verification failed" push.log)" ]
+  refute_server_object "$reponame" "$lost_oid"
+
+  set +e
+  git lfs push --verify-objects origin main 2>&1 | tee push.log
+  res="${PIPESTATUS[0]}"
+  set -e
+  if [ "0" -eq "$res" ]; then
+    echo >&2 "fatal: expected push --verify-objects to fail"
+    exit 1
+  fi
+
+  grep "LFS upload verification failed for 1 object(s):" push.log
+  grep "lost.dat ($lost_oid)" push.log
+  [ "0" -eq "$(grep -c "kept.dat" push.log)" ]
+)
+end_test
+
+begin_test "push and fetch with --metrics-file"
+(
+  set -e
+
+  push_repo_setup "push-metrics-file"
+
+  GIT_LFS_METRICS_FILE="$(pwd)/push.prom" git lfs push origin main
+  cat push.prom
+  grep '^# TYPE git_lfs_transfer_objects_total counter$' push.prom
+  grep '^git_lfs_transfer_objects_total{direction="upload"} 1$' push.prom
+  grep '^git_lfs_transfer_bytes_total{direction="upload"} 7$' push.prom
+  grep '^git_lfs_transfer_failures_total{direction="upload"} 0$' push.prom
+  grep '^git_lfs_transfer_concurrency{direction="upload"} 8$' push.prom
+  grep '^git_lfs_transfer_duration_seconds{direction="upload"} [0-9.]*$' push.prom
+
+  rm -rf .git/lfs/objects
+  git lfs fetch --metrics-file fetch.prom origin main
+  cat fetch.prom
+  grep '^git_lfs_transfer_objects_total{direction="download"} 1$' fetch.prom
+  grep 'direction="upload"' fetch.prom && exit 1
+
+  rm fetch.prom
+  git lfs fetch origin main
+  [ ! -e fetch.prom ]
+)
+end_test
+
+begin_test "push and fetch with lfs.transfer.maxbandwidth"
+(
+  set -e
+
+  reponame="push-max-bandwidth"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  head -c 102400 /dev/urandom > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  # 100 KB at 25 KB/s takes at least three seconds, after the first second's
+  # worth of it is sent at once.
+  git config lfs.transfer.maxbandwidth 25KB
+  start=$(date +%s)
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  [ $(($(date +%s) - start)) -ge 2 ]
+  grep "tq: limiting transfers to 25 KB/s overall and no limit per object" push.log
+  assert_server_object "$reponame" "$(calc_oid_file a.dat)"
+
+  rm -rf .git/lfs/objects
+  git config --unset lfs.transfer.maxbandwidth
+  git config lfs.transfer.maxbandwidthperobject 25KB
+  start=$(date +%s)
+  GIT_TRACE=1 git lfs fetch 2>&1 | tee fetch.log
+  [ $(($(date +%s) - start)) -ge 2 ]
+  grep "tq: limiting transfers to no limit overall and 25 KB/s per object" fetch.log
+  assert_local_object "$(calc_oid_file a.dat)" 102400
+)
+end_test
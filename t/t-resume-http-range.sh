@@ -126,3 +126,118 @@ begin_test "resume-http-range-retry"
   assert_local_object "$contents_oid" "${#contents}"
 )
 end_test
+
+begin_test "resume-http-range with lfs.transfer.enableresume false"
+(
+  set -e
+
+  reponame="resume-http-range-disabled"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  contents="status-batch-resume-206"
+  contents_oid=$(calc_oid "$contents")
+
+  printf "%s" "$contents" > a.dat
+  git add a.dat
+  git add .gitattributes
+  git commit -m "add a.dat" 2>&1 | tee commit.log
+  git push origin main
+
+  assert_server_object "$reponame" "$contents_oid"
+
+  git config lfs.transfer.enableresume false
+
+  # The server cuts the download short, and the partial download isn't kept.
+  rm -rf .git/lfs/objects
+  git lfs fetch 2>&1 | tee fetchinterrupted.log
+  refute_local_object "$contents_oid"
+  [ ! -e ".git/lfs/incomplete/$contents_oid.part" ]
+
+  GIT_TRACE=1 git lfs fetch 2>&1 | tee fetchresume.log
+  grep "xfer: Attempting to resume" fetchresume.log && exit 1
+  refute_local_object "$contents_oid"
+
+  # Once enabled again, the download is kept and resumed.
+  git config --unset lfs.transfer.enableresume
+  git lfs fetch 2>&1 | tee fetchinterrupted.log
+  [ -e ".git/lfs/incomplete/$contents_oid.part" ]
+  GIT_TRACE=1 git lfs fetch 2>&1 | tee fetchresume.log
+  grep "xfer: server accepted resume" fetchresume.log
+  assert_local_object "$contents_oid" "${#contents}"
+)
+end_test
+
+begin_test "resume-http-range without Accept-Ranges"
+(
+  set -e
+
+  # this repo name tells the server not to advertise Range requests
+  reponame="resume-http-range-no-accept-ranges"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  contents="status-batch-resume-206"
+  contents_oid=$(calc_oid "$contents")
+
+  printf "%s" "$contents" > a.dat
+  git add a.dat
+  git add .gitattributes
+  git commit -m "add a.dat" 2>&1 | tee commit.log
+  git push origin main
+
+  assert_server_object "$reponame" "$contents_oid"
+
+  rm -rf .git/lfs/objects
+  GIT_TRACE=1 git lfs fetch 2>&1 | tee fetchinterrupted.log
+  grep "xfer: discarding partial download of \"$contents_oid\": server does not accept Range requests" fetchinterrupted.log
+  grep "xfer: Attempting to resume" fetchinterrupted.log && exit 1
+  refute_local_object "$contents_oid"
+  [ ! -e ".git/lfs/incomplete/$contents_oid.part" ]
+)
+end_test
+
+begin_test "resume-http-range with lfs.transfer.maxresumes"
+(
+  set -e
+
+  reponame="resume-http-range-max-resumes"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" $reponame
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \"\*.dat\"" track.log
+
+  contents="status-batch-resume-206"
+  contents_oid=$(calc_oid "$contents")
+
+  printf "%s" "$contents" > a.dat
+  git add a.dat
+  git add .gitattributes
+  git commit -m "add a.dat" 2>&1 | tee commit.log
+  git push origin main
+
+  assert_server_object "$reponame" "$contents_oid"
+
+  rm -rf .git/lfs/objects
+  git lfs fetch 2>&1 | tee fetchinterrupted.log
+  refute_local_object "$contents_oid"
+  [ -e ".git/lfs/incomplete/$contents_oid.part" ]
+
+  # The download starts again from the beginning, and is cut short again.
+  git config lfs.transfer.maxresumes 0
+  GIT_TRACE=1 git lfs fetch 2>&1 | tee fetchresume.log
+  grep "xfer: not resuming download of \"$contents_oid\" after 0 attempts" fetchresume.log
+  grep "xfer: server accepted resume" fetchresume.log && exit 1
+  refute_local_object "$contents_oid"
+)
+end_test
@@ -0,0 +1,164 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+# setup_s3_bucket configures the current repository to transfer objects to and
+# from the fake S3 bucket "$1" of the test server with its static credentials.
+setup_s3_bucket() {
+  git config lfs.storage.s3.bucket "$1"
+  git config lfs.storage.s3.endpoint "$GITSERVER/s3"
+  git config lfs.storage.s3.accesskeyid "LFSTESTACCESSKEY"
+  git config lfs.storage.s3.secretaccesskey "lfs-test-secret"
+}
+
+begin_test "s3: push and fetch objects with static credentials"
+(
+  set -e
+
+  reponame="s3-static"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+  setup_s3_bucket "$reponame"
+
+  git lfs track "*.dat"
+  contents="s3 static"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "tq: starting transfer adapter \"lfs-standalone-s3\"" push.log
+  grep "HTTP: PUT $GITSERVER/s3/$reponame/${oid:0:2}/${oid:2:2}/$oid" push.log
+  grep "info/lfs/objects/batch" push.log && exit 1
+
+  # The object went to the bucket, not the server.
+  refute_server_object "$reponame" "$oid"
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$reponame-clone"
+  cd "$reponame-clone"
+  setup_s3_bucket "$reponame"
+  git lfs fetch 2>&1 | tee fetch.log
+  assert_local_object "$oid" "${#contents}"
+
+  # Objects the bucket already has aren't uploaded again, even by another
+  # repository.
+  cd ..
+  setup_remote_repo "$reponame-other"
+  clone_repo "$reponame-other" "$reponame-other"
+  setup_s3_bucket "$reponame"
+  git lfs track "*.dat"
+  printf "%s" "$contents" > b.dat
+  git add .gitattributes b.dat
+  git commit -m "add b.dat"
+  GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "xfer: \"$oid\" is already in S3 bucket \"$reponame\", skipping" push.log
+  grep "HTTP: PUT" push.log && exit 1
+  true
+)
+end_test
+
+begin_test "s3: push and fetch objects with environment credentials under a prefix"
+(
+  set -e
+
+  reponame="s3-environment"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+  git config lfs.storage.s3.bucket "$reponame"
+  git config lfs.storage.s3.endpoint "$GITSERVER/s3"
+  git config lfs.storage.s3.prefix "team/lfs"
+  export AWS_ACCESS_KEY_ID="LFSTESTACCESSKEY" AWS_SECRET_ACCESS_KEY="lfs-test-secret"
+
+  git lfs track "*.dat"
+  contents="s3 environment"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "HTTP: PUT $GITSERVER/s3/$reponame/team/lfs/${oid:0:2}/${oid:2:2}/$oid" push.log
+
+  rm -rf .git/lfs/objects
+  git lfs fetch
+  assert_local_object "$oid" "${#contents}"
+)
+end_test
+
+begin_test "s3: push and fetch objects with the credentials of an IAM role"
+(
+  set -e
+
+  reponame="s3-role"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+  git config lfs.storage.s3.bucket "$reponame"
+  git config lfs.storage.s3.endpoint "$GITSERVER/s3"
+  export AWS_EC2_METADATA_SERVICE_ENDPOINT="$GITSERVER/imds"
+
+  git lfs track "*.dat"
+  contents="s3 role"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "s3: using the credentials of IAM role \"lfs-test-role\"" push.log
+
+  rm -rf .git/lfs/objects
+  git lfs fetch
+  assert_local_object "$oid" "${#contents}"
+)
+end_test
+
+begin_test "s3: push fails with unknown credentials"
+(
+  set -e
+
+  reponame="s3-unknown-credentials"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+  setup_s3_bucket "$reponame"
+  git config lfs.storage.s3.accesskeyid "SOMEONEELSE"
+
+  git lfs track "*.dat"
+  printf "unknown" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  git push origin main 2>&1 | tee push.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected push to fail"
+    exit 1
+  fi
+  grep "Invalid status for HEAD $GITSERVER/s3/$reponame/.*: 403" push.log
+)
+end_test
+
+begin_test "s3: push fails without any credentials"
+(
+  set -e
+
+  reponame="s3-no-credentials"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+  git config lfs.storage.s3.bucket "$reponame"
+  git config lfs.storage.s3.endpoint "$GITSERVER/s3"
+  export AWS_EC2_METADATA_DISABLED=true
+
+  git lfs track "*.dat"
+  printf "none" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  git push origin main 2>&1 | tee push.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected push to fail"
+    exit 1
+  fi
+  grep "no credentials for lfs.storage.s3.bucket" push.log
+)
+end_test
@@ -0,0 +1,151 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+# shared_clone clones the remote repository "$1" into "$2", storing its objects
+# in the shared object store "$3", and leaves the current directory as the clone.
+shared_clone() {
+  local reponame="$1"
+  local dir="$2"
+  local store="$3"
+
+  cd "$TRASHDIR"
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$dir"
+  git config lfs.storage "$store"
+  git config lfs.storage.shared true
+}
+
+begin_test "shared storage: clones share objects"
+(
+  set -e
+
+  reponame="shared-storage-objects"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+  git push origin main
+
+  store="$TRASHDIR/shared-store"
+  oid="$(calc_oid "a.dat"$'\n')"
+
+  shared_clone "$reponame" "$reponame-one" "$store"
+  GIT_TRACE=1 git lfs pull 2>&1 | tee pull.log
+  grep "sending batch of size 1" pull.log
+  [ "a.dat" = "$(cat a.dat)" ]
+  [ -f "$store/objects/${oid:0:2}/${oid:2:2}/$oid" ]
+
+  shared_clone "$reponame" "$reponame-two" "$store"
+  GIT_TRACE=1 git lfs pull 2>&1 | tee pull.log
+  [ "0" -eq "$(grep -c "sending batch of size 1" pull.log)" ]
+  [ "a.dat" = "$(cat a.dat)" ]
+)
+end_test
+
+begin_test "shared storage: concurrent fetches"
+(
+  set -e
+
+  reponame="shared-storage-concurrent"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  for i in $(seq 1 20); do
+    printf "%s" "file $i" > "$i.dat"
+  done
+  git add .gitattributes *.dat
+  git commit -m "add files"
+  git push origin main
+
+  store="$TRASHDIR/shared-store-concurrent"
+  for n in one two three; do
+    shared_clone "$reponame" "$reponame-$n" "$store"
+  done
+
+  cd "$TRASHDIR"
+  for n in one two three; do
+    (cd "$reponame-$n" && GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git lfs fetch >fetch.log 2>&1) &
+  done
+  wait
+
+  for n in one two three; do
+    cd "$TRASHDIR/$reponame-$n"
+    git lfs checkout
+    for i in $(seq 1 20); do
+      [ "file $i" = "$(cat "$i.dat")" ]
+    done
+    git lfs fsck
+  done
+
+  # The objects were downloaded holding their locks.
+  [ -n "$(ls "$store/objectlocks")" ]
+)
+end_test
+
+begin_test "shared storage: prune refuses"
+(
+  set -e
+
+  reponame="shared-storage-prune"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+  git push origin main
+
+  store="$TRASHDIR/shared-store-prune"
+  oid="$(calc_oid "a.dat"$'\n')"
+
+  shared_clone "$reponame" "$reponame-one" "$store"
+  git lfs pull
+
+  git lfs prune --force 2>&1 | tee prune.log
+  grep "Cannot prune a shared object store (lfs.storage.shared)" prune.log
+  [ -f "$store/objects/${oid:0:2}/${oid:2:2}/$oid" ]
+
+  git lfs prune --tmp
+)
+end_test
+
+begin_test "shared storage: lfs.checkout.hardlink"
+(
+  set -e
+
+  reponame="shared-storage-hardlink"
+  setup_remote_repo_with_file "$reponame" "a.dat"
+  git push origin main
+
+  store="$TRASHDIR/shared-store-hardlink"
+  oid="$(calc_oid "a.dat"$'\n')"
+  object="$store/objects/${oid:0:2}/${oid:2:2}/$oid"
+
+  shared_clone "$reponame" "$reponame-one" "$store"
+  git config lfs.checkout.hardlink true
+  git lfs fetch
+  GIT_TRACE=1 git lfs checkout 2>&1 | tee checkout.log
+  [ "a.dat" = "$(cat a.dat)" ]
+
+  if grep "smudge: cloned" checkout.log; then
+    echo "cloned the object into the working tree, which comes first"
+    exit 0
+  fi
+  grep "smudge: linked" checkout.log
+  [ a.dat -ef "$object" ]
+
+  # The object is read-only, so it can't be changed through the file.
+  ls -l a.dat | cut -c1-10 | grep w && exit 1
+  if [ "$(id -u)" -ne 0 ]; then
+    echo "changed" >> a.dat && exit 1
+  fi
+  [ "a.dat" = "$(cat a.dat)" ]
+  git lfs fsck 2>&1 | tee fsck.log
+  [ "0" -eq "${PIPESTATUS[0]}" ]
+
+  # A pointer hard linked to another file is replaced, rather than
+  # written over along with the other file.
+  git show HEAD:a.dat > pointer
+  rm a.dat
+  ln pointer a.dat
+  git config lfs.checkout.hardlink false
+  git lfs checkout
+  [ "a.dat" = "$(cat a.dat)" ]
+  [ "$(git show HEAD:a.dat)" = "$(cat pointer)" ]
+  [ ! a.dat -ef "$object" ]
+  assert_local_object "$oid" 6
+)
+end_test
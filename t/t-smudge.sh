@@ -133,6 +133,56 @@ begin_test "smudge with skip"
 )
 end_test
 
+begin_test "smudge with lfs.skipsmudge.<operation>"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-skip-operation"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  echo "smudge a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  git checkout -b other
+  echo "smudge b" > a.dat
+  git commit -am "change a.dat"
+  git push origin other
+  git checkout main
+
+  pointer_a="$(pointer fcf5015df7a9089a7aa7fe74139d4b8f7d62e52d5a34f9a87aeffc8e8c668254 9)"
+  pointer_b="$(pointer "$(calc_oid "smudge b
+")" 9)"
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$reponame-clone"
+  [ "$pointer_a" = "$(cat a.dat)" ]
+  git config lfs.skipsmudge.checkout true
+
+  # Switching branches leaves the pointers as they are.
+  git checkout other
+  [ "$pointer_b" = "$(cat a.dat)" ]
+  git checkout main
+  [ "$pointer_a" = "$(cat a.dat)" ]
+  [ "$pointer_a" = "$(echo "$pointer_a" | git lfs smudge)" ]
+
+  # While a pull smudges the files it changes.
+  git pull origin other
+  [ "smudge b" = "$(cat a.dat)" ]
+  [ "smudge a" = "$(echo "$pointer_a" | GIT_REFLOG_ACTION=pull git lfs smudge)" ]
+
+  # The configuration for the operation takes precedence over the environment.
+  rm -rf .git/lfs/objects
+  git config lfs.skipsmudge.pull true
+  [ "$pointer_a" = "$(echo "$pointer_a" | GIT_REFLOG_ACTION="pull origin" git lfs smudge)" ]
+  git config lfs.skipsmudge.checkout false
+  [ "smudge a" = "$(echo "$pointer_a" | GIT_LFS_SKIP_SMUDGE=1 git lfs smudge)" ]
+)
+end_test
+
 begin_test "smudge clone with include/exclude"
 (
   set -e
@@ -220,6 +270,66 @@ begin_test "smudge skip download failure"
 )
 end_test
 
+begin_test "smudge with lfs.smudge.onmissing"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-onmissing"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  echo "smudge a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  pointer="$(pointer fcf5015df7a9089a7aa7fe74139d4b8f7d62e52d5a34f9a87aeffc8e8c668254 9)"
+
+  rm -rf .git/lfs/objects
+  git remote set-url origin httpnope://nope.com/nope
+
+  git config lfs.smudge.onmissing pointer
+  echo "$pointer" | git lfs smudge a.dat > smudged 2>smudge.log
+  [ "$pointer" = "$(cat smudged)" ]
+  grep "Error downloading object: a.dat" smudge.log
+
+  git config lfs.smudge.onmissing empty
+  echo "$pointer" | git lfs smudge a.dat > smudged 2>smudge.log
+  [ ! -s smudged ]
+  grep "Error downloading object: a.dat" smudge.log
+
+  # An explicit setting takes precedence over lfs.skipdownloaderrors.
+  git config lfs.smudge.onmissing error
+  git config lfs.skipdownloaderrors true
+  set +e
+  echo "$pointer" | git lfs smudge a.dat > smudged 2>smudge.log
+  res=${PIPESTATUS[1]}
+  set -e
+  [ "$res" -eq 2 ]
+  [ "$pointer" = "$(cat smudged)" ]
+
+  git config --unset lfs.smudge.onmissing
+  echo "$pointer" | git lfs smudge a.dat > smudged
+  [ "$pointer" = "$(cat smudged)" ]
+
+  git config lfs.smudge.onmissing bogus
+  set +e
+  echo "$pointer" | git lfs smudge a.dat 2>smudge.log
+  res=${PIPESTATUS[1]}
+  set -e
+  [ "$res" -eq 2 ]
+  grep "Invalid lfs.smudge.onmissing value \"bogus\"" smudge.log
+
+  # The checkout continues with each file left empty.
+  git config --unset lfs.skipdownloaderrors
+  git config lfs.smudge.onmissing empty
+  rm a.dat
+  git checkout -- a.dat
+  [ ! -s a.dat ]
+)
+end_test
+
 begin_test "smudge no ref, non-origin"
 (
   set -e
@@ -250,3 +360,108 @@ begin_test "smudge no ref, non-origin"
   [ "smudge a" = "$(cat a.dat)" ]
 )
 end_test
+
+begin_test "smudge --info"
+(
+  set -e
+
+  reponame="smudge-info"
+  git init "$reponame"
+  cd "$reponame"
+
+  oid="$(calc_oid "smudge info\n")"
+  pointer "$oid" 12 > a.ptr
+
+  expected="{\"oid\":\"$oid\",\"size\":12}"
+  [ "$expected" = "$(git lfs smudge --info < a.ptr)" ]
+  [ "$expected" = "$(git lfs smudge --info a.ptr)" ]
+
+  # No object should have been fetched.
+  refute_local_object "$oid"
+
+  echo "not a pointer" > b.dat
+  set +e
+  git lfs smudge --info b.dat > info.log 2>&1
+  res=$?
+  set -e
+
+  [ "$res" -ne 0 ]
+  grep "b.dat is not a Git LFS pointer" info.log
+)
+end_test
+
+begin_test "smudge with lfs.verifycachedobjects"
+(
+  set -e
+
+  reponame="smudge-verify-cached-objects"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.dat"
+  contents="smudge a"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  # corrupt the cached object, keeping its size
+  path=".git/lfs/objects/${oid:0:2}/${oid:2:2}/$oid"
+  chmod u+w "$path"
+  printf "smudge b" > "$path"
+
+  # without the setting, the cached object is trusted
+  output="$(pointer "$oid" 8 | git lfs smudge)"
+  [ "smudge b" = "$output" ]
+
+  pointer "$oid" 8 | git -c lfs.verifycachedobjects=true lfs smudge 2>&1 | tee smudge.log
+  if [ "0" -eq "${PIPESTATUS[1]}" ]; then
+    echo >&2 "fatal: expected smudge of corrupt object to fail"
+    exit 1
+  fi
+
+  grep "cached object $oid is corrupt" smudge.log
+  refute_local_object "$oid"
+  [ "smudge b" = "$(cat ".git/lfs/bad/$oid")" ]
+)
+end_test
+
+begin_test "smudge with lfs.verifycachedobjects (corrupt alternate)"
+(
+  set -e
+
+  reponame="smudge-verify-cached-objects-alternate"
+  git init "${reponame}_warehouse"
+  cd "${reponame}_warehouse"
+
+  git lfs track "*.dat"
+  contents="smudge a"
+  oid="$(calc_oid "$contents")"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  # corrupt the object in the alternate, keeping its size
+  alternate="$(pwd)/.git/lfs/objects"
+  path="$alternate/${oid:0:2}/${oid:2:2}/$oid"
+  chmod u+w "$path"
+  printf "smudge b" > "$path"
+
+  cd ..
+  git init "$reponame"
+  cd "$reponame"
+  git config lfs.storage.alternates "$alternate"
+
+  pointer "$oid" 8 | git -c lfs.verifycachedobjects=true lfs smudge 2>&1 | tee smudge.log
+  if [ "0" -eq "${PIPESTATUS[1]}" ]; then
+    echo >&2 "fatal: expected smudge of corrupt object to fail"
+    exit 1
+  fi
+
+  grep "cached object $oid is corrupt, and has been left in its alternate object directory" smudge.log
+
+  # The alternate is read-only, so is left as it is.
+  [ "smudge b" = "$(cat "$path")" ]
+  [ ! -e ".git/lfs/bad/$oid" ]
+)
+end_test
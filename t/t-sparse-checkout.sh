@@ -0,0 +1,169 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+ensure_git_version_isnt $VERSION_LOWER "2.25.0"
+
+# setup_sparse_repo creates a repository named "$1" on the server with Git LFS
+# files inside and outside of the directories used in the sparse checkouts
+# below, and clones it without downloading any objects.
+setup_sparse_repo() {
+  local reponame="$1"
+
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir -p a/b/c a/out other
+  printf "root" > root.dat
+  printf "inside" > a/b/inside.dat
+  printf "nested" > a/b/c/nested.dat
+  printf "parent" > a/parent.dat
+  printf "sibling" > a/out/sibling.dat
+  printf "other" > other/other.dat
+  git add .
+  git commit -m "add files"
+  git push origin main
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 clone_repo "$reponame" "$reponame-sparse"
+  rm -rf .git/lfs/objects
+}
+
+begin_test "sparse checkout: pull in cone mode"
+(
+  set -e
+
+  setup_sparse_repo "sparse-checkout-pull-cone"
+
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout init --cone
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout set a/b
+  [ ! -e other/other.dat ]
+  [ ! -e a/out/sibling.dat ]
+
+  git lfs pull
+
+  [ "root" = "$(cat root.dat)" ]
+  [ "inside" = "$(cat a/b/inside.dat)" ]
+  [ "nested" = "$(cat a/b/c/nested.dat)" ]
+  [ "parent" = "$(cat a/parent.dat)" ]
+  [ ! -e other/other.dat ]
+  [ ! -e a/out/sibling.dat ]
+  refute_local_object "$(calc_oid "other")"
+  refute_local_object "$(calc_oid "sibling")"
+
+  git lfs pull --all-paths
+  assert_local_object "$(calc_oid "other")" 5
+  assert_local_object "$(calc_oid "sibling")" 7
+)
+end_test
+
+begin_test "sparse checkout: fetch --sparse with nested patterns"
+(
+  set -e
+
+  setup_sparse_repo "sparse-checkout-fetch-nested"
+
+  git config core.sparseCheckout true
+  mkdir -p .git/info
+  cat > .git/info/sparse-checkout <<-EOP
+	/*
+	!/*/
+	/a/
+	!/a/out/
+	# Nested directories are matched like .gitignore patterns.
+	!c/
+EOP
+  GIT_LFS_SKIP_SMUDGE=1 git read-tree -mu HEAD
+
+  git lfs fetch --sparse --dry-run 2>&1 | tee fetch.log
+  grep "a/b/inside.dat" fetch.log
+  grep "other.dat" fetch.log && exit 1
+  grep "nested.dat" fetch.log && exit 1
+
+  git lfs fetch --sparse
+
+  assert_local_object "$(calc_oid "root")" 4
+  assert_local_object "$(calc_oid "inside")" 6
+  assert_local_object "$(calc_oid "parent")" 6
+  refute_local_object "$(calc_oid "nested")"
+  refute_local_object "$(calc_oid "sibling")"
+  refute_local_object "$(calc_oid "other")"
+
+  # Without --sparse, fetch downloads every object of the current ref.
+  git lfs fetch
+  assert_local_object "$(calc_oid "nested")" 6
+  assert_local_object "$(calc_oid "sibling")" 7
+  assert_local_object "$(calc_oid "other")" 5
+)
+end_test
+
+begin_test "sparse checkout: checkout leaves paths outside alone"
+(
+  set -e
+
+  setup_sparse_repo "sparse-checkout-checkout"
+
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout init --cone
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout set a/b
+
+  git lfs fetch
+  git lfs checkout
+
+  [ "inside" = "$(cat a/b/inside.dat)" ]
+  [ "root" = "$(cat root.dat)" ]
+  [ ! -e other/other.dat ]
+
+  git lfs checkout --all-paths
+  [ "other" = "$(cat other/other.dat)" ]
+)
+end_test
+
+begin_test "sparse checkout: fetch with lfs.fetchfromsparse"
+(
+  set -e
+
+  setup_sparse_repo "sparse-checkout-fetchfromsparse"
+
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout init --cone
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout set a/b
+  git config lfs.fetchfromsparse true
+
+  git lfs fetch
+  assert_local_object "$(calc_oid "inside")" 6
+  assert_local_object "$(calc_oid "root")" 4
+  refute_local_object "$(calc_oid "other")"
+  refute_local_object "$(calc_oid "sibling")"
+
+  git lfs fetch --all 2>&1 | tee fetch.log
+  grep "Ignoring lfs.fetchfromsparse to fulfil --all" fetch.log
+  assert_local_object "$(calc_oid "other")" 5
+  assert_local_object "$(calc_oid "sibling")" 7
+)
+end_test
+
+begin_test "sparse checkout: smudge with lfs.fetchfromsparse"
+(
+  set -e
+
+  setup_sparse_repo "sparse-checkout-smudge-fetchfromsparse"
+
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout init --cone
+  GIT_LFS_SKIP_SMUDGE=1 git sparse-checkout set a/b
+  git config lfs.fetchfromsparse true
+  git lfs pull
+
+  # Files outside the sparse checkout are left as pointers.
+  git show HEAD:other/other.dat > pointer.txt
+  git lfs smudge other/other.dat < pointer.txt > smudged.txt
+  cmp pointer.txt smudged.txt
+  refute_local_object "$(calc_oid "other")"
+
+  # Expanding the sparse checkout downloads the objects of the files it
+  # newly includes.
+  git sparse-checkout add other
+  [ "other" = "$(cat other/other.dat)" ]
+  assert_local_object "$(calc_oid "other")" 5
+  refute_local_object "$(calc_oid "sibling")"
+)
+end_test
@@ -0,0 +1,190 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+# Repositories named "ssh-transfer-*" act as if the SSH server supports the SSH
+# transfer protocol of git-lfs-transfer(1); lfs-ssh-echo makes their requests
+# through the LFS API of lfstest-gitserver.
+
+begin_test "ssh transfer: push and pull"
+(
+  set -e
+
+  reponame="ssh-transfer-push-pull"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  sshurl="${GITSERVER/http:\/\//ssh://git@}/$reponame"
+  git config lfs.url "$sshurl"
+
+  contents="ssh transfer"
+  oid="$(calc_oid "$contents")"
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "Uploading LFS objects: 100% (1/1)" push.log
+  grep "lfs-ssh-echo.*git-lfs-transfer $reponame upload" push.log
+  grep "ssh: sending \"put-object $oid\"" push.log
+  grep "lfs-ssh-echo.*git-lfs-authenticate" push.log && exit 1
+  assert_server_object "$reponame" "$oid"
+
+  cd ..
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$reponame-clone"
+  cd "$reponame-clone"
+  git config lfs.url "$sshurl"
+
+  GIT_TRACE=1 git lfs pull 2>&1 | tee pull.log
+  grep "lfs-ssh-echo.*git-lfs-transfer $reponame download" pull.log
+  grep "ssh: sending \"get-object $oid\"" pull.log
+  grep "lfs-ssh-echo.*git-lfs-authenticate" pull.log && exit 1
+  assert_local_object "$oid" "${#contents}"
+  [ "$contents" = "$(cat a.dat)" ]
+)
+end_test
+
+begin_test "ssh transfer: fetch missing object"
+(
+  set -e
+
+  reponame="ssh-transfer-missing"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  sshurl="${GITSERVER/http:\/\//ssh://git@}/$reponame"
+  git config lfs.url "$sshurl"
+
+  contents="missing"
+  oid="$(calc_oid "$contents")"
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+  GIT_LFS_SKIP_PUSH=1 git push origin main
+  rm -rf .git/lfs/objects
+
+  git lfs fetch 2>&1 | tee fetch.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: fetch succeeded"
+    exit 1
+  fi
+  grep "$oid" fetch.log
+  refute_local_object "$oid"
+)
+end_test
+
+begin_test "ssh transfer: locks"
+(
+  set -e
+
+  reponame="ssh-transfer-locks"
+  setup_remote_repo_with_file "$reponame" "f.dat"
+  clone_repo "$reponame" "$reponame"
+
+  sshurl="${GITSERVER/http:\/\//ssh://git@}/$reponame"
+  git config lfs.url "$sshurl"
+
+  GIT_TRACE=1 git lfs lock --json "f.dat" 2>trace.log | tee lock.log
+  grep "ssh: sending \"lock\"" trace.log
+  id=$(assert_lock lock.log f.dat)
+  assert_server_lock "$reponame" "$id" "refs/heads/main"
+
+  GIT_TRACE=1 git lfs locks --path "f.dat" 2>trace.log | tee locks.log
+  grep "ssh: sending \"list-lock\"" trace.log
+  [ $(wc -l < locks.log) -eq 1 ]
+  grep "f.dat" locks.log
+  grep "Git LFS Tests" locks.log
+
+  GIT_TRACE=1 git lfs locks --verify 2>trace.log | tee verify.log
+  grep "lfs-ssh-echo.*git-lfs-transfer $reponame upload" trace.log
+  grep "O f.dat" verify.log
+
+  GIT_TRACE=1 git lfs unlock --id="$id" 2>trace.log
+  grep "ssh: sending \"unlock $id\"" trace.log
+  grep "lfs-ssh-echo.*git-lfs-authenticate" trace.log && exit 1
+  refute_server_lock "$reponame" "$id" "refs/heads/main"
+)
+end_test
+
+begin_test "ssh transfer: falls back to git-lfs-authenticate"
+(
+  set -e
+
+  reponame="ssh-authenticate-fallback"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  sshurl="${GITSERVER/http:\/\//ssh://git@}/$reponame"
+  git config lfs.url "$sshurl"
+
+  contents="fallback"
+  oid="$(calc_oid "$contents")"
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "ssh: git-lfs-transfer is not available, using git-lfs-authenticate" push.log
+  grep "lfs-ssh-echo.*git-lfs-authenticate $reponame upload" push.log
+  assert_server_object "$reponame" "$oid"
+)
+end_test
+
+begin_test "ssh transfer: lfs.sshtransfer always"
+(
+  set -e
+
+  reponame="ssh-authenticate-always"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  sshurl="${GITSERVER/http:\/\//ssh://git@}/$reponame"
+  git config lfs.url "$sshurl"
+  git config lfs.sshtransfer always
+
+  contents="always"
+  oid="$(calc_oid "$contents")"
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  git push origin main 2>&1 | tee push.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: push succeeded"
+    exit 1
+  fi
+  grep "git-lfs-transfer: command not found" push.log
+  refute_server_object "$reponame" "$oid"
+)
+end_test
+
+begin_test "ssh transfer: lfs.<url>.sshtransfer never"
+(
+  set -e
+
+  reponame="ssh-transfer-never"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  sshurl="${GITSERVER/http:\/\//ssh://git@}/$reponame"
+  git config lfs.url "$sshurl"
+  # The URL of an SSH endpoint is matched in its HTTPS form.
+  git config "lfs.https://127.0.0.1/$reponame.sshtransfer" never
+
+  contents="never"
+  oid="$(calc_oid "$contents")"
+  git lfs track "*.dat"
+  printf "%s" "$contents" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "initial commit"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  grep "lfs-ssh-echo.*git-lfs-transfer" push.log && exit 1
+  grep "lfs-ssh-echo.*git-lfs-authenticate $reponame upload" push.log
+  assert_server_object "$reponame" "$oid"
+)
+end_test
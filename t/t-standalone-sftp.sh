@@ -0,0 +1,79 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+# setup_sftp_repo creates a repository named "$1" pushing to a bare repository
+# next to it, whose objects are transferred over SFTP to "$1-objects".
+setup_sftp_repo () {
+  local reponame="$1"
+
+  git init --bare "$reponame.git"
+  objdir="$(pwd)/$reponame-objects"
+  mkdir "$objdir"
+
+  git init "$reponame"
+  cd "$reponame"
+  git remote add origin "../$reponame.git"
+  git config lfs.url "sftp://user@example.com:2222$objdir"
+  git config lfs.sftp.program lfstest-sftp
+
+  git lfs track "*.dat"
+  printf "abc" > a.dat
+  printf "defgh" > b.dat
+  git add .gitattributes a.dat b.dat
+  git commit -m "add objects"
+}
+
+begin_test "standalone-sftp: upload and download"
+(
+  set -e
+
+  setup_sftp_repo "standalone-sftp-upload-download"
+
+  LFSTEST_SFTP_LOG="$(pwd)/../sftp.log" GIT_TRACE=1 GIT_TRANSFER_TRACE=1 git push origin main 2>&1 | tee push.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "xfer: started custom adapter process" push.log
+  grep "Uploading LFS objects: 100% (2/2)" push.log
+  grep "BatchMode=yes -P 2222 user@example.com" ../sftp.log
+
+  oid_a="$(calc_oid "abc")"
+  oid_b="$(calc_oid "defgh")"
+  [ "abc" = "$(cat "$objdir/${oid_a:0:2}/${oid_a:2:2}/$oid_a")" ]
+  [ "defgh" = "$(cat "$objdir/${oid_b:0:2}/${oid_b:2:2}/$oid_b")" ]
+
+  # objects already on the server aren't uploaded again
+  touch -t 200001010000 "$objdir/${oid_a:0:2}/${oid_a:2:2}/$oid_a"
+  git lfs push origin main --all 2>&1 | tee push.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  [ -z "$(find "$objdir" -newer "$objdir/${oid_b:0:2}/${oid_b:2:2}/$oid_b" -name "$oid_a")" ]
+
+  rm -rf .git/lfs/objects
+  git lfs fetch origin main 2>&1 | tee fetch.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  assert_local_object "$oid_a" 3
+  assert_local_object "$oid_b" 5
+)
+end_test
+
+begin_test "standalone-sftp: download verifies objects"
+(
+  set -e
+
+  setup_sftp_repo "standalone-sftp-verify"
+
+  git push origin main
+
+  oid_a="$(calc_oid "abc")"
+  oid_b="$(calc_oid "defgh")"
+  printf "xyz" > "$objdir/${oid_a:0:2}/${oid_a:2:2}/$oid_a"
+  rm "$objdir/${oid_b:0:2}/${oid_b:2:2}/$oid_b"
+
+  rm -rf .git/lfs/objects
+  git lfs fetch origin main 2>&1 | tee fetch.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "expected OID $oid_a" fetch.log
+  grep "remote missing object $oid_b" fetch.log
+  refute_local_object "$oid_a"
+  refute_local_object "$oid_b"
+)
+end_test
@@ -0,0 +1,85 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+setup_stats_repo () {
+  reponame="$1"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.psd" "*.bin"
+  mkdir art
+  printf "aaaa" > art/a.psd
+  printf "bb" > b.bin
+  git add .gitattributes art b.bin
+  git commit -m "initial commit"
+
+  printf "aaaaaaaa" > art/a.psd
+  git commit -am "update a.psd"
+
+  git checkout -b other
+  printf "c" > c.bin
+  git add c.bin
+  git commit -m "add c.bin"
+  git checkout main
+}
+
+begin_test "stats"
+(
+  set -e
+
+  setup_stats_repo "stats"
+
+  git lfs stats 2>&1 | tee stats.log
+  grep "3 LFS object(s), 14 B, in the history of 1 ref(s); 3 object(s), 14 B, present locally" stats.log
+  grep "refs/heads/main" stats.log
+  ! grep "refs/heads/other" stats.log
+  grep -E "^  art[[:space:]]+2 object\(s\)[[:space:]]+12 B" stats.log
+  grep -E "^  \.[[:space:]]+1 object\(s\)[[:space:]]+2 B" stats.log
+  grep -E "^  \*\.psd[[:space:]]+2 object\(s\)[[:space:]]+12 B" stats.log
+
+  a_oid="$(calc_oid "aaaaaaaa")"
+  [ " ${a_oid:0:10} 8 B art/a.psd" = "$(grep -A1 "Largest objects:" stats.log | tail -1 | tr -s '\t ' ' ')" ]
+
+  git lfs stats --all --top=1 --long 2>&1 | tee stats.log
+  grep "4 LFS object(s), 15 B, in the history of 2 ref(s)" stats.log
+  grep -E "^  refs/heads/main[[:space:]]+3 object\(s\)[[:space:]]+14 B" stats.log
+  grep -E "^  refs/heads/other[[:space:]]+4 object\(s\)[[:space:]]+15 B" stats.log
+  [ 1 -eq "$(grep -A10 "Largest objects:" stats.log | grep -c " B")" ]
+  grep "$a_oid" stats.log
+)
+end_test
+
+begin_test "stats --json"
+(
+  set -e
+
+  setup_stats_repo "stats-json"
+
+  git lfs stats --json --top=2 main other > stats.json
+  cat stats.json
+  grep '"count":4,"size":15,"local_count":4,"local_size":15' stats.json
+  grep '{"name":"refs/heads/main","count":3,"size":14},{"name":"refs/heads/other","count":4,"size":15}' stats.json
+  grep '"extensions":\[{"name":"\*.psd","count":2,"size":12},{"name":"\*.bin","count":2,"size":3}\]' stats.json
+  grep "\"largest\":\[{\"oid\":\"$(calc_oid "aaaaaaaa")\",\"size\":8,\"name\":\"art/a.psd\",\"local\":true},{\"oid\":\"$(calc_oid "aaaa")\"" stats.json
+)
+end_test
+
+begin_test "stats --include and --exclude"
+(
+  set -e
+
+  setup_stats_repo "stats-include-exclude"
+
+  git lfs stats --all --include="*.bin" 2>&1 | tee stats.log
+  grep "2 LFS object(s), 3 B" stats.log
+  ! grep "a.psd" stats.log
+
+  git lfs stats --all --exclude="*.bin" 2>&1 | tee stats.log
+  grep "2 LFS object(s), 12 B" stats.log
+
+  rm -rf .git/lfs/objects
+  git lfs stats 2>&1 | tee stats.log
+  grep "3 LFS object(s), 14 B, in the history of 1 ref(s); 0 object(s), 0 B, present locally" stats.log
+)
+end_test
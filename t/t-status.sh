@@ -593,3 +593,205 @@ Objects not staged for commit:"
   [ "$expected" = "$actual" ]
 )
 end_test
+
+begin_test "status: pointer text in place of contents"
+(
+  set -e
+
+  reponame="status-pointer-contents"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.dat"
+  echo "unsmudged" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  git lfs status 2>&1 | tee status.log
+  grep "pointer text" status.log && exit 1
+
+  git show HEAD:a.dat > a.dat
+
+  # Files are only read for pointer text when asked to.
+  git lfs status 2>&1 | tee status.log
+  grep "pointer text" status.log && exit 1
+
+  git lfs status --pointers 2>&1 | tee status.log
+  grep "Objects with pointer text in place of contents:" status.log
+  grep "a.dat (working tree file is a pointer)" status.log
+
+  git lfs checkout a.dat
+  git lfs status --pointers 2>&1 | tee status.log
+  grep "pointer text" status.log && exit 1
+  true
+)
+end_test
+
+begin_test "status: --check-attributes"
+(
+  set -e
+
+  reponame="status-check-attributes"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.dat"
+  printf "tracked" > a.dat
+  printf "" > empty.dat
+  printf "text" > a.txt
+  mkdir dir
+  git add .gitattributes a.dat empty.dat a.txt
+  git commit -m "add files"
+
+  # Symbolic links are never converted to pointers.
+  ln -s a.txt link.dat
+  git add link.dat
+  git commit -m "add link"
+
+  git lfs status --check-attributes 2>&1 | tee status.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  [ ! -s status.log ]
+
+  # add files while the filter is broken
+  printf "raw contents" > b.dat
+  printf "more raw contents" > dir/c.dat
+  git -c filter.lfs.clean= -c filter.lfs.process= -c filter.lfs.required=false \
+    add b.dat dir/c.dat
+
+  git lfs status --check-attributes 2>&1 | tee status.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Files matching Git LFS patterns stored as Git objects:" status.log
+  grep "b.dat (12 B)" status.log
+  grep "dir/c.dat (17 B)" status.log
+  grep "git lfs migrate import --fixup" status.log
+  grep "a.dat\|a.txt\|link.dat" status.log && exit 1
+
+  cd dir
+  git lfs status --check-attributes 2>&1 | tee status.log
+  grep "	../b.dat (12 B)" status.log
+  grep "	c.dat (17 B)" status.log
+)
+end_test
+
+begin_test "status: --exit-code"
+(
+  set -e
+
+  reponame="status-exit-code"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.dat"
+  printf "tracked" > a.dat
+  printf "text" > a.txt
+  git add .gitattributes a.dat a.txt
+  git commit -m "add files"
+
+  git lfs status --exit-code
+  git lfs status --exit-code --porcelain
+  git lfs status --exit-code --json
+
+  # changes only to files stored as Git objects, and untracked files, don't
+  # count
+  printf "other text" > a.txt
+  printf "untracked" > b.dat
+  git lfs status --exit-code
+
+  # unstaged changes to Git LFS files do
+  printf "modified" > a.dat
+  for args in "" "--porcelain" "--json"; do
+    set +e
+    git lfs status --exit-code $args > status.log 2>&1
+    res=$?
+    set -e
+
+    cat status.log
+    [ "$res" -eq 1 ]
+    grep "a.dat" status.log
+  done
+
+  # as do staged ones
+  git add a.dat
+  set +e
+  git lfs status --exit-code
+  res=$?
+  set -e
+  [ "$res" -eq 1 ]
+
+  git commit -m "modify a.dat"
+  git lfs status --exit-code
+
+  # as do files converted to Git LFS
+  git add a.txt
+  git commit -m "modify a.txt"
+  git lfs track "*.txt"
+  git add --renormalize a.txt
+  git lfs status --porcelain | grep "a.txt"
+
+  set +e
+  git lfs status --exit-code
+  res=$?
+  set -e
+  [ "$res" -eq 1 ]
+)
+end_test
+
+begin_test "status: --by-pattern"
+(
+  set -e
+
+  reponame="status-by-pattern"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track "*.psd" "*.fbx"
+  mkdir -p art/sub
+  printf "*.dat filter=lfs diff=lfs merge=lfs -text\n" > art/sub/.gitattributes
+  git add .gitattributes art/sub/.gitattributes
+  git commit -m "track patterns"
+
+  git lfs status --by-pattern 2>&1 | tee status.log
+  grep "0 file(s), 0 B in total" status.log
+
+  printf "aaaa" > a.psd
+  printf "bbbbbb" > art/b.psd
+  printf "cc" > c.fbx
+  printf "d" > art/sub/d.dat
+  printf "text" > e.txt
+  git add a.psd art/b.psd c.fbx
+  # staged and then modified again: counted once, at its working tree size
+  printf "cccc" > c.fbx
+
+  git lfs status --by-pattern 2>&1 | tee status.log
+  [ "Git LFS changes by pattern:" = "$(head -n 1 status.log)" ]
+  grep -F "*.psd (.gitattributes): 2 file(s), 10 B" status.log
+  grep -F "*.fbx (.gitattributes): 1 file(s), 4 B" status.log
+  grep "0 file(s)" status.log && exit 1
+  grep "e.txt" status.log && exit 1
+  grep "3 file(s), 14 B in total" status.log
+
+  # untracked files aren't counted until they're added
+  git add art/sub/d.dat
+  git lfs status --by-pattern 2>&1 | tee status.log
+  grep -F "art/sub/**/*.dat (art/sub/.gitattributes): 1 file(s), 1 B" status.log
+
+  expected='{"patterns":[{"pattern":"*.psd","source":".gitattributes","count":2,"size":10,"files":["a.psd","art/b.psd"]},{"pattern":"*.fbx","source":".gitattributes","count":1,"size":4,"files":["c.fbx"]},{"pattern":"art/sub/**/*.dat","source":"art/sub/.gitattributes","count":1,"size":1,"files":["art/sub/d.dat"]}]}'
+  [ "$expected" = "$(git lfs status --by-pattern --json)" ]
+
+  # pointers no longer tracked by any pattern are still counted
+  git commit -m "add files"
+  git lfs untrack "*.fbx"
+  git add .gitattributes
+  printf "fbx" > c.fbx
+  git add c.fbx
+  git lfs status --by-pattern 2>&1 | tee status.log
+  grep -F "(no pattern): 1 file(s), 3 B" status.log
+
+  set +e
+  git lfs status --by-pattern --porcelain > status.log 2>&1
+  res=$?
+  set -e
+  [ "$res" -ne 0 ]
+  grep "can't be combined with --porcelain" status.log
+)
+end_test
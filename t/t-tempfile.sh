@@ -32,3 +32,90 @@ begin_test "cleans only temp files and directories older than an hour"
   [ ! -f "$tmpdir/to-destroy" ]
 )
 end_test
+
+begin_test "cleans temp files older than lfs.tmpmaxage"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-maxage"
+  git init "$reponame"
+  cd "$reponame"
+
+  tmpdir=.git/lfs/tmp
+  mkdir -p "$tmpdir"
+
+  TZ=UTC touch -t 200109170000.00 "$tmpdir/to-preserve"
+
+  git -c lfs.tmpmaxage=1000000h lfs env >/dev/null
+  [ -f "$tmpdir/to-preserve" ]
+
+  git -c lfs.tmpmaxage=24h lfs env >/dev/null
+  [ ! -f "$tmpdir/to-preserve" ]
+)
+end_test
+
+begin_test "prune --tmp"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-prune"
+  git init "$reponame"
+  cd "$reponame"
+
+  tmpdir=.git/lfs/tmp
+  mkdir -p "$tmpdir"
+
+  touch "$tmpdir/to-preserve"
+  TZ=UTC touch -t 200109170000.00 "$tmpdir/to-destroy"
+
+  git lfs prune --tmp --dry-run --verbose 2>&1 | tee prune.log
+  grep "prune: 1 stale temporary file(s) would be removed" prune.log
+  grep "to-destroy" prune.log
+  grep "to-preserve" prune.log && exit 1
+
+  TZ=UTC touch -t 200109170000.00 "$tmpdir/to-destroy"
+
+  git lfs prune --tmp 2>&1 | tee prune.log
+  grep "prune: 1 stale temporary file(s) removed" prune.log
+  [ -f "$tmpdir/to-preserve" ]
+  [ ! -f "$tmpdir/to-destroy" ]
+)
+end_test
+
+begin_test "interrupted clean leaves no temp files"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-interrupted"
+  git init "$reponame"
+  cd "$reponame"
+
+  tmpdir=.git/lfs/tmp
+  git lfs env >/dev/null
+  [ -z "$(ls "$tmpdir")" ]
+
+  mkfifo input
+  git-lfs clean a.bin < input > output 2>clean.log &
+  pid=$!
+
+  # Keep the input open, so the clean filter waits for more of it.
+  exec 3> input
+  head -c 4096 /dev/zero >&3
+
+  for i in $(seq 1 100); do
+    [ -n "$(ls "$tmpdir")" ] && break
+    sleep 0.1
+  done
+  [ -n "$(ls "$tmpdir")" ]
+
+  kill -INT "$pid"
+  set +e
+  wait "$pid"
+  res=$?
+  set -e
+  exec 3>&-
+
+  [ "$res" -ne 0 ]
+  [ -z "$(ls "$tmpdir")" ]
+)
+end_test
@@ -56,6 +56,28 @@ begin_test "track"
 )
 end_test
 
+begin_test "track (core.ignorecase)"
+(
+  set -e
+
+  mkdir track-ignorecase
+  cd track-ignorecase
+  git init
+
+  git lfs track "*.jpg" | grep "Tracking \"\*.jpg\""
+
+  git config core.ignorecase false
+  git lfs track "*.JPG" | grep "Tracking \"\*.JPG\""
+  git lfs untrack "*.JPG"
+
+  # Patterns which differ only in case are the same where case is ignored.
+  git config core.ignorecase true
+  git lfs track "*.JPG" | grep "\"\*.JPG\" already supported"
+  assert_attributes_count "jpg" "filter=lfs" 1
+  assert_attributes_count "JPG" "filter=lfs" 0
+)
+end_test
+
 begin_test "track --no-excluded"
 (
   set -e
@@ -710,3 +732,216 @@ begin_test "track: escaped glob pattern with spaces in .gitattributes"
   assert_pointer "main" "$filename" "$contents_oid" 15
 )
 end_test
+
+begin_test "track: over-broad patterns require --force"
+(
+  set -e
+
+  reponame="track-over-broad"
+  git init "$reponame"
+  cd "$reponame"
+
+  for i in $(seq 1 12); do
+    echo "text $i" > "file$i.txt"
+  done
+  echo "image" > image.psd
+  git add .
+  git commit -m "initial commit"
+
+  # matches every file tracked by Git
+  git lfs track "*.txt" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Pattern \"\*.txt\" matches 12 of the 13 files tracked by Git (92%), more than lfs.track.warnthreshold (50%)." track.log
+  grep "Not tracking any patterns. Use --force to track them anyway." track.log
+  grep "Tracking" track.log && exit 1
+  [ ! -e .gitattributes ]
+
+  # a dry run only warns
+  git lfs track --dry-run "*.txt" "*.psd" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "Pattern \"\*.txt\" matches 12 of the 13 files" track.log
+  grep "Tracking \"\*.psd\"" track.log
+  grep "Tracking \"\*.txt\"" track.log && exit 1
+  rm -f .gitattributes
+
+  git config lfs.track.warnthreshold 95
+  git lfs track "*.txt" 2>&1 | tee track.log
+  grep "Tracking \"\*.txt\"" track.log
+  grep "*.txt filter=lfs" .gitattributes
+  rm .gitattributes
+  git config --unset lfs.track.warnthreshold
+
+  # source code is refused whatever the threshold
+  echo "package main" > main.go
+  git add main.go
+  git commit -m "add source"
+  git lfs track "*" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Pattern \"\*\" matches source code, such as \"main.go\"." track.log
+  [ ! -e .gitattributes ]
+
+  git lfs track --force "*" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "Tracking \"\*\"" track.log
+  grep "^\* filter=lfs" .gitattributes
+)
+end_test
+
+begin_test "track: --export and --import"
+(
+  set -e
+
+  reponame="track-export-import"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track --lockable "*.psd"
+  git lfs track "*.bin"
+  mkdir sub
+  (cd sub && git lfs track "*.dat" && git lfs track "/top.dat")
+  printf "*.jpg filter=lfs diff=lfs merge=lfs -text\n*.jpg -filter -diff -merge text\n" >> .gitattributes
+
+  git lfs track --export | tee ../patterns
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "^\*.psd filter=lfs diff=lfs merge=lfs -text lockable$" ../patterns
+  grep "^\*.bin filter=lfs diff=lfs merge=lfs -text$" ../patterns
+  grep "^sub/\*\*/\*.dat filter=lfs diff=lfs merge=lfs -text$" ../patterns
+  grep "^sub/top.dat filter=lfs diff=lfs merge=lfs -text$" ../patterns
+  grep "jpg" ../patterns && exit 1
+
+  git lfs track --export --import ../patterns 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Cannot use --export or --import" track.log
+
+  cd ..
+  git init "$reponame-import"
+  cd "$reponame-import"
+
+  printf "*.txt eol=lf\n*.psd eol=crlf\n" > .gitattributes
+  git lfs track "*.bin"
+  mkdir -p sub/deeper
+  printf "psd\n" > image.psd
+  git add image.psd
+
+  (cd sub && git lfs track --import ../../patterns) 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "\"\*.bin\" already supported" track.log
+  grep "Tracking \"\*.psd\"" track.log
+  grep "Tracking \"sub/\*\*/\*.dat\"" track.log
+
+  grep "^\*.txt eol=lf$" .gitattributes
+  grep "^\*.psd eol=crlf filter=lfs diff=lfs merge=lfs -text lockable$" .gitattributes
+  [ "1" -eq "$(grep -c "^\*.bin" .gitattributes)" ]
+  [ ! -e sub/.gitattributes ]
+  refute_file_writeable image.psd
+
+  git lfs track --export > ../patterns-import
+  diff -u <(sort ../patterns) <(sort ../patterns-import)
+
+  git lfs track --import - < ../patterns 2>&1 | tee track.log
+  [ "0" -eq "$(grep -c "Tracking" track.log)" ]
+)
+end_test
+
+begin_test "track: --attributes-file and lfs.track.attributesfile"
+(
+  set -e
+
+  reponame="track-attributes-file"
+  git init "$reponame"
+  cd "$reponame"
+
+  mkdir -p assets/models other
+  printf "model" > assets/models/a.bin
+  git add assets/models/a.bin
+  git commit -m "initial commit"
+
+  cd assets/models
+  git lfs track --attributes-file ../../.gitattributes "*.bin" "/top.dat" "sub/*.psd" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -eq 0 ]
+  grep "Tracking \"assets/models/\*\*/\*.bin\"" track.log
+  [ ! -e .gitattributes ]
+  grep "^assets/models/\*\*/\*.bin filter=lfs diff=lfs merge=lfs -text$" ../../.gitattributes
+  grep "^assets/models/top.dat filter=lfs diff=lfs merge=lfs -text$" ../../.gitattributes
+  grep "^assets/models/sub/\*.psd filter=lfs diff=lfs merge=lfs -text$" ../../.gitattributes
+  [ "filter: lfs" = "$(git check-attr filter a.bin | cut -d' ' -f2-)" ]
+
+  git lfs track --attributes-file ../../.gitattributes "*.bin" 2>&1 | tee track.log
+  grep "\"\*.bin\" already supported" track.log
+
+  git lfs track --attributes-file ../.gitattributes "*.fbx"
+  grep "^models/\*\*/\*.fbx filter=lfs diff=lfs merge=lfs -text$" ../.gitattributes
+
+  git config lfs.track.attributesfile .gitattributes
+  git lfs track "*.wav"
+  grep "^assets/models/\*\*/\*.wav filter=lfs diff=lfs merge=lfs -text$" ../../.gitattributes
+
+  git lfs track --attributes-file ../../../.gitattributes "*.png" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "is outside of the working directory" track.log
+
+  git lfs track --attributes-file ../../other/.gitattributes "*.png" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Cannot track patterns outside of" track.log
+
+  git lfs track --attributes-file ../../attributes "*.png" 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "is not named .gitattributes" track.log
+  grep "png" ../../.gitattributes && exit 1
+  [ ! -e ../../other/.gitattributes ]
+)
+end_test
+
+begin_test "track --magic"
+(
+  set -e
+
+  reponame="track-magic"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs track --magic 2>&1 | tee track.log
+  [ "${PIPESTATUS[0]}" -ne 0 ]
+  grep "Set lfs.magic.types" track.log
+  [ ! -e .gitattributes ]
+
+  printf "\x00\x01\x02binary" > existing
+  git add existing
+  git commit -m "initial commit"
+
+  git config -f .lfsconfig lfs.magic.types "application/octet-stream"
+  git lfs track "*.psd"
+  git lfs track --magic 2>&1 | tee track.log
+  grep "Tracking files matching no other pattern by their contents" track.log
+  [ "* filter=lfs lfs-magic" = "$(head -n 1 .gitattributes)" ]
+  grep "^\*.psd filter=lfs diff=lfs merge=lfs -text$" .gitattributes
+
+  git lfs track --magic 2>&1 | tee track.log
+  grep "Already tracking files by their contents" track.log
+  [ 1 -eq "$(grep -c "lfs-magic" .gitattributes)" ]
+
+  git lfs track | tee track.log
+  grep "\* \[by content\] (.gitattributes)" track.log
+  git lfs track --export | tee export.log
+  grep "lfs-magic" export.log && exit 1
+  grep "^\*.psd " export.log
+
+  printf "\x00\x01\x02binary" > asset
+  printf "text" > notes
+  printf "layered" > image.psd
+  git add .gitattributes .lfsconfig asset notes image.psd existing
+  git commit -m "add files"
+
+  binary_oid="$(calc_oid_file asset)"
+  assert_pointer "main" "asset" "$binary_oid" 9
+  assert_pointer "main" "existing" "$binary_oid" 9
+  assert_pointer "main" "image.psd" "$(calc_oid "layered")" 7
+  [ "text" = "$(git cat-file -p main:notes)" ]
+
+  # Without any types, nothing is stored in Git LFS by its contents.
+  git config -f .lfsconfig --unset lfs.magic.types
+  printf "\x00\x01\x02other" > other
+  git add other
+  [ "$(printf "\x00\x01\x02other" | git hash-object --stdin)" = "$(git rev-parse :other)" ]
+)
+end_test
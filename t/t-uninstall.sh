@@ -263,3 +263,58 @@ begin_test "uninstall --local"
   [ "" = "$(git config --local filter.lfs.process)" ]
 )
 end_test
+
+begin_test "uninstall reports changed keys"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-report"
+  mkdir "$reponame"
+  cd "$reponame"
+  git init
+  git lfs install --local
+
+  git lfs uninstall --local 2>&1 | tee uninstall.log
+  grep "Removed filter.lfs.clean from .git/config" uninstall.log
+  grep "Removed filter.lfs.smudge from .git/config" uninstall.log
+  grep "Removed filter.lfs.process from .git/config" uninstall.log
+  grep "Removed filter.lfs.required from .git/config" uninstall.log
+
+  git lfs install
+  git lfs uninstall --skip-repo < /dev/null 2>&1 | tee uninstall.log
+  grep "Removed filter.lfs.clean from $HOME/.gitconfig" uninstall.log
+  grep "Global Git LFS configuration has been removed." uninstall.log
+  git lfs install
+)
+end_test
+
+begin_test "uninstall --skip-smudge"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-skip-smudge"
+  mkdir "$reponame"
+  cd "$reponame"
+  git init
+  git lfs install --local --skip-smudge
+
+  [ "git-lfs smudge --skip -- %f" = "$(git config --local filter.lfs.smudge)" ]
+  [ "git-lfs filter-process --skip" = "$(git config --local filter.lfs.process)" ]
+
+  git lfs uninstall --local --skip-smudge 2>&1 | tee uninstall.log
+  grep "Updated filter.lfs.smudge in .git/config" uninstall.log
+  grep "Updated filter.lfs.process in .git/config" uninstall.log
+  grep "Removed" uninstall.log && exit 1
+  grep "Automatic downloading of Git LFS objects has been restored." uninstall.log
+
+  [ "git-lfs smudge -- %f" = "$(git config --local filter.lfs.smudge)" ]
+  [ "git-lfs filter-process" = "$(git config --local filter.lfs.process)" ]
+  [ "git-lfs clean -- %f" = "$(git config --local filter.lfs.clean)" ]
+  [ -f .git/hooks/pre-push ]
+
+  git lfs uninstall --local --skip-smudge 2>&1 | tee uninstall.log
+  grep "WARNING: .*not installed with --skip-smudge" uninstall.log
+  grep "restored" uninstall.log && exit 1
+  [ "git-lfs smudge -- %f" = "$(git config --local filter.lfs.smudge)" ]
+)
+end_test
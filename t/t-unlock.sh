@@ -170,6 +170,38 @@ begin_test "unlocking lock removed file"
 )
 end_test
 
+begin_test "unlocking lock removed file without --force"
+(
+  set -e
+
+  reponame="unlock-removed-file-no-force"
+  setup_repo "$reponame" "a.dat"
+
+  mkdir -p dir/sub
+  contents="b"
+  printf "%s" "$contents" > dir/sub/b.dat
+  git add dir/sub/b.dat
+  git commit -m "add dir/sub/b.dat"
+
+  git lfs lock --json "a.dat" | tee lock.log
+  id_a=$(assert_lock lock.log a.dat)
+  git lfs lock --json "dir/sub/b.dat" | tee lock.log
+  id_b=$(assert_lock lock.log dir/sub/b.dat)
+
+  git rm -r a.dat dir
+  git commit -m "remove a.dat and dir"
+  rm *.log # ensure clean git status
+
+  git lfs unlock "a.dat" 2>&1 | tee ../unlock.log
+  grep "Unlocked a.dat" ../unlock.log
+  refute_server_lock "$reponame" "$id_a"
+
+  git lfs unlock --root-relative "dir/sub/b.dat" 2>&1 | tee ../unlock.log
+  grep "Unlocked dir/sub/b.dat" ../unlock.log
+  refute_server_lock "$reponame" "$id_b"
+)
+end_test
+
 begin_test "unlocking nonexistent file"
 (
   set -e
@@ -383,3 +415,170 @@ begin_test "unlocking a lock while untracked"
   refute_server_lock "$reponame" "$id"
 )
 end_test
+
+begin_test "unlocking a file that looks like a flag"
+(
+  set -e
+
+  reponame="unlock_flag_like_path"
+  setup_repo "$reponame" "a.dat"
+
+  echo "flag" > ./-r.dat
+  git add -- ./-r.dat
+  git commit -m "add -r.dat"
+  git push origin main
+
+  git lfs lock --json -- "-r.dat" | tee lock.log
+  id=$(assert_lock lock.log -r.dat)
+  assert_server_lock "$reponame" "$id"
+
+  git lfs unlock -- "-r.dat" 2>&1 | tee unlock.log
+  grep "Unlocked -r.dat" unlock.log
+  refute_server_lock "$reponame" "$id"
+)
+end_test
+
+begin_test "unlocking a file with spaces in its path"
+(
+  set -e
+
+  reponame="unlock_path_with_spaces"
+  setup_repo "$reponame" "a.dat"
+
+  mkdir "dir with spaces"
+  echo "spaces" > "dir with spaces/a file.dat"
+  git add "dir with spaces/a file.dat"
+  git commit -m "add file with spaces"
+  git push origin main
+
+  git lfs lock --json "dir with spaces/a file.dat" | tee lock.log
+  id=$(assert_lock lock.log "dir with spaces/a file.dat")
+  assert_server_lock "$reponame" "$id"
+
+  git lfs unlock "dir with spaces/a file.dat" 2>&1 | tee unlock.log
+  grep "Unlocked dir with spaces/a file.dat" unlock.log
+  refute_server_lock "$reponame" "$id"
+)
+end_test
+
+begin_test "unlocking a lock already deleted on the server"
+(
+  set -e
+
+  reponame="unlock-deleted-on-server"
+  setup_repo "$reponame" "a.dat"
+
+  git lfs lock --json "a.dat" | tee lock.log
+  id=$(assert_lock lock.log a.dat)
+  assert_server_lock "$reponame" "$id"
+
+  # Delete the lock on the server, but keep it in the local cache.
+  cp .git/lfs/lockcache.db lockcache.db
+  git lfs unlock "a.dat"
+  refute_server_lock "$reponame" "$id"
+  cp lockcache.db .git/lfs/lockcache.db
+
+  git lfs locks --local --json | grep "\"path\":\"a.dat\""
+
+  git lfs unlock --force "a.dat" 2>&1 | tee unlock.log
+  grep "no matching locks found" unlock.log
+  [ "[]" = "$(git lfs locks --local --json)" ]
+
+  # And by ID.
+  cp lockcache.db .git/lfs/lockcache.db
+  git lfs locks --local --json | grep "\"path\":\"a.dat\""
+
+  git lfs unlock --id="$id" 2>&1 | tee unlock.log
+  grep "unable to find lock" unlock.log
+  [ "[]" = "$(git lfs locks --local --json)" ]
+)
+end_test
+
+begin_test "unlocking a directory with --recursive"
+(
+  set -e
+
+  reponame="unlock-recursive"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir -p assets/maps
+  echo "a" > assets/a.dat
+  echo "b" > assets/maps/b.dat
+  echo "c" > c.dat
+  git add .gitattributes assets c.dat
+  git commit -m "add assets"
+  git push origin main
+
+  git lfs lock --recursive . 2>&1 | tee lock.log
+  grep "3 file(s) locked, 0 failed" lock.log
+
+  # Locks on deleted files are unlocked too.
+  git rm assets/maps/b.dat
+  git commit -m "remove b.dat"
+
+  git lfs unlock --recursive assets 2>&1 | tee unlock.log
+  grep "Unlocked assets/a.dat" unlock.log
+  grep "Unlocked assets/maps/b.dat" unlock.log
+  grep "2 file(s) unlocked, 0 failed" unlock.log
+
+  git lfs locks 2>&1 | tee locks.log
+  grep "assets/" locks.log && exit 1
+  grep "c.dat" locks.log
+
+  git lfs unlock --recursive assets 2>&1 | tee unlock.log
+  grep "no locks in directory: assets" unlock.log
+)
+end_test
+
+begin_test "unlocking the locks of another user with --owner"
+(
+  set -e
+
+  reponame="unlock-owner"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  mkdir -p assets
+  echo "a" > assets/a.dat
+  echo "b" > assets/b.dat
+  echo "c" > c.dat
+  git add .gitattributes assets c.dat
+  git commit -m "add assets"
+  git push origin main
+
+  git lfs lock --recursive . 2>&1 | tee lock.log
+  grep "3 file(s) locked, 0 failed" lock.log
+  git lfs lock --transfer-to "Jane Doe" --yes 2>&1 | tee transfer.log
+  grep "3 lock(s) transferred to Jane Doe, 0 failed" transfer.log
+
+  # Another user's locks can only be broken with --force.
+  git lfs unlock --owner "Jane Doe" "assets/" 2>&1 | tee unlock.log
+  if [ "2" -ne "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected unlock to fail"
+    exit 1
+  fi
+  grep "0 lock(s) unlocked, 2 failed" unlock.log
+
+  git lfs unlock --owner "Jane Doe" --force "assets/" 2>&1 | tee unlock.log
+  grep "Unlocked assets/a.dat" unlock.log
+  grep "Unlocked assets/b.dat" unlock.log
+  grep "2 lock(s) unlocked, 0 failed" unlock.log
+
+  git lfs locks 2>&1 | tee locks.log
+  grep "assets/" locks.log && exit 1
+  grep -E "^c.dat\s+Jane Doe" locks.log
+
+  git lfs unlock --owner "Jane Doe" --force "assets/" 2>&1 | tee unlock.log
+  grep "no matching locks held by Jane Doe" unlock.log
+
+  git lfs unlock --owner "Jane Doe" --id "1" 2>&1 | tee unlock.log
+  grep "Usage:" unlock.log
+
+  git lfs unlock --owner "Jane Doe" --force --json | tee unlock.json
+  grep '"path":"c.dat"' unlock.json
+  grep '"unlocked":true' unlock.json
+)
+end_test
@@ -0,0 +1,219 @@
+#!/usr/bin/env bash
+
+. "$(dirname "$0")/testlib.sh"
+
+begin_test "verify: objects held by the remote"
+(
+  set -e
+
+  reponame="verify-ok"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  printf "b" > b.dat
+  printf "b" > b2.dat
+  git add .gitattributes a.dat b.dat b2.dat
+  git commit -m "add files"
+  git push origin main
+
+  git lfs verify 2>&1 | tee verify.log
+  grep "verify: 2 object(s) referenced by HEAD checked against \"origin\"" verify.log
+  grep "Git LFS verify OK" verify.log
+
+  # The objects are downloaded from the remote, whether or not they are
+  # present locally, but are not kept.
+  rm -rf .git/lfs/objects
+  GIT_TRACE=1 git lfs verify --content 2>&1 | tee verify.log
+  [ 2 -eq "$(grep -c "HTTP: GET .*/storage/" verify.log)" ]
+  grep "Git LFS verify OK" verify.log
+  refute_local_object "$(calc_oid "a")"
+  refute_local_object "$(calc_oid "b")"
+
+  git lfs verify --json | tee verify.json
+  grep "\"ref\":\"HEAD\",\"commit\":\"$(git rev-parse HEAD)\",\"remote\":\"origin\",\"content\":false,\"checked\":2,\"problems\":\[\]" verify.json
+)
+end_test
+
+begin_test "verify: objects missing from the remote"
+(
+  set -e
+
+  reponame="verify-missing"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+  git push origin main
+
+  git checkout -b unpushed
+  printf "missing" > missing.dat
+  git add missing.dat
+  git commit -m "add missing.dat"
+  git checkout main
+
+  missing_oid="$(calc_oid "missing")"
+
+  git lfs verify --ref unpushed 2>&1 | tee verify.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs verify' to fail"
+    exit 1
+  fi
+  grep "verify: $missing_oid missing.dat:" verify.log
+  grep "verify: 2 object(s) referenced by unpushed checked" verify.log
+  grep "verify: 1 object(s) do not match their pointers" verify.log
+  grep "$(calc_oid "a")" verify.log && exit 1
+
+  git lfs verify --ref unpushed --json 2>/dev/null | tee verify.json
+  grep "\"problems\":\[{\"name\":\"missing.dat\",\"oid\":\"$missing_oid\",\"size\":7,\"error\":" verify.json
+
+  git lfs verify --ref main
+  git lfs verify --ref no-such-ref 2>&1 | tee verify.log
+  grep "could not resolve \"no-such-ref\"" verify.log
+)
+end_test
+
+begin_test "verify --content: objects whose contents don't match their pointers"
+(
+  set -e
+
+  reponame="verify-content"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  contents="storage-download-corrupt"
+  oid="$(calc_oid "$contents")"
+
+  git lfs track "*.dat"
+  printf "%s" "$contents" > corrupt.dat
+  printf "a" > a.dat
+  git add .gitattributes a.dat corrupt.dat
+  git commit -m "add files"
+  git push origin main
+
+  # The remote has an object for the pointer, so only downloading it shows
+  # that its contents are not those the pointer describes.
+  git lfs verify
+
+  git lfs verify --content 2>&1 | tee verify.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs verify --content' to fail"
+    exit 1
+  fi
+  grep "verify: $oid corrupt.dat: expected OID $oid" verify.log
+  grep "verify: 1 object(s) do not match their pointers" verify.log
+
+  # The local copy is left as it is.
+  assert_local_object "$oid" "${#contents}"
+)
+end_test
+
+begin_test "verify --local: corrupt objects in the local store"
+(
+  set -e
+
+  reponame="verify-local"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  printf "bbbb" > b.dat
+  printf "cccc" > c.dat
+  git add .gitattributes a.dat b.dat c.dat
+  git commit -m "add files"
+  git push origin main
+
+  git lfs verify --local 2>&1 | tee verify.log
+  grep "verify: 3 object(s) referenced by HEAD checked in the local store, 0 not present" verify.log
+  grep "Git LFS verify OK" verify.log
+
+  a_oid="$(calc_oid "a")"
+  b_oid="$(calc_oid "bbbb")"
+  c_oid="$(calc_oid "cccc")"
+
+  # b.dat's object is truncated, and c.dat's has the wrong contents.
+  printf "bb" > ".git/lfs/objects/${b_oid:0:2}/${b_oid:2:2}/$b_oid"
+  printf "dddd" > ".git/lfs/objects/${c_oid:0:2}/${c_oid:2:2}/$c_oid"
+  rm ".git/lfs/objects/${a_oid:0:2}/${a_oid:2:2}/$a_oid"
+
+  git lfs verify --local 2>&1 | tee verify.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs verify --local' to fail"
+    exit 1
+  fi
+  grep "verify: $b_oid b.dat: object is 2 byte(s), expected 4" verify.log
+  grep "verify: $c_oid c.dat: object's contents hash to $(calc_oid "dddd")" verify.log
+  grep "verify: 2 object(s) referenced by HEAD checked in the local store, 1 not present" verify.log
+  grep "verify: 2 local object(s) are corrupt" verify.log
+
+  git lfs verify --all --json 2>/dev/null | tee verify.json
+  grep "{\"all\":true,\"checked\":2,\"missing\":0,\"problems\":\[" verify.json
+  grep "\"name\":\"b.dat\",\"oid\":\"$b_oid\",\"size\":4,\"error\":\"object is 2 byte(s), expected 4\"" verify.json
+
+  git lfs verify --all --ref HEAD 2>&1 | tee verify.log
+  grep "fatal: --all and --ref cannot be combined" verify.log
+
+  # With --local, the argument is the ref to check, not a remote.
+  git branch other HEAD
+  git lfs verify --local other 2>&1 | tee verify.log
+  grep "verify: 2 object(s) referenced by other checked in the local store, 1 not present" verify.log
+  git lfs verify --local --ref HEAD other 2>&1 | tee verify.log
+  grep "fatal: --ref and a ref argument cannot be combined" verify.log
+  git lfs verify --all other 2>&1 | tee verify.log
+  grep "fatal: --all and a ref cannot be combined" verify.log
+
+  # Without it, the argument is a remote, and a ref is pointed at --local.
+  git lfs verify other 2>&1 | tee verify.log
+  grep "Invalid remote name \"other\"" verify.log
+  grep "run 'git lfs verify --local other'" verify.log
+
+  # Nothing is changed without --fix.
+  [ "bb" = "$(cat ".git/lfs/objects/${b_oid:0:2}/${b_oid:2:2}/$b_oid")" ]
+)
+end_test
+
+begin_test "verify --fix: re-download corrupt objects"
+(
+  set -e
+
+  reponame="verify-fix"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "bbbb" > b.dat
+  git add .gitattributes b.dat
+  git commit -m "add b.dat"
+  git push origin main
+
+  b_oid="$(calc_oid "bbbb")"
+  orphan_oid="$(calc_oid "orphan")"
+  printf "bb" > ".git/lfs/objects/${b_oid:0:2}/${b_oid:2:2}/$b_oid"
+
+  git lfs verify --fix main 2>&1 | tee verify.log
+  grep "verify: $b_oid b.dat: object is 2 byte(s), expected 4 (fixed)" verify.log
+  grep "referenced by main checked" verify.log
+  grep "Git LFS verify OK" verify.log
+  assert_local_object "$b_oid" 4
+  git lfs verify --local
+
+  # An object no file refers to can't be downloaded again, since its size
+  # isn't known, so it is reported, but left as it is.
+  mkdir -p ".git/lfs/objects/${orphan_oid:0:2}/${orphan_oid:2:2}"
+  printf "other" > ".git/lfs/objects/${orphan_oid:0:2}/${orphan_oid:2:2}/$orphan_oid"
+
+  git lfs verify --all --fix 2>&1 | tee verify.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "fatal: expected 'git lfs verify --all --fix' to fail"
+    exit 1
+  fi
+  grep "verify: $orphan_oid: object's contents hash to $(calc_oid "other"); not fixed, since no Git LFS file refers to it" verify.log
+  grep "verify: 2 object(s) in the local store checked" verify.log
+  grep "verify: 1 local object(s) are corrupt" verify.log
+)
+end_test
@@ -123,3 +123,118 @@ begin_test "verify with retries (bad .gitconfig)"
   [ "2" -eq "$(grep -c "verify $contents_short_oid attempt" push.log)" ]
 )
 end_test
+
+begin_test "verify without a verify action"
+(
+  set -e
+
+  reponame="verify-no-action"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "initial commit"
+
+  contents="no verify action"
+  printf "%s" "$contents" > a.dat
+
+  git add a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  [ "0" -eq "${PIPESTATUS[0]}" ]
+
+  [ "0" -eq "$(grep -c "tq: verify" push.log)" ]
+  assert_server_object "$reponame" "$(calc_oid "$contents")"
+)
+end_test
+
+begin_test "verify unsupported by the server (auto)"
+(
+  set -e
+
+  reponame="verify-404"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "initial commit"
+
+  contents="send-verify-action"
+  contents_short_oid="$(calc_oid "$contents" | head -c 7)"
+  printf "%s" "$contents" > a.dat
+
+  git add a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  [ "0" -eq "${PIPESTATUS[0]}" ]
+
+  [ "1" -eq "$(grep -c "verify $contents_short_oid attempt" push.log)" ]
+  grep "server does not implement verify, skipping verify of $contents_short_oid" push.log
+)
+end_test
+
+begin_test "verify unsupported by the server (lfs.transfer.uploadverify=true)"
+(
+  set -e
+
+  reponame="verify-required-verify-404"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git config lfs.transfer.uploadverify true
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "initial commit"
+
+  contents="send-verify-action"
+  contents_short_oid="$(calc_oid "$contents" | head -c 7)"
+  printf "%s" "$contents" > a.dat
+
+  git add a.dat
+  git commit -m "add a.dat"
+
+  set +e
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "verify: expected \"git push\" to fail, didn't ..."
+    exit 1
+  fi
+  set -e
+
+  [ "3" -eq "$(grep -c "verify $contents_short_oid attempt" push.log)" ]
+)
+end_test
+
+begin_test "verify disabled (lfs.transfer.uploadverify=false)"
+(
+  set -e
+
+  reponame="verify-disabled-verify-404"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git config lfs.transfer.uploadverify false
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "initial commit"
+
+  contents="send-verify-action"
+  contents_short_oid="$(calc_oid "$contents" | head -c 7)"
+  printf "%s" "$contents" > a.dat
+
+  git add a.dat
+  git commit -m "add a.dat"
+
+  GIT_TRACE=1 git push origin main 2>&1 | tee push.log
+  [ "0" -eq "${PIPESTATUS[0]}" ]
+
+  [ "0" -eq "$(grep -c "verify $contents_short_oid attempt" push.log)" ]
+  grep "skipping verify of $contents_short_oid, disabled by lfs.transfer.uploadverify" push.log
+)
+end_test
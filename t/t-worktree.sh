@@ -5,7 +5,9 @@
 ensure_git_version_isnt $VERSION_LOWER "2.5.0"
 envInitConfig='git config filter.lfs.process = "git-lfs filter-process"
 git config filter.lfs.smudge = "git-lfs smudge -- %f"
-git config filter.lfs.clean = "git-lfs clean -- %f"'
+git config filter.lfs.clean = "git-lfs clean -- %f"
+git config filter.lfs.required = "true"
+FilterMode=process'
 
 unset_vars () {
     # If set, these will cause the test to fail.
@@ -47,8 +49,8 @@ PruneRemoteName=origin
 LfsStorageDir=$(canonical_path_escaped "$TRASHDIR/$reponame/.git/lfs")
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 $(escape_path "$(env | grep "^GIT")")
 %s
 " "$(git lfs version)" "$(git version)" "$envInitConfig")
@@ -83,8 +85,8 @@ PruneRemoteName=origin
 LfsStorageDir=$(canonical_path_escaped "$TRASHDIR/$reponame/.git/lfs")
 AccessDownload=none
 AccessUpload=none
-DownloadTransfers=basic,lfs-standalone-file
-UploadTransfers=basic,lfs-standalone-file
+DownloadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
+UploadTransfers=basic,lfs-standalone-file,lfs-standalone-sftp
 $(escape_path "$(env | grep "^GIT")")
 %s
 " "$(git lfs version)" "$(git version)" "$envInitConfig")
@@ -20,6 +20,9 @@ type PercentageTask struct {
 	total uint64
 	// msg is the task message.
 	msg string
+	// detail, if non-nil, returns more about the work done so far, which
+	// is shown after the count of elements.
+	detail func() string
 	// ch is a channel which is written to when the task state changes and
 	// is closed when the task is completed.
 	ch chan *Update
@@ -54,9 +57,16 @@ func (c *PercentageTask) Count(n uint64) (new uint64) {
 		percentage = 100 * float64(new) / float64(c.total)
 	}
 
+	s := fmt.Sprintf("%s: %3.f%% (%d/%d)",
+		c.msg, math.Floor(percentage), new, c.total)
+	if c.detail != nil {
+		if detail := c.detail(); len(detail) > 0 {
+			s = fmt.Sprintf("%s, %s", s, detail)
+		}
+	}
+
 	c.ch <- &Update{
-		S: fmt.Sprintf("%s: %3.f%% (%d/%d)",
-			c.msg, math.Floor(percentage), new, c.total),
+		S:  s,
 		At: time.Now(),
 	}
 
@@ -67,6 +77,14 @@ func (c *PercentageTask) Count(n uint64) (new uint64) {
 	return new
 }
 
+// Detail sets "fn" to be called each time work is counted, returning more
+// about the work done so far, such as its throughput, to be shown after the
+// count of elements. It must be called before Count is, and "fn" must be safe
+// to call from whichever goroutine calls Count.
+func (c *PercentageTask) Detail(fn func() string) {
+	c.detail = fn
+}
+
 // Entry logs a line-delimited task entry.
 func (t *PercentageTask) Entry(update string) {
 	t.ch <- &Update{
@@ -17,6 +17,21 @@ func TestPercentageTaskCalculuatesPercentages(t *testing.T) {
 	assert.Equal(t, "example:  30% (3/10)", (<-task.Updates()).S)
 }
 
+func TestPercentageTaskShowsDetail(t *testing.T) {
+	task := NewPercentageTask("example", 10)
+	assert.Equal(t, "example:   0% (0/10)", (<-task.Updates()).S)
+
+	detail := "1 MB/s"
+	task.Detail(func() string { return detail })
+
+	task.Count(3)
+	assert.Equal(t, "example:  30% (3/10), 1 MB/s", (<-task.Updates()).S)
+
+	detail = ""
+	task.Count(1)
+	assert.Equal(t, "example:  40% (4/10)", (<-task.Updates()).S)
+}
+
 func TestPercentageTaskCalculatesPercentWithoutTotal(t *testing.T) {
 	task := NewPercentageTask("example", 0)
 
@@ -0,0 +1,14 @@
+// +build !linux,!openbsd,!dragonfly,!solaris
+// +build !darwin,!freebsd,!netbsd
+// +build !windows
+
+package tools
+
+import (
+	"os"
+	"time"
+)
+
+func accessTime(fi os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,17 @@
+// +build windows
+
+package tools
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func accessTime(fi os.FileInfo) (time.Time, bool) {
+	data, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, data.LastAccessTime.Nanoseconds()), true
+}
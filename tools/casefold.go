@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// caseDirEntries caches the names of the entries of each directory
+	// read by CanonicalCase.
+	caseDirEntries   = make(map[string][]string)
+	caseDirEntriesMu sync.Mutex
+)
+
+// PathsEqual returns whether "a" and "b" name the same path, ignoring case if
+// "caseInsensitive" is set.
+func PathsEqual(a, b string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// FoldPath returns "path" in a form which is the same for all the paths naming
+// it, such as for use as a key in a map: in lower case if "caseInsensitive" is
+// set, and unchanged otherwise.
+func FoldPath(path string, caseInsensitive bool) string {
+	if caseInsensitive {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// CanonicalCase returns "path", which is relative to "root" and uses forward
+// slashes, with each of its elements spelt as the entry on disk which it
+// matches regardless of case, if "caseInsensitive" is set, so that the same
+// file is always named the same way however it was typed. Elements which exist
+// as they are, or have no such entry, are left unchanged.
+func CanonicalCase(root, path string, caseInsensitive bool) string {
+	if !caseInsensitive || len(path) == 0 {
+		return path
+	}
+
+	elems := strings.Split(path, "/")
+	dir := root
+	for i, elem := range elems {
+		if len(elem) == 0 || elem == "." || elem == ".." {
+			dir = filepath.Join(dir, elem)
+			continue
+		}
+
+		names, err := dirEntryNames(dir)
+		if err != nil {
+			break
+		}
+
+		var match string
+		for _, name := range names {
+			if name == elem {
+				match = elem
+				break
+			}
+			if len(match) == 0 && strings.EqualFold(name, elem) {
+				match = name
+			}
+		}
+		if len(match) == 0 {
+			break
+		}
+
+		elems[i] = match
+		dir = filepath.Join(dir, match)
+	}
+	return strings.Join(elems, "/")
+}
+
+// dirEntryNames returns the names of the entries of the directory "dir", which
+// are read once and cached, since many paths in the same directories may be
+// given to CanonicalCase in turn.
+func dirEntryNames(dir string) ([]string, error) {
+	caseDirEntriesMu.Lock()
+	defer caseDirEntriesMu.Unlock()
+
+	if names, ok := caseDirEntries[dir]; ok {
+		return names, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	caseDirEntries[dir] = names
+	return names, nil
+}
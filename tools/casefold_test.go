@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathsEqual(t *testing.T) {
+	assert.True(t, PathsEqual("a/Foo.PNG", "a/Foo.PNG", false))
+	assert.False(t, PathsEqual("a/Foo.PNG", "a/foo.png", false))
+	assert.True(t, PathsEqual("a/Foo.PNG", "a/foo.png", true))
+	assert.False(t, PathsEqual("a/Foo.PNG", "b/foo.png", true))
+}
+
+func TestFoldPath(t *testing.T) {
+	assert.Equal(t, "a/Foo.PNG", FoldPath("a/Foo.PNG", false))
+	assert.Equal(t, "a/foo.png", FoldPath("a/Foo.PNG", true))
+}
+
+func TestCanonicalCase(t *testing.T) {
+	root, err := ioutil.TempDir("", "canonical-case")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	require.Nil(t, os.MkdirAll(filepath.Join(root, "Dir", "Sub"), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(root, "Dir", "Sub", "Foo.PNG"), nil, 0644))
+
+	for desc, c := range map[string]struct {
+		Given           string
+		CaseInsensitive bool
+		Expected        string
+	}{
+		"case-sensitive": {"dir/sub/foo.png", false, "dir/sub/foo.png"},
+		"exact":          {"Dir/Sub/Foo.PNG", true, "Dir/Sub/Foo.PNG"},
+		"different case": {"dir/SUB/foo.png", true, "Dir/Sub/Foo.PNG"},
+		"missing file":   {"dir/sub/bar.png", true, "Dir/Sub/bar.png"},
+		"missing dir":    {"dir/other/foo.png", true, "Dir/other/foo.png"},
+		"current dir":    {"./dir/foo", true, "./Dir/foo"},
+		"empty":          {"", true, ""},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, c.Expected, CanonicalCase(root, c.Given, c.CaseInsensitive))
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package tools
+
+import (
+	"sync"
+)
+
+var (
+	cloneFileDirs   = make(map[string]bool)
+	cloneFileDirsMu sync.Mutex
+)
+
+// CanCloneFilesIn returns whether files in the directory "dir" can be cloned,
+// sharing their data on disk rather than being copied, as CloneFileByPath
+// does. It is found with CheckCloneFileSupported the first time it is asked
+// for each directory, and cached thereafter. Directories which can't be
+// checked, such as those which are read-only, are taken not to support it.
+func CanCloneFilesIn(dir string) bool {
+	cloneFileDirsMu.Lock()
+	defer cloneFileDirsMu.Unlock()
+
+	if supported, ok := cloneFileDirs[dir]; ok {
+		return supported
+	}
+
+	supported, err := CheckCloneFileSupported(dir)
+	supported = supported && err == nil
+	cloneFileDirs[dir] = supported
+	return supported
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanCloneFilesIn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "can-clone-files")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	expected, err := CheckCloneFileSupported(dir)
+	expected = expected && err == nil
+
+	assert.Equal(t, expected, CanCloneFilesIn(dir))
+	assert.Equal(t, expected, CanCloneFilesIn(dir), "cached")
+
+	entries, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Empty(t, entries, "the check should leave nothing behind")
+}
+
+func TestCanCloneFilesInMissingDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "can-clone-files")
+	require.Nil(t, err)
+	os.RemoveAll(dir)
+
+	assert.False(t, CanCloneFilesIn(dir))
+}
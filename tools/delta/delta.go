@@ -0,0 +1,353 @@
+// package delta encodes an object as its differences from another, similar
+// object, its base, so that a new version of a file can be sent as the parts
+// of it which aren't in an earlier version the receiver already has.
+//
+// Deltas are made by matching blocks of the base against every offset of the
+// object with a rolling checksum, in the way rsync(1) does, and are
+// compressed with DEFLATE.
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// MediaType is the type of the body of a request or response holding
+	// a delta.
+	MediaType = "application/vnd.git-lfs.delta"
+
+	// BlockSize is the size of the blocks of the base which are matched
+	// against the object.
+	BlockSize = 4096
+
+	// maxLiteral is the most data which is held before it is written as
+	// an insertion, which bounds the memory used for an object which
+	// shares little with its base.
+	maxLiteral = 1024 * 1024
+
+	version = 1
+)
+
+var (
+	magic = []byte("LFSD")
+
+	// ErrInvalid is returned by Apply for data which isn't a delta, or
+	// isn't a delta from the given base.
+	ErrInvalid = errors.New("delta: invalid delta")
+)
+
+// Operations of a delta, after its header.
+const (
+	// opCopy is followed by the offset and length of data of the base
+	// which is copied to the object.
+	opCopy = byte('c')
+	// opInsert is followed by the length of data which is inserted into
+	// the object, and the data.
+	opInsert = byte('i')
+	// opEnd is followed by the size of the object, and ends the delta.
+	opEnd = byte('e')
+)
+
+// Diff writes to "w" a delta which makes the contents of "target" from the
+// "baseSize" bytes of "base". It returns the number of bytes of "target" read.
+func Diff(base io.ReaderAt, baseSize int64, target io.Reader, w io.Writer) (int64, error) {
+	index, err := indexBase(base, baseSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := w.Write(append(append([]byte{}, magic...), version)); err != nil {
+		return 0, err
+	}
+	fw, err := flate.NewWriter(w, flate.DefaultCompression)
+	if err != nil {
+		return 0, err
+	}
+
+	e := &encoder{w: bufio.NewWriter(fw)}
+	e.uvarint(uint64(baseSize))
+
+	d := &differ{base: base, index: index, target: target, e: e}
+	if err := d.run(); err != nil {
+		return d.read, err
+	}
+
+	e.flushCopy()
+	e.w.WriteByte(opEnd)
+	e.uvarint(uint64(d.read))
+	if err := e.w.Flush(); err != nil {
+		return d.read, err
+	}
+	return d.read, fw.Close()
+}
+
+// Apply writes to "w" the object made by the delta read from "r" from the
+// "baseSize" bytes of "base", and returns its size.
+func Apply(base io.ReaderAt, baseSize int64, r io.Reader, w io.Writer) (int64, error) {
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, ErrInvalid
+	}
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return 0, ErrInvalid
+	}
+	if header[len(magic)] != version {
+		return 0, fmt.Errorf("delta: unsupported version %d", header[len(magic)])
+	}
+
+	br := bufio.NewReader(flate.NewReader(r))
+	if n, err := binary.ReadUvarint(br); err != nil || int64(n) != baseSize {
+		return 0, fmt.Errorf("delta: not made from a base of %d byte(s)", baseSize)
+	}
+
+	var written int64
+	for {
+		op, err := br.ReadByte()
+		if err != nil {
+			return written, ErrInvalid
+		}
+
+		switch op {
+		case opCopy:
+			offset, err1 := binary.ReadUvarint(br)
+			length, err2 := binary.ReadUvarint(br)
+			if err1 != nil || err2 != nil || offset > uint64(baseSize) || length > uint64(baseSize)-offset {
+				return written, ErrInvalid
+			}
+			n, err := io.Copy(w, io.NewSectionReader(base, int64(offset), int64(length)))
+			written += n
+			if err != nil {
+				return written, err
+			}
+		case opInsert:
+			length, err := binary.ReadUvarint(br)
+			if err != nil {
+				return written, ErrInvalid
+			}
+			n, err := io.CopyN(w, br, int64(length))
+			written += n
+			if err == io.EOF {
+				return written, ErrInvalid
+			} else if err != nil {
+				return written, err
+			}
+		case opEnd:
+			size, err := binary.ReadUvarint(br)
+			if err != nil || int64(size) != written {
+				return written, ErrInvalid
+			}
+			return written, nil
+		default:
+			return written, ErrInvalid
+		}
+	}
+}
+
+// indexBase returns the offsets of the whole blocks of "base", by their weak
+// checksums. A partial block at the end isn't matched.
+func indexBase(base io.ReaderAt, baseSize int64) (map[uint32][]int64, error) {
+	index := make(map[uint32][]int64, baseSize/BlockSize)
+	r := bufio.NewReaderSize(io.NewSectionReader(base, 0, baseSize), 64*1024)
+	block := make([]byte, BlockSize)
+	for offset := int64(0); offset+BlockSize <= baseSize; offset += BlockSize {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		sum := newChecksum(block).sum()
+		index[sum] = append(index[sum], offset)
+	}
+	return index, nil
+}
+
+// differ finds the blocks of the base in the target, and encodes the target
+// as copies of them, and insertions of the data between them.
+type differ struct {
+	base   io.ReaderAt
+	index  map[uint32][]int64
+	target io.Reader
+	e      *encoder
+
+	// buf holds the data of the target which hasn't been encoded yet,
+	// starting with any literal data, which ends at "pos", where the
+	// window being matched starts.
+	buf  []byte
+	pos  int
+	eof  bool
+	read int64
+
+	block []byte
+}
+
+func (d *differ) run() error {
+	d.block = make([]byte, BlockSize)
+
+	var sum *checksum
+	for {
+		if len(d.buf)-d.pos < BlockSize {
+			if err := d.fill(); err != nil {
+				return err
+			}
+			if len(d.buf)-d.pos < BlockSize {
+				break
+			}
+		}
+
+		if sum == nil {
+			sum = newChecksum(d.buf[d.pos : d.pos+BlockSize])
+		}
+
+		if offset, ok := d.match(sum.sum()); ok {
+			d.e.insert(d.buf[:d.pos])
+			d.e.copy(offset, BlockSize)
+			d.buf = d.buf[d.pos+BlockSize:]
+			d.pos = 0
+			sum = nil
+			continue
+		}
+
+		if d.pos+BlockSize >= len(d.buf) {
+			if err := d.fill(); err != nil {
+				return err
+			}
+			if d.pos+BlockSize >= len(d.buf) {
+				break
+			}
+		}
+		sum.roll(d.buf[d.pos], d.buf[d.pos+BlockSize])
+		d.pos++
+
+		if d.pos >= maxLiteral {
+			d.e.insert(d.buf[:d.pos])
+			d.buf = d.buf[d.pos:]
+			d.pos = 0
+		}
+	}
+
+	// What's left is shorter than a block, so can only be inserted.
+	for !d.eof {
+		if err := d.fill(); err != nil {
+			return err
+		}
+	}
+	d.e.insert(d.buf)
+	return nil
+}
+
+// match returns the offset of a block of the base whose checksum is "sum",
+// and whose contents are those of the window, if there is one.
+func (d *differ) match(sum uint32) (int64, bool) {
+	window := d.buf[d.pos : d.pos+BlockSize]
+	for _, offset := range d.index[sum] {
+		if _, err := d.base.ReadAt(d.block, offset); err != nil && err != io.EOF {
+			continue
+		}
+		if bytes.Equal(d.block, window) {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// fill reads more of the target, moving what hasn't been encoded to the start
+// of a new buffer as it needs to.
+func (d *differ) fill() error {
+	if d.eof {
+		return nil
+	}
+
+	if cap(d.buf)-len(d.buf) < BlockSize {
+		buf := make([]byte, len(d.buf), len(d.buf)+maxLiteral+2*BlockSize)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+
+	var n int
+	var err error
+	for n == 0 && err == nil {
+		n, err = d.target.Read(d.buf[len(d.buf):cap(d.buf)])
+	}
+	d.buf = d.buf[:len(d.buf)+n]
+	d.read += int64(n)
+	if err == io.EOF {
+		d.eof = true
+		return nil
+	}
+	return err
+}
+
+// encoder writes the operations of a delta, joining copies of adjacent data of
+// the base into one.
+type encoder struct {
+	w *bufio.Writer
+
+	copyOffset int64
+	copyLength int64
+
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) copy(offset, length int64) {
+	if e.copyLength > 0 && e.copyOffset+e.copyLength == offset {
+		e.copyLength += length
+		return
+	}
+	e.flushCopy()
+	e.copyOffset, e.copyLength = offset, length
+}
+
+func (e *encoder) flushCopy() {
+	if e.copyLength == 0 {
+		return
+	}
+	e.w.WriteByte(opCopy)
+	e.uvarint(uint64(e.copyOffset))
+	e.uvarint(uint64(e.copyLength))
+	e.copyLength = 0
+}
+
+func (e *encoder) insert(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	e.flushCopy()
+	e.w.WriteByte(opInsert)
+	e.uvarint(uint64(len(data)))
+	e.w.Write(data)
+}
+
+func (e *encoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.scratch[:], v)
+	e.w.Write(e.scratch[:n])
+}
+
+// checksum is the weak, rolling checksum of a block, as used by rsync(1),
+// which can be moved along by one byte at a time.
+type checksum struct {
+	a, b uint32
+}
+
+func newChecksum(block []byte) *checksum {
+	c := &checksum{}
+	for i, x := range block {
+		c.a += uint32(x)
+		c.b += uint32(len(block)-i) * uint32(x)
+	}
+	return c
+}
+
+// roll moves the block along by one byte, dropping "out" from its start and
+// adding "in" at its end.
+func (c *checksum) roll(out, in byte) {
+	c.a += uint32(in) - uint32(out)
+	c.b += c.a - BlockSize*uint32(out)
+}
+
+func (c *checksum) sum() uint32 {
+	return (c.a & 0xffff) | (c.b << 16)
+}
@@ -0,0 +1,85 @@
+package delta_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/git-lfs/git-lfs/tools/delta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func roundTrip(t *testing.T, base, target []byte) []byte {
+	var buf bytes.Buffer
+	read, err := delta.Diff(bytes.NewReader(base), int64(len(base)), bytes.NewReader(target), &buf)
+	require.Nil(t, err)
+	assert.EqualValues(t, len(target), read)
+	encoded := buf.Bytes()
+
+	var out bytes.Buffer
+	written, err := delta.Apply(bytes.NewReader(base), int64(len(base)), bytes.NewReader(encoded), &out)
+	require.Nil(t, err)
+	assert.EqualValues(t, len(target), written)
+	assert.True(t, bytes.Equal(target, out.Bytes()))
+	return encoded
+}
+
+func TestDeltaOfSimilarObject(t *testing.T) {
+	base := randomBytes(1, 3*1024*1024+123)
+
+	// Data is inserted, changed and removed, which moves the rest of the
+	// object away from the block boundaries of the base.
+	var target []byte
+	target = append(target, base[:100000]...)
+	target = append(target, []byte("inserted")...)
+	target = append(target, base[100000:2000000]...)
+	target = append(target, randomBytes(2, 5000)...)
+	target = append(target, base[2010000:]...)
+
+	encoded := roundTrip(t, base, target)
+	assert.True(t, len(encoded) < 32*1024, "delta of %d byte(s) is too large", len(encoded))
+}
+
+func TestDeltaOfUnrelatedObject(t *testing.T) {
+	base := randomBytes(1, 100000)
+	target := randomBytes(2, 2*1024*1024+17)
+
+	roundTrip(t, base, target)
+}
+
+func TestDeltaOfEmptyObjects(t *testing.T) {
+	roundTrip(t, nil, randomBytes(1, 5000))
+	roundTrip(t, randomBytes(1, 5000), nil)
+	roundTrip(t, nil, nil)
+}
+
+func TestDeltaOfSmallObject(t *testing.T) {
+	base := randomBytes(1, 10000)
+	roundTrip(t, base, base[:delta.BlockSize-1])
+	roundTrip(t, base, base)
+}
+
+func TestApplyInvalidDelta(t *testing.T) {
+	base := randomBytes(1, 10000)
+	var buf bytes.Buffer
+	_, err := delta.Diff(bytes.NewReader(base), int64(len(base)), bytes.NewReader(base[5:]), &buf)
+	require.Nil(t, err)
+	encoded := buf.Bytes()
+
+	var out bytes.Buffer
+	_, err = delta.Apply(bytes.NewReader(base), int64(len(base)), bytes.NewReader([]byte("not a delta")), &out)
+	assert.Equal(t, delta.ErrInvalid, err)
+
+	_, err = delta.Apply(bytes.NewReader(base), int64(len(base)), bytes.NewReader(encoded[:len(encoded)-4]), &out)
+	assert.NotNil(t, err)
+
+	_, err = delta.Apply(bytes.NewReader(base[:9000]), 9000, bytes.NewReader(encoded), &out)
+	assert.EqualError(t, err, "delta: not made from a base of 9000 byte(s)")
+}
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/filepathfilter"
@@ -448,6 +449,18 @@ func SetFileWriteFlag(path string, writeEnabled bool) error {
 	return os.Chmod(path, os.FileMode(mode))
 }
 
+// AccessTime returns the time the file described by "fi" was last accessed,
+// or modified, if that is later, as it is where access times aren't updated
+// on every read. Where access times aren't available, it is the time the file
+// was last modified.
+func AccessTime(fi os.FileInfo) time.Time {
+	atime, ok := accessTime(fi)
+	if !ok || atime.Before(fi.ModTime()) {
+		return fi.ModTime()
+	}
+	return atime
+}
+
 // TempFile creates a temporary file in specified directory with proper permissions for the repository.
 // On success, it returns an open, non-nil *os.File, and the caller is responsible
 // for closing and/or removing it.  On failure, the temporary file is
@@ -2,7 +2,10 @@
 
 package tools
 
-import "path/filepath"
+import (
+	"path/filepath"
+	"syscall"
+)
 
 func CanonicalizeSystemPath(path string) (string, error) {
 	path, err := filepath.Abs(path)
@@ -11,3 +14,22 @@ func CanonicalizeSystemPath(path string) (string, error) {
 	}
 	return filepath.EvalSymlinks(path)
 }
+
+// DiskFree returns the number of bytes available to an unprivileged user on
+// the filesystem containing "path".
+func DiskFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+// IsHardLinked returns whether the file at "path" has other hard links to it.
+func IsHardLinked(path string) bool {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return false
+	}
+	return st.Mode&syscall.S_IFMT == syscall.S_IFREG && st.Nlink > 1
+}
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -334,3 +335,38 @@ func TestExecutablePermissions(t *testing.T) {
 	assert.EqualValues(t, os.FileMode(0750), ExecutablePermissions(0640))
 	assert.EqualValues(t, os.FileMode(0700), ExecutablePermissions(0600))
 }
+
+func TestDiskFree(t *testing.T) {
+	free, err := DiskFree(os.TempDir())
+	assert.Nil(t, err)
+	assert.True(t, free > 0)
+
+	_, err = DiskFree(filepath.Join(os.TempDir(), "does-not-exist", "at-all"))
+	assert.NotNil(t, err)
+}
+
+func TestAccessTime(t *testing.T) {
+	f, err := ioutil.TempFile("", "lfstestaccesstime")
+	assert.Nil(t, err)
+	filename := f.Name()
+	defer os.Remove(filename)
+	f.Close()
+
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// The modification time is used when it is later.
+	assert.Nil(t, os.Chtimes(filename, older, newer))
+	fi, err := os.Stat(filename)
+	assert.Nil(t, err)
+	assert.True(t, AccessTime(fi).Equal(newer))
+
+	assert.Nil(t, os.Chtimes(filename, newer, older))
+	fi, err = os.Stat(filename)
+	assert.Nil(t, err)
+	if _, ok := accessTime(fi); ok {
+		assert.True(t, AccessTime(fi).Equal(newer))
+	} else {
+		assert.True(t, AccessTime(fi).Equal(older))
+	}
+}
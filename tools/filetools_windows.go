@@ -51,3 +51,38 @@ func CanonicalizeSystemPath(path string) (string, error) {
 	}
 	return s, nil
 }
+
+// DiskFree returns the number of bytes available to the current user on the
+// volume containing "path".
+func DiskFree(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
+
+// IsHardLinked returns whether the file at "path" has other hard links to it.
+func IsHardLinked(path string) bool {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+
+	h, err := windows.CreateFile(p, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &info); err != nil {
+		return false
+	}
+	return info.NumberOfLinks > 1
+}
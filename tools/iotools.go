@@ -39,6 +39,30 @@ func CopyWithCallback(writer io.Writer, reader io.Reader, totalSize int64, cb Co
 	return io.Copy(writer, cbReader)
 }
 
+// CopyWithCallbackBuffer copies reader to writer like CopyWithCallback, but
+// always stages the data through "buf", even if either side could copy more
+// efficiently without it, so that no more than len(buf) bytes of the data are
+// held in memory at once.
+func CopyWithCallbackBuffer(writer io.Writer, reader io.Reader, totalSize int64, cb CopyCallback, buf []byte) (int64, error) {
+	if success, _ := CloneFile(writer, reader); success {
+		if cb != nil {
+			cb(totalSize, totalSize, 0)
+		}
+		return totalSize, nil
+	}
+	if cb != nil {
+		reader = &CallbackReader{
+			C:         cb,
+			TotalSize: totalSize,
+			Reader:    reader,
+		}
+	}
+
+	// Hide any io.WriterTo or io.ReaderFrom implementations, which
+	// io.CopyBuffer would otherwise use instead of "buf".
+	return io.CopyBuffer(struct{ io.Writer }{writer}, struct{ io.Reader }{reader}, buf)
+}
+
 // Get a new Hash instance of the type used to hash LFS content
 func NewLfsContentHash() hash.Hash {
 	return sha256.New()
@@ -51,10 +51,13 @@ func CloneFileByPath(dst, src string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	defer srcFile.Close()
+
 	dstFile, err := os.Create(dst) //truncating, it if it already exists.
 	if err != nil {
 		return false, err
 	}
+	defer dstFile.Close()
 
 	return CloneFile(dstFile, srcFile)
 }
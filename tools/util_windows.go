@@ -65,11 +65,13 @@ func CloneFileByPath(dst, src string) (success bool, err error) {
 	if err != nil {
 		return
 	}
+	defer dstFile.Close()
 
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return
 	}
+	defer srcFile.Close()
 
 	return CloneFile(dstFile, srcFile)
 }
@@ -2,13 +2,18 @@ package tq
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/git-lfs/git-lfs/errors"
 	"github.com/git-lfs/git-lfs/fs"
 	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/tools"
 	"github.com/rubyist/tracerx"
 )
 
@@ -25,6 +30,12 @@ type adapterBase struct {
 	jobChan      chan *job
 	debugging    bool
 	cb           ProgressCallback
+	// bandwidth limits "cb" of each transfer by lfs.transfer.maxbandwidth
+	// and lfs.transfer.maxbandwidthperobject, if they are set, before it
+	// is given to the transferImplementation, which gives the progress of
+	// bytes which weren't transferred, such as those of a resumed
+	// transfer, to "cb".
+	bandwidth *bandwidthLimiter
 	// WaitGroup to sync the completion of all workers
 	workerWait sync.WaitGroup
 	// WaitGroup to sync the completion of all in-flight jobs
@@ -53,7 +64,7 @@ type transferImplementation interface {
 
 const (
 	enableHrefRewriteKey     = "lfs.transfer.enablehrefrewrite"
-	defaultEnableHrefRewrite = false
+	defaultEnableHrefRewrite = true
 )
 
 func newAdapterBase(f *fs.Filesystem, name string, dir Direction, ti transferImplementation) *adapterBase {
@@ -78,6 +89,7 @@ func (a *adapterBase) Begin(cfg AdapterConfig, cb ProgressCallback) error {
 	a.apiClient = cfg.APIClient()
 	a.remote = cfg.Remote()
 	a.cb = cb
+	a.bandwidth = cfg.bandwidth()
 	a.jobChan = make(chan *job, 100)
 	a.debugging = a.apiClient.OSEnv().Bool("GIT_TRANSFER_TRACE", false) ||
 		a.apiClient.OSEnv().Bool("GIT_CURL_VERBOSE", false)
@@ -179,7 +191,9 @@ func (a *adapterBase) worker(workerNum int, ctx interface{}) {
 		if t.Size < 0 {
 			err = fmt.Errorf("object %q has invalid size (got: %d)", t.Oid, t.Size)
 		} else {
-			err = a.transferImpl.DoTransfer(ctx, t, a.cb, authCallback)
+			start := time.Now()
+			err = a.doTransfer(ctx, t, authCallback)
+			t.Elapsed = time.Since(start)
 		}
 
 		// Mark the job as completed, and alter all listeners
@@ -196,15 +210,47 @@ func (a *adapterBase) worker(workerNum int, ctx interface{}) {
 	a.workerWait.Done()
 }
 
+// doTransfer makes the transfer "t". Downloads into a shared object store (see:
+// "lfs.storage.shared") are made holding the object's lock, so that
+// repositories sharing it don't download the same object at once, and objects
+// which another process downloaded while this one waited for the lock aren't
+// downloaded again.
+func (a *adapterBase) doTransfer(ctx interface{}, t *Transfer, authCallback func()) error {
+	cb, release := a.bandwidth.callback(t, a.cb)
+	defer release()
+
+	if a.direction != Download || a.fs == nil || !a.fs.Shared || len(t.Path) == 0 {
+		return a.transferImpl.DoTransfer(ctx, t, cb, authCallback)
+	}
+
+	unlock, err := a.fs.LockObject(t.Oid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if tools.FileExistsOfSize(t.Path, t.Size) {
+		a.Trace("xfer: %q was downloaded into the shared object store by another process, skipping", t.Oid)
+		if authCallback != nil {
+			authCallback()
+		}
+		advanceCallbackProgress(a.cb, t, t.Size)
+		return nil
+	}
+	return a.transferImpl.DoTransfer(ctx, t, cb, authCallback)
+}
+
 var httpRE = regexp.MustCompile(`\Ahttps?://`)
 
 func (a *adapterBase) newHTTPRequest(method string, rel *Action) (*http.Request, error) {
-	enableRewrite := a.apiClient.GitEnv().Bool(enableHrefRewriteKey, defaultEnableHrefRewrite)
+	return newActionRequest(method, rel)
+}
 
+// newActionRequest returns a request for the action "rel", whose href has
+// already been rewritten by any "url.*.insteadOf" settings when the batch
+// response was read.
+func newActionRequest(method string, rel *Action) (*http.Request, error) {
 	href := rel.Href
-	if enableRewrite {
-		href = a.apiClient.Endpoints.NewEndpoint(a.direction.String(), rel.Href).Url
-	}
 
 	if !httpRE.MatchString(href) {
 		urlfragment := strings.SplitN(href, "?", 2)[0]
@@ -231,6 +277,46 @@ func (a *adapterBase) doHTTP(t *Transfer, req *http.Request) (*http.Response, er
 	return a.apiClient.DoWithAuthNoRetry(a.remote, a.apiClient.Endpoints.AccessFor(endpoint), req)
 }
 
+// doHTTPWithFreshAuth sends "req" to an action URL of "t" with doHTTP. If the
+// server responds with a 401 to the credentials filled for it, they are
+// rejected, and it is sent once more with fresh ones, after calling "reset",
+// if given, to rewind its body.
+//
+// If the authorization is refused with a 401 again, or a 401 or 403 refuses
+// the authorization given by the action itself, the action has most likely
+// expired part of the way through the transfer, as signed URLs and tokens may,
+// so a retriable error is returned, which causes the object to be retried in a
+// new batch with fresh actions. A 403 to the credentials filled for the
+// request is a refusal of permission, so is returned as it is, as are other
+// responses.
+func (a *adapterBase) doHTTPWithFreshAuth(t *Transfer, req *http.Request, reset func() error) (*http.Response, error) {
+	actionAuth := len(req.Header.Get("Authorization")) > 0
+
+	res, err := a.doHTTP(t, req)
+	if res != nil && res.StatusCode == 401 && errors.IsAuthError(err) && !actionAuth && !t.Authenticated {
+		// The response is dropped, so its connection can be reused
+		// for the retry.
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		if reset != nil {
+			if rerr := reset(); rerr != nil {
+				return nil, rerr
+			}
+		}
+		res, err = a.doHTTP(t, req)
+	}
+
+	if res != nil && (res.StatusCode == 401 || (res.StatusCode == 403 && (actionAuth || t.Authenticated))) {
+		tracerx.Printf("xfer: authorization refused for %s of %q; retrying with fresh actions", a.direction, t.Oid)
+		if err == nil {
+			err = errors.Errorf("http: received status %d", res.StatusCode)
+		}
+		return res, errors.NewRetriableError(err)
+	}
+	return res, err
+}
+
 func advanceCallbackProgress(cb ProgressCallback, t *Transfer, numBytes int64) {
 	if cb != nil {
 		// Must split into max int sizes since read count is int
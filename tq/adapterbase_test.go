@@ -0,0 +1,59 @@
+package tq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoHTTPWithFreshAuthRetriesExpiredActions(t *testing.T) {
+	for _, test := range []struct {
+		status        int
+		authenticated bool
+		actionAuth    bool
+		retriable     bool
+	}{
+		{401, true, false, true},
+		// A presigned URL, or token given by the action, which has
+		// expired is refused with a 403.
+		{403, true, false, true},
+		{403, false, true, true},
+		// Otherwise, a 403 refuses permission, which fresh actions
+		// won't change.
+		{403, false, false, false},
+		{404, true, false, false},
+	} {
+		status := test.status
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(status)
+		}))
+
+		c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, nil))
+		require.Nil(t, err)
+
+		a := newAdapterBase(nil, BasicAdapterName, Download, nil)
+		a.apiClient = c
+
+		req, err := http.NewRequest("GET", srv.URL+"/storage/a", nil)
+		require.Nil(t, err)
+		if test.actionAuth {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+
+		res, err := a.doHTTPWithFreshAuth(&Transfer{Oid: "a", Authenticated: test.authenticated}, req, nil)
+		srv.Close()
+
+		require.NotNil(t, res, test)
+		assert.Equal(t, status, res.StatusCode)
+		assert.Equal(t, test.retriable, errors.IsRetriableError(err), test)
+		assert.Equal(t, 1, requests, test)
+	}
+}
@@ -1,6 +1,9 @@
 package tq
 
 import (
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/git-lfs/git-lfs/errors"
@@ -13,17 +16,66 @@ import (
 type tqClient struct {
 	MaxRetries int
 	*lfsapi.Client
+
+	// downgraded is the set of endpoint URLs whose servers rejected a
+	// batch request advertising transfer adapters or a ref, and were
+	// sent requests without them thereafter.
+	downgraded   map[string]bool
+	downgradedMu sync.Mutex
 }
 
 type batchRef struct {
 	Name string `json:"name,omitempty"`
 }
 
+func (r *batchRef) refName() string {
+	if r == nil {
+		return ""
+	}
+	return r.Name
+}
+
+// batchRefFor returns the ref to send in batch requests for "remoteRef": the
+// one given by lfs.batch.ref, if any, or else "remoteRef". If HEAD is detached,
+// "remoteRef" names no ref, and the one given by a CI system is sent instead,
+// if there is one. Nothing is sent if no ref is known.
+func (m *Manifest) batchRefFor(remoteRef *git.Ref) *batchRef {
+	if len(m.batchRef) > 0 {
+		return &batchRef{Name: m.batchRef}
+	}
+	if isDetachedRef(remoteRef) && len(m.ciRef) > 0 {
+		tracerx.Printf("api: HEAD is detached, sending ref %q from the environment", m.ciRef)
+		return &batchRef{Name: m.ciRef}
+	}
+	if name := remoteRef.Refspec(); len(name) > 0 {
+		return &batchRef{Name: name}
+	}
+	return nil
+}
+
+// isDetachedRef returns whether "r" is a detached HEAD, or an object ID, rather
+// than a named ref.
+func isDetachedRef(r *git.Ref) bool {
+	if r == nil || r.Type == git.RefTypeHEAD {
+		return true
+	}
+	return r.Type == git.RefTypeOther && !strings.HasPrefix(r.Name, "refs/")
+}
+
+// batchRequest is the body of a batch request. The "ref" property is left out
+// when there is no ref to send, rather than sent as null, as older clients did,
+// so that requests downgraded for servers which don't understand it don't have
+// it at all. The batch API has always allowed it to be missing.
+//
+// The "namespace" property is the lfs.object.prefix the objects are kept
+// under, and is never removed by a downgrade, since objects sent without it
+// would not be kept apart from those of other namespaces.
 type batchRequest struct {
 	Operation            string      `json:"operation"`
 	Objects              []*Transfer `json:"objects"`
 	TransferAdapterNames []string    `json:"transfers,omitempty"`
-	Ref                  *batchRef   `json:"ref"`
+	Ref                  *batchRef   `json:"ref,omitempty"`
+	Namespace            string      `json:"namespace,omitempty"`
 }
 
 type BatchResponse struct {
@@ -33,19 +85,115 @@ type BatchResponse struct {
 }
 
 func Batch(m *Manifest, dir Direction, remote string, remoteRef *git.Ref, objects []*Transfer) (*BatchResponse, error) {
+	return batchFor(m, dir, remote, "", remoteRef, objects)
+}
+
+// batchFor makes a batch request like Batch, but to the endpoint at "rawurl"
+// rather than the remote's own, unless "rawurl" is empty.
+func batchFor(m *Manifest, dir Direction, remote, rawurl string, remoteRef *git.Ref, objects []*Transfer) (*BatchResponse, error) {
 	if len(objects) == 0 {
 		return &BatchResponse{}, nil
 	}
 
-	return m.batchClient().Batch(remote, &batchRequest{
+	bReq := &batchRequest{
 		Operation:            dir.String(),
 		Objects:              objects,
 		TransferAdapterNames: m.GetAdapterNames(dir),
-		Ref:                  &batchRef{Name: remoteRef.Refspec()},
-	})
+		Ref:                  m.batchRefFor(remoteRef),
+		Namespace:            m.objectPrefix,
+	}
+
+	c := m.batchClient()
+	if len(rawurl) == 0 {
+		conn, err := m.sshTransfer(dir, remote)
+		if err != nil {
+			return nil, errors.Wrap(err, "batch request")
+		}
+		if conn != nil {
+			return m.sshBatch(conn, remote, bReq)
+		}
+		return c.Batch(remote, bReq)
+	}
+	return c.BatchTo(remote, c.Endpoints.NewEndpoint(bReq.Operation, rawurl), bReq)
+}
+
+// FindMissing asks the server, through a "download" batch request to the
+// endpoint of each object's route, whether it has each of "objects", and
+// returns those it doesn't, along with the reason. Only the name, OID and size
+// of each object are used. No more than m.BatchSize() objects are sent in each
+// request.
+func FindMissing(m *Manifest, remote string, remoteRef *git.Ref, objects []*Transfer) ([]*FailedTransfer, error) {
+	b := make(batch, 0, len(objects))
+	for _, o := range objects {
+		b = append(b, &objectTuple{Name: o.Name, Oid: o.Oid, Size: o.Size})
+	}
+
+	var missing []*FailedTransfer
+	for _, g := range m.routes.Group(b) {
+		for len(g.batch) > 0 {
+			n := m.BatchSize()
+			if n > len(g.batch) {
+				n = len(g.batch)
+			}
+
+			failed, err := findMissingBatch(m, remote, g.url, remoteRef, g.batch[:n])
+			if err != nil {
+				return nil, err
+			}
+			missing = append(missing, failed...)
+			g.batch = g.batch[n:]
+		}
+	}
+	return missing, nil
+}
+
+// findMissingBatch makes a single "download" batch request for "b" to
+// "rawurl" on behalf of FindMissing.
+func findMissingBatch(m *Manifest, remote, rawurl string, remoteRef *git.Ref, b batch) ([]*FailedTransfer, error) {
+	names := make(map[string]string, len(b))
+	for _, t := range b {
+		names[t.Oid] = t.Name
+	}
+
+	bRes, err := batchFor(m, Download, remote, rawurl, remoteRef, b.ToTransfers())
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []*FailedTransfer
+
+	for _, o := range bRes.Objects {
+		var err error
+		if o.Error != nil {
+			err = o.Error
+		} else if a, rerr := o.Rel(Download.String()); rerr != nil {
+			err = rerr
+		} else if a == nil {
+			err = errors.New("no download action returned")
+		}
+
+		if err != nil {
+			missing = append(missing, &FailedTransfer{Name: names[o.Oid], Oid: o.Oid, Err: err})
+		}
+		delete(names, o.Oid)
+	}
+
+	for _, t := range b {
+		if _, ok := names[t.Oid]; ok {
+			missing = append(missing, &FailedTransfer{Name: t.Name, Oid: t.Oid, Err: errors.New("not returned by the server")})
+			delete(names, t.Oid)
+		}
+	}
+	return missing, nil
 }
 
 func (c *tqClient) Batch(remote string, bReq *batchRequest) (*BatchResponse, error) {
+	return c.BatchTo(remote, c.Endpoints.Endpoint(bReq.Operation, remote), bReq)
+}
+
+// BatchTo makes the batch request "bReq" to the endpoint "e", authenticating
+// with the credentials of "remote".
+func (c *tqClient) BatchTo(remote string, e lfshttp.Endpoint, bReq *batchRequest) (*BatchResponse, error) {
 	bRes := &BatchResponse{}
 	if len(bReq.Objects) == 0 {
 		return bRes, nil
@@ -55,42 +203,134 @@ func (c *tqClient) Batch(remote string, bReq *batchRequest) (*BatchResponse, err
 		bReq.TransferAdapterNames = nil
 	}
 
+	if c.isDowngraded(e.Url) {
+		bReq = downgradeBatchRequest(bReq)
+	}
+
+	bRes, res, err := c.batch(remote, e, bReq)
+	for err != nil && isServerError(res) {
+		next, ok := c.Endpoints.Failover(e)
+		if !ok {
+			break
+		}
+		if next.Url != e.Url {
+			tracerx.Printf("api: batch endpoint is now %s", next.Url)
+		}
+
+		e = next
+		bRes, res, err = c.batch(remote, e, bReq)
+	}
+
+	if err != nil && isUnsupportedBatchRequest(res) && canDowngradeBatchRequest(bReq) {
+		tracerx.Printf("api: batch request rejected with HTTP %d, retrying without transfer adapters %v and ref %q", res.StatusCode, bReq.TransferAdapterNames, bReq.Ref.refName())
+
+		if bRes, _, err = c.batch(remote, e, downgradeBatchRequest(bReq)); err == nil {
+			c.setDowngraded(e.Url)
+		}
+	}
+	return bRes, err
+}
+
+// batch makes the batch request "bReq" to the endpoint "e", returning the
+// HTTP response along with any error, so that the caller can tell why the
+// request failed.
+func (c *tqClient) batch(remote string, e lfshttp.Endpoint, bReq *batchRequest) (*BatchResponse, *http.Response, error) {
+	bRes := &BatchResponse{}
+
 	missing := make(map[string]bool)
 	for _, obj := range bReq.Objects {
 		missing[obj.Oid] = obj.Missing
 	}
 
-	bRes.endpoint = c.Endpoints.Endpoint(bReq.Operation, remote)
+	bRes.endpoint = e
 	requestedAt := time.Now()
 
 	req, err := c.NewRequest("POST", bRes.endpoint, "objects/batch", bReq)
 	if err != nil {
-		return nil, errors.Wrap(err, "batch request")
+		return nil, nil, errors.Wrap(err, "batch request")
 	}
 
 	tracerx.Printf("api: batch %d files", len(bReq.Objects))
 
 	req = c.Client.LogRequest(req, "lfs.batch")
-	res, err := c.DoAPIRequestWithAuth(remote, lfshttp.WithRetries(req, c.MaxRetries))
+	res, err := c.DoWithAuth(remote, c.Endpoints.AccessFor(e.Url), lfshttp.WithRetries(req, c.MaxRetries))
 	if err != nil {
 		tracerx.Printf("api error: %s", err)
-		return nil, errors.Wrap(err, "batch response")
+		return nil, res, errors.Wrap(err, "batch response")
 	}
 
 	if err := lfshttp.DecodeJSON(res, bRes); err != nil {
-		return bRes, errors.Wrap(err, "batch response")
+		return bRes, res, errors.Wrap(err, "batch response")
 	}
 
 	if res.StatusCode != 200 {
-		return nil, lfshttp.NewStatusCodeError(res)
+		return nil, res, lfshttp.NewStatusCodeError(res)
 	}
 
+	// The hrefs of the actions are rewritten like the endpoint, so that
+	// objects are transferred through the same mirror as Git talks to.
+	rewrite := c.GitEnv().Bool(enableHrefRewriteKey, defaultEnableHrefRewrite)
 	for _, obj := range bRes.Objects {
 		obj.Missing = missing[obj.Oid]
 		for _, a := range obj.Actions {
 			a.createdAt = requestedAt
+			if rewrite {
+				a.Href = c.Endpoints.ReplaceUrlAlias(bReq.Operation, a.Href)
+			}
+		}
+		for _, a := range obj.Links {
+			if rewrite {
+				a.Href = c.Endpoints.ReplaceUrlAlias(bReq.Operation, a.Href)
+			}
 		}
 	}
 
-	return bRes, nil
+	return bRes, res, nil
+}
+
+// isUnsupportedBatchRequest returns whether "res" is a response from a server
+// which couldn't understand a batch request, which older servers may send
+// when given properties added to the batch API after they were written.
+func isUnsupportedBatchRequest(res *http.Response) bool {
+	return res != nil && (res.StatusCode == 400 || res.StatusCode == 422)
+}
+
+// isServerError returns whether "res" is a response from a server which failed
+// to handle a request, and which another endpoint may be able to handle.
+func isServerError(res *http.Response) bool {
+	return res != nil && res.StatusCode >= 500 && res.StatusCode < 600
+}
+
+// canDowngradeBatchRequest returns whether "bReq" uses any optional parts of
+// the batch API which downgradeBatchRequest would remove.
+func canDowngradeBatchRequest(bReq *batchRequest) bool {
+	return len(bReq.TransferAdapterNames) > 0 || len(bReq.Ref.refName()) > 0
+}
+
+// downgradeBatchRequest returns a copy of "bReq" with only the properties
+// understood by the earliest servers implementing the batch API, which
+// assume the basic transfer adapter, and its namespace, if any.
+func downgradeBatchRequest(bReq *batchRequest) *batchRequest {
+	return &batchRequest{
+		Operation: bReq.Operation,
+		Objects:   bReq.Objects,
+		Namespace: bReq.Namespace,
+	}
+}
+
+func (c *tqClient) isDowngraded(rawurl string) bool {
+	c.downgradedMu.Lock()
+	defer c.downgradedMu.Unlock()
+
+	return c.downgraded[rawurl]
+}
+
+func (c *tqClient) setDowngraded(rawurl string) {
+	c.downgradedMu.Lock()
+	defer c.downgradedMu.Unlock()
+
+	if c.downgraded == nil {
+		c.downgraded = make(map[string]bool)
+	}
+	c.downgraded[rawurl] = true
 }
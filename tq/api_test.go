@@ -3,6 +3,7 @@ package tq
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfsapi"
 	"github.com/git-lfs/git-lfs/lfshttp"
 	"github.com/stretchr/testify/assert"
@@ -28,7 +30,8 @@ func TestAPIBatch(t *testing.T) {
 		}
 
 		assert.Equal(t, "POST", r.Method)
-		assert.Equal(t, "91", r.Header.Get("Content-Length"))
+		// No "ref" is given, so the property is left out.
+		assert.Equal(t, "80", r.Header.Get("Content-Length"))
 
 		bodyLoader, body := gojsonschema.NewReaderLoader(r.Body)
 		bReq := &batchRequest{}
@@ -187,3 +190,414 @@ func assertSchema(t *testing.T, schema *sourcedSchema, dataLoader gojsonschema.J
 		t.Errorf("Schema: %s\n%s", schema.Source, strings.Join(valErrors, "\n"))
 	}
 }
+
+func TestAPIBatchRewritesActionHrefs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bReq := &batchRequest{}
+		err := json.NewDecoder(r.Body).Decode(bReq)
+		r.Body.Close()
+		assert.Nil(t, err)
+
+		for _, o := range bReq.Objects {
+			o.Actions = ActionSet{bReq.Operation: &Action{Href: "https://storage.example.com/" + o.Oid}}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&BatchResponse{Objects: bReq.Objects})
+	}))
+	defer srv.Close()
+
+	for desc, c := range map[string]struct {
+		Operation string
+		Config    map[string]string
+		Expected  string
+	}{
+		"download": {
+			"download", nil, "https://mirror.example.com/a",
+		},
+		"upload": {
+			"upload", nil, "https://push.example.com/a",
+		},
+		"disabled": {
+			"download", map[string]string{"lfs.transfer.enablehrefrewrite": "false"},
+			"https://storage.example.com/a",
+		},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			gitEnv := map[string]string{
+				"lfs.url": srv.URL + "/api",
+				"url.https://mirror.example.com/.insteadof":   "https://storage.example.com/",
+				"url.https://push.example.com/.pushinsteadof": "https://storage.example.com/",
+			}
+			for k, v := range c.Config {
+				gitEnv[k] = v
+			}
+			cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, gitEnv))
+			require.Nil(t, err)
+
+			tqc := &tqClient{Client: cli}
+			bRes, err := tqc.Batch("remote", &batchRequest{
+				Operation: c.Operation,
+				Objects:   []*Transfer{&Transfer{Oid: "a", Size: 1}},
+			})
+			require.Nil(t, err)
+			require.Len(t, bRes.Objects, 1)
+
+			a, err := bRes.Objects[0].Rel(c.Operation)
+			require.Nil(t, err)
+			assert.Equal(t, c.Expected, a.Href)
+		})
+	}
+}
+
+func TestAPIFindMissing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bReq := &batchRequest{}
+		err := json.NewDecoder(r.Body).Decode(bReq)
+		r.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, "download", bReq.Operation)
+
+		res := &BatchResponse{}
+		for _, o := range bReq.Objects {
+			switch o.Oid {
+			case "present":
+				o.Actions = ActionSet{"download": &Action{Href: "https://example.com/present"}}
+			case "missing":
+				o.Error = &ObjectError{Code: 404, Message: "Object does not exist"}
+			case "unreturned":
+				continue
+			}
+			res.Objects = append(res.Objects, o)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	m := NewManifest(nil, c, "", "")
+	missing, err := FindMissing(m, "remote", nil, []*Transfer{
+		{Name: "a.dat", Oid: "present", Size: 1},
+		{Name: "b.dat", Oid: "missing", Size: 1},
+		{Name: "c.dat", Oid: "noaction", Size: 1},
+		{Name: "d.dat", Oid: "unreturned", Size: 1},
+	})
+	require.Nil(t, err)
+	require.Len(t, missing, 3)
+
+	assert.Equal(t, "b.dat (missing): [404] Object does not exist", missing[0].Error())
+	assert.Equal(t, "c.dat (noaction): no download action returned", missing[1].Error())
+	assert.Equal(t, "d.dat (unreturned): not returned by the server", missing[2].Error())
+}
+
+func TestAPIFindMissingSplitsBatches(t *testing.T) {
+	var sizes []int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bReq := &batchRequest{}
+		err := json.NewDecoder(r.Body).Decode(bReq)
+		r.Body.Close()
+		assert.Nil(t, err)
+		sizes = append(sizes, len(bReq.Objects))
+
+		res := &BatchResponse{}
+		for _, o := range bReq.Objects {
+			o.Error = &ObjectError{Code: 404, Message: "Object does not exist"}
+			res.Objects = append(res.Objects, o)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url":                srv.URL + "/api",
+		"lfs.transfer.batchsize": "2",
+	}))
+	require.Nil(t, err)
+
+	m := NewManifest(nil, c, "", "")
+	missing, err := FindMissing(m, "remote", nil, []*Transfer{
+		{Name: "a.dat", Oid: "a", Size: 1},
+		{Name: "b.dat", Oid: "b", Size: 1},
+		{Name: "c.dat", Oid: "c", Size: 1},
+		{Name: "d.dat", Oid: "d", Size: 1},
+		{Name: "e.dat", Oid: "e", Size: 1},
+	})
+	require.Nil(t, err)
+	assert.Len(t, missing, 5)
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+}
+
+func TestAPIBatchSendsObjectPrefixAsNamespace(t *testing.T) {
+	for prefix, namespace := range map[string]interface{}{
+		"":                nil,
+		"/org/./tenant a": "org/tenant a",
+	} {
+		var raw map[string]interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/objects/batch", r.URL.Path)
+
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&raw))
+			r.Body.Close()
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&BatchResponse{TransferAdapterName: "basic"})
+		}))
+
+		gitConf := map[string]string{"lfs.url": srv.URL + "/api"}
+		if len(prefix) > 0 {
+			gitConf["lfs.object.prefix"] = prefix
+		}
+		c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, gitConf))
+		require.Nil(t, err)
+
+		m := NewManifest(nil, c, "", "")
+		_, err = Batch(m, Download, "remote", nil, []*Transfer{&Transfer{Oid: "a", Size: 1}})
+		srv.Close()
+		require.Nil(t, err, prefix)
+		assert.Equal(t, namespace, raw["namespace"], prefix)
+	}
+}
+
+func TestDowngradeBatchRequestKeepsNamespace(t *testing.T) {
+	bReq := downgradeBatchRequest(&batchRequest{
+		Operation:            "upload",
+		TransferAdapterNames: []string{"basic", "tus"},
+		Ref:                  &batchRef{Name: "refs/heads/main"},
+		Namespace:            "org/tenant",
+	})
+
+	assert.Empty(t, bReq.TransferAdapterNames)
+	assert.Nil(t, bReq.Ref)
+	assert.Equal(t, "org/tenant", bReq.Namespace)
+}
+
+func TestAPIBatchDowngradesForOlderServers(t *testing.T) {
+	var requests []*batchRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]interface{}
+		bReq := &batchRequest{}
+
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		require.Nil(t, err)
+		require.Nil(t, json.Unmarshal(body, &raw))
+		require.Nil(t, json.Unmarshal(body, bReq))
+		requests = append(requests, bReq)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		// Like an older server, reject any properties other than
+		// "operation" and "objects".
+		for key := range raw {
+			if key != "operation" && key != "objects" {
+				w.WriteHeader(422)
+				json.NewEncoder(w).Encode(map[string]string{
+					"message": fmt.Sprintf("unknown property %q", key),
+				})
+				return
+			}
+		}
+
+		json.NewEncoder(w).Encode(&BatchResponse{Objects: bReq.Objects})
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	tqc := &tqClient{Client: c}
+	newRequest := func() *batchRequest {
+		return &batchRequest{
+			Operation:            "download",
+			TransferAdapterNames: []string{"basic", "tus"},
+			Ref:                  &batchRef{Name: "refs/heads/main"},
+			Objects:              []*Transfer{{Oid: "a", Size: 1}},
+		}
+	}
+
+	bRes, err := tqc.Batch("remote", newRequest())
+	require.Nil(t, err)
+	require.Len(t, bRes.Objects, 1)
+	assert.Equal(t, "a", bRes.Objects[0].Oid)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, []string{"basic", "tus"}, requests[0].TransferAdapterNames)
+	assert.Nil(t, requests[1].TransferAdapterNames)
+	assert.Nil(t, requests[1].Ref)
+
+	// Later requests to the same endpoint are downgraded up front.
+	_, err = tqc.Batch("remote", newRequest())
+	require.Nil(t, err)
+	require.Len(t, requests, 3)
+	assert.Nil(t, requests[2].TransferAdapterNames)
+}
+
+func TestAPIBatchDoesNotDowngradeMinimalRequests(t *testing.T) {
+	var count int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]string{"message": "rejected"})
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	tqc := &tqClient{Client: c}
+	_, err = tqc.Batch("remote", &batchRequest{
+		Operation: "download",
+		Objects:   []*Transfer{{Oid: "a", Size: 1}},
+	})
+	require.NotNil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestAPIBatchFailsOverAfterServerErrors(t *testing.T) {
+	var primary, backup int
+
+	primarySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primary++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(502)
+		json.NewEncoder(w).Encode(map[string]string{"message": "bad gateway"})
+	}))
+	defer primarySrv.Close()
+
+	backupSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backup++
+		bReq := &batchRequest{}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(bReq))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&BatchResponse{Objects: bReq.Objects})
+	}))
+	defer backupSrv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url":          primarySrv.URL + "/api",
+		"lfs.url.failover": backupSrv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	tqc := &tqClient{Client: c}
+	bRes, err := tqc.Batch("remote", &batchRequest{
+		Operation: "download",
+		Objects:   []*Transfer{{Oid: "a", Size: 1}},
+	})
+	require.Nil(t, err)
+	require.Len(t, bRes.Objects, 1)
+	assert.Equal(t, backupSrv.URL+"/api", bRes.endpoint.Url)
+	assert.Equal(t, 3, primary)
+	assert.Equal(t, 1, backup)
+
+	// The rest of the operation uses the failover endpoint.
+	_, err = tqc.Batch("remote", &batchRequest{
+		Operation: "download",
+		Objects:   []*Transfer{{Oid: "b", Size: 1}},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 3, primary)
+	assert.Equal(t, 2, backup)
+}
+
+func TestAPIBatchDoesNotRetryServerErrorsWithoutFailover(t *testing.T) {
+	var count int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(502)
+		json.NewEncoder(w).Encode(map[string]string{"message": "bad gateway"})
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.url": srv.URL + "/api",
+	}))
+	require.Nil(t, err)
+
+	tqc := &tqClient{Client: c}
+	_, err = tqc.Batch("remote", &batchRequest{
+		Operation: "download",
+		Objects:   []*Transfer{{Oid: "a", Size: 1}},
+	})
+	require.NotNil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestAPIBatchRef(t *testing.T) {
+	var refName string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw struct {
+			Objects []*Transfer `json:"objects"`
+			Ref     *struct {
+				Name string `json:"name"`
+			} `json:"ref"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&raw))
+		r.Body.Close()
+
+		refName = "<none>"
+		if raw.Ref != nil {
+			refName = raw.Ref.Name
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&BatchResponse{Objects: raw.Objects})
+	}))
+	defer srv.Close()
+
+	branch := git.ParseRef("refs/heads/feature", "")
+	detached := git.ParseRef("HEAD", "")
+
+	for desc, c := range map[string]struct {
+		GitEnv   map[string]string
+		OSEnv    map[string]string
+		Ref      *git.Ref
+		Expected string
+	}{
+		"branch":               {nil, nil, branch, "refs/heads/feature"},
+		"detached":             {nil, nil, detached, "HEAD"},
+		"no ref":               {nil, nil, nil, "<none>"},
+		"configured branch":    {map[string]string{"lfs.batch.ref": "release"}, nil, branch, "refs/heads/release"},
+		"configured full ref":  {map[string]string{"lfs.batch.ref": "refs/tags/v1"}, nil, detached, "refs/tags/v1"},
+		"configured over CI":   {map[string]string{"lfs.batch.ref": "release"}, map[string]string{"GITHUB_REF": "refs/heads/ci"}, detached, "refs/heads/release"},
+		"detached with GitHub": {nil, map[string]string{"GITHUB_REF": "refs/pull/1/merge"}, detached, "refs/pull/1/merge"},
+		"detached with GitLab": {nil, map[string]string{"CI_COMMIT_BRANCH": "ci"}, detached, "refs/heads/ci"},
+		"detached with tag":    {nil, map[string]string{"CI_COMMIT_TAG": "v2"}, detached, "refs/tags/v2"},
+		"detached object ID":   {nil, map[string]string{"GITHUB_REF": "refs/heads/ci"}, &git.Ref{Name: "abc123", Type: git.RefTypeOther}, "refs/heads/ci"},
+		"branch ignores CI":    {nil, map[string]string{"GITHUB_REF": "refs/heads/ci"}, branch, "refs/heads/feature"},
+		"other full ref":       {nil, map[string]string{"GITHUB_REF": "refs/heads/ci"}, git.ParseRef("refs/pull/2/head", ""), "refs/pull/2/head"},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			gitEnv := map[string]string{"lfs.url": srv.URL + "/api"}
+			for k, v := range c.GitEnv {
+				gitEnv[k] = v
+			}
+
+			cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, c.OSEnv, gitEnv))
+			require.Nil(t, err)
+
+			m := NewManifest(nil, cli, "download", "origin")
+			_, err = Batch(m, Download, "origin", c.Ref, []*Transfer{{Oid: "a", Size: 1}})
+			require.Nil(t, err)
+			assert.Equal(t, c.Expected, refName)
+		})
+	}
+}
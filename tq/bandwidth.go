@@ -0,0 +1,305 @@
+package tq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/tools/humanize"
+	"github.com/rubyist/tracerx"
+)
+
+var (
+	// overallBuckets holds the token bucket of each overall bandwidth
+	// limit, by its rate, so that every transfer made by this process,
+	// whether an upload or a download, draws on the same one.
+	overallBuckets   = make(map[uint64]*tokenBucket)
+	overallBucketsMu sync.Mutex
+)
+
+// bandwidthLimiter limits the rate at which the objects of transfers are sent
+// and received, to that given by lfs.transfer.maxbandwidth for all of them at
+// once and by lfs.transfer.maxbandwidthperobject for each one, during the times
+// of day given by lfs.transfer.bandwidthschedule.
+type bandwidthLimiter struct {
+	// overall is shared by every transfer, or nil if there is no overall
+	// limit.
+	overall *tokenBucket
+	// perObject is the rate of each object, in bytes per second, or zero
+	// if there is no limit.
+	perObject uint64
+	// schedule holds the times of day at which the limits apply, or is
+	// empty if they always do.
+	schedule []bandwidthWindow
+
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	mu sync.Mutex
+	// objects holds the token bucket of each object being transferred, by
+	// its OID and path.
+	objects map[string]*objectBucket
+}
+
+// objectBucket is the token bucket of an object, and the number of transfers of
+// it which are under way.
+type objectBucket struct {
+	*tokenBucket
+	transfers int
+}
+
+// findBandwidthLimiter returns the bandwidthLimiter configured in "git", or
+// nil if no limit is set.
+func findBandwidthLimiter(git config.Environment) *bandwidthLimiter {
+	overall := findBandwidth(git, "lfs.transfer.maxbandwidth")
+	perObject := findBandwidth(git, "lfs.transfer.maxbandwidthperobject")
+	if overall == 0 && perObject == 0 {
+		return nil
+	}
+
+	l := &bandwidthLimiter{
+		perObject: perObject,
+		now:       time.Now,
+		sleep:     time.Sleep,
+		objects:   make(map[string]*objectBucket),
+	}
+	if overall > 0 {
+		overallBucketsMu.Lock()
+		if overallBuckets[overall] == nil {
+			overallBuckets[overall] = newTokenBucket(overall)
+		}
+		l.overall = overallBuckets[overall]
+		overallBucketsMu.Unlock()
+	}
+
+	if v, ok := git.Get("lfs.transfer.bandwidthschedule"); ok {
+		schedule, err := parseBandwidthSchedule(v)
+		if err != nil {
+			tracerx.Printf("tq: ignoring invalid lfs.transfer.bandwidthschedule %q, the limits always apply: %s", v, err)
+		}
+		l.schedule = schedule
+	}
+
+	tracerx.Printf("tq: limiting transfers to %s overall and %s per object",
+		formatBandwidth(overall), formatBandwidth(perObject))
+	return l
+}
+
+// formatBandwidth returns the rate "rate", in bytes per second, in human
+// readable form.
+func formatBandwidth(rate uint64) string {
+	if rate == 0 {
+		return "no limit"
+	}
+	return humanize.FormatByteRate(rate, time.Second)
+}
+
+// findBandwidth returns the rate in bytes per second given by "key", which may
+// use units such as "MB", or zero if it isn't given or is invalid.
+func findBandwidth(git config.Environment, key string) uint64 {
+	v, ok := git.Get(key)
+	if !ok {
+		return 0
+	}
+
+	rate, err := humanize.ParseBytes(strings.TrimSuffix(strings.TrimSpace(v), "/s"))
+	if err != nil {
+		tracerx.Printf("tq: ignoring invalid %s %q", key, v)
+		return 0
+	}
+	return rate
+}
+
+// callback returns a ProgressCallback for the transfer "t" which passes its
+// progress on to "cb", and then waits for as long as it takes the bytes
+// transferred since the last call to be within the limits, which holds up the
+// transfer, since adapters call it as they go. It also returns a func which
+// releases the object's own limit, and which must be called once the transfer
+// ends, whether or not it succeeded. If "l" is nil, "cb" is returned.
+func (l *bandwidthLimiter) callback(t *Transfer, cb ProgressCallback) (ProgressCallback, func()) {
+	if l == nil {
+		return cb, func() {}
+	}
+
+	bucket, release := l.objectBucket(t)
+	return func(name string, totalSize, readSoFar int64, readSinceLast int) error {
+		var err error
+		if cb != nil {
+			err = cb(name, totalSize, readSoFar, readSinceLast)
+		}
+		if readSinceLast > 0 {
+			l.wait(bucket, readSinceLast)
+		}
+		return err
+	}, release
+}
+
+// objectBucket returns the token bucket of the object of the transfer "t",
+// which is shared by the transfers of the same object to or from the same path
+// which are under way, or nil if there is no limit per object, and a func which
+// releases it.
+func (l *bandwidthLimiter) objectBucket(t *Transfer) (*tokenBucket, func()) {
+	if l.perObject == 0 {
+		return nil, func() {}
+	}
+
+	key := t.Oid + ":" + t.Path
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ob := l.objects[key]
+	if ob == nil {
+		ob = &objectBucket{tokenBucket: newTokenBucket(l.perObject)}
+		l.objects[key] = ob
+	}
+	ob.transfers++
+
+	var once sync.Once
+	return ob.tokenBucket, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			if ob.transfers--; ob.transfers == 0 {
+				delete(l.objects, key)
+			}
+		})
+	}
+}
+
+// wait waits until "n" more bytes of the object whose token bucket is "bucket",
+// if any, may be transferred.
+func (l *bandwidthLimiter) wait(bucket *tokenBucket, n int) {
+	now := l.now()
+	if !inBandwidthSchedule(l.schedule, now) {
+		return
+	}
+
+	var d time.Duration
+	if l.overall != nil {
+		d = l.overall.take(n, now)
+	}
+	if bucket != nil {
+		if od := bucket.take(n, now); od > d {
+			d = od
+		}
+	}
+
+	if d > 0 {
+		l.sleep(d)
+	}
+}
+
+// tokenBucket is a token bucket of bytes, filled at a given rate, which holds
+// at most a second's worth of them. Bytes may be taken from it before it holds
+// enough, in which case they are owed, and those taking them must wait for as
+// long as it takes to pay them back.
+type tokenBucket struct {
+	// interval is the time it takes to fill the bucket with one byte.
+	interval float64
+
+	mu sync.Mutex
+	// full is the time at which the bucket is full again, which is in the
+	// future if bytes are owed.
+	full time.Time
+}
+
+// newTokenBucket returns a full tokenBucket filled at "rate" bytes per second.
+func newTokenBucket(rate uint64) *tokenBucket {
+	return &tokenBucket{interval: float64(time.Second) / float64(rate)}
+}
+
+// take takes "n" bytes from the bucket at "now", and returns how long the
+// caller must wait before they may be sent.
+func (b *tokenBucket) take(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The bucket holds a second's worth of bytes when it is full, so
+	// transfers may burst that far ahead of the rate, but no further.
+	if empty := now.Add(-time.Second); b.full.Before(empty) {
+		b.full = empty
+	}
+	b.full = b.full.Add(time.Duration(float64(n) * b.interval))
+
+	if wait := b.full.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// bandwidthWindow is a time of day during which the bandwidth limits apply, in
+// minutes since midnight, local time. A window which ends before it starts
+// runs past midnight.
+type bandwidthWindow struct {
+	start, end int
+}
+
+// parseBandwidthSchedule parses a comma-separated list of times of day, each of
+// the form "HH:MM-HH:MM".
+func parseBandwidthSchedule(v string) ([]bandwidthWindow, error) {
+	var schedule []bandwidthWindow
+	for _, field := range strings.Split(v, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+
+		times := strings.Split(field, "-")
+		if len(times) != 2 {
+			return nil, fmt.Errorf("invalid time of day %q, must be of the form HH:MM-HH:MM", field)
+		}
+		start, err := parseTimeOfDay(times[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTimeOfDay(times[1])
+		if err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, bandwidthWindow{start: start, end: end})
+	}
+	return schedule, nil
+}
+
+// parseTimeOfDay returns the number of minutes since midnight of the time "v",
+// of the form "HH:MM".
+func parseTimeOfDay(v string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(v), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q, must be of the form HH:MM", v)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("invalid hour in time %q", v)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("invalid minute in time %q", v)
+	}
+	return hours*60 + minutes, nil
+}
+
+// inBandwidthSchedule returns whether the time "t" is in one of the windows of
+// "schedule", or true if it is empty.
+func inBandwidthSchedule(schedule []bandwidthWindow, t time.Time) bool {
+	if len(schedule) == 0 {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range schedule {
+		if w.start <= w.end {
+			if minute >= w.start && minute < w.end {
+				return true
+			}
+		} else if minute >= w.start || minute < w.end {
+			return true
+		}
+	}
+	return false
+}
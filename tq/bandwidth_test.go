@@ -0,0 +1,156 @@
+package tq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBandwidthManifest(t *testing.T, git map[string]string) *Manifest {
+	cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, git))
+	require.Nil(t, err)
+
+	return NewManifest(nil, cli, "", "")
+}
+
+func TestManifestWithoutBandwidthLimits(t *testing.T) {
+	m := newBandwidthManifest(t, nil)
+	assert.Nil(t, m.bandwidth)
+
+	m = newBandwidthManifest(t, map[string]string{
+		"lfs.transfer.maxbandwidth": "fast",
+	})
+	assert.Nil(t, m.bandwidth)
+}
+
+func TestManifestSharesOverallBandwidth(t *testing.T) {
+	git := map[string]string{
+		"lfs.transfer.maxbandwidth":          "10MB/s",
+		"lfs.transfer.maxbandwidthperobject": "1MB",
+	}
+	upload := newBandwidthManifest(t, git)
+	download := newBandwidthManifest(t, git)
+
+	require.NotNil(t, upload.bandwidth)
+	assert.EqualValues(t, 1000*1000, upload.bandwidth.perObject)
+	assert.True(t, upload.bandwidth.overall == download.bandwidth.overall)
+}
+
+func TestTokenBucketAllowsBurstOfOneSecond(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(100)
+
+	assert.Equal(t, time.Duration(0), b.take(100, now))
+	assert.Equal(t, 500*time.Millisecond, b.take(50, now))
+	assert.Equal(t, 400*time.Millisecond, b.take(0, now.Add(100*time.Millisecond)))
+
+	// Bytes not taken for a while don't build up beyond a second's worth.
+	later := now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.take(100, later))
+	assert.Equal(t, 10*time.Millisecond, b.take(1, later))
+}
+
+func newTestBandwidthLimiter(now time.Time, slept *[]time.Duration) *bandwidthLimiter {
+	return &bandwidthLimiter{
+		overall:   newTokenBucket(1000),
+		perObject: 100,
+		now:       func() time.Time { return now },
+		sleep:     func(d time.Duration) { *slept = append(*slept, d) },
+		objects:   make(map[string]*objectBucket),
+	}
+}
+
+func TestBandwidthLimiterWaitsForSlowestLimit(t *testing.T) {
+	var slept []time.Duration
+	l := newTestBandwidthLimiter(time.Now(), &slept)
+
+	var reported int
+	progress := func(name string, total, read int64, current int) error {
+		reported += current
+		return nil
+	}
+	a, releaseA := l.callback(&Transfer{Name: "a.dat", Oid: "a", Path: "a.dat"}, progress)
+	b, releaseB := l.callback(&Transfer{Name: "b.dat", Oid: "b", Path: "b.dat"}, progress)
+
+	a("a.dat", 300, 200, 200)
+	b("b.dat", 100, 100, 100)
+	a("a.dat", 300, 300, 100)
+	assert.Equal(t, 400, reported)
+
+	// a.dat is a second ahead of its own rate, then two seconds ahead.
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, slept)
+
+	// Progress which is taken back isn't limited.
+	a("a.dat", 300, 0, -300)
+	assert.Len(t, slept, 2)
+
+	releaseA()
+	releaseB()
+	assert.Empty(t, l.objects)
+}
+
+func TestBandwidthLimiterReleasesObjectsWhenTransfersEnd(t *testing.T) {
+	var slept []time.Duration
+	l := newTestBandwidthLimiter(time.Now(), &slept)
+
+	// A transfer which fails part way through releases its bucket, as
+	// does one of unknown size, which is never seen to finish.
+	failed, release := l.callback(&Transfer{Name: "a.dat", Oid: "a", Path: "a.dat"}, nil)
+	failed("a.dat", 300, 100, 100)
+	unknown, releaseUnknown := l.callback(&Transfer{Name: "c.dat", Oid: "c", Path: "c.dat"}, nil)
+	unknown("c.dat", -1, 100, 100)
+	assert.Len(t, l.objects, 2)
+
+	release()
+	release()
+	releaseUnknown()
+	assert.Empty(t, l.objects)
+
+	// Objects of the same name at different paths don't share a bucket,
+	// while transfers of the same object to the same path do, until the
+	// last of them ends.
+	x, releaseX := l.callback(&Transfer{Name: "a.dat", Oid: "x", Path: "x/a.dat"}, nil)
+	y, releaseY := l.callback(&Transfer{Name: "a.dat", Oid: "y", Path: "y/a.dat"}, nil)
+	_, releaseY2 := l.callback(&Transfer{Name: "a.dat", Oid: "y", Path: "y/a.dat"}, nil)
+	assert.Len(t, l.objects, 2)
+
+	slept = nil
+	x("a.dat", 100, 100, 100)
+	y("a.dat", 100, 100, 100)
+	assert.Empty(t, slept)
+
+	releaseX()
+	releaseY()
+	assert.Len(t, l.objects, 1)
+	releaseY2()
+	assert.Empty(t, l.objects)
+}
+
+func TestBandwidthLimiterFollowsSchedule(t *testing.T) {
+	schedule, err := parseBandwidthSchedule("09:00-12:00, 22:30-06:00")
+	require.Nil(t, err)
+	assert.Equal(t, []bandwidthWindow{{540, 720}, {1350, 360}}, schedule)
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	assert.True(t, inBandwidthSchedule(schedule, at(9, 0)))
+	assert.True(t, inBandwidthSchedule(schedule, at(11, 59)))
+	assert.False(t, inBandwidthSchedule(schedule, at(12, 0)))
+	assert.True(t, inBandwidthSchedule(schedule, at(23, 0)))
+	assert.True(t, inBandwidthSchedule(schedule, at(5, 0)))
+	assert.False(t, inBandwidthSchedule(schedule, at(6, 30)))
+	assert.True(t, inBandwidthSchedule(nil, at(6, 30)))
+}
+
+func TestParseBandwidthScheduleRejectsInvalidTimes(t *testing.T) {
+	for _, v := range []string{"9-17", "09:00", "25:00-26:00", "09:60-10:00", "09:00-10:00-11:00"} {
+		_, err := parseBandwidthSchedule(v)
+		assert.NotNil(t, err, v)
+	}
+}
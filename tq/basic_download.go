@@ -9,8 +9,11 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/rubyist/tracerx"
 )
@@ -18,6 +21,27 @@ import (
 // Adapter for basic HTTP downloads, includes resuming via HTTP Range
 type basicDownloadAdapter struct {
 	*adapterBase
+
+	// verifyDigests indicates whether downloaded content should also be
+	// checked against any digest headers sent by the server.
+	verifyDigests bool
+
+	// disableResume indicates whether partially downloaded objects are
+	// discarded, rather than kept to be resumed, and maxResumes the number
+	// of times the download of a single object may be resumed before it
+	// starts again from the beginning.
+	disableResume bool
+	maxResumes    int
+
+	// finalStatus, if given, returns whether an error status in response to
+	// a download is final, so that the download isn't retried, as for the
+	// statuses of an S3 bucket which retrying won't change.
+	finalStatus func(status int) bool
+
+	// resumes counts the times the download of each object has been
+	// resumed, by OID.
+	resumes   map[string]int
+	resumesMu sync.Mutex
 }
 
 func (a *basicDownloadAdapter) ClearTempStorage() error {
@@ -45,7 +69,7 @@ func (a *basicDownloadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb Progr
 	// Reserve a temporary filename. We need to make sure nobody operates on the file simultaneously with us.
 	f, err := tools.TempFile(a.tempDir(), t.Oid, a.fs)
 	if err != nil {
-		return err
+		return fs.NewStorageError(a.tempDir(), err)
 	}
 	tmpName := f.Name()
 	defer func() {
@@ -66,7 +90,11 @@ func (a *basicDownloadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb Progr
 	}
 
 	// Attempt to resume download. No error checking here. If we fail, we'll simply download from the start
-	tools.RobustRename(a.downloadFilename(t), f.Name())
+	if a.disableResume {
+		os.Remove(a.downloadFilename(t))
+	} else {
+		tools.RobustRename(a.downloadFilename(t), f.Name())
+	}
 
 	// Open temp file. It is either empty or partially downloaded
 	f, err = os.OpenFile(f.Name(), os.O_RDWR, 0644)
@@ -83,10 +111,17 @@ func (a *basicDownloadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb Progr
 
 	// Ensure that partial file seems valid
 	if fromByte > 0 {
-		if fromByte < t.Size-1 {
+		// If we somehow have more data than expected, or have resumed
+		// this download too many times already, retry from the beginning.
+		resume := fromByte < t.Size-1
+		if resume && !a.allowResume(t) {
+			tracerx.Printf("xfer: not resuming download of %q after %d attempts; re-downloading from start", t.Oid, a.maxResumes)
+			resume = false
+		}
+
+		if resume {
 			tracerx.Printf("xfer: Attempting to resume download of %q from byte %d", t.Oid, fromByte)
 		} else {
-			// Somehow we have more data than expected. Let's retry from the beginning.
 			if _, err := f.Seek(0, io.SeekStart); err != nil {
 				return err
 			}
@@ -104,12 +139,34 @@ func (a *basicDownloadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb Progr
 		f.Close()
 		// Rename file so next download can resume from where we stopped.
 		// No error checking here, if rename fails then file will be deleted and there just will be no download resuming
-		tools.RobustRename(f.Name(), a.downloadFilename(t))
+		if fi, serr := os.Stat(f.Name()); !a.disableResume && serr == nil && fi.Size() > 0 {
+			tools.RobustRename(f.Name(), a.downloadFilename(t))
+		}
 	}
 
 	return err
 }
 
+// allowResume returns whether the download of "t" may be resumed once more,
+// counting this attempt, according to lfs.transfer.maxresumes.
+func (a *basicDownloadAdapter) allowResume(t *Transfer) bool {
+	a.resumesMu.Lock()
+	defer a.resumesMu.Unlock()
+
+	if a.resumes == nil {
+		a.resumes = make(map[string]int)
+	}
+	a.resumes[t.Oid]++
+	return a.resumes[t.Oid] <= a.maxResumes
+}
+
+// discardPartial empties the partially downloaded file of "t", so that it is
+// not kept to be resumed from.
+func discardPartial(t *Transfer, dlFile *os.File, reason string) {
+	tracerx.Printf("xfer: discarding partial download of %q: %s", t.Oid, reason)
+	dlFile.Truncate(0)
+}
+
 // Returns path where partially downloaded file should be stored for download resuming
 func (a *basicDownloadAdapter) downloadFilename(t *Transfer) string {
 	return filepath.Join(a.tempDir(), t.Oid+".part")
@@ -144,6 +201,15 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb ProgressCallback, authOk
 			return errors.NewRetriableError(err)
 		}
 
+		if a.finalStatus != nil && a.finalStatus(res.StatusCode) {
+			discardResponse(res)
+			return errors.Errorf("Invalid status for %s %s: %d",
+				req.Method,
+				strings.SplitN(req.URL.String(), "?", 2)[0],
+				res.StatusCode,
+			)
+		}
+
 		// Special-case status code 416 () - fall back
 		if fromByte > 0 && dlFile != nil && res.StatusCode == 416 {
 			tracerx.Printf("xfer: server rejected resume download request for %q from byte %d; re-downloading from start", t.Oid, fromByte)
@@ -197,7 +263,7 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb ProgressCallback, authOk
 		}
 		if rangeRequestOk {
 			tracerx.Printf("xfer: server accepted resume download request: %q from byte %d", t.Oid, fromByte)
-			advanceCallbackProgress(cb, t, fromByte)
+			advanceCallbackProgress(a.cb, t, fromByte)
 		} else {
 			// Abort resume, perform regular download
 			tracerx.Printf("xfer: failed to resume download for %q from byte %d: %s. Re-downloading from start", t.Oid, fromByte, failReason)
@@ -227,6 +293,13 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb ProgressCallback, authOk
 		authOkFunc()
 	}
 
+	t.ContentType = res.Header.Get("Content-Type")
+
+	// What has been written may only be resumed from if the server
+	// supports Range requests, as it does if it has answered one, or if
+	// it advertises them.
+	resumable := res.StatusCode == 206 || strings.EqualFold(res.Header.Get("Accept-Ranges"), "bytes")
+
 	var hasher *tools.HashingReader
 	httpReader := tools.NewRetriableReader(res.Body)
 
@@ -237,6 +310,18 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb ProgressCallback, authOk
 		hasher = tools.NewHashingReader(httpReader)
 	}
 
+	var reader io.Reader = hasher
+	var verifier *digestVerifier
+	if a.verifyDigests {
+		if fromByte > 0 {
+			// Digest headers on a partial response need not describe
+			// the whole object, so leave it to the OID check alone.
+			tracerx.Printf("xfer: skipping digest verification of resumed download for %q", t.Oid)
+		} else if verifier = newDigestVerifier(res.Header); verifier != nil {
+			reader = io.TeeReader(hasher, verifier)
+		}
+	}
+
 	dlfilename := dlFile.Name()
 	// Wrap callback to give name context
 	ccb := func(totalSize int64, readSoFar int64, readSinceLast int) error {
@@ -245,15 +330,34 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb ProgressCallback, authOk
 		}
 		return nil
 	}
-	written, err := tools.CopyWithCallback(dlFile, hasher, res.ContentLength, ccb)
+	written, err := tools.CopyWithCallback(dlFile, reader, res.ContentLength, ccb)
 	if err != nil {
+		if !resumable {
+			discardPartial(t, dlFile, "server does not accept Range requests")
+		}
+		if err = fs.NewStorageError(dlfilename, err); fs.IsStorageError(err) {
+			return err
+		}
 		return errors.Wrapf(err, "cannot write data to tempfile %q", dlfilename)
 	}
 
 	if actual := hasher.Hash(); actual != t.Oid {
+		if !resumable {
+			discardPartial(t, dlFile, "server does not accept Range requests")
+		} else if fromByte+written >= t.Size {
+			// All of the object has been written, so its content
+			// is wrong, rather than cut short.
+			discardPartial(t, dlFile, "content does not match OID")
+		}
 		return fmt.Errorf("expected OID %s, got %s after %d bytes written", t.Oid, actual, written)
 	}
 
+	if verifier != nil {
+		if err := verifier.Verify(); err != nil {
+			return errors.Wrapf(err, "digest mismatch for %s", t.Oid)
+		}
+	}
+
 	if err := dlFile.Close(); err != nil {
 		return fmt.Errorf("can't close tempfile %q: %v", dlfilename, err)
 	}
@@ -263,14 +367,19 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb ProgressCallback, authOk
 		// Target file already exists, possibly was downloaded by other git-lfs process
 		return nil
 	}
-	return err
+	return fs.NewStorageError(t.Path, err)
 }
 
 func configureBasicDownloadAdapter(m *Manifest) {
 	m.RegisterNewAdapterFunc(BasicAdapterName, Download, func(name string, dir Direction) Adapter {
 		switch dir {
 		case Download:
-			bd := &basicDownloadAdapter{newAdapterBase(m.fs, name, dir, nil)}
+			bd := &basicDownloadAdapter{
+				adapterBase:   newAdapterBase(m.fs, name, dir, nil),
+				verifyDigests: m.verifyDigests,
+				disableResume: m.disableResume,
+				maxResumes:    m.maxResumes,
+			}
 			// self implements impl
 			bd.transferImpl = bd
 			return bd
@@ -282,10 +391,5 @@ func configureBasicDownloadAdapter(m *Manifest) {
 }
 
 func (a *basicDownloadAdapter) makeRequest(t *Transfer, req *http.Request) (*http.Response, error) {
-	res, err := a.doHTTP(t, req)
-	if errors.IsAuthError(err) && len(req.Header.Get("Authorization")) == 0 {
-		return a.makeRequest(t, req)
-	}
-
-	return res, err
+	return a.doHTTPWithFreshAuth(t, req, nil)
 }
@@ -229,19 +229,26 @@ func configureBasicUploadAdapter(m *Manifest) {
 }
 
 func (a *basicUploadAdapter) makeRequest(t *Transfer, req *http.Request) (*http.Response, error) {
-	res, err := a.doHTTP(t, req)
-	if errors.IsAuthError(err) && len(req.Header.Get("Authorization")) == 0 {
-		// Construct a new body with just the raw file and no callbacks. Since
-		// all progress tracking happens when the net.http code copies our
-		// request body into a new request, we can safely make this request
-		// outside of the flow of the transfer adapter, and if it fails, the
-		// transfer progress will be rewound at the top level
-		f, _ := os.OpenFile(t.Path, os.O_RDONLY, 0644)
-		defer f.Close()
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	return a.doHTTPWithFreshAuth(t, req, func() error {
+		// Construct a new body with just the raw file and no callbacks.
+		// Since all progress tracking happens when the net.http code
+		// copies our request body into a new request, we can safely
+		// make this request outside of the flow of the transfer
+		// adapter, and if it fails, the transfer progress will be
+		// rewound at the top level
+		var err error
+		if f, err = os.OpenFile(t.Path, os.O_RDONLY, 0644); err != nil {
+			return errors.Wrap(err, "basic upload")
+		}
 
 		req.Body = tools.NewBodyWithCallback(f, t.Size, nil)
-		return a.makeRequest(t, req)
-	}
-
-	return res, err
+		return nil
+	})
 }
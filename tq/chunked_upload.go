@@ -0,0 +1,356 @@
+package tq
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/git-lfs/git-lfs/tools"
+)
+
+const (
+	ChunkedAdapterName = "chunked"
+
+	defaultChunkSize    = 64 * 1024 * 1024
+	defaultChunkWorkers = 4
+)
+
+// Adapter for uploads split into parts, which are sent concurrently, and which
+// the server keeps until the upload is completed, so that an interrupted
+// upload resumes with the parts it doesn't already have. See
+// docs/api/chunked-transfers.md.
+type chunkedUploadAdapter struct {
+	*adapterBase
+
+	// chunkSize is the size of every part but the last, and chunkWorkers
+	// the number of the parts of a single object which are sent at once.
+	chunkSize    int64
+	chunkWorkers int
+}
+
+// chunkedPart is a part of an object, as listed by the server.
+type chunkedPart struct {
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+func (a *chunkedUploadAdapter) ClearTempStorage() error {
+	// nothing to do, all temp state is on the server end
+	return nil
+}
+
+func (a *chunkedUploadAdapter) WorkerStarting(workerNum int) (interface{}, error) {
+	return nil, nil
+}
+func (a *chunkedUploadAdapter) WorkerEnding(workerNum int, ctx interface{}) {
+}
+
+func (a *chunkedUploadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCallback, authOkFunc func()) error {
+	rel, err := t.Rel("upload")
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		return errors.Errorf("No upload action for object: %s", t.Oid)
+	}
+
+	// 1. Ask the server which parts it already has, so that an upload
+	//    which was interrupted sends only the rest.
+	received, err := a.receivedParts(t, rel)
+	if err != nil {
+		return err
+	}
+
+	// Signal auth was ok; this frees up other workers to start
+	if authOkFunc != nil {
+		authOkFunc()
+	}
+
+	var parts []chunkedPart
+	var have int
+	var skipped int64
+	for _, p := range a.parts(t.Size) {
+		if received[p] {
+			have++
+			skipped += p.Size
+			continue
+		}
+		parts = append(parts, p)
+	}
+
+	if len(parts) == 0 {
+		a.Trace("xfer: chunked upload of %q has all of its parts already, completing", t.Oid)
+	} else if skipped > 0 {
+		a.Trace("xfer: chunked upload of %q resuming with %d of %d parts already uploaded",
+			t.Oid, have, have+len(parts))
+	} else {
+		a.Trace("xfer: chunked upload of %q in %d parts", t.Oid, len(parts))
+	}
+
+	// The parts which weren't sent are reported to a.cb, which isn't
+	// limited by lfs.transfer.maxbandwidth.
+	progress := &chunkedProgress{t: t}
+	ccb := progress.add
+	if skipped > 0 {
+		advanceCallbackProgress(func(name string, total, read int64, current int) error {
+			ccb(a.cb, current)
+			return nil
+		}, t, skipped)
+	}
+
+	// 2. Send the missing parts, chunkWorkers at a time
+	f, err := os.OpenFile(t.Path, os.O_RDONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "chunked upload")
+	}
+	defer f.Close()
+
+	errs := make([]error, len(parts))
+	bodies := make([]*tools.BodyWithCallback, len(parts))
+	sem := make(chan struct{}, a.chunkWorkers)
+	var wg sync.WaitGroup
+	for i, p := range parts {
+		bodies[i] = tools.NewBodyWithCallback(&partBody{io.NewSectionReader(f, p.Offset, p.Size)}, p.Size,
+			func(totalSize int64, readSoFar int64, readSinceLast int) error {
+				ccb(cb, readSinceLast)
+				return nil
+			})
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, p chunkedPart) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = a.uploadPart(t, rel, p, bodies[i])
+		}(i, p)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		// The whole object is retried, skipping the parts the server
+		// now has, so the progress of every part sent by this attempt
+		// is taken back, since those parts are counted again when
+		// the retry finds them.
+		for _, body := range bodies {
+			body.ResetProgress()
+		}
+		if skipped > 0 {
+			ccb(a.cb, -int(skipped))
+		}
+		return errors.Wrapf(err, "chunked upload of part %d", parts[i].Offset/a.chunkSize+1)
+	}
+
+	// 3. Ask the server to put the parts together
+	if err := a.complete(t, rel); err != nil {
+		return err
+	}
+
+	return verifyUpload(a.apiClient, a.remote, t)
+}
+
+// chunkedProgress is the running total of the bytes of an object sent, or
+// already held by the server, over all of its parts, which is reported to the
+// progress callback whichever order the parts are sent in.
+type chunkedProgress struct {
+	t    *Transfer
+	sent int64
+	mu   sync.Mutex
+}
+
+// add adds "readSinceLast" bytes to the total, and reports the new total to
+// "cb", if given. The callback is called without the lock held, since it
+// sleeps under lfs.transfer.maxbandwidth, which would otherwise keep the
+// other parts from being sent meanwhile.
+func (p *chunkedProgress) add(cb ProgressCallback, readSinceLast int) {
+	p.mu.Lock()
+	p.sent += int64(readSinceLast)
+	sent := p.sent
+	p.mu.Unlock()
+
+	if cb != nil {
+		cb(p.t.Name, p.t.Size, sent, readSinceLast)
+	}
+}
+
+// parts returns the parts an object of the given size is split into. An empty
+// object has none, and is only completed.
+func (a *chunkedUploadAdapter) parts(size int64) []chunkedPart {
+	parts := make([]chunkedPart, 0, size/a.chunkSize+1)
+	for offset := int64(0); offset < size; offset += a.chunkSize {
+		p := chunkedPart{Offset: offset, Size: a.chunkSize}
+		if offset+p.Size > size {
+			p.Size = size - offset
+		}
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+// receivedParts returns the parts of "t" the server already has. Parts which
+// don't match those the object is split into, as when lfs.transfer.chunksize
+// has changed since the upload began, are sent again.
+func (a *chunkedUploadAdapter) receivedParts(t *Transfer, rel *Action) (map[chunkedPart]bool, error) {
+	a.Trace("xfer: sending chunked upload GET request for %q", t.Oid)
+	req, err := a.newHTTPRequest("GET", rel)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+
+	res, err := a.doHTTP(t, req)
+	if err != nil {
+		discardResponse(res)
+		return nil, errors.NewRetriableError(err)
+	}
+	if res.StatusCode == 403 {
+		discardResponse(res)
+		return nil, errors.NewRetriableError(errors.New("http: received status 403"))
+	}
+	if res.StatusCode > 299 {
+		discardResponse(res)
+		return nil, errors.Wrapf(nil, "Invalid status for %s %s: %d",
+			req.Method,
+			strings.SplitN(req.URL.String(), "?", 2)[0],
+			res.StatusCode,
+		)
+	}
+
+	var listing struct {
+		Parts []chunkedPart `json:"parts"`
+	}
+	if err := lfshttp.DecodeJSON(res, &listing); err != nil {
+		return nil, fmt.Errorf("invalid list of parts from chunked upload GET at %q, contact server admin: %s", rel.Href, err)
+	}
+
+	received := make(map[chunkedPart]bool, len(listing.Parts))
+	for _, p := range listing.Parts {
+		received[p] = true
+	}
+	return received, nil
+}
+
+// uploadPart sends the part "p" of "t", whose contents are read from "body".
+func (a *chunkedUploadAdapter) uploadPart(t *Transfer, rel *Action, p chunkedPart, body *tools.BodyWithCallback) error {
+	req, err := a.newHTTPRequest("PUT", rel)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", p.Offset, p.Offset+p.Size-1, t.Size))
+	req.Header.Del("Transfer-Encoding")
+	req.ContentLength = p.Size
+	req.Body = body
+
+	req = a.apiClient.LogRequest(req, "lfs.data.upload")
+	res, err := a.doHTTP(t, req)
+	return a.checkResponse(req, res, err)
+}
+
+// complete asks the server to put the parts of "t" together, once all of them
+// have been sent.
+func (a *chunkedUploadAdapter) complete(t *Transfer, rel *Action) error {
+	a.Trace("xfer: sending chunked upload POST request for %q", t.Oid)
+	req, err := a.newHTTPRequest("POST", rel)
+	if err != nil {
+		return err
+	}
+
+	err = lfshttp.MarshalToRequest(req, struct {
+		Oid  string `json:"oid"`
+		Size int64  `json:"size"`
+	}{Oid: t.Oid, Size: t.Size})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+
+	res, err := a.doHTTP(t, req)
+	return a.checkResponse(req, res, err)
+}
+
+// checkResponse returns the error for the response to an upload or completion
+// request, which is retriable unless the server gave a status which can't
+// be expected to change.
+func (a *chunkedUploadAdapter) checkResponse(req *http.Request, res *http.Response, err error) error {
+	// The body is never needed, so is dropped, which lets the connection
+	// be reused.
+	defer discardResponse(res)
+
+	if err != nil {
+		if res != nil && res.StatusCode == 429 {
+			if retLaterErr := errors.NewRetriableLaterError(err, res.Header.Get("Retry-After")); retLaterErr != nil {
+				return retLaterErr
+			}
+		}
+		return errors.NewRetriableError(err)
+	}
+
+	// A status code of 403 likely means that an authentication token for the
+	// upload has expired. This can be safely retried.
+	if res.StatusCode == 403 {
+		err = errors.New("http: received status 403")
+		return errors.NewRetriableError(err)
+	}
+
+	if res.StatusCode > 299 {
+		return errors.Wrapf(nil, "Invalid status for %s %s: %d",
+			req.Method,
+			strings.SplitN(req.URL.String(), "?", 2)[0],
+			res.StatusCode,
+		)
+	}
+	return nil
+}
+
+// discardResponse reads the rest of the body of "res", if there is one, and
+// closes it.
+func discardResponse(res *http.Response) {
+	if res == nil || res.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
+
+// partBody is the body of a request sending a single part, which is read
+// from the object's file, shared by all of its parts, without closing it.
+type partBody struct {
+	*io.SectionReader
+}
+
+func (b *partBody) Close() error {
+	return nil
+}
+
+func configureChunkedAdapter(m *Manifest) {
+	m.RegisterNewAdapterFunc(ChunkedAdapterName, Upload, func(name string, dir Direction) Adapter {
+		switch dir {
+		case Upload:
+			cu := &chunkedUploadAdapter{
+				adapterBase:  newAdapterBase(m.fs, name, dir, nil),
+				chunkSize:    m.chunkSize,
+				chunkWorkers: m.chunkWorkers,
+			}
+			// self implements impl
+			cu.transferImpl = cu
+			return cu
+		case Download:
+			panic("Should never ask the chunked adapter to download")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package tq
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkedUploadParts(t *testing.T) {
+	a := &chunkedUploadAdapter{chunkSize: 10}
+
+	assert.Empty(t, a.parts(0))
+	assert.Equal(t, []chunkedPart{{0, 5}}, a.parts(5))
+	assert.Equal(t, []chunkedPart{{0, 10}}, a.parts(10))
+	assert.Equal(t, []chunkedPart{{0, 10}, {10, 10}, {20, 1}}, a.parts(21))
+}
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestChunkedUploadCheckResponseClosesBody(t *testing.T) {
+	a := &chunkedUploadAdapter{}
+	req, err := http.NewRequest("PUT", "https://example.com/upload?token=a", nil)
+	require.Nil(t, err)
+
+	for status, ok := range map[int]bool{
+		200: true,
+		403: false,
+		500: false,
+	} {
+		body := &closeRecorder{Reader: strings.NewReader("body")}
+		res := &http.Response{StatusCode: status, Body: body}
+
+		err := a.checkResponse(req, res, nil)
+		assert.Equal(t, ok, err == nil, status)
+		assert.True(t, body.closed, status)
+		assert.Equal(t, 0, body.Len(), status)
+	}
+}
+
+func TestChunkedProgressCallsBackConcurrently(t *testing.T) {
+	p := &chunkedProgress{t: &Transfer{Name: "a.dat", Size: 20}}
+
+	// Each callback waits for the other, as a callback limited by
+	// lfs.transfer.maxbandwidth sleeps, so they only both return if they
+	// are called at once.
+	var entered sync.WaitGroup
+	entered.Add(2)
+	both := make(chan struct{})
+	go func() {
+		entered.Wait()
+		close(both)
+	}()
+
+	var timedOut bool
+	var mu sync.Mutex
+	cb := func(name string, total, read int64, current int) error {
+		entered.Done()
+		select {
+		case <-both:
+		case <-time.After(5 * time.Second):
+			mu.Lock()
+			timedOut = true
+			mu.Unlock()
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.add(cb, 10)
+		}()
+	}
+	wg.Wait()
+
+	assert.False(t, timedOut)
+	assert.EqualValues(t, 20, p.sent)
+}
@@ -318,6 +318,9 @@ func (a *customAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCall
 				}
 				// Move file to final location
 				if err = tools.RenameFileCopyPermissions(resp.Path, t.Path); err != nil {
+					if err = fs.NewStorageError(t.Path, err); fs.IsStorageError(err) {
+						return err
+					}
 					return fmt.Errorf("failed to copy downloaded file: %v", err)
 				}
 			} else if a.direction == Upload {
@@ -350,6 +353,7 @@ func newCustomAdapter(f *fs.Filesystem, name string, dir Direction, path, args s
 
 const (
 	standaloneFileName = "lfs-standalone-file"
+	standaloneSFTPName = "lfs-standalone-sftp"
 )
 
 func configureDefaultCustomAdapters(git Env, m *Manifest) {
@@ -359,6 +363,13 @@ func configureDefaultCustomAdapters(git Env, m *Manifest) {
 	}
 	m.RegisterNewAdapterFunc(standaloneFileName, Download, newfunc)
 	m.RegisterNewAdapterFunc(standaloneFileName, Upload, newfunc)
+
+	newSFTPFunc := func(name string, dir Direction) Adapter {
+		standalone := m.standaloneTransferAgent != ""
+		return newCustomAdapter(m.fs, standaloneSFTPName, dir, "git-lfs", "standalone-sftp", false, standalone)
+	}
+	m.RegisterNewAdapterFunc(standaloneSFTPName, Download, newSFTPFunc)
+	m.RegisterNewAdapterFunc(standaloneSFTPName, Upload, newSFTPFunc)
 }
 
 // Initialise custom adapters based on current config
@@ -0,0 +1,486 @@
+package tq
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/lfshttp"
+	"github.com/git-lfs/git-lfs/tools"
+	"github.com/git-lfs/git-lfs/tools/delta"
+)
+
+const (
+	DeltaAdapterName = "delta"
+
+	// deltaBasesHeader lists the OIDs of the objects the client offers as
+	// bases for a download, and deltaBaseHeader gives the one a delta
+	// was made from.
+	deltaBasesHeader = "LFS-Delta-Bases"
+	deltaBaseHeader  = "LFS-Delta-Base"
+)
+
+// DeltaBasesFunc returns the OIDs of the objects present locally which are
+// likely to share most of their contents with the object "oid" of the file at
+// "name", such as those of earlier versions of the file, best first.
+type DeltaBasesFunc func(name, oid string) []string
+
+// deltaBases is the state shared by the delta adapters.
+type deltaBases struct {
+	find DeltaBasesFunc
+
+	// whole holds the objects which are transferred whole when they are
+	// next retried, because a delta of them couldn't be sent or applied.
+	// Each is removed once its retry starts, so that it holds no more than
+	// the pending retries.
+	whole map[string]bool
+	mu    sync.Mutex
+}
+
+// offer returns the bases to offer for "t", which are none if it is to be
+// transferred whole.
+func (b *deltaBases) offer(t *Transfer) []string {
+	if b.find == nil || len(t.Name) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	whole := b.whole[t.Oid]
+	delete(b.whole, t.Oid)
+	b.mu.Unlock()
+	if whole {
+		return nil
+	}
+
+	var bases []string
+	for _, oid := range b.find(t.Name, t.Oid) {
+		if oid != t.Oid {
+			bases = append(bases, oid)
+		}
+	}
+	return bases
+}
+
+// sendWhole makes "t" be transferred whole when it's retried, for "reason".
+func (b *deltaBases) sendWhole(a *adapterBase, t *Transfer, reason error) error {
+	a.Trace("xfer: delta %s of %q failed, retrying it whole: %s", a.direction, t.Oid, reason)
+
+	b.mu.Lock()
+	b.whole[t.Oid] = true
+	b.mu.Unlock()
+	return errors.NewRetriableError(reason)
+}
+
+// offered returns whether "oid" is one of "bases".
+func offered(bases []string, oid string) bool {
+	for _, base := range bases {
+		if base == oid {
+			return true
+		}
+	}
+	return false
+}
+
+// openBase opens the local object "oid", and returns its size.
+func openBase(f *fs.Filesystem, oid string) (*os.File, int64, error) {
+	bf, err := os.Open(f.ObjectReadPathname(oid, -1))
+	if err != nil {
+		return nil, 0, err
+	}
+	stat, err := bf.Stat()
+	if err != nil {
+		bf.Close()
+		return nil, 0, err
+	}
+	return bf, stat.Size(), nil
+}
+
+// Adapter for downloads which offer the server the objects of earlier
+// versions of the file present locally, so that it can send a delta from one
+// of them in place of the whole object. See docs/api/delta-transfers.md.
+type deltaDownloadAdapter struct {
+	*basicDownloadAdapter
+	bases *deltaBases
+}
+
+func (a *deltaDownloadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCallback, authOkFunc func()) error {
+	bases := a.bases.offer(t)
+	if len(bases) == 0 {
+		return a.basicDownloadAdapter.DoTransfer(ctx, t, cb, authOkFunc)
+	}
+
+	rel, err := t.Rel("download")
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		return errors.Errorf("Object %s not found on the server.", t.Oid)
+	}
+
+	req, err := a.newHTTPRequest("GET", rel)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", delta.MediaType+", "+defaultContentType)
+	req.Header.Set(deltaBasesHeader, strings.Join(bases, ", "))
+
+	req = a.apiClient.LogRequest(req, "lfs.data.download")
+	res, err := a.makeRequest(t, req)
+	if err != nil {
+		if res == nil {
+			// We encountered a network or similar error which caused us
+			// to not receive a response at all.
+			return errors.NewRetriableError(err)
+		}
+		if res.StatusCode == 429 {
+			if retLaterErr := errors.NewRetriableLaterError(err, res.Header.Get("Retry-After")); retLaterErr != nil {
+				return retLaterErr
+			}
+		}
+		return errors.NewRetriableError(err)
+	}
+	defer res.Body.Close()
+
+	// Signal auth OK on success response, before starting download to free up
+	// other workers immediately
+	if authOkFunc != nil {
+		authOkFunc()
+	}
+
+	dlFile, err := tools.TempFile(a.tempDir(), t.Oid, a.fs)
+	if err != nil {
+		return err
+	}
+	dlfilename := dlFile.Name()
+
+	hasher := tools.NewLfsContentHash()
+	w := &deltaProgressWriter{w: io.MultiWriter(dlFile, hasher), t: t, cb: cb}
+
+	var done bool
+	defer func() {
+		dlFile.Close()
+		os.Remove(dlfilename)
+		if !done {
+			// The download is retried from the start, so the
+			// progress reported is taken back.
+			w.reset()
+		}
+	}()
+
+	isDelta := strings.HasPrefix(res.Header.Get("Content-Type"), delta.MediaType)
+	if isDelta {
+		base := res.Header.Get(deltaBaseHeader)
+		if !offered(bases, base) {
+			return errors.Errorf("delta download of %s from %q, which wasn't offered", t.Oid, base)
+		}
+
+		bf, size, err := openBase(a.fs, base)
+		if err != nil {
+			return a.bases.sendWhole(a.adapterBase, t, err)
+		}
+		defer bf.Close()
+
+		a.Trace("xfer: delta download of %q from %q: %d byte(s) for %d", t.Oid, base, res.ContentLength, t.Size)
+		if _, err := delta.Apply(bf, size, tools.NewRetriableReader(res.Body), w); err != nil {
+			if errors.IsRetriableError(err) {
+				return err
+			}
+			return a.bases.sendWhole(a.adapterBase, t, err)
+		}
+	} else {
+		a.Trace("xfer: server sent all of %q, rather than a delta", t.Oid)
+		if _, err := io.Copy(w, tools.NewRetriableReader(res.Body)); err != nil {
+			return err
+		}
+	}
+
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != t.Oid {
+		err := fmt.Errorf("expected OID %s, got %s after %d bytes written", t.Oid, actual, w.written)
+		if isDelta {
+			// The local base may be corrupt, so rather than trusting
+			// it again, the object is downloaded whole.
+			return a.bases.sendWhole(a.adapterBase, t, err)
+		}
+		return err
+	}
+
+	if err := dlFile.Close(); err != nil {
+		return fmt.Errorf("can't close tempfile %q: %v", dlfilename, err)
+	}
+
+	done = true
+	err = tools.RenameFileCopyPermissions(dlfilename, t.Path)
+	if _, err2 := os.Stat(t.Path); err2 == nil {
+		// Target file already exists, possibly was downloaded by other git-lfs process
+		return nil
+	}
+	return fs.NewStorageError(t.Path, err)
+}
+
+// deltaProgressWriter reports the progress of a download as the contents of the
+// object are written, whether they are made from a delta or not.
+type deltaProgressWriter struct {
+	w       io.Writer
+	t       *Transfer
+	cb      ProgressCallback
+	written int64
+}
+
+func (w *deltaProgressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	if n > 0 && w.cb != nil {
+		w.cb(w.t.Name, w.t.Size, w.written, n)
+	}
+	return n, err
+}
+
+// reset takes back the progress reported, since the object is retried.
+func (w *deltaProgressWriter) reset() {
+	if w.cb != nil && w.written > 0 {
+		w.cb(w.t.Name, w.t.Size, 0, -int(w.written))
+	}
+	w.written = 0
+}
+
+// Adapter for uploads which ask the server which of the objects of earlier
+// versions of the file it has, and send a delta from one of them in place of
+// the whole object. See docs/api/delta-transfers.md.
+type deltaUploadAdapter struct {
+	*basicUploadAdapter
+	bases *deltaBases
+}
+
+func (a *deltaUploadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCallback, authOkFunc func()) error {
+	bases := a.bases.offer(t)
+	if len(bases) == 0 {
+		return a.basicUploadAdapter.DoTransfer(ctx, t, cb, authOkFunc)
+	}
+
+	rel, err := t.Rel("upload")
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		return errors.Errorf("No upload action for object: %s", t.Oid)
+	}
+
+	// 1. Ask the server which of the bases it has
+	base, err := a.chooseBase(t, rel, bases)
+	if err != nil {
+		return err
+	}
+	if len(base) == 0 {
+		a.Trace("xfer: server has none of the %d base(s) offered for %q, uploading it whole", len(bases), t.Oid)
+		return a.basicUploadAdapter.DoTransfer(ctx, t, cb, authOkFunc)
+	}
+
+	// 2. Make the delta from it
+	df, size, err := a.diff(t, base)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		df.Close()
+		os.Remove(df.Name())
+	}()
+	if size >= t.Size {
+		a.Trace("xfer: delta of %q from %q is no smaller than it, uploading it whole", t.Oid, base)
+		return a.basicUploadAdapter.DoTransfer(ctx, t, cb, authOkFunc)
+	}
+	a.Trace("xfer: delta upload of %q from %q: %d byte(s) for %d", t.Oid, base, size, t.Size)
+
+	// 3. Send it, reporting progress through the object in proportion to
+	//    the delta sent
+	req, err := a.newHTTPRequest("PUT", rel)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", delta.MediaType)
+	req.Header.Set(deltaBaseHeader, base)
+	req.Header.Del("Transfer-Encoding")
+	req.ContentLength = size
+
+	var reported int64
+	ccb := func(totalSize int64, readSoFar int64, readSinceLast int) error {
+		progress := t.Size
+		if readSoFar < size {
+			progress = readSoFar * t.Size / size
+		}
+		n := progress - reported
+		reported = progress
+		if cb != nil {
+			return cb(t.Name, t.Size, progress, int(n))
+		}
+		return nil
+	}
+
+	cbr := tools.NewFileBodyWithCallback(df, size, ccb)
+	var reader lfsapi.ReadSeekCloser = cbr
+	// Signal auth was ok on first read; this frees up other workers to start
+	if authOkFunc != nil {
+		reader = newStartCallbackReader(reader, func() error {
+			authOkFunc()
+			return nil
+		})
+	}
+	req.Body = reader
+
+	req = a.apiClient.LogRequest(req, "lfs.data.upload")
+	res, err := a.doHTTPWithFreshAuth(t, req, func() error {
+		if _, err := df.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		req.Body = tools.NewBodyWithCallback(tools.NewFileBody(df), size, nil)
+		return nil
+	})
+	if err != nil {
+		if perr := cbr.ResetProgress(); perr != nil {
+			err = errors.Wrap(err, perr.Error())
+		}
+
+		if res == nil {
+			// We encountered a network or similar error which caused us
+			// to not receive a response at all.
+			return errors.NewRetriableError(err)
+		}
+
+		// The server no longer has the base, or can't apply the delta.
+		if res.StatusCode == 409 || res.StatusCode == 422 {
+			return a.bases.sendWhole(a.adapterBase, t, err)
+		}
+
+		if res.StatusCode == 429 {
+			if retLaterErr := errors.NewRetriableLaterError(err, res.Header.Get("Retry-After")); retLaterErr != nil {
+				return retLaterErr
+			}
+		}
+		return errors.NewRetriableError(err)
+	}
+
+	if res.StatusCode > 299 {
+		return errors.Wrapf(nil, "Invalid status for %s %s: %d",
+			req.Method,
+			strings.SplitN(req.URL.String(), "?", 2)[0],
+			res.StatusCode,
+		)
+	}
+
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	return verifyUpload(a.apiClient, a.remote, t)
+}
+
+// chooseBase returns the one of "bases" the server has chosen to take a delta
+// of "t" from, or an empty string if it has none of them, or doesn't take
+// deltas of it.
+func (a *deltaUploadAdapter) chooseBase(t *Transfer, rel *Action, bases []string) (string, error) {
+	a.Trace("xfer: sending delta upload POST request for %q with %d base(s)", t.Oid, len(bases))
+	req, err := a.newHTTPRequest("POST", rel)
+	if err != nil {
+		return "", err
+	}
+
+	err = lfshttp.MarshalToRequest(req, struct {
+		Oid   string   `json:"oid"`
+		Size  int64    `json:"size"`
+		Bases []string `json:"bases"`
+	}{Oid: t.Oid, Size: t.Size, Bases: bases})
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+
+	res, err := a.doHTTP(t, req)
+	if err != nil {
+		if res != nil && res.StatusCode < 500 && res.StatusCode != 429 {
+			// Storage which doesn't take deltas of the object
+			// takes it whole.
+			return "", nil
+		}
+		return "", errors.NewRetriableError(err)
+	}
+	if res.StatusCode > 299 {
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		return "", nil
+	}
+
+	var chosen struct {
+		Base string `json:"base"`
+	}
+	if err := lfshttp.DecodeJSON(res, &chosen); err != nil {
+		return "", fmt.Errorf("invalid response to delta upload POST at %q, contact server admin: %s", rel.Href, err)
+	}
+	if len(chosen.Base) > 0 && !offered(bases, chosen.Base) {
+		return "", errors.Errorf("server chose delta base %q for %s, which wasn't offered", chosen.Base, t.Oid)
+	}
+	return chosen.Base, nil
+}
+
+// diff writes the delta of "t" from "base" to a temporary file, and returns the
+// file, rewound, and its size.
+func (a *deltaUploadAdapter) diff(t *Transfer, base string) (*os.File, int64, error) {
+	bf, baseSize, err := openBase(a.fs, base)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "delta upload")
+	}
+	defer bf.Close()
+
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "delta upload")
+	}
+	defer f.Close()
+
+	df, err := tools.TempFile(a.tempDir(), t.Oid, a.fs)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := delta.Diff(bf, baseSize, f, df); err != nil {
+		df.Close()
+		os.Remove(df.Name())
+		return nil, 0, errors.Wrap(err, "delta upload")
+	}
+
+	size, err := df.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = df.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		df.Close()
+		os.Remove(df.Name())
+		return nil, 0, err
+	}
+	return df, size, nil
+}
+
+func configureDeltaAdapter(m *Manifest) {
+	m.RegisterNewAdapterFunc(DeltaAdapterName, Download, func(name string, dir Direction) Adapter {
+		bd := &basicDownloadAdapter{
+			adapterBase:   newAdapterBase(m.fs, name, dir, nil),
+			verifyDigests: m.verifyDigests,
+			disableResume: m.disableResume,
+			maxResumes:    m.maxResumes,
+		}
+		dd := &deltaDownloadAdapter{basicDownloadAdapter: bd, bases: m.newDeltaBases()}
+		// self implements impl
+		bd.transferImpl = dd
+		return dd
+	})
+	m.RegisterNewAdapterFunc(DeltaAdapterName, Upload, func(name string, dir Direction) Adapter {
+		bu := &basicUploadAdapter{newAdapterBase(m.fs, name, dir, nil)}
+		du := &deltaUploadAdapter{basicUploadAdapter: bu, bases: m.newDeltaBases()}
+		// self implements impl
+		bu.transferImpl = du
+		return du
+	})
+}
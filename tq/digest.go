@@ -0,0 +1,106 @@
+package tq
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/git-lfs/git-lfs/errors"
+)
+
+// digestAlgorithms maps the lowercase names of the digest algorithms which
+// may be given in a "Digest" header (see RFC 3230) to functions constructing a
+// hash for that algorithm. Algorithms not listed here are ignored.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// digestVerifier verifies the body of an HTTP response against the digests
+// given by the server in the "Content-MD5" and "Digest" response headers.
+type digestVerifier struct {
+	expected map[string][]byte
+	hashes   map[string]hash.Hash
+	w        io.Writer
+}
+
+// newDigestVerifier returns a digestVerifier for the digests in the given
+// response header, or nil if the header contains no digests of a supported
+// algorithm.
+func newDigestVerifier(h http.Header) *digestVerifier {
+	expected := make(map[string][]byte)
+
+	for _, v := range h[http.CanonicalHeaderKey("Digest")] {
+		for _, entry := range strings.Split(v, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			alg := strings.ToLower(parts[0])
+			if _, ok := digestAlgorithms[alg]; !ok {
+				continue
+			}
+			if sum, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+				expected[alg] = sum
+			}
+		}
+	}
+
+	if v := h.Get("Content-MD5"); len(v) > 0 {
+		if sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v)); err == nil {
+			expected["md5"] = sum
+		}
+	}
+
+	if len(expected) == 0 {
+		return nil
+	}
+
+	hashes := make(map[string]hash.Hash, len(expected))
+	writers := make([]io.Writer, 0, len(expected))
+	for alg := range expected {
+		hashes[alg] = digestAlgorithms[alg]()
+		writers = append(writers, hashes[alg])
+	}
+
+	return &digestVerifier{
+		expected: expected,
+		hashes:   hashes,
+		w:        io.MultiWriter(writers...),
+	}
+}
+
+// Write implements io.Writer by adding p to each of the running digests.
+func (v *digestVerifier) Write(p []byte) (int, error) {
+	return v.w.Write(p)
+}
+
+// Verify returns an error if any of the digests written so far does not match
+// the value given by the server.
+func (v *digestVerifier) Verify() error {
+	algs := make([]string, 0, len(v.expected))
+	for alg := range v.expected {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+
+	for _, alg := range algs {
+		expected := v.expected[alg]
+		if actual := v.hashes[alg].Sum(nil); !bytes.Equal(actual, expected) {
+			return errors.Errorf("expected %s digest %s, got %s",
+				alg,
+				base64.StdEncoding.EncodeToString(expected),
+				base64.StdEncoding.EncodeToString(actual))
+		}
+	}
+	return nil
+}
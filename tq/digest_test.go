@@ -0,0 +1,71 @@
+package tq
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodedMD5(s string) string {
+	sum := md5.Sum([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func encodedSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func verifyDigests(h http.Header, body string) error {
+	v := newDigestVerifier(h)
+	if v == nil {
+		return nil
+	}
+	io.Copy(v, strings.NewReader(body))
+	return v.Verify()
+}
+
+func TestDigestVerifierNoHeaders(t *testing.T) {
+	assert.Nil(t, newDigestVerifier(http.Header{}))
+}
+
+func TestDigestVerifierIgnoresUnknownAlgorithms(t *testing.T) {
+	h := http.Header{}
+	h.Set("Digest", "crc32c=AAAAAA==, unixsum=30637")
+
+	assert.Nil(t, newDigestVerifier(h))
+}
+
+func TestDigestVerifierContentMD5(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-MD5", encodedMD5("hello"))
+
+	require.NotNil(t, newDigestVerifier(h))
+	assert.Nil(t, verifyDigests(h, "hello"))
+	assert.EqualError(t, verifyDigests(h, "world"),
+		"expected md5 digest "+encodedMD5("hello")+", got "+encodedMD5("world"))
+}
+
+func TestDigestVerifierDigestHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Digest", "MD5="+encodedMD5("hello")+",SHA-256="+encodedSHA256("hello"))
+
+	assert.Nil(t, verifyDigests(h, "hello"))
+	assert.NotNil(t, verifyDigests(h, "world"))
+}
+
+func TestDigestVerifierChecksEveryDigest(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-MD5", encodedMD5("hello"))
+	h.Set("Digest", "sha-256="+encodedSHA256("world"))
+
+	assert.EqualError(t, verifyDigests(h, "hello"),
+		"expected sha-256 digest "+encodedSHA256("world")+", got "+encodedSHA256("hello"))
+}
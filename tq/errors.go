@@ -27,3 +27,18 @@ func (e MalformedObjectError) Error() string {
 	}
 	return fmt.Sprintf("missing object: %s (%s)", e.Name, e.Oid)
 }
+
+// FailedTransfer records the error which caused the transfer of a single
+// object to fail.
+type FailedTransfer struct {
+	Name string
+	Oid  string
+	Err  error
+}
+
+func (e *FailedTransfer) Error() string {
+	if len(e.Name) == 0 {
+		return fmt.Sprintf("%s: %v", e.Oid, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Name, e.Oid, e.Err)
+}
@@ -1,6 +1,7 @@
 package tq
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,3 +22,11 @@ func TestCorruptObjectErrorsAreRecognizable(t *testing.T) {
 	assert.Equal(t, "some-oid", err.Oid)
 	assert.True(t, err.Corrupt())
 }
+
+func TestFailedTransferErrorIncludesObject(t *testing.T) {
+	err := &FailedTransfer{Name: "some-name", Oid: "some-oid", Err: errors.New("some error")}
+	assert.Equal(t, "some-name (some-oid): some error", err.Error())
+
+	err = &FailedTransfer{Oid: "some-oid", Err: errors.New("some error")}
+	assert.Equal(t, "some-oid: some error", err.Error())
+}
@@ -1,12 +1,16 @@
 package tq
 
 import (
+	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/git-lfs/git-lfs/config"
 	"github.com/git-lfs/git-lfs/fs"
 	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/tools/humanize"
 	"github.com/rubyist/tracerx"
 )
 
@@ -14,6 +18,20 @@ const (
 	defaultMaxRetries          = 8
 	defaultMaxRetryDelay       = 10
 	defaultConcurrentTransfers = 8
+	defaultMaxResumes          = 5
+)
+
+// Values of "lfs.transfer.order", which determines the order in which the
+// objects in each batch are handed to the transfer adapter.
+const (
+	// orderDefault sorts each batch by descending size before making the
+	// batch request, and starts transfers in the order the server returns
+	// them.
+	orderDefault = "default"
+	// orderLargestFirst starts the largest objects in each batch first.
+	orderLargestFirst = "largest-first"
+	// orderSmallestFirst starts the smallest objects in each batch first.
+	orderSmallestFirst = "smallest-first"
 )
 
 type Manifest struct {
@@ -23,21 +41,58 @@ type Manifest struct {
 	maxRetries              int
 	maxRetryDelay           int
 	concurrentTransfers     int
+	batchSize               int
 	basicTransfersOnly      bool
 	standaloneTransferAgent string
 	tusTransfersAllowed     bool
+	chunkSize               int64
+	chunkWorkers            int
+	s3                      *s3Config
+	deltaBases              DeltaBasesFunc
+	verifyDigests           bool
+	disableResume           bool
+	maxResumes              int
+	transferOrder           string
+	bandwidth               *bandwidthLimiter
+	routes                  endpointRoutes
+	batchRef                string
+	ciRef                   string
+	objectPrefix            string
 	downloadAdapterFuncs    map[string]NewAdapterFunc
 	uploadAdapterFuncs      map[string]NewAdapterFunc
 	fs                      *fs.Filesystem
 	apiClient               *lfsapi.Client
 	tqClient                *tqClient
+	metrics                 *Metrics
 	mu                      sync.Mutex
+	// sshOnce registers the SSH transfer adapter once a connection to
+	// git-lfs-transfer(1) has been made.
+	sshOnce sync.Once
 }
 
 func (m *Manifest) APIClient() *lfsapi.Client {
 	return m.apiClient
 }
 
+// SetMetrics makes the transfer queues created with this manifest record their
+// transfers in "metrics".
+func (m *Manifest) SetMetrics(metrics *Metrics) {
+	m.metrics = metrics
+}
+
+// SetDeltaBases makes the delta transfer adapter offer the objects found by
+// "fn" as the bases of deltas, if the adapter is enabled with
+// lfs.deltatransfers. It must be called before any transfer queue is created
+// with this manifest.
+func (m *Manifest) SetDeltaBases(fn DeltaBasesFunc) {
+	m.deltaBases = fn
+}
+
+// newDeltaBases returns the state of a new delta adapter.
+func (m *Manifest) newDeltaBases() *deltaBases {
+	return &deltaBases{find: m.deltaBases, whole: make(map[string]bool)}
+}
+
 func (m *Manifest) MaxRetries() int {
 	return m.maxRetries
 }
@@ -50,6 +105,12 @@ func (m *Manifest) ConcurrentTransfers() int {
 	return m.concurrentTransfers
 }
 
+// BatchSize returns the maximum number of objects sent in each batch request
+// by the transfer queues created with this manifest.
+func (m *Manifest) BatchSize() int {
+	return m.batchSize
+}
+
 func (m *Manifest) IsStandaloneTransfer() bool {
 	return m.standaloneTransferAgent != ""
 }
@@ -77,9 +138,10 @@ func NewManifest(f *fs.Filesystem, apiClient *lfsapi.Client, operation, remote s
 		tqClient:             &tqClient{Client: apiClient},
 		downloadAdapterFuncs: make(map[string]NewAdapterFunc),
 		uploadAdapterFuncs:   make(map[string]NewAdapterFunc),
+		maxResumes:           defaultMaxResumes,
 	}
 
-	var tusAllowed bool
+	var tusAllowed, chunkedAllowed, deltaAllowed bool
 	if git := apiClient.GitEnv(); git != nil {
 		if v := git.Int("lfs.transfer.maxretries", 0); v > 0 {
 			m.maxRetries = v
@@ -90,13 +152,34 @@ func NewManifest(f *fs.Filesystem, apiClient *lfsapi.Client, operation, remote s
 		if v := git.Int("lfs.concurrenttransfers", 0); v > 0 {
 			m.concurrentTransfers = v
 		}
+		m.batchSize = findBatchSize(git)
 		m.basicTransfersOnly = git.Bool("lfs.basictransfersonly", false)
 		m.standaloneTransferAgent = findStandaloneTransfer(
 			apiClient, operation, remote,
 		)
 		tusAllowed = git.Bool("lfs.tustransfers", false)
+		chunkedAllowed = git.Bool("lfs.chunkedtransfers", false)
+		deltaAllowed = git.Bool("lfs.deltatransfers", false)
+		m.chunkSize = findChunkSize(git)
+		m.chunkWorkers = git.Int("lfs.transfer.chunkworkers", 0)
+		m.verifyDigests = git.Bool("lfs.transfer.verifydigests", false)
+		m.disableResume = !git.Bool("lfs.transfer.enableresume", true)
+		if v := git.Int("lfs.transfer.maxresumes", -1); v > -1 {
+			m.maxResumes = v
+		}
+		m.transferOrder = findTransferOrder(git)
+		m.bandwidth = findBandwidthLimiter(git)
+		m.routes = findEndpointRoutes(git)
+		m.s3 = findS3Config(git, apiClient.OSEnv())
+		m.batchRef = findBatchRef(git)
+		if v, ok := git.Get("lfs.object.prefix"); ok {
+			m.objectPrefix = fs.CleanObjectPrefix(v)
+		}
 		configureCustomAdapters(git, m)
 	}
+	if osEnv := apiClient.OSEnv(); osEnv != nil {
+		m.ciRef = findCIRef(osEnv)
+	}
 
 	if m.maxRetries < 1 {
 		m.maxRetries = defaultMaxRetries
@@ -109,24 +192,153 @@ func NewManifest(f *fs.Filesystem, apiClient *lfsapi.Client, operation, remote s
 		m.concurrentTransfers = defaultConcurrentTransfers
 	}
 
+	if m.batchSize < 1 {
+		m.batchSize = defaultBatchSize
+	}
+
+	if m.chunkSize < 1 {
+		m.chunkSize = defaultChunkSize
+	}
+	if m.chunkWorkers < 1 {
+		m.chunkWorkers = defaultChunkWorkers
+	}
+
+	if len(m.transferOrder) == 0 {
+		m.transferOrder = orderDefault
+	}
+
 	configureBasicDownloadAdapter(m)
 	configureBasicUploadAdapter(m)
 	if tusAllowed {
 		configureTusAdapter(m)
 	}
+	if chunkedAllowed {
+		configureChunkedAdapter(m)
+	}
+	if deltaAllowed {
+		configureDeltaAdapter(m)
+	}
+	if m.s3 != nil {
+		configureS3Adapter(m)
+	}
 	return m
 }
 
+// findBatchRef returns the ref given by lfs.batch.ref, as a full ref name, or
+// the empty string if it isn't given. Names which don't start with "refs/"
+// are taken to be branches.
+func findBatchRef(git config.Environment) string {
+	v, _ := git.Get("lfs.batch.ref")
+	v = strings.TrimSpace(v)
+	if len(v) == 0 || strings.HasPrefix(v, "refs/") {
+		return v
+	}
+	return "refs/heads/" + v
+}
+
+// ciRefVariables are the environment variables in which CI systems give the
+// ref being built, along with the prefix making their values full ref names.
+var ciRefVariables = []struct {
+	name, prefix string
+}{
+	{"GITHUB_REF", ""},
+	{"BUILD_SOURCEBRANCH", ""},
+	{"CI_COMMIT_TAG", "refs/tags/"},
+	{"CI_COMMIT_BRANCH", "refs/heads/"},
+}
+
+// findCIRef returns the full name of the ref being built by a CI system, as
+// given in its environment, or the empty string if there is none.
+func findCIRef(osEnv config.Environment) string {
+	for _, v := range ciRefVariables {
+		if value, _ := osEnv.Get(v.name); len(value) > 0 {
+			if len(v.prefix) > 0 && !strings.HasPrefix(value, "refs/") {
+				value = v.prefix + value
+			}
+			return value
+		}
+	}
+	return ""
+}
+
+// warnBatchSizeOnce ensures that an invalid lfs.transfer.batchsize is warned
+// about once, however many manifests are made.
+var warnBatchSizeOnce sync.Once
+
+// findBatchSize returns the batch size given by lfs.transfer.batchsize, or the
+// default if it isn't given or isn't a positive integer, in which case a
+// warning is written to stderr.
+func findBatchSize(git config.Environment) int {
+	v, ok := git.Get("lfs.transfer.batchsize")
+	if !ok {
+		return defaultBatchSize
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 1 {
+		warnBatchSizeOnce.Do(func() {
+			fmt.Fprintf(os.Stderr, "warning: ignoring invalid lfs.transfer.batchsize %q, must be a positive integer; using %d\n", v, defaultBatchSize)
+		})
+		return defaultBatchSize
+	}
+	return n
+}
+
+// findChunkSize returns the size of the parts objects are split into by the
+// chunked upload adapter, as given by lfs.transfer.chunksize, which may use
+// units such as "MB".
+func findChunkSize(git config.Environment) int64 {
+	v, ok := git.Get("lfs.transfer.chunksize")
+	if !ok {
+		return defaultChunkSize
+	}
+
+	size, err := humanize.ParseBytes(v)
+	if err != nil || size == 0 {
+		tracerx.Printf("tq: ignoring invalid lfs.transfer.chunksize %q", v)
+		return defaultChunkSize
+	}
+	return int64(size)
+}
+
+func findTransferOrder(git config.Environment) string {
+	v, ok := git.Get("lfs.transfer.order")
+	if !ok {
+		return orderDefault
+	}
+
+	switch order := strings.ToLower(v); order {
+	case orderDefault, orderLargestFirst, orderSmallestFirst:
+		return order
+	default:
+		tracerx.Printf("tq: ignoring unknown lfs.transfer.order %q", v)
+		return orderDefault
+	}
+}
+
 func findDefaultStandaloneTransfer(url string) string {
 	if strings.HasPrefix(url, "file://") {
 		return standaloneFileName
 	}
+	if strings.HasPrefix(url, "sftp://") {
+		return standaloneSFTPName
+	}
 	return ""
 }
 
+// hasS3Bucket returns whether objects are transferred to and from an S3
+// bucket given by lfs.storage.s3.bucket, rather than through the server.
+func hasS3Bucket(git config.Environment) bool {
+	v, _ := git.Get("lfs.storage.s3.bucket")
+	return len(v) > 0
+}
+
 func findStandaloneTransfer(client *lfsapi.Client, operation, remote string) string {
 	if operation == "" || remote == "" {
-		v, _ := client.GitEnv().Get("lfs.standalonetransferagent")
+		v, ok := client.GitEnv().Get("lfs.standalonetransferagent")
+		if !ok && hasS3Bucket(client.GitEnv()) {
+			return S3AdapterName
+		}
 		return v
 	}
 
@@ -135,6 +347,9 @@ func findStandaloneTransfer(client *lfsapi.Client, operation, remote string) str
 	uc := config.NewURLConfig(client.GitEnv())
 	v, ok := uc.Get("lfs", ep.Url, "standalonetransferagent")
 	if !ok {
+		if hasS3Bucket(client.GitEnv()) {
+			return S3AdapterName
+		}
 		return findDefaultStandaloneTransfer(aep.Url)
 	}
 
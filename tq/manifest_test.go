@@ -38,3 +38,126 @@ func TestManifestIgnoresNonInts(t *testing.T) {
 	m := NewManifest(nil, cli, "", "")
 	assert.Equal(t, 8, m.MaxRetries())
 }
+
+func TestManifestTransferOrder(t *testing.T) {
+	for value, expected := range map[string]string{
+		"":               orderDefault,
+		"default":        orderDefault,
+		"largest-first":  orderLargestFirst,
+		"Smallest-First": orderSmallestFirst,
+		"random":         orderDefault,
+	} {
+		vals := map[string]string{}
+		if len(value) > 0 {
+			vals["lfs.transfer.order"] = value
+		}
+		cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, vals))
+		require.Nil(t, err)
+
+		m := NewManifest(nil, cli, "", "")
+		assert.Equal(t, expected, m.transferOrder, "lfs.transfer.order=%q", value)
+	}
+}
+
+func TestManifestBatchSize(t *testing.T) {
+	for value, expected := range map[string]int{
+		"":     100,
+		"25":   25,
+		"1000": 1000,
+		"0":    100,
+		"-5":   100,
+		"many": 100,
+	} {
+		vals := map[string]string{}
+		if len(value) > 0 {
+			vals["lfs.transfer.batchsize"] = value
+		}
+		cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, vals))
+		require.Nil(t, err)
+
+		m := NewManifest(nil, cli, "", "")
+		assert.Equal(t, expected, m.BatchSize(), "lfs.transfer.batchsize=%q", value)
+	}
+}
+
+func TestManifestChunkedTransfers(t *testing.T) {
+	cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.chunkedtransfers":      "true",
+		"lfs.transfer.chunksize":    "8 MB",
+		"lfs.transfer.chunkworkers": "2",
+	}))
+	require.Nil(t, err)
+
+	m := NewManifest(nil, cli, "", "")
+	assert.Contains(t, m.GetAdapterNames(Upload), ChunkedAdapterName)
+	assert.NotContains(t, m.GetAdapterNames(Download), ChunkedAdapterName)
+	assert.EqualValues(t, 8*1000*1000, m.chunkSize)
+	assert.Equal(t, 2, m.chunkWorkers)
+}
+
+func TestManifestChunkedTransfersDefaults(t *testing.T) {
+	for _, value := range []string{"", "0", "lots"} {
+		vals := map[string]string{}
+		if len(value) > 0 {
+			vals["lfs.transfer.chunksize"] = value
+		}
+		cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, vals))
+		require.Nil(t, err)
+
+		m := NewManifest(nil, cli, "", "")
+		assert.NotContains(t, m.GetAdapterNames(Upload), ChunkedAdapterName)
+		assert.EqualValues(t, defaultChunkSize, m.chunkSize, "lfs.transfer.chunksize=%q", value)
+		assert.Equal(t, defaultChunkWorkers, m.chunkWorkers)
+	}
+}
+
+func TestManifestDeltaTransfers(t *testing.T) {
+	cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.deltatransfers": "true",
+	}))
+	require.Nil(t, err)
+
+	m := NewManifest(nil, cli, "", "")
+	assert.Contains(t, m.GetAdapterNames(Upload), DeltaAdapterName)
+	assert.Contains(t, m.GetAdapterNames(Download), DeltaAdapterName)
+
+	m.SetDeltaBases(func(name, oid string) []string {
+		return []string{oid, "base"}
+	})
+	bases := m.newDeltaBases()
+	assert.Equal(t, []string{"base"}, bases.offer(&Transfer{Name: "a.dat", Oid: "oid"}))
+	assert.Empty(t, bases.offer(&Transfer{Oid: "oid"}))
+
+	bases.whole["oid"] = true
+	assert.Empty(t, bases.offer(&Transfer{Name: "a.dat", Oid: "oid"}))
+	assert.Empty(t, bases.whole)
+	assert.Equal(t, []string{"base"}, bases.offer(&Transfer{Name: "a.dat", Oid: "oid"}))
+
+	m = NewManifest(nil, cli, "", "")
+	assert.Empty(t, m.newDeltaBases().offer(&Transfer{Name: "a.dat", Oid: "oid"}))
+}
+
+func TestManifestResume(t *testing.T) {
+	cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.transfer.enableresume": "false",
+		"lfs.transfer.maxresumes":   "0",
+	}))
+	require.Nil(t, err)
+
+	m := NewManifest(nil, cli, "", "")
+	assert.True(t, m.disableResume)
+	assert.Equal(t, 0, m.maxResumes)
+
+	for _, value := range []string{"", "-1", "many"} {
+		vals := map[string]string{}
+		if len(value) > 0 {
+			vals["lfs.transfer.maxresumes"] = value
+		}
+		cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, vals))
+		require.Nil(t, err)
+
+		m := NewManifest(nil, cli, "", "")
+		assert.False(t, m.disableResume)
+		assert.Equal(t, defaultMaxResumes, m.maxResumes, "lfs.transfer.maxresumes=%q", value)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/tasklog"
 	"github.com/git-lfs/git-lfs/tools"
 	"github.com/git-lfs/git-lfs/tools/humanize"
@@ -27,6 +28,7 @@ type Meter struct {
 	currentBytes      int64
 	sampleCount       uint64
 	avgBytes          float64
+	rollingBytes      float64
 	lastAvg           time.Time
 	estimatedFiles    int32
 	paused            uint32
@@ -37,9 +39,18 @@ type Meter struct {
 
 	DryRun    bool
 	Logger    *tools.SyncWriter
+	Events    *events.Emitter
 	Direction Direction
+
+	// Quiet hides the progress meter, as when progress is given as a
+	// stream of events instead, while still writing to Logger and Events.
+	Quiet bool
 }
 
+// etaSmoothing is the weight given to the latest sample of the transfer rate
+// in the rolling average from which the time remaining is estimated.
+const etaSmoothing = 0.25
+
 type env interface {
 	Get(key string) (val string, ok bool)
 }
@@ -162,9 +173,16 @@ func (m *Meter) TransferBytes(direction, name string, read, total int64, current
 		bps := float64(m.lastBytes) / since.Seconds()
 
 		m.avgBytes = (m.avgBytes*float64(m.sampleCount) + bps) / (float64(m.sampleCount) + 1.0)
+		if m.sampleCount == 0 {
+			m.rollingBytes = bps
+		} else {
+			m.rollingBytes = etaSmoothing*bps + (1-etaSmoothing)*m.rollingBytes
+		}
 
 		atomic.StoreInt64(&m.lastBytes, 0)
 		atomic.AddUint64(&m.sampleCount, 1)
+
+		m.emitProgress()
 	}
 
 	m.logBytes(direction, name, read, total)
@@ -199,6 +217,9 @@ func (m *Meter) Finish() {
 	}
 
 	m.update(false)
+	if m.estimatedFiles > 0 {
+		m.emitProgress()
+	}
 	close(m.updates)
 }
 
@@ -226,7 +247,7 @@ func (m *Meter) update(force bool) {
 }
 
 func (m *Meter) skipUpdate() bool {
-	return m.DryRun ||
+	return m.DryRun || m.Quiet ||
 		m.estimatedFiles == 0 ||
 		atomic.LoadUint32(&m.paused) == 1
 }
@@ -235,12 +256,58 @@ func (m *Meter) str() string {
 	// (Uploading|Downloading) LFS objects: 100% (10/10) 100 MiB | 10 MiB/s
 	percentage := 100 * float64(m.finishedFiles) / float64(m.estimatedFiles)
 
-	return fmt.Sprintf("%s LFS objects: %3.f%% (%d/%d), %s | %s",
+	s := fmt.Sprintf("%s LFS objects: %3.f%% (%d/%d), %s | %s",
 		m.Direction.Verb(),
 		percentage,
 		m.finishedFiles, m.estimatedFiles,
 		humanize.FormatBytes(clamp(m.currentBytes)),
 		humanize.FormatByteRate(clampf(m.avgBytes), time.Second))
+
+	if eta, ok := m.eta(); ok {
+		s += fmt.Sprintf(", ETA %s", eta)
+	}
+	return s
+}
+
+// eta estimates the time remaining from the rolling average of the transfer
+// rate and the number of bytes left to transfer. It returns false if that
+// can't be estimated, such as when the total size of the transfer isn't known,
+// or the transfer has finished.
+func (m *Meter) eta() (time.Duration, bool) {
+	estimated := atomic.LoadInt64(&m.estimatedBytes)
+	remaining := estimated - atomic.LoadInt64(&m.currentBytes)
+	if estimated <= 0 || remaining <= 0 || m.rollingBytes <= 0 ||
+		atomic.LoadInt64(&m.finishedFiles) >= int64(atomic.LoadInt32(&m.estimatedFiles)) {
+		return 0, false
+	}
+
+	eta := time.Duration(float64(remaining) / m.rollingBytes * float64(time.Second))
+	if eta > 0 && eta < time.Second {
+		eta = time.Second
+	}
+	return eta.Round(time.Second), true
+}
+
+// emitProgress writes a TransferProgress event with the current totals to the
+// event stream, if any.
+func (m *Meter) emitProgress() {
+	if m.Events == nil || m.DryRun {
+		return
+	}
+
+	ev := &events.Event{
+		Type:           events.TransferProgress,
+		Direction:      m.Direction.String(),
+		Files:          atomic.LoadInt64(&m.finishedFiles),
+		TotalFiles:     int64(atomic.LoadInt32(&m.estimatedFiles)),
+		Bytes:          atomic.LoadInt64(&m.currentBytes),
+		TotalBytes:     atomic.LoadInt64(&m.estimatedBytes),
+		BytesPerSecond: int64(m.rollingBytes),
+	}
+	if eta, ok := m.eta(); ok {
+		ev.ETA = int64(eta / time.Second)
+	}
+	m.Events.Emit(ev)
 }
 
 // clamp clamps the given "x" within the acceptable domain of the uint64 integer
@@ -0,0 +1,65 @@
+package tq
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/git-lfs/git-lfs/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeterETA(t *testing.T) {
+	m := &Meter{
+		estimatedFiles: 2,
+		finishedFiles:  1,
+		estimatedBytes: 3000,
+		currentBytes:   1000,
+		rollingBytes:   100,
+	}
+
+	eta, ok := m.eta()
+	require.True(t, ok)
+	assert.Equal(t, 20*time.Second, eta)
+	assert.Contains(t, m.str(), ", ETA 20s")
+}
+
+func TestMeterETAUnknown(t *testing.T) {
+	for desc, m := range map[string]*Meter{
+		"no total":  {estimatedFiles: 2, estimatedBytes: 0, rollingBytes: 100},
+		"no rate":   {estimatedFiles: 2, estimatedBytes: 3000},
+		"finished":  {estimatedFiles: 2, finishedFiles: 2, estimatedBytes: 3000, rollingBytes: 100},
+		"all bytes": {estimatedFiles: 2, estimatedBytes: 3000, currentBytes: 3000, rollingBytes: 100},
+	} {
+		_, ok := m.eta()
+		assert.False(t, ok, desc)
+		assert.NotContains(t, m.str(), "ETA", desc)
+	}
+}
+
+func TestMeterEmitsProgress(t *testing.T) {
+	var buf bytes.Buffer
+	m := &Meter{
+		Direction:      Download,
+		Events:         events.NewEmitter(&buf),
+		estimatedFiles: 4,
+		finishedFiles:  1,
+		estimatedBytes: 3000,
+		currentBytes:   1000,
+		rollingBytes:   100,
+	}
+	m.emitProgress()
+
+	var ev map[string]interface{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &ev))
+	assert.Equal(t, "transfer-progress", ev["event"])
+	assert.Equal(t, "download", ev["direction"])
+	assert.Equal(t, float64(1), ev["files"])
+	assert.Equal(t, float64(4), ev["total_files"])
+	assert.Equal(t, float64(1000), ev["bytes"])
+	assert.Equal(t, float64(3000), ev["total_bytes"])
+	assert.Equal(t, float64(100), ev["bytes_per_second"])
+	assert.Equal(t, float64(20), ev["eta_seconds"])
+}
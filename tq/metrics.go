@@ -0,0 +1,165 @@
+package tq
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics collects counters of the transfers made by the transfer queues of
+// a process, and writes them to a file in the Prometheus text exposition
+// format each time a queue finishes, so that they can be collected by, e.g.,
+// the textfile collector of the Prometheus node exporter.
+//
+// A nil *Metrics collects nothing.
+type Metrics struct {
+	// Path is the path of the file the metrics are written to.
+	Path string
+
+	mu         sync.Mutex
+	directions map[Direction]*directionMetrics
+}
+
+// directionMetrics are the counters of the transfers in a single direction.
+type directionMetrics struct {
+	objects     int64
+	bytes       int64
+	failures    int64
+	retries     int64
+	duration    time.Duration
+	concurrency int
+}
+
+// NewMetrics returns a *Metrics which writes to the file at "path".
+func NewMetrics(path string) *Metrics {
+	return &Metrics{
+		Path:       path,
+		directions: make(map[Direction]*directionMetrics),
+	}
+}
+
+// transferred records that an object of "size" bytes was transferred in the
+// direction "dir".
+func (m *Metrics) transferred(dir Direction, size int64) {
+	m.update(dir, func(d *directionMetrics) {
+		d.objects++
+		d.bytes += size
+	})
+}
+
+// failed records that the transfer of an object failed and was not retried.
+func (m *Metrics) failed(dir Direction) {
+	m.update(dir, func(d *directionMetrics) { d.failures++ })
+}
+
+// retried records that the transfer of an object was retried.
+func (m *Metrics) retried(dir Direction) {
+	m.update(dir, func(d *directionMetrics) { d.retries++ })
+}
+
+// finished records that a queue in the direction "dir" which was started at
+// "start" and made up to "concurrency" transfers at once has finished, and
+// writes the metrics collected so far.
+func (m *Metrics) finished(dir Direction, start time.Time, concurrency int) error {
+	m.update(dir, func(d *directionMetrics) {
+		d.duration += time.Since(start)
+		if concurrency > d.concurrency {
+			d.concurrency = concurrency
+		}
+	})
+	return m.Write()
+}
+
+func (m *Metrics) update(dir Direction, fn func(*directionMetrics)) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.directions[dir]
+	if !ok {
+		d = &directionMetrics{}
+		m.directions[dir] = d
+	}
+	fn(d)
+}
+
+// metricDescs describes each metric written, in order, with a function
+// returning its value for a single direction.
+var metricDescs = []struct {
+	name, kind, help string
+	value            func(d *directionMetrics) string
+}{
+	{"git_lfs_transfer_objects_total", "counter", "Number of objects transferred.",
+		func(d *directionMetrics) string { return fmt.Sprintf("%d", d.objects) }},
+	{"git_lfs_transfer_bytes_total", "counter", "Number of bytes of the objects transferred.",
+		func(d *directionMetrics) string { return fmt.Sprintf("%d", d.bytes) }},
+	{"git_lfs_transfer_failures_total", "counter", "Number of objects whose transfers failed.",
+		func(d *directionMetrics) string { return fmt.Sprintf("%d", d.failures) }},
+	{"git_lfs_transfer_retries_total", "counter", "Number of times transfers were retried.",
+		func(d *directionMetrics) string { return fmt.Sprintf("%d", d.retries) }},
+	{"git_lfs_transfer_duration_seconds", "gauge", "Time spent transferring objects.",
+		func(d *directionMetrics) string { return fmt.Sprintf("%.3f", d.duration.Seconds()) }},
+	{"git_lfs_transfer_concurrency", "gauge", "Maximum number of concurrent transfers.",
+		func(d *directionMetrics) string { return fmt.Sprintf("%d", d.concurrency) }},
+}
+
+// Format returns the metrics collected so far in the Prometheus text format.
+func (m *Metrics) Format() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dirs := make([]Direction, 0, len(m.directions))
+	for dir := range m.directions {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].String() < dirs[j].String() })
+
+	var buf bytes.Buffer
+	for _, desc := range metricDescs {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", desc.name, desc.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", desc.name, desc.kind)
+		for _, dir := range dirs {
+			fmt.Fprintf(&buf, "%s{direction=%q} %s\n", desc.name, dir.String(), desc.value(m.directions[dir]))
+		}
+	}
+	return buf.Bytes()
+}
+
+// Write replaces the file at Path with the metrics collected so far. The file
+// is replaced atomically, so that it is never read while partly written.
+func (m *Metrics) Write() error {
+	if m == nil {
+		return nil
+	}
+
+	dir, base := filepath.Split(m.Path)
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(m.Format()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.Path)
+}
@@ -0,0 +1,65 @@
+package tq
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	m := NewMetrics("")
+	m.transferred(Upload, 10)
+	m.transferred(Upload, 5)
+	m.retried(Upload)
+	m.failed(Download)
+	m.update(Download, func(d *directionMetrics) {
+		d.duration = 1500 * time.Millisecond
+		d.concurrency = 8
+	})
+
+	out := string(m.Format())
+	for _, line := range []string{
+		"# TYPE git_lfs_transfer_objects_total counter",
+		`git_lfs_transfer_objects_total{direction="download"} 0`,
+		`git_lfs_transfer_objects_total{direction="upload"} 2`,
+		`git_lfs_transfer_bytes_total{direction="upload"} 15`,
+		`git_lfs_transfer_failures_total{direction="download"} 1`,
+		`git_lfs_transfer_retries_total{direction="upload"} 1`,
+		`git_lfs_transfer_duration_seconds{direction="download"} 1.500`,
+		`git_lfs_transfer_concurrency{direction="download"} 8`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.True(t, strings.Index(out, `{direction="download"}`) < strings.Index(out, `{direction="upload"}`))
+}
+
+func TestMetricsWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tq-metrics")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lfs.prom")
+	m := NewMetrics(path)
+	require.Nil(t, m.finished(Download, time.Now(), 3))
+
+	by, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	assert.Contains(t, string(by), `git_lfs_transfer_concurrency{direction="download"} 3`)
+
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.transferred(Download, 1)
+	m.retried(Download)
+	assert.Nil(t, m.finished(Download, time.Now(), 1))
+}
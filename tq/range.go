@@ -0,0 +1,157 @@
+package tq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/git"
+	"github.com/rubyist/tracerx"
+)
+
+var contentRangeRE = regexp.MustCompile(`\Abytes (\d+)-(\d+)/(\d+|\*)\z`)
+
+// DownloadRange writes the bytes of the object "t" from "from" to "to",
+// inclusive, to "w", requesting only those bytes from the server with an HTTP
+// Range request to the object's basic download action.
+//
+// If the server ignores the range and sends the whole object, the object is
+// read in full and verified, and only the range is written, unless "rangeOnly"
+// is set, in which case an error is returned without writing anything. If an
+// error is returned, anything written to "w" should be discarded. The
+// Content-Type the server sent is recorded in "t".
+func DownloadRange(m *Manifest, remote string, remoteRef *git.Ref, t *Transfer, from, to int64, rangeOnly bool, w io.Writer) error {
+	if from < 0 || to < from || to >= t.Size {
+		return errors.Errorf("invalid byte range %d-%d for object %s of %d byte(s)", from, to, t.Oid, t.Size)
+	}
+	if m.IsStandaloneTransfer() {
+		return errors.New("byte ranges cannot be downloaded with a standalone transfer agent")
+	}
+
+	c := m.batchClient()
+	e := c.Endpoints.Endpoint(Download.String(), remote)
+	if rawurl := m.routes.URLFor(t.Name); len(rawurl) > 0 {
+		e = c.Endpoints.NewEndpoint(Download.String(), rawurl)
+	}
+
+	// Only the basic adapter downloads objects with a plain GET request
+	// which a Range header can be added to.
+	bRes, err := c.BatchTo(remote, e, &batchRequest{
+		Operation: Download.String(),
+		Objects:   []*Transfer{{Name: t.Name, Oid: t.Oid, Size: t.Size}},
+		Ref:       m.batchRefFor(remoteRef),
+		Namespace: m.objectPrefix,
+	})
+	if err != nil {
+		return err
+	}
+	if len(bRes.TransferAdapterName) > 0 && bRes.TransferAdapterName != BasicAdapterName {
+		return errors.Errorf("server chose the %q transfer adapter, which doesn't support byte ranges", bRes.TransferAdapterName)
+	}
+
+	var obj *Transfer
+	for _, o := range bRes.Objects {
+		if o.Oid == t.Oid {
+			obj = o
+		}
+	}
+	if obj == nil {
+		return errors.Errorf("object %s not returned by the server", t.Oid)
+	}
+	if obj.Error != nil {
+		return errors.Wrapf(obj.Error, "object %s", t.Oid)
+	}
+
+	rel, err := obj.Rel(Download.String())
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		return errors.Errorf("no download action returned for object %s", t.Oid)
+	}
+
+	apiClient := m.APIClient()
+	req, err := newActionRequest("GET", rel)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+
+	req = apiClient.LogRequest(req, "lfs.data.download")
+	tracerx.Printf("tq: downloading bytes %d-%d of %s", from, to, t.Oid)
+
+	var res *http.Response
+	if obj.Authenticated {
+		res, err = apiClient.Do(req)
+	} else {
+		res, err = apiClient.DoWithAuthNoRetry(remote, apiClient.Endpoints.AccessFor(endpointURL(req.URL.String(), t.Oid)), req)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	t.ContentType = res.Header.Get("Content-Type")
+
+	n := to - from + 1
+	switch res.StatusCode {
+	case 206:
+		match := contentRangeRE.FindStringSubmatch(res.Header.Get("Content-Range"))
+		if match == nil {
+			return errors.Errorf("badly formatted Content-Range header: %q", res.Header.Get("Content-Range"))
+		}
+		if start, _ := strconv.ParseInt(match[1], 10, 64); start != from {
+			return errors.Errorf("Content-Range start byte incorrect: %s expected %d", match[1], from)
+		}
+
+		written, err := io.Copy(w, io.LimitReader(res.Body, n))
+		if err != nil {
+			return err
+		}
+		if written != n {
+			return errors.Errorf("expected %d byte(s) of object %s, received %d", n, t.Oid, written)
+		}
+		return nil
+	case 200:
+		if rangeOnly {
+			return errors.Errorf("server ignored the byte range requested for object %s", t.Oid)
+		}
+		tracerx.Printf("tq: server ignored byte range for %s, reading the whole object", t.Oid)
+		return sliceObject(res.Body, t, from, n, w)
+	default:
+		return errors.Errorf("expected status code 206, received %d", res.StatusCode)
+	}
+}
+
+// sliceObject reads the whole of the object "t" from "r", writing the "n"
+// bytes starting at "from" to "w", and returns an error if what was read isn't
+// the object, in which case what was written should be discarded.
+func sliceObject(r io.Reader, t *Transfer, from, n int64, w io.Writer) error {
+	hasher := sha256.New()
+	tee := io.TeeReader(r, hasher)
+
+	if _, err := io.CopyN(ioutil.Discard, tee, from); err != nil {
+		return errors.Wrapf(err, "reading object %s", t.Oid)
+	}
+	if _, err := io.CopyN(w, tee, n); err != nil {
+		return errors.Wrapf(err, "reading object %s", t.Oid)
+	}
+
+	rest, err := io.Copy(ioutil.Discard, tee)
+	if err != nil {
+		return errors.Wrapf(err, "reading object %s", t.Oid)
+	}
+	if size := from + n + rest; size != t.Size {
+		return errors.Errorf("expected object %s to be %d byte(s), received %d", t.Oid, t.Size, size)
+	}
+	if oid := hex.EncodeToString(hasher.Sum(nil)); oid != t.Oid {
+		return errors.Errorf("expected OID %s, got %s", t.Oid, oid)
+	}
+	return nil
+}
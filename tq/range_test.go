@@ -0,0 +1,37 @@
+package tq
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSliceObject(t *testing.T) {
+	content := "some object content"
+	sum := sha256.Sum256([]byte(content))
+	tr := &Transfer{Oid: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+
+	var buf bytes.Buffer
+	assert.Nil(t, sliceObject(strings.NewReader(content), tr, 5, 6, &buf))
+	assert.Equal(t, "object", buf.String())
+}
+
+func TestSliceObjectWrongContent(t *testing.T) {
+	content := "some object content"
+	sum := sha256.Sum256([]byte(content))
+	tr := &Transfer{Oid: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+
+	err := sliceObject(strings.NewReader("some other content!"), tr, 0, 4, &bytes.Buffer{})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "expected OID")
+	}
+
+	err = sliceObject(strings.NewReader(content[:10]), tr, 0, 4, &bytes.Buffer{})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "expected object")
+	}
+}
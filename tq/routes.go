@@ -0,0 +1,107 @@
+package tq
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/filepathfilter"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	routeKeyPrefix = "lfs.route."
+	routeKeySuffix = ".url"
+)
+
+// endpointRoute directs the objects of all files matching a pattern to an LFS
+// endpoint other than the remote's own. It is configured as
+// "lfs.route.<pattern>.url".
+type endpointRoute struct {
+	pattern string
+	url     string
+	filter  *filepathfilter.Filter
+}
+
+// endpointRoutes is a set of routes, ordered such that the first route to
+// match a given file is the one to use.
+type endpointRoutes []*endpointRoute
+
+// findEndpointRoutes returns the routes configured in "git", with the longest,
+// and so most specific, patterns first. Routes with an empty URL are ignored.
+func findEndpointRoutes(git config.Environment) endpointRoutes {
+	var routes endpointRoutes
+	for key, values := range git.All() {
+		if len(values) == 0 || !strings.HasPrefix(key, routeKeyPrefix) || !strings.HasSuffix(key, routeKeySuffix) {
+			continue
+		}
+
+		pattern := strings.TrimSuffix(strings.TrimPrefix(key, routeKeyPrefix), routeKeySuffix)
+		url := values[len(values)-1]
+		if len(pattern) == 0 || len(url) == 0 {
+			tracerx.Printf("tq: ignoring incomplete endpoint route %q", key)
+			continue
+		}
+
+		routes = append(routes, &endpointRoute{
+			pattern: pattern,
+			url:     url,
+			filter:  filepathfilter.New([]string{pattern}, nil),
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if len(routes[i].pattern) != len(routes[j].pattern) {
+			return len(routes[i].pattern) > len(routes[j].pattern)
+		}
+		return routes[i].pattern < routes[j].pattern
+	})
+	return routes
+}
+
+// URLFor returns the URL of the endpoint to which the object of the file
+// "name" should be routed, or the empty string if the remote's own endpoint
+// should be used.
+func (rs endpointRoutes) URLFor(name string) string {
+	for _, r := range rs {
+		if r.filter.Allows(name) {
+			return r.url
+		}
+	}
+	return ""
+}
+
+// routedBatch is a batch whose objects are all to be requested from the same
+// endpoint. An empty url denotes the remote's own endpoint.
+type routedBatch struct {
+	url   string
+	batch batch
+}
+
+// Group splits "b" into one batch per endpoint, each of which keeps the
+// relative order of its objects. The batch for the remote's own endpoint, if
+// any, comes first, followed by the others in the order in which their
+// objects first appear in "b".
+func (rs endpointRoutes) Group(b batch) []*routedBatch {
+	if len(rs) == 0 {
+		return []*routedBatch{{batch: b}}
+	}
+
+	groups := []*routedBatch{{}}
+	byURL := map[string]*routedBatch{"": groups[0]}
+	for _, t := range b {
+		url := rs.URLFor(t.Name)
+		g, ok := byURL[url]
+		if !ok {
+			g = &routedBatch{url: url}
+			byURL[url] = g
+			groups = append(groups, g)
+		}
+		g.batch = append(g.batch, t)
+	}
+
+	if len(groups[0].batch) == 0 {
+		groups = groups[1:]
+	}
+	return groups
+}
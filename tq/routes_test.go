@@ -0,0 +1,85 @@
+package tq
+
+import (
+	"testing"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/filepathfilter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindEndpointRoutes(t *testing.T) {
+	git := config.NewFrom(config.Values{Git: map[string][]string{
+		"lfs.route.assets/**.url":          []string{"https://assets.example.com/lfs"},
+		"lfs.route.assets/textures/**.url": []string{"https://textures.example.com/lfs"},
+		"lfs.route.*.psd.url":              []string{"https://old.example.com/lfs", "https://psd.example.com/lfs"},
+		"lfs.route.docs/**.url":            []string{""},
+		"lfs.url":                          []string{"https://example.com/lfs"},
+	}}).Git
+
+	routes := findEndpointRoutes(git)
+	require.Len(t, routes, 3)
+	assert.Equal(t, "assets/textures/**", routes[0].pattern)
+	assert.Equal(t, "assets/**", routes[1].pattern)
+	assert.Equal(t, "*.psd", routes[2].pattern)
+	assert.Equal(t, "https://psd.example.com/lfs", routes[2].url)
+
+	assert.Equal(t, "https://textures.example.com/lfs", routes.URLFor("assets/textures/wall.png"))
+	assert.Equal(t, "https://assets.example.com/lfs", routes.URLFor("assets/model.fbx"))
+	assert.Equal(t, "https://psd.example.com/lfs", routes.URLFor("art/cover.psd"))
+	assert.Equal(t, "", routes.URLFor("docs/manual.pdf"))
+}
+
+func TestEndpointRoutesGroup(t *testing.T) {
+	routes := endpointRoutes{
+		{pattern: "a/**", url: "https://a.example.com/lfs", filter: filterFor("a/**")},
+		{pattern: "b/**", url: "https://b.example.com/lfs", filter: filterFor("b/**")},
+	}
+
+	groups := routes.Group(batch{
+		{Name: "b/1.dat", Oid: "1"},
+		{Name: "other.dat", Oid: "2"},
+		{Name: "a/3.dat", Oid: "3"},
+		{Name: "b/4.dat", Oid: "4"},
+	})
+
+	require.Len(t, groups, 3)
+	assert.Equal(t, "", groups[0].url)
+	assert.Equal(t, []string{"2"}, oidsOf(groups[0].batch))
+	assert.Equal(t, "https://b.example.com/lfs", groups[1].url)
+	assert.Equal(t, []string{"1", "4"}, oidsOf(groups[1].batch))
+	assert.Equal(t, "https://a.example.com/lfs", groups[2].url)
+	assert.Equal(t, []string{"3"}, oidsOf(groups[2].batch))
+}
+
+func TestEndpointRoutesGroupWithoutDefault(t *testing.T) {
+	routes := endpointRoutes{
+		{pattern: "a/**", url: "https://a.example.com/lfs", filter: filterFor("a/**")},
+	}
+
+	groups := routes.Group(batch{{Name: "a/1.dat", Oid: "1"}})
+	require.Len(t, groups, 1)
+	assert.Equal(t, "https://a.example.com/lfs", groups[0].url)
+}
+
+func TestEndpointRoutesGroupWithoutRoutes(t *testing.T) {
+	b := batch{{Name: "a/1.dat", Oid: "1"}, {Name: "2.dat", Oid: "2"}}
+
+	groups := endpointRoutes(nil).Group(b)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "", groups[0].url)
+	assert.Equal(t, b, groups[0].batch)
+}
+
+func filterFor(pattern string) *filepathfilter.Filter {
+	return filepathfilter.New([]string{pattern}, nil)
+}
+
+func oidsOf(b batch) []string {
+	oids := make([]string, 0, len(b))
+	for _, t := range b {
+		oids = append(oids, t.Oid)
+	}
+	return oids
+}
@@ -0,0 +1,338 @@
+package tq
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/git-lfs/git-lfs/config"
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+
+	// defaultS3MetadataEndpoint is the address of the EC2 instance metadata
+	// service, which gives the credentials of the instance's IAM role.
+	defaultS3MetadataEndpoint = "http://169.254.169.254"
+
+	// emptyPayloadHash is the SHA-256 of an empty request body, as signed
+	// for requests which have none.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Config is the bucket objects are transferred to and from by the S3
+// transfer adapter, as given by the "lfs.storage.s3.*" settings.
+type s3Config struct {
+	bucket    string
+	region    string
+	endpoint  *url.URL
+	prefix    string
+	pathStyle bool
+
+	// creds are the static credentials given by lfs.storage.s3.* or the
+	// AWS_* environment variables, if any. Otherwise those of the IAM
+	// role of the EC2 instance are fetched from metadataEndpoint.
+	creds            *s3Credentials
+	metadataEndpoint string
+
+	// err is the error in the settings, if any, returned for every
+	// transfer.
+	err error
+
+	mu sync.Mutex
+}
+
+// findS3Config returns the S3 bucket given by lfs.storage.s3.bucket, or nil if
+// there is none.
+func findS3Config(git, osEnv config.Environment) *s3Config {
+	bucket, _ := git.Get("lfs.storage.s3.bucket")
+	if len(bucket) == 0 {
+		return nil
+	}
+
+	c := &s3Config{bucket: bucket, metadataEndpoint: defaultS3MetadataEndpoint}
+	c.region = firstS3Setting(git, "lfs.storage.s3.region", osEnv, "AWS_REGION", "AWS_DEFAULT_REGION")
+	if len(c.region) == 0 {
+		c.region = defaultS3Region
+	}
+
+	endpoint := firstS3Setting(git, "lfs.storage.s3.endpoint", osEnv, "AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", c.region)
+		c.pathStyle = git.Bool("lfs.storage.s3.pathstyle", false)
+	} else {
+		// Other S3-compatible stores, such as MinIO, are more
+		// often served by a single host name than by one per bucket.
+		c.pathStyle = git.Bool("lfs.storage.s3.pathstyle", true)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		c.err = errors.Errorf("invalid lfs.storage.s3.endpoint %q: must be an HTTP or HTTPS URL", endpoint)
+		return c
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c.endpoint = u
+
+	prefix, _ := git.Get("lfs.storage.s3.prefix")
+	c.prefix = fs.CleanObjectPrefix(prefix)
+
+	if id, _ := git.Get("lfs.storage.s3.accesskeyid"); len(id) > 0 {
+		secret, _ := git.Get("lfs.storage.s3.secretaccesskey")
+		token, _ := git.Get("lfs.storage.s3.sessiontoken")
+		c.creds = &s3Credentials{AccessKeyID: id, SecretAccessKey: secret, Token: token}
+	} else if id, _ := osEnv.Get("AWS_ACCESS_KEY_ID"); len(id) > 0 {
+		secret, _ := osEnv.Get("AWS_SECRET_ACCESS_KEY")
+		token, _ := osEnv.Get("AWS_SESSION_TOKEN")
+		c.creds = &s3Credentials{AccessKeyID: id, SecretAccessKey: secret, Token: token}
+	} else if osEnv.Bool("AWS_EC2_METADATA_DISABLED", false) {
+		c.err = errors.New("no credentials for lfs.storage.s3.bucket: set lfs.storage.s3.accesskeyid or AWS_ACCESS_KEY_ID, or enable the EC2 instance metadata service")
+	} else if v, _ := osEnv.Get("AWS_EC2_METADATA_SERVICE_ENDPOINT"); len(v) > 0 {
+		c.metadataEndpoint = strings.TrimSuffix(v, "/")
+	}
+
+	return c
+}
+
+// firstS3Setting returns the value of the Git setting "key", or if not given,
+// of the first of the environment variables "vars" which is set.
+func firstS3Setting(git config.Environment, key string, osEnv config.Environment, vars ...string) string {
+	if v, _ := git.Get(key); len(v) > 0 {
+		return v
+	}
+	for _, name := range vars {
+		if v, _ := osEnv.Get(name); len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+// objectURL returns the URL of the object "oid" in the bucket, namespaced by
+// lfs.storage.s3.prefix and lfs.object.prefix, and laid out like the local
+// object store without a prefix.
+func (c *s3Config) objectURL(objectPrefix, oid string) *url.URL {
+	key := fs.ObjectKey(path.Join(c.prefix, objectPrefix), oid)
+
+	u := *c.endpoint
+	if c.pathStyle {
+		u.Path = u.Path + "/" + c.bucket + "/" + key
+	} else {
+		u.Host = c.bucket + "." + u.Host
+		u.Path = u.Path + "/" + key
+	}
+	return &u
+}
+
+// credentials returns the credentials requests to the bucket are signed with,
+// fetching those of the instance's IAM role if no others were given, or if
+// those fetched before are about to expire.
+func (c *s3Config) credentials() (*s3Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.creds != nil && (c.creds.Expiration.IsZero() || time.Until(c.creds.Expiration) > 5*time.Minute) {
+		return c.creds, nil
+	}
+
+	creds, err := fetchS3RoleCredentials(c.metadataEndpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get credentials for lfs.storage.s3.bucket from the EC2 instance metadata service")
+	}
+	c.creds = creds
+	return creds, nil
+}
+
+// s3Credentials are the AWS credentials requests are signed with.
+type s3Credentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// s3MetadataClient talks to the instance metadata service, which is always
+// reached directly, rather than through any proxy.
+var s3MetadataClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: &http.Transport{Proxy: nil},
+}
+
+// fetchS3RoleCredentials returns the temporary credentials of the IAM role of
+// the EC2 instance, from its metadata service at "endpoint", using a session
+// token as version 2 of the service requires.
+func fetchS3RoleCredentials(endpoint string) (*s3Credentials, error) {
+	req, err := http.NewRequest("PUT", endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := s3MetadataRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(p string) ([]byte, error) {
+		req, err := http.NewRequest("GET", endpoint+"/latest/meta-data/iam/security-credentials/"+p, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return s3MetadataRequest(req)
+	}
+
+	roles, err := get("")
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if len(role) == 0 {
+		return nil, errors.New("the instance has no IAM role")
+	}
+
+	by, err := get(role)
+	if err != nil {
+		return nil, err
+	}
+	creds := &s3Credentials{}
+	if err := json.Unmarshal(by, creds); err != nil {
+		return nil, errors.Wrapf(err, "invalid credentials for IAM role %q", role)
+	}
+	if len(creds.AccessKeyID) == 0 {
+		return nil, errors.Errorf("no credentials for IAM role %q", role)
+	}
+
+	tracerx.Printf("s3: using the credentials of IAM role %q, which expire at %s", role, creds.Expiration)
+	return creds, nil
+}
+
+func s3MetadataRequest(req *http.Request) ([]byte, error) {
+	res, err := s3MetadataClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	by, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		return nil, errors.Errorf("%s %s: status %d", req.Method, req.URL.Path, res.StatusCode)
+	}
+	return by, nil
+}
+
+// signedAction returns an action for the request "method" to "u", whose body
+// has the SHA-256 "payloadHash", with the headers which sign it with version
+// 4 of the AWS signature algorithm, at "now".
+func (c *s3Config) signedAction(method string, u *url.URL, payloadHash string, now time.Time) (*Action, error) {
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	header := map[string]string{
+		"X-Amz-Content-Sha256": payloadHash,
+		"X-Amz-Date":           now.UTC().Format("20060102T150405Z"),
+	}
+	if len(creds.Token) > 0 {
+		header["X-Amz-Security-Token"] = creds.Token
+	}
+	header["Authorization"] = signS3Request(creds, c.region, method, u, header, payloadHash, now)
+
+	return &Action{Href: u.String(), Header: header}, nil
+}
+
+// signS3Request returns the Authorization header signing the request "method"
+// to "u", including its host and the given headers.
+func signS3Request(creds *s3Credentials, region, method string, u *url.URL, header map[string]string, payloadHash string, now time.Time) string {
+	now = now.UTC()
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, region, "s3", "aws4_request"}, "/")
+
+	signed := map[string]string{"host": u.Host}
+	for k, v := range header {
+		signed[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + signed[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		s3EscapePath(u.Path),
+		u.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature)
+}
+
+// s3EscapePath returns "p" with every byte but the unreserved characters of RFC
+// 3986 and slashes percent-encoded, as the canonical request signed for S3
+// needs it.
+func s3EscapePath(p string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
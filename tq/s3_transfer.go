@@ -0,0 +1,145 @@
+package tq
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/git-lfs/git-lfs/errors"
+)
+
+const (
+	// S3AdapterName is the standalone transfer agent moving objects
+	// directly to and from the S3 bucket given by lfs.storage.s3.bucket,
+	// without a Git LFS API server.
+	S3AdapterName = "lfs-standalone-s3"
+)
+
+// Adapter for downloads from an S3 bucket, which are made as basic downloads,
+// including resuming them, of the object's URL in the bucket, with a signed
+// download action. Those refused with a status isS3FinalStatus gives aren't
+// retried.
+type s3DownloadAdapter struct {
+	*basicDownloadAdapter
+	s3     *s3Config
+	prefix string
+}
+
+func (a *s3DownloadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCallback, authOkFunc func()) error {
+	action, err := a.s3.signedAction("GET", a.s3.objectURL(a.prefix, t.Oid), emptyPayloadHash, time.Now())
+	if err != nil {
+		return err
+	}
+
+	t.Actions = ActionSet{"download": action}
+	t.Authenticated = true
+	return a.basicDownloadAdapter.DoTransfer(ctx, t, cb, authOkFunc)
+}
+
+// Adapter for uploads to an S3 bucket, which are made as basic uploads of the
+// object's URL in the bucket, with a signed upload action, unless the bucket
+// has the object already.
+type s3UploadAdapter struct {
+	*basicUploadAdapter
+	s3     *s3Config
+	prefix string
+}
+
+func (a *s3UploadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCallback, authOkFunc func()) error {
+	u := a.s3.objectURL(a.prefix, t.Oid)
+
+	exists, err := a.exists(t, u)
+	if err != nil {
+		return err
+	}
+	if exists {
+		a.Trace("xfer: %q is already in S3 bucket %q, skipping", t.Oid, a.s3.bucket)
+		if authOkFunc != nil {
+			authOkFunc()
+		}
+		advanceCallbackProgress(a.cb, t, t.Size)
+		return nil
+	}
+
+	// The OID is the SHA-256 of the object, so it signs the content, which
+	// S3 checks as it receives it.
+	action, err := a.s3.signedAction("PUT", u, t.Oid, time.Now())
+	if err != nil {
+		return err
+	}
+
+	t.Actions = ActionSet{"upload": action}
+	t.Authenticated = true
+	return a.basicUploadAdapter.DoTransfer(ctx, t, cb, authOkFunc)
+}
+
+// exists returns whether the bucket holds the object "t", of its size, at "u".
+func (a *s3UploadAdapter) exists(t *Transfer, u *url.URL) (bool, error) {
+	action, err := a.s3.signedAction("HEAD", u, emptyPayloadHash, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest("HEAD", action.Href, nil)
+	if err != nil {
+		return false, err
+	}
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+
+	res, err := a.apiClient.Do(req)
+	if res == nil {
+		// We encountered a network or similar error which caused us
+		// to not receive a response at all.
+		return false, errors.NewRetriableError(err)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode == 200:
+		return res.ContentLength == t.Size, nil
+	case res.StatusCode == 404:
+		return false, nil
+	case res.StatusCode >= 500:
+		return false, errors.NewRetriableError(errors.Errorf("Invalid status for HEAD %s: %d", action.Href, res.StatusCode))
+	}
+
+	// S3 refuses requests whose signature or credentials are wrong with
+	// a 403, which retrying won't change.
+	return false, errors.Errorf("Invalid status for HEAD %s: %d", action.Href, res.StatusCode)
+}
+
+// isS3FinalStatus returns whether the status of a response from S3 is one which
+// retrying the request won't change: a 403, with which S3 refuses requests
+// whose signature or credentials are wrong, or a 404, for an object the bucket
+// doesn't have.
+func isS3FinalStatus(status int) bool {
+	return status == 403 || status == 404
+}
+
+func configureS3Adapter(m *Manifest) {
+	m.RegisterNewAdapterFunc(S3AdapterName, Download, func(name string, dir Direction) Adapter {
+		bd := &basicDownloadAdapter{
+			adapterBase:   newAdapterBase(m.fs, name, dir, nil),
+			verifyDigests: m.verifyDigests,
+			disableResume: m.disableResume,
+			maxResumes:    m.maxResumes,
+			finalStatus:   isS3FinalStatus,
+		}
+		sd := &s3DownloadAdapter{basicDownloadAdapter: bd, s3: m.s3, prefix: m.objectPrefix}
+		// self implements impl
+		bd.transferImpl = sd
+		return sd
+	})
+	m.RegisterNewAdapterFunc(S3AdapterName, Upload, func(name string, dir Direction) Adapter {
+		bu := &basicUploadAdapter{newAdapterBase(m.fs, name, dir, nil)}
+		su := &s3UploadAdapter{basicUploadAdapter: bu, s3: m.s3, prefix: m.objectPrefix}
+		// self implements impl
+		bu.transferImpl = su
+		return su
+	})
+}
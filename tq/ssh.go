@@ -0,0 +1,334 @@
+package tq
+
+import (
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/fs"
+	"github.com/git-lfs/git-lfs/ssh"
+	"github.com/git-lfs/git-lfs/tools"
+)
+
+const (
+	// SSHAdapterName is the transfer adapter moving objects through
+	// git-lfs-transfer(1) on an SSH server, over the same connection as
+	// the batch requests, when the server supports the SSH transfer
+	// protocol.
+	SSHAdapterName = "ssh"
+)
+
+// sshTransfer returns the connection to git-lfs-transfer(1) through which
+// batch requests in the direction "dir" to the endpoint of "remote" are made,
+// or nil if they are made through the LFS API.
+func (m *Manifest) sshTransfer(dir Direction, remote string) (*ssh.Connection, error) {
+	if m.IsStandaloneTransfer() {
+		return nil, nil
+	}
+
+	conn, err := m.apiClient.SSHTransfer(dir.String(), remote)
+	if conn != nil {
+		m.sshOnce.Do(func() { configureSSHAdapter(m) })
+	}
+	return conn, err
+}
+
+// sshBatch makes the batch request "bReq" through "conn". Each object the
+// server returns has the action it needs, if any, with the ID and token of
+// the transfer, and objects the server can't provide for a download are
+// returned with an error.
+func (m *Manifest) sshBatch(conn *ssh.Connection, remote string, bReq *batchRequest) (*BatchResponse, error) {
+	args := []string{"hash-algo=sha256", "transfer=" + SSHAdapterName}
+	if name := bReq.Ref.refName(); len(name) > 0 {
+		args = append(args, "refname="+name)
+	}
+
+	missing := make(map[string]bool, len(bReq.Objects))
+	lines := make([]string, 0, len(bReq.Objects))
+	for _, obj := range bReq.Objects {
+		missing[obj.Oid] = obj.Missing
+		lines = append(lines, fmt.Sprintf("%s %d", obj.Oid, obj.Size))
+	}
+
+	requestedAt := time.Now()
+	res, err := conn.Exchange(&ssh.Message{Command: "batch", Args: args, Lines: lines}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "batch request")
+	}
+	if err := res.Err(); err != nil {
+		return nil, errors.Wrap(sshError(err), "batch response")
+	}
+
+	bRes := &BatchResponse{
+		TransferAdapterName: SSHAdapterName,
+		endpoint:            m.apiClient.Endpoints.Endpoint(bReq.Operation, remote),
+	}
+	for _, line := range res.Lines {
+		obj, err := parseSSHBatchObject(line, bReq.Operation, requestedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "batch response")
+		}
+		obj.Missing = missing[obj.Oid]
+		bRes.Objects = append(bRes.Objects, obj)
+	}
+	return bRes, nil
+}
+
+// parseSSHBatchObject parses a line of the response to a batch request made in
+// the direction "operation": the OID and size of an object, the action needed,
+// which is "upload", "download" or "noop", and any arguments of the action,
+// each of the form "key=value".
+func parseSSHBatchObject(line, operation string, requestedAt time.Time) (*Transfer, error) {
+	fields := strings.Split(line, " ")
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid object %q", line)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid size in object %q", line)
+	}
+
+	obj := &Transfer{Oid: fields[0], Size: size}
+	switch fields[2] {
+	case "noop":
+		if operation == Download.String() {
+			obj.Error = &ObjectError{Code: 404, Message: "Object does not exist on the server"}
+		}
+		return obj, nil
+	case Upload.String(), Download.String():
+	default:
+		return nil, fmt.Errorf("invalid action %q in object %q", fields[2], line)
+	}
+
+	action := &Action{createdAt: requestedAt}
+	for _, arg := range fields[3:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid argument %q in object %q", arg, line)
+		}
+
+		switch kv[0] {
+		case "id":
+			action.Id = kv[1]
+		case "token":
+			action.Token = kv[1]
+		case "expires-in":
+			if action.ExpiresIn, err = strconv.Atoi(kv[1]); err != nil {
+				return nil, fmt.Errorf("invalid expires-in in object %q", line)
+			}
+		case "expires-at":
+			if action.ExpiresAt, err = time.Parse(time.RFC3339, kv[1]); err != nil {
+				return nil, fmt.Errorf("invalid expires-at in object %q", line)
+			}
+		}
+	}
+	obj.Actions = ActionSet{fields[2]: action}
+	return obj, nil
+}
+
+// sshActionArgs returns the arguments identifying the transfer of "action" to
+// the server, if it gave any.
+func sshActionArgs(action *Action) []string {
+	var args []string
+	if action == nil {
+		return args
+	}
+	if len(action.Id) > 0 {
+		args = append(args, "id="+action.Id)
+	}
+	if len(action.Token) > 0 {
+		args = append(args, "token="+action.Token)
+	}
+	return args
+}
+
+// sshError returns the error of a failed request through git-lfs-transfer(1)
+// as the transfer queue expects it, with requests the server failed or asked
+// to be made again later retried, and those it didn't authorize failed as
+// such.
+func sshError(err error) error {
+	serr, ok := err.(*ssh.StatusError)
+	if !ok {
+		return err
+	}
+
+	switch {
+	case serr.Status == 401 || serr.Status == 403:
+		return errors.NewAuthError(err)
+	case serr.Status == 429 || serr.Status >= 500:
+		return errors.NewRetriableError(err)
+	}
+	return err
+}
+
+// sshAdapter transfers objects through git-lfs-transfer(1), with the
+// "get-object" and "put-object" requests. Since the connection exchanges one
+// message at a time, the adapter has a single worker.
+type sshAdapter struct {
+	*adapterBase
+	conn *ssh.Connection
+}
+
+func (a *sshAdapter) Begin(cfg AdapterConfig, cb ProgressCallback) error {
+	conn, err := cfg.APIClient().SSHTransfer(a.direction.String(), cfg.Remote())
+	if err != nil {
+		return err
+	}
+	if conn == nil {
+		return errors.New("ssh: git-lfs-transfer is not available")
+	}
+	a.conn = conn
+
+	return a.adapterBase.Begin(&sshAdapterConfig{AdapterConfig: cfg}, cb)
+}
+
+func (a *sshAdapter) ClearTempStorage() error {
+	// Downloads aren't resumed, so their temporary files are removed
+	// as they finish.
+	return nil
+}
+
+func (a *sshAdapter) WorkerStarting(workerNum int) (interface{}, error) {
+	return nil, nil
+}
+
+func (a *sshAdapter) WorkerEnding(workerNum int, ctx interface{}) {
+}
+
+func (a *sshAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressCallback, authOkFunc func()) error {
+	if authOkFunc != nil {
+		authOkFunc()
+	}
+
+	if a.direction == Upload {
+		return a.upload(t, cb)
+	}
+	return a.download(t, cb)
+}
+
+func (a *sshAdapter) download(t *Transfer, cb ProgressCallback) error {
+	rel, err := t.Rel("download")
+	if err != nil {
+		return err
+	}
+
+	f, err := tools.TempFile(a.fs.TempDir(), t.Oid, a.fs)
+	if err != nil {
+		return fs.NewStorageError(a.fs.TempDir(), err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	hash := tools.NewLfsContentHash()
+	w := &sshProgressWriter{t: t, cb: cb, w: f, hash: hash}
+	res, err := a.conn.Exchange(&ssh.Message{
+		Command: "get-object " + t.Oid,
+		Args:    sshActionArgs(rel),
+	}, w)
+	if err != nil {
+		return err
+	}
+	if err := res.Err(); err != nil {
+		return sshError(err)
+	}
+
+	if actual := fmt.Sprintf("%x", hash.Sum(nil)); actual != t.Oid {
+		return errors.NewRetriableError(fmt.Errorf("expected OID %s, got %s after %d bytes written", t.Oid, actual, w.written))
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("can't close tempfile %q: %v", f.Name(), err)
+	}
+
+	err = tools.RenameFileCopyPermissions(f.Name(), t.Path)
+	if _, err2 := os.Stat(t.Path); err2 == nil {
+		// The object may have been downloaded by another process.
+		return nil
+	}
+	return fs.NewStorageError(t.Path, err)
+}
+
+func (a *sshAdapter) upload(t *Transfer, cb ProgressCallback) error {
+	rel, err := t.Rel("upload")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(t.Path, os.O_RDONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "ssh upload")
+	}
+	defer f.Close()
+
+	body := tools.NewFileBodyWithCallback(f, t.Size, func(totalSize, readSoFar int64, readSinceLast int) error {
+		if cb != nil {
+			return cb(t.Name, totalSize, readSoFar, readSinceLast)
+		}
+		return nil
+	})
+
+	res, err := a.conn.Exchange(&ssh.Message{
+		Command: "put-object " + t.Oid,
+		Args:    append([]string{fmt.Sprintf("size=%d", t.Size)}, sshActionArgs(rel)...),
+		Data:    body,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if err := res.Err(); err != nil {
+		body.ResetProgress()
+		return sshError(err)
+	}
+	return nil
+}
+
+// sshProgressWriter writes the data of a downloaded object to "w" and "hash",
+// reporting the progress of the transfer "t" to "cb".
+type sshProgressWriter struct {
+	t       *Transfer
+	cb      ProgressCallback
+	w       *os.File
+	hash    hash.Hash
+	written int64
+}
+
+func (w *sshProgressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		return n, fs.NewStorageError(w.w.Name(), err)
+	}
+	w.hash.Write(p[:n])
+	w.written += int64(n)
+
+	if w.cb != nil {
+		if err := w.cb(w.t.Name, w.t.Size, w.written, n); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// sshAdapterConfig limits the adapter to a single worker.
+type sshAdapterConfig struct {
+	AdapterConfig
+}
+
+func (c *sshAdapterConfig) ConcurrentTransfers() int {
+	return 1
+}
+
+func configureSSHAdapter(m *Manifest) {
+	newAdapter := func(name string, dir Direction) Adapter {
+		a := &sshAdapter{adapterBase: newAdapterBase(m.fs, name, dir, nil)}
+		// self implements impl
+		a.transferImpl = a
+		return a
+	}
+	m.RegisterNewAdapterFunc(SSHAdapterName, Upload, newAdapter)
+	m.RegisterNewAdapterFunc(SSHAdapterName, Download, newAdapter)
+}
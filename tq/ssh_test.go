@@ -0,0 +1,64 @@
+package tq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSSHBatchObjectWithAction(t *testing.T) {
+	now := time.Now()
+	obj, err := parseSSHBatchObject("abc123 42 download id=1 token=secret expires-in=60", "download", now)
+	require.Nil(t, err)
+
+	assert.Equal(t, "abc123", obj.Oid)
+	assert.EqualValues(t, 42, obj.Size)
+	assert.Nil(t, obj.Error)
+
+	action, err := obj.Rel("download")
+	require.Nil(t, err)
+	require.NotNil(t, action)
+	assert.Equal(t, "1", action.Id)
+	assert.Equal(t, "secret", action.Token)
+	assert.Equal(t, 60, action.ExpiresIn)
+	assert.Equal(t, []string{"id=1", "token=secret"}, sshActionArgs(action))
+}
+
+func TestParseSSHBatchObjectNoop(t *testing.T) {
+	obj, err := parseSSHBatchObject("abc123 42 noop", "upload", time.Now())
+	require.Nil(t, err)
+	assert.Nil(t, obj.Error)
+	assert.Empty(t, obj.Actions)
+
+	obj, err = parseSSHBatchObject("abc123 42 noop", "download", time.Now())
+	require.Nil(t, err)
+	require.NotNil(t, obj.Error)
+	assert.Equal(t, 404, obj.Error.Code)
+}
+
+func TestParseSSHBatchObjectInvalid(t *testing.T) {
+	for _, line := range []string{
+		"abc123 42",
+		"abc123 size download",
+		"abc123 42 verify",
+		"abc123 42 download id",
+		"abc123 42 download expires-at=tomorrow",
+	} {
+		_, err := parseSSHBatchObject(line, "download", time.Now())
+		assert.NotNil(t, err, line)
+	}
+}
+
+func TestSSHError(t *testing.T) {
+	assert.True(t, errors.IsAuthError(sshError(&ssh.StatusError{Status: 403})))
+	assert.True(t, errors.IsRetriableError(sshError(&ssh.StatusError{Status: 503})))
+	assert.True(t, errors.IsRetriableError(sshError(&ssh.StatusError{Status: 429})))
+
+	err := sshError(&ssh.StatusError{Status: 404})
+	assert.False(t, errors.IsRetriableError(err))
+	assert.False(t, errors.IsAuthError(err))
+}
@@ -56,6 +56,21 @@ type Transfer struct {
 	Error         *ObjectError `json:"error,omitempty"`
 	Path          string       `json:"path,omitempty"`
 	Missing       bool         `json:"-"`
+	// ContentType is the Content-Type header the server sent with the
+	// object when it was downloaded, if any.
+	ContentType string `json:"-"`
+	// Elapsed is how long the transfer adapter took to transfer the
+	// object, once it was handed to one of its workers.
+	Elapsed time.Duration `json:"-"`
+}
+
+// BytesPerSecond returns the rate at which the object was transferred, or
+// zero if it took no measurable time.
+func (t *Transfer) BytesPerSecond() int64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(t.Size) / t.Elapsed.Seconds())
 }
 
 func (t *Transfer) Rel(name string) (*Action, error) {
@@ -135,6 +150,11 @@ type Action struct {
 	ExpiresAt time.Time         `json:"expires_at,omitempty"`
 	ExpiresIn int               `json:"expires_in,omitempty"`
 
+	// Id and Token identify the transfer to a server using the SSH
+	// transfer protocol, which has no href, if it gave them.
+	Id    string `json:"-"`
+	Token string `json:"-"`
+
 	createdAt time.Time
 }
 
@@ -193,12 +213,16 @@ type AdapterConfig interface {
 	APIClient() *lfsapi.Client
 	ConcurrentTransfers() int
 	Remote() string
+	// bandwidth returns the limits on the rate at which objects are
+	// transferred, or nil if there are none.
+	bandwidth() *bandwidthLimiter
 }
 
 type adapterConfig struct {
 	apiClient           *lfsapi.Client
 	concurrentTransfers int
 	remote              string
+	bandwidthLimiter    *bandwidthLimiter
 }
 
 func (c *adapterConfig) ConcurrentTransfers() int {
@@ -213,6 +237,10 @@ func (c *adapterConfig) Remote() string {
 	return c.remote
 }
 
+func (c *adapterConfig) bandwidth() *bandwidthLimiter {
+	return c.bandwidthLimiter
+}
+
 // Adapter is implemented by types which can upload and/or download LFS
 // file content to a remote store. Each Adapter accepts one or more requests
 // which it may schedule and parallelise in whatever way it chooses, clients of
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
 	"github.com/git-lfs/git-lfs/git"
 	"github.com/git-lfs/git-lfs/lfshttp"
 	"github.com/git-lfs/git-lfs/tools"
@@ -137,6 +138,22 @@ func (b batch) Len() int           { return len(b) }
 func (b batch) Less(i, j int) bool { return b[i].Size < b[j].Size }
 func (b batch) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 
+// sortTransfers sorts the given transfers by size according to "order", one of
+// the values of "lfs.transfer.order". The default order leaves them as they
+// are.
+func sortTransfers(transfers []*Transfer, order string) {
+	switch order {
+	case orderLargestFirst:
+		sort.SliceStable(transfers, func(i, j int) bool {
+			return transfers[i].Size > transfers[j].Size
+		})
+	case orderSmallestFirst:
+		sort.SliceStable(transfers, func(i, j int) bool {
+			return transfers[i].Size < transfers[j].Size
+		})
+	}
+}
+
 type abortableWaitGroup struct {
 	wq      sync.WaitGroup
 	counter int
@@ -188,7 +205,13 @@ type TransferQueue struct {
 	dryRun            bool
 	cb                tools.CopyCallback
 	meter             *Meter
+	events            *events.Emitter
+	progress          map[string]*objectProgress
+	progressMu        sync.Mutex
 	errors            []error
+	failedTransfers   []*FailedTransfer
+	failedMu          sync.Mutex
+	continueOnError   bool
 	transfers         map[string]*objects
 	batchSize         int
 	bufferDepth       int
@@ -205,12 +228,29 @@ type TransferQueue struct {
 	manifest *Manifest
 	rc       *retryCounter
 
+	// metrics records the transfers of the queue, which was created at
+	// startedAt, if metrics are enabled.
+	metrics   *Metrics
+	startedAt time.Time
+
 	// unsupportedContentType indicates whether the transfer queue ever saw
 	// an HTTP 422 response indicating that their upload destination does
 	// not support Content-Type detection.
 	unsupportedContentType bool
 }
 
+// objectProgress is the progress of the transfer of an object, from which
+// TransferBytes events are emitted.
+type objectProgress struct {
+	oid      string
+	started  time.Time
+	lastSent time.Time
+}
+
+// objectProgressInterval is the least time between the TransferBytes events
+// emitted for an object, other than the last.
+const objectProgressInterval = time.Second
+
 // objects holds a set of objects.
 type objects struct {
 	completed bool
@@ -290,6 +330,13 @@ func WithBufferDepth(depth int) Option {
 	return func(tq *TransferQueue) { tq.bufferDepth = depth }
 }
 
+// ContinueOnError instructs the TransferQueue to fail only the affected
+// objects, rather than abort the whole queue, when a batch of uploads contains
+// objects which are missing locally.
+func ContinueOnError(continueOnError bool) Option {
+	return func(tq *TransferQueue) { tq.continueOnError = continueOnError }
+}
+
 // NewTransferQueue builds a TransferQueue, direction and underlying mechanism determined by adapter
 func NewTransferQueue(dir Direction, manifest *Manifest, remote string, options ...Option) *TransferQueue {
 	q := &TransferQueue{
@@ -302,6 +349,10 @@ func NewTransferQueue(dir Direction, manifest *Manifest, remote string, options
 		manifest:  manifest,
 		rc:        newRetryCounter(),
 		wait:      newAbortableWaitGroup(),
+		events:    events.FromEnvironment(manifest.APIClient().OSEnv()),
+		progress:  make(map[string]*objectProgress),
+		metrics:   manifest.metrics,
+		startedAt: time.Now(),
 	}
 
 	for _, opt := range options {
@@ -312,6 +363,9 @@ func NewTransferQueue(dir Direction, manifest *Manifest, remote string, options
 	q.rc.MaxRetryDelay = q.manifest.maxRetryDelay
 	q.client.MaxRetries = q.manifest.maxRetries
 
+	if q.batchSize <= 0 {
+		q.batchSize = q.manifest.batchSize
+	}
 	if q.batchSize <= 0 {
 		q.batchSize = defaultBatchSize
 	}
@@ -434,8 +488,13 @@ func (q *TransferQueue) collectBatches() {
 		}
 
 		// Before enqueuing the next batch, sort by descending object
-		// size.
-		sort.Sort(sort.Reverse(next))
+		// size, unless the smallest objects are to be transferred
+		// first.
+		if q.manifest.transferOrder == orderSmallestFirst {
+			sort.Sort(next)
+		} else {
+			sort.Sort(sort.Reverse(next))
+		}
 
 		done := make(chan struct{})
 
@@ -449,7 +508,7 @@ func (q *TransferQueue) collectBatches() {
 				return
 			}
 
-			retries, err = q.enqueueAndCollectRetriesFor(next)
+			retries, err = q.enqueueAndCollectRetriesForRoutes(next)
 			if err != nil {
 				q.errorc <- err
 			}
@@ -507,6 +566,36 @@ func (q *TransferQueue) collectPendingUntil(done <-chan struct{}) (pending batch
 	}
 }
 
+// enqueueAndCollectRetriesForRoutes splits "b" by the endpoint to which each of
+// its objects is routed (see: "lfs.route.<pattern>.url"), and makes one Batch
+// API call per endpoint with enqueueAndCollectRetriesFor, returning the retries
+// of all of them.
+//
+// If a call fails with a non-retriable error, the remaining endpoints are not
+// contacted, and that error is returned immediately.
+func (q *TransferQueue) enqueueAndCollectRetriesForRoutes(b batch) (batch, error) {
+	groups := q.manifest.routes.Group(b)
+	if len(groups) == 1 {
+		return q.enqueueAndCollectRetriesFor(groups[0].batch, groups[0].url)
+	}
+
+	next := q.makeBatch()
+	var err error
+	for _, g := range groups {
+		tracerx.Printf("tq: routing %d object(s) to %q", len(g.batch), g.url)
+
+		retries, gerr := q.enqueueAndCollectRetriesFor(g.batch, g.url)
+		if gerr != nil && !errors.IsRetriableError(gerr) {
+			return nil, gerr
+		}
+		if err == nil {
+			err = gerr
+		}
+		next = append(next, retries...)
+	}
+	return next, err
+}
+
 // enqueueAndCollectRetriesFor makes a Batch API call and returns a "next" batch
 // containing all of the objects that failed from the previous batch and had
 // retries availale to them.
@@ -515,14 +604,18 @@ func (q *TransferQueue) collectPendingUntil(done <-chan struct{}) (pending batch
 // from the previous batch (that have retries available to them) will be
 // returned immediately, along with the error that was encountered.
 //
+// The Batch API call is made to the endpoint at "url", or to the remote's own
+// endpoint when "url" is empty.
+//
 // enqueueAndCollectRetriesFor blocks until the entire Batch "batch" has been
 // processed.
-func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error) {
+func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch, url string) (batch, error) {
 	next := q.makeBatch()
 	tracerx.Printf("tq: sending batch of size %d", len(batch))
 
 	enqueueRetry := func(t *objectTuple, err error, readyTime *time.Time) {
 		count := q.rc.Increment(t.Oid)
+		q.metrics.retried(q.direction)
 
 		if readyTime == nil {
 			t.ReadyTime = q.rc.ReadyTime(t.Oid)
@@ -555,7 +648,7 @@ func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error)
 		// Query the Git LFS server for what transfer method to use and
 		// details such as URLs, authentication, etc.
 		var err error
-		bRes, err = Batch(q.manifest, q.direction, q.remote, q.ref, batch.ToTransfers())
+		bRes, err = batchFor(q.manifest, q.direction, q.remote, url, q.ref, batch.ToTransfers())
 		if err != nil {
 			// If there was an error making the batch API call, mark all of
 			// the objects for retry, and return them along with the error
@@ -563,11 +656,15 @@ func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error)
 			// retried, they will be marked as failed.
 			for _, t := range batch {
 				if q.canRetryObject(t.Oid, err) {
+					q.emit(events.TransferRetried, t.ToTransfer(), err)
 					enqueueRetry(t, err, nil)
 				} else if readyTime, canRetry := q.canRetryObjectLater(t.Oid, err); canRetry {
+					q.emit(events.TransferRetried, t.ToTransfer(), err)
 					err = nil
 					enqueueRetry(t, err, &readyTime)
 				} else {
+					q.addFailedTransfer(t.Name, t.Oid, err)
+					q.emit(events.TransferFailed, t.ToTransfer(), err)
 					q.wait.Done()
 				}
 			}
@@ -584,25 +681,53 @@ func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error)
 	// and abort if any are missing. We'll never have any objects marked as
 	// missing except possibly on upload, so just skip iterating over the
 	// objects in that case.
+	//
+	// If continueOnError is set, fail only the missing objects instead.
 	if q.direction == Upload {
+		present := make([]*Transfer, 0, len(bRes.Objects))
 		for _, o := range bRes.Objects {
 			// If the server already has the object, the list of
 			// actions will be empty. It's fine if the file is
 			// missing in that case, since we don't need to upload
 			// it.
-			if o.Missing && len(o.Actions) != 0 {
+			if !o.Missing || len(o.Actions) == 0 {
+				present = append(present, o)
+				continue
+			}
+			if !q.continueOnError {
 				return nil, errors.Errorf("Unable to find source for object %v (try running git lfs fetch --all)", o.Oid)
 			}
+
+			name := q.nameFor(o.Oid)
+			err := newObjectMissingError(name, o.Oid)
+
+			q.emit(events.ObjectMissing, o, nil)
+			q.addFailedTransfer(name, o.Oid, err)
+			q.errorc <- err
+			q.Skip(o.Size)
+			q.wait.Done()
 		}
+		bRes.Objects = present
 	}
 
 	q.useAdapter(bRes.TransferAdapterName)
 	q.meter.Start()
 
+	// The server may return objects in any order, so restore the
+	// configured one, if any, before starting transfers.
+	sortTransfers(bRes.Objects, q.manifest.transferOrder)
+
 	toTransfer := make([]*Transfer, 0, len(bRes.Objects))
 
 	for _, o := range bRes.Objects {
 		if o.Error != nil {
+			if o.Error.Code == 404 {
+				q.emit(events.ObjectMissing, o, nil)
+			} else {
+				q.emit(events.TransferFailed, o, o.Error)
+			}
+
+			q.addFailedTransfer(q.nameFor(o.Oid), o.Oid, o.Error)
 			q.errorc <- errors.Wrapf(o.Error, "[%v] %v", o.Oid, o.Error.Message)
 			q.Skip(o.Size)
 			q.wait.Done()
@@ -618,7 +743,9 @@ func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error)
 			// Transfer object, then we give up on the
 			// transfer by telling the progress meter to
 			// skip the number of bytes in "o".
-			q.errorc <- errors.Errorf("[%v] The server returned an unknown OID.", o.Oid)
+			err := errors.Errorf("[%v] The server returned an unknown OID.", o.Oid)
+			q.addFailedTransfer("", o.Oid, err)
+			q.errorc <- err
 
 			q.Skip(o.Size)
 			q.wait.Done()
@@ -629,8 +756,11 @@ func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error)
 
 			if a, err := tr.Rel(q.direction.String()); err != nil {
 				if q.canRetryObject(tr.Oid, err) {
+					q.emit(events.TransferRetried, tr, err)
 					enqueueRetry(objects.First(), err, nil)
 				} else {
+					q.addFailedTransfer(tr.Name, tr.Oid, err)
+					q.emit(events.TransferFailed, tr, err)
 					q.errorc <- errors.Errorf("[%v] %v", tr.Name, err)
 
 					q.Skip(o.Size)
@@ -641,6 +771,7 @@ func (q *TransferQueue) enqueueAndCollectRetriesFor(batch batch) (batch, error)
 				q.wait.Done()
 			} else {
 				q.meter.StartTransfer(objects.First().Name)
+				q.emit(events.TransferStarted, tr, nil)
 				toTransfer = append(toTransfer, tr)
 			}
 		}
@@ -672,6 +803,7 @@ func (q *TransferQueue) addToAdapter(e lfshttp.Endpoint, pending []*Transfer) <-
 
 		q.errorc <- err
 		for _, t := range pending {
+			q.addFailedTransfer(t.Name, t.Oid, err)
 			q.Skip(t.Size)
 			q.wait.Done()
 		}
@@ -776,6 +908,7 @@ func (q *TransferQueue) handleTransferResult(
 			if ok {
 				t := objects.First()
 				t.ReadyTime = readyTime
+				q.emit(events.TransferRetried, res.Transfer, res.Error)
 				retries <- t
 			} else {
 				q.errorc <- res.Error
@@ -791,6 +924,7 @@ func (q *TransferQueue) handleTransferResult(
 			q.trMutex.Unlock()
 
 			if ok {
+				q.emit(events.TransferRetried, res.Transfer, res.Error)
 				retries <- objects.First()
 			} else {
 				q.errorc <- res.Error
@@ -804,8 +938,15 @@ func (q *TransferQueue) handleTransferResult(
 			if errors.IsUnprocessableEntityError(res.Error) {
 				q.unsupportedContentType = true
 			} else {
+				q.addFailedTransfer(res.Transfer.Name, oid, res.Error)
 				q.errorc <- res.Error
 			}
+
+			if merr, ok := res.Error.(*MalformedObjectError); ok && merr.Missing() {
+				q.emit(events.ObjectMissing, res.Transfer, nil)
+			} else {
+				q.emit(events.TransferFailed, res.Transfer, res.Error)
+			}
 			q.wait.Done()
 		}
 	} else {
@@ -820,10 +961,12 @@ func (q *TransferQueue) handleTransferResult(
 			// same OID.
 			for _, t := range objects.All() {
 				c <- &Transfer{
-					Name: t.Name,
-					Path: t.Path,
-					Oid:  t.Oid,
-					Size: t.Size,
+					Name:        t.Name,
+					Path:        t.Path,
+					Oid:         t.Oid,
+					Size:        t.Size,
+					ContentType: res.Transfer.ContentType,
+					Elapsed:     res.Transfer.Elapsed,
 				}
 			}
 		}
@@ -831,10 +974,112 @@ func (q *TransferQueue) handleTransferResult(
 		q.trMutex.Unlock()
 
 		q.meter.FinishTransfer(res.Transfer.Name)
+		q.metrics.transferred(q.direction, res.Transfer.Size)
+		q.emit(events.TransferFinished, res.Transfer, nil)
 		q.wait.Done()
 	}
 }
 
+// addFailedTransfer records that the transfer of the object "oid", named "name",
+// failed with the given error, and will not be retried.
+func (q *TransferQueue) addFailedTransfer(name, oid string, err error) {
+	q.failedMu.Lock()
+	defer q.failedMu.Unlock()
+
+	q.metrics.failed(q.direction)
+	q.failedTransfers = append(q.failedTransfers, &FailedTransfer{
+		Name: name,
+		Oid:  oid,
+		Err:  err,
+	})
+}
+
+// nameFor returns the name of the first transfer added for the object "oid",
+// or the empty string if there is none.
+func (q *TransferQueue) nameFor(oid string) string {
+	q.trMutex.Lock()
+	defer q.trMutex.Unlock()
+
+	if objects, ok := q.transfers[oid]; ok && objects.First() != nil {
+		return objects.First().Name
+	}
+	return ""
+}
+
+// emit writes an event of the given type describing the transfer "t" to the
+// event stream, if one is configured.
+func (q *TransferQueue) emit(typ events.Type, t *Transfer, err error) {
+	ev := &events.Event{
+		Type:      typ,
+		Path:      t.Name,
+		Oid:       t.Oid,
+		Size:      t.Size,
+		Direction: q.direction.String(),
+	}
+	switch typ {
+	case events.TransferFinished:
+		ev.Elapsed = t.Elapsed.Nanoseconds() / int64(time.Millisecond)
+		ev.BytesPerSecond = t.BytesPerSecond()
+		ev.Retries = q.rc.CountFor(t.Oid)
+	case events.TransferFailed:
+		ev.Retries = q.rc.CountFor(t.Oid)
+	case events.TransferRetried:
+		// The retry is counted once the object is enqueued again.
+		ev.Retries = q.rc.CountFor(t.Oid) + 1
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	if q.events != nil {
+		q.progressMu.Lock()
+		if typ == events.TransferStarted {
+			q.progress[t.Name] = &objectProgress{oid: t.Oid, started: time.Now()}
+		} else {
+			delete(q.progress, t.Name)
+		}
+		q.progressMu.Unlock()
+	}
+
+	q.events.Emit(ev)
+}
+
+// emitBytes writes a TransferBytes event for the object being transferred as
+// "name", of which "read" of "total" bytes have been transferred, to the event
+// stream, if one is configured. Events are written at most once for each
+// objectProgressInterval, and once the whole object has been transferred.
+func (q *TransferQueue) emitBytes(name string, total, read int64) {
+	if q.events == nil {
+		return
+	}
+
+	now := time.Now()
+	q.progressMu.Lock()
+	p, ok := q.progress[name]
+	if !ok || (read < total && now.Sub(p.lastSent) < objectProgressInterval) {
+		q.progressMu.Unlock()
+		return
+	}
+	p.lastSent = now
+	if read >= total {
+		delete(q.progress, name)
+	}
+	q.progressMu.Unlock()
+
+	ev := &events.Event{
+		Type:      events.TransferBytes,
+		Path:      name,
+		Oid:       p.oid,
+		Size:      total,
+		Bytes:     read,
+		Direction: q.direction.String(),
+	}
+	if elapsed := now.Sub(p.started); elapsed > 0 {
+		ev.BytesPerSecond = int64(float64(read) / elapsed.Seconds())
+	}
+	q.events.Emit(ev)
+}
+
 func (q *TransferQueue) useAdapter(name string) {
 	q.adapterInitMutex.Lock()
 	defer q.adapterInitMutex.Unlock()
@@ -882,6 +1127,7 @@ func (q *TransferQueue) ensureAdapterBegun(e lfshttp.Endpoint) error {
 	// Progress callback - receives byte updates
 	cb := func(name string, total, read int64, current int) error {
 		q.meter.TransferBytes(q.direction.String(), name, read, total, current)
+		q.emitBytes(name, total, read)
 		if q.cb != nil {
 			// NOTE: this is the mechanism by which the logpath
 			// specified by GIT_LFS_PROGRESS is written to.
@@ -910,6 +1156,7 @@ func (q *TransferQueue) toAdapterCfg(e lfshttp.Endpoint) AdapterConfig {
 		concurrentTransfers: concurrency,
 		apiClient:           apiClient,
 		remote:              q.remote,
+		bandwidthLimiter:    q.manifest.bandwidth,
 	}
 }
 
@@ -943,6 +1190,10 @@ func (q *TransferQueue) Wait() {
 	q.meter.Flush()
 	q.errorwait.Wait()
 
+	if err := q.metrics.finished(q.direction, q.startedAt, q.manifest.ConcurrentTransfers()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to write transfer metrics to %s: %s\n", q.metrics.Path, err)
+	}
+
 	if q.unsupportedContentType {
 		for _, line := range contentTypeWarning {
 			fmt.Fprintf(os.Stderr, "info: %s\n", line)
@@ -1014,3 +1265,12 @@ func (q *TransferQueue) canRetryObjectLater(oid string, err error) (time.Time, b
 func (q *TransferQueue) Errors() []error {
 	return q.errors
 }
+
+// FailedTransfers returns the objects which failed to transfer, along with the
+// error for each. It should only be called once Wait has returned.
+func (q *TransferQueue) FailedTransfers() []*FailedTransfer {
+	q.failedMu.Lock()
+	defer q.failedMu.Unlock()
+
+	return q.failedTransfers
+}
@@ -1,10 +1,22 @@
 package tq
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/git-lfs/git-lfs/errors"
+	"github.com/git-lfs/git-lfs/events"
+	"github.com/git-lfs/git-lfs/lfsapi"
+	"github.com/git-lfs/git-lfs/lfshttp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestManifestDefaultsToFixedRetries(t *testing.T) {
@@ -80,3 +92,137 @@ func TestBatchSizeReturnsBatchSize(t *testing.T) {
 
 	assert.Equal(t, 3, q.BatchSize())
 }
+
+func TestTransferQueueChunksBatchesByBatchSize(t *testing.T) {
+	for _, c := range []struct {
+		objects, batchSize, requests int
+	}{
+		{10, 3, 4},
+		{10, 5, 2},
+		{10, 100, 1},
+		{250, 0, 3},
+	} {
+		var mu sync.Mutex
+		var sizes []int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bReq := &batchRequest{}
+			require.Nil(t, json.NewDecoder(r.Body).Decode(bReq))
+
+			mu.Lock()
+			sizes = append(sizes, len(bReq.Objects))
+			mu.Unlock()
+
+			for _, o := range bReq.Objects {
+				o.Error = &ObjectError{Code: 404, Message: "not found"}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&BatchResponse{Objects: bReq.Objects})
+		}))
+
+		vals := map[string]string{"lfs.url": srv.URL}
+		if c.batchSize > 0 {
+			vals["lfs.transfer.batchsize"] = fmt.Sprintf("%d", c.batchSize)
+		}
+		cli, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, vals))
+		require.Nil(t, err)
+
+		q := NewTransferQueue(Download, NewManifest(nil, cli, "", ""), "origin")
+		for i := 0; i < c.objects; i++ {
+			q.Add(fmt.Sprintf("file%d", i), "", fmt.Sprintf("%064d", i), 1, false, nil)
+		}
+		q.Wait()
+		srv.Close()
+
+		total := 0
+		for _, size := range sizes {
+			total += size
+		}
+		assert.Len(t, sizes, c.requests, "%d object(s), batch size %d", c.objects, c.batchSize)
+		assert.Equal(t, c.objects, total, "%d object(s), batch size %d", c.objects, c.batchSize)
+	}
+}
+
+func TestSortTransfers(t *testing.T) {
+	sizes := func(transfers []*Transfer) []int64 {
+		s := make([]int64, 0, len(transfers))
+		for _, t := range transfers {
+			s = append(s, t.Size)
+		}
+		return s
+	}
+
+	for order, expected := range map[string][]int64{
+		orderDefault:       {2, 3, 1},
+		orderLargestFirst:  {3, 2, 1},
+		orderSmallestFirst: {1, 2, 3},
+	} {
+		transfers := []*Transfer{{Size: 2}, {Size: 3}, {Size: 1}}
+		sortTransfers(transfers, order)
+
+		assert.Equal(t, expected, sizes(transfers), order)
+	}
+}
+
+func TestTransferQueueEmitsObjectBytes(t *testing.T) {
+	var buf bytes.Buffer
+	q := &TransferQueue{
+		direction: Download,
+		events:    events.NewEmitter(&buf),
+		progress:  make(map[string]*objectProgress),
+		rc:        newRetryCounter(),
+	}
+
+	// Nothing is emitted for an object which hasn't been started.
+	q.emitBytes("a.dat", 10, 5)
+	assert.Empty(t, buf.String())
+
+	q.emit(events.TransferStarted, &Transfer{Name: "a.dat", Oid: "oid", Size: 10}, nil)
+	buf.Reset()
+
+	q.emitBytes("a.dat", 10, 2)
+	q.emitBytes("a.dat", 10, 5)
+	q.emitBytes("a.dat", 10, 10)
+	q.emitBytes("a.dat", 10, 10)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var ev map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &ev))
+	assert.Equal(t, "transfer-bytes", ev["event"])
+	assert.Equal(t, "oid", ev["oid"])
+	assert.Equal(t, "a.dat", ev["path"])
+	assert.Equal(t, float64(2), ev["bytes"])
+	assert.Equal(t, float64(10), ev["size"])
+
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &ev))
+	assert.Equal(t, float64(10), ev["bytes"])
+}
+
+func TestTransferQueueEmitsRetries(t *testing.T) {
+	var buf bytes.Buffer
+	q := &TransferQueue{
+		direction: Upload,
+		events:    events.NewEmitter(&buf),
+		progress:  make(map[string]*objectProgress),
+		rc:        newRetryCounter(),
+	}
+
+	tr := &Transfer{Name: "a.dat", Oid: "oid", Size: 10}
+	q.emit(events.TransferRetried, tr, errors.New("boom"))
+	q.rc.Increment("oid")
+	q.emit(events.TransferFinished, tr, nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var ev map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &ev))
+	assert.Equal(t, "transfer-retried", ev["event"])
+	assert.Equal(t, float64(1), ev["retries"])
+	assert.Equal(t, "boom", ev["error"])
+
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &ev))
+	assert.Equal(t, "transfer-finished", ev["event"])
+	assert.Equal(t, float64(1), ev["retries"])
+}
@@ -2,6 +2,7 @@ package tq
 
 import (
 	"testing"
+	"time"
 
 	"github.com/git-lfs/git-lfs/lfsapi"
 	"github.com/git-lfs/git-lfs/lfshttp"
@@ -140,3 +141,11 @@ func testAdapterRegButBasicOnly(t *testing.T) {
 	lu := m.GetUploadAdapterNames()
 	assert.Equal([]string{BasicAdapterName}, lu)
 }
+
+func TestTransferBytesPerSecond(t *testing.T) {
+	tr := &Transfer{Size: 3000, Elapsed: 1500 * time.Millisecond}
+	assert.Equal(t, int64(2000), tr.BytesPerSecond())
+
+	tr.Elapsed = 0
+	assert.Equal(t, int64(0), tr.BytesPerSecond())
+}
@@ -74,7 +74,7 @@ func (a *tusUploadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressC
 	// Batch API will probably already detect this, but handle just in case
 	if offset >= t.Size {
 		a.Trace("xfer: tus.io HEAD offset %d indicates %q is already fully uploaded, skipping", offset, t.Oid)
-		advanceCallbackProgress(cb, t, t.Size)
+		advanceCallbackProgress(a.cb, t, t.Size)
 		return nil
 	}
 
@@ -90,7 +90,7 @@ func (a *tusUploadAdapter) DoTransfer(ctx interface{}, t *Transfer, cb ProgressC
 		a.Trace("xfer: tus.io uploading %q from start", t.Oid)
 	} else {
 		a.Trace("xfer: tus.io resuming upload %q from %d", t.Oid, offset)
-		advanceCallbackProgress(cb, t, offset)
+		advanceCallbackProgress(a.cb, t, offset)
 	}
 
 	// 2. Send PATCH request with byte start point (even if 0) in Upload-Offset
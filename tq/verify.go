@@ -2,6 +2,7 @@ package tq
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/git-lfs/git-lfs/lfsapi"
 	"github.com/git-lfs/git-lfs/tools"
@@ -11,8 +12,48 @@ import (
 const (
 	maxVerifiesConfigKey     = "lfs.transfer.maxverifies"
 	defaultMaxVerifyAttempts = 3
+
+	uploadVerifyConfigKey = "lfs.transfer.uploadverify"
+)
+
+// uploadVerifyMode is how the verify action returned for an uploaded object
+// is treated, as configured by lfs.transfer.uploadverify.
+type uploadVerifyMode int
+
+const (
+	// uploadVerifyAuto issues the verify action if the server returned
+	// one, but treats a server which doesn't implement it as though it
+	// hadn't.
+	uploadVerifyAuto uploadVerifyMode = iota
+	// uploadVerifyAlways issues the verify action if the server returned
+	// one, and fails the transfer if it couldn't be verified.
+	uploadVerifyAlways
+	// uploadVerifyNever never issues the verify action.
+	uploadVerifyNever
 )
 
+// getUploadVerifyMode returns the uploadVerifyMode configured for "c". Values
+// other than "auto" are read as booleans, and an unset or invalid value is
+// taken to be "auto".
+func getUploadVerifyMode(c *lfsapi.Client) uploadVerifyMode {
+	v, ok := c.GitEnv().Get(uploadVerifyConfigKey)
+	if !ok || strings.EqualFold(v, "auto") {
+		return uploadVerifyAuto
+	}
+
+	switch strings.ToLower(v) {
+	case "true", "1", "on", "yes", "t":
+		return uploadVerifyAlways
+	case "false", "0", "off", "no", "f":
+		return uploadVerifyNever
+	default:
+		tracerx.Printf("tq: invalid value for %s: %q, using \"auto\"", uploadVerifyConfigKey, v)
+		return uploadVerifyAuto
+	}
+}
+
+// verifyUpload issues the verify action of the uploaded object "t", if it has
+// one and lfs.transfer.uploadverify allows it.
 func verifyUpload(c *lfsapi.Client, remote string, t *Transfer) error {
 	action, err := t.Actions.Get("verify")
 	if err != nil {
@@ -22,6 +63,12 @@ func verifyUpload(c *lfsapi.Client, remote string, t *Transfer) error {
 		return nil
 	}
 
+	mode := getUploadVerifyMode(c)
+	if mode == uploadVerifyNever {
+		tracerx.Printf("tq: skipping verify of %s, disabled by %s", t.Oid[:7], uploadVerifyConfigKey)
+		return nil
+	}
+
 	req, err := http.NewRequest("POST", action.Href, nil)
 	if err != nil {
 		return err
@@ -57,6 +104,10 @@ func verifyUpload(c *lfsapi.Client, remote string, t *Transfer) error {
 
 		if err != nil {
 			tracerx.Printf("tq: verify err: %+v", err.Error())
+			if mode == uploadVerifyAuto && isVerifyUnsupported(res) {
+				tracerx.Printf("tq: server does not implement verify, skipping verify of %s", t.Oid[:7])
+				return nil
+			}
 		} else {
 			err = res.Body.Close()
 			break
@@ -64,3 +115,9 @@ func verifyUpload(c *lfsapi.Client, remote string, t *Transfer) error {
 	}
 	return err
 }
+
+// isVerifyUnsupported returns whether "res" is the response of a server which
+// doesn't implement the verify action it returned.
+func isVerifyUnsupported(res *http.Response) bool {
+	return res != nil && (res.StatusCode == 404 || res.StatusCode == 501)
+}
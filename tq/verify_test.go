@@ -70,3 +70,70 @@ func TestVerifySuccess(t *testing.T) {
 	assert.Nil(t, verifyUpload(c, "origin", tr))
 	assert.EqualValues(t, 1, called)
 }
+
+func TestVerifyDisabled(t *testing.T) {
+	var called uint32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint32(&called, 1)
+	}))
+	defer srv.Close()
+
+	c, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, map[string]string{
+		"lfs.transfer.uploadverify": "false",
+	}))
+	require.Nil(t, err)
+	tr := &Transfer{
+		Oid:     "abcd1234",
+		Size:    123,
+		Actions: map[string]*Action{"verify": &Action{Href: srv.URL + "/verify"}},
+	}
+
+	assert.Nil(t, verifyUpload(c, "origin", tr))
+	assert.EqualValues(t, 0, called)
+}
+
+func TestVerifyUnsupported(t *testing.T) {
+	for desc, c := range map[string]struct {
+		Config map[string]string
+		Fails  bool
+	}{
+		"auto by default": {
+			Config: map[string]string{},
+		},
+		"auto": {
+			Config: map[string]string{"lfs.transfer.uploadverify": "auto"},
+		},
+		"true": {
+			Config: map[string]string{"lfs.transfer.uploadverify": "true"},
+			Fails:  true,
+		},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			var called uint32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddUint32(&called, 1)
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer srv.Close()
+
+			c.Config["lfs.transfer.maxverifies"] = "3"
+			c.Config["lfs."+srv.URL+".access"] = "None"
+			client, err := lfsapi.NewClient(lfshttp.NewContext(nil, nil, c.Config))
+			require.Nil(t, err)
+			tr := &Transfer{
+				Oid:     "abcd1234",
+				Size:    123,
+				Actions: map[string]*Action{"verify": &Action{Href: srv.URL + "/verify"}},
+			}
+
+			err = verifyUpload(client, "origin", tr)
+			if c.Fails {
+				assert.NotNil(t, err)
+				assert.EqualValues(t, 3, called)
+			} else {
+				assert.Nil(t, err)
+				assert.EqualValues(t, 1, called)
+			}
+		})
+	}
+}